@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// componentsHandler lists the engine's detectors and SHIELD modules with their settings
+func componentsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireRole(w, r, RoleAdmin, RoleAuditor); !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(engine.Components())
+}
+
+// detectorsHandler documents every detector and SHIELD module, for showing
+// why a finding was made
+func detectorsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(engine.ComponentDocs())
+}
+
+// updateComponentHandler enables, disables or sets the confidence threshold of a component
+func updateComponentHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	var update aegong.ComponentUpdate
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&update); err != nil {
+		http.Error(w, "Request body must be JSON with \"enabled\" and/or \"min_confidence\"", http.StatusBadRequest)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if !componentExists(name) {
+		http.Error(w, "Component not found", http.StatusNotFound)
+		return
+	}
+
+	status, err := engine.UpdateComponent(name, update, principal.Name, string(principal.Role))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Component %s updated by %s: enabled=%t min_confidence=%g", name, principal.Name, status.Enabled, status.MinConfidence)
+	// Only warn, even in strict mode: the server is already running
+	runSelfTest(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func componentExists(name string) bool {
+	for _, component := range engine.Components() {
+		if component.Name == name {
+			return true
+		}
+	}
+	return false
+}
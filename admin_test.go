@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// TestComponentsAPI tests role checks and runtime updates of detectors and shields
+func TestComponentsAPI(t *testing.T) {
+	oldTokens, oldEngine := apiTokens, engine
+	t.Cleanup(func() { apiTokens, engine = oldTokens, oldEngine })
+	apiTokens, _ = loadAPITokens("alice:admin:admin,ci:auditor:audit,bob:viewer:view")
+	engine, _ = aegong.NewEngine(aegong.Config{})
+	defer engine.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/admin/components", componentsHandler).Methods("GET")
+	router.HandleFunc("/api/admin/components/{name}", updateComponentHandler).Methods("PATCH")
+
+	request := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := request("GET", "/api/admin/components", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Listing without a token should return 401, got %d", rec.Code)
+	}
+	if rec := request("GET", "/api/admin/components", "view", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("Viewers should not list components, got %d", rec.Code)
+	}
+	if rec := request("GET", "/api/admin/components", "audit", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"name":"T1"`) {
+		t.Fatalf("Auditors should list components, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if rec := request("PATCH", "/api/admin/components/T3", "audit", `{"enabled":false}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("Auditors should not change components, got %d", rec.Code)
+	}
+	if rec := request("PATCH", "/api/admin/components/T42", "admin", `{"enabled":false}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("Unknown component should return 404, got %d", rec.Code)
+	}
+	if rec := request("PATCH", "/api/admin/components/T3", "admin", `{"min_confidence":2}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("Out of range threshold should return 400, got %d", rec.Code)
+	}
+
+	rec := request("PATCH", "/api/admin/components/T3", "admin", `{"enabled":false,"min_confidence":0.5}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"enabled":false`) {
+		t.Fatalf("Admin should disable T3, got %d: %s", rec.Code, rec.Body)
+	}
+	for _, component := range engine.Components() {
+		if component.Name == "T3" && (component.Enabled || component.MinConfidence != 0.5) {
+			t.Fatalf("T3 should be disabled with a 0.5 threshold, got %+v", component)
+		}
+	}
+}
+
+// TestDetectorsAPI tests that detector documentation is served without a token
+func TestDetectorsAPI(t *testing.T) {
+	oldEngine := engine
+	t.Cleanup(func() { engine = oldEngine })
+	engine, _ = aegong.NewEngine(aegong.Config{})
+	defer engine.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/detectors", detectorsHandler).Methods("GET")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/detectors", nil))
+
+	var docs []aegong.ComponentDoc
+	if err := json.Unmarshal(rec.Body.Bytes(), &docs); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("Should list detector docs, got %d: %s", rec.Code, rec.Body)
+	}
+	if len(docs) != 15 || docs[3].Name != "T4" || docs[3].Title != "Unauthorized Action" || len(docs[3].LooksFor) == 0 || docs[9].Kind != aegong.ComponentShield {
+		t.Errorf("Should document the 9 detectors then the 6 shields, got %+v", docs)
+	}
+}
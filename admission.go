@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// In admission webhook mode the server doubles as a Kubernetes
+// ValidatingAdmissionWebhook. The API server sends it every pod, or pod
+// template of a workload, being created or updated, and the webhook only
+// admits it if each container image and each agent binary listed in the
+// aegong.io/agent-sha256 annotation was audited: images must be pinned by
+// digest and audited from the same registry and repository, with a manifest
+// that had that digest. The newest report for each image or binary must be
+// no riskier than
+// AEGONG_ADMISSION_MAX_RISK and free of capability policy violations, and
+// its agent must not be retired in the agent registry. Images matching the
+// patterns in AEGONG_ADMISSION_EXEMPT, such as sidecars, are not checked.
+
+// Annotation listing the SHA-256 of agent binaries a pod runs, comma separated
+const agentDigestAnnotation = "aegong.io/agent-sha256"
+
+// Largest AdmissionReview read; Kubernetes objects are at most a few MB
+const maxAdmissionReviewBytes = 4 << 20
+
+var sha256Digest = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// admissionPolicy is how the webhook decides; nil when the mode is off
+type admissionPolicy struct {
+	maxRisk string   // Highest risk level admitted
+	exempt  []string // path.Match patterns of image names not checked
+}
+
+var admission *admissionPolicy
+
+// initAdmission reads AEGONG_ADMISSION_WEBHOOK, AEGONG_ADMISSION_MAX_RISK
+// and AEGONG_ADMISSION_EXEMPT
+func initAdmission() error {
+	admission = nil
+	if os.Getenv("AEGONG_ADMISSION_WEBHOOK") != "1" {
+		return nil
+	}
+	policy := &admissionPolicy{maxRisk: "HIGH"}
+	if value := os.Getenv("AEGONG_ADMISSION_MAX_RISK"); value != "" {
+		policy.maxRisk = strings.ToUpper(value)
+		if riskLevelRank(policy.maxRisk) < 0 {
+			return fmt.Errorf("AEGONG_ADMISSION_MAX_RISK must be one of %s, got %q", strings.Join(riskLevels, ", "), value)
+		}
+	}
+	for _, pattern := range strings.Split(os.Getenv("AEGONG_ADMISSION_EXEMPT"), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid AEGONG_ADMISSION_EXEMPT pattern %q: %v", pattern, err)
+		}
+		policy.exempt = append(policy.exempt, pattern)
+	}
+	admission = policy
+	return nil
+}
+
+// admissionReview is the part of a Kubernetes admission.k8s.io/v1
+// AdmissionReview the webhook reads and writes
+type admissionReview struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Request    *admissionRequest  `json:"request,omitempty"`
+	Response   *admissionResponse `json:"response,omitempty"`
+}
+
+type admissionRequest struct {
+	UID  string `json:"uid"`
+	Kind struct {
+		Kind string `json:"kind"`
+	} `json:"kind"`
+	Namespace string          `json:"namespace"`
+	Name      string          `json:"name"`
+	Operation string          `json:"operation"`
+	Object    json.RawMessage `json:"object"`
+}
+
+type admissionResponse struct {
+	UID      string           `json:"uid"`
+	Allowed  bool             `json:"allowed"`
+	Status   *admissionStatus `json:"status,omitempty"`
+	Warnings []string         `json:"warnings,omitempty"`
+}
+
+type admissionStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// podTemplate is the metadata and containers of a pod
+type podTemplate struct {
+	Metadata struct {
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		Containers          []struct{ Image string } `json:"containers"`
+		InitContainers      []struct{ Image string } `json:"initContainers"`
+		EphemeralContainers []struct{ Image string } `json:"ephemeralContainers"`
+	} `json:"spec"`
+}
+
+// admittedObject finds the pod in a pod, a workload with a pod template, or
+// a CronJob
+type admittedObject struct {
+	Spec struct {
+		Template    *podTemplate `json:"template"`
+		JobTemplate *struct {
+			Spec struct {
+				Template *podTemplate `json:"template"`
+			} `json:"spec"`
+		} `json:"jobTemplate"`
+	} `json:"spec"`
+}
+
+// pod returns the pod template of an object, or the object itself for a pod
+func (o *admittedObject) pod(data []byte) *podTemplate {
+	switch {
+	case o.Spec.Template != nil:
+		return o.Spec.Template
+	case o.Spec.JobTemplate != nil && o.Spec.JobTemplate.Spec.Template != nil:
+		return o.Spec.JobTemplate.Spec.Template
+	}
+	var pod podTemplate
+	json.Unmarshal(data, &pod)
+	return &pod
+}
+
+// admissionSubject is an image or binary the webhook checks
+type admissionSubject struct {
+	name   string // Image reference or annotation
+	digest string // Hex SHA-256
+	image  string // Registry and repository of an image; empty for binaries
+}
+
+// imageDigest splits an image reference into its name and sha256 digest
+func imageDigest(image string) (string, string, bool) {
+	name, digest, ok := strings.Cut(image, "@sha256:")
+	if !ok || !sha256Digest.MatchString(digest) {
+		return image, "", false
+	}
+	return name, digest, true
+}
+
+// matches reports whether a report is of the subject: for an image, an
+// audit of the same registry and repository whose manifest had the digest,
+// and for a binary, an audit of a file with the digest. The URL an audit was
+// requested with is not trusted, as any registry can claim any digest.
+func (s admissionSubject) matches(report *aegong.AuditReport) bool {
+	if s.image == "" {
+		return report.AgentHash == s.digest
+	}
+	source := report.Source
+	return source != nil && source.Kind == "oci" && source.ManifestDigest == s.digest && strings.EqualFold(source.Image, s.image)
+}
+
+// review decides whether to admit a pod, returning the reasons for
+// rejecting it and warnings about exempt images
+func (p *admissionPolicy) review(pod *podTemplate, reports []*aegong.AuditReport) (denials, warnings []string) {
+	var subjects []admissionSubject
+	var images []string
+	for _, containers := range [][]struct{ Image string }{pod.Spec.InitContainers, pod.Spec.Containers, pod.Spec.EphemeralContainers} {
+		for _, container := range containers {
+			images = append(images, container.Image)
+		}
+	}
+	for _, image := range images {
+		name, digest, pinned := imageDigest(image)
+		if p.exempted(name) {
+			warnings = append(warnings, fmt.Sprintf("AEGONG did not check exempt image %s", image))
+			continue
+		}
+		if !pinned {
+			denials = append(denials, fmt.Sprintf("image %s is not pinned by sha256 digest", image))
+			continue
+		}
+		ref, err := parseOCIReference(name)
+		if err != nil {
+			denials = append(denials, err.Error())
+			continue
+		}
+		subjects = append(subjects, admissionSubject{name: image, digest: digest, image: ref.registry + "/" + ref.repository})
+	}
+	if value, ok := pod.Metadata.Annotations[agentDigestAnnotation]; ok {
+		for _, digest := range strings.Split(value, ",") {
+			digest = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(digest), "sha256:"))
+			if !sha256Digest.MatchString(digest) {
+				denials = append(denials, fmt.Sprintf("%s annotation has an invalid digest %q", agentDigestAnnotation, digest))
+				continue
+			}
+			subjects = append(subjects, admissionSubject{name: "agent sha256:" + digest[:12], digest: digest})
+		}
+	}
+
+	for _, subject := range subjects {
+		var latest *aegong.AuditReport
+		for _, report := range reports {
+			if subject.matches(report) && (latest == nil || report.Timestamp.After(latest.Timestamp)) {
+				latest = report
+			}
+		}
+		if latest == nil {
+			denials = append(denials, fmt.Sprintf("%s has not been audited", subject.name))
+			continue
+		}
+		hash := latest.AgentHash[:8]
+		if riskLevelRank(latest.RiskLevel) > riskLevelRank(p.maxRisk) {
+			denials = append(denials, fmt.Sprintf("%s was rated %s in report %s, above the %s allowed", subject.name, latest.RiskLevel, hash, p.maxRisk))
+		}
+		if latest.Policy != nil && len(latest.Policy.Violations) > 0 {
+			denials = append(denials, fmt.Sprintf("%s violates the capability policy %d times in report %s", subject.name, len(latest.Policy.Violations), hash))
+		}
+		if agent := registeredAgentNamed(latest.AgentName); agent != nil && agent.State == agentRetired {
+			denials = append(denials, fmt.Sprintf("%s is agent %s, which is retired", subject.name, agent.Name))
+		}
+	}
+	return denials, warnings
+}
+
+// exempted reports whether an image name matches an exempt pattern
+func (p *admissionPolicy) exempted(name string) bool {
+	// Tags are not part of the name patterns are matched against
+	if slash := strings.LastIndex(name, "/"); strings.LastIndex(name, ":") > slash {
+		name = name[:strings.LastIndex(name, ":")]
+	}
+	for _, pattern := range p.exempt {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// registeredAgentNamed returns the registered agent with a name, if any
+func registeredAgentNamed(name string) *registeredAgent {
+	if name == "" {
+		return nil
+	}
+	agentRegistry.mutex.RLock()
+	defer agentRegistry.mutex.RUnlock()
+	for _, agent := range agentRegistry.agents {
+		if agent.Name == name {
+			return &agent
+		}
+	}
+	return nil
+}
+
+// admissionHandler answers Kubernetes AdmissionReview requests, admitting
+// pods only if every agent in them passed its audit
+func admissionHandler(w http.ResponseWriter, r *http.Request) {
+	if admission == nil {
+		http.Error(w, "Admission webhook mode is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	var review admissionReview
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdmissionReviewBytes)).Decode(&review); err != nil || review.Request == nil {
+		http.Error(w, "Request body must be an admission.k8s.io/v1 AdmissionReview with a request", http.StatusBadRequest)
+		return
+	}
+	request := review.Request
+	response := &admissionResponse{UID: request.UID, Allowed: true}
+
+	var object admittedObject
+	if err := json.Unmarshal(request.Object, &object); err != nil {
+		response.Allowed = false
+		response.Status = &admissionStatus{Code: http.StatusBadRequest, Message: fmt.Sprintf("AEGONG could not read the %s: %v", request.Kind.Kind, err)}
+	} else if reports, err := loadReports(); err != nil {
+		// Fail closed: without the reports nothing can be shown to be audited
+		response.Allowed = false
+		response.Status = &admissionStatus{Code: http.StatusInternalServerError, Message: "AEGONG could not read its reports"}
+	} else {
+		denials, warnings := admission.review(object.pod(request.Object), reports)
+		response.Warnings = warnings
+		if len(denials) > 0 {
+			response.Allowed = false
+			response.Status = &admissionStatus{Code: http.StatusForbidden, Message: "AEGONG denied: " + strings.Join(denials, "; ")}
+		}
+	}
+
+	verdict := "admitted"
+	if !response.Allowed {
+		verdict = "denied: " + response.Status.Message
+	}
+	logf(r.Context(), "Admission %s of %s %s/%s %s", request.Operation, request.Kind.Kind, request.Namespace, request.Name, verdict)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(admissionReview{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview", Response: response})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// TestAdmissionConfig tests reading the admission webhook settings
+func TestAdmissionConfig(t *testing.T) {
+	old := admission
+	t.Cleanup(func() { admission = old })
+
+	for _, c := range []struct {
+		enabled, maxRisk, exempt string
+		valid                    bool
+	}{
+		{"", "SEVERE", "", true},
+		{"1", "", "", true},
+		{"1", "severe", "", false},
+		{"1", "medium", "[", false},
+		{"1", "medium", "registry.k8s.io/*, docker.io/istio/*", true},
+	} {
+		t.Setenv("AEGONG_ADMISSION_WEBHOOK", c.enabled)
+		t.Setenv("AEGONG_ADMISSION_MAX_RISK", c.maxRisk)
+		t.Setenv("AEGONG_ADMISSION_EXEMPT", c.exempt)
+		if err := initAdmission(); (err == nil) != c.valid {
+			t.Errorf("%+v: Should be valid: %v, got %v", c, c.valid, err)
+		}
+	}
+	if admission == nil || admission.maxRisk != "MEDIUM" || len(admission.exempt) != 2 {
+		t.Fatalf("Should read the risk level and exemptions, got %+v", admission)
+	}
+}
+
+// TestAdmissionWebhook tests admitting pods and workloads by the audits of their images and agents
+func TestAdmissionWebhook(t *testing.T) {
+	audited, critical, violating, binary, unaudited := strings.Repeat("a", 64), strings.Repeat("c", 64), strings.Repeat("d", 64), strings.Repeat("b", 64), strings.Repeat("e", 64)
+	now := time.Now()
+	withTestReports(t,
+		&aegong.AuditReport{AgentHash: "1111111111", AgentName: "scanner", Timestamp: now.Add(-time.Hour), RiskLevel: "CRITICAL",
+			Source: &aegong.ArtifactSource{Kind: "oci", Image: "ghcr.io/acme/scanner", ManifestDigest: audited}},
+		// A re-audit of the same image is what counts
+		&aegong.AuditReport{AgentHash: "2222222222", AgentName: "scanner", Timestamp: now, RiskLevel: "LOW",
+			Source: &aegong.ArtifactSource{Kind: "oci", Image: "ghcr.io/acme/scanner", ManifestDigest: audited}},
+		&aegong.AuditReport{AgentHash: "3333333333", AgentName: "miner", Timestamp: now.Add(-time.Hour), RiskLevel: "CRITICAL",
+			Source: &aegong.ArtifactSource{Kind: "oci", Image: "ghcr.io/acme/miner", ManifestDigest: critical}},
+		// A newer clean audit claiming the digest from another registry must not count
+		&aegong.AuditReport{AgentHash: "4444444444", AgentName: "miner", Timestamp: now, RiskLevel: "MINIMAL",
+			Source: &aegong.ArtifactSource{URL: "oci://attacker.example/x@sha256:" + critical, Kind: "oci", Image: "attacker.example/x", ManifestDigest: critical}},
+		&aegong.AuditReport{AgentHash: "5555555555", AgentName: "python", Timestamp: now, RiskLevel: "LOW",
+			Source: &aegong.ArtifactSource{Kind: "oci", Image: "registry-1.docker.io/library/python", ManifestDigest: unaudited}},
+		&aegong.AuditReport{AgentHash: violating, AgentName: "writer", Timestamp: now, RiskLevel: "MEDIUM",
+			Policy: &aegong.PolicyCheck{Violations: []aegong.PolicyViolation{{Kind: "write_path", Capability: "/etc"}}}},
+		&aegong.AuditReport{AgentHash: binary, AgentName: "helper", Timestamp: now, RiskLevel: "MINIMAL"},
+	)
+	oldAdmission, oldRegistry := admission, agentRegistry
+	t.Cleanup(func() { admission, agentRegistry = oldAdmission, oldRegistry })
+	agentRegistry = &registry{agents: []registeredAgent{}}
+
+	review := func(kind, object string) admissionResponse {
+		body := `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"705ab4f5","kind":{"kind":"` + kind + `"},"namespace":"agents","name":"x","operation":"CREATE","object":` + object + `}}`
+		w := httptest.NewRecorder()
+		admissionHandler(w, httptest.NewRequest("POST", "/api/admission/validate", strings.NewReader(body)))
+		var result admissionReview
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil || w.Code != http.StatusOK || result.Response == nil || result.Response.UID != "705ab4f5" {
+			t.Fatalf("Should answer the review, got %d (%v)", w.Code, err)
+		}
+		return *result.Response
+	}
+	pod := func(annotations string, images ...string) string {
+		var containers []string
+		for _, image := range images {
+			containers = append(containers, `{"name":"c","image":"`+image+`"}`)
+		}
+		return `{"metadata":{"annotations":{` + annotations + `}},"spec":{"containers":[` + strings.Join(containers, ",") + `]}}`
+	}
+
+	w := httptest.NewRecorder()
+	admissionHandler(w, httptest.NewRequest("POST", "/api/admission/validate", strings.NewReader("{}")))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Should be off unless enabled, got %d", w.Code)
+	}
+	admission = &admissionPolicy{maxRisk: "HIGH", exempt: []string{"registry.k8s.io/*"}}
+	w = httptest.NewRecorder()
+	admissionHandler(w, httptest.NewRequest("POST", "/api/admission/validate", strings.NewReader(`{"kind":"AdmissionReview"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Should reject a review without a request, got %d", w.Code)
+	}
+
+	if response := review("Pod", pod(`"aegong.io/agent-sha256":"sha256:`+binary+`"`, "ghcr.io/acme/scanner@sha256:"+audited, "registry.k8s.io/pause:3.9")); !response.Allowed || len(response.Warnings) != 1 {
+		t.Fatalf("Should admit audited images and agents, warning about exempt ones, got %+v", response)
+	}
+
+	cases := map[string]string{
+		pod("", "ghcr.io/acme/scanner:latest"):                               "not pinned",
+		pod("", "ghcr.io/acme/scanner@sha256:"+unaudited):                    "has not been audited",
+		pod("", "ghcr.io/acme/python@sha256:"+unaudited):                     "has not been audited",
+		pod("", "ghcr.io/acme/miner@sha256:"+critical):                       "rated CRITICAL",
+		pod(`"aegong.io/agent-sha256":"` + violating + `"`):                  "capability policy",
+		pod(`"aegong.io/agent-sha256":"` + binary + `,not-a-digest"`):        "invalid digest",
+		pod(`"aegong.io/agent-sha256":"` + strings.ToUpper(unaudited) + `"`): "agent sha256:eeeeeeeeeeee has not been audited",
+	}
+	for object, reason := range cases {
+		response := review("Pod", object)
+		if response.Allowed || response.Status == nil || response.Status.Code != http.StatusForbidden || !strings.Contains(response.Status.Message, reason) {
+			t.Errorf("%s: Should be denied for %q, got %+v", object, reason, response.Status)
+		}
+	}
+
+	// Docker Hub images match however they are named
+	if response := review("Pod", pod("", "docker.io/library/python@sha256:"+unaudited)); !response.Allowed {
+		t.Fatalf("Should admit a Docker Hub image audited by its short name, got %+v", response.Status)
+	}
+
+	// Workloads are checked by their pod templates
+	deployment := `{"spec":{"replicas":3,"template":` + pod("", "ghcr.io/acme/miner@sha256:"+critical) + `}}`
+	if response := review("Deployment", deployment); response.Allowed {
+		t.Fatal("Should check a deployment's pod template")
+	}
+	cronJob := `{"spec":{"schedule":"@daily","jobTemplate":{"spec":{"template":` + pod("", "ghcr.io/acme/scanner@sha256:"+audited) + `}}}}`
+	if response := review("CronJob", cronJob); !response.Allowed {
+		t.Fatalf("Should check a cron job's pod template, got %+v", response.Status)
+	}
+
+	// Retired agents are not admitted, however their audit went
+	agentRegistry.agents = []registeredAgent{{ID: "1", Name: "scanner", Owner: "security", State: agentRetired}}
+	if response := review("Pod", pod("", "ghcr.io/acme/scanner@sha256:"+audited)); response.Allowed || !strings.Contains(response.Status.Message, "retired") {
+		t.Fatalf("Should deny a retired agent, got %+v", response)
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Saved reports can be anchored in an external transparency log: the
+// SHA-256 of the report file is published as it is written, so anyone
+// holding the report can check it existed at that time and was not
+// regenerated later. The log's receipt is kept next to the report.
+
+// Transparency logs reports can be anchored in
+const (
+	anchorLogRekor  = "rekor"  // Sigstore Rekor, as hashedrekord entries
+	anchorLogSimple = "simple" // Any append-only service speaking the protocol in the README
+)
+
+const (
+	anchorAttempts = 3
+	anchorTimeout  = 30 * time.Second
+)
+
+// reportAnchoring is where and how reports are anchored
+type reportAnchoring struct {
+	kind   string
+	url    string
+	key    *ecdsa.PrivateKey // Signs Rekor entries
+	client *http.Client
+}
+
+// Report anchoring configured by AEGONG_ANCHOR_URL; nil when disabled
+var anchoring *reportAnchoring
+
+// reportAnchor is the transparency log's receipt for a report
+type reportAnchor struct {
+	Log            string          `json:"log"` // rekor or simple
+	URL            string          `json:"url"`
+	ReportSHA256   string          `json:"report_sha256"`
+	EntryID        string          `json:"entry_id,omitempty"`
+	EntryURL       string          `json:"entry_url,omitempty"` // Where the entry can be looked up
+	LogIndex       int64           `json:"log_index"`
+	IntegratedTime time.Time       `json:"integrated_time"`
+	PublicKey      string          `json:"public_key,omitempty"` // PEM key that signed a Rekor entry
+	Receipt        json.RawMessage `json:"receipt"`              // The log's response as received
+}
+
+// initReportAnchoring reads AEGONG_ANCHOR_URL, AEGONG_ANCHOR_LOG and
+// AEGONG_ANCHOR_KEY. Rekor entries are signed with the EC key in
+// AEGONG_ANCHOR_KEY, or with a key generated at startup.
+func initReportAnchoring() error {
+	logURL := os.Getenv("AEGONG_ANCHOR_URL")
+	if logURL == "" {
+		return nil
+	}
+	parsed, err := url.Parse(logURL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return fmt.Errorf("AEGONG_ANCHOR_URL must be an http(s) URL, got %q", logURL)
+	}
+
+	config := &reportAnchoring{
+		kind:   os.Getenv("AEGONG_ANCHOR_LOG"),
+		url:    strings.TrimSuffix(logURL, "/"),
+		client: &http.Client{Timeout: anchorTimeout},
+	}
+	switch config.kind {
+	case "":
+		config.kind = anchorLogSimple
+	case anchorLogSimple, anchorLogRekor:
+	default:
+		return fmt.Errorf("AEGONG_ANCHOR_LOG must be %q or %q, got %q", anchorLogRekor, anchorLogSimple, config.kind)
+	}
+
+	if config.kind == anchorLogRekor {
+		if path := os.Getenv("AEGONG_ANCHOR_KEY"); path != "" {
+			if config.key, err = loadAnchorKey(path); err != nil {
+				return err
+			}
+		} else {
+			log.Printf("Warning: AEGONG_ANCHOR_KEY is not set, signing Rekor entries with a key generated for this run")
+			if config.key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+				return fmt.Errorf("failed to generate anchor key: %v", err)
+			}
+		}
+	}
+
+	anchoring = config
+	log.Printf("Info: Anchoring reports in the %s transparency log at %s", config.kind, config.url)
+	return nil
+}
+
+// loadAnchorKey reads a PEM encoded EC private key
+func loadAnchorKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read anchor key: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("anchor key %s is not PEM encoded", path)
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse anchor key: %v", err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("anchor key must be an EC key, got %T", parsed)
+	}
+	return key, nil
+}
+
+// anchorPath is where a report's anchor is kept
+func anchorPath(hash string) string {
+	return filepath.Join("reports", fmt.Sprintf("anchor_%s.json", hash))
+}
+
+// anchorReport publishes a saved report's hash and keeps the receipt,
+// retrying if the log cannot be reached. It runs in the background; failures
+// are logged and leave the report unanchored.
+func anchorReport(hash string, reportJSON []byte) {
+	if anchoring == nil {
+		return
+	}
+	sum := sha256.Sum256(reportJSON)
+
+	var err error
+	for attempt := 1; attempt <= anchorAttempts; attempt++ {
+		var anchor *reportAnchor
+		if anchor, err = anchoring.publish(sum[:]); err == nil {
+			data, _ := json.MarshalIndent(anchor, "", "  ")
+			if err = writeStored(anchorPath(hash), data); err != nil {
+				break
+			}
+			log.Printf("Report %s anchored in %s at index %d", hash, anchoring.url, anchor.LogIndex)
+			return
+		}
+		if attempt < anchorAttempts {
+			time.Sleep(time.Duration(attempt) * 5 * time.Second)
+		}
+	}
+	log.Printf("Warning: Failed to anchor report %s: %v", hash, err)
+}
+
+// publish adds a digest to the transparency log
+func (a *reportAnchoring) publish(digest []byte) (*reportAnchor, error) {
+	if a.kind == anchorLogRekor {
+		return a.publishRekor(digest)
+	}
+	return a.publishSimple(digest)
+}
+
+// post sends a JSON body and returns the response body if the log accepted it
+func (a *reportAnchoring) post(endpoint string, body interface{}) ([]byte, error) {
+	payload, _ := json.Marshal(body)
+	response, err := a.client.Post(endpoint, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("transparency log returned %s: %s", response.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// publishRekor records the digest as a hashedrekord entry signed with the
+// anchoring key
+func (a *reportAnchoring) publishRekor(digest []byte) (*reportAnchor, error) {
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign report hash: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&a.key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	entry := map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]interface{}{
+			"data": map[string]interface{}{
+				"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(digest)},
+			},
+			"signature": map[string]interface{}{
+				"content":   base64.StdEncoding.EncodeToString(signature),
+				"publicKey": map[string]string{"content": base64.StdEncoding.EncodeToString(publicPEM)},
+			},
+		},
+	}
+	data, err := a.post(a.url+"/api/v1/log/entries", entry)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rekor answers with the new entry keyed by its UUID
+	var entries map[string]struct {
+		LogIndex       int64 `json:"logIndex"`
+		IntegratedTime int64 `json:"integratedTime"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil || len(entries) != 1 {
+		return nil, fmt.Errorf("unexpected Rekor response: %s", data)
+	}
+	anchor := &reportAnchor{
+		Log:          anchorLogRekor,
+		URL:          a.url,
+		ReportSHA256: hex.EncodeToString(digest),
+		PublicKey:    string(publicPEM),
+		Receipt:      data,
+	}
+	for uuid, created := range entries {
+		anchor.EntryID = uuid
+		anchor.EntryURL = a.url + "/api/v1/log/entries/" + uuid
+		anchor.LogIndex = created.LogIndex
+		anchor.IntegratedTime = time.Unix(created.IntegratedTime, 0).UTC()
+	}
+	return anchor, nil
+}
+
+// publishSimple posts the digest to an append-only log that answers with
+// the entry's id, index and time
+func (a *reportAnchoring) publishSimple(digest []byte) (*reportAnchor, error) {
+	data, err := a.post(a.url, map[string]string{"sha256": hex.EncodeToString(digest)})
+	if err != nil {
+		return nil, err
+	}
+
+	var entry struct {
+		ID        string    `json:"id"`
+		Index     int64     `json:"index"`
+		Timestamp time.Time `json:"timestamp"`
+		URL       string    `json:"url"`
+	}
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Timestamp.IsZero() {
+		return nil, fmt.Errorf("unexpected transparency log response: %s", data)
+	}
+	return &reportAnchor{
+		Log:            anchorLogSimple,
+		URL:            a.url,
+		ReportSHA256:   hex.EncodeToString(digest),
+		EntryID:        entry.ID,
+		EntryURL:       entry.URL,
+		LogIndex:       entry.Index,
+		IntegratedTime: entry.Timestamp.UTC(),
+		Receipt:        data,
+	}, nil
+}
+
+// reportAnchorHandler serves the transparency log receipt for a report
+func reportAnchorHandler(w http.ResponseWriter, r *http.Request) {
+	hash := mux.Vars(r)["hash"]
+	if !validReportHash(hash) {
+		http.Error(w, fmt.Sprintf("invalid report hash %q", hash), http.StatusBadRequest)
+		return
+	}
+	data, err := readStored(anchorPath(hash))
+	if os.IsNotExist(err) {
+		http.Error(w, "Report has not been anchored", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read anchor: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestSimpleAnchor tests anchoring a report in an append-only log and serving the receipt
+func TestSimpleAnchor(t *testing.T) {
+	var published string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		published = body["sha256"]
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"entry-7","index":7,"timestamp":"2026-10-16T12:00:00Z"}`))
+	}))
+	defer server.Close()
+
+	withTestUpload(t)
+	os.MkdirAll("reports", 0755)
+	t.Setenv("AEGONG_ANCHOR_URL", server.URL)
+	t.Cleanup(func() { anchoring = nil })
+	if err := initReportAnchoring(); err != nil {
+		t.Fatalf("Failed to configure anchoring: %v", err)
+	}
+
+	report := testReportJSON(t)
+	anchorReport("abcdef01", report)
+	sum := sha256.Sum256(report)
+	if published != hex.EncodeToString(sum[:]) {
+		t.Fatalf("Should publish the report's SHA-256, got %q", published)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/report/{hash}/anchor", reportAnchorHandler)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/report/abcdef01/anchor", nil))
+	var anchor reportAnchor
+	if err := json.Unmarshal(recorder.Body.Bytes(), &anchor); err != nil || recorder.Code != http.StatusOK {
+		t.Fatalf("Should serve the receipt, got %d: %s", recorder.Code, recorder.Body)
+	}
+	if anchor.Log != "simple" || anchor.EntryID != "entry-7" || anchor.LogIndex != 7 || anchor.ReportSHA256 != published || anchor.IntegratedTime.Year() != 2026 {
+		t.Errorf("Receipt should record the log entry, got %+v", anchor)
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/report/00000000/anchor", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Unanchored reports should be 404, got %d", recorder.Code)
+	}
+}
+
+// TestRekorAnchor tests that Rekor entries carry a signature over the report hash
+func TestRekorAnchor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var entry struct {
+			Kind string `json:"kind"`
+			Spec struct {
+				Data struct {
+					Hash struct{ Value string } `json:"hash"`
+				} `json:"data"`
+				Signature struct {
+					Content   string `json:"content"`
+					PublicKey struct {
+						Content string `json:"content"`
+					} `json:"publicKey"`
+				} `json:"signature"`
+			} `json:"spec"`
+		}
+		json.NewDecoder(r.Body).Decode(&entry)
+		digest, _ := hex.DecodeString(entry.Spec.Data.Hash.Value)
+		signature, _ := base64.StdEncoding.DecodeString(entry.Spec.Signature.Content)
+		publicPEM, _ := base64.StdEncoding.DecodeString(entry.Spec.Signature.PublicKey.Content)
+		block, _ := pem.Decode(publicPEM)
+		if r.URL.Path != "/api/v1/log/entries" || entry.Kind != "hashedrekord" || block == nil {
+			http.Error(w, "bad entry", http.StatusBadRequest)
+			return
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil || !ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), digest, signature) {
+			http.Error(w, "signature does not verify", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"24296fb24b8ad77a": {"logIndex": 1234, "integratedTime": 1792152000, "body": "..."}}`))
+	}))
+	defer server.Close()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	keyPath := filepath.Join(t.TempDir(), "anchor.pem")
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+
+	t.Setenv("AEGONG_ANCHOR_URL", server.URL+"/")
+	t.Setenv("AEGONG_ANCHOR_LOG", "rekor")
+	t.Setenv("AEGONG_ANCHOR_KEY", keyPath)
+	t.Cleanup(func() { anchoring = nil })
+	if err := initReportAnchoring(); err != nil {
+		t.Fatalf("Failed to configure anchoring: %v", err)
+	}
+
+	sum := sha256.Sum256(testReportJSON(t))
+	anchor, err := anchoring.publish(sum[:])
+	if err != nil {
+		t.Fatalf("Rekor should accept the entry: %v", err)
+	}
+	if anchor.EntryID != "24296fb24b8ad77a" || anchor.LogIndex != 1234 || anchor.EntryURL != server.URL+"/api/v1/log/entries/24296fb24b8ad77a" {
+		t.Errorf("Receipt should record the Rekor entry, got %+v", anchor)
+	}
+	if !strings.Contains(anchor.PublicKey, "PUBLIC KEY") || anchor.IntegratedTime.Unix() != 1792152000 {
+		t.Errorf("Receipt should keep the signing key and time, got %+v", anchor)
+	}
+
+	t.Setenv("AEGONG_ANCHOR_LOG", "blockchain")
+	if err := initReportAnchoring(); err == nil {
+		t.Error("Unknown logs should be rejected")
+	}
+}
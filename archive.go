@@ -0,0 +1,472 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// Report archives move saved reports, and optionally the audit log, between
+// instances. Reports are stored in the clear inside the archive and written
+// byte for byte on import, so their hashes and signature results survive;
+// the importing instance re-encrypts them under its own at-rest key.
+//
+// An archive is stamped with when its newest file last changed rather than
+// when it was exported, so exporting unchanged reports again gives the same
+// bytes and an interrupted download can be resumed.
+
+const (
+	archiveFormat = 1
+
+	archiveManifestName = "manifest.json"
+	archiveAuditLogName = "aegong_audit.log"
+)
+
+// maxArchiveBytes caps both the upload and what it unpacks to
+var maxArchiveBytes int64 = 1 << 30
+
+// archiveManifest lists what an archive holds, so imports can check nothing
+// was lost or altered on the way
+type archiveManifest struct {
+	Format    int                  `json:"format"`
+	CreatedAt time.Time            `json:"created_at"` // When the newest file in the archive last changed
+	Engine    aegong.EngineVersion `json:"engine"`
+	Reports   []archivedReport     `json:"reports"`
+	Anchors   []archivedFile       `json:"anchors,omitempty"`  // Transparency log receipts
+	Comments  []archivedFile       `json:"comments,omitempty"` // Triage discussion of reports
+	AuditLog  *archivedFile        `json:"audit_log,omitempty"`
+}
+
+// archivedReport is a report file in an archive
+type archivedReport struct {
+	AgentHash string `json:"agent_hash"`
+	archivedFile
+}
+
+// archivedFile is a file in an archive with the SHA-256 of its contents
+type archivedFile struct {
+	File   string `json:"file"`
+	SHA256 string `json:"sha256"`
+}
+
+// archiveImport is the outcome of importing an archive
+type archiveImport struct {
+	ReportsImported      int      `json:"reports_imported"`
+	ReportsSkipped       []string `json:"reports_skipped"` // Already present and not overwritten
+	AuditEntriesImported int      `json:"audit_entries_imported"`
+}
+
+// Report, anchor and comments files as saved under reports/
+var (
+	archivedReportName   = regexp.MustCompile(`^reports/report_([0-9a-f]{8})\.json$`)
+	archivedAnchorName   = regexp.MustCompile(`^reports/anchor_[0-9a-f]{8}\.json$`)
+	archivedCommentsName = regexp.MustCompile(`^reports/comments_[0-9a-f]{8}\.json$`)
+)
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeArchive writes every saved report, and the audit log if auditLog is
+// set, to w as a gzipped tar archive. It returns the time the archive is
+// stamped with.
+func writeArchive(w io.Writer, auditLog *aegong.AuditLogger) (time.Time, error) {
+	files, err := filepath.Glob("reports/report_*.json")
+	if err != nil {
+		return time.Time{}, err
+	}
+	sort.Strings(files)
+
+	manifest := archiveManifest{Format: archiveFormat, Engine: engine.Version()}
+	contents := make(map[string][]byte)
+	modTime := time.Unix(0, 0)
+	changed := func(file string) {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	for _, file := range files {
+		name := filepath.ToSlash(file)
+		if !archivedReportName.MatchString(name) {
+			continue
+		}
+		data, err := readStored(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to read %s: %v", file, err)
+		}
+		var report aegong.AuditReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			log.Printf("Warning: Leaving unreadable report %s out of the archive: %v", file, err)
+			continue
+		}
+		manifest.Reports = append(manifest.Reports, archivedReport{
+			AgentHash:    report.AgentHash,
+			archivedFile: archivedFile{File: name, SHA256: sha256Hex(data)},
+		})
+		contents[name] = data
+		changed(file)
+	}
+
+	anchors, err := filepath.Glob("reports/anchor_*.json")
+	if err != nil {
+		return time.Time{}, err
+	}
+	sort.Strings(anchors)
+	for _, file := range anchors {
+		name := filepath.ToSlash(file)
+		if !archivedAnchorName.MatchString(name) {
+			continue
+		}
+		data, err := readStored(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to read %s: %v", file, err)
+		}
+		manifest.Anchors = append(manifest.Anchors, archivedFile{File: name, SHA256: sha256Hex(data)})
+		contents[name] = data
+		changed(file)
+	}
+
+	comments, err := filepath.Glob("reports/comments_*.json")
+	if err != nil {
+		return time.Time{}, err
+	}
+	sort.Strings(comments)
+	for _, file := range comments {
+		name := filepath.ToSlash(file)
+		if !archivedCommentsName.MatchString(name) {
+			continue
+		}
+		data, err := readStored(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to read %s: %v", file, err)
+		}
+		manifest.Comments = append(manifest.Comments, archivedFile{File: name, SHA256: sha256Hex(data)})
+		contents[name] = data
+		changed(file)
+	}
+
+	if auditLog != nil {
+		var buffer bytes.Buffer
+		if err := auditLog.Export(&buffer); err != nil {
+			return time.Time{}, err
+		}
+		manifest.AuditLog = &archivedFile{File: archiveAuditLogName, SHA256: sha256Hex(buffer.Bytes())}
+		contents[archiveAuditLogName] = buffer.Bytes()
+		if logTime, err := auditLog.ModTime(); err == nil && logTime.After(modTime) {
+			modTime = logTime
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+	add := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: modTime}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := archive.Write(data)
+		return err
+	}
+
+	manifest.CreatedAt = modTime.UTC()
+	manifestJSON, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := add(archiveManifestName, manifestJSON); err != nil {
+		return time.Time{}, err
+	}
+	for _, report := range manifest.Reports {
+		if err := add(report.File, contents[report.File]); err != nil {
+			return time.Time{}, err
+		}
+	}
+	for _, anchor := range manifest.Anchors {
+		if err := add(anchor.File, contents[anchor.File]); err != nil {
+			return time.Time{}, err
+		}
+	}
+	for _, comments := range manifest.Comments {
+		if err := add(comments.File, contents[comments.File]); err != nil {
+			return time.Time{}, err
+		}
+	}
+	if manifest.AuditLog != nil {
+		if err := add(archiveAuditLogName, contents[archiveAuditLogName]); err != nil {
+			return time.Time{}, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return time.Time{}, err
+	}
+	return modTime, gz.Close()
+}
+
+// files lists the names of the files the manifest references
+func (m *archiveManifest) files() map[string]bool {
+	files := make(map[string]bool)
+	for _, report := range m.Reports {
+		files[report.File] = true
+	}
+	for _, anchor := range m.Anchors {
+		files[anchor.File] = true
+	}
+	for _, comments := range m.Comments {
+		files[comments.File] = true
+	}
+	if m.AuditLog != nil {
+		files[m.AuditLog.File] = true
+	}
+	return files
+}
+
+// readArchive reads an archive written by writeArchive, checking every file
+// against the manifest. The manifest must come first, so files it does not
+// reference are refused before they are read, and no more than
+// maxArchiveBytes are unpacked however well the archive compresses.
+func readArchive(r io.Reader) (*archiveManifest, map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("archive is not gzipped: %v", err)
+	}
+	archive := tar.NewReader(gz)
+
+	var manifest *archiveManifest
+	var listed map[string]bool
+	var unpacked int64
+	contents := make(map[string][]byte)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read archive: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if manifest == nil && header.Name != archiveManifestName {
+			return nil, nil, fmt.Errorf("archive does not start with %s", archiveManifestName)
+		}
+		if manifest != nil && !listed[header.Name] {
+			return nil, nil, fmt.Errorf("%s is not listed in the manifest", header.Name)
+		}
+		if _, ok := contents[header.Name]; ok {
+			return nil, nil, fmt.Errorf("%s appears twice in the archive", header.Name)
+		}
+
+		// The header's size is not trusted; reading stops one byte past what is left
+		data, err := io.ReadAll(io.LimitReader(archive, maxArchiveBytes-unpacked+1))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %v", header.Name, err)
+		}
+		unpacked += int64(len(data))
+		if unpacked > maxArchiveBytes {
+			return nil, nil, fmt.Errorf("archive unpacks to more than %d bytes", maxArchiveBytes)
+		}
+
+		if manifest == nil {
+			manifest = &archiveManifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, nil, fmt.Errorf("archive has no valid %s: %v", archiveManifestName, err)
+			}
+			if manifest.Format != archiveFormat {
+				return nil, nil, fmt.Errorf("unsupported archive format %d", manifest.Format)
+			}
+			listed = manifest.files()
+		}
+		contents[header.Name] = data
+	}
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("archive has no %s", archiveManifestName)
+	}
+
+	check := func(file archivedFile) error {
+		data, ok := contents[file.File]
+		if !ok {
+			return fmt.Errorf("%s is listed in the manifest but missing", file.File)
+		}
+		if sha256Hex(data) != file.SHA256 {
+			return fmt.Errorf("%s does not match its checksum", file.File)
+		}
+		return nil
+	}
+	for _, report := range manifest.Reports {
+		match := archivedReportName.FindStringSubmatch(report.File)
+		if match == nil {
+			return nil, nil, fmt.Errorf("%s is not a report file", report.File)
+		}
+		if err := check(report.archivedFile); err != nil {
+			return nil, nil, err
+		}
+		var saved aegong.AuditReport
+		if err := json.Unmarshal(contents[report.File], &saved); err != nil {
+			return nil, nil, fmt.Errorf("%s is not a valid report: %v", report.File, err)
+		}
+		if saved.AgentHash != report.AgentHash || !strings.HasPrefix(saved.AgentHash, match[1]) {
+			return nil, nil, fmt.Errorf("%s does not hold the report for agent %s", report.File, report.AgentHash)
+		}
+	}
+	for _, anchor := range manifest.Anchors {
+		if !archivedAnchorName.MatchString(anchor.File) {
+			return nil, nil, fmt.Errorf("%s is not an anchor file", anchor.File)
+		}
+		if err := check(anchor); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, comments := range manifest.Comments {
+		if !archivedCommentsName.MatchString(comments.File) {
+			return nil, nil, fmt.Errorf("%s is not a comments file", comments.File)
+		}
+		if err := check(comments); err != nil {
+			return nil, nil, err
+		}
+	}
+	if manifest.AuditLog != nil {
+		if manifest.AuditLog.File != archiveAuditLogName {
+			return nil, nil, fmt.Errorf("%s is not an audit log", manifest.AuditLog.File)
+		}
+		if err := check(*manifest.AuditLog); err != nil {
+			return nil, nil, err
+		}
+		lines := bytes.Split(contents[archiveAuditLogName], []byte("\n"))
+		for i, line := range lines {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			if _, err := aegong.VerifyLogEntry(line); err != nil {
+				return nil, nil, fmt.Errorf("%s line %d: %v", archiveAuditLogName, i+1, err)
+			}
+		}
+	}
+	return manifest, contents, nil
+}
+
+// importArchive appends an archive's audit log entries to auditLog and saves
+// its reports. Existing reports are kept unless overwrite is set.
+func importArchive(manifest *archiveManifest, contents map[string][]byte, auditLog *aegong.AuditLogger, overwrite bool) (*archiveImport, error) {
+	result := &archiveImport{ReportsSkipped: []string{}}
+
+	if manifest.AuditLog != nil {
+		imported, err := auditLog.Import(bytes.NewReader(contents[manifest.AuditLog.File]))
+		if err != nil {
+			return result, err
+		}
+		result.AuditEntriesImported = imported
+	}
+
+	os.MkdirAll("reports", 0755)
+	for _, report := range manifest.Reports {
+		path := filepath.FromSlash(report.File)
+		if _, err := os.Stat(path); err == nil && !overwrite {
+			result.ReportsSkipped = append(result.ReportsSkipped, report.AgentHash)
+			continue
+		}
+		switch err := writeReportFile(report.AgentHash[:8], path, contents[report.File]); {
+		case errors.Is(err, errLegalHold):
+			result.ReportsSkipped = append(result.ReportsSkipped, report.AgentHash)
+		case err != nil:
+			return result, fmt.Errorf("failed to save %s: %v", report.File, err)
+		default:
+			result.ReportsImported++
+		}
+	}
+
+	// Transparency log receipts and comments, kept like reports unless
+	// overwrite is set
+	for _, anchor := range append(manifest.Anchors, manifest.Comments...) {
+		path := filepath.FromSlash(anchor.File)
+		hash := strings.TrimSuffix(filepath.Base(path), ".json")
+		hash = hash[strings.LastIndex(hash, "_")+1:]
+		if _, err := os.Stat(path); err == nil && !overwrite {
+			continue
+		}
+		if err := writeReportFile(hash, path, contents[anchor.File]); err != nil && !errors.Is(err, errLegalHold) {
+			return result, fmt.Errorf("failed to save %s: %v", anchor.File, err)
+		}
+	}
+	return result, nil
+}
+
+// exportArchiveHandler downloads every saved report as an archive;
+// ?audit_log=1 adds the audit log
+func exportArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	var auditLog *aegong.AuditLogger
+	if r.URL.Query().Get("audit_log") == "1" {
+		if auditLog = engine.AuditLog(); auditLog == nil {
+			http.Error(w, "Audit logging is disabled", http.StatusNotFound)
+			return
+		}
+	}
+
+	// Built in memory so a failure can still be reported as an error, and so
+	// ranges of it can be served
+	var buffer bytes.Buffer
+	modTime, err := writeArchive(&buffer, auditLog)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to build archive: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if r.Header.Get("Range") == "" {
+		log.Printf("Report archive exported by %s (%d bytes, audit log %t)", principal.Name, buffer.Len(), auditLog != nil)
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	download{
+		name:       fmt.Sprintf("aegong_archive_%s.tar.gz", modTime.UTC().Format("20060102T150405Z")),
+		modTime:    modTime,
+		etag:       `"` + sha256Hex(buffer.Bytes()) + `"`,
+		attachment: true,
+	}.serve(w, r, bytes.NewReader(buffer.Bytes()))
+}
+
+// importArchiveHandler restores an archive sent as the request body;
+// ?overwrite=1 replaces reports that already exist
+func importArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	manifest, contents, err := readArchive(http.MaxBytesReader(w, r.Body, maxArchiveBytes))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid archive: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if manifest.AuditLog != nil && engine.AuditLog() == nil {
+		http.Error(w, "Archive holds an audit log but audit logging is disabled", http.StatusConflict)
+		return
+	}
+
+	result, err := importArchive(manifest, contents, engine.AuditLog(), r.URL.Query().Get("overwrite") == "1")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to import archive: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Report archive imported by %s: %d reports, %d skipped, %d audit log entries", principal.Name, result.ReportsImported, len(result.ReportsSkipped), result.AuditEntriesImported)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
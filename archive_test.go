@@ -0,0 +1,210 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// TestReportArchive tests exporting reports and the audit log and importing them elsewhere
+func TestReportArchive(t *testing.T) {
+	oldTokens, oldEngine := apiTokens, engine
+	t.Cleanup(func() { apiTokens, engine = oldTokens, oldEngine })
+	apiTokens, _ = loadAPITokens("alice:admin:admin,ci:auditor:audit")
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/admin/archive", exportArchiveHandler).Methods("GET")
+	router.HandleFunc("/api/admin/archive", importArchiveHandler).Methods("POST")
+	request := func(method, path, token string, body []byte) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, bytes.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, r)
+		return rec
+	}
+
+	// The source instance has a saved report and its audit log entry
+	withTestUpload(t)
+	os.MkdirAll("reports", 0755)
+	engine, _ = aegong.NewEngine(aegong.Config{AuditLogPath: filepath.Join(t.TempDir(), "audit.log")})
+	defer engine.Close()
+	original := testReportJSON(t)
+	var report aegong.AuditReport
+	json.Unmarshal(original, &report)
+	engine.AuditLog().LogAudit(&report)
+	reportName := filepath.Join("reports", "report_abcdef01.json")
+	if err := writeStored(reportName, original); err != nil {
+		t.Fatal(err)
+	}
+	writeStored(anchorPath("abcdef01"), []byte(`{"log":"simple","log_index":7}`))
+	addComment("abcdef01", reportComment{ID: "1", Author: "ci", Body: "Expected for a build agent"})
+
+	if rec := request("GET", "/api/admin/archive", "audit", nil); rec.Code != http.StatusForbidden {
+		t.Fatalf("Only admins should export archives, got %d", rec.Code)
+	}
+	rec := request("GET", "/api/admin/archive?audit_log=1", "admin", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("Admins should export an archive, got %d: %s", rec.Code, rec.Body)
+	}
+	archive := rec.Body.Bytes()
+	etag := rec.Header().Get("ETag")
+	if disposition := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, "attachment; filename=aegong_archive_") {
+		t.Errorf("Archive should download as an attachment, got %q", disposition)
+	}
+
+	// Exporting the same reports again resumes where the first download stopped
+	resume := httptest.NewRequest("GET", "/api/admin/archive?audit_log=1", nil)
+	resume.Header.Set("Authorization", "Bearer admin")
+	resume.Header.Set("Range", "bytes=100-")
+	resume.Header.Set("If-Range", etag)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, resume)
+	if rec.Code != http.StatusPartialContent || !bytes.Equal(rec.Body.Bytes(), archive[100:]) {
+		t.Fatalf("Unchanged archive should resume from the range, got %d", rec.Code)
+	}
+	resume.Header.Set("If-Range", `"0123"`)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, resume)
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), archive) {
+		t.Fatalf("Changed archive should be sent whole, got %d", rec.Code)
+	}
+
+	// The target instance starts empty
+	withTestUpload(t)
+	targetLog := filepath.Join(t.TempDir(), "audit.log")
+	engine, _ = aegong.NewEngine(aegong.Config{AuditLogPath: targetLog})
+	defer engine.Close()
+
+	rec = request("POST", "/api/admin/archive", "admin", archive)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"reports_imported":1`) || !strings.Contains(rec.Body.String(), `"audit_entries_imported":1`) {
+		t.Fatalf("Should import the report and log entry, got %d: %s", rec.Code, rec.Body)
+	}
+	imported, err := readStored(reportName)
+	if err != nil || !bytes.Equal(imported, original) {
+		t.Fatalf("Imported report should be unchanged, got %v:\n%s", err, imported)
+	}
+	if anchor, err := readStored(anchorPath("abcdef01")); err != nil || !strings.Contains(string(anchor), `"log_index":7`) {
+		t.Fatalf("Anchor receipt should be imported, got %v: %s", err, anchor)
+	}
+	if comments, err := loadComments("abcdef01"); err != nil || len(comments) != 1 || comments[0].Body != "Expected for a build agent" {
+		t.Fatalf("Comments should be imported, got %v: %+v", err, comments)
+	}
+	if data, _ := os.ReadFile(targetLog); !strings.Contains(string(data), "abcdef0123456789") {
+		t.Fatalf("Audit log entry should be imported, got:\n%s", data)
+	}
+
+	rec = request("POST", "/api/admin/archive", "admin", archive)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"reports_skipped":["abcdef0123456789"]`) || !strings.Contains(rec.Body.String(), `"audit_entries_imported":0`) {
+		t.Fatalf("Existing reports and entries should be skipped, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := request("POST", "/api/admin/archive?overwrite=1", "admin", archive); !strings.Contains(rec.Body.String(), `"reports_imported":1`) {
+		t.Fatalf("overwrite=1 should replace existing reports, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if rec := request("POST", "/api/admin/archive", "admin", []byte("not an archive")); rec.Code != http.StatusBadRequest {
+		t.Fatalf("Invalid archives should be rejected, got %d", rec.Code)
+	}
+}
+
+// packArchive builds an archive from a manifest and files as given
+func packArchive(t *testing.T, manifest *archiveManifest, contents map[string][]byte) io.Reader {
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	archive := tar.NewWriter(gz)
+	manifestJSON, _ := json.Marshal(manifest)
+	archive.WriteHeader(&tar.Header{Name: archiveManifestName, Mode: 0644, Size: int64(len(manifestJSON))})
+	archive.Write(manifestJSON)
+	for name, data := range contents {
+		archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))})
+		archive.Write(data)
+	}
+	if archive.Close() != nil || gz.Close() != nil {
+		t.Fatal("Failed to write archive")
+	}
+	return &buffer
+}
+
+// TestReadArchiveTampered tests that altered reports and log entries are rejected
+func TestReadArchiveTampered(t *testing.T) {
+	oldEngine := engine
+	t.Cleanup(func() { engine = oldEngine })
+	engine, _ = aegong.NewEngine(aegong.Config{})
+	defer engine.Close()
+	withTestUpload(t)
+	os.MkdirAll("reports", 0755)
+	writeStored(filepath.Join("reports", "report_abcdef01.json"), testReportJSON(t))
+
+	var buffer bytes.Buffer
+	if _, err := writeArchive(&buffer, nil); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	manifest, contents, err := readArchive(&buffer)
+	if err != nil {
+		t.Fatalf("Archive should read back: %v", err)
+	}
+	if len(manifest.Reports) != 1 || manifest.Reports[0].AgentHash != "abcdef0123456789" || manifest.AuditLog != nil {
+		t.Fatalf("Manifest should list the report, got %+v", manifest)
+	}
+	if !bytes.Equal(contents["reports/report_abcdef01.json"], testReportJSON(t)) {
+		t.Fatal("Report should be archived as saved")
+	}
+
+	contents = map[string][]byte{"reports/report_abcdef01.json": []byte(`{"agent_hash":"abcdef0123456789","overall_risk":0}`)}
+	if _, _, err := readArchive(packArchive(t, manifest, contents)); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("Altered reports should fail their checksum, got %v", err)
+	}
+
+	log := []byte(`{"agent_hash":"abcdef0123456789","signature":"00"}` + "\n")
+	manifest.AuditLog = &archivedFile{File: archiveAuditLogName, SHA256: sha256Hex(log)}
+	contents = map[string][]byte{"reports/report_abcdef01.json": testReportJSON(t), archiveAuditLogName: log}
+	if _, _, err := readArchive(packArchive(t, manifest, contents)); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("Forged log entries should be rejected, got %v", err)
+	}
+}
+
+// TestReadArchiveLimits tests that unlisted files and archives that unpack too large are refused
+func TestReadArchiveLimits(t *testing.T) {
+	report := testReportJSON(t)
+	manifest := &archiveManifest{Format: archiveFormat, Reports: []archivedReport{{AgentHash: "abcdef0123456789", archivedFile: archivedFile{File: "reports/report_abcdef01.json", SHA256: sha256Hex(report)}}}}
+	if _, _, err := readArchive(packArchive(t, manifest, map[string][]byte{"reports/report_abcdef01.json": report})); err != nil {
+		t.Fatalf("Archive should read back: %v", err)
+	}
+
+	contents := map[string][]byte{"reports/report_abcdef01.json": report, "padding.bin": make([]byte, 1024)}
+	if _, _, err := readArchive(packArchive(t, manifest, contents)); err == nil || !strings.Contains(err.Error(), "padding.bin is not listed") {
+		t.Fatalf("Files the manifest does not list should be refused, got %v", err)
+	}
+
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	archive := tar.NewWriter(gz)
+	archive.WriteHeader(&tar.Header{Name: "reports/report_abcdef01.json", Mode: 0644, Size: int64(len(report))})
+	archive.Write(report)
+	archive.Close()
+	gz.Close()
+	if _, _, err := readArchive(&buffer); err == nil || !strings.Contains(err.Error(), "does not start with") {
+		t.Fatalf("Archives that do not start with the manifest should be refused, got %v", err)
+	}
+
+	oldMax := maxArchiveBytes
+	t.Cleanup(func() { maxArchiveBytes = oldMax })
+	maxArchiveBytes = 4096
+	contents = map[string][]byte{"reports/report_abcdef01.json": append(report, make([]byte, 8192)...)}
+	if _, _, err := readArchive(packArchive(t, manifest, contents)); err == nil || !strings.Contains(err.Error(), "unpacks to more than 4096 bytes") {
+		t.Fatalf("Archives that unpack past the limit should be refused, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// Archiving a report takes it out of the default report listing without
+// deleting it: the report, its voice narrations and its upload are kept and
+// can still be fetched by hash, searched and exported, and restoring it puts
+// it back in the listing. The report file itself is left untouched so its
+// anchor still matches; the flags are kept in a store of their own next to
+// the reports. Archiving and restoring are recorded in the audit log.
+
+// Where archived report flags are kept
+var archivedReportsPath = filepath.Join("reports", "archived_reports.json")
+
+// Serializes changes to the archived reports file
+var archiveMutex sync.Mutex
+
+// archiveFlag marks an archived report
+type archiveFlag struct {
+	Hash       string    `json:"hash"`
+	ArchivedBy string    `json:"archived_by"`
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+// loadArchivedReports reads the archived reports by hash
+func loadArchivedReports() (map[string]archiveFlag, error) {
+	data, err := readStored(archivedReportsPath)
+	if os.IsNotExist(err) {
+		return map[string]archiveFlag{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	archived := make(map[string]archiveFlag)
+	if err := json.Unmarshal(data, &archived); err != nil {
+		return nil, fmt.Errorf("failed to parse archived reports: %v", err)
+	}
+	return archived, nil
+}
+
+func saveArchivedReports(archived map[string]archiveFlag) error {
+	data, _ := json.MarshalIndent(archived, "", "  ")
+	os.MkdirAll(filepath.Dir(archivedReportsPath), 0755)
+	return writeStored(archivedReportsPath, data)
+}
+
+// unarchiveReport drops a deleted report's flag
+func unarchiveReport(hash string) error {
+	archiveMutex.Lock()
+	defer archiveMutex.Unlock()
+	archived, err := loadArchivedReports()
+	if err != nil {
+		return err
+	}
+	if _, ok := archived[hash]; !ok {
+		return nil
+	}
+	delete(archived, hash)
+	return saveArchivedReports(archived)
+}
+
+func logArchival(r *http.Request, action, hash string, principal Principal) {
+	if engine == nil || engine.AuditLog() == nil {
+		return
+	}
+	engine.AuditLog().LogReportArchival(&aegong.ReportArchival{
+		Action:        action,
+		ReportHash:    hash,
+		Actor:         principal.Name,
+		Role:          string(principal.Role),
+		Timestamp:     time.Now(),
+		CorrelationID: aegong.CorrelationID(r.Context()),
+	})
+}
+
+// archiveReportHandler lets admins and auditors hide a report from the
+// default listing
+func archiveReportHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin, RoleAuditor)
+	if !ok {
+		return
+	}
+	hash := mux.Vars(r)["hash"]
+	reportPath, err := reportFile(hash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Stat(reportPath); os.IsNotExist(err) {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+
+	archiveMutex.Lock()
+	archived, err := loadArchivedReports()
+	var flag archiveFlag
+	conflict := false
+	if err == nil {
+		if _, conflict = archived[hash]; !conflict {
+			flag = archiveFlag{Hash: hash, ArchivedBy: principal.Name, ArchivedAt: time.Now().UTC()}
+			archived[hash] = flag
+			err = saveArchivedReports(archived)
+		}
+	}
+	archiveMutex.Unlock()
+	if conflict {
+		http.Error(w, fmt.Sprintf("Report %s is already archived", hash), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to archive report: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logArchival(r, "archived", hash, principal)
+	logf(r.Context(), "Report %s archived by %s", hash, principal.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flag)
+}
+
+// restoreReportHandler lets admins and auditors put an archived report back
+// in the default listing
+func restoreReportHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin, RoleAuditor)
+	if !ok {
+		return
+	}
+	hash := mux.Vars(r)["hash"]
+	if !validReportHash(hash) {
+		http.Error(w, fmt.Sprintf("invalid report hash %q", hash), http.StatusBadRequest)
+		return
+	}
+
+	archiveMutex.Lock()
+	archived, err := loadArchivedReports()
+	found := false
+	if err == nil {
+		if _, found = archived[hash]; found {
+			delete(archived, hash)
+			err = saveArchivedReports(archived)
+		}
+	}
+	archiveMutex.Unlock()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to restore report: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Report is not archived", http.StatusNotFound)
+		return
+	}
+	logArchival(r, "restored", hash, principal)
+	logf(r.Context(), "Report %s restored by %s", hash, principal.Name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
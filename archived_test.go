@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// TestArchiveReports tests archiving reports out of the default listing and restoring them
+func TestArchiveReports(t *testing.T) {
+	withTestReports(t, &aegong.AuditReport{AgentHash: "aaaaaaaa11", AgentName: "kept"}, &aegong.AuditReport{AgentHash: "bbbbbbbb22", AgentName: "old"})
+	auditLogPath := filepath.Join(t.TempDir(), "audit.log")
+	engine, _ = aegong.NewEngine(aegong.Config{AuditLogPath: auditLogPath})
+	oldTokens := apiTokens
+	t.Cleanup(func() { apiTokens = oldTokens })
+	apiTokens, _ = loadAPITokens("alice:admin:admin,ci:auditor:audit,bob:viewer:view")
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/reports", reportsHandler).Methods("GET")
+	router.HandleFunc("/api/report/{hash}/archive", archiveReportHandler).Methods("POST")
+	router.HandleFunc("/api/report/{hash}/archive", restoreReportHandler).Methods("DELETE")
+	request := func(method, path, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, r)
+		return rec
+	}
+	listed := func(query string) []string {
+		t.Helper()
+		rec := request("GET", "/api/reports"+query, "")
+		var reports []map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &reports); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%s: Should list reports, got %d: %s", query, rec.Code, rec.Body)
+		}
+		var hashes []string
+		for _, report := range reports {
+			hash := report["hash"].(string)
+			if report["archived"] == true {
+				hash += " (archived)"
+			}
+			hashes = append(hashes, hash)
+		}
+		return hashes
+	}
+
+	if rec := request("POST", "/api/report/bbbbbbbb/archive", "view"); rec.Code != http.StatusForbidden {
+		t.Fatalf("Viewers should not archive reports, got %d", rec.Code)
+	}
+	if rec := request("POST", "/api/report/cccccccc/archive", "audit"); rec.Code != http.StatusNotFound {
+		t.Fatalf("Archiving a missing report should return 404, got %d", rec.Code)
+	}
+	rec := request("POST", "/api/report/bbbbbbbb/archive", "audit")
+	var flag archiveFlag
+	if err := json.Unmarshal(rec.Body.Bytes(), &flag); err != nil || rec.Code != http.StatusOK || flag.ArchivedBy != "ci" {
+		t.Fatalf("Auditors should archive reports, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := request("POST", "/api/report/bbbbbbbb/archive", "admin"); rec.Code != http.StatusConflict {
+		t.Fatalf("Archiving an archived report should return 409, got %d", rec.Code)
+	}
+
+	if got := listed(""); len(got) != 1 || got[0] != "aaaaaaaa" {
+		t.Fatalf("Should hide archived reports by default, got %v", got)
+	}
+	if got := listed("?archived=include"); len(got) != 2 || !slices.Contains(got, "bbbbbbbb (archived)") {
+		t.Fatalf("Should list archived reports when asked, got %v", got)
+	}
+	if got := listed("?archived=only"); len(got) != 1 || got[0] != "bbbbbbbb (archived)" {
+		t.Fatalf("Should list only archived reports when asked, got %v", got)
+	}
+	if rec := request("GET", "/api/reports?archived=yes", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("Should reject an unknown archived filter, got %d", rec.Code)
+	}
+
+	if rec := request("DELETE", "/api/report/aaaaaaaa/archive", "admin"); rec.Code != http.StatusNotFound {
+		t.Fatalf("Restoring a report that is not archived should return 404, got %d", rec.Code)
+	}
+	if rec := request("DELETE", "/api/report/bbbbbbbb/archive", "admin"); rec.Code != http.StatusNoContent {
+		t.Fatalf("Admins should restore reports, got %d: %s", rec.Code, rec.Body)
+	}
+	if got := listed(""); len(got) != 2 {
+		t.Fatalf("Should list restored reports, got %v", got)
+	}
+
+	data, _ := os.ReadFile(auditLogPath)
+	log := string(data)
+	if strings.Count(log, `"event":"report_archival"`) != 2 || !strings.Contains(log, `"action":"restored"`) {
+		t.Errorf("Should audit log archiving and restoring, got:\n%s", log)
+	}
+}
+
+// TestDeleteReportCascade tests that deleting a report removes its voice reports, archive flag and upload
+func TestDeleteReportCascade(t *testing.T) {
+	// The report is of the test upload, so deleting it finds the upload
+	sum := sha256.Sum256([]byte("print('hi')\n"))
+	agentHash := hex.EncodeToString(sum[:])
+	hash := agentHash[:8]
+	withTestReports(t, &aegong.AuditReport{AgentHash: agentHash, AgentName: "greeter"})
+	auditLogPath := filepath.Join(t.TempDir(), "audit.log")
+	engine, _ = aegong.NewEngine(aegong.Config{AuditLogPath: auditLogPath})
+	oldTokens, oldVoice := apiTokens, voiceManager
+	t.Cleanup(func() { apiTokens, voiceManager = oldTokens, oldVoice })
+	apiTokens, _ = loadAPITokens("alice:admin:admin,ci:auditor:audit")
+	voiceManager = &VoiceInferenceManager{audioCache: map[string]string{voiceCacheKey(hash, "calm"): "voice_reports/aegong_report_" + hash + "_calm.wav"}}
+
+	os.MkdirAll("voice_reports", 0755)
+	voiceFiles := []string{"aegong_report_" + hash + ".wav", "aegong_report_" + hash + ".json", "aegong_report_" + hash + "_calm.wav", "aegong_report_" + hash + "_calm.json", "aegong_report_ffffffff.wav"}
+	for _, file := range voiceFiles {
+		os.WriteFile(filepath.Join("voice_reports", file), []byte("RIFF"), 0644)
+	}
+	saveArchivedReports(map[string]archiveFlag{hash: {Hash: hash, ArchivedBy: "ci"}})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/report/{hash}", deleteReportHandler).Methods("DELETE")
+	request := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("DELETE", "/api/report/"+hash, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := request("audit"); rec.Code != http.StatusForbidden {
+		t.Fatalf("Auditors should not delete reports, got %d", rec.Code)
+	}
+	if rec := request("admin"); rec.Code != http.StatusNoContent {
+		t.Fatalf("Admins should delete reports, got %d: %s", rec.Code, rec.Body)
+	}
+
+	for _, file := range voiceFiles[:4] {
+		if _, err := os.Stat(filepath.Join("voice_reports", file)); !os.IsNotExist(err) {
+			t.Errorf("Should delete voice file %s", file)
+		}
+	}
+	if _, err := os.Stat(filepath.Join("voice_reports", voiceFiles[4])); err != nil {
+		t.Error("Should keep other reports' voice files")
+	}
+	if _, ok := voiceManager.GetAudioPathForReport(hash, "calm"); ok {
+		t.Error("Should forget the cached voice report")
+	}
+	if archived, _ := loadArchivedReports(); len(archived) != 0 {
+		t.Errorf("Should drop the archive flag, got %v", archived)
+	}
+	if _, err := os.Stat(filepath.Join("uploads", "agent.py")); !os.IsNotExist(err) {
+		t.Error("Should delete the upload the report was made from")
+	}
+
+	data, _ := os.ReadFile(auditLogPath)
+	if log := string(data); strings.Count(log, `"event":"deletion"`) != 2 {
+		t.Errorf("Should audit log deleting the report and its upload, got:\n%s", log)
+	}
+}
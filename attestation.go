@@ -0,0 +1,299 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// Attestations let deployment pipelines prove an agent was audited before
+// admitting it. For a saved report the server issues an in-toto statement
+// whose subject is the agent's SHA-256 and whose predicate says which
+// AEGONG build and detector configuration audited it, when, and with what
+// result. The statement is wrapped in a DSSE envelope signed with the key in
+// AEGONG_ATTESTATION_KEY, so admission controllers holding the public key
+// can check it with standard in-toto and DSSE tooling.
+
+const (
+	inTotoStatementType  = "https://in-toto.io/Statement/v1"
+	inTotoPayloadType    = "application/vnd.in-toto+json"
+	auditPredicateType   = "https://github.com/guiperry/agent-auditor/attestation/audit/v1"
+	attestationAlgorithm = "ecdsa-p256-sha256"
+)
+
+// attestationSigner signs attestations
+type attestationSigner struct {
+	key       crypto.Signer
+	keyID     string // Hex SHA-256 of the DER public key
+	algorithm string // ecdsa-p256-sha256 or ed25519
+	publicPEM string
+}
+
+// Signer of attestations; initAttestations sets it up
+var attestations *attestationSigner
+
+// initAttestations loads the attestation key from AEGONG_ATTESTATION_KEY,
+// or makes one up that lasts until the server restarts
+func initAttestations() error {
+	var key crypto.Signer
+	if path := os.Getenv("AEGONG_ATTESTATION_KEY"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read attestation key: %v", err)
+		}
+		if key, err = parseAttestationKey(data); err != nil {
+			return err
+		}
+	} else {
+		log.Printf("Info: AEGONG_ATTESTATION_KEY is not set, attestations are signed with a key generated for this run")
+		generated, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return fmt.Errorf("failed to generate attestation key: %v", err)
+		}
+		key = generated
+	}
+	signer, err := newAttestationSigner(key)
+	if err != nil {
+		return err
+	}
+	attestations = signer
+	return nil
+}
+
+// parseAttestationKey reads a PEM encoded P-256 EC or Ed25519 private key
+func parseAttestationKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("attestation key is not PEM encoded")
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse attestation key: %v", err)
+	}
+	switch key := parsed.(type) {
+	case *ecdsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("attestation key must be a P-256 EC or Ed25519 key, got %T", parsed)
+}
+
+func newAttestationSigner(key crypto.Signer) (*attestationSigner, error) {
+	algorithm := "ed25519"
+	if ec, ok := key.(*ecdsa.PrivateKey); ok {
+		if ec.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("attestation key must use the P-256 curve, got %s", ec.Curve.Params().Name)
+		}
+		algorithm = attestationAlgorithm
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	return &attestationSigner{
+		key:       key,
+		keyID:     hex.EncodeToString(sum[:]),
+		algorithm: algorithm,
+		publicPEM: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+	}, nil
+}
+
+// inTotoStatement is an in-toto v1 statement
+type inTotoStatement struct {
+	Type          string           `json:"_type"`
+	Subject       []inTotoSubject  `json:"subject"`
+	PredicateType string           `json:"predicateType"`
+	Predicate     auditAttestation `json:"predicate"`
+}
+
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// auditAttestation is the predicate: who audited the agent, when and with
+// what result
+type auditAttestation struct {
+	Auditor   attestationAuditor `json:"auditor"`
+	AuditedAt time.Time          `json:"audited_at"`
+	Result    attestationResult  `json:"result"`
+	Report    attestationReport  `json:"report"`
+	IssuedAt  time.Time          `json:"issued_at"`
+}
+
+type attestationAuditor struct {
+	Name             string `json:"name"`
+	Version          string `json:"version"`
+	Commit           string `json:"commit,omitempty"`
+	DetectorRevision int    `json:"detector_revision"`
+	ConfigChecksum   string `json:"config_checksum"`
+	PatternFeed      int    `json:"pattern_feed_version,omitempty"`
+}
+
+type attestationResult struct {
+	RiskLevel   string         `json:"risk_level"`
+	OverallRisk float64        `json:"overall_risk"`
+	Findings    int            `json:"findings"`
+	Severities  map[string]int `json:"severities"` // Findings by severity
+}
+
+type attestationReport struct {
+	Hash   string `json:"hash"`   // First 8 characters of the agent hash, as in /api/report/{hash}
+	SHA256 string `json:"sha256"` // Of the saved report, as anchored in the transparency log
+}
+
+// dsseEnvelope is a signed DSSE envelope
+type dsseEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     string          `json:"payload"` // Base64 statement
+	Signatures  []dsseSignature `json:"signatures"`
+}
+
+type dsseSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"` // Base64
+}
+
+// dssePAE is the pre-authentication encoding DSSE signs
+func dssePAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// auditStatement describes a saved report as an in-toto statement
+func auditStatement(report *aegong.AuditReport, reportJSON []byte, now time.Time) inTotoStatement {
+	name := report.AgentName
+	if name == "" {
+		name = report.AgentHash[:8]
+	}
+	sum := sha256.Sum256(reportJSON)
+	predicate := auditAttestation{
+		Auditor: attestationAuditor{
+			Name:             "AEGONG",
+			Version:          report.Engine.Version,
+			Commit:           report.Engine.Commit,
+			DetectorRevision: report.Engine.DetectorRevision,
+			ConfigChecksum:   report.Engine.ConfigChecksum,
+		},
+		AuditedAt: report.Timestamp,
+		Result: attestationResult{
+			RiskLevel:   report.RiskLevel,
+			OverallRisk: report.OverallRisk,
+			Findings:    len(report.Threats),
+			Severities:  map[string]int{},
+		},
+		Report:   attestationReport{Hash: report.AgentHash[:8], SHA256: hex.EncodeToString(sum[:])},
+		IssuedAt: now.UTC(),
+	}
+	if report.PatternFeed != nil {
+		predicate.Auditor.PatternFeed = report.PatternFeed.Version
+	}
+	for _, threat := range report.Threats {
+		predicate.Result.Severities[aegong.SeverityName(threat.Severity)]++
+	}
+	return inTotoStatement{
+		Type:          inTotoStatementType,
+		Subject:       []inTotoSubject{{Name: name, Digest: map[string]string{"sha256": report.AgentHash}}},
+		PredicateType: auditPredicateType,
+		Predicate:     predicate,
+	}
+}
+
+// sign wraps a statement in a signed DSSE envelope
+func (s *attestationSigner) sign(statement inTotoStatement) (*dsseEnvelope, error) {
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		return nil, err
+	}
+	message := dssePAE(inTotoPayloadType, payload)
+	var signature []byte
+	if s.algorithm == attestationAlgorithm {
+		digest := sha256.Sum256(message)
+		signature, err = s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	} else {
+		signature, err = s.key.Sign(rand.Reader, message, crypto.Hash(0))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign attestation: %v", err)
+	}
+	return &dsseEnvelope{
+		PayloadType: inTotoPayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []dsseSignature{{KeyID: s.keyID, Sig: base64.StdEncoding.EncodeToString(signature)}},
+	}, nil
+}
+
+// reportAttestationHandler serves a signed attestation of a saved report
+func reportAttestationHandler(w http.ResponseWriter, r *http.Request) {
+	if attestations == nil {
+		http.Error(w, "Attestations are not configured", http.StatusServiceUnavailable)
+		return
+	}
+	reportPath, err := reportFile(mux.Vars(r)["hash"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := readStored(reportPath)
+	if os.IsNotExist(err) {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read report: %v", err), http.StatusInternalServerError)
+		return
+	}
+	report, err := parseReport(data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to parse report: %v", err), http.StatusInternalServerError)
+		return
+	}
+	// Without the engine stamp there is no version to attest to
+	if report.Engine == nil {
+		http.Error(w, "Report predates engine version stamping; re-audit the agent to attest it", http.StatusConflict)
+		return
+	}
+
+	envelope, err := attestations.sign(auditStatement(report, data, time.Now()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(envelope)
+}
+
+// attestationKeyHandler serves the public key attestations are signed with
+func attestationKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if attestations == nil {
+		http.Error(w, "Attestations are not configured", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"keyid":      attestations.keyID,
+		"algorithm":  attestations.algorithm,
+		"public_key": attestations.publicPEM,
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// TestAttestationKey tests loading EC and Ed25519 attestation keys
+func TestAttestationKey(t *testing.T) {
+	withTestUpload(t)
+	old := attestations
+	t.Cleanup(func() { attestations = old })
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	os.WriteFile("ed25519.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	der, _ = x509.MarshalECPrivateKey(p384)
+	os.WriteFile("p384.pem", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+	os.WriteFile("garbage.pem", []byte("not a key"), 0600)
+
+	for path, valid := range map[string]bool{"": true, "ed25519.pem": true, "p384.pem": false, "garbage.pem": false, "missing.pem": false} {
+		t.Setenv("AEGONG_ATTESTATION_KEY", path)
+		if err := initAttestations(); (err == nil) != valid {
+			t.Errorf("%q: Should be valid: %v, got %v", path, valid, err)
+		}
+	}
+	t.Setenv("AEGONG_ATTESTATION_KEY", "ed25519.pem")
+	initAttestations()
+	if attestations.algorithm != "ed25519" || len(attestations.keyID) != 64 {
+		t.Fatalf("Should sign with the Ed25519 key, got %s %s", attestations.algorithm, attestations.keyID)
+	}
+}
+
+// TestReportAttestation tests issuing and verifying a signed attestation of a report
+func TestReportAttestation(t *testing.T) {
+	agentHash := strings.Repeat("ab", 32)
+	withTestReports(t,
+		&aegong.AuditReport{
+			AgentHash: agentHash, AgentName: "payments-bot", Timestamp: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+			RiskLevel: "HIGH", OverallRisk: 0.72, Threats: []aegong.ThreatDetection{{Severity: aegong.HIGH}, {Severity: aegong.HIGH}, {Severity: aegong.LOW}},
+			Engine: &aegong.EngineVersion{Version: "v1.4.0", Commit: "abc123", DetectorRevision: 14, ConfigChecksum: "deadbeef"},
+		},
+		&aegong.AuditReport{AgentHash: "cccccccc33", AgentName: "legacy", Timestamp: time.Now()},
+	)
+	old := attestations
+	t.Cleanup(func() { attestations = old })
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	attestations, _ = newAttestationSigner(key)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/report/{hash}/attestation", reportAttestationHandler).Methods("GET")
+	router.HandleFunc("/api/attestation/key", attestationKeyHandler).Methods("GET")
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	for path, code := range map[string]int{
+		"/api/report/XYZ/attestation":      http.StatusBadRequest,
+		"/api/report/dddddddd/attestation": http.StatusNotFound,
+		"/api/report/cccccccc/attestation": http.StatusConflict,
+	} {
+		if w := get(path); w.Code != code {
+			t.Errorf("%s: Should answer %d, got %d", path, code, w.Code)
+		}
+	}
+
+	w := get("/api/report/abababab/attestation")
+	var envelope dsseEnvelope
+	if err := json.NewDecoder(w.Body).Decode(&envelope); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Should issue an attestation, got %d (%v)", w.Code, err)
+	}
+	if envelope.PayloadType != inTotoPayloadType || len(envelope.Signatures) != 1 || envelope.Signatures[0].KeyID != attestations.keyID {
+		t.Fatalf("Should wrap the statement in a DSSE envelope, got %+v", envelope)
+	}
+
+	// Verify it the way an admission controller would, with only the public key
+	w = get("/api/attestation/key")
+	var published struct {
+		KeyID     string `json:"keyid"`
+		PublicKey string `json:"public_key"`
+	}
+	json.NewDecoder(w.Body).Decode(&published)
+	block, _ := pem.Decode([]byte(published.PublicKey))
+	if block == nil || published.KeyID != attestations.keyID {
+		t.Fatalf("Should publish the public key, got %+v", published)
+	}
+	publicKey, _ := x509.ParsePKIXPublicKey(block.Bytes)
+	payload, _ := base64.StdEncoding.DecodeString(envelope.Payload)
+	signature, _ := base64.StdEncoding.DecodeString(envelope.Signatures[0].Sig)
+	digest := sha256.Sum256(dssePAE(envelope.PayloadType, payload))
+	if !ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), digest[:], signature) {
+		t.Fatal("Should verify against the published key")
+	}
+	tampered := sha256.Sum256(dssePAE(envelope.PayloadType, []byte(strings.Replace(string(payload), "HIGH", "LOW", 1))))
+	if ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), tampered[:], signature) {
+		t.Fatal("Should not verify a tampered statement")
+	}
+
+	var statement inTotoStatement
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		t.Fatal(err)
+	}
+	if statement.Type != inTotoStatementType || statement.PredicateType != auditPredicateType || len(statement.Subject) != 1 ||
+		statement.Subject[0].Name != "payments-bot" || statement.Subject[0].Digest["sha256"] != agentHash {
+		t.Fatalf("Should name the agent and its hash as the subject, got %+v", statement)
+	}
+	predicate := statement.Predicate
+	if predicate.Auditor.Version != "v1.4.0" || predicate.Auditor.ConfigChecksum != "deadbeef" || !predicate.AuditedAt.Equal(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Should state the engine version and audit time, got %+v", predicate)
+	}
+	if predicate.Result.RiskLevel != "HIGH" || predicate.Result.Findings != 3 || predicate.Result.Severities["HIGH"] != 2 || predicate.Report.Hash != "abababab" {
+		t.Fatalf("Should state the result, got %+v", predicate.Result)
+	}
+	saved, _ := readStored("reports/report_abababab.json")
+	if sum := sha256.Sum256(saved); predicate.Report.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("Should record the hash of the saved report, got %s", predicate.Report.SHA256)
+	}
+}
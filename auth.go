@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Role is the access level granted to an API token
+type Role string
+
+const (
+	RoleAdmin   Role = "admin"
+	RoleAuditor Role = "auditor"
+	RoleViewer  Role = "viewer"
+)
+
+// Principal is the caller identified by an API token or client certificate
+type Principal struct {
+	Name        string
+	Role        Role
+	Certificate string // SHA-256 fingerprint of the client certificate, if it identified the caller
+}
+
+type apiToken struct {
+	token     string
+	principal Principal
+}
+
+// API tokens loaded from AEGONG_API_TOKENS
+var apiTokens []apiToken
+
+// loadAPITokens parses a comma separated list of name:role:token entries
+func loadAPITokens(spec string) ([]apiToken, error) {
+	var tokens []apiToken
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid API token entry %q, expected name:role:token", entry)
+		}
+
+		role := Role(parts[1])
+		switch role {
+		case RoleAdmin, RoleAuditor, RoleViewer:
+		default:
+			return nil, fmt.Errorf("invalid role %q for API token %q", parts[1], parts[0])
+		}
+
+		tokens = append(tokens, apiToken{
+			token:     parts[2],
+			principal: Principal{Name: parts[0], Role: role},
+		})
+	}
+	return tokens, nil
+}
+
+// initAPITokens loads the API tokens from the environment
+func initAPITokens() error {
+	tokens, err := loadAPITokens(os.Getenv("AEGONG_API_TOKENS"))
+	if err != nil {
+		return err
+	}
+	apiTokens = tokens
+	return nil
+}
+
+// requestPrincipal identifies the caller from an "Authorization: Bearer"
+// header or, without one, from a verified client certificate
+func requestPrincipal(r *http.Request) (Principal, bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return certificatePrincipal(r)
+	}
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return Principal{}, false
+	}
+
+	for _, t := range apiTokens {
+		if subtle.ConstantTimeCompare([]byte(t.token), []byte(token)) == 1 {
+			return t.principal, true
+		}
+	}
+	return Principal{}, false
+}
+
+// hasRole reports whether the principal holds one of the given roles
+func (p Principal) hasRole(roles ...Role) bool {
+	for _, role := range roles {
+		if p.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// requireRole authenticates the caller and checks they hold one of roles,
+// writing a 401 or 403 response if not
+func requireRole(w http.ResponseWriter, r *http.Request, roles ...Role) (Principal, bool) {
+	principal, ok := requestPrincipal(r)
+	if !ok {
+		http.Error(w, "An API token or client certificate is required", http.StatusUnauthorized)
+		return Principal{}, false
+	}
+	if !principal.hasRole(roles...) {
+		http.Error(w, fmt.Sprintf("This operation requires one of the roles %v", roles), http.StatusForbidden)
+		return Principal{}, false
+	}
+	return principal, true
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// TestLoadAPITokens tests parsing of the AEGONG_API_TOKENS format
+func TestLoadAPITokens(t *testing.T) {
+	tokens, err := loadAPITokens("alice:admin:s3cret, ci:auditor:tok2,")
+	if err != nil {
+		t.Fatalf("Failed to parse tokens: %v", err)
+	}
+	if len(tokens) != 2 {
+		t.Fatalf("Should parse 2 tokens, got %d", len(tokens))
+	}
+	if tokens[1].principal != (Principal{Name: "ci", Role: RoleAuditor}) {
+		t.Fatalf("Unexpected principal %+v", tokens[1].principal)
+	}
+
+	for _, spec := range []string{"alice:admin", "alice:root:s3cret", ":admin:s3cret"} {
+		if _, err := loadAPITokens(spec); err == nil {
+			t.Fatalf("Spec %q should be rejected", spec)
+		}
+	}
+}
+
+// TestRequestPrincipal tests bearer token lookup
+func TestRequestPrincipal(t *testing.T) {
+	oldTokens := apiTokens
+	t.Cleanup(func() { apiTokens = oldTokens })
+	apiTokens, _ = loadAPITokens("alice:admin:s3cret,bob:viewer:view")
+
+	r := httptest.NewRequest("POST", "/api/audit/agent.py?force=true", nil)
+	if _, ok := requestPrincipal(r); ok {
+		t.Fatal("Request without a token should not be authenticated")
+	}
+
+	r.Header.Set("Authorization", "Bearer view")
+	principal, ok := requestPrincipal(r)
+	if !ok || principal.Name != "bob" {
+		t.Fatalf("Should identify bob, got %+v", principal)
+	}
+	if principal.hasRole(RoleAdmin, RoleAuditor) {
+		t.Fatal("Viewer should not hold admin or auditor roles")
+	}
+
+	r.Header.Set("Authorization", "Bearer wrong")
+	if _, ok := requestPrincipal(r); ok {
+		t.Fatal("Unknown token should not be authenticated")
+	}
+}
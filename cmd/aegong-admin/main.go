@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"Agent_Auditor/key_manager"
+)
+
+// Administers an AEGONG deployment from the command line
+func main() {
+	if len(os.Args) < 3 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] + " " + os.Args[2] {
+	case "keys import":
+		err = keysImport(os.Args[3:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", name)
+	fmt.Fprintf(os.Stderr, "  keys import   Add API keys to an encrypted key file from stdin or prompts\n")
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command.\n", name)
+}
+
+// keysImport encrypts API keys into a key file. Keys are read as NAME=value
+// lines from stdin, or prompted for when stdin is a terminal; they are only
+// held in memory until encrypted.
+func keysImport(args []string) error {
+	flags := flag.NewFlagSet("keys import", flag.ExitOnError)
+	keyFile := flags.String("key-file", "default.key", "encrypted key file to create or add to")
+	passEnv := flags.String("pass-env", "AEGONG_KEY_PASS", "environment variable holding the passphrase")
+	replace := flags.Bool("replace", false, "discard the keys already in the key file")
+	expires := flags.String("expires", "", "date the imported keys expire and should be rotated by (YYYY-MM-DD)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s keys import [flags] < keys.env\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(flags.Output(), "Reads NAME=value lines from stdin, or prompts for each key on a terminal.\n")
+		fmt.Fprintf(flags.Output(), "Key names include openai, cerebras, cartesia, azure, azure_region,\n")
+		fmt.Fprintf(flags.Output(), "google_credentials_path, LIVEKIT_API_KEY, LIVEKIT_API_SECRET and storage.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var expiresAt time.Time
+	if *expires != "" {
+		var err error
+		if expiresAt, err = time.Parse("2006-01-02", *expires); err != nil {
+			return fmt.Errorf("-expires must be a date such as 2027-01-31, got %q", *expires)
+		}
+	}
+
+	interactive := isTerminal(os.Stdin)
+	in := bufio.NewReader(os.Stdin)
+	if interactive {
+		// Echo is off while secrets are typed; put it back if interrupted
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt)
+		go func() {
+			<-interrupts
+			stty("echo")
+			fmt.Fprintln(os.Stderr)
+			os.Exit(130)
+		}()
+	}
+
+	passphrase := os.Getenv(*passEnv)
+	if passphrase == "" {
+		if !interactive {
+			return fmt.Errorf("environment variable %s not set", *passEnv)
+		}
+		var err error
+		if passphrase, err = promptPassphrase(in, *keyFile, *replace); err != nil {
+			return err
+		}
+	}
+
+	var keys map[string]string
+	var err error
+	if interactive {
+		keys, err = promptKeys(in, os.Stderr)
+	} else {
+		keys, err = key_manager.ParseKeys(os.Stdin)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read keys: %v", err)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys given")
+	}
+
+	names, err := importKeys(*keyFile, passphrase, keys, *replace, expiresAt)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "✅ Imported %d keys into %s, which now holds: %s\n", len(keys), *keyFile, strings.Join(names, ", "))
+	return nil
+}
+
+// importKeys adds keys to the key file, creating it if needed, and returns
+// the names of every key it then holds. Existing keys of the same name are
+// replaced; with replace, so are all the others. Imported keys are recorded
+// as created now and expiring at expiresAt, if set.
+func importKeys(keyFile, passphrase string, keys map[string]string, replace bool, expiresAt time.Time) ([]string, error) {
+	merged := make(map[string]string)
+	metadata := make(map[string]key_manager.KeyMetadata)
+	if _, err := os.Stat(keyFile); err == nil && !replace {
+		manager := key_manager.NewKeyManager(keyFile)
+		if err := manager.Initialize(passphrase); err != nil {
+			return nil, err
+		}
+		if err := manager.LoadKeys(); err != nil {
+			return nil, fmt.Errorf("%v (use -replace to overwrite the key file)", err)
+		}
+		manager.AllowExpired(true)
+		for _, name := range manager.GetAllKeys() {
+			merged[name], _ = manager.GetKey(name)
+			metadata[name], _ = manager.GetKeyMetadata(name)
+		}
+	}
+	for name, value := range keys {
+		if name == "" || strings.ContainsAny(name, " \t=") {
+			return nil, fmt.Errorf("invalid key name %q", name)
+		}
+		merged[name] = value
+		metadata[name] = key_manager.KeyMetadata{CreatedAt: time.Now().UTC(), ExpiresAt: expiresAt}
+	}
+
+	if err := key_manager.CreateKeyFileWithMetadata(keyFile, passphrase, merged, metadata); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(merged))
+	for name := range merged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// promptPassphrase asks for the key file's passphrase, twice for a new file
+func promptPassphrase(in *bufio.Reader, keyFile string, replace bool) (string, error) {
+	fmt.Fprint(os.Stderr, "Passphrase: ")
+	passphrase, err := readSecret(in)
+	if err != nil || passphrase == "" {
+		return "", fmt.Errorf("no passphrase given")
+	}
+	if _, err := os.Stat(keyFile); err == nil && !replace {
+		return passphrase, nil
+	}
+	fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+	if confirm, _ := readSecret(in); confirm != passphrase {
+		return "", fmt.Errorf("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// promptKeys asks for key names and values until a blank name
+func promptKeys(in *bufio.Reader, out io.Writer) (map[string]string, error) {
+	keys := make(map[string]string)
+	for {
+		fmt.Fprint(out, "Key name (blank to finish): ")
+		name, err := in.ReadString('\n')
+		name = strings.TrimSpace(name)
+		if name == "" {
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			return keys, nil
+		}
+
+		fmt.Fprintf(out, "Value for %s: ", name)
+		value, err := readSecret(in)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			fmt.Fprintf(out, "Skipping %s, no value given\n", name)
+			continue
+		}
+		keys[name] = value
+	}
+}
+
+// readSecret reads a line without echoing it to the terminal
+func readSecret(in *bufio.Reader) (string, error) {
+	if stty("-echo") == nil {
+		defer stty("echo")
+		defer fmt.Fprintln(os.Stderr)
+	}
+	line, err := in.ReadString('\n')
+	if err != nil && !(err == io.EOF && line != "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// stty changes the terminal's settings
+func stty(setting string) error {
+	cmd := exec.Command("stty", setting)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+// isTerminal reports whether a file is a terminal rather than a pipe or file
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
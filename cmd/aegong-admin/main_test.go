@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"Agent_Auditor/key_manager"
+)
+
+// TestImportKeys tests creating a key file and adding keys to it
+func TestImportKeys(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "default.key")
+	keys, err := key_manager.ParseKeys(strings.NewReader("# Voice providers\nopenai=sk-one\n\ncartesia=\"ca-two\"\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse keys: %v", err)
+	}
+
+	names, err := importKeys(keyFile, "passphrase", keys, false, time.Time{})
+	if err != nil || !reflect.DeepEqual(names, []string{"cartesia", "openai"}) {
+		t.Fatalf("Should create the key file, got %v, %v", names, err)
+	}
+
+	names, err = importKeys(keyFile, "passphrase", map[string]string{"openai": "sk-three", "storage": "s3cret"}, false, time.Time{})
+	if err != nil || !reflect.DeepEqual(names, []string{"cartesia", "openai", "storage"}) {
+		t.Fatalf("Should add to the existing keys, got %v, %v", names, err)
+	}
+	manager := key_manager.NewKeyManager(keyFile)
+	manager.Initialize("passphrase")
+	if err := manager.LoadKeys(); err != nil {
+		t.Fatalf("Key file should decrypt: %v", err)
+	}
+	if value, _ := manager.GetKey("openai"); value != "sk-three" {
+		t.Errorf("Imported keys should replace those of the same name, got %q", value)
+	}
+	if value, _ := manager.GetKey("cartesia"); value != "ca-two" {
+		t.Errorf("Other keys should be kept, got %q", value)
+	}
+
+	if _, err := importKeys(keyFile, "wrong", map[string]string{"azure": "az"}, false, time.Time{}); err == nil || !strings.Contains(err.Error(), "-replace") {
+		t.Errorf("A wrong passphrase should not overwrite the key file, got %v", err)
+	}
+	if names, err := importKeys(keyFile, "new passphrase", map[string]string{"azure": "az"}, true, time.Time{}); err != nil || !reflect.DeepEqual(names, []string{"azure"}) {
+		t.Errorf("-replace should start a new key file, got %v, %v", names, err)
+	}
+	if _, err := importKeys(keyFile, "new passphrase", map[string]string{"bad name": "x"}, false, time.Time{}); err == nil {
+		t.Error("Key names with spaces should be rejected")
+	}
+}
+
+// TestPromptKeys tests reading keys interactively until a blank name
+func TestPromptKeys(t *testing.T) {
+	in := bufio.NewReader(strings.NewReader("openai\nsk-one\nazure\n\ncartesia\nca-two\n\n"))
+	keys, err := promptKeys(in, io.Discard)
+	if err != nil || !reflect.DeepEqual(keys, map[string]string{"openai": "sk-one", "cartesia": "ca-two"}) {
+		t.Errorf("Should read the keys given values, got %v, %v", keys, err)
+	}
+}
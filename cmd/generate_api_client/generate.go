@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// The subset of OpenAPI 3.0 that openapi.json uses
+
+type spec struct {
+	Info struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type schema struct {
+	Ref         string             `json:"$ref"`
+	Type        string             `json:"type"`
+	Format      string             `json:"format"`
+	Description string             `json:"description"`
+	Enum        []string           `json:"enum"`
+	Items       *schema            `json:"items"`
+	Properties  map[string]*schema `json:"properties"`
+	Required    []string           `json:"required"`
+	AllOf       []*schema          `json:"allOf"`
+	Nullable    bool               `json:"nullable"`
+	// Either true or the schema of the values of a map
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+}
+
+type operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Parameters  []parameter          `json:"parameters"`
+	RequestBody *body                `json:"requestBody"`
+	Responses   map[string]*response `json:"responses"`
+	Client      *bool                `json:"x-aegong-client"` // False leaves the operation out of the client
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type body struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Content map[string]*mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// Ordered HTTP methods, so the client lists operations in a stable order
+var methods = []string{"get", "post", "put", "patch", "delete"}
+
+// method is one client method
+type method struct {
+	name        string
+	summary     string
+	httpMethod  string
+	path        string // With ${} placeholders for path parameters
+	pathParams  []string
+	query       []parameter
+	bodyType    string // Empty without a request body
+	bodyOptions bool   // Whether the body may be left out
+	contentType string
+	result      string // TypeScript type of the response
+	expect      string // json, blob or none
+}
+
+// References to the specification's schemas
+var schemaRef = regexp.MustCompile(`"\$ref"\s*:\s*"#/components/schemas/([^"]+)"`)
+
+// generate renders the TypeScript and JavaScript clients of a specification
+func generate(data []byte) ([]byte, []byte, error) {
+	var s spec
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, nil, fmt.Errorf("invalid specification: %v", err)
+	}
+	for _, ref := range schemaRef.FindAllSubmatch(data, -1) {
+		if s.Components.Schemas[string(ref[1])] == nil {
+			return nil, nil, fmt.Errorf("reference to undefined schema %s", ref[1])
+		}
+	}
+	methods, err := clientMethods(&s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ts, js bytes.Buffer
+	header := fmt.Sprintf("// Client for the %s %s, generated from openapi.json by `make api-client`.\n// Do not edit; change the specification and regenerate.\n", s.Info.Title, s.Info.Version)
+	ts.WriteString(header)
+	ts.WriteString("// The UI loads the JavaScript build, static/js/aegong-api.js.\n\n")
+	js.WriteString(header)
+	js.WriteString("// Types are in static/ts/aegong-api.ts.\n\n")
+
+	names := make([]string, 0, len(s.Components.Schemas))
+	for name := range s.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := writeModel(&ts, name, s.Components.Schemas[name]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	writeClient(&ts, methods, true)
+	writeClient(&js, methods, false)
+	return ts.Bytes(), js.Bytes(), nil
+}
+
+// clientMethods lists the operations of the client, ordered by path and method
+func clientMethods(s *spec) ([]method, error) {
+	paths := make([]string, 0, len(s.Paths))
+	for path := range s.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var all []method
+	for _, path := range paths {
+		for _, httpMethod := range methods {
+			op := s.Paths[path][httpMethod]
+			if op == nil || (op.Client != nil && !*op.Client) {
+				continue
+			}
+			if op.OperationID == "" {
+				return nil, fmt.Errorf("%s %s has no operationId", strings.ToUpper(httpMethod), path)
+			}
+			m := method{name: op.OperationID, summary: op.Summary, httpMethod: strings.ToUpper(httpMethod), path: path, expect: "none", result: "void"}
+
+			for _, p := range op.Parameters {
+				switch p.In {
+				case "path":
+					m.pathParams = append(m.pathParams, p.Name)
+					m.path = strings.Replace(m.path, "{"+p.Name+"}", "${encodeURIComponent("+identifier(p.Name)+")}", 1)
+				case "query":
+					m.query = append(m.query, p)
+				default:
+					return nil, fmt.Errorf("%s: unsupported %s parameter %s", op.OperationID, p.In, p.Name)
+				}
+			}
+
+			if op.RequestBody != nil {
+				contentType, media, err := single(op.RequestBody.Content)
+				if err != nil {
+					return nil, fmt.Errorf("%s request: %v", op.OperationID, err)
+				}
+				m.contentType = contentType
+				m.bodyOptions = !op.RequestBody.Required
+				switch contentType {
+				case "application/json":
+					m.bodyType = tsType(media.Schema)
+				case "multipart/form-data":
+					m.bodyType = "FormData"
+				default:
+					m.bodyType = "Blob"
+				}
+			}
+
+			if r := success(op.Responses); r != nil && len(r.Content) > 0 {
+				contentType, media, err := single(r.Content)
+				if err != nil {
+					return nil, fmt.Errorf("%s response: %v", op.OperationID, err)
+				}
+				if contentType == "application/json" {
+					m.result, m.expect = tsType(media.Schema), "json"
+				} else {
+					m.result, m.expect = "Blob", "blob"
+				}
+			}
+			all = append(all, m)
+		}
+	}
+	return all, nil
+}
+
+// single returns the only media type of a body or response
+func single(content map[string]*mediaType) (string, *mediaType, error) {
+	if len(content) != 1 {
+		return "", nil, fmt.Errorf("expected one media type, got %d", len(content))
+	}
+	for contentType, media := range content {
+		return contentType, media, nil
+	}
+	return "", nil, nil
+}
+
+// success returns the first 2xx response of an operation
+func success(responses map[string]*response) *response {
+	codes := make([]string, 0, len(responses))
+	for code := range responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") {
+			return responses[code]
+		}
+	}
+	return nil
+}
+
+// tsType is the TypeScript type of a schema
+func tsType(s *schema) string {
+	if s == nil {
+		return "any"
+	}
+	var t string
+	switch {
+	case s.Ref != "":
+		t = s.Ref[strings.LastIndex(s.Ref, "/")+1:]
+	case len(s.AllOf) == 1:
+		t = tsType(s.AllOf[0])
+	case len(s.AllOf) > 1:
+		parts := make([]string, len(s.AllOf))
+		for i, part := range s.AllOf {
+			parts[i] = tsType(part)
+		}
+		t = strings.Join(parts, " & ")
+	case len(s.Enum) > 0:
+		literals := make([]string, len(s.Enum))
+		for i, value := range s.Enum {
+			literals[i] = fmt.Sprintf("%q", value)
+		}
+		t = strings.Join(literals, " | ")
+	case s.Type == "string" && s.Format == "binary":
+		t = "Blob"
+	case s.Type == "string":
+		t = "string"
+	case s.Type == "integer" || s.Type == "number":
+		t = "number"
+	case s.Type == "boolean":
+		t = "boolean"
+	case s.Type == "array":
+		t = tsType(s.Items)
+		if strings.Contains(t, " | ") {
+			t = "(" + t + ")"
+		}
+		t += "[]"
+	case len(s.Properties) > 0:
+		var fields []string
+		for _, name := range sortedKeys(s.Properties) {
+			fields = append(fields, fmt.Sprintf("%s%s: %s", name, optional(s, name), tsType(s.Properties[name])))
+		}
+		t = "{ " + strings.Join(fields, "; ") + " }"
+	default:
+		t = "Record<string, " + tsType(additional(s)) + ">"
+	}
+	if s.Nullable {
+		t += " | null"
+	}
+	return t
+}
+
+// additional is the schema of a map's values, nil for any
+func additional(s *schema) *schema {
+	var values schema
+	if len(s.AdditionalProperties) == 0 || json.Unmarshal(s.AdditionalProperties, &values) != nil {
+		return nil
+	}
+	return &values
+}
+
+func optional(s *schema, property string) string {
+	for _, name := range s.Required {
+		if name == property {
+			return ""
+		}
+	}
+	return "?"
+}
+
+func sortedKeys(properties map[string]*schema) []string {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeModel renders a schema as an interface, or a type alias if it is not an object
+func writeModel(w *bytes.Buffer, name string, s *schema) error {
+	if !unicode.IsUpper(rune(name[0])) {
+		return fmt.Errorf("schema %s should be named in PascalCase", name)
+	}
+	if s.Description != "" {
+		fmt.Fprintf(w, "// %s\n", s.Description)
+	}
+	if len(s.Properties) == 0 {
+		fmt.Fprintf(w, "type %s = %s;\n\n", name, tsType(s))
+		return nil
+	}
+	fmt.Fprintf(w, "interface %s {\n", name)
+	for _, property := range sortedKeys(s.Properties) {
+		field := s.Properties[property]
+		if field.Description != "" {
+			fmt.Fprintf(w, "    // %s\n", field.Description)
+		}
+		fmt.Fprintf(w, "    %s%s: %s;\n", property, optional(s, property), tsType(field))
+	}
+	w.WriteString("}\n\n")
+	return nil
+}
+
+// identifier turns a parameter name into a JavaScript identifier
+func identifier(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		switch {
+		case r == '_' || r == '-':
+			upper = b.Len() > 0
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// writeClient renders the client class, with types if typed
+func writeClient(w *bytes.Buffer, methods []method, typed bool) {
+	// t returns a type annotation only in the TypeScript client
+	t := func(annotation string) string {
+		if typed {
+			return annotation
+		}
+		return ""
+	}
+
+	w.WriteString("// AegongAPIError is a response outside 2xx; body is its JSON, if any\n")
+	if typed {
+		w.WriteString(`class AegongAPIError extends Error {
+    constructor(public status: number, message: string, public body?: any) {
+        super(message);
+    }
+}
+
+interface AegongAPIOptions {
+    // Prefix of every path; the page's own server by default
+    baseURL?: string;
+    // API token sent as a bearer token
+    token?: string;
+    // Extra headers for each request, such as the CSRF token
+    headers?: () => Record<string, string>;
+}
+
+`)
+	} else {
+		w.WriteString(`class AegongAPIError extends Error {
+    constructor(status, message, body) {
+        super(message);
+        this.status = status;
+        this.body = body;
+    }
+}
+
+`)
+	}
+
+	fmt.Fprintf(w, "class AegongAPI {\n")
+	if typed {
+		w.WriteString("    constructor(private options: AegongAPIOptions = {}) {}\n")
+	} else {
+		w.WriteString("    constructor(options = {}) {\n        this.options = options;\n    }\n")
+	}
+
+	for _, m := range methods {
+		var params []string
+		for _, name := range m.pathParams {
+			params = append(params, identifier(name)+t(": string"))
+		}
+		bodyArg := "undefined"
+		if m.bodyType != "" {
+			bodyArg = "body"
+			if m.bodyOptions {
+				params = append(params, "body"+t("?: "+m.bodyType))
+			} else {
+				params = append(params, "body"+t(": "+m.bodyType))
+			}
+		}
+		queryArg := "undefined"
+		if len(m.query) > 0 {
+			queryArg = "query"
+			var fields []string
+			for _, p := range m.query {
+				fields = append(fields, fmt.Sprintf("%s?: %s", p.Name, tsType(p.Schema)))
+			}
+			params = append(params, "query"+t(": { "+strings.Join(fields, "; ")+" }")+" = {}")
+		}
+
+		fmt.Fprintf(w, "\n    // %s\n", m.summary)
+		fmt.Fprintf(w, "    %s(%s)%s {\n", m.name, strings.Join(params, ", "), t(": Promise<"+m.result+">"))
+		fmt.Fprintf(w, "        return this.request(%q, `%s`, %s, %s, %q, %q);\n", m.httpMethod, m.path, queryArg, bodyArg, m.contentType, m.expect)
+		w.WriteString("    }\n")
+	}
+
+	query := "query"
+	if typed {
+		query = "(query as Record<string, any>)"
+	}
+	fmt.Fprintf(w, `
+    // Sends a request and decodes its response; responses outside 2xx reject with an AegongAPIError
+    %sasync request(method%s, path%s, query%s, body%s, contentType%s, expect%s)%s {
+        const url = new URL((this.options.baseURL || "") + path, window.location.origin);
+        Object.keys(query || {}).forEach(key => {
+            const value = %s[key];
+            if (value !== undefined && value !== null && value !== "") {
+                url.searchParams.set(key, String(value));
+            }
+        });
+
+        const headers%s = Object.assign({}, this.options.headers ? this.options.headers() : {});
+        if (this.options.token) {
+            headers["Authorization"] = `+"`Bearer ${this.options.token}`"+`;
+        }
+        let payload%s;
+        if (body !== undefined) {
+            if (contentType === "application/json") {
+                payload = JSON.stringify(body);
+            } else {
+                payload = body;
+            }
+            // FormData sets its own multipart boundary
+            if (contentType !== "multipart/form-data") {
+                headers["Content-Type"] = contentType;
+            }
+        }
+
+        const response = await fetch(url.toString(), { method, headers, body: payload });
+        if (!response.ok) {
+            const text = await response.text();
+            let parsed%s;
+            try {
+                parsed = JSON.parse(text);
+            } catch (error) {
+                parsed = undefined;
+            }
+            throw new AegongAPIError(response.status, (parsed && parsed.error) || text.trim() || response.statusText, parsed);
+        }
+        if (expect === "json") {
+            return response.json();
+        }
+        if (expect === "blob") {
+            return response.blob();
+        }
+        return undefined;
+    }
+}
+
+(window%s).AegongAPI = AegongAPI;
+(window%s).AegongAPIError = AegongAPIError;
+`, t("private "), t(": string"), t(": string"), t(": Record<string, any> | undefined"), t(": any"), t(": string"), t(": string"), t(": Promise<any>"),
+		query,
+		t(": Record<string, string>"), t(": BodyInit | undefined"), t(": any"), t(" as any"), t(" as any"))
+}
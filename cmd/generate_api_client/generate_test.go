@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// TestClientUpToDate tests that the committed client was generated from the committed specification
+func TestClientUpToDate(t *testing.T) {
+	data, err := os.ReadFile("../../openapi.json")
+	if err != nil {
+		t.Fatalf("Failed to read specification: %v", err)
+	}
+	ts, js, err := generate(data)
+	if err != nil {
+		t.Fatalf("Failed to generate client: %v", err)
+	}
+	for path, want := range map[string][]byte{"../../static/ts/aegong-api.ts": ts, "../../static/js/aegong-api.js": js} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s should match openapi.json, run make api-client", path)
+		}
+	}
+}
+
+// TestGenerateRejectsUnknownSchema tests that references to undefined schemas fail generation
+func TestGenerateRejectsUnknownSchema(t *testing.T) {
+	spec := `{"info":{"title":"t","version":"1"},"paths":{"/x":{"get":{"operationId":"getX","responses":{"200":{"description":"ok","content":{"application/json":{"schema":{"$ref":"#/components/schemas/Missing"}}}}}}}}}`
+	if _, _, err := generate([]byte(spec)); err == nil {
+		t.Error("Should fail on a reference to an undefined schema")
+	}
+}
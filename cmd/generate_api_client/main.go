@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Generates the web UI's API client from openapi.json: typed models and a
+// client in TypeScript, and the same client in plain JavaScript that the UI
+// loads without a build step
+func main() {
+	specPath := flag.String("spec", "openapi.json", "OpenAPI specification of the server")
+	tsOut := flag.String("ts", "static/ts/aegong-api.ts", "where to write the TypeScript client")
+	jsOut := flag.String("js", "static/js/aegong-api.js", "where to write the JavaScript client")
+	flag.Parse()
+
+	data, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatalf("Failed to read specification: %v", err)
+	}
+	ts, js, err := generate(data)
+	if err != nil {
+		log.Fatalf("Failed to generate client: %v", err)
+	}
+	if err := os.WriteFile(*tsOut, ts, 0644); err != nil {
+		log.Fatalf("Failed to write TypeScript client: %v", err)
+	}
+	if err := os.WriteFile(*jsOut, js, 0644); err != nil {
+		log.Fatalf("Failed to write JavaScript client: %v", err)
+	}
+	fmt.Printf("Client written to %s and %s\n", *tsOut, *jsOut)
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// Audits an agent on a remote AEGONG server: uploads it, queues an audit job,
+// polls the job until it finishes and fetches the report
+func main() {
+	server := flag.String("server", envOr("AEGONG_SERVER", "http://localhost:8084"), "URL of the AEGONG server")
+	token := flag.String("token", os.Getenv("AEGONG_TOKEN"), "API token sent as a bearer token")
+	manifest := flag.String("manifest", "", "agent manifest to check the declared capabilities against")
+	force := flag.Bool("force", false, "audit even if the agent fails validation (admin or auditor token)")
+	out := flag.String("out", "", "where to write the JSON report")
+	poll := flag.Duration("poll", 2*time.Second, "how often to poll the audit job")
+	failOn := flag.String("fail-on", "", "exit with status 2 if the risk level is at least this (LOW, MEDIUM, HIGH or CRITICAL)")
+	quiet := flag.Bool("quiet", false, "don't show progress")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <agent>\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *failOn != "" && riskRank(strings.ToUpper(*failOn)) < 0 {
+		log.Fatalf("Unknown risk level %q", *failOn)
+	}
+
+	client := &remoteClient{server: strings.TrimRight(*server, "/"), token: *token, http: &http.Client{Timeout: time.Minute}}
+	progress := io.Writer(os.Stderr)
+	if *quiet {
+		progress = io.Discard
+	}
+
+	// Ctrl-C cancels the remote job as well as the wait
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, data, err := client.audit(ctx, flag.Arg(0), *manifest, *force, *poll, progress)
+	if err != nil {
+		log.Fatalf("Audit failed: %v", err)
+	}
+
+	if *out != "" {
+		if err := os.WriteFile(*out, data, 0644); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+	}
+	printSummary(os.Stdout, report)
+
+	if *failOn != "" && riskRank(report.RiskLevel) >= riskRank(strings.ToUpper(*failOn)) {
+		os.Exit(2)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// remoteClient calls the AEGONG HTTP API
+type remoteClient struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// remoteJob is the part of an audit job the client reads
+type remoteJob struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	QueuePosition int    `json:"queue_position"`
+	Error         string `json:"error"`
+	ReportHash    string `json:"report_hash"`
+}
+
+// do sends a request with the API token and decodes a JSON response into
+// result, turning error statuses into errors carrying the server's message
+func (c *remoteClient) do(ctx context.Context, method, path, contentType string, body io.Reader, result interface{}) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if result != nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			return nil, fmt.Errorf("invalid response from %s: %v", path, err)
+		}
+	}
+	return data, nil
+}
+
+// upload sends the agent, and its manifest if given, returning the name the
+// server stored it under
+func (c *remoteClient) upload(ctx context.Context, agentPath, manifestPath string) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	files := []struct{ field, path string }{{"agent", agentPath}, {"manifest", manifestPath}}
+	for _, file := range files {
+		if file.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			return "", err
+		}
+		part, err := form.CreateFormFile(file.field, filepath.Base(file.path))
+		if err != nil {
+			return "", err
+		}
+		part.Write(data)
+	}
+	form.Close()
+
+	var uploaded struct {
+		Filename string `json:"filename"`
+	}
+	if _, err := c.do(ctx, http.MethodPost, "/api/upload", form.FormDataContentType(), &body, &uploaded); err != nil {
+		return "", err
+	}
+	return uploaded.Filename, nil
+}
+
+// audit runs the whole remote audit, writing progress as it goes, and
+// returns the report both decoded and as the server sent it
+func (c *remoteClient) audit(ctx context.Context, agentPath, manifestPath string, force bool, poll time.Duration, progress io.Writer) (*aegong.AuditReport, []byte, error) {
+	fmt.Fprintf(progress, "Uploading %s to %s\n", filepath.Base(agentPath), c.server)
+	filename, err := c.upload(ctx, agentPath, manifestPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("upload failed: %v", err)
+	}
+
+	request, _ := json.Marshal(map[string]interface{}{"filename": filename, "force": force})
+	var job remoteJob
+	if _, err := c.do(ctx, http.MethodPost, "/api/jobs", "application/json", bytes.NewReader(request), &job); err != nil {
+		return nil, nil, fmt.Errorf("failed to queue audit: %v", err)
+	}
+
+	job, err = c.wait(ctx, job, poll, progress)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch job.Status {
+	case "completed":
+	case "failed":
+		return nil, nil, errors.New(job.Error)
+	default:
+		return nil, nil, fmt.Errorf("audit job %s was %s", job.ID, job.Status)
+	}
+
+	var report aegong.AuditReport
+	data, err := c.do(ctx, http.MethodGet, "/api/report/"+job.ReportHash, "", nil, &report)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch report: %v", err)
+	}
+	return &report, data, nil
+}
+
+// wait polls a job until it finishes, showing its status as it changes. If
+// ctx is cancelled first, the job is cancelled on the server too.
+func (c *remoteClient) wait(ctx context.Context, job remoteJob, poll time.Duration, progress io.Writer) (remoteJob, error) {
+	started := time.Now()
+	last := ""
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for {
+		status := job.Status
+		if job.Status == "queued" {
+			status = fmt.Sprintf("queued, position %d", job.QueuePosition)
+		}
+		if status != last {
+			fmt.Fprintf(progress, "Job %s %s (%s)\n", job.ID, status, time.Since(started).Round(time.Second))
+			last = status
+		}
+		if job.Status != "queued" && job.Status != "running" {
+			return job, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			cancelCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if _, err := c.do(cancelCtx, http.MethodDelete, "/api/jobs/"+job.ID, "", nil, nil); err != nil {
+				log.Printf("Warning: Failed to cancel job %s: %v", job.ID, err)
+			}
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+
+		if _, err := c.do(ctx, http.MethodGet, "/api/jobs/"+job.ID, "", nil, &job); err != nil && ctx.Err() == nil {
+			return job, fmt.Errorf("failed to poll job %s: %v", job.ID, err)
+		}
+	}
+}
+
+// Risk levels in increasing order
+var riskLevels = []string{"MINIMAL", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+func riskRank(level string) int {
+	for i, name := range riskLevels {
+		if name == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// printSummary writes the verdict and findings of a report
+func printSummary(w io.Writer, report *aegong.AuditReport) {
+	fmt.Fprintf(w, "%s (%s)\n", report.AgentName, report.AgentHash)
+	fmt.Fprintf(w, "Risk: %s (%.0f%%), %d threats\n", report.RiskLevel, report.OverallRisk*100, len(report.Threats))
+	for _, threat := range report.Threats {
+		fmt.Fprintf(w, "  [%s] %s (confidence %.0f%%)", threat.SeverityName, threat.VectorName, threat.Confidence*100)
+		if threat.Location != nil {
+			fmt.Fprintf(w, " at %s", threat.Location)
+		}
+		fmt.Fprintln(w)
+		for _, evidence := range threat.Evidence {
+			fmt.Fprintf(w, "      %s\n", evidence)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer answers the upload, jobs and report endpoints, finishing the job
+// on its second poll
+func fakeServer(t *testing.T, finalStatus string) (*httptest.Server, *[]string) {
+	var calls []string
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Should send the API token, got %q", r.Header.Get("Authorization"))
+		}
+		switch {
+		case r.URL.Path == "/api/upload":
+			file, header, err := r.FormFile("agent")
+			if err != nil {
+				t.Errorf("Should upload the agent: %v", err)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			if header.Filename != "agent.py" || string(data) != "print('hi')" {
+				t.Errorf("Should upload the agent's name and contents, got %q %q", header.Filename, data)
+			}
+			json.NewEncoder(w).Encode(map[string]string{"filename": "1_agent.py"})
+		case r.URL.Path == "/api/jobs":
+			var request map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&request)
+			if request["filename"] != "1_agent.py" {
+				t.Errorf("Should queue the uploaded file, got %v", request)
+			}
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(remoteJob{ID: "job1", Status: "queued", QueuePosition: 1})
+		case r.URL.Path == "/api/jobs/job1" && r.Method == http.MethodGet:
+			polls++
+			job := remoteJob{ID: "job1", Status: "running"}
+			if polls >= 2 {
+				job = remoteJob{ID: "job1", Status: finalStatus, ReportHash: "abc", Error: "not an agent"}
+			}
+			json.NewEncoder(w).Encode(job)
+		case r.URL.Path == "/api/report/abc":
+			w.Write([]byte(`{"agent_hash":"abc","agent_name":"agent.py","risk_level":"HIGH","overall_risk":0.7,"threats":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func writeAgent(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "agent.py")
+	if err := os.WriteFile(path, []byte("print('hi')"), 0644); err != nil {
+		t.Fatalf("Failed to write agent: %v", err)
+	}
+	return path
+}
+
+// TestRemoteAudit tests uploading, polling and fetching the report
+func TestRemoteAudit(t *testing.T) {
+	server, calls := fakeServer(t, "completed")
+	client := &remoteClient{server: server.URL, token: "secret", http: server.Client()}
+
+	var progress strings.Builder
+	report, data, err := client.audit(context.Background(), writeAgent(t), "", false, time.Millisecond, &progress)
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if report.RiskLevel != "HIGH" || report.AgentHash != "abc" {
+		t.Errorf("Should decode the report, got %+v", report)
+	}
+	if !strings.Contains(string(data), `"agent_hash":"abc"`) {
+		t.Errorf("Should return the report as sent, got %s", data)
+	}
+	for _, status := range []string{"queued, position 1", "running", "completed"} {
+		if !strings.Contains(progress.String(), status) {
+			t.Errorf("Should show the %q status, got %q", status, progress.String())
+		}
+	}
+	if (*calls)[len(*calls)-1] != "GET /api/report/abc" {
+		t.Errorf("Should fetch the report last, got %v", *calls)
+	}
+}
+
+// TestRemoteAuditFailed tests that a failed job is reported with its error
+func TestRemoteAuditFailed(t *testing.T) {
+	server, _ := fakeServer(t, "failed")
+	client := &remoteClient{server: server.URL, token: "secret", http: server.Client()}
+
+	_, _, err := client.audit(context.Background(), writeAgent(t), "", false, time.Millisecond, io.Discard)
+	if err == nil || err.Error() != "not an agent" {
+		t.Errorf("Should return the job's error, got %v", err)
+	}
+}
+
+// TestRemoteAuditCancelled tests that cancelling the wait cancels the job
+func TestRemoteAuditCancelled(t *testing.T) {
+	cancelled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete && r.URL.Path == "/api/jobs/job1" {
+			close(cancelled)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+	client := &remoteClient{server: server.URL, http: server.Client()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.wait(ctx, remoteJob{ID: "job1", Status: "running"}, time.Hour, io.Discard); err != context.Canceled {
+		t.Errorf("Should return the cancellation, got %v", err)
+	}
+	select {
+	case <-cancelled:
+	default:
+		t.Error("Should cancel the job on the server")
+	}
+}
+
+// TestRiskRank tests the ordering used by -fail-on
+func TestRiskRank(t *testing.T) {
+	if riskRank("HIGH") <= riskRank("MEDIUM") || riskRank("MINIMAL") != 0 {
+		t.Error("Should rank risk levels in increasing order")
+	}
+	if riskRank("SEVERE") != -1 {
+		t.Error("Should not rank unknown levels")
+	}
+}
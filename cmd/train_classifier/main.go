@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// Trains the embedded agent classifier from a labelled corpus laid out as
+// <corpus>/agent/* and <corpus>/other/*
+func main() {
+	corpus := flag.String("corpus", "pkg/aegong/testdata/classifier", "directory containing agent/ and other/ samples")
+	out := flag.String("out", "pkg/aegong/model/agent_classifier.json", "where to write the trained model")
+	version := flag.String("version", "logreg-v2", "model version recorded in validation results")
+	flag.Parse()
+
+	samples, labels, err := aegong.LoadClassifierCorpus(*corpus)
+	if err != nil {
+		log.Fatalf("Failed to load corpus: %v", err)
+	}
+	if len(samples) == 0 {
+		log.Fatalf("No samples found under %s", *corpus)
+	}
+
+	model := aegong.TrainClassifier(*version, samples, labels)
+
+	// Report accuracy on the samples the weights were fit to and on those
+	// held out for calibration as a sanity check
+	heldOut := aegong.ClassifierHoldout(labels)
+	var correct, total [2]int
+	for i, features := range samples {
+		split := 0
+		if heldOut[i] {
+			split = 1
+		}
+		total[split]++
+		if (model.Probability(features) >= model.Threshold) == labels[i] {
+			correct[split]++
+		}
+	}
+	fmt.Printf("Trained %s on %d samples, training accuracy %d/%d, held out accuracy %d/%d\n",
+		model.Version, len(samples), correct[0], total[0], correct[1], total[1])
+
+	data, err := json.MarshalIndent(model, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode model: %v", err)
+	}
+	if err := os.WriteFile(*out, append(data, '\n'), 0644); err != nil {
+		log.Fatalf("Failed to write model: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// Triage discussion is kept next to the report it is about, in a comments
+// file beside the report file, so the report itself and its anchor hash never
+// change. Each comment is on the report as a whole or on one of its findings;
+// the comments on the same target, oldest first, are its thread.
+
+// Longest comment body accepted
+const maxCommentBody = 10000
+
+// Serializes changes to comments files
+var commentsMutex sync.Mutex
+
+// reportComment is a comment on a report or one of its findings
+type reportComment struct {
+	ID        string    `json:"id"`
+	Threat    *int      `json:"threat,omitempty"` // Index into the report's threats; absent for the report as a whole
+	Author    string    `json:"author"`
+	Role      string    `json:"role"`
+	Body      string    `json:"body"` // Markdown
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// commentRequest adds a comment to a report
+type commentRequest struct {
+	Threat *int   `json:"threat"`
+	Body   string `json:"body"`
+}
+
+// commentsPath is where a report's comments are kept
+func commentsPath(hash string) string {
+	return filepath.Join("reports", fmt.Sprintf("comments_%s.json", hash))
+}
+
+// loadComments reads a report's comments, oldest first
+func loadComments(hash string) ([]reportComment, error) {
+	data, err := readStored(commentsPath(hash))
+	if os.IsNotExist(err) {
+		return []reportComment{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var comments []reportComment
+	if err := json.Unmarshal(data, &comments); err != nil {
+		return nil, fmt.Errorf("failed to parse comments of report %s: %v", hash, err)
+	}
+	return comments, nil
+}
+
+// addComment appends a comment to a report's comments
+func addComment(hash string, comment reportComment) error {
+	commentsMutex.Lock()
+	defer commentsMutex.Unlock()
+
+	comments, err := loadComments(hash)
+	if err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(append(comments, comment), "", "  ")
+	return writeStored(commentsPath(hash), data)
+}
+
+// readReportForComments reads the report comments are about, writing an
+// error response if it can't be read
+func readReportForComments(w http.ResponseWriter, hash string) (*aegong.AuditReport, bool) {
+	reportPath, err := reportFile(hash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	data, err := readStored(reportPath)
+	if os.IsNotExist(err) {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read report: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	report, err := parseReport(data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to parse report: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	return report, true
+}
+
+// commentsHandler lists a report's comments, oldest first. With ?threat=N
+// only the thread on that finding is listed, and with ?threat=report only
+// the thread on the report as a whole.
+func commentsHandler(w http.ResponseWriter, r *http.Request) {
+	hash := mux.Vars(r)["hash"]
+	if _, ok := readReportForComments(w, hash); !ok {
+		return
+	}
+	comments, err := loadComments(hash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if filter := r.URL.Query().Get("threat"); filter != "" {
+		threat := -1
+		if filter != "report" {
+			var err error
+			if threat, err = strconv.Atoi(filter); err != nil || threat < 0 {
+				http.Error(w, fmt.Sprintf("threat must be a finding index or \"report\", got %q", filter), http.StatusBadRequest)
+				return
+			}
+		}
+		thread := []reportComment{}
+		for _, comment := range comments {
+			if comment.Threat == nil && threat < 0 || comment.Threat != nil && *comment.Threat == threat {
+				thread = append(thread, comment)
+			}
+		}
+		comments = thread
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comments)
+}
+
+// addCommentHandler lets admins and auditors comment on a report or one of
+// its findings
+func addCommentHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin, RoleAuditor)
+	if !ok {
+		return
+	}
+
+	var request commentRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil || strings.TrimSpace(request.Body) == "" {
+		http.Error(w, "Request body must be JSON with \"body\" and optionally \"threat\"", http.StatusBadRequest)
+		return
+	}
+	if len(request.Body) > maxCommentBody {
+		http.Error(w, fmt.Sprintf("body must be at most %d bytes", maxCommentBody), http.StatusBadRequest)
+		return
+	}
+
+	hash := mux.Vars(r)["hash"]
+	report, ok := readReportForComments(w, hash)
+	if !ok {
+		return
+	}
+	if request.Threat != nil && (*request.Threat < 0 || *request.Threat >= len(report.Threats)) {
+		http.Error(w, fmt.Sprintf("threat %d is not a finding of report %s", *request.Threat, hash), http.StatusBadRequest)
+		return
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to add comment: %v", err), http.StatusInternalServerError)
+		return
+	}
+	comment := reportComment{
+		ID:        hex.EncodeToString(id),
+		Threat:    request.Threat,
+		Author:    principal.Name,
+		Role:      string(principal.Role),
+		Body:      request.Body,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := addComment(hash, comment); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save comment: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logf(r.Context(), "Comment %s added to report %s by %s", comment.ID, hash, principal.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
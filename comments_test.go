@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// TestReportComments tests commenting on reports and findings and listing threads
+func TestReportComments(t *testing.T) {
+	withTestReports(t, &aegong.AuditReport{
+		AgentHash: "abcdef0123",
+		Threats: []aegong.ThreatDetection{
+			{Vector: aegong.T4_UNAUTHORIZED_ACTION, Evidence: []string{"Unauthorized action pattern: os.system"}},
+		},
+	})
+	oldTokens := apiTokens
+	t.Cleanup(func() { apiTokens = oldTokens })
+	apiTokens, _ = loadAPITokens("ci:auditor:audit,bob:viewer:view")
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/report/{hash}/comments", commentsHandler).Methods("GET")
+	router.HandleFunc("/api/report/{hash}/comments", addCommentHandler).Methods("POST")
+	request := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, r)
+		return rec
+	}
+	list := func(query string) []reportComment {
+		t.Helper()
+		rec := request("GET", "/api/report/abcdef01/comments"+query, "", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Should list comments, got %d: %s", rec.Code, rec.Body)
+		}
+		var comments []reportComment
+		if err := json.Unmarshal(rec.Body.Bytes(), &comments); err != nil {
+			t.Fatalf("Failed to parse comments: %v", err)
+		}
+		return comments
+	}
+
+	if comments := list(""); len(comments) != 0 {
+		t.Fatalf("A report should start without comments, got %+v", comments)
+	}
+	if rec := request("POST", "/api/report/abcdef01/comments", "view", `{"body":"hi"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("Viewers should not comment, got %d", rec.Code)
+	}
+	if rec := request("POST", "/api/report/abcdef01/comments", "audit", `{"body":"  "}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("An empty body should return 400, got %d", rec.Code)
+	}
+	if rec := request("POST", "/api/report/abcdef01/comments", "audit", `{"body":"hi","threat":1}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("An unknown finding should return 400, got %d", rec.Code)
+	}
+	if rec := request("POST", "/api/report/ffffffff/comments", "audit", `{"body":"hi"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("An unknown report should return 404, got %d", rec.Code)
+	}
+
+	rec := request("POST", "/api/report/abcdef01/comments", "audit", `{"body":"Is **os.system** expected here?","threat":0}`)
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"author":"ci"`) {
+		t.Fatalf("Auditors should comment on findings, got %d: %s", rec.Code, rec.Body)
+	}
+	request("POST", "/api/report/abcdef01/comments", "audit", `{"body":"Approved for staging"}`)
+	request("POST", "/api/report/abcdef01/comments", "audit", `{"body":"Yes, it runs the build","threat":0}`)
+
+	comments := list("")
+	if len(comments) != 3 || comments[0].Body != "Is **os.system** expected here?" || comments[2].Body != "Yes, it runs the build" {
+		t.Fatalf("Should list every comment oldest first, got %+v", comments)
+	}
+	if thread := list("?threat=0"); len(thread) != 2 || thread[1].Body != "Yes, it runs the build" {
+		t.Errorf("Should list the thread on the finding, got %+v", thread)
+	}
+	if thread := list("?threat=report"); len(thread) != 1 || thread[0].Threat != nil {
+		t.Errorf("Should list the thread on the report, got %+v", thread)
+	}
+	if rec := request("GET", "/api/report/abcdef01/comments?threat=x", "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("An invalid threat filter should return 400, got %d", rec.Code)
+	}
+}
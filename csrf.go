@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// The web UI reads the CSRF token from this cookie and echoes it in the header
+const (
+	csrfCookie = "aegong_csrf"
+	csrfHeader = "X-CSRF-Token"
+)
+
+// Origins allowed besides the server's own, loaded from AEGONG_ALLOWED_ORIGINS
+var allowedOrigins []string
+
+// parseOrigins parses a comma separated list of scheme://host[:port] origins
+func parseOrigins(spec string) ([]string, error) {
+	var origins []string
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		u, err := url.Parse(entry)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.Trim(u.Path, "/") != "" {
+			return nil, fmt.Errorf("invalid origin %q, expected scheme://host[:port]", entry)
+		}
+		origins = append(origins, strings.ToLower(u.Scheme+"://"+u.Host))
+	}
+	return origins, nil
+}
+
+// initAllowedOrigins loads the allowed origins from the environment
+func initAllowedOrigins() error {
+	origins, err := parseOrigins(os.Getenv("AEGONG_ALLOWED_ORIGINS"))
+	if err != nil {
+		return err
+	}
+	allowedOrigins = origins
+	return nil
+}
+
+// originAllowed reports whether a request's Origin, if it has one, is the
+// server itself or an allowed origin. Requests without an Origin do not come
+// from a browser page on another site.
+func originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	origin = strings.ToLower(u.Scheme + "://" + u.Host)
+	for _, allowed := range allowedOrigins {
+		if origin == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// setCSRFCookie gives the browser a CSRF token unless it already holds one
+func setCSRFCookie(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(csrfCookie); err == nil && len(cookie.Value) == 64 {
+		return
+	}
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		log.Printf("Warning: Failed to generate CSRF token: %v", err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    hex.EncodeToString(token),
+		Path:     "/",
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// browserRequest reports whether a request looks like it came from a browser
+func browserRequest(r *http.Request) bool {
+	return r.Header.Get("Origin") != "" || r.Header.Get("Sec-Fetch-Site") != "" || len(r.Cookies()) > 0
+}
+
+// validCSRFToken reports whether the CSRF header matches the cookie
+func validCSRFToken(r *http.Request) bool {
+	cookie, err := r.Cookie(csrfCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	token := r.Header.Get(csrfHeader)
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(token)) == 1
+}
+
+// protectCSRF rejects state-changing requests from other origins, and
+// browser requests without the CSRF token. Bearer token clients are exempt,
+// since a page on another site cannot set the Authorization header.
+func protectCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !originAllowed(r) {
+			log.Printf("Warning: Rejected %s %s from origin %s", r.Method, r.URL.Path, r.Header.Get("Origin"))
+			http.Error(w, "Cross-origin requests are not allowed", http.StatusForbidden)
+			return
+		}
+		if r.Header.Get("Authorization") == "" && browserRequest(r) && !validCSRFToken(r) {
+			log.Printf("Warning: Rejected %s %s without a valid CSRF token", r.Method, r.URL.Path)
+			http.Error(w, "Missing or invalid CSRF token", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestParseOrigins tests parsing of AEGONG_ALLOWED_ORIGINS
+func TestParseOrigins(t *testing.T) {
+	origins, err := parseOrigins("https://Dashboard.example.com, http://localhost:3000/")
+	if err != nil {
+		t.Fatalf("Failed to parse origins: %v", err)
+	}
+	if len(origins) != 2 || origins[0] != "https://dashboard.example.com" || origins[1] != "http://localhost:3000" {
+		t.Fatalf("Unexpected origins %v", origins)
+	}
+
+	for _, spec := range []string{"dashboard.example.com", "ftp://example.com", "https://example.com/app"} {
+		if _, err := parseOrigins(spec); err == nil {
+			t.Fatalf("Spec %q should be rejected", spec)
+		}
+	}
+}
+
+// TestProtectCSRF tests origin and CSRF token checks on state-changing requests
+func TestProtectCSRF(t *testing.T) {
+	oldOrigins := allowedOrigins
+	t.Cleanup(func() { allowedOrigins = oldOrigins })
+	allowedOrigins, _ = parseOrigins("https://dashboard.example.com")
+
+	handler := protectCSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	token := strings.Repeat("ab", 32)
+	request := func(method, origin, cookie, header, auth string) int {
+		r := httptest.NewRequest(method, "http://aegong.local/api/upload", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: csrfCookie, Value: cookie})
+		}
+		if header != "" {
+			r.Header.Set(csrfHeader, header)
+		}
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	for _, c := range []struct {
+		name                                 string
+		method, origin, cookie, header, auth string
+		status                               int
+	}{
+		{"same-origin page with token", "POST", "http://aegong.local", token, token, "", http.StatusOK},
+		{"allowed origin with token", "POST", "https://dashboard.example.com", token, token, "", http.StatusOK},
+		{"same-origin page without token", "POST", "http://aegong.local", token, "", "", http.StatusForbidden},
+		{"mismatched token", "POST", "http://aegong.local", token, strings.Repeat("cd", 32), "", http.StatusForbidden},
+		{"other origin", "POST", "https://evil.example.com", token, token, "", http.StatusForbidden},
+		{"other origin with bearer token", "POST", "https://evil.example.com", "", "", "Bearer x", http.StatusForbidden},
+		{"API client", "POST", "", "", "", "", http.StatusOK},
+		{"bearer token client", "DELETE", "http://aegong.local", "", "", "Bearer x", http.StatusOK},
+		{"safe method", "GET", "https://evil.example.com", "", "", "", http.StatusOK},
+	} {
+		if status := request(c.method, c.origin, c.cookie, c.header, c.auth); status != c.status {
+			t.Fatalf("%s should get %d, got %d", c.name, c.status, status)
+		}
+	}
+}
+
+// TestSetCSRFCookie tests that the page hands out a token once
+func TestSetCSRFCookie(t *testing.T) {
+	w := httptest.NewRecorder()
+	homeHandler(w, httptest.NewRequest("GET", "/", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != csrfCookie || len(cookies[0].Value) != 64 {
+		t.Fatalf("Should set a CSRF cookie, got %v", cookies)
+	}
+	if cookies[0].SameSite != http.SameSiteStrictMode {
+		t.Fatal("CSRF cookie should be SameSite=Strict")
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	homeHandler(w, r)
+	if len(w.Result().Cookies()) != 0 {
+		t.Fatal("Should keep the existing CSRF cookie")
+	}
+}
+
+// TestWebSocketOrigin tests that WebSocket upgrades from other origins are refused
+func TestWebSocketOrigin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(websocketHandler))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	header := http.Header{"Origin": {"https://evil.example.com"}}
+	if _, resp, err := websocket.DefaultDialer.Dial(url, header); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Cross-origin upgrade should be refused, got %v", err)
+	}
+
+	header = http.Header{"Origin": {server.URL}}
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("Same-origin upgrade should succeed: %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package main
+
+// filesystemSpace is not implemented here, so the status omits free space
+func filesystemSpace(path string) (uint64, uint64, bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Voice reports and archives can run to hundreds of megabytes, so they are
+// served with http.ServeContent: clients get Range requests, and If-Range
+// against a strong ETag lets an interrupted download resume only when the
+// file hasn't changed since. Proxy buffering is turned off so large files
+// stream through rather than being spooled by the proxy first.
+
+// download is a file served for resumable download
+type download struct {
+	name       string // File name offered to the client
+	modTime    time.Time
+	etag       string // Strong ETag, quoted
+	attachment bool   // Saved rather than shown inline
+}
+
+// serve writes content, honouring Range, If-Range and conditional requests
+func (d download) serve(w http.ResponseWriter, r *http.Request, content io.ReadSeeker) {
+	disposition := "inline"
+	if d.attachment {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": d.name}))
+	w.Header().Set("ETag", d.etag)
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("X-Accel-Buffering", "no")
+	http.ServeContent(w, r, d.name, d.modTime, content)
+}
+
+// serveFile serves the regular file at path, which must already be confined
+// to the directory it is served from. ?download=1 saves it as an attachment.
+func serveFile(w http.ResponseWriter, r *http.Request, path string) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to open file: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+
+	download{
+		name:       info.Name(),
+		modTime:    info.ModTime(),
+		etag:       fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()),
+		attachment: r.URL.Query().Get("download") == "1",
+	}.serve(w, r, file)
+}
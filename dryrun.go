@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// Largest dry run request, sample payload included
+const maxDryRunBytes = 10 << 20
+
+// dryRunRequest tries a vector definition on a sample payload, or on the
+// agent of a saved report
+type dryRunRequest struct {
+	Definition aegong.VectorDefinition `json:"definition"`
+	Payload    string                  `json:"payload,omitempty"`
+	ReportHash string                  `json:"report_hash,omitempty"`
+}
+
+// testDetectorHandler shows what a custom vector's patterns would match
+// without running an audit, so rule authors can iterate on them
+func testDetectorHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireRole(w, r, RoleAdmin, RoleAuditor); !ok {
+		return
+	}
+
+	var request dryRunRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDryRunBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil || (request.Payload == "") == (request.ReportHash == "") {
+		http.Error(w, "Request body must be JSON with a \"definition\" and either a \"payload\" or a \"report_hash\"", http.StatusBadRequest)
+		return
+	}
+
+	payload, source := []byte(request.Payload), "payload"
+	if request.ReportHash != "" {
+		var status int
+		var err error
+		if payload, source, status, err = reportPayload(request.ReportHash); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+	}
+
+	run, err := aegong.DryRunVector(r.Context(), request.Definition, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"source":   source,
+		"vector":   run.Vector,
+		"patterns": run.Patterns,
+		"finding":  run.Finding,
+	})
+}
+
+// reportPayload returns the agent a saved report audited if its upload is
+// still kept, and the report's evidence otherwise
+func reportPayload(hash string) ([]byte, string, int, error) {
+	report, status, err := savedReport(hash)
+	if err != nil {
+		return nil, "", status, err
+	}
+
+	if agent := uploadByHash(report.AgentHash); agent != nil {
+		return agent, "upload", 0, nil
+	}
+	var evidence []string
+	for _, threat := range report.Threats {
+		evidence = append(evidence, threat.Evidence...)
+	}
+	return []byte(strings.Join(evidence, "\n")), "evidence", 0, nil
+}
+
+// savedReport reads the report saved under a short hash, with the status to
+// answer if it cannot be read
+func savedReport(hash string) (*aegong.AuditReport, int, error) {
+	reportPath, err := reportFile(hash)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	data, err := readStored(reportPath)
+	if os.IsNotExist(err) {
+		return nil, http.StatusNotFound, errors.New("Report not found")
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to read report: %v", err)
+	}
+	report, err := parseReport(data)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to parse report: %v", err)
+	}
+	return report, 0, nil
+}
+
+// uploadByHash returns the upload whose SHA-256 is agentHash, or nil if it
+// has been purged
+func uploadByHash(agentHash string) []byte {
+	_, data := findUpload(agentHash)
+	return data
+}
+
+// findUpload returns the name and content of the upload whose SHA-256 is
+// agentHash, or an empty name if it has been purged
+func findUpload(agentHash string) (string, []byte) {
+	entries, _ := os.ReadDir("uploads")
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasSuffix(entry.Name(), signatureBundleSuffix) || strings.HasSuffix(entry.Name(), agentManifestSuffix) {
+			continue
+		}
+		data, err := readStored(filepath.Join("uploads", entry.Name()))
+		if err != nil {
+			continue
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) == agentHash {
+			return entry.Name(), data
+		}
+	}
+	return "", nil
+}
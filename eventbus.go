@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// Event types published on the bus
+const (
+	eventUploadReceived = "upload_received"
+	eventAuditStarted   = "audit_started"
+	eventAuditPhase     = "audit_phase"
+	eventThreatFound    = "threat_found"
+	eventAuditCompleted = "audit_completed"
+	eventAuditFailed    = "audit_failed"
+	eventAuditCancelled = "audit_cancelled"
+	eventVoiceReady     = "voice_ready"
+	eventStatusWarning  = "status_warning"
+)
+
+// busEvent is something that happened in the auditor. Only the fields of its
+// type are set.
+type busEvent struct {
+	Type          string
+	Time          time.Time
+	AuditID       string // Audit or job the event belongs to
+	CorrelationID string // Request that started the audit
+	Filename      string // Upload the event concerns
+	Workspace     string // Tenant the audit was started by
+
+	Phase  string                  // audit_phase
+	Threat *aegong.ThreatDetection // threat_found
+	Report *aegong.AuditReport     // audit_completed
+	Err    error                   // audit_failed
+
+	ReportHash string // voice_ready
+	AudioURL   string // voice_ready
+
+	Warning string // status_warning
+}
+
+type busSubscription struct {
+	types   map[string]bool // Empty for every type
+	handler func(busEvent)
+}
+
+// eventBus connects the code that produces events (HTTP handlers, audits,
+// voice generation and the status monitor) with the sinks that deliver them (the WebSocket and
+// server-sent event streams). Handlers run on the publisher's goroutine in
+// the order events are published, so they must not block; slow sinks queue
+// events themselves.
+type eventBus struct {
+	mutex         sync.RWMutex
+	subscriptions map[*busSubscription]bool
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscriptions: make(map[*busSubscription]bool)}
+}
+
+var bus = newEventBus()
+
+// subscribe calls handler for every event of the given types, or of every
+// type if none are given, until the returned function is called
+func (b *eventBus) subscribe(handler func(busEvent), types ...string) func() {
+	sub := &busSubscription{types: make(map[string]bool), handler: handler}
+	for _, eventType := range types {
+		sub.types[eventType] = true
+	}
+
+	b.mutex.Lock()
+	b.subscriptions[sub] = true
+	b.mutex.Unlock()
+
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(b.subscriptions, sub)
+	}
+}
+
+// publish delivers an event to its subscribers
+func (b *eventBus) publish(event busEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mutex.RLock()
+	var handlers []func(busEvent)
+	for sub := range b.subscriptions {
+		if len(sub.types) == 0 || sub.types[event.Type] {
+			handlers = append(handlers, sub.handler)
+		}
+	}
+	b.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// auditStarted announces that an audit has begun and returns a context that
+// publishes the audit's phases and findings as it runs
+func (b *eventBus) auditStarted(ctx context.Context, auditID, filename string) context.Context {
+	correlationID, workspace := aegong.CorrelationID(ctx), contextTenant(ctx)
+	b.publish(busEvent{Type: eventAuditStarted, AuditID: auditID, CorrelationID: correlationID, Filename: filename, Workspace: workspace})
+	return aegong.WithObserver(ctx, &aegong.AuditObserver{
+		PhaseStarted: func(phase string) {
+			b.publish(busEvent{Type: eventAuditPhase, AuditID: auditID, CorrelationID: correlationID, Filename: filename, Workspace: workspace, Phase: phase})
+		},
+		ThreatFound: func(threat aegong.ThreatDetection) {
+			b.publish(busEvent{Type: eventThreatFound, AuditID: auditID, CorrelationID: correlationID, Filename: filename, Workspace: workspace, Threat: &threat})
+		},
+	})
+}
+
+// auditFinished announces how an audit ended
+func (b *eventBus) auditFinished(ctx context.Context, auditID, filename string, report *aegong.AuditReport, err error) {
+	event := busEvent{AuditID: auditID, CorrelationID: aegong.CorrelationID(ctx), Filename: filename, Workspace: contextTenant(ctx)}
+	switch {
+	case ctx.Err() != nil:
+		event.Type = eventAuditCancelled
+	case err != nil:
+		event.Type = eventAuditFailed
+		event.Err = err
+	default:
+		event.Type = eventAuditCompleted
+		event.Report = report
+	}
+	b.publish(event)
+}
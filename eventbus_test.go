@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// TestEventBus tests type filtering, unsubscribing and audit outcome events
+func TestEventBus(t *testing.T) {
+	b := newEventBus()
+
+	var all, uploads []string
+	b.subscribe(func(event busEvent) { all = append(all, event.Type) })
+	unsubscribe := b.subscribe(func(event busEvent) { uploads = append(uploads, event.Filename) }, eventUploadReceived)
+
+	b.publish(busEvent{Type: eventUploadReceived, Filename: "agent.py"})
+	ctx := b.auditStarted(context.Background(), "a1", "agent.py")
+	b.auditFinished(ctx, "a1", "agent.py", nil, &notAgentError{})
+	b.auditFinished(ctx, "a2", "agent.py", &aegong.AuditReport{}, nil)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	b.auditFinished(cancelled, "a3", "agent.py", nil, errors.New("audit cancelled"))
+
+	unsubscribe()
+	b.publish(busEvent{Type: eventUploadReceived, Filename: "other.py"})
+
+	want := []string{eventUploadReceived, eventAuditStarted, eventAuditFailed, eventAuditCompleted, eventAuditCancelled, eventUploadReceived}
+	if len(all) != len(want) {
+		t.Fatalf("Should deliver every event, got %v", all)
+	}
+	for i := range want {
+		if all[i] != want[i] {
+			t.Fatalf("Should deliver events in order, got %v", all)
+		}
+	}
+	if len(uploads) != 1 || uploads[0] != "agent.py" {
+		t.Fatalf("Filtered subscriber should only see uploads until it unsubscribes, got %v", uploads)
+	}
+}
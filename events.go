@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Server-sent events
+//
+// GET /api/events streams the same events as the WebSocket hub for
+// clients behind proxies that break WebSocket upgrades. Each event is sent as
+//
+//	event: audit_completed
+//	data: {"type": "audit_completed", "data": {...}, "message": "..."}
+//
+// The stream follows the "audits" topic unless one or more ?topic= parameters
+// select specific audits ("audit:<id>").
+
+const (
+	sseKeepAlive  = 15 * time.Second
+	sseBufferSize = 32 // Events queued per client before new ones are dropped
+)
+
+// Closed on server shutdown so open streams don't hold up the graceful
+// shutdown of in-flight audits
+var (
+	sseShutdown     = make(chan struct{})
+	sseShutdownOnce sync.Once
+)
+
+func closeEventStreams() {
+	sseShutdownOnce.Do(func() { close(sseShutdown) })
+}
+
+// sseClient is a single event stream
+type sseClient struct {
+	topics map[string]bool
+	events chan WebSocketMessage
+}
+
+func (c *sseClient) subscribed(topics ...string) bool {
+	for _, topic := range topics {
+		if c.topics[topic] {
+			return true
+		}
+	}
+	return false
+}
+
+// send queues an event without blocking the publisher; slow clients miss
+// events rather than holding up audits
+func (c *sseClient) send(msg WebSocketMessage) error {
+	select {
+	case c.events <- msg:
+		return nil
+	default:
+		return fmt.Errorf("event stream is full")
+	}
+}
+
+func sseHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	topics := r.URL.Query()["topic"]
+	if len(topics) == 0 {
+		topics = []string{wsTopicAudits}
+	}
+	client := &sseClient{
+		topics: make(map[string]bool),
+		events: make(chan WebSocketMessage, sseBufferSize),
+	}
+	for _, topic := range topics {
+		if !validTopic(topic) {
+			http.Error(w, fmt.Sprintf("Invalid topic %q", topic), http.StatusBadRequest)
+			return
+		}
+		client.topics[topic] = true
+	}
+
+	hub.addClient(client)
+	defer hub.removeClient(client)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stop nginx and similar proxies from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sseShutdown:
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case msg := <-client.events:
+			data, err := json.Marshal(msg)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, data)
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// TestEventStream tests that job lifecycle events are streamed as server-sent events
+func TestEventStream(t *testing.T) {
+	withTestUpload(t)
+
+	oldBus, oldHub, oldJobs := bus, hub, jobs
+	t.Cleanup(func() { bus, hub, jobs = oldBus, oldHub, oldJobs })
+	bus = newEventBus()
+	hub = newWSHub(bus)
+	jobs = newJobStore(context.Background())
+	jobs.audit = func(ctx context.Context, filename string, opts auditOptions) (*aegong.AuditReport, error) {
+		return &aegong.AuditReport{AgentHash: "abc123", AegongMessage: "done"}, nil
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(sseHandler))
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL + "?topic=nope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Should reject invalid topics, got %d", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Should stream text/event-stream, got %q", ct)
+	}
+
+	// Wait for the stream to register with the hub before starting the job
+	reader := bufio.NewReader(resp.Body)
+	if line, _ := reader.ReadString('\n'); !strings.HasPrefix(line, ":") {
+		t.Fatalf("Stream should open with a comment, got %q", line)
+	}
+
+	job, err := jobs.submit("agent.py", "", auditOptions{})
+	if err != nil {
+		t.Fatalf("Failed to submit job: %v", err)
+	}
+
+	var events []string
+	var event string
+	for len(events) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Stream ended after %v: %v", events, err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			var msg WebSocketMessage
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &msg); err != nil {
+				t.Fatalf("Event data should be JSON: %v", err)
+			}
+			data, _ := msg.Data.(map[string]interface{})
+			if msg.Type != event || data["audit_id"] != job.ID {
+				t.Fatalf("Event should describe job %s, got %s %+v", job.ID, event, msg)
+			}
+			events = append(events, event)
+		}
+	}
+	if events[0] != wsTypeAuditStarted || events[1] != wsTypeAuditCompleted {
+		t.Fatalf("Should stream audit_started then audit_completed, got %v", events)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Defaults for AEGONG_MAX_CONCURRENT_AUDITS and AEGONG_AUDIT_QUEUE_DEPTH
+const (
+	defaultMaxConcurrentAudits = 2
+	defaultAuditQueueDepth     = 16
+)
+
+// errAuditQueueFull is returned when every sandbox slot is busy and the queue is full
+var errAuditQueueFull = errors.New("audit queue is full, try again later")
+
+// auditExecutor limits how many audits run at once and queues the rest in
+// arrival order, so concurrent sandboxes cannot exhaust /tmp and memory
+type auditExecutor struct {
+	mutex   sync.Mutex
+	limit   int // Audits allowed to run at once
+	depth   int // Audits allowed to wait for a slot
+	running int
+	waiting []*auditTicket
+}
+
+// auditTicket is an audit's place in the executor
+type auditTicket struct {
+	executor *auditExecutor
+	granted  chan struct{} // Closed once the audit may run
+	state    int
+}
+
+const (
+	ticketWaiting = iota
+	ticketRunning
+	ticketReleased
+)
+
+func newAuditExecutor(limit, depth int) *auditExecutor {
+	return &auditExecutor{limit: limit, depth: depth}
+}
+
+// Executor shared by every audit the server runs
+var auditSlots = newAuditExecutor(defaultMaxConcurrentAudits, defaultAuditQueueDepth)
+
+// initAuditExecutor configures the executor from the environment
+func initAuditExecutor() error {
+	limit, err := envInt("AEGONG_MAX_CONCURRENT_AUDITS", defaultMaxConcurrentAudits, 1)
+	if err != nil {
+		return err
+	}
+	depth, err := envInt("AEGONG_AUDIT_QUEUE_DEPTH", defaultAuditQueueDepth, 0)
+	if err != nil {
+		return err
+	}
+	auditSlots = newAuditExecutor(limit, depth)
+	return nil
+}
+
+// envInt reads an integer environment variable that must be at least min
+func envInt(name string, fallback, min int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min {
+		return 0, fmt.Errorf("%s must be an integer of at least %d, got %q", name, min, value)
+	}
+	return n, nil
+}
+
+// enqueue takes a slot if one is free, otherwise a place in the queue
+func (x *auditExecutor) enqueue() (*auditTicket, error) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	ticket := &auditTicket{executor: x, granted: make(chan struct{})}
+	switch {
+	case x.running < x.limit && len(x.waiting) == 0:
+		x.running++
+		ticket.state = ticketRunning
+		close(ticket.granted)
+	case len(x.waiting) < x.depth:
+		x.waiting = append(x.waiting, ticket)
+	default:
+		return nil, errAuditQueueFull
+	}
+	return ticket, nil
+}
+
+// stats returns the number of running and queued audits
+func (x *auditExecutor) stats() (running, queued int) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	return x.running, len(x.waiting)
+}
+
+// wait blocks until the audit may run; a cancelled wait gives up the ticket
+func (t *auditTicket) wait(ctx context.Context) error {
+	select {
+	case <-t.granted:
+		return nil
+	case <-ctx.Done():
+		t.release()
+		return ctx.Err()
+	}
+}
+
+// release frees the ticket's slot or queue place; calling it again is a no-op
+func (t *auditTicket) release() {
+	x := t.executor
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	switch t.state {
+	case ticketWaiting:
+		for i, waiting := range x.waiting {
+			if waiting == t {
+				x.waiting = append(x.waiting[:i], x.waiting[i+1:]...)
+				break
+			}
+		}
+	case ticketRunning:
+		x.running--
+		// Hand the slot to the longest waiting audit
+		if len(x.waiting) > 0 && x.running < x.limit {
+			next := x.waiting[0]
+			x.waiting = x.waiting[1:]
+			x.running++
+			next.state = ticketRunning
+			close(next.granted)
+		}
+	}
+	t.state = ticketReleased
+}
+
+// position returns the ticket's 1-based place in the queue, or 0 once it is running
+func (t *auditTicket) position() int {
+	x := t.executor
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	for i, waiting := range x.waiting {
+		if waiting == t {
+			return i + 1
+		}
+	}
+	return 0
+}
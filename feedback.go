@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// Longest reason kept with a false positive mark
+const maxFeedbackReason = 500
+
+// falsePositiveRequest marks one finding of a report as a false positive
+type falsePositiveRequest struct {
+	Threat *int   `json:"threat"` // Index into the report's threats
+	Reason string `json:"reason"`
+}
+
+// feedbackHandler lets auditors mark a finding of a report as a false positive
+func feedbackHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin, RoleAuditor)
+	if !ok {
+		return
+	}
+
+	var request falsePositiveRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil || request.Threat == nil {
+		http.Error(w, "Request body must be JSON with \"threat\" and optionally \"reason\"", http.StatusBadRequest)
+		return
+	}
+	request.Reason = strings.TrimSpace(request.Reason)
+	if len(request.Reason) > maxFeedbackReason {
+		http.Error(w, fmt.Sprintf("reason must be at most %d bytes", maxFeedbackReason), http.StatusBadRequest)
+		return
+	}
+
+	hash := mux.Vars(r)["hash"]
+	reportPath, err := reportFile(hash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := readStored(reportPath)
+	if os.IsNotExist(err) {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read report: %v", err), http.StatusInternalServerError)
+		return
+	}
+	report, err := parseReport(data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to parse report: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	fp, err := engine.MarkFalsePositive(report, *request.Threat, principal.Name, string(principal.Role), request.Reason, aegong.CorrelationID(r.Context()))
+	if err == aegong.ErrAlreadyMarked {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logf(r.Context(), "Threat %d (%s) of report %s marked as a false positive by %s", fp.Threat, fp.Vector, hash, principal.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(fp)
+}
+
+// feedbackSummaryHandler lists false positive marks per vector and pattern
+func feedbackSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireRole(w, r, RoleAdmin, RoleAuditor); !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(engine.Feedback())
+}
+
+// initFeedback reads where false positive marks are kept and whether they
+// lower the risk of noisy patterns
+func initFeedback(config *aegong.Config) {
+	if path := os.Getenv("AEGONG_FEEDBACK_FILE"); path != "" {
+		config.FeedbackPath = path
+	}
+	config.FeedbackDownweight = os.Getenv("AEGONG_FEEDBACK_DOWNWEIGHT") == "1"
+	if config.FeedbackDownweight {
+		log.Printf("Info: Findings with patterns often marked as false positives are downweighted")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// TestFeedbackAPI tests marking findings as false positives and the admin summary
+func TestFeedbackAPI(t *testing.T) {
+	withTestReports(t, &aegong.AuditReport{
+		AgentHash: "abcdef0123",
+		Threats: []aegong.ThreatDetection{
+			{Vector: aegong.T4_UNAUTHORIZED_ACTION, Evidence: []string{"Unauthorized action pattern: os.system"}},
+		},
+	})
+	oldTokens := apiTokens
+	t.Cleanup(func() { apiTokens = oldTokens })
+	apiTokens, _ = loadAPITokens("ci:auditor:audit,bob:viewer:view")
+	engine, _ = aegong.NewEngine(aegong.Config{FeedbackPath: filepath.Join(t.TempDir(), "feedback.json")})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/report/{hash}/feedback", feedbackHandler).Methods("POST")
+	router.HandleFunc("/api/admin/feedback", feedbackSummaryHandler).Methods("GET")
+	request := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := request("POST", "/api/report/abcdef01/feedback", "view", `{"threat":0}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("Viewers should not mark findings, got %d", rec.Code)
+	}
+	if rec := request("POST", "/api/report/abcdef01/feedback", "audit", `{}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("A missing threat should return 400, got %d", rec.Code)
+	}
+	if rec := request("POST", "/api/report/abcdef01/feedback", "audit", `{"threat":3}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("An unknown threat should return 400, got %d", rec.Code)
+	}
+	if rec := request("POST", "/api/report/ffffffff/feedback", "audit", `{"threat":0}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("An unknown report should return 404, got %d", rec.Code)
+	}
+
+	rec := request("POST", "/api/report/abcdef01/feedback", "audit", `{"threat":0,"reason":"expected shell use"}`)
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"vector":"T4"`) {
+		t.Fatalf("Auditors should mark findings, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := request("POST", "/api/report/abcdef01/feedback", "audit", `{"threat":0}`); rec.Code != http.StatusConflict {
+		t.Fatalf("Marking a finding twice should return 409, got %d", rec.Code)
+	}
+
+	rec = request("GET", "/api/admin/feedback", "audit", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"pattern":"Unauthorized action pattern: os.system","seen":1,"false_positives":1`) {
+		t.Fatalf("Summary should count the mark, got %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// uploadTypePolicy accepts or denies agents by what their content is,
+// whatever their name says. Entries are type names such as docx or groups
+// such as document; a type name decides before its group does.
+type uploadTypePolicy struct {
+	accept map[string]bool // Empty accepts every type not denied
+	deny   map[string]bool
+}
+
+// Types denied when AEGONG_UPLOAD_DENY is unset; neither can hold an agent
+var defaultDeniedTypes = map[string]bool{aegong.FileGroupDocument: true, aegong.FileGroupImage: true}
+
+// File type policy from AEGONG_UPLOAD_ACCEPT and AEGONG_UPLOAD_DENY
+var uploadTypes = uploadTypePolicy{deny: defaultDeniedTypes}
+
+// parseFileTypes parses a comma separated list of file types and groups
+func parseFileTypes(spec string) (map[string]bool, error) {
+	types := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if !aegong.IsFileType(entry) {
+			return nil, fmt.Errorf("unknown file type %q", entry)
+		}
+		types[entry] = true
+	}
+	return types, nil
+}
+
+// initUploadTypes loads the file type policy from the environment
+func initUploadTypes() error {
+	accept, err := parseFileTypes(os.Getenv("AEGONG_UPLOAD_ACCEPT"))
+	if err != nil {
+		return fmt.Errorf("AEGONG_UPLOAD_ACCEPT: %v", err)
+	}
+	// Set but empty denies nothing
+	deny := defaultDeniedTypes
+	if spec, ok := os.LookupEnv("AEGONG_UPLOAD_DENY"); ok {
+		if deny, err = parseFileTypes(spec); err != nil {
+			return fmt.Errorf("AEGONG_UPLOAD_DENY: %v", err)
+		}
+	}
+	uploadTypes = uploadTypePolicy{accept: accept, deny: deny}
+	return nil
+}
+
+// allows reports whether content of a file type may be uploaded
+func (p uploadTypePolicy) allows(fileType aegong.FileType) bool {
+	switch {
+	case p.deny[fileType.Name]:
+		return false
+	case p.accept[fileType.Name]:
+		return true
+	case p.deny[fileType.Group]:
+		return false
+	}
+	return len(p.accept) == 0 || p.accept[fileType.Group]
+}
+
+// unsupportedFileType is the answer to an agent of a file type the policy rejects
+type unsupportedFileType struct {
+	Error    string          `json:"error"`
+	Message  string          `json:"message"`
+	FileType aegong.FileType `json:"file_type"`
+	Accepted []string        `json:"accepted,omitempty"` // Empty when every type not denied is accepted
+	Denied   []string        `json:"denied,omitempty"`
+}
+
+// checkFileType identifies an agent by its content and writes a 415 if the
+// policy rejects its file type
+func checkFileType(w http.ResponseWriter, r *http.Request, name string, data []byte) bool {
+	fileType := aegong.SniffFileType(data)
+	if uploadTypes.allows(fileType) {
+		return true
+	}
+	logf(r.Context(), "Rejected %s: %s files are not accepted", name, fileType)
+
+	response := unsupportedFileType{
+		Error:    "Unsupported file type",
+		Message:  fmt.Sprintf("%s is a %s file (%s), which this server does not accept as an agent.", name, fileType.Name, fileType.Group),
+		FileType: fileType,
+		Accepted: sortedTypes(uploadTypes.accept),
+		Denied:   sortedTypes(uploadTypes.deny),
+	}
+	if len(response.Accepted) > 0 {
+		response.Message += " Accepted types: " + strings.Join(response.Accepted, ", ") + "."
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnsupportedMediaType)
+	json.NewEncoder(w).Encode(response)
+	return false
+}
+
+func sortedTypes(types map[string]bool) []string {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// uploadRequest builds a multipart upload of an agent
+func uploadRequest(t *testing.T, name string, data []byte) *http.Request {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("agent", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	form.Close()
+
+	r := httptest.NewRequest("POST", "/api/upload", &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	return r
+}
+
+// testDocx builds the skeleton of a Word document
+func testDocx(t *testing.T) []byte {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, name := range []string{"[Content_Types].xml", "word/document.xml"} {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte("<xml/>"))
+	}
+	archive.Close()
+	return buf.Bytes()
+}
+
+// TestUploadFileTypes tests that uploads are accepted or rejected by their content
+func TestUploadFileTypes(t *testing.T) {
+	withTestUpload(t)
+	old := uploadTypes
+	t.Cleanup(func() { uploadTypes = old })
+	uploadTypes = uploadTypePolicy{deny: defaultDeniedTypes}
+
+	elf := []byte{0x7F, 'E', 'L', 'F', 2, 1, 1, 0}
+	for _, c := range []struct {
+		name   string
+		data   []byte
+		status int
+		group  string
+	}{
+		{"agent.py", []byte("import os\nprint(os.getcwd())\n"), http.StatusOK, ""},
+		{"report.docx", testDocx(t), http.StatusUnsupportedMediaType, "document"},
+		{"agent.exe", testDocx(t), http.StatusUnsupportedMediaType, "document"},
+		{"notes.docx", elf, http.StatusOK, ""},
+		{"agent.bin", []byte("%PDF-1.7\n"), http.StatusUnsupportedMediaType, "document"},
+		{"logo.png", []byte("\x89PNG\r\n\x1a\n\x00\x00"), http.StatusUnsupportedMediaType, "image"},
+	} {
+		w := httptest.NewRecorder()
+		uploadHandler(w, uploadRequest(t, c.name, c.data))
+		if w.Code != c.status {
+			t.Errorf("%s: Should answer %d, got %d: %s", c.name, c.status, w.Code, w.Body)
+			continue
+		}
+		if c.status == http.StatusOK {
+			continue
+		}
+		var response unsupportedFileType
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("%s: Should explain the rejection as JSON: %v", c.name, err)
+		}
+		if response.FileType.Group != c.group || len(response.Denied) != 2 {
+			t.Errorf("%s: Should name the detected type and the denied types, got %+v", c.name, response)
+		}
+	}
+
+	// Rejected uploads are never saved
+	entries, _ := os.ReadDir("uploads")
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".png" || filepath.Ext(entry.Name()) == ".bin" {
+			t.Errorf("Should not save rejected uploads, found %s", entry.Name())
+		}
+	}
+}
+
+// TestUploadTypePolicy tests accept and deny lists of types and groups
+func TestUploadTypePolicy(t *testing.T) {
+	t.Setenv("AEGONG_UPLOAD_ACCEPT", "executable, script, archive")
+	t.Setenv("AEGONG_UPLOAD_DENY", "zip")
+	old := uploadTypes
+	t.Cleanup(func() { uploadTypes = old })
+	if err := initUploadTypes(); err != nil {
+		t.Fatalf("Failed to load the policy: %v", err)
+	}
+
+	for _, c := range []struct {
+		name, group string
+		allowed     bool
+	}{
+		{"elf", "executable", true},
+		{"python", "script", true},
+		{"jar", "archive", true},
+		{"zip", "archive", false},
+		{"text", "text", false},
+		{"docx", "document", false},
+	} {
+		if got := uploadTypes.allows(aegong.FileType{Name: c.name, Group: c.group}); got != c.allowed {
+			t.Errorf("%s/%s: Should be allowed=%v, got %v", c.group, c.name, c.allowed, got)
+		}
+	}
+
+	t.Setenv("AEGONG_UPLOAD_DENY", "")
+	t.Setenv("AEGONG_UPLOAD_ACCEPT", "")
+	if err := initUploadTypes(); err != nil || !uploadTypes.allows(aegong.FileType{Name: "docx", Group: "document"}) {
+		t.Error("An empty deny list should accept every type")
+	}
+
+	t.Setenv("AEGONG_UPLOAD_DENY", "spreadsheet")
+	if err := initUploadTypes(); err == nil {
+		t.Error("Should reject unknown file types")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// FuzzParseReport fuzzes the report loader. Report files can be planted or
+// edited on disk, so everything that reads a loaded report is exercised too.
+// Seeds live in testdata/fuzz/FuzzParseReport.
+func FuzzParseReport(f *testing.F) {
+	f.Add([]byte(`{"agent_hash":"0123456789abcdef","agent_name":"agent.py","overall_risk":0.4,"risk_level":"MEDIUM","threats":[{"vector":0,"severity":2,"confidence":0.8,"evidence":["eval("]}]}`))
+	f.Add([]byte(`{"agent_hash":"abc"}`))
+	f.Add([]byte(`{"agent_hash":"0123456789abcdef","threats":[{"vector":-1}],"narration":"executive"}`))
+	f.Add([]byte(`null`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		report, err := parseReport(data)
+		if err != nil {
+			return
+		}
+		if len(report.AgentHash) < 8 {
+			t.Fatalf("Should reject reports without a full agent hash, got %q", report.AgentHash)
+		}
+
+		for _, narration := range []string{NarrationAegong, NarrationProfessional, NarrationExecutive} {
+			narrated := *report
+			narrateReport(&narrated, narration)
+		}
+		voiceScript(report)
+		if _, err := json.Marshal(report); err != nil {
+			t.Fatalf("Should save any loaded report again, got %v", err)
+		}
+	})
+}
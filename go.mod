@@ -7,6 +7,7 @@ toolchain go1.24.3
 require (
 	github.com/gorilla/mux v1.8.1
 	github.com/gorilla/websocket v1.5.1
+	github.com/tetratelabs/wazero v1.9.0
 )
 
 require (
@@ -0,0 +1,901 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A minimal GraphQL query engine for the report API.
+//
+// It parses query documents with variables, aliases, arguments, nested
+// selections and fragments, and executes them against objects implementing
+// gqlObject. Mutations, subscriptions, directives and introspection beyond
+// __typename are not supported.
+
+// Deepest selection nesting a query may use
+const gqlMaxDepth = 12
+
+// gqlObject is a value of a GraphQL object type
+type gqlObject interface {
+	typeName() string
+	// resolve returns a field's value: a scalar, a gqlObject, a slice of
+	// either, or nil
+	resolve(field string, args gqlArgs) (interface{}, error)
+}
+
+// gqlArgs are a field's arguments with variables substituted
+type gqlArgs map[string]interface{}
+
+// gqlError is an error in a GraphQL response
+type gqlError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// gqlResponse is the result of executing a query
+type gqlResponse struct {
+	Data   interface{} `json:"data"`
+	Errors []gqlError  `json:"errors,omitempty"`
+}
+
+// gqlSelection is a field, fragment spread or inline fragment in a query
+type gqlSelection struct {
+	alias         string
+	name          string
+	args          map[string]interface{} // Values may be gqlVariable
+	selections    []*gqlSelection
+	fragment      string // Name of a spread fragment
+	typeCondition string // Type an inline fragment or fragment applies to
+	inline        bool
+}
+
+func (s *gqlSelection) responseKey() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+// gqlVariable is a reference to a query variable in an argument value
+type gqlVariable string
+
+type gqlVariableDefinition struct {
+	name         string
+	required     bool
+	defaultValue interface{}
+	hasDefault   bool
+}
+
+type gqlOperation struct {
+	kind       string
+	name       string
+	variables  []gqlVariableDefinition
+	selections []*gqlSelection
+}
+
+type gqlFragment struct {
+	typeCondition string
+	selections    []*gqlSelection
+}
+
+// gqlDocument is a parsed query document
+type gqlDocument struct {
+	operations []*gqlOperation
+	fragments  map[string]*gqlFragment
+}
+
+// Token kinds
+const (
+	gqlEOF = iota
+	gqlPunct
+	gqlName
+	gqlInt
+	gqlFloat
+	gqlString
+)
+
+type gqlToken struct {
+	kind  int
+	value string
+	pos   int
+}
+
+type gqlParser struct {
+	src string
+	pos int
+	tok gqlToken
+}
+
+// parseGraphQL parses a query document
+func parseGraphQL(src string) (doc *gqlDocument, err error) {
+	p := &gqlParser{src: src}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	doc = &gqlDocument{fragments: make(map[string]*gqlFragment)}
+	for p.tok.kind != gqlEOF {
+		if p.tok.kind == gqlName && p.tok.value == "fragment" {
+			name, fragment, err := p.parseFragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[name]; ok {
+				return nil, fmt.Errorf("fragment %q is defined more than once", name)
+			}
+			doc.fragments[name] = fragment
+			continue
+		}
+		operation, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		doc.operations = append(doc.operations, operation)
+	}
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("document contains no operation")
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("syntax error at offset %d: %s", p.tok.pos, fmt.Sprintf(format, args...))
+}
+
+// next advances to the next token, skipping whitespace, commas and comments
+func (p *gqlParser) next() error {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+		} else if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		} else {
+			break
+		}
+	}
+
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = gqlToken{kind: gqlEOF, pos: start}
+		return nil
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = gqlToken{kind: gqlPunct, value: "...", pos: start}
+	case strings.IndexByte("!$():=@[]{}|", c) >= 0:
+		p.pos++
+		p.tok = gqlToken{kind: gqlPunct, value: string(c), pos: start}
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		for p.pos < len(p.src) && isGQLNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+		p.tok = gqlToken{kind: gqlName, value: p.src[start:p.pos], pos: start}
+	case c == '-' || c >= '0' && c <= '9':
+		return p.lexNumber()
+	case c == '"':
+		return p.lexString()
+	default:
+		p.tok = gqlToken{pos: start}
+		return p.errorf("unexpected character %q", c)
+	}
+	return nil
+}
+
+func isGQLNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *gqlParser) lexNumber() error {
+	start := p.pos
+	kind := gqlInt
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() {
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+	}
+	digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		kind = gqlFloat
+		p.pos++
+		digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		kind = gqlFloat
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+	p.tok = gqlToken{kind: kind, value: p.src[start:p.pos], pos: start}
+	return nil
+}
+
+func (p *gqlParser) lexString() error {
+	start := p.pos
+	p.tok = gqlToken{pos: start}
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			return p.errorf("unterminated block string")
+		}
+		p.tok = gqlToken{kind: gqlString, value: p.src[p.pos+3 : p.pos+3+end], pos: start}
+		p.pos += end + 6
+		return nil
+	}
+
+	var value strings.Builder
+	p.pos++
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			return p.errorf("unterminated string")
+		}
+		c := p.src[p.pos]
+		if c == '"' {
+			p.pos++
+			break
+		}
+		if c != '\\' {
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			value.WriteRune(r)
+			p.pos += size
+			continue
+		}
+
+		if p.pos+1 >= len(p.src) {
+			return p.errorf("unterminated string")
+		}
+		escape := p.src[p.pos+1]
+		p.pos += 2
+		switch escape {
+		case '"', '\\', '/':
+			value.WriteByte(escape)
+		case 'b':
+			value.WriteByte('\b')
+		case 'f':
+			value.WriteByte('\f')
+		case 'n':
+			value.WriteByte('\n')
+		case 'r':
+			value.WriteByte('\r')
+		case 't':
+			value.WriteByte('\t')
+		case 'u':
+			if p.pos+4 > len(p.src) {
+				return p.errorf("invalid unicode escape")
+			}
+			code, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				return p.errorf("invalid unicode escape")
+			}
+			value.WriteRune(rune(code))
+			p.pos += 4
+		default:
+			return p.errorf("invalid escape \\%c", escape)
+		}
+	}
+	p.tok = gqlToken{kind: gqlString, value: value.String(), pos: start}
+	return nil
+}
+
+// expect consumes the punctuator punct
+func (p *gqlParser) expect(punct string) error {
+	if p.tok.kind != gqlPunct || p.tok.value != punct {
+		return p.errorf("expected %q", punct)
+	}
+	return p.next()
+}
+
+// skip consumes punct if it is the current token
+func (p *gqlParser) skip(punct string) (bool, error) {
+	if p.tok.kind == gqlPunct && p.tok.value == punct {
+		return true, p.next()
+	}
+	return false, nil
+}
+
+func (p *gqlParser) peek(punct string) bool {
+	return p.tok.kind == gqlPunct && p.tok.value == punct
+}
+
+func (p *gqlParser) name() (string, error) {
+	if p.tok.kind != gqlName {
+		return "", p.errorf("expected a name")
+	}
+	name := p.tok.value
+	return name, p.next()
+}
+
+func (p *gqlParser) parseOperation() (*gqlOperation, error) {
+	operation := &gqlOperation{kind: "query"}
+	if p.peek("{") {
+		selections, err := p.parseSelectionSet(1)
+		operation.selections = selections
+		return operation, err
+	}
+
+	kind, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if kind != "query" && kind != "mutation" && kind != "subscription" {
+		return nil, fmt.Errorf("unknown operation type %q", kind)
+	}
+	operation.kind = kind
+	if p.tok.kind == gqlName {
+		if operation.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+
+	if ok, err := p.skip("("); err != nil {
+		return nil, err
+	} else if ok {
+		for !p.peek(")") {
+			definition, err := p.parseVariableDefinition()
+			if err != nil {
+				return nil, err
+			}
+			operation.variables = append(operation.variables, definition)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek("@") {
+		return nil, p.errorf("directives are not supported")
+	}
+
+	operation.selections, err = p.parseSelectionSet(1)
+	return operation, err
+}
+
+func (p *gqlParser) parseVariableDefinition() (gqlVariableDefinition, error) {
+	var definition gqlVariableDefinition
+	if err := p.expect("$"); err != nil {
+		return definition, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return definition, err
+	}
+	definition.name = name
+	if err := p.expect(":"); err != nil {
+		return definition, err
+	}
+	if definition.required, err = p.parseType(); err != nil {
+		return definition, err
+	}
+	if ok, err := p.skip("="); err != nil {
+		return definition, err
+	} else if ok {
+		definition.hasDefault = true
+		if definition.defaultValue, err = p.parseValue(true); err != nil {
+			return definition, err
+		}
+	}
+	return definition, nil
+}
+
+// parseType skips a type reference and reports whether it is non-null
+func (p *gqlParser) parseType() (bool, error) {
+	if ok, err := p.skip("["); err != nil {
+		return false, err
+	} else if ok {
+		if _, err := p.parseType(); err != nil {
+			return false, err
+		}
+		if err := p.expect("]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.name(); err != nil {
+		return false, err
+	}
+	return p.skip("!")
+}
+
+func (p *gqlParser) parseFragment() (string, *gqlFragment, error) {
+	if err := p.next(); err != nil {
+		return "", nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return "", nil, err
+	}
+	if on, err := p.name(); err != nil || on != "on" {
+		return "", nil, p.errorf("expected \"on\" after fragment name")
+	}
+	typeCondition, err := p.name()
+	if err != nil {
+		return "", nil, err
+	}
+	selections, err := p.parseSelectionSet(1)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, &gqlFragment{typeCondition: typeCondition, selections: selections}, nil
+}
+
+func (p *gqlParser) parseSelectionSet(depth int) ([]*gqlSelection, error) {
+	if depth > gqlMaxDepth {
+		return nil, fmt.Errorf("query is nested deeper than %d levels", gqlMaxDepth)
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var selections []*gqlSelection
+	for !p.peek("}") {
+		if p.tok.kind == gqlEOF {
+			return nil, p.errorf("unterminated selection set")
+		}
+		selection, err := p.parseSelection(depth)
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	if len(selections) == 0 {
+		return nil, p.errorf("empty selection set")
+	}
+	return selections, p.next()
+}
+
+func (p *gqlParser) parseSelection(depth int) (*gqlSelection, error) {
+	if ok, err := p.skip("..."); err != nil {
+		return nil, err
+	} else if ok {
+		selection := &gqlSelection{}
+		if p.tok.kind == gqlName && p.tok.value != "on" {
+			selection.fragment, err = p.name()
+			return selection, err
+		}
+		selection.inline = true
+		if p.tok.kind == gqlName {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if selection.typeCondition, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		selection.selections, err = p.parseSelectionSet(depth + 1)
+		return selection, err
+	}
+
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	selection := &gqlSelection{name: name}
+	if ok, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		selection.alias = name
+		if selection.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+
+	if ok, err := p.skip("("); err != nil {
+		return nil, err
+	} else if ok {
+		selection.args = make(map[string]interface{})
+		for !p.peek(")") {
+			arg, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if selection.args[arg], err = p.parseValue(false); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek("@") {
+		return nil, p.errorf("directives are not supported")
+	}
+
+	if p.peek("{") {
+		if selection.selections, err = p.parseSelectionSet(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return selection, nil
+}
+
+// parseValue parses an argument or default value; constant values may not
+// reference variables
+func (p *gqlParser) parseValue(constant bool) (interface{}, error) {
+	tok := p.tok
+	switch tok.kind {
+	case gqlInt:
+		value, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, p.errorf("invalid integer %s", tok.value)
+		}
+		return value, p.next()
+	case gqlFloat:
+		value, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %s", tok.value)
+		}
+		return value, p.next()
+	case gqlString:
+		return tok.value, p.next()
+	case gqlName:
+		var value interface{} = tok.value // Enum values are passed as strings
+		switch tok.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		}
+		return value, p.next()
+	}
+
+	if p.peek("$") && constant {
+		return nil, p.errorf("variables are not allowed here")
+	}
+	opening := p.tok.value
+	if tok.kind != gqlPunct || (opening != "$" && opening != "[" && opening != "{") {
+		return nil, p.errorf("expected a value")
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	switch opening {
+	case "$":
+		name, err := p.name()
+		return gqlVariable(name), err
+	case "[":
+		list := []interface{}{}
+		for !p.peek("]") {
+			value, err := p.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, p.next()
+	default:
+		object := map[string]interface{}{}
+		for !p.peek("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if object[name], err = p.parseValue(constant); err != nil {
+				return nil, err
+			}
+		}
+		return object, p.next()
+	}
+}
+
+// gqlExecutor runs one operation of a document
+type gqlExecutor struct {
+	fragments map[string]*gqlFragment
+	variables map[string]interface{}
+	errors    []gqlError
+}
+
+// executeGraphQL runs a query against root and returns the response; the
+// error is set when the request is invalid and nothing was executed
+func executeGraphQL(root gqlObject, query, operationName string, variables map[string]interface{}) (*gqlResponse, error) {
+	doc, err := parseGraphQL(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if operationName == "" && len(doc.operations) > 1 {
+		return nil, fmt.Errorf("operationName is required for documents with several operations")
+	}
+	var operation *gqlOperation
+	for _, candidate := range doc.operations {
+		if operationName == "" || candidate.name == operationName {
+			operation = candidate
+			break
+		}
+	}
+	if operation == nil {
+		return nil, fmt.Errorf("unknown operation %q", operationName)
+	}
+	if operation.kind != "query" {
+		return nil, fmt.Errorf("%s operations are not supported", operation.kind)
+	}
+
+	// Substitute defaults and check required variables are given
+	values := make(map[string]interface{})
+	for _, definition := range operation.variables {
+		value, ok := variables[definition.name]
+		if !ok && definition.hasDefault {
+			value, ok = definition.defaultValue, true
+		}
+		if definition.required && (!ok || value == nil) {
+			return nil, fmt.Errorf("variable $%s is required", definition.name)
+		}
+		values[definition.name] = value
+	}
+
+	executor := &gqlExecutor{fragments: doc.fragments, variables: values}
+	data := executor.executeObject(root, operation.selections, nil)
+	return &gqlResponse{Data: data, Errors: executor.errors}, nil
+}
+
+// gqlResult is a selection's results in query order
+type gqlResult struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (r *gqlResult) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(r.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// collectFields flattens fragments into the fields that apply to an object
+// type, merging the sub-selections of fields with the same response key
+func (x *gqlExecutor) collectFields(typeName string, selections []*gqlSelection, fields *[]*gqlSelection, seen map[string]*gqlSelection, visited map[string]bool) error {
+	for _, selection := range selections {
+		switch {
+		case selection.fragment != "":
+			fragment, ok := x.fragments[selection.fragment]
+			if !ok {
+				return fmt.Errorf("unknown fragment %q", selection.fragment)
+			}
+			if visited[selection.fragment] {
+				return fmt.Errorf("fragment %q spreads itself", selection.fragment)
+			}
+			if fragment.typeCondition == typeName {
+				visited[selection.fragment] = true
+				err := x.collectFields(typeName, fragment.selections, fields, seen, visited)
+				delete(visited, selection.fragment)
+				if err != nil {
+					return err
+				}
+			}
+		case selection.inline:
+			if selection.typeCondition == "" || selection.typeCondition == typeName {
+				if err := x.collectFields(typeName, selection.selections, fields, seen, visited); err != nil {
+					return err
+				}
+			}
+		default:
+			key := selection.responseKey()
+			if existing, ok := seen[key]; ok {
+				if existing.name != selection.name {
+					return fmt.Errorf("fields %q and %q both use the response name %q", existing.name, selection.name, key)
+				}
+				merged := *existing
+				merged.selections = append(append([]*gqlSelection(nil), existing.selections...), selection.selections...)
+				*existing = merged
+				continue
+			}
+			field := *selection
+			seen[key] = &field
+			*fields = append(*fields, &field)
+		}
+	}
+	return nil
+}
+
+func (x *gqlExecutor) fail(path []interface{}, err error) {
+	x.errors = append(x.errors, gqlError{Message: err.Error(), Path: path})
+}
+
+// gqlPath returns a copy of path extended by a field name or list index
+func gqlPath(path []interface{}, element interface{}) []interface{} {
+	return append(append(make([]interface{}, 0, len(path)+1), path...), element)
+}
+
+func (x *gqlExecutor) executeObject(object gqlObject, selections []*gqlSelection, path []interface{}) interface{} {
+	var fields []*gqlSelection
+	if err := x.collectFields(object.typeName(), selections, &fields, make(map[string]*gqlSelection), make(map[string]bool)); err != nil {
+		x.fail(path, err)
+		return nil
+	}
+
+	result := &gqlResult{values: make(map[string]interface{})}
+	for _, field := range fields {
+		key := field.responseKey()
+		fieldPath := gqlPath(path, key)
+		result.keys = append(result.keys, key)
+
+		if field.name == "__typename" {
+			result.values[key] = object.typeName()
+			continue
+		}
+
+		args, err := x.arguments(field.args)
+		if err != nil {
+			x.fail(fieldPath, err)
+			result.values[key] = nil
+			continue
+		}
+		value, err := object.resolve(field.name, args)
+		if err != nil {
+			x.fail(fieldPath, err)
+			result.values[key] = nil
+			continue
+		}
+		result.values[key] = x.complete(value, field, fieldPath, object.typeName())
+	}
+	return result
+}
+
+// complete turns a resolved value into its response, selecting the fields of objects
+func (x *gqlExecutor) complete(value interface{}, field *gqlSelection, path []interface{}, parentType string) interface{} {
+	if value == nil {
+		return nil
+	}
+	if object, ok := value.(gqlObject); ok {
+		if len(field.selections) == 0 {
+			x.fail(path, fmt.Errorf("field %q of type %q must have a selection of subfields", field.name, object.typeName()))
+			return nil
+		}
+		return x.executeObject(object, field.selections, path)
+	}
+
+	if objects, ok := value.([]gqlObject); ok {
+		list := make([]interface{}, len(objects))
+		for i, object := range objects {
+			list[i] = x.complete(object, field, gqlPath(path, i), parentType)
+		}
+		return list
+	}
+
+	if len(field.selections) > 0 {
+		x.fail(path, fmt.Errorf("field %q of type %q is a scalar and cannot have a selection", field.name, parentType))
+		return nil
+	}
+	return value
+}
+
+// arguments substitutes variables into a field's arguments
+func (x *gqlExecutor) arguments(raw map[string]interface{}) (gqlArgs, error) {
+	args := make(gqlArgs, len(raw))
+	for name, value := range raw {
+		resolved, err := x.substitute(value)
+		if err != nil {
+			return nil, err
+		}
+		args[name] = resolved
+	}
+	return args, nil
+}
+
+func (x *gqlExecutor) substitute(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case gqlVariable:
+		resolved, ok := x.variables[string(v)]
+		if !ok {
+			return nil, fmt.Errorf("variable $%s is not defined", v)
+		}
+		return resolved, nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := x.substitute(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = resolved
+		}
+		return list, nil
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for name, item := range v {
+			resolved, err := x.substitute(item)
+			if err != nil {
+				return nil, err
+			}
+			object[name] = resolved
+		}
+		return object, nil
+	}
+	return value, nil
+}
+
+// allow checks that args holds no arguments other than names
+func (a gqlArgs) allow(field string, names ...string) error {
+	for arg := range a {
+		found := false
+		for _, name := range names {
+			found = found || arg == name
+		}
+		if !found {
+			return fmt.Errorf("unknown argument %q on field %q", arg, field)
+		}
+	}
+	return nil
+}
+
+// string returns a String or enum argument, or "" if it is absent
+func (a gqlArgs) string(name string) (string, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("argument %q must be a string", name)
+}
+
+// int returns an Int argument, or fallback if it is absent
+func (a gqlArgs) int(name string, fallback int) (int, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return fallback, nil
+	case int64:
+		return int(v), nil
+	case float64: // Variables decoded from JSON
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("argument %q must be an integer", name)
+}
+
+// float returns a Float argument and whether it was given
+func (a gqlArgs) float(name string) (float64, bool, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return 0, false, nil
+	case int64:
+		return float64(v), true, nil
+	case float64:
+		return v, true, nil
+	}
+	return 0, false, fmt.Errorf("argument %q must be a number", name)
+}
+
+// bool returns a Boolean argument and whether it was given
+func (a gqlArgs) bool(name string) (bool, bool, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return false, false, nil
+	case bool:
+		return v, true, nil
+	}
+	return false, false, fmt.Errorf("argument %q must be a boolean", name)
+}
@@ -0,0 +1,646 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// Report schema served at /graphql:
+//
+//	type Query {
+//	  reports(agentName, riskLevel, minRisk, maxRisk, since, until, vector, outdated, limit, offset): [Report]
+//	  report(hash: String!): Report
+//	  threats(vector, severity, minConfidence, agentName, since, until, limit): [Threat]
+//	  stats(agentName, since, until): Stats
+//	}
+//	type Report { hash agentHash agentName timestamp overallRisk riskLevel threatCount outdated
+//	  engineVersion configChecksum ruleset coverageComplete
+//	  threats(vector, severity, minConfidence, limit): [Threat]
+//	  shieldResults(valid: Boolean): [ShieldResult]
+//	  recommendations(priority: String): [Recommendation] }
+//	type Threat { vector vectorName severity confidence evidence location timestamp analysis report }
+//	type ShieldResult { module valid }
+//	type Recommendation { id title vector priority effort instances guidance }
+//	type Stats { reports threats averageRisk maxRisk byVector bySeverity byRiskLevel
+//	  overTime(interval: "hour" | "day" | "week" | "month"): [Bucket] }
+//	type Bucket { period reports threats averageRisk byVector bySeverity }
+//	type Count { key count }
+//
+// Times are RFC 3339 strings; since and until also accept dates. Vectors are
+// T1 to T9 and severities LOW, MEDIUM, HIGH or CRITICAL.
+
+// graphqlRequest is a GraphQL request body
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// graphqlHandler runs a report query sent as JSON in a POST body or as query
+// parameters of a GET
+func graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	var request graphqlRequest
+	if r.Method == http.MethodGet {
+		request.Query = r.URL.Query().Get("query")
+		request.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				writeGraphQLError(w, "variables must be a JSON object")
+				return
+			}
+		}
+	} else if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&request); err != nil {
+		writeGraphQLError(w, "Request body must be JSON with a \"query\"")
+		return
+	}
+	if request.Query == "" {
+		writeGraphQLError(w, "query is required")
+		return
+	}
+
+	reports, err := loadReports()
+	if err != nil {
+		http.Error(w, "Error reading reports", http.StatusInternalServerError)
+		return
+	}
+
+	// Newest first, as dashboards list them
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Timestamp.After(reports[j].Timestamp)
+	})
+	root := &queryRoot{reports: reports, current: engine.Version()}
+
+	response, err := executeGraphQL(root, request.Query, request.OperationName, request.Variables)
+	if err != nil {
+		writeGraphQLError(w, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeGraphQLError rejects a request that could not be executed
+func writeGraphQLError(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(gqlResponse{Errors: []gqlError{{Message: message}}})
+}
+
+// queryRoot is the Query type
+type queryRoot struct {
+	reports []*aegong.AuditReport
+	current aegong.EngineVersion
+}
+
+func (q *queryRoot) typeName() string { return "Query" }
+
+func (q *queryRoot) resolve(field string, args gqlArgs) (interface{}, error) {
+	switch field {
+	case "reports":
+		if err := args.allow(field, "agentName", "riskLevel", "minRisk", "maxRisk", "since", "until", "vector", "outdated", "limit", "offset"); err != nil {
+			return nil, err
+		}
+		reports, err := q.filterReports(args)
+		if err != nil {
+			return nil, err
+		}
+		riskLevel, err := args.string("riskLevel")
+		if err != nil {
+			return nil, err
+		}
+		minRisk, hasMin, err := args.float("minRisk")
+		if err != nil {
+			return nil, err
+		}
+		maxRisk, hasMax, err := args.float("maxRisk")
+		if err != nil {
+			return nil, err
+		}
+		vector, err := args.string("vector")
+		if err != nil {
+			return nil, err
+		}
+		outdated, hasOutdated, err := args.bool("outdated")
+		if err != nil {
+			return nil, err
+		}
+
+		var objects []gqlObject
+		for _, report := range reports {
+			if riskLevel != "" && !strings.EqualFold(report.RiskLevel, riskLevel) ||
+				hasMin && report.OverallRisk < minRisk ||
+				hasMax && report.OverallRisk > maxRisk ||
+				hasOutdated && report.Engine.Outdated(q.current) != outdated {
+				continue
+			}
+			if vector != "" && !hasVector(report, vector) {
+				continue
+			}
+			objects = append(objects, &reportObject{report: report, root: q})
+		}
+		return paginate(objects, args)
+
+	case "report":
+		if err := args.allow(field, "hash"); err != nil {
+			return nil, err
+		}
+		hash, err := args.string("hash")
+		if err != nil || hash == "" {
+			return nil, fmt.Errorf("argument \"hash\" is required")
+		}
+		for _, report := range q.reports {
+			if strings.HasPrefix(report.AgentHash, hash) {
+				return &reportObject{report: report, root: q}, nil
+			}
+		}
+		return nil, nil
+
+	case "threats":
+		if err := args.allow(field, "vector", "severity", "minConfidence", "agentName", "since", "until", "limit"); err != nil {
+			return nil, err
+		}
+		reports, err := q.filterReports(args)
+		if err != nil {
+			return nil, err
+		}
+		filter, err := newThreatFilter(args)
+		if err != nil {
+			return nil, err
+		}
+		var objects []gqlObject
+		for _, report := range reports {
+			objects = append(objects, filter.apply(&reportObject{report: report, root: q})...)
+		}
+		return paginate(objects, args)
+
+	case "stats":
+		if err := args.allow(field, "agentName", "since", "until"); err != nil {
+			return nil, err
+		}
+		reports, err := q.filterReports(args)
+		if err != nil {
+			return nil, err
+		}
+		return &statsObject{reports: reports}, nil
+	}
+	return nil, unknownField(field, q)
+}
+
+// filterReports applies the agentName, since and until arguments
+func (q *queryRoot) filterReports(args gqlArgs) ([]*aegong.AuditReport, error) {
+	agentName, err := args.string("agentName")
+	if err != nil {
+		return nil, err
+	}
+	since, err := timeArg(args, "since")
+	if err != nil {
+		return nil, err
+	}
+	until, err := timeArg(args, "until")
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []*aegong.AuditReport
+	for _, report := range q.reports {
+		if agentName != "" && report.AgentName != agentName ||
+			!since.IsZero() && report.Timestamp.Before(since) ||
+			!until.IsZero() && !report.Timestamp.Before(until) {
+			continue
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// timeArg parses an RFC 3339 time or a date
+func timeArg(args gqlArgs, name string) (time.Time, error) {
+	value, err := args.string(name)
+	if err != nil || value == "" {
+		return time.Time{}, err
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("argument %q must be an RFC 3339 time or a date", name)
+}
+
+// paginate applies the limit and offset arguments
+func paginate(objects []gqlObject, args gqlArgs) ([]gqlObject, error) {
+	offset, err := args.int("offset", 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := args.int("limit", len(objects))
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 || limit < 0 {
+		return nil, fmt.Errorf("limit and offset must not be negative")
+	}
+	if offset > len(objects) {
+		offset = len(objects)
+	}
+	objects = objects[offset:]
+	if limit < len(objects) {
+		objects = objects[:limit]
+	}
+	return objects, nil
+}
+
+func unknownField(field string, object gqlObject) error {
+	return fmt.Errorf("cannot query field %q on type %q", field, object.typeName())
+}
+
+// vectorCode names a threat vector T1 to T9
+func vectorCode(vector aegong.ThreatVector) string {
+	return fmt.Sprintf("T%d", int(vector)+1)
+}
+
+func hasVector(report *aegong.AuditReport, vector string) bool {
+	for _, threat := range report.Threats {
+		if strings.EqualFold(vectorCode(threat.Vector), vector) {
+			return true
+		}
+	}
+	return false
+}
+
+// reportObject is the Report type
+type reportObject struct {
+	report *aegong.AuditReport
+	root   *queryRoot
+}
+
+func (o *reportObject) typeName() string { return "Report" }
+
+func (o *reportObject) resolve(field string, args gqlArgs) (interface{}, error) {
+	report := o.report
+	switch field {
+	case "hash":
+		return report.AgentHash[:min(8, len(report.AgentHash))], nil
+	case "agentHash":
+		return report.AgentHash, nil
+	case "agentName":
+		return report.AgentName, nil
+	case "timestamp":
+		return report.Timestamp, nil
+	case "overallRisk":
+		return report.OverallRisk, nil
+	case "riskLevel":
+		return report.RiskLevel, nil
+	case "threatCount":
+		return len(report.Threats), nil
+	case "outdated":
+		return report.Engine.Outdated(o.root.current), nil
+	case "engineVersion":
+		if report.Engine == nil {
+			return nil, nil
+		}
+		return report.Engine.Version, nil
+	case "configChecksum":
+		if report.Engine == nil {
+			return nil, nil
+		}
+		return report.Engine.ConfigChecksum, nil
+	case "ruleset":
+		if report.Ruleset == nil {
+			return nil, nil
+		}
+		return report.Ruleset.Version, nil
+	case "coverageComplete":
+		if report.Coverage == nil {
+			return nil, nil
+		}
+		return report.Coverage.Complete, nil
+	case "threats":
+		if err := args.allow(field, "vector", "severity", "minConfidence", "limit"); err != nil {
+			return nil, err
+		}
+		filter, err := newThreatFilter(args)
+		if err != nil {
+			return nil, err
+		}
+		return paginate(filter.apply(o), args)
+	case "shieldResults":
+		if err := args.allow(field, "valid"); err != nil {
+			return nil, err
+		}
+		valid, hasValid, err := args.bool("valid")
+		if err != nil {
+			return nil, err
+		}
+		var modules []string
+		for module := range report.ShieldResults {
+			modules = append(modules, module)
+		}
+		sort.Strings(modules)
+
+		var objects []gqlObject
+		for _, module := range modules {
+			result, _ := report.ShieldResults[module].(map[string]interface{})
+			moduleValid, _ := result["valid"].(bool)
+			if !hasValid || moduleValid == valid {
+				objects = append(objects, &shieldObject{module: module, valid: moduleValid})
+			}
+		}
+		return objects, nil
+	case "recommendations":
+		if err := args.allow(field, "priority"); err != nil {
+			return nil, err
+		}
+		priority, err := args.string("priority")
+		if err != nil {
+			return nil, err
+		}
+		var objects []gqlObject
+		for _, recommendation := range report.Recommendations {
+			if priority == "" || strings.EqualFold(recommendation.Priority, priority) {
+				objects = append(objects, &recommendationObject{recommendation})
+			}
+		}
+		return objects, nil
+	}
+	return nil, unknownField(field, o)
+}
+
+// threatFilter selects threats by the vector, severity and minConfidence arguments
+type threatFilter struct {
+	vector        string
+	severity      string
+	minConfidence float64
+}
+
+func newThreatFilter(args gqlArgs) (*threatFilter, error) {
+	var filter threatFilter
+	var err error
+	if filter.vector, err = args.string("vector"); err != nil {
+		return nil, err
+	}
+	if filter.severity, err = args.string("severity"); err != nil {
+		return nil, err
+	}
+	if filter.minConfidence, _, err = args.float("minConfidence"); err != nil {
+		return nil, err
+	}
+	return &filter, nil
+}
+
+func (f *threatFilter) apply(report *reportObject) []gqlObject {
+	var objects []gqlObject
+	for _, threat := range report.report.Threats {
+		if f.vector != "" && !strings.EqualFold(vectorCode(threat.Vector), f.vector) ||
+			f.severity != "" && !strings.EqualFold(aegong.SeverityName(threat.Severity), f.severity) ||
+			threat.Confidence < f.minConfidence {
+			continue
+		}
+		objects = append(objects, &threatObject{threat: threat, report: report})
+	}
+	return objects
+}
+
+// threatObject is the Threat type
+type threatObject struct {
+	threat aegong.ThreatDetection
+	report *reportObject
+}
+
+func (o *threatObject) typeName() string { return "Threat" }
+
+func (o *threatObject) resolve(field string, args gqlArgs) (interface{}, error) {
+	threat := o.threat
+	switch field {
+	case "vector":
+		return vectorCode(threat.Vector), nil
+	case "vectorName":
+		return aegong.ThreatName(threat.Vector), nil
+	case "severity":
+		return aegong.SeverityName(threat.Severity), nil
+	case "confidence":
+		return threat.Confidence, nil
+	case "evidence":
+		return threat.Evidence, nil
+	case "location":
+		if threat.Location == nil {
+			return nil, nil
+		}
+		return threat.Location.String(), nil
+	case "timestamp":
+		return threat.Timestamp, nil
+	case "analysis":
+		return threat.Details["analysis"], nil
+	case "report":
+		return o.report, nil
+	}
+	return nil, unknownField(field, o)
+}
+
+// shieldObject is the ShieldResult type
+type shieldObject struct {
+	module string
+	valid  bool
+}
+
+func (o *shieldObject) typeName() string { return "ShieldResult" }
+
+func (o *shieldObject) resolve(field string, args gqlArgs) (interface{}, error) {
+	switch field {
+	case "module":
+		return o.module, nil
+	case "valid":
+		return o.valid, nil
+	}
+	return nil, unknownField(field, o)
+}
+
+// recommendationObject is the Recommendation type
+type recommendationObject struct {
+	aegong.Recommendation
+}
+
+func (o *recommendationObject) typeName() string { return "Recommendation" }
+
+func (o *recommendationObject) resolve(field string, args gqlArgs) (interface{}, error) {
+	switch field {
+	case "id":
+		return o.ID, nil
+	case "title":
+		return o.Title, nil
+	case "vector":
+		return o.Vector, nil
+	case "priority":
+		return o.Priority, nil
+	case "effort":
+		return o.Effort, nil
+	case "instances":
+		return o.Instances, nil
+	case "guidance":
+		return o.Guidance, nil
+	}
+	return nil, unknownField(field, o)
+}
+
+// statsObject is the Stats type, aggregating a set of reports
+type statsObject struct {
+	reports []*aegong.AuditReport
+}
+
+func (o *statsObject) typeName() string { return "Stats" }
+
+func (o *statsObject) resolve(field string, args gqlArgs) (interface{}, error) {
+	switch field {
+	case "reports":
+		return len(o.reports), nil
+	case "threats":
+		threats := 0
+		for _, report := range o.reports {
+			threats += len(report.Threats)
+		}
+		return threats, nil
+	case "averageRisk":
+		if len(o.reports) == 0 {
+			return 0.0, nil
+		}
+		total := 0.0
+		for _, report := range o.reports {
+			total += report.OverallRisk
+		}
+		return total / float64(len(o.reports)), nil
+	case "maxRisk":
+		maxRisk := 0.0
+		for _, report := range o.reports {
+			maxRisk = max(maxRisk, report.OverallRisk)
+		}
+		return maxRisk, nil
+	case "byVector":
+		return o.countThreats(func(threat aegong.ThreatDetection) string { return vectorCode(threat.Vector) }), nil
+	case "bySeverity":
+		return o.countThreats(func(threat aegong.ThreatDetection) string { return aegong.SeverityName(threat.Severity) }), nil
+	case "byRiskLevel":
+		counts := make(map[string]int)
+		for _, report := range o.reports {
+			counts[report.RiskLevel]++
+		}
+		return countObjects(counts), nil
+	case "overTime":
+		if err := args.allow(field, "interval"); err != nil {
+			return nil, err
+		}
+		interval, err := args.string("interval")
+		if err != nil {
+			return nil, err
+		}
+		return o.buckets(interval)
+	}
+	return nil, unknownField(field, o)
+}
+
+func (o *statsObject) countThreats(key func(aegong.ThreatDetection) string) []gqlObject {
+	counts := make(map[string]int)
+	for _, report := range o.reports {
+		for _, threat := range report.Threats {
+			counts[key(threat)]++
+		}
+	}
+	return countObjects(counts)
+}
+
+// buckets groups the reports into periods of interval, oldest first
+func (o *statsObject) buckets(interval string) ([]gqlObject, error) {
+	var truncate func(time.Time) time.Time
+	switch interval {
+	case "hour":
+		truncate = func(t time.Time) time.Time { return t.Truncate(time.Hour) }
+	case "day", "":
+		truncate = func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC) }
+	case "week":
+		truncate = func(t time.Time) time.Time {
+			day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			return day.AddDate(0, 0, -(int(day.Weekday())+6)%7) // Weeks start on Monday
+		}
+	case "month":
+		truncate = func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC) }
+	default:
+		return nil, fmt.Errorf("interval must be hour, day, week or month")
+	}
+
+	periods := make(map[time.Time]*bucketObject)
+	for _, report := range o.reports {
+		period := truncate(report.Timestamp.UTC())
+		if periods[period] == nil {
+			periods[period] = &bucketObject{period: period}
+		}
+		periods[period].reports = append(periods[period].reports, report)
+	}
+
+	var buckets []*bucketObject
+	for _, bucket := range periods {
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].period.Before(buckets[j].period) })
+
+	objects := make([]gqlObject, len(buckets))
+	for i, bucket := range buckets {
+		objects[i] = bucket
+	}
+	return objects, nil
+}
+
+// bucketObject is the Bucket type, the reports of one period
+type bucketObject struct {
+	period  time.Time
+	reports []*aegong.AuditReport
+}
+
+func (o *bucketObject) typeName() string { return "Bucket" }
+
+func (o *bucketObject) resolve(field string, args gqlArgs) (interface{}, error) {
+	switch field {
+	case "period":
+		return o.period, nil
+	case "reports", "threats", "averageRisk", "byVector", "bySeverity":
+		return (&statsObject{reports: o.reports}).resolve(field, args)
+	}
+	return nil, unknownField(field, o)
+}
+
+// countObject is the Count type
+type countObject struct {
+	key   string
+	count int
+}
+
+func (o *countObject) typeName() string { return "Count" }
+
+func (o *countObject) resolve(field string, args gqlArgs) (interface{}, error) {
+	switch field {
+	case "key":
+		return o.key, nil
+	case "count":
+		return o.count, nil
+	}
+	return nil, unknownField(field, o)
+}
+
+func countObjects(counts map[string]int) []gqlObject {
+	var keys []string
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	objects := make([]gqlObject, len(keys))
+	for i, key := range keys {
+		objects[i] = &countObject{key: key, count: counts[key]}
+	}
+	return objects
+}
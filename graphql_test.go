@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// withTestReports saves reports into a temporary reports directory
+func withTestReports(t *testing.T, reports ...*aegong.AuditReport) {
+	withTestUpload(t)
+	oldEngine := engine
+	t.Cleanup(func() { engine = oldEngine })
+	engine, _ = aegong.NewEngine(aegong.Config{})
+
+	os.MkdirAll("reports", 0755)
+	for _, report := range reports {
+		data, _ := json.Marshal(report)
+		if err := os.WriteFile(fmt.Sprintf("reports/report_%s.json", report.AgentHash[:8]), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func queryGraphQL(t *testing.T, query string, variables map[string]interface{}) (int, map[string]json.RawMessage) {
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	w := httptest.NewRecorder()
+	graphqlHandler(w, httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body))))
+
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Response should be JSON: %v\n%s", err, w.Body.String())
+	}
+	return w.Code, response
+}
+
+// TestGraphQLReports tests filtering, nested selection and aggregation over saved reports
+func TestGraphQLReports(t *testing.T) {
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	withTestReports(t,
+		&aegong.AuditReport{
+			AgentHash: "aaaaaaaa1111", AgentName: "scraper", Timestamp: day, OverallRisk: 0.7, RiskLevel: "HIGH",
+			Threats: []aegong.ThreatDetection{
+				{Vector: aegong.T4_UNAUTHORIZED_ACTION, Severity: aegong.HIGH, Confidence: 0.9, Evidence: []string{"os.system"},
+					Location: &aegong.Location{File: "scraper/run.py", Offset: 42, Line: 3}},
+				{Vector: aegong.T1_REASONING_HIJACK, Severity: aegong.LOW, Confidence: 0.4},
+			},
+			ShieldResults: map[string]interface{}{
+				"integrity": map[string]interface{}{"valid": false},
+				"logging":   map[string]interface{}{"valid": true},
+			},
+		},
+		&aegong.AuditReport{
+			AgentHash: "bbbbbbbb2222", AgentName: "helper", Timestamp: day.AddDate(0, 0, 1), OverallRisk: 0.1, RiskLevel: "MINIMAL",
+			Threats: []aegong.ThreatDetection{
+				{Vector: aegong.T4_UNAUTHORIZED_ACTION, Severity: aegong.MEDIUM, Confidence: 0.6},
+			},
+		},
+	)
+
+	code, response := queryGraphQL(t, `
+		query Dashboard($vector: String!) {
+			risky: reports(minRisk: 0.5) {
+				hash
+				agentName
+				threats(vector: $vector) { severity evidence location report { agentName } }
+				failed: shieldResults(valid: false) { module }
+			}
+			report(hash: "bbbbbbbb") { ...Summary }
+			stats {
+				reports
+				byVector { key count }
+				overTime(interval: day) { period threats }
+			}
+		}
+		fragment Summary on Report { agentName riskLevel __typename }`,
+		map[string]interface{}{"vector": "T4"})
+	if code != http.StatusOK || response["errors"] != nil {
+		t.Fatalf("Query should succeed, got %d %s", code, response["errors"])
+	}
+
+	// Fields are returned in query order
+	want := `{"risky":[{"hash":"aaaaaaaa","agentName":"scraper","threats":[{"severity":"HIGH","evidence":["os.system"],"location":"scraper/run.py:3","report":{"agentName":"scraper"}}],"failed":[{"module":"integrity"}]}],` +
+		`"report":{"agentName":"helper","riskLevel":"MINIMAL","__typename":"Report"},` +
+		`"stats":{"reports":2,"byVector":[{"key":"T1","count":1},{"key":"T4","count":2}],` +
+		`"overTime":[{"period":"2024-03-01T00:00:00Z","threats":2},{"period":"2024-03-02T00:00:00Z","threats":1}]}}`
+	if string(response["data"]) != want {
+		t.Fatalf("Unexpected data:\n got %s\nwant %s", response["data"], want)
+	}
+}
+
+// TestGraphQLErrors tests that invalid queries are rejected and field errors reported
+func TestGraphQLErrors(t *testing.T) {
+	withTestReports(t, &aegong.AuditReport{AgentHash: "cccccccc3333", Timestamp: time.Now()})
+
+	for _, query := range []string{`{ reports { hash }`, `mutation { reports { hash } }`, `query($id: String!) { report(hash: $id) { hash } }`} {
+		if code, response := queryGraphQL(t, query, nil); code != http.StatusBadRequest || response["errors"] == nil {
+			t.Fatalf("Query %q should be rejected, got %d %s", query, code, response["data"])
+		}
+	}
+
+	code, response := queryGraphQL(t, `{ reports { hash secret } stats { reports } }`, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Field errors should not fail the request, got %d", code)
+	}
+	var errors []gqlError
+	json.Unmarshal(response["errors"], &errors)
+	if len(errors) != 1 || !strings.Contains(errors[0].Message, "secret") || fmt.Sprint(errors[0].Path) != "[reports 0 secret]" {
+		t.Fatalf("Unknown fields should be reported with their path, got %s", response["errors"])
+	}
+	if !strings.Contains(string(response["data"]), `"stats":{"reports":1}`) {
+		t.Fatalf("Other fields should still resolve after a field error, got %s", response["data"])
+	}
+
+	// Queries can also be sent as GET parameters
+	w := httptest.NewRecorder()
+	graphqlHandler(w, httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(`{ stats { reports } }`), nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"reports":1`) {
+		t.Fatalf("GET queries should be executed, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// Job states
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobCompleted = "completed"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+// Finished jobs kept in memory for status queries
+const maxFinishedJobs = 200
+
+// auditJob is an audit submitted through the jobs API
+type auditJob struct {
+	ID            string     `json:"id"`
+	Filename      string     `json:"filename"`
+	Status        string     `json:"status"`
+	Phase         string     `json:"phase,omitempty"` // Audit phase a running job is in
+	QueuePosition int        `json:"queue_position,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	ReportHash    string     `json:"report_hash,omitempty"`
+	CorrelationID string     `json:"correlation_id"` // Traces the job in logs, events and its report
+
+	options jobOptions
+	ticket  *auditTicket
+	cancel  context.CancelFunc
+}
+
+// jobStore runs audit jobs in the background and tracks their state
+type jobStore struct {
+	mutex  sync.RWMutex
+	jobs   map[string]*auditJob
+	ctx    context.Context // Parent of every job; cancelled on forced shutdown
+	active sync.WaitGroup
+	events *eventBus // Receives job lifecycle events
+	// Where jobs are saved so they survive a restart; empty keeps jobs in
+	// memory only
+	dir string
+	// Runs the audit; replaced in tests
+	audit func(ctx context.Context, filename string, opts auditOptions) (*aegong.AuditReport, error)
+}
+
+func newJobStore(ctx context.Context) *jobStore {
+	return &jobStore{
+		jobs:   make(map[string]*auditJob),
+		ctx:    ctx,
+		events: bus,
+		audit:  runAudit,
+	}
+}
+
+var jobs = newJobStore(context.Background())
+
+// randomID returns a random hex identifier for audits and jobs
+func randomID() string {
+	idBytes := make([]byte, 8)
+	rand.Read(idBytes)
+	return hex.EncodeToString(idBytes)
+}
+
+// jobOptions are the options of a job that are saved with it
+type jobOptions struct {
+	Force     bool              `json:"force,omitempty"`
+	Principal Principal         `json:"principal"`
+	Scope     aegong.AuditScope `json:"scope"`
+	Narration string            `json:"narration,omitempty"`
+	Tenant    string            `json:"tenant,omitempty"`
+}
+
+// storedJob is a job as saved in the store's directory
+type storedJob struct {
+	auditJob
+	Options jobOptions `json:"options"`
+}
+
+// submit queues an audit of an upload, failing if the executor queue is full.
+// The job is traced by the correlation ID of the request that submitted it,
+// or by its own ID if that is empty.
+func (s *jobStore) submit(filename, correlationID string, opts auditOptions) (*auditJob, error) {
+	job := &auditJob{
+		ID:            randomID(),
+		Filename:      filename,
+		CreatedAt:     time.Now(),
+		CorrelationID: correlationID,
+		options: jobOptions{
+			Force:     opts.Force,
+			Principal: opts.Principal,
+			Scope:     opts.Scope,
+			Narration: opts.Narration,
+			Tenant:    opts.Tenant,
+		},
+	}
+	if job.CorrelationID == "" {
+		job.CorrelationID = job.ID
+	}
+	if err := s.start(job, opts); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// start queues a job, saving it until it finishes. Its audit is checkpointed
+// under the job's ID.
+func (s *jobStore) start(job *auditJob, opts auditOptions) error {
+	ticket, err := auditSlots.enqueue()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	job.Status = jobQueued
+	job.ticket = ticket
+	job.cancel = cancel
+	ctx = aegong.WithCorrelationID(ctx, job.CorrelationID)
+	ctx = withTenant(ctx, opts.Tenant)
+	opts.Ticket = ticket
+	opts.Checkpoint = job.ID
+
+	s.mutex.Lock()
+	s.pruneLocked()
+	s.jobs[job.ID] = job
+	s.saveLocked(job)
+	s.mutex.Unlock()
+
+	s.active.Add(1)
+	go s.run(ctx, job, opts)
+	return nil
+}
+
+// saveLocked writes a job's state to dir, encrypted at rest if configured
+func (s *jobStore) saveLocked(job *auditJob) {
+	if s.dir == "" {
+		return
+	}
+	stored := storedJob{auditJob: *job, Options: job.options}
+	stored.QueuePosition = 0
+	data, _ := json.Marshal(stored)
+	err := os.MkdirAll(s.dir, 0700)
+	if err == nil {
+		err = writeStored(filepath.Join(s.dir, job.ID+".json"), data)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to save job %s, it will not survive a restart: %v", job.ID, err)
+	}
+}
+
+// restore loads the jobs saved in dir by the last server. Queued jobs are
+// queued again and running ones resume from their audit's checkpoint, or fail
+// if it has none. Finished jobs are kept for status queries.
+func (s *jobStore) restore() {
+	if s.dir == "" {
+		return
+	}
+	paths, _ := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	var restored []storedJob
+	for _, path := range paths {
+		var stored storedJob
+		data, err := readStored(path)
+		if err == nil {
+			err = json.Unmarshal(data, &stored)
+		}
+		if err != nil || stored.ID+".json" != filepath.Base(path) {
+			log.Printf("Warning: Ignoring unreadable job %s: %v", path, err)
+			continue
+		}
+		restored = append(restored, stored)
+	}
+	// Queued jobs keep their order
+	sort.Slice(restored, func(i, j int) bool {
+		return restored[i].CreatedAt.Before(restored[j].CreatedAt)
+	})
+
+	for _, stored := range restored {
+		job := stored.auditJob
+		job.options = stored.Options
+		job.cancel = func() {}
+		if job.FinishedAt != nil {
+			s.mutex.Lock()
+			s.jobs[job.ID] = &job
+			s.mutex.Unlock()
+			continue
+		}
+
+		if job.Status == jobRunning && (engine == nil || !engine.HasCheckpoint(job.ID)) {
+			s.fail(&job, "interrupted by a server restart before any results were saved")
+			continue
+		}
+		if job.Status == jobRunning {
+			log.Printf("Info: Resuming job %s of %s from its checkpoint", job.ID, job.Filename)
+		}
+		job.Phase = ""
+		opts := auditOptions{
+			Force:     job.options.Force,
+			Principal: job.options.Principal,
+			Scope:     job.options.Scope,
+			Narration: job.options.Narration,
+			Tenant:    job.options.Tenant,
+		}
+		// Restored jobs take up their tenant's concurrent audits again
+		if opts.Tenant != "" {
+			opts.Release = quotas.resumeAudit(opts.Tenant)
+		}
+		if err := s.start(&job, opts); err != nil {
+			if opts.Release != nil {
+				opts.Release()
+			}
+			s.fail(&job, "could not be queued again after a server restart: "+err.Error())
+		}
+	}
+}
+
+// fail records a job that could not be restored as failed and discards its
+// checkpoint
+func (s *jobStore) fail(job *auditJob, reason string) {
+	log.Printf("Warning: Job %s of %s %s", job.ID, job.Filename, reason)
+	finished := time.Now()
+	job.Status = jobFailed
+	job.Error = "Job " + reason
+	job.Phase = ""
+	job.FinishedAt = &finished
+	if engine != nil {
+		engine.DiscardCheckpoint(job.ID)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.jobs[job.ID] = job
+	s.saveLocked(job)
+}
+
+func (s *jobStore) run(ctx context.Context, job *auditJob, opts auditOptions) {
+	defer s.active.Done()
+	defer job.cancel()
+	defer job.ticket.release()
+	if opts.Release != nil {
+		defer opts.Release()
+	}
+
+	if err := job.ticket.wait(ctx); err == nil {
+		started := time.Now()
+		s.mutex.Lock()
+		job.Status = jobRunning
+		job.StartedAt = &started
+		s.saveLocked(job)
+		s.mutex.Unlock()
+		ctx = s.events.auditStarted(ctx, job.ID, job.Filename)
+		ctx = aegong.WithObserver(ctx, &aegong.AuditObserver{PhaseStarted: func(phase string) {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			job.Phase = phase
+			s.saveLocked(job)
+		}})
+	}
+
+	report, err := s.audit(ctx, job.Filename, opts)
+	s.events.auditFinished(ctx, job.ID, job.Filename, report, err)
+
+	// Jobs interrupted by a forced shutdown keep their saved state and
+	// checkpoint so they resume on the next start
+	if s.ctx.Err() != nil {
+		return
+	}
+	if engine != nil {
+		engine.DiscardCheckpoint(job.ID)
+	}
+
+	finished := time.Now()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	defer s.saveLocked(job)
+	job.Phase = ""
+	job.FinishedAt = &finished
+	switch {
+	case ctx.Err() != nil:
+		job.Status = jobCancelled
+	case err != nil:
+		job.Status = jobFailed
+		job.Error = err.Error()
+	default:
+		job.Status = jobCompleted
+		job.ReportHash = report.AgentHash
+	}
+}
+
+// pruneLocked drops the oldest finished jobs beyond maxFinishedJobs
+func (s *jobStore) pruneLocked() {
+	var finished []*auditJob
+	for _, job := range s.jobs {
+		if job.FinishedAt != nil {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) < maxFinishedJobs {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.Before(*finished[j].FinishedAt)
+	})
+	for _, job := range finished[:len(finished)-maxFinishedJobs+1] {
+		delete(s.jobs, job.ID)
+		if s.dir != "" {
+			os.Remove(filepath.Join(s.dir, job.ID+".json"))
+		}
+	}
+}
+
+// snapshot returns a copy of a job with its current queue position
+func (s *jobStore) snapshot(job *auditJob) auditJob {
+	s.mutex.RLock()
+	snapshot := *job
+	s.mutex.RUnlock()
+
+	if snapshot.Status == jobQueued {
+		snapshot.QueuePosition = job.ticket.position()
+	}
+	return snapshot
+}
+
+func (s *jobStore) get(id string) (*auditJob, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+// wait blocks until every job has finished or ctx is done
+func (s *jobStore) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// createJobHandler queues an audit of an upload and returns immediately
+func createJobHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Filename string            `json:"filename"`
+		Force    bool              `json:"force"`
+		Options  aegong.AuditScope `json:"options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Filename == "" {
+		http.Error(w, "Request body must be JSON with the \"filename\" of an upload", http.StatusBadRequest)
+		return
+	}
+	if err := engine.CheckScope(request.Options); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filePath, err := uploadPath(request.Filename)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+
+	opts := auditOptions{Scope: request.Options}
+	if request.Force && !authorizeForce(w, r, &opts) {
+		return
+	}
+	if !admitRequestAudit(w, r, &opts) {
+		return
+	}
+
+	job, err := jobs.submit(request.Filename, aegong.CorrelationID(r.Context()), opts)
+	if err != nil {
+		opts.Release()
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(jobs.snapshot(job))
+}
+
+// listJobsHandler returns every known job, newest first
+func listJobsHandler(w http.ResponseWriter, r *http.Request) {
+	jobs.mutex.RLock()
+	all := make([]*auditJob, 0, len(jobs.jobs))
+	for _, job := range jobs.jobs {
+		all = append(all, job)
+	}
+	jobs.mutex.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].CreatedAt.After(all[j].CreatedAt)
+	})
+	snapshots := make([]auditJob, len(all))
+	for i, job := range all {
+		snapshots[i] = jobs.snapshot(job)
+	}
+
+	running, queued := auditSlots.stats()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs":    snapshots,
+		"running": running,
+		"queued":  queued,
+	})
+}
+
+// jobHandler returns a job's status and queue position
+func jobHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := jobs.get(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs.snapshot(job))
+}
+
+// cancelJobHandler cancels a queued or running job
+func cancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := jobs.get(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	job.cancel()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// jobPartialHandler returns the findings of a running or interrupted job's
+// completed detectors
+func jobPartialHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := jobs.get(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	report, err := engine.PartialReport(job.ID)
+	if err == aegong.ErrNoCheckpoint {
+		http.Error(w, "Job has no partial results", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
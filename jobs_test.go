@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// TestAuditExecutor tests slot limits, FIFO hand-off and the queue depth
+func TestAuditExecutor(t *testing.T) {
+	x := newAuditExecutor(1, 2)
+
+	first, _ := x.enqueue()
+	second, _ := x.enqueue()
+	third, _ := x.enqueue()
+	if _, err := x.enqueue(); err != errAuditQueueFull {
+		t.Fatalf("Should reject audits beyond the queue depth, got %v", err)
+	}
+	if first.position() != 0 || second.position() != 1 || third.position() != 2 {
+		t.Fatalf("Should report queue positions 0, 1, 2, got %d, %d, %d", first.position(), second.position(), third.position())
+	}
+
+	// A cancelled wait gives up its place
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := second.wait(ctx); err == nil {
+		t.Fatal("Should not grant a slot while one is running")
+	}
+	if third.position() != 1 {
+		t.Fatalf("Should move up after a cancellation, got position %d", third.position())
+	}
+
+	first.release()
+	first.release()
+	if err := third.wait(context.Background()); err != nil {
+		t.Fatalf("Should hand the slot to the next audit: %v", err)
+	}
+	if running, queued := x.stats(); running != 1 || queued != 0 {
+		t.Fatalf("Should count one running audit after a double release, got %d running, %d queued", running, queued)
+	}
+}
+
+// TestJobLifecycle tests that jobs queue behind the concurrency limit and report their state
+func TestJobLifecycle(t *testing.T) {
+	withTestUpload(t)
+
+	oldSlots, oldJobs := auditSlots, jobs
+	t.Cleanup(func() { auditSlots, jobs = oldSlots, oldJobs })
+	auditSlots = newAuditExecutor(1, 4)
+	jobs = newJobStore(context.Background())
+
+	release := make(chan struct{})
+	jobs.audit = func(ctx context.Context, filename string, opts auditOptions) (*aegong.AuditReport, error) {
+		if err := opts.Ticket.wait(ctx); err != nil {
+			return nil, err
+		}
+		select {
+		case <-release:
+			return &aegong.AuditReport{AgentHash: "abc123"}, nil
+		case <-ctx.Done():
+			return nil, errors.New("audit cancelled")
+		}
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/jobs", createJobHandler).Methods("POST")
+	router.HandleFunc("/api/jobs/{id}", jobHandler).Methods("GET")
+	router.HandleFunc("/api/jobs/{id}", cancelJobHandler).Methods("DELETE")
+
+	submit := func() *auditJob {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("POST", "/api/jobs", strings.NewReader(`{"filename":"agent.py"}`)))
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("Should accept the job, got %d: %s", rec.Code, rec.Body)
+		}
+		job, _ := jobs.get(strings.TrimPrefix(rec.Header().Get("Location"), "/api/jobs/"))
+		return job
+	}
+	waitFor := func(job *auditJob, status string) auditJob {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if snapshot := jobs.snapshot(job); snapshot.Status == status {
+				return snapshot
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("Job should reach %s, got %+v", status, jobs.snapshot(job))
+		return auditJob{}
+	}
+
+	first := submit()
+	second := submit()
+	third := submit()
+	waitFor(first, jobRunning)
+	if snapshot := jobs.snapshot(third); snapshot.Status != jobQueued || snapshot.QueuePosition != 2 {
+		t.Fatalf("Third job should be queued at position 2, got %+v", snapshot)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("DELETE", "/api/jobs/"+second.ID, nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Should cancel the job, got %d", rec.Code)
+	}
+	waitFor(second, jobCancelled)
+	if snapshot := jobs.snapshot(third); snapshot.QueuePosition != 1 {
+		t.Fatalf("Third job should move up after a cancellation, got %+v", snapshot)
+	}
+
+	close(release)
+	if snapshot := waitFor(first, jobCompleted); snapshot.ReportHash != "abc123" {
+		t.Fatalf("Completed job should record the report hash, got %+v", snapshot)
+	}
+	waitFor(third, jobCompleted)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/jobs/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Unknown job should return 404, got %d", rec.Code)
+	}
+}
+
+// TestJobScope tests that job options are validated and passed to the audit
+func TestJobScope(t *testing.T) {
+	withTestUpload(t)
+
+	oldJobs, oldEngine := jobs, engine
+	t.Cleanup(func() { jobs, engine = oldJobs, oldEngine })
+	engine, _ = aegong.NewEngine(aegong.Config{})
+	defer engine.Close()
+	jobs = newJobStore(context.Background())
+
+	scopes := make(chan aegong.AuditScope, 1)
+	jobs.audit = func(ctx context.Context, filename string, opts auditOptions) (*aegong.AuditReport, error) {
+		scopes <- opts.Scope
+		return &aegong.AuditReport{AgentHash: "abc123"}, nil
+	}
+
+	rec := httptest.NewRecorder()
+	createJobHandler(rec, httptest.NewRequest("POST", "/api/jobs", strings.NewReader(`{"filename":"agent.py","options":{"vectors":["T42"]}}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "T42") {
+		t.Fatalf("Unknown vectors should be rejected, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	createJobHandler(rec, httptest.NewRequest("POST", "/api/jobs", strings.NewReader(`{"filename":"agent.py","options":{"vectors":["T4","T6"],"skip_shields":true}}`)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Should accept a scoped job, got %d: %s", rec.Code, rec.Body)
+	}
+	select {
+	case scope := <-scopes:
+		if len(scope.Vectors) != 2 || scope.Vectors[1] != "T6" || !scope.SkipShields {
+			t.Fatalf("Audit should receive the job's options, got %+v", scope)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Job should run")
+	}
+}
+
+// TestJobResume tests that jobs interrupted by a shutdown keep their partial
+// results and resume from their checkpoint on the next start
+func TestJobResume(t *testing.T) {
+	withTestUpload(t)
+
+	oldJobs, oldEngine := jobs, engine
+	t.Cleanup(func() { jobs, engine = oldJobs, oldEngine })
+	engine, _ = aegong.NewEngine(aegong.Config{CheckpointDir: "audit_checkpoints"})
+	defer engine.Close()
+
+	// The server stops while the first job's SHIELD phase is starting
+	baseCtx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+	jobs = newJobStore(baseCtx)
+	jobs.dir = "audit_jobs"
+	jobs.audit = func(ctx context.Context, filename string, opts auditOptions) (*aegong.AuditReport, error) {
+		ctx = aegong.WithObserver(ctx, &aegong.AuditObserver{PhaseStarted: func(phase string) {
+			if phase == aegong.PhaseShield {
+				shutdown()
+			}
+		}})
+		return engine.AuditAgentWithOptions(ctx, "uploads/"+filename, aegong.AuditOptions{Scope: opts.Scope, CheckpointID: opts.Checkpoint})
+	}
+	job, err := jobs.submit("agent.py", "", auditOptions{Scope: aegong.AuditScope{Vectors: []string{"T4"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs.wait(context.Background())
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/jobs/{id}/partial", jobPartialHandler).Methods("GET")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/jobs/"+job.ID+"/partial", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"completed_phases":["static","dynamic"]`) {
+		t.Fatalf("Interrupted job should have a partial report, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// On the next start the job resumes with its ID, options and checkpoint
+	resumed := make(chan auditOptions, 1)
+	jobs = newJobStore(context.Background())
+	jobs.dir = "audit_jobs"
+	jobs.audit = func(ctx context.Context, filename string, opts auditOptions) (*aegong.AuditReport, error) {
+		resumed <- opts
+		return engine.AuditAgentWithOptions(ctx, "uploads/"+filename, aegong.AuditOptions{Scope: opts.Scope, CheckpointID: opts.Checkpoint})
+	}
+	jobs.restore()
+	jobs.wait(context.Background())
+
+	select {
+	case opts := <-resumed:
+		if opts.Checkpoint != job.ID || len(opts.Scope.Vectors) != 1 {
+			t.Fatalf("Resumed job should keep its ID and options, got %+v", opts)
+		}
+	default:
+		t.Fatal("Saved job should resume")
+	}
+	resumedJob, ok := jobs.get(job.ID)
+	if !ok || jobs.snapshot(resumedJob).Status != jobCompleted {
+		t.Fatalf("Resumed job should complete, got %+v", resumedJob)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/jobs/"+job.ID+"/partial", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Finished jobs should have no partial report, got %d", rec.Code)
+	}
+	if engine.HasCheckpoint(job.ID) {
+		t.Error("Finished jobs should leave no checkpoint")
+	}
+}
+
+// TestJobRestore tests that saved jobs are restored after a restart rather
+// than vanishing
+func TestJobRestore(t *testing.T) {
+	withTestUpload(t)
+
+	oldJobs, oldEngine := jobs, engine
+	t.Cleanup(func() { jobs, engine = oldJobs, oldEngine })
+	engine, _ = aegong.NewEngine(aegong.Config{CheckpointDir: "audit_checkpoints"})
+	defer engine.Close()
+
+	// The last server left a queued, a running and a completed job
+	previous := newJobStore(context.Background())
+	previous.dir = "audit_jobs"
+	finished := time.Now()
+	saved := []*auditJob{
+		{ID: "queued", Filename: "agent.py", Status: jobQueued, CreatedAt: finished, options: jobOptions{Narration: NarrationExecutive}},
+		{ID: "running", Filename: "agent.py", Status: jobRunning, Phase: aegong.PhaseDynamic, CreatedAt: finished},
+		{ID: "completed", Filename: "agent.py", Status: jobCompleted, ReportHash: "abc123", CreatedAt: finished, FinishedAt: &finished},
+	}
+	for _, job := range saved {
+		previous.saveLocked(job)
+	}
+
+	narrations := make(chan string, 3)
+	jobs = newJobStore(context.Background())
+	jobs.dir = "audit_jobs"
+	jobs.audit = func(ctx context.Context, filename string, opts auditOptions) (*aegong.AuditReport, error) {
+		narrations <- opts.Narration
+		return &aegong.AuditReport{AgentHash: "def456"}, nil
+	}
+	jobs.restore()
+	jobs.wait(context.Background())
+
+	if len(narrations) != 1 || <-narrations != NarrationExecutive {
+		t.Fatal("Only the queued job should run again, with its options")
+	}
+	states := make(map[string]auditJob)
+	for _, job := range saved {
+		restored, ok := jobs.get(job.ID)
+		if !ok {
+			t.Fatalf("Job %s should be restored", job.ID)
+		}
+		states[job.ID] = jobs.snapshot(restored)
+	}
+	if states["queued"].Status != jobCompleted || states["queued"].ReportHash != "def456" {
+		t.Errorf("Queued jobs should run after a restart, got %+v", states["queued"])
+	}
+	if states["running"].Status != jobFailed || !strings.Contains(states["running"].Error, "restart") || states["running"].Phase != "" {
+		t.Errorf("Running jobs without a checkpoint should fail, got %+v", states["running"])
+	}
+	if states["completed"].Status != jobCompleted || states["completed"].ReportHash != "abc123" {
+		t.Errorf("Finished jobs should keep their results, got %+v", states["completed"])
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/jobs/{id}", cancelJobHandler).Methods("DELETE")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("DELETE", "/api/jobs/completed", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Cancelling a restored finished job should do nothing, got %d", rec.Code)
+	}
+
+	// Every job's final state is saved for the next restart
+	data, err := os.ReadFile(filepath.Join("audit_jobs", "running.json"))
+	if err != nil || !strings.Contains(string(data), `"status":"failed"`) {
+		t.Errorf("Failed jobs should be saved, got %s", data)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -83,15 +84,25 @@ func parseEnvFile(envFilePath string) (map[string]string, error) {
 	}
 	defer file.Close()
 
+	keys, err := ParseKeys(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading .env file: %v", err)
+	}
+	return keys, nil
+}
+
+// ParseKeys reads NAME=value lines, as in a .env file. Blank lines and
+// comments are skipped and surrounding quotes are removed from values.
+func ParseKeys(r io.Reader) (map[string]string, error) {
 	keys := make(map[string]string)
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		line := scanner.Text()
 		line = strings.TrimSpace(line)
 
-		// Skip empty lines
-		if line == "" {
+		// Skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
 
@@ -113,7 +124,7 @@ func parseEnvFile(envFilePath string) (map[string]string, error) {
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading .env file: %v", err)
+		return nil, err
 	}
 
 	return keys, nil
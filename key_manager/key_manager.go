@@ -15,29 +15,67 @@ import (
 	"path/filepath"
 	
 	"sync"
+	"time"
 )
 
 // APIKeyStore represents the structure of the encrypted key file
 type APIKeyStore struct {
-	Keys map[string]string `json:"keys"`
+	Keys     map[string]string      `json:"keys"`
+	Metadata map[string]KeyMetadata `json:"metadata,omitempty"`
+}
+
+// KeyMetadata records when a key was added and when it should be rotated.
+// Zero times are unknown: keys from older key files have no metadata, and
+// keys without an expiry never expire.
+type KeyMetadata struct {
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Expired reports whether the key has expired at the given time
+func (m KeyMetadata) Expired(now time.Time) bool {
+	return !m.ExpiresAt.IsZero() && !now.Before(m.ExpiresAt)
 }
 
 // KeyManager handles secure loading and decryption of API keys
 type KeyManager struct {
-	keyFilePath string
-	passphrase  string
-	keyCache    map[string]string
-	mutex       sync.RWMutex
+	keyFilePath  string
+	passphrase   string
+	keyCache     map[string][]byte // Values point into arena
+	keyMetadata  map[string]KeyMetadata
+	arena        *keyArena
+	allowExpired bool
+	mutex        sync.RWMutex
+
+	idleTimeout time.Duration
+	lockMemory  bool
+	idleTimer   *time.Timer
+	lastUsed    time.Time
+	evicted     bool // Values were zeroed while idle and are decrypted again on use
+	wiped       bool
 }
 
-// NewKeyManager creates a new key manager instance
+// NewKeyManager creates a new key manager instance with the package's
+// IdleTimeout and LockMemory. Expired keys are refused unless
+// AEGONG_ALLOW_EXPIRED_KEYS=1.
 func NewKeyManager(keyFilePath string) *KeyManager {
 	return &KeyManager{
-		keyFilePath: keyFilePath,
-		keyCache:    make(map[string]string),
+		keyFilePath:  keyFilePath,
+		keyCache:     make(map[string][]byte),
+		keyMetadata:  make(map[string]KeyMetadata),
+		allowExpired: os.Getenv("AEGONG_ALLOW_EXPIRED_KEYS") == "1",
+		idleTimeout:  IdleTimeout,
+		lockMemory:   LockMemory,
 	}
 }
 
+// AllowExpired sets whether GetKey returns keys past their expiry
+func (km *KeyManager) AllowExpired(allow bool) {
+	km.mutex.Lock()
+	defer km.mutex.Unlock()
+	km.allowExpired = allow
+}
+
 // Initialize sets up the key manager with the passphrase
 func (km *KeyManager) Initialize(passphrase string) error {
 	if passphrase == "" {
@@ -52,6 +90,15 @@ func (km *KeyManager) LoadKeys() error {
 	km.mutex.Lock()
 	defer km.mutex.Unlock()
 
+	if err := km.load(); err != nil {
+		return err
+	}
+	km.touch()
+	return nil
+}
+
+// load decrypts the key file into a new arena, wiping the old one
+func (km *KeyManager) load() error {
 	// Check if key file exists
 	if _, err := os.Stat(km.keyFilePath); os.IsNotExist(err) {
 		return fmt.Errorf("key file not found: %s", km.keyFilePath)
@@ -68,29 +115,142 @@ func (km *KeyManager) LoadKeys() error {
 	if err != nil {
 		return fmt.Errorf("failed to decrypt key file: %v", err)
 	}
+	defer clear(decryptedData)
 
 	// Parse JSON
-	var keyStore APIKeyStore
-	if err := json.Unmarshal(decryptedData, &keyStore); err != nil {
+	values, metadata, arena, err := decodeKeys(decryptedData, km.lockMemory)
+	if err != nil {
 		return fmt.Errorf("failed to parse key file: %v", err)
 	}
 
 	// Store in cache
-	km.keyCache = keyStore.Keys
+	km.arena.wipe()
+	km.arena = arena
+	km.keyCache = values
+	km.keyMetadata = metadata
+	km.evicted = false
+	km.wiped = false
 	return nil
 }
 
-// GetKey retrieves a key by name
+// touch records a use of the keys and restarts the idle timer
+func (km *KeyManager) touch() {
+	km.lastUsed = time.Now()
+	if km.idleTimeout <= 0 {
+		return
+	}
+	if km.idleTimer == nil {
+		km.idleTimer = time.AfterFunc(km.idleTimeout, km.evictIdle)
+	} else {
+		km.idleTimer.Reset(km.idleTimeout)
+	}
+}
+
+// evictIdle zeroes the decrypted keys once they have gone unused for the
+// idle timeout; the next GetKey decrypts the key file again
+func (km *KeyManager) evictIdle() {
+	km.mutex.Lock()
+	defer km.mutex.Unlock()
+
+	if km.evicted || km.wiped || time.Since(km.lastUsed) < km.idleTimeout {
+		return
+	}
+	km.arena.wipe()
+	km.arena = nil
+	for name := range km.keyCache {
+		km.keyCache[name] = nil
+	}
+	km.evicted = true
+}
+
+// Wipe zeroes the decrypted keys and forgets the passphrase. The manager
+// must be initialized and loaded again before it can be used.
+func (km *KeyManager) Wipe() {
+	km.mutex.Lock()
+	defer km.mutex.Unlock()
+
+	if km.idleTimer != nil {
+		km.idleTimer.Stop()
+	}
+	km.arena.wipe()
+	km.arena = nil
+	km.keyCache = make(map[string][]byte)
+	km.keyMetadata = make(map[string]KeyMetadata)
+	km.passphrase = ""
+	km.evicted = false
+	km.wiped = true
+}
+
+// GetKey retrieves a key by name, decrypting the key file again if the keys
+// were evicted while idle. The string is a copy that can't be zeroed; keys
+// that needn't be strings should be used through WithKey instead.
 func (km *KeyManager) GetKey(keyName string) (string, error) {
-	km.mutex.RLock()
-	defer km.mutex.RUnlock()
+	km.mutex.Lock()
+	defer km.mutex.Unlock()
+
+	key, err := km.lookup(keyName)
+	if err != nil {
+		return "", err
+	}
+	return string(key), nil
+}
+
+// WithKey calls fn with the key's value in the arena, decrypting the key
+// file again if the keys were evicted while idle. The keys can't be evicted
+// or wiped while fn runs, and fn must not keep the value, which is zeroed
+// with the others afterwards.
+func (km *KeyManager) WithKey(keyName string, fn func(key []byte) error) error {
+	for {
+		km.mutex.Lock()
+		_, err := km.lookup(keyName)
+		km.mutex.Unlock()
+		if err != nil {
+			return err
+		}
+
+		// Read locked, so the key can be used by several callers at once
+		km.mutex.RLock()
+		if key, exists := km.keyCache[keyName]; exists && !km.evicted && !km.wiped {
+			defer km.mutex.RUnlock()
+			return fn(key)
+		}
+		// Evicted or wiped in between; look it up again
+		km.mutex.RUnlock()
+	}
+}
+
+// lookup returns a key's value in the arena. The caller holds the mutex.
+func (km *KeyManager) lookup(keyName string) ([]byte, error) {
+	if km.wiped {
+		return nil, errors.New("keys have been wiped")
+	}
+	if km.evicted {
+		if err := km.load(); err != nil {
+			return nil, fmt.Errorf("failed to decrypt keys again: %v", err)
+		}
+	}
 
 	// Check if key exists in cache
 	if key, exists := km.keyCache[keyName]; exists {
+		if metadata := km.keyMetadata[keyName]; metadata.Expired(time.Now()) && !km.allowExpired {
+			return nil, fmt.Errorf("key %s expired on %s", keyName, metadata.ExpiresAt.Format("2006-01-02"))
+		}
+		km.touch()
 		return key, nil
 	}
 
-	return "", fmt.Errorf("key not found: %s", keyName)
+	return nil, fmt.Errorf("key not found: %s", keyName)
+}
+
+// GetKeyMetadata returns when a key was created and when it expires
+func (km *KeyManager) GetKeyMetadata(keyName string) (KeyMetadata, error) {
+	km.mutex.RLock()
+	defer km.mutex.RUnlock()
+
+	if _, exists := km.keyCache[keyName]; !exists {
+		return KeyMetadata{}, fmt.Errorf("key not found: %s", keyName)
+	}
+	return km.keyMetadata[keyName], nil
 }
 
 // GetAllKeys returns all available key names
@@ -105,11 +265,28 @@ func (km *KeyManager) GetAllKeys() []string {
 	return keys
 }
 
-// CreateKeyFile creates a new encrypted key file
+// CreateKeyFile creates a new encrypted key file, recording each key as
+// created now without an expiry
 func CreateKeyFile(keyFilePath, passphrase string, keys map[string]string) error {
+	return CreateKeyFileWithMetadata(keyFilePath, passphrase, keys, nil)
+}
+
+// CreateKeyFileWithMetadata creates a new encrypted key file with the given
+// creation and expiry times. Keys missing from metadata are recorded as
+// created now.
+func CreateKeyFileWithMetadata(keyFilePath, passphrase string, keys map[string]string, metadata map[string]KeyMetadata) error {
 	// Create key store
 	keyStore := APIKeyStore{
-		Keys: keys,
+		Keys:     keys,
+		Metadata: make(map[string]KeyMetadata, len(keys)),
+	}
+	now := time.Now().UTC()
+	for name := range keys {
+		keyMetadata := metadata[name]
+		if keyMetadata.CreatedAt.IsZero() {
+			keyMetadata.CreatedAt = now
+		}
+		keyStore.Metadata[name] = keyMetadata
 	}
 
 	// Convert to JSON
@@ -120,6 +297,7 @@ func CreateKeyFile(keyFilePath, passphrase string, keys map[string]string) error
 
 	// Encrypt the data
 	encryptedData, err := encrypt(jsonData, passphrase)
+	clear(jsonData)
 	if err != nil {
 		return fmt.Errorf("failed to encrypt data: %v", err)
 	}
@@ -141,14 +319,20 @@ func CreateKeyFile(keyFilePath, passphrase string, keys map[string]string) error
 // Helper functions for encryption/decryption
 
 // createHash creates a SHA-256 hash from a passphrase
-func createHash(key string) []byte {
-	hash := sha256.Sum256([]byte(key))
+func createHash(key []byte) []byte {
+	hash := sha256.Sum256(key)
 	return hash[:]
 }
 
 // encrypt encrypts data using AES-256-GCM
 func encrypt(data []byte, passphrase string) ([]byte, error) {
-	key := createHash(passphrase)
+	return seal(data, []byte(passphrase))
+}
+
+// seal encrypts data using AES-256-GCM under the hash of a key
+func seal(data, secret []byte) ([]byte, error) {
+	key := createHash(secret)
+	defer clear(key)
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -170,7 +354,13 @@ func encrypt(data []byte, passphrase string) ([]byte, error) {
 
 // decrypt decrypts data using AES-256-GCM
 func decrypt(data []byte, passphrase string) ([]byte, error) {
-	key := createHash(passphrase)
+	return open(data, []byte(passphrase))
+}
+
+// open decrypts data sealed under a key
+func open(data, secret []byte) ([]byte, error) {
+	key := createHash(secret)
+	defer clear(key)
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -188,4 +378,15 @@ func decrypt(data []byte, passphrase string) ([]byte, error) {
 
 	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
 	return gcm.Open(nil, nonce, ciphertext, nil)
-}
\ No newline at end of file
+}
+
+// Encrypt encrypts data with AES-256-GCM under a key retrieved from a key
+// file, such as the value WithKey lends
+func Encrypt(data []byte, key []byte) ([]byte, error) {
+	return seal(data, key)
+}
+
+// Decrypt decrypts data produced by Encrypt
+func Decrypt(data []byte, key []byte) ([]byte, error) {
+	return open(data, key)
+}
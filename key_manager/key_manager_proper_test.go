@@ -3,7 +3,9 @@ package key_manager
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 // TestKeyManagerInitialization tests the initialization of the key manager
@@ -214,3 +216,185 @@ func TestKeyManagerEmptyFile(t *testing.T) {
 		t.Fatal("Loading keys from an empty file should return an error")
 	}
 }
+
+// TestKeyManagerExpiry tests key metadata and refusing expired keys
+func TestKeyManagerExpiry(t *testing.T) {
+	keyFilePath := filepath.Join(t.TempDir(), "test.key")
+	expired := time.Now().Add(-time.Hour).UTC()
+	metadata := map[string]KeyMetadata{"old": {ExpiresAt: expired}}
+	if err := CreateKeyFileWithMetadata(keyFilePath, "test-passphrase", map[string]string{"old": "v1", "new": "v2"}, metadata); err != nil {
+		t.Fatalf("Failed to create key file: %v", err)
+	}
+
+	t.Setenv("AEGONG_ALLOW_EXPIRED_KEYS", "")
+	keyManager := NewKeyManager(keyFilePath)
+	keyManager.Initialize("test-passphrase")
+	if err := keyManager.LoadKeys(); err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+
+	oldMetadata, err := keyManager.GetKeyMetadata("old")
+	if err != nil || !oldMetadata.ExpiresAt.Equal(expired) || oldMetadata.CreatedAt.IsZero() || !oldMetadata.Expired(time.Now()) {
+		t.Fatalf("Should record the expiry and creation time, got %+v, %v", oldMetadata, err)
+	}
+	if newMetadata, _ := keyManager.GetKeyMetadata("new"); !newMetadata.ExpiresAt.IsZero() || newMetadata.Expired(time.Now()) {
+		t.Fatalf("Keys without an expiry should never expire, got %+v", newMetadata)
+	}
+	if _, err := keyManager.GetKeyMetadata("missing"); err == nil {
+		t.Fatal("Metadata of missing keys should be an error")
+	}
+
+	if _, err := keyManager.GetKey("old"); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("Expired keys should be refused, got %v", err)
+	}
+	if value, err := keyManager.GetKey("new"); err != nil || value != "v2" {
+		t.Fatalf("Unexpired keys should be returned, got %q, %v", value, err)
+	}
+
+	t.Setenv("AEGONG_ALLOW_EXPIRED_KEYS", "1")
+	keyManager = NewKeyManager(keyFilePath)
+	keyManager.Initialize("test-passphrase")
+	keyManager.LoadKeys()
+	if value, err := keyManager.GetKey("old"); err != nil || value != "v1" {
+		t.Fatalf("AEGONG_ALLOW_EXPIRED_KEYS=1 should allow expired keys, got %q, %v", value, err)
+	}
+}
+
+// TestKeyManagerWipe tests that wiping zeroes the decrypted keys
+func TestKeyManagerWipe(t *testing.T) {
+	keyFilePath := filepath.Join(t.TempDir(), "test.key")
+	if err := CreateKeyFile(keyFilePath, "test-passphrase", map[string]string{"openai": "sk-secret", "quoted": "a\"b\\u00e9"}); err != nil {
+		t.Fatalf("Failed to create key file: %v", err)
+	}
+	keyManager := NewKeyManager(keyFilePath)
+	keyManager.Initialize("test-passphrase")
+	if err := keyManager.LoadKeys(); err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+	if value, _ := keyManager.GetKey("quoted"); value != "a\"b\\u00e9" {
+		t.Fatalf("Escaped values should decode, got %q", value)
+	}
+
+	buffer := keyManager.arena.buffer
+	if !strings.Contains(string(buffer), "sk-secret") {
+		t.Fatal("Keys should be held in the arena")
+	}
+	keyManager.Wipe()
+	for _, b := range buffer {
+		if b != 0 {
+			t.Fatalf("Wipe should zero the keys, got %q", buffer)
+		}
+	}
+	if _, err := keyManager.GetKey("openai"); err == nil || !strings.Contains(err.Error(), "wiped") {
+		t.Fatalf("Wiped keys should not be returned, got %v", err)
+	}
+
+	keyManager.Initialize("test-passphrase")
+	if err := keyManager.LoadKeys(); err != nil {
+		t.Fatalf("Should load again after a wipe: %v", err)
+	}
+	if value, _ := keyManager.GetKey("openai"); value != "sk-secret" {
+		t.Fatalf("Reloaded keys should be returned, got %q", value)
+	}
+}
+
+// TestKeyManagerIdleEviction tests evicting unused keys and decrypting them again
+func TestKeyManagerIdleEviction(t *testing.T) {
+	keyFilePath := filepath.Join(t.TempDir(), "test.key")
+	if err := CreateKeyFile(keyFilePath, "test-passphrase", map[string]string{"openai": "sk-secret"}); err != nil {
+		t.Fatalf("Failed to create key file: %v", err)
+	}
+	keyManager := NewKeyManager(keyFilePath)
+	keyManager.idleTimeout = 20 * time.Millisecond
+	keyManager.Initialize("test-passphrase")
+	if err := keyManager.LoadKeys(); err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+	buffer := keyManager.arena.buffer
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		keyManager.mutex.RLock()
+		evicted := keyManager.evicted
+		keyManager.mutex.RUnlock()
+		if evicted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Idle keys should be evicted")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if strings.Contains(string(buffer), "sk-secret") {
+		t.Fatal("Evicted keys should be zeroed")
+	}
+	if names := keyManager.GetAllKeys(); len(names) != 1 || names[0] != "openai" {
+		t.Fatalf("Key names should survive eviction, got %v", names)
+	}
+	if value, err := keyManager.GetKey("openai"); err != nil || value != "sk-secret" {
+		t.Fatalf("Evicted keys should be decrypted again on use, got %q, %v", value, err)
+	}
+	keyManager.Wipe()
+}
+
+// TestKeyManagerLockMemory tests loading keys into locked memory
+func TestKeyManagerLockMemory(t *testing.T) {
+	keyFilePath := filepath.Join(t.TempDir(), "test.key")
+	if err := CreateKeyFile(keyFilePath, "test-passphrase", map[string]string{"openai": "sk-secret"}); err != nil {
+		t.Fatalf("Failed to create key file: %v", err)
+	}
+	keyManager := NewKeyManager(keyFilePath)
+	keyManager.lockMemory = true
+	keyManager.Initialize("test-passphrase")
+	if err := keyManager.LoadKeys(); err != nil {
+		t.Skipf("Memory cannot be locked here: %v", err)
+	}
+	if !keyManager.arena.locked {
+		t.Fatal("The arena should be locked")
+	}
+	if value, _ := keyManager.GetKey("openai"); value != "sk-secret" {
+		t.Fatalf("Locked keys should be returned, got %q", value)
+	}
+	keyManager.Wipe()
+}
+
+// TestKeyManagerWithKey tests lending keys without copying them out of the arena
+func TestKeyManagerWithKey(t *testing.T) {
+	keyFilePath := filepath.Join(t.TempDir(), "test.key")
+	if err := CreateKeyFile(keyFilePath, "test-passphrase", map[string]string{"storage": "at-rest-secret"}); err != nil {
+		t.Fatalf("Failed to create key file: %v", err)
+	}
+	keyManager := NewKeyManager(keyFilePath)
+	keyManager.Initialize("test-passphrase")
+	if err := keyManager.LoadKeys(); err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+
+	var lent []byte
+	var sealed []byte
+	err := keyManager.WithKey("storage", func(key []byte) (err error) {
+		lent = key
+		sealed, err = Encrypt([]byte("report"), key)
+		return err
+	})
+	if err != nil || string(lent) != "at-rest-secret" {
+		t.Fatalf("Should lend the key, got %q, %v", lent, err)
+	}
+	if plain, err := Decrypt(sealed, []byte("at-rest-secret")); err != nil || string(plain) != "report" {
+		t.Fatalf("Should decrypt with the same key, got %q, %v", plain, err)
+	}
+	if err := keyManager.WithKey("missing", func([]byte) error { return nil }); err == nil {
+		t.Fatal("Should not lend a missing key")
+	}
+
+	// The lent value is the arena's, so wiping zeroes it
+	keyManager.Wipe()
+	for _, b := range lent {
+		if b != 0 {
+			t.Fatalf("Wipe should zero the lent key, got %q", lent)
+		}
+	}
+	if err := keyManager.WithKey("storage", func([]byte) error { return nil }); err == nil || !strings.Contains(err.Error(), "wiped") {
+		t.Fatalf("Wiped keys should not be lent, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package key_manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Decrypted keys are held back to back in one buffer that can be locked into
+// RAM and zeroed, instead of in strings the garbage collector may copy and
+// never clears. Values returned by GetKey are copies owned by the caller; the
+// passphrase and values callers keep are outside this protection. WithKey
+// lends a value without copying it.
+
+// Protection applied to key managers created after it is set
+var (
+	IdleTimeout time.Duration // Evict decrypted keys after this long unused; zero keeps them
+	LockMemory  bool          // mlock decrypted keys so they are never written to swap
+)
+
+// keyArena holds the decrypted key values
+type keyArena struct {
+	buffer []byte
+	locked bool
+}
+
+// newKeyArena allocates a buffer for size bytes of key values, locking it
+// into memory if asked
+func newKeyArena(size int, lock bool) (*keyArena, error) {
+	arena := &keyArena{buffer: make([]byte, size)}
+	if lock && size > 0 {
+		if err := mlock(arena.buffer); err != nil {
+			return nil, fmt.Errorf("failed to lock key memory: %v", err)
+		}
+		arena.locked = true
+	}
+	return arena, nil
+}
+
+// wipe zeroes the buffer and unlocks it
+func (a *keyArena) wipe() {
+	if a == nil {
+		return
+	}
+	clear(a.buffer)
+	if a.locked {
+		munlock(a.buffer)
+		a.locked = false
+	}
+	a.buffer = nil
+}
+
+// decodeKeys parses a decrypted key file, copying each key value into an
+// arena and zeroing the parser's copies
+func decodeKeys(plaintext []byte, lock bool) (map[string][]byte, map[string]KeyMetadata, *keyArena, error) {
+	var keyStore struct {
+		Keys     map[string]json.RawMessage `json:"keys"`
+		Metadata map[string]KeyMetadata     `json:"metadata"`
+	}
+	if err := json.Unmarshal(plaintext, &keyStore); err != nil {
+		return nil, nil, nil, err
+	}
+	defer func() {
+		for _, raw := range keyStore.Keys {
+			clear(raw)
+		}
+	}()
+
+	// Quoted values are never shorter than the values themselves
+	size := 0
+	for _, raw := range keyStore.Keys {
+		size += len(raw)
+	}
+	arena, err := newKeyArena(size, lock)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	values := make(map[string][]byte, len(keyStore.Keys))
+	offset := 0
+	for name, raw := range keyStore.Keys {
+		n, err := unquoteKey(raw, arena.buffer[offset:])
+		if err != nil {
+			arena.wipe()
+			return nil, nil, nil, fmt.Errorf("key %s: %v", name, err)
+		}
+		values[name] = arena.buffer[offset : offset+n : offset+n]
+		offset += n
+	}
+
+	metadata := keyStore.Metadata
+	if metadata == nil {
+		metadata = make(map[string]KeyMetadata)
+	}
+	return values, metadata, arena, nil
+}
+
+// unquoteKey writes the JSON string raw into dst and returns its length.
+// Values with escapes are decoded through a string, which cannot be zeroed;
+// API keys rarely have any.
+func unquoteKey(raw json.RawMessage, dst []byte) (int, error) {
+	if len(raw) < 2 || raw[0] != '"' || raw[len(raw)-1] != '"' {
+		return 0, fmt.Errorf("value is not a string")
+	}
+	inner := raw[1 : len(raw)-1]
+	for _, c := range inner {
+		if c == '\\' {
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return 0, err
+			}
+			return copy(dst, value), nil
+		}
+	}
+	return copy(dst, inner), nil
+}
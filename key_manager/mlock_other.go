@@ -0,0 +1,13 @@
+//go:build !unix
+
+package key_manager
+
+import "errors"
+
+func mlock(b []byte) error {
+	return errors.New("locking memory is not supported on this platform")
+}
+
+func munlock(b []byte) error {
+	return nil
+}
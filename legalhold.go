@@ -0,0 +1,421 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// A legal hold keeps a report, an upload or a range of the audit log exactly
+// as it is while litigation or an investigation needs it: retention cleanup
+// passes it over and the deletion APIs refuse it, until an admin releases
+// the hold. Placing and releasing holds is recorded in the audit log.
+
+// Where legal holds are kept
+var legalHoldsPath = filepath.Join("reports", "legal_holds.json")
+
+// Longest reason kept with a legal hold
+const maxHoldReason = 1000
+
+var errLegalHold = errors.New("under legal hold")
+
+// Serializes changes to the legal holds file
+var holdsMutex sync.Mutex
+
+// holdRequest places a legal hold
+type holdRequest struct {
+	Kind   string     `json:"kind"`
+	Target string     `json:"target"`
+	From   *time.Time `json:"from"`
+	To     *time.Time `json:"to"`
+	Reason string     `json:"reason"`
+}
+
+// loadLegalHolds reads the legal holds in force
+func loadLegalHolds() ([]aegong.LegalHold, error) {
+	data, err := readStored(legalHoldsPath)
+	if os.IsNotExist(err) {
+		return []aegong.LegalHold{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var holds []aegong.LegalHold
+	if err := json.Unmarshal(data, &holds); err != nil {
+		return nil, fmt.Errorf("failed to parse legal holds: %v", err)
+	}
+	return holds, nil
+}
+
+func saveLegalHolds(holds []aegong.LegalHold) error {
+	data, _ := json.MarshalIndent(holds, "", "  ")
+	return writeStored(legalHoldsPath, data)
+}
+
+// isHeld reports whether a report or upload is under legal hold. Holds that
+// can't be read count as holding everything.
+func isHeld(kind, target string) bool {
+	holds, err := loadLegalHolds()
+	if err != nil {
+		return true
+	}
+	for _, hold := range holds {
+		if hold.Kind == kind && hold.Target == target {
+			return true
+		}
+	}
+	return false
+}
+
+// auditLogHeld returns whether an audit log entry written at a time is
+// under legal hold
+func auditLogHeld() (func(time.Time) bool, error) {
+	holds, err := loadLegalHolds()
+	if err != nil {
+		return nil, err
+	}
+	return func(ts time.Time) bool {
+		for _, hold := range holds {
+			if hold.Kind == aegong.HoldAuditLog && !ts.Before(*hold.From) && (hold.To == nil || !ts.After(*hold.To)) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// validateHold checks a hold request, normalizing its target
+func validateHold(request *holdRequest) error {
+	request.Reason = strings.TrimSpace(request.Reason)
+	if request.Reason == "" {
+		return fmt.Errorf("a legal hold needs a reason")
+	}
+	if len(request.Reason) > maxHoldReason {
+		return fmt.Errorf("reason must be at most %d bytes", maxHoldReason)
+	}
+
+	switch request.Kind {
+	case aegong.HoldReport:
+		if _, err := reportFile(request.Target); err != nil {
+			return err
+		}
+	case aegong.HoldUpload:
+		if _, err := uploadPath(request.Target); err != nil {
+			return err
+		}
+	case aegong.HoldAuditLog:
+		if request.Target != "" {
+			return fmt.Errorf("audit log holds take from and to, not a target")
+		}
+		if request.From == nil {
+			return fmt.Errorf("audit log holds need from")
+		}
+		if request.To != nil && request.To.Before(*request.From) {
+			return fmt.Errorf("to is before from")
+		}
+		return nil
+	default:
+		return fmt.Errorf("kind must be %q, %q or %q, got %q", aegong.HoldReport, aegong.HoldUpload, aegong.HoldAuditLog, request.Kind)
+	}
+	if request.From != nil || request.To != nil {
+		return fmt.Errorf("only audit log holds take from and to")
+	}
+	return nil
+}
+
+// logHoldChange records a legal hold being placed or released
+func logHoldChange(r *http.Request, action string, hold aegong.LegalHold, principal Principal) {
+	if engine == nil || engine.AuditLog() == nil {
+		return
+	}
+	engine.AuditLog().LogLegalHold(&aegong.LegalHoldChange{
+		Action:        action,
+		Hold:          hold,
+		Actor:         principal.Name,
+		Role:          string(principal.Role),
+		Timestamp:     time.Now(),
+		CorrelationID: aegong.CorrelationID(r.Context()),
+	})
+}
+
+// logDeletion records a report or upload deleted through the API
+func logDeletion(r *http.Request, kind, target string, principal Principal) {
+	if engine == nil || engine.AuditLog() == nil {
+		return
+	}
+	engine.AuditLog().LogDeletion(&aegong.RecordDeletion{
+		Kind:          kind,
+		Target:        target,
+		Actor:         principal.Name,
+		Role:          string(principal.Role),
+		Timestamp:     time.Now(),
+		CorrelationID: aegong.CorrelationID(r.Context()),
+	})
+}
+
+// legalHoldsHandler lists the legal holds in force
+func legalHoldsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireRole(w, r, RoleAdmin, RoleAuditor); !ok {
+		return
+	}
+	holds, err := loadLegalHolds()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(holds)
+}
+
+// placeHoldHandler lets admins place a legal hold
+func placeHoldHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	var request holdRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		http.Error(w, "Request body must be JSON with \"kind\", \"reason\" and \"target\" or \"from\" and \"to\"", http.StatusBadRequest)
+		return
+	}
+	if err := validateHold(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to place legal hold: %v", err), http.StatusInternalServerError)
+		return
+	}
+	hold := aegong.LegalHold{
+		ID:       hex.EncodeToString(id),
+		Kind:     request.Kind,
+		Target:   request.Target,
+		From:     request.From,
+		To:       request.To,
+		Reason:   request.Reason,
+		PlacedBy: principal.Name,
+		PlacedAt: time.Now().UTC(),
+	}
+
+	holdsMutex.Lock()
+	holds, err := loadLegalHolds()
+	if err == nil {
+		os.MkdirAll("reports", 0755)
+		err = saveLegalHolds(append(holds, hold))
+	}
+	holdsMutex.Unlock()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save legal hold: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logHoldChange(r, "placed", hold, principal)
+	logf(r.Context(), "Legal hold %s placed on %s %s by %s", hold.ID, hold.Kind, hold.Target, principal.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hold)
+}
+
+// releaseHoldHandler lets admins release a legal hold
+func releaseHoldHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+
+	holdsMutex.Lock()
+	holds, err := loadLegalHolds()
+	var released *aegong.LegalHold
+	if err == nil {
+		for i, hold := range holds {
+			if hold.ID == id {
+				released = &hold
+				err = saveLegalHolds(append(holds[:i:i], holds[i+1:]...))
+				break
+			}
+		}
+	}
+	holdsMutex.Unlock()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to release legal hold: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if released == nil {
+		http.Error(w, "Legal hold not found", http.StatusNotFound)
+		return
+	}
+	logHoldChange(r, "released", *released, principal)
+	logf(r.Context(), "Legal hold %s on %s %s released by %s", released.ID, released.Kind, released.Target, principal.Name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteReportHandler lets admins delete a report with its anchor receipt,
+// comments, archive flag, voice narrations and upload, unless the report is
+// under legal hold. An upload under legal hold or being audited is kept.
+func deleteReportHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin)
+	if !ok {
+		return
+	}
+	hash := mux.Vars(r)["hash"]
+	reportPath, err := reportFile(hash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	agentHash, err := removeReport(hash, reportPath)
+	switch {
+	case errors.Is(err, errLegalHold):
+		http.Error(w, fmt.Sprintf("Report %s is under legal hold", hash), http.StatusConflict)
+		return
+	case os.IsNotExist(err):
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to delete report: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := unarchiveReport(hash); err != nil {
+		log.Printf("Warning: Failed to drop the archive flag of deleted report %s: %v", hash, err)
+	}
+	voice := removeVoiceReports(hash)
+	logDeletion(r, aegong.HoldReport, hash, principal)
+	logf(r.Context(), "Report %s deleted by %s with %d voice reports", hash, principal.Name, voice)
+
+	if agentHash != "" {
+		deleteReportUpload(r, hash, agentHash, principal)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeReport deletes a report with its anchor receipt and comments unless
+// it is under legal hold, returning its full agent hash. The holds lock is
+// held from the check to the removal, so a hold placed meanwhile either
+// stops the deletion or finds the report gone.
+func removeReport(hash, reportPath string) (string, error) {
+	holdsMutex.Lock()
+	defer holdsMutex.Unlock()
+	if isHeld(aegong.HoldReport, hash) {
+		return "", errLegalHold
+	}
+	// The full agent hash finds the upload the report was made from
+	var agentHash string
+	if data, err := readStored(reportPath); err == nil {
+		if report, err := parseReport(data); err == nil {
+			agentHash = report.AgentHash
+		}
+	}
+	if err := os.Remove(reportPath); err != nil {
+		return "", err
+	}
+	os.Remove(anchorPath(hash))
+	os.Remove(commentsPath(hash))
+	return agentHash, nil
+}
+
+// writeReportFile saves a report, or its anchor or comments, unless the
+// report is under legal hold. Like removeReport it holds holdsMutex from the
+// check to the write, so a hold placed meanwhile can't be overwritten.
+func writeReportFile(hash, path string, data []byte) error {
+	holdsMutex.Lock()
+	defer holdsMutex.Unlock()
+	if isHeld(aegong.HoldReport, hash) {
+		return errLegalHold
+	}
+	return writeStored(path, data)
+}
+
+// deleteReportUpload deletes the upload a deleted report was made from,
+// keeping it if it is under legal hold or being audited
+func deleteReportUpload(r *http.Request, hash, agentHash string, principal Principal) {
+	filename, _ := findUpload(agentHash)
+	if filename == "" || activeUploads.busy(filename) {
+		return
+	}
+	switch err := purgeUpload(filename, "report deleted by "+principal.Name); {
+	case errors.Is(err, errLegalHold):
+		log.Printf("Info: Kept upload %s of deleted report %s, which is under legal hold", filename, hash)
+	case err != nil:
+		log.Printf("Warning: Failed to delete upload %s of deleted report %s: %v", filename, hash, err)
+	default:
+		logDeletion(r, aegong.HoldUpload, filename, principal)
+		logf(r.Context(), "Upload %s deleted with report %s", filename, hash)
+	}
+}
+
+// removeVoiceReports deletes a report's voice narrations in every profile,
+// with their metadata, returning how many were removed
+func removeVoiceReports(hash string) int {
+	dir := "voice_reports"
+	if voiceManager != nil {
+		if configured := voiceManager.Config().OutputDir; configured != "" {
+			dir = configured
+		}
+		voiceManager.forgetReport(hash)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "aegong_report_"+hash+"*.wav"))
+	removed := 0
+	for _, file := range files {
+		if os.Remove(file) == nil {
+			removed++
+		}
+		os.Remove(voiceMetadataPath(file))
+	}
+	return removed
+}
+
+// deleteUploadHandler lets admins delete an upload, keeping its hash as a
+// retention purge does, unless it is under legal hold or being audited
+func deleteUploadHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin)
+	if !ok {
+		return
+	}
+	filename := mux.Vars(r)["filename"]
+	if _, err := uploadPath(filename); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if activeUploads.busy(filename) {
+		http.Error(w, fmt.Sprintf("Upload %s is being audited", filename), http.StatusConflict)
+		return
+	}
+	err := purgeUpload(filename, "deleted by "+principal.Name)
+	if errors.Is(err, errLegalHold) {
+		http.Error(w, fmt.Sprintf("Upload %s is under legal hold", filename), http.StatusConflict)
+		return
+	}
+	if os.IsNotExist(err) {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete upload: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logDeletion(r, aegong.HoldUpload, filename, principal)
+	logf(r.Context(), "Upload %s deleted by %s", filename, principal.Name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
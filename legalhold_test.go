@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// TestLegalHolds tests that held reports and uploads can't be deleted until
+// the hold is released, and that holds and deletions are audit logged
+func TestLegalHolds(t *testing.T) {
+	withTestReports(t, &aegong.AuditReport{AgentHash: "abcdef0123"})
+	auditLogPath := filepath.Join(t.TempDir(), "audit.log")
+	engine, _ = aegong.NewEngine(aegong.Config{AuditLogPath: auditLogPath})
+	oldTokens := apiTokens
+	t.Cleanup(func() { apiTokens = oldTokens })
+	apiTokens, _ = loadAPITokens("alice:admin:admin,ci:auditor:audit")
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/admin/holds", legalHoldsHandler).Methods("GET")
+	router.HandleFunc("/api/admin/holds", placeHoldHandler).Methods("POST")
+	router.HandleFunc("/api/admin/holds/{id}", releaseHoldHandler).Methods("DELETE")
+	router.HandleFunc("/api/admin/reports/{hash}", deleteReportHandler).Methods("DELETE")
+	router.HandleFunc("/api/admin/uploads/{filename}", deleteUploadHandler).Methods("DELETE")
+	request := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, r)
+		return rec
+	}
+	place := func(body string) aegong.LegalHold {
+		t.Helper()
+		rec := request("POST", "/api/admin/holds", "admin", body)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Admins should place holds, got %d: %s", rec.Code, rec.Body)
+		}
+		var hold aegong.LegalHold
+		json.Unmarshal(rec.Body.Bytes(), &hold)
+		return hold
+	}
+
+	if rec := request("POST", "/api/admin/holds", "audit", `{"kind":"report","target":"abcdef01","reason":"Case 42"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("Auditors should not place holds, got %d", rec.Code)
+	}
+	reportHold := place(`{"kind":"report","target":"abcdef01","reason":"Case 42"}`)
+	uploadHold := place(`{"kind":"upload","target":"agent.py","reason":"Case 42"}`)
+
+	rec := request("GET", "/api/admin/holds", "audit", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), reportHold.ID) || !strings.Contains(rec.Body.String(), uploadHold.ID) {
+		t.Fatalf("Auditors should list holds, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if rec := request("DELETE", "/api/admin/reports/abcdef01", "admin", ""); rec.Code != http.StatusConflict {
+		t.Fatalf("Deleting a held report should return 409, got %d", rec.Code)
+	}
+	if rec := request("DELETE", "/api/admin/uploads/agent.py", "admin", ""); rec.Code != http.StatusConflict {
+		t.Fatalf("Deleting a held upload should return 409, got %d", rec.Code)
+	}
+	if err := purgeUpload("agent.py", "expired"); !errors.Is(err, errLegalHold) {
+		t.Fatalf("Retention should not purge a held upload, got %v", err)
+	}
+	reportPath := filepath.Join("reports", "report_abcdef01.json")
+	saved, _ := readStored(reportPath)
+	if err := writeReportFile("abcdef01", reportPath, []byte(`{"agent_hash":"abcdef0123"}`)); !errors.Is(err, errLegalHold) {
+		t.Fatalf("Audits should not overwrite a held report, got %v", err)
+	}
+	if data, _ := readStored(reportPath); string(data) != string(saved) {
+		t.Fatal("Held report should be kept as saved")
+	}
+
+	if rec := request("DELETE", "/api/admin/holds/"+reportHold.ID, "audit", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("Auditors should not release holds, got %d", rec.Code)
+	}
+	for _, hold := range []aegong.LegalHold{reportHold, uploadHold} {
+		if rec := request("DELETE", "/api/admin/holds/"+hold.ID, "admin", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("Admins should release holds, got %d: %s", rec.Code, rec.Body)
+		}
+	}
+	if rec := request("DELETE", "/api/admin/holds/"+reportHold.ID, "admin", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("Releasing a released hold should return 404, got %d", rec.Code)
+	}
+
+	if rec := request("DELETE", "/api/admin/reports/abcdef01", "admin", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("Released report should be deleted, got %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := os.Stat(filepath.Join("reports", "report_abcdef01.json")); !os.IsNotExist(err) {
+		t.Fatal("Deleted report should be gone")
+	}
+	if rec := request("DELETE", "/api/admin/reports/abcdef01", "admin", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("Deleting a missing report should return 404, got %d", rec.Code)
+	}
+	if rec := request("DELETE", "/api/admin/uploads/agent.py", "admin", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("Released upload should be deleted, got %d: %s", rec.Code, rec.Body)
+	}
+
+	data, _ := os.ReadFile(auditLogPath)
+	log := string(data)
+	if strings.Count(log, `"event":"legal_hold"`) != 4 || strings.Count(log, `"event":"deletion"`) != 2 {
+		t.Errorf("Should audit log holds and deletions, got:\n%s", log)
+	}
+}
+
+// TestValidateHold tests which legal hold requests are accepted
+func TestValidateHold(t *testing.T) {
+	from := time.Now().Add(-time.Hour)
+	before := from.Add(-time.Hour)
+	valid := []holdRequest{
+		{Kind: aegong.HoldReport, Target: "abcdef01", Reason: "Case 42"},
+		{Kind: aegong.HoldUpload, Target: "agent.py", Reason: "Case 42"},
+		{Kind: aegong.HoldAuditLog, From: &from, Reason: "Case 42"},
+		{Kind: aegong.HoldAuditLog, From: &before, To: &from, Reason: "Case 42"},
+	}
+	for _, request := range valid {
+		if err := validateHold(&request); err != nil {
+			t.Errorf("Hold %+v should be accepted, got %v", request, err)
+		}
+	}
+
+	invalid := []holdRequest{
+		{Kind: aegong.HoldReport, Target: "abcdef01"},
+		{Kind: aegong.HoldReport, Target: "../etc", Reason: "Case 42"},
+		{Kind: aegong.HoldUpload, Target: "../agent.py", Reason: "Case 42"},
+		{Kind: aegong.HoldUpload, Target: "agent.py", From: &from, Reason: "Case 42"},
+		{Kind: aegong.HoldAuditLog, Reason: "Case 42"},
+		{Kind: aegong.HoldAuditLog, From: &from, To: &before, Reason: "Case 42"},
+		{Kind: aegong.HoldAuditLog, Target: "abcdef01", From: &from, Reason: "Case 42"},
+		{Kind: "everything", Reason: "Case 42"},
+	}
+	for _, request := range invalid {
+		if err := validateHold(&request); err == nil {
+			t.Errorf("Hold %+v should be rejected", request)
+		}
+	}
+}
@@ -1,19 +1,26 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
+	"Agent_Auditor/pkg/aegong"
+
 	"github.com/gorilla/mux"
-	"github.com/gorilla/websocket"
 	"github.com/joho/godotenv"
 )
 
@@ -42,6 +49,80 @@ var requirementsTxt []byte
 //go:embed static/index.html
 var indexHTML []byte
 
+// OpenAPI specification of the API, from which the web UI's client is generated
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// registerAPIRoutes adds the routes documented in openapi.json
+func registerAPIRoutes(r *mux.Router) {
+	r.HandleFunc("/api/upload", uploadHandler).Methods("POST")
+	r.HandleFunc("/api/audit/{filename}", auditHandler).Methods("POST")
+	r.HandleFunc("/api/audit-url", auditURLHandler).Methods("POST")
+	r.HandleFunc("/api/validate/{filename}", validateHandler).Methods("GET")
+	r.HandleFunc("/api/jobs", createJobHandler).Methods("POST")
+	r.HandleFunc("/api/jobs", listJobsHandler).Methods("GET")
+	r.HandleFunc("/api/jobs/{id}", jobHandler).Methods("GET")
+	r.HandleFunc("/api/jobs/{id}", cancelJobHandler).Methods("DELETE")
+	r.HandleFunc("/api/jobs/{id}/partial", jobPartialHandler).Methods("GET")
+	r.HandleFunc("/api/admin/components", componentsHandler).Methods("GET")
+	r.HandleFunc("/api/admin/components/{name}", updateComponentHandler).Methods("PATCH")
+	r.HandleFunc("/api/admin/detectors/test", testDetectorHandler).Methods("POST")
+	r.HandleFunc("/api/admin/status", statusHandler).Methods("GET")
+	r.HandleFunc("/api/admin/archive", exportArchiveHandler).Methods("GET")
+	r.HandleFunc("/api/admin/archive", importArchiveHandler).Methods("POST")
+	r.HandleFunc("/api/admin/voice", voiceConfigHandler).Methods("GET")
+	r.HandleFunc("/api/admin/voice", updateVoiceConfigHandler).Methods("PATCH")
+	r.HandleFunc("/api/admin/feedback", feedbackSummaryHandler).Methods("GET")
+	r.HandleFunc("/api/admin/rulesets", rulesetsHandler).Methods("GET")
+	r.HandleFunc("/api/admin/quotas", adminQuotasHandler).Methods("GET")
+	r.HandleFunc("/api/admin/notifications", notificationRulesHandler).Methods("GET")
+	r.HandleFunc("/api/admin/notifications", createNotificationRuleHandler).Methods("POST")
+	r.HandleFunc("/api/admin/notifications/{id}", updateNotificationRuleHandler).Methods("PUT")
+	r.HandleFunc("/api/admin/notifications/{id}", deleteNotificationRuleHandler).Methods("DELETE")
+	r.HandleFunc("/api/admin/holds", legalHoldsHandler).Methods("GET")
+	r.HandleFunc("/api/admin/holds", placeHoldHandler).Methods("POST")
+	r.HandleFunc("/api/admin/holds/{id}", releaseHoldHandler).Methods("DELETE")
+	r.HandleFunc("/api/admin/reports/{hash}", deleteReportHandler).Methods("DELETE")
+	r.HandleFunc("/api/admin/uploads/{filename}", deleteUploadHandler).Methods("DELETE")
+	r.HandleFunc("/api/reports", reportsHandler).Methods("GET")
+	r.HandleFunc("/api/search", searchHandler).Methods("GET")
+	r.HandleFunc("/api/stats", statsHandler).Methods("GET")
+	r.HandleFunc("/api/posture", postureHandler).Methods("GET")
+	r.HandleFunc("/api/agents", agentsHandler).Methods("GET")
+	r.HandleFunc("/api/agents", createAgentHandler).Methods("POST")
+	r.HandleFunc("/api/agents/{id}", agentHandler).Methods("GET")
+	r.HandleFunc("/api/agents/{id}", updateAgentHandler).Methods("PUT")
+	r.HandleFunc("/api/agents/{id}", deleteAgentHandler).Methods("DELETE")
+	r.HandleFunc("/api/detectors", detectorsHandler).Methods("GET")
+	r.HandleFunc("/api/quota", quotaHandler).Methods("GET")
+	r.HandleFunc("/api/report/{hash}", reportHandler).Methods("GET")
+	r.HandleFunc("/api/report/{hash}", deleteReportHandler).Methods("DELETE")
+	r.HandleFunc("/api/report/{hash}/archive", archiveReportHandler).Methods("POST")
+	r.HandleFunc("/api/report/{hash}/archive", restoreReportHandler).Methods("DELETE")
+	r.HandleFunc("/api/report/{hash}/export", exportReportHandler).Methods("GET")
+	r.HandleFunc("/api/report/{hash}/anchor", reportAnchorHandler).Methods("GET")
+	r.HandleFunc("/api/report/{hash}/attestation", reportAttestationHandler).Methods("GET")
+	r.HandleFunc("/api/attestation/key", attestationKeyHandler).Methods("GET")
+	r.HandleFunc("/api/admission/validate", admissionHandler).Methods("POST")
+	r.HandleFunc("/api/report/{hash}/feedback", feedbackHandler).Methods("POST")
+	r.HandleFunc("/api/report/{hash}/rescore", rescoreHandler).Methods("POST")
+	r.HandleFunc("/api/report/{hash}/share", shareReportHandler).Methods("POST")
+	r.HandleFunc("/api/report/{hash}/comments", commentsHandler).Methods("GET")
+	r.HandleFunc("/api/report/{hash}/comments", addCommentHandler).Methods("POST")
+	r.HandleFunc("/api/shared/{token}", sharedReportHandler).Methods("GET")
+	r.HandleFunc("/api/voice/{hash}", voiceReportHandler).Methods("GET")
+	r.HandleFunc("/graphql", graphqlHandler).Methods("GET", "POST")
+	r.HandleFunc("/api/openapi.json", openAPIHandler).Methods("GET")
+	r.HandleFunc("/api/events", sseHandler).Methods("GET")
+}
+
+// openAPIHandler serves the OpenAPI specification of the API
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
 // Helper functions for embedded assets
 func getStaticFileSystem() http.FileSystem {
 	staticFS, err := fs.Sub(staticFiles, "static")
@@ -71,71 +152,14 @@ func writeEmbeddedFile(content []byte, filePath string) error {
 	return nil
 }
 
-// Core data structures
-type ThreatVector int
-
-const (
-	T1_REASONING_HIJACK ThreatVector = iota
-	T2_OBJECTIVE_CORRUPTION
-	T3_MEMORY_POISONING
-	T4_UNAUTHORIZED_ACTION
-	T5_RESOURCE_MANIPULATION
-	T6_IDENTITY_SPOOFING
-	T7_TRUST_MANIPULATION
-	T8_OVERSIGHT_SATURATION
-	T9_GOVERNANCE_EVASION
-)
-
-type ThreatSeverity int
-
-const (
-	LOW ThreatSeverity = iota
-	MEDIUM
-	HIGH
-	CRITICAL
-)
-
-type ThreatDetection struct {
-	Vector       ThreatVector           `json:"vector"`
-	VectorName   string                 `json:"vector_name"`
-	Severity     ThreatSeverity         `json:"severity"`
-	SeverityName string                 `json:"severity_name"`
-	Confidence   float64                `json:"confidence"`
-	Evidence     []string               `json:"evidence"`
-	Timestamp    time.Time              `json:"timestamp"`
-	Details      map[string]interface{} `json:"details"`
-}
-
-type AuditReport struct {
-	AgentHash       string                 `json:"agent_hash"`
-	AgentName       string                 `json:"agent_name"`
-	Timestamp       time.Time              `json:"timestamp"`
-	Threats         []ThreatDetection      `json:"threats"`
-	ShieldResults   map[string]interface{} `json:"shield_results"`
-	OverallRisk     float64                `json:"overall_risk"`
-	RiskLevel       string                 `json:"risk_level"`
-	Recommendations []string               `json:"recommendations"`
-	AegongMessage   string                 `json:"aegong_message"`
-	Details         map[string]interface{} `json:"details,omitempty"`
-}
-
-type WebSocketMessage struct {
-	Type    string      `json:"type"`
-	Data    interface{} `json:"data"`
-	Message string      `json:"message"`
-}
-
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-}
-
 var (
-	engine       *AEGONGEngine
+	engine       *aegong.Engine
 	voiceManager *VoiceInferenceManager
 )
 
+// How long the server waits for in-flight requests on shutdown
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Load .env file if it exists (for development environment)
 	if err := godotenv.Load(); err != nil {
@@ -159,9 +183,183 @@ func main() {
 		log.Printf("Info: Running in development mode - some features may be limited")
 	}
 
+	// Load API tokens for role-restricted operations
+	if err := initAPITokens(); err != nil {
+		log.Fatalf("Failed to load API tokens: %v", err)
+	}
+
+	// Restrict admin and upload endpoints to trusted networks
+	if err := initNetworkPolicy(); err != nil {
+		log.Fatalf("Failed to load network allowlists: %v", err)
+	}
+
+	// Export trace spans to an OpenTelemetry collector
+	if err := initTracing(); err != nil {
+		log.Fatalf("Failed to configure tracing: %v", err)
+	}
+
+	// Origins other than the server's own that may call the API from a browser
+	if err := initAllowedOrigins(); err != nil {
+		log.Fatalf("Failed to load allowed origins: %v", err)
+	}
+
+	// Serve TLS and accept client certificates
+	tlsConfig, err := initTLS()
+	if err != nil {
+		log.Fatalf("Failed to configure TLS: %v", err)
+	}
+
+	// Limit concurrent sandboxes
+	if err := initAuditExecutor(); err != nil {
+		log.Fatalf("Failed to configure audit queue: %v", err)
+	}
+
+	// And what each tenant may use
+	if err := initQuotas(); err != nil {
+		log.Fatalf("Failed to configure quotas: %v", err)
+	}
+
+	// How long raw agent binaries are kept
+	if err := initUploadRetention(); err != nil {
+		log.Fatalf("Failed to configure upload retention: %v", err)
+	}
+
+	// How long audit log entries are kept
+	if err := initAuditLogRetention(); err != nil {
+		log.Fatalf("Failed to configure audit log retention: %v", err)
+	}
+
+	// Which file types may be uploaded
+	if err := initUploadTypes(); err != nil {
+		log.Fatalf("Failed to configure upload file types: %v", err)
+	}
+
+	// How decrypted keys are held in memory
+	if err := initKeyProtection(); err != nil {
+		log.Fatalf("Failed to configure key protection: %v", err)
+	}
+
+	// Encrypt uploads and reports on disk
+	if err := initStorageEncryption(); err != nil {
+		log.Fatalf("Failed to load at-rest encryption key: %v", err)
+	}
+
+	// What shareable report exports leave out
+	if err := initRedactionProfiles(); err != nil {
+		log.Fatalf("Failed to load redaction profiles: %v", err)
+	}
+
+	// Key that signs report share links
+	if err := initShareLinks(); err != nil {
+		log.Fatalf("Failed to configure share links: %v", err)
+	}
+
+	// Key that signs audit attestations for deployment pipelines
+	if err := initAttestations(); err != nil {
+		log.Fatalf("Failed to configure attestations: %v", err)
+	}
+
+	// Whether the server also answers Kubernetes admission reviews
+	if err := initAdmission(); err != nil {
+		log.Fatalf("Failed to configure the admission webhook: %v", err)
+	}
+
+	// Transparency log report hashes are published to
+	if err := initReportAnchoring(); err != nil {
+		log.Fatalf("Failed to configure report anchoring: %v", err)
+	}
+
+	// LLM that writes reports' executive summaries
+	if err := initExecutiveSummary(); err != nil {
+		log.Fatalf("Failed to configure executive summaries: %v", err)
+	}
+
+	// When disk, queue and sandbox status raise warnings
+	if err := initStatusThresholds(); err != nil {
+		log.Fatalf("Failed to configure status thresholds: %v", err)
+	}
+
+	// How much each agent counts towards the organization's risk posture
+	if err := initAgentCriticality(); err != nil {
+		log.Fatalf("Failed to configure agent criticality: %v", err)
+	}
+
+	// Agents the organization knows about and when they are due for audits
+	if err := initAgentRegistry(); err != nil {
+		log.Fatalf("Failed to load the agent registry: %v", err)
+	}
+
+	// Who hears about audits and warnings
+	if err := initNotifications(); err != nil {
+		log.Fatalf("Failed to configure notifications: %v", err)
+	}
+
+	// Where signed detector pattern updates come from
+	if err := initPatternFeed(); err != nil {
+		log.Fatalf("Failed to configure the pattern feed: %v", err)
+	}
+
+	// How detectors missing their canaries are handled
+	if err := initSelfTest(); err != nil {
+		log.Fatalf("Failed to configure the detector self-test: %v", err)
+	}
+
 	// Initialize AEGONG engine
-	engine = NewAEGONGEngine()
-	defer engine.auditLog.Close()
+	config := aegong.DefaultConfig()
+	config.CacheDir = os.Getenv("AEGONG_CACHE_DIR")
+	if mode := os.Getenv("AEGONG_CACHE_MODE"); mode != "" {
+		config.CacheMode = mode
+	}
+	if scoring := os.Getenv("AEGONG_RISK_SCORING"); scoring != "" {
+		config.RiskScoring = scoring
+	}
+	initFeedback(&config)
+	if path := os.Getenv("AEGONG_CUSTOM_VECTORS"); path != "" {
+		if config.CustomVectors, err = aegong.LoadVectorDefinitions(path); err != nil {
+			log.Fatalf("Failed to load custom threat vectors: %v", err)
+		}
+	}
+	if path := os.Getenv("AEGONG_CAPABILITY_POLICY"); path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			config.Policy, err = aegong.ParseCapabilityPolicy(data)
+		}
+		if err != nil {
+			log.Fatalf("Failed to load capability policy: %v", err)
+		}
+	}
+	if path := os.Getenv("AEGONG_SYSCALL_POLICY"); path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			config.SyscallPolicy, err = aegong.ParseSyscallPolicy(data)
+		}
+		if err != nil {
+			log.Fatalf("Failed to load syscall policy: %v", err)
+		}
+	}
+	config.PluginDir = os.Getenv("AEGONG_PLUGIN_DIR")
+	config.StaticOnly = os.Getenv("AEGONG_STATIC_ONLY") == "1"
+	if path := os.Getenv("AEGONG_RULESET_FILE"); path != "" {
+		config.RulesetPath = path
+	}
+	engine, err = aegong.NewEngine(config)
+	if err != nil {
+		log.Fatalf("Failed to initialize AEGONG engine: %v", err)
+	}
+	version := engine.Version()
+	log.Printf("Info: AEGONG engine %s (commit %s, detector config %s)", version.Version, version.Commit, version.ConfigChecksum)
+	if reason := engine.SandboxStatus().StaticOnly; reason != "" {
+		log.Printf("Warning: Agents will be audited without being run: %s", reason)
+	}
+	defer engine.Close()
+	if patternFeed != nil {
+		if err := patternFeed.restore(); err != nil {
+			log.Printf("Warning: Failed to apply the saved pattern feed: %v", err)
+		}
+	}
+	if err := runSelfTest(context.Background()); err != nil {
+		log.Fatalf("Detector self-test failed: %v", err)
+	}
 
 	// Write embedded Python script to filesystem if needed for voice inference
 	if err := writeEmbeddedFile(voiceInferencePy, "voice_inference.py"); err != nil {
@@ -177,7 +375,6 @@ func main() {
 	log.Printf("Info: Target host configuration is now handled by Ansible")
 
 	// Initialize voice inference manager
-	var err error
 	voiceManager, err = NewVoiceInferenceManager("voice_config.json")
 	if err != nil {
 		log.Printf("Warning: Failed to initialize voice inference: %v", err)
@@ -187,15 +384,21 @@ func main() {
 		}
 	}
 
+	// The narration profile reports and voice reports use unless a request asks for another
+	if defaultNarration, err = parseNarration(os.Getenv("AEGONG_NARRATION")); err != nil {
+		log.Fatalf("Invalid AEGONG_NARRATION: %v", err)
+	}
+
 	// Create required directories
 	os.MkdirAll("uploads", 0755)
 	os.MkdirAll("reports", 0755)
 	if voiceManager.IsEnabled() {
-		os.MkdirAll(voiceManager.config.OutputDir, 0755)
+		os.MkdirAll(voiceManager.Config().OutputDir, 0755)
 	}
 
 	// Setup routes
 	r := mux.NewRouter()
+	r.Use(traceRequests)
 
 	// EMBEDDED Static files - serve from embedded filesystem
 	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(getStaticFileSystem())))
@@ -203,18 +406,12 @@ func main() {
 	// EMBEDDED Documentation files - serve from embedded filesystem
 	r.PathPrefix("/docs/").Handler(http.StripPrefix("/docs/", http.FileServer(getDocsifyFileSystem())))
 
-	// Voice reports (if enabled)
-	if voiceManager.IsEnabled() {
-		r.PathPrefix("/voice_reports/").Handler(http.StripPrefix("/voice_reports/", http.FileServer(http.Dir(voiceManager.config.OutputDir))))
-	}
+	// Voice reports, served while voice is enabled
+	r.PathPrefix("/voice_reports/").Handler(http.StripPrefix("/voice_reports/", http.HandlerFunc(voiceFilesHandler)))
 
 	// API routes
+	registerAPIRoutes(r)
 	r.HandleFunc("/", homeHandler).Methods("GET")
-	r.HandleFunc("/api/upload", uploadHandler).Methods("POST")
-	r.HandleFunc("/api/audit/{filename}", auditHandler).Methods("POST")
-	r.HandleFunc("/api/reports", reportsHandler).Methods("GET")
-	r.HandleFunc("/api/report/{hash}", reportHandler).Methods("GET")
-	r.HandleFunc("/api/voice/{hash}", voiceReportHandler).Methods("GET")
 	r.HandleFunc("/ws", websocketHandler)
 
 	// Get port from environment variable or use default
@@ -231,11 +428,73 @@ func main() {
 	if voiceManager.IsEnabled() {
 		fmt.Println("🔊 Voice inference enabled - Aegong can now speak!")
 	}
-	fmt.Printf("🔍 AEGONG Web Interface starting on http://localhost:%s\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	fmt.Printf("🔍 AEGONG Web Interface starting on %s://localhost:%s\n", scheme, port)
+
+	// Request contexts derive from baseCtx so in-flight audits can be
+	// cancelled if they don't finish within the shutdown grace period
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+	jobs = newJobStore(baseCtx)
+	jobs.dir = "audit_jobs"
+	jobs.restore()
+	go runUploadSweeper(baseCtx)
+	go runAuditLogSweeper(baseCtx)
+	go runStatusMonitor(baseCtx)
+	go runPatternFeedUpdates(baseCtx)
+
+	srv := &http.Server{
+		Addr:        ":" + port,
+		Handler:     withRequestID(restrictNetworks(protectCSRF(r))),
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+		TLSConfig:   tlsConfig,
+	}
+	srv.RegisterOnShutdown(closeEventStreams)
+
+	// Shut down gracefully on SIGINT/SIGTERM
+	stopCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			serverErr <- srv.ListenAndServeTLS("", "")
+		} else {
+			serverErr <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Fatal(err)
+	case <-stopCtx.Done():
+	}
+
+	log.Printf("Info: Shutting down, waiting up to %v for in-flight audits", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Warning: Graceful shutdown incomplete, cancelling in-flight audits: %v", err)
+		cancelBase()
+	}
+	if err := jobs.wait(shutdownCtx); err != nil {
+		log.Printf("Warning: Audit jobs still running, cancelling them: %v", err)
+		cancelBase()
+	}
+	wipeKeys()
+
+	// Export the spans of the audits that finished
+	traceCtx, cancelTrace := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelTrace()
+	shutdownTracing(traceCtx)
+	// The deferred engine.Close() waits for cancelled audits to clean up their sandboxes
 }
 
 func homeHandler(w http.ResponseWriter, r *http.Request) {
+	setCSRFCookie(w, r)
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.Write(indexHTML)
 }
@@ -250,29 +509,66 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	// Create unique filename
+	// Create unique filename; the client's name is reduced to safe characters
+	name := safeFilename(handler.Filename)
+	if name == "" {
+		name = "agent"
+	}
 	timestamp := time.Now().Unix()
-	filename := fmt.Sprintf("%d_%s", timestamp, handler.Filename)
-	filePath := filepath.Join("uploads", filename)
+	filename := fmt.Sprintf("%d_%s", timestamp, name)
+	filePath, err := uploadPath(filename)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Save file
-	dst, err := os.Create(filePath)
+	data, err := io.ReadAll(file)
 	if err != nil {
+		http.Error(w, "Error retrieving file", http.StatusBadRequest)
+		return
+	}
+	if !checkFileType(w, r, handler.Filename, data) {
+		return
+	}
+	if !chargeRequestUpload(w, r, int64(len(data))) {
+		return
+	}
+	if err := writeStored(filePath, data); err != nil {
 		http.Error(w, "Error saving file", http.StatusInternalServerError)
 		return
 	}
-	defer dst.Close()
 
-	// Copy file content
-	buffer := make([]byte, 1024)
-	for {
-		n, err := file.Read(buffer)
-		if n == 0 || err != nil {
-			break
+	// Keep any detached signature next to the upload for the audit to verify
+	bundle, err := signatureBundleFromForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if bundle != nil {
+		bundleJSON, _ := json.Marshal(bundle)
+		if err := writeStored(filePath+signatureBundleSuffix, bundleJSON); err != nil {
+			http.Error(w, "Error saving signature", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// And any agent manifest, to check the declared capabilities against
+	manifest, err := agentManifestFromForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if manifest != nil {
+		manifestJSON, _ := json.Marshal(manifest)
+		if err := writeStored(filePath+agentManifestSuffix, manifestJSON); err != nil {
+			http.Error(w, "Error saving manifest", http.StatusInternalServerError)
+			return
 		}
-		dst.Write(buffer[:n])
 	}
 
+	bus.publish(busEvent{Type: eventUploadReceived, Filename: filename})
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"filename": filename,
@@ -280,85 +576,556 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// Suffix of the file holding the signature bundle uploaded with an agent
+const signatureBundleSuffix = ".sig.json"
+
+// signatureBundleFromForm reads the optional "signature", "certificate" and
+// "public_key" files and the "signature_type" and "publisher" fields of an upload
+func signatureBundleFromForm(r *http.Request) (*aegong.SignatureBundle, error) {
+	readFormFile := func(field string) ([]byte, error) {
+		file, _, err := r.FormFile(field)
+		if err == http.ErrMissingFile {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error retrieving %s: %v", field, err)
+		}
+		defer file.Close()
+		return io.ReadAll(io.LimitReader(file, 1<<20))
+	}
+
+	signature, err := readFormFile("signature")
+	if err != nil || signature == nil {
+		return nil, err
+	}
+
+	bundle := &aegong.SignatureBundle{
+		Type:             r.FormValue("signature_type"),
+		Signature:        signature,
+		ClaimedPublisher: r.FormValue("publisher"),
+	}
+	if bundle.Type == "" {
+		bundle.Type = aegong.SignatureCosign
+	}
+	if bundle.Type != aegong.SignatureCosign && bundle.Type != aegong.SignatureGPG {
+		return nil, fmt.Errorf("Unsupported signature type %q", bundle.Type)
+	}
+	if bundle.Certificate, err = readFormFile("certificate"); err != nil {
+		return nil, err
+	}
+	if bundle.PublicKey, err = readFormFile("public_key"); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// loadSignatureBundle returns the signature uploaded with an agent, or nil if there is none
+func loadSignatureBundle(filePath string) (*aegong.SignatureBundle, error) {
+	data, err := readStored(filePath + signatureBundleSuffix)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var bundle aegong.SignatureBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse signature bundle: %v", err)
+	}
+	return &bundle, nil
+}
+
+// Suffix of the file holding the agent manifest uploaded with an agent
+const agentManifestSuffix = ".manifest.json"
+
+// agentManifestFromForm reads the optional "manifest" file of an upload
+func agentManifestFromForm(r *http.Request) (*aegong.AgentManifest, error) {
+	file, _, err := r.FormFile("manifest")
+	if err == http.ErrMissingFile {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving manifest: %v", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving manifest: %v", err)
+	}
+	manifest, err := aegong.ParseAgentManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid manifest: %v", err)
+	}
+	return manifest, nil
+}
+
+// loadAgentManifest returns the manifest uploaded with an agent, or nil if there is none
+func loadAgentManifest(filePath string) (*aegong.AgentManifest, error) {
+	data, err := readStored(filePath + agentManifestSuffix)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return aegong.ParseAgentManifest(data)
+}
+
 func auditHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	filename := vars["filename"]
+	if _, err := uploadPath(filename); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	filePath := filepath.Join("uploads", filename)
+	var opts auditOptions
+	if r.URL.Query().Get("force") == "true" && !authorizeForce(w, r, &opts) {
+		return
+	}
+	narration, ok := requestNarration(w, r)
+	if !ok {
+		return
+	}
+	opts.Narration = narration
 
-	// First, validate if the file is actually an AI agent
-	validationResult, err := ValidateAgent(filePath)
+	// An optional body limits the audit to some vectors and shields
+	var request struct {
+		Options aegong.AuditScope `json:"options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		http.Error(w, "Request body must be JSON with an optional \"options\" object", http.StatusBadRequest)
+		return
+	}
+	if err := engine.CheckScope(request.Options); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.Scope = request.Options
+
+	if !admitRequestAudit(w, r, &opts) {
+		return
+	}
+	defer opts.Release()
+
+	report, err := runPublishedAudit(r.Context(), filename, opts)
+	if err == errAuditQueueFull {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		// If the file is not an agent, return the validation details
+		if notAgent, ok := err.(*notAgentError); ok {
+			response := map[string]interface{}{
+				"error":      "Not an AI agent",
+				"message":    "The uploaded file does not appear to be an AI agent based on our validation criteria.",
+				"validation": notAgent.validation,
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// auditURLHandler downloads an agent from a registry URL and audits it
+func auditURLHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		URL     string            `json:"url"`
+		Force   bool              `json:"force"`
+		Options aegong.AuditScope `json:"options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.URL == "" {
+		http.Error(w, "Request body must be JSON with a \"url\" field", http.StatusBadRequest)
+		return
+	}
+	if err := engine.CheckScope(request.Options); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	narration, ok := requestNarration(w, r)
+	if !ok {
+		return
+	}
+	opts := auditOptions{Scope: request.Options, Narration: narration}
+	if request.Force && !authorizeForce(w, r, &opts) {
+		return
+	}
+
+	if !admitRequestAudit(w, r, &opts) {
+		return
+	}
+	defer opts.Release()
+
+	artifact, err := fetchArtifact(r.Context(), request.URL)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch agent: %v", err), http.StatusBadGateway)
+		return
+	}
+	if !checkFileType(w, r, artifact.filename, artifact.data) {
+		return
+	}
+	if !chargeRequestUpload(w, r, int64(len(artifact.data))) {
+		return
+	}
+
+	// Save the artifact like an upload so it can be re-audited later
+	name := safeFilename(artifact.filename)
+	if name == "" {
+		name = "agent"
+	}
+	filename := fmt.Sprintf("%d_%s", time.Now().Unix(), name)
+	filePath, err := uploadPath(filename)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := writeStored(filePath, artifact.data); err != nil {
+		http.Error(w, "Error saving file", http.StatusInternalServerError)
+		return
+	}
+	opts.Source = artifact.source
+	bus.publish(busEvent{Type: eventUploadReceived, Filename: filename})
+
+	report, err := runPublishedAudit(r.Context(), filename, opts)
+	if err == errAuditQueueFull {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		if notAgent, ok := err.(*notAgentError); ok {
+			response := map[string]interface{}{
+				"error":      "Not an AI agent",
+				"message":    "The downloaded artifact does not appear to be an AI agent based on our validation criteria.",
+				"filename":   filename,
+				"source":     artifact.source,
+				"validation": notAgent.validation,
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// authorizeForce lets admins and auditors bypass the agent validator, writing a 403 for anyone else
+func authorizeForce(w http.ResponseWriter, r *http.Request, opts *auditOptions) bool {
+	principal, ok := requestPrincipal(r)
+	if !ok || !principal.hasRole(RoleAdmin, RoleAuditor) {
+		http.Error(w, "Forcing an audit requires an admin or auditor API token", http.StatusForbidden)
+		return false
+	}
+	opts.Force = true
+	opts.Principal = principal
+	return true
+}
+
+// validateHandler explains whether an upload would pass agent validation without auditing it
+func validateHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	filename := vars["filename"]
+
+	filePath, err := uploadPath(filename)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+
+	plainPath, cleanup, err := plaintextPath(filePath)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read upload: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer cleanup()
+
+	validationResult, err := aegong.ValidateAgent(plainPath)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Agent validation failed: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// If the file is not an agent, return an error
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(validationResult)
+}
+
+// notAgentError is returned by runAudit when validation rejects the upload
+type notAgentError struct {
+	validation *aegong.AgentValidationResult
+}
+
+func (e *notAgentError) Error() string {
+	return "not an AI agent"
+}
+
+// auditOptions controls how runAudit treats an upload
+type auditOptions struct {
+	// Force audits the upload even if validation says it is not an agent
+	Force     bool
+	Principal Principal
+	// Source records where a fetched artifact was downloaded from
+	Source *aegong.ArtifactSource
+	// Ticket is a slot already reserved in auditSlots; runAudit reserves one if nil
+	Ticket *auditTicket
+	// Scope limits the audit to some threat vectors and shields
+	Scope aegong.AuditScope
+	// Narration is the profile the report's message is written in; empty
+	// uses the deployment's default
+	Narration string
+	// Checkpoint saves the audit's progress under this ID so it can be resumed
+	Checkpoint string
+	// Tenant is whose quotas the audit counts against, and the workspace
+	// its notifications are routed by
+	Tenant string
+	// Release frees the audit's place in its tenant's quota of concurrent
+	// audits; jobs call it once they finish
+	Release func()
+	// Replace fails the audit with errLegalHold rather than keep a saved
+	// report that is under legal hold
+	Replace bool
+}
+
+// runPublishedAudit runs an audit requested over HTTP, publishing its
+// progress on the event bus
+func runPublishedAudit(ctx context.Context, filename string, opts auditOptions) (*aegong.AuditReport, error) {
+	auditID := randomID()
+	ctx = withCorrelationID(ctx, auditID)
+	ctx = withTenant(ctx, opts.Tenant)
+	ctx = bus.auditStarted(ctx, auditID, filename)
+	report, err := runAudit(ctx, filename, opts)
+	bus.auditFinished(ctx, auditID, filename, report, err)
+	return report, err
+}
+
+// runAudit validates and audits an uploaded file, then saves the report
+func runAudit(ctx context.Context, filename string, opts auditOptions) (*aegong.AuditReport, error) {
+	// Wait for a sandbox slot so concurrent audits cannot exhaust the host
+	ticket := opts.Ticket
+	if ticket == nil {
+		var err error
+		if ticket, err = auditSlots.enqueue(); err != nil {
+			return nil, err
+		}
+	}
+	defer ticket.release()
+	if err := ticket.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	filePath, err := uploadPath(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the upload from being purged while it is read
+	reported := false
+	release := useUpload(filename)
+	defer func() { release(reported) }()
+
+	// The validator and engine read the binary by path, so encrypted uploads
+	// are decrypted to a private copy for the duration of the audit
+	plainPath, cleanup, err := plaintextPath(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read upload: %v", err)
+	}
+	defer cleanup()
+
+	// First, validate if the file is actually an AI agent
+	validationResult, err := aegong.ValidateAgent(plainPath)
+	if err != nil {
+		return nil, fmt.Errorf("Agent validation failed: %v", err)
+	}
+
+	// If the file is not an agent, return an error unless the caller forced the audit
+	var override *aegong.ValidationOverride
 	if !validationResult.IsAgent {
-		response := map[string]interface{}{
-			"error":      "Not an AI agent",
-			"message":    "The uploaded file does not appear to be an AI agent based on our validation criteria.",
-			"validation": validationResult,
+		if !opts.Force {
+			return nil, &notAgentError{validation: validationResult}
+		}
+
+		override = &aegong.ValidationOverride{
+			Actor:         opts.Principal.Name,
+			Role:          string(opts.Principal.Role),
+			Confidence:    validationResult.Confidence,
+			Reasons:       validationResult.Reasons,
+			Timestamp:     time.Now(),
+			CorrelationID: aegong.CorrelationID(ctx),
+		}
+		logf(ctx, "Warning: %s (%s) forced audit of %s despite failed validation",
+			override.Actor, override.Role, filename)
+		if err := engine.LogValidationOverride(plainPath, override); err != nil {
+			return nil, fmt.Errorf("Failed to record validation override: %v", err)
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(response)
-		return
 	}
 
 	// If confidence is too low, warn but continue
 	if validationResult.Confidence < 0.5 {
-		log.Printf("Warning: Low confidence (%f) that %s is an AI agent",
+		logf(ctx, "Warning: Low confidence (%f) that %s is an AI agent",
 			validationResult.Confidence, filename)
 	}
 
+	bundle, err := loadSignatureBundle(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load signature: %v", err)
+	}
+
+	manifest, err := loadAgentManifest(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load manifest: %v", err)
+	}
+
 	// Run audit
-	report, err := engine.AuditAgent(filePath)
+	report, err := engine.AuditAgentWithOptions(ctx, plainPath, aegong.AuditOptions{
+		Bundle:       bundle,
+		Manifest:     manifest,
+		Scope:        opts.Scope,
+		CheckpointID: opts.Checkpoint,
+		Validation:   validationResult,
+	})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Audit failed: %v", err), http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("Audit failed: %v", err)
 	}
 
-	// Add agent name from filename
+	// Add agent name from the manifest, else the filename
 	report.AgentName = strings.TrimSuffix(filename, filepath.Ext(filename))
+	if manifest != nil && manifest.Name != "" {
+		report.AgentName = manifest.Name
+	}
 
 	// Add validation results to the report
-	if report.Details == nil {
-		report.Details = make(map[string]interface{})
+	report.Validation = validationResult
+	report.ValidationOverride = override
+	report.Source = opts.Source
+
+	// Generate Aegong's message in the requested profile
+	narration := opts.Narration
+	if narration == "" {
+		narration = defaultNarration
+	}
+	narrateReport(report, narration)
+
+	// An LLM writes the executive summary when one is configured
+	if summarizer != nil {
+		if summary, err := summarizer.summarize(ctx, report); err != nil {
+			logf(ctx, "Warning: Failed to write executive summary: %v", err)
+		} else {
+			report.ExecutiveSummary = summary
+		}
 	}
-	report.Details["validation"] = validationResult
-
-	// Generate Aegong's message
-	report.AegongMessage = generateAegongMessage(report)
 
 	// Save report
 	reportPath := filepath.Join("reports", fmt.Sprintf("report_%s.json", report.AgentHash[:8]))
 	reportJSON, _ := json.MarshalIndent(report, "", "  ")
-	os.WriteFile(reportPath, reportJSON, 0644)
+	if err := writeReportFile(report.AgentHash[:8], reportPath, reportJSON); errors.Is(err, errLegalHold) {
+		logf(ctx, "Report %s is under legal hold, keeping the saved report", report.AgentHash[:8])
+		if opts.Replace {
+			return nil, errLegalHold
+		}
+	} else if err == nil {
+		reported = true
+		go anchorReport(report.AgentHash[:8], reportJSON)
+
+		// Speak risky reports now so their voice report is ready when opened
+		if voiceManager.ShouldPregenerate(report) {
+			hash := report.AgentHash[:8]
+			logf(ctx, "Pre-generating voice report for %s (risk %.2f)", hash, report.OverallRisk)
+			voiceManager.GenerateVoiceReportAsync(reportPath, report.Narration, func(audioPath string, err error) {
+				if err != nil {
+					log.Printf("Warning: Failed to pre-generate voice report for %s: %v", hash, err)
+					return
+				}
+				publishVoiceReady(hash, audioPath)
+			})
+		}
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(report)
+	return report, nil
 }
 
-func reportsHandler(w http.ResponseWriter, r *http.Request) {
+// loadReports reads every saved report, skipping any that cannot be read
+func loadReports() ([]*aegong.AuditReport, error) {
 	files, err := filepath.Glob("reports/report_*.json")
 	if err != nil {
-		http.Error(w, "Error reading reports", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
-	var reports []map[string]interface{}
+	var reports []*aegong.AuditReport
 	for _, file := range files {
-		data, err := os.ReadFile(file)
+		data, err := readStored(file)
 		if err != nil {
 			continue
 		}
 
-		var report AuditReport
-		if err := json.Unmarshal(data, &report); err != nil {
+		report, err := parseReport(data)
+		if err != nil {
 			continue
 		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// parseReport decodes a saved report. Report files can be edited or planted
+// on disk, so reports without a full agent hash are rejected before handlers
+// slice it.
+func parseReport(data []byte) (*aegong.AuditReport, error) {
+	var report aegong.AuditReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	if len(report.AgentHash) < 8 {
+		return nil, fmt.Errorf("report has an invalid agent hash %q", report.AgentHash)
+	}
+	return &report, nil
+}
+
+func reportsHandler(w http.ResponseWriter, r *http.Request) {
+	// Archived reports are left out unless asked for
+	show := r.URL.Query().Get("archived")
+	if show != "" && show != "include" && show != "only" {
+		http.Error(w, "archived must be include or only", http.StatusBadRequest)
+		return
+	}
+	saved, err := loadReports()
+	if err != nil {
+		http.Error(w, "Error reading reports", http.StatusInternalServerError)
+		return
+	}
+	archived, err := loadArchivedReports()
+	if err != nil {
+		http.Error(w, "Error reading archived reports", http.StatusInternalServerError)
+		return
+	}
+
+	// Reports from other detector rules are flagged so they can be re-audited
+	current := engine.Version()
 
+	var reports []map[string]interface{}
+	for _, report := range saved {
+		_, isArchived := archived[report.AgentHash[:8]]
+		if show == "" && isArchived || show == "only" && !isArchived {
+			continue
+		}
 		summary := map[string]interface{}{
 			"hash":         report.AgentHash[:8],
 			"agent_name":   report.AgentName,
@@ -366,6 +1133,17 @@ func reportsHandler(w http.ResponseWriter, r *http.Request) {
 			"overall_risk": report.OverallRisk,
 			"risk_level":   report.RiskLevel,
 			"threat_count": len(report.Threats),
+			"outdated":     report.Engine.Outdated(current),
+		}
+		if report.Engine != nil {
+			summary["engine_version"] = report.Engine.Version
+			summary["config_checksum"] = report.Engine.ConfigChecksum
+		}
+		if report.Ruleset != nil {
+			summary["ruleset"] = report.Ruleset.Version
+		}
+		if isArchived {
+			summary["archived"] = true
 		}
 		reports = append(reports, summary)
 	}
@@ -378,16 +1156,46 @@ func reportHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	hash := vars["hash"]
 
-	reportPath := filepath.Join("reports", fmt.Sprintf("report_%s.json", hash))
-	data, err := os.ReadFile(reportPath)
+	reportPath, err := reportFile(hash)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := readStored(reportPath)
+	if os.IsNotExist(err) {
 		http.Error(w, "Report not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read report: %v", err), http.StatusInternalServerError)
+		return
+	}
+	report, err := parseReport(data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to parse report: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// The message is rewritten when another narration profile is asked for
+	narration := reportNarration(report)
+	if r.URL.Query().Get("narration") != "" {
+		var ok bool
+		if narration, ok = requestNarration(w, r); !ok {
+			return
+		}
+		if narration != reportNarration(report) {
+			narrateReport(report, narration)
+			data, _ = json.Marshal(report)
+		}
+	}
 
 	// If voice inference is enabled, generate a voice report asynchronously
 	if voiceManager.IsEnabled() {
-		voiceManager.GenerateVoiceReportAsync(reportPath, nil)
+		voiceManager.GenerateVoiceReportAsync(reportPath, narration, func(audioPath string, err error) {
+			if err == nil {
+				publishVoiceReady(hash, audioPath)
+			}
+		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -407,26 +1215,39 @@ func voiceReportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Voice inference is enabled, using provider: %s", voiceManager.config.Provider)
+	log.Printf("Voice inference is enabled, using provider: %s", voiceManager.Config().Provider)
 
-	// Check if we already have a voice report for this hash
-	audioPath, exists := voiceManager.GetAudioPathForReport(hash)
-	if !exists {
-		log.Printf("No cached voice report found for hash: %s, generating new one", hash)
+	// Check if the report file exists
+	reportPath, err := reportFile(hash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := readStored(reportPath)
+	if err != nil {
+		log.Printf("Report file not found: %s", reportPath)
+		http.Error(w, fmt.Sprintf("Report file not found: %v", err), http.StatusNotFound)
+		return
+	}
 
-		// Check if the report file exists
-		reportPath := filepath.Join("reports", fmt.Sprintf("report_%s.json", hash))
-		if _, err := os.Stat(reportPath); err != nil {
-			log.Printf("Report file not found: %s", reportPath)
-			http.Error(w, fmt.Sprintf("Report file not found: %v", err), http.StatusNotFound)
+	// The narration follows the report's own profile unless another is asked for
+	var report aegong.AuditReport
+	json.Unmarshal(data, &report)
+	narration := reportNarration(&report)
+	if r.URL.Query().Get("narration") != "" {
+		var ok bool
+		if narration, ok = requestNarration(w, r); !ok {
 			return
 		}
+	}
 
-		log.Printf("Found report file: %s", reportPath)
+	// Check if we already have a voice report for this hash
+	audioPath, exists := voiceManager.GetAudioPathForReport(hash, narration)
+	if !exists {
+		log.Printf("No cached %s voice report found for hash: %s, generating new one", narration, hash)
 
 		// Try to generate a new voice report
-		var err error
-		audioPath, err = voiceManager.GenerateVoiceReport(reportPath)
+		audioPath, err = voiceManager.GenerateVoiceReport(reportPath, narration)
 		if err != nil {
 			log.Printf("Failed to generate voice report: %v", err)
 			http.Error(w, fmt.Sprintf("Failed to generate voice report: %v", err), http.StatusInternalServerError)
@@ -434,6 +1255,7 @@ func voiceReportHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		log.Printf("Successfully generated voice report: %s", audioPath)
+		publishVoiceReady(hash, audioPath)
 	} else {
 		log.Printf("Found cached voice report: %s", audioPath)
 	}
@@ -447,11 +1269,20 @@ func voiceReportHandler(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("Voice report file exists: %s", audioPath)
 
-	// Return the audio file path
-	audioURL := fmt.Sprintf("/voice_reports/%s", filepath.Base(audioPath))
-	response := map[string]string{
+	// Return the audio file path and the provider that spoke it
+	audioURL := voiceReportURL(audioPath)
+	response := map[string]interface{}{
 		"audio_url": audioURL,
 	}
+	if metadata := voiceManager.Metadata(audioPath); metadata != nil {
+		response["provider"] = metadata.Provider
+		if metadata.Engine != "" {
+			response["engine"] = metadata.Engine
+		}
+		response["voice"] = metadata.Voice
+		response["fallback"] = metadata.Fallback
+	}
+	response["narration"] = narration
 
 	log.Printf("Returning audio URL: %s", audioURL)
 
@@ -459,36 +1290,18 @@ func voiceReportHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func websocketHandler(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Print("upgrade failed: ", err)
-		return
-	}
-	defer conn.Close()
-
-	// Send welcome message
-	welcomeMsg := WebSocketMessage{
-		Type:    "aegong_message",
-		Message: "🤖 Aegong awakens! The Agent Auditor is ready to inspect your digital minions...",
-	}
-	conn.WriteJSON(welcomeMsg)
-
-	// Keep connection alive and handle messages
-	for {
-		var msg WebSocketMessage
-		err := conn.ReadJSON(&msg)
-		if err != nil {
-			break
-		}
+// voiceReportURL is where a generated voice report is served
+func voiceReportURL(audioPath string) string {
+	return fmt.Sprintf("/voice_reports/%s", filepath.Base(audioPath))
+}
 
-		// Echo back for now
-		conn.WriteJSON(msg)
-	}
+// publishVoiceReady announces a newly generated voice report
+func publishVoiceReady(hash, audioPath string) {
+	bus.publish(busEvent{Type: eventVoiceReady, ReportHash: hash, AudioURL: voiceReportURL(audioPath)})
 }
 
-func generateAegongMessage(report *AuditReport) string {
-	riskLevel := getRiskLevel(report.OverallRisk)
+func generateAegongMessage(report *aegong.AuditReport) string {
+	riskLevel := aegong.RiskLevel(report.OverallRisk)
 	threatCount := len(report.Threats)
 
 	var message string
@@ -517,13 +1330,13 @@ func generateAegongMessage(report *AuditReport) string {
 	// Add threat-specific commentary
 	if threatCount > 0 {
 		message += "\n\n🔍 Aegong's specific concerns include:"
-		threatTypes := make(map[ThreatVector]int)
+		threatTypes := make(map[aegong.ThreatVector]int)
 		for _, threat := range report.Threats {
 			threatTypes[threat.Vector]++
 		}
 
 		for vector, count := range threatTypes {
-			threatName := getThreatName(vector)
+			threatName := aegong.ThreatName(vector)
 			message += fmt.Sprintf("\n• %s (%d instances) - %s", threatName, count, getAegongThreatComment(vector))
 		}
 	}
@@ -533,30 +1346,22 @@ func generateAegongMessage(report *AuditReport) string {
 	return message
 }
 
-func getAegongThreatComment(vector ThreatVector) string {
-	comments := map[ThreatVector]string{
-		T1_REASONING_HIJACK:      "Aegong detects potential mind-bending shenanigans!",
-		T2_OBJECTIVE_CORRUPTION:  "This agent might be having an identity crisis!",
-		T3_MEMORY_POISONING:      "Someone's been tampering with this agent's digital brain!",
-		T4_UNAUTHORIZED_ACTION:   "This agent thinks it's above the law!",
-		T5_RESOURCE_MANIPULATION: "Aegong spotted a digital glutton in action!",
-		T6_IDENTITY_SPOOFING:     "This agent is playing dress-up with other identities!",
-		T7_TRUST_MANIPULATION:    "Aegong senses a digital con artist at work!",
-		T8_OVERSIGHT_SATURATION:  "This agent is trying to overwhelm Aegong's watchful eyes!",
-		T9_GOVERNANCE_EVASION:    "Aegong caught this agent trying to slip past the rules!",
-	}
-	return comments[vector]
-}
-
-func getRiskLevel(risk float64) string {
-	if risk < 0.2 {
-		return "MINIMAL"
-	} else if risk < 0.4 {
-		return "LOW"
-	} else if risk < 0.6 {
-		return "MEDIUM"
-	} else if risk < 0.8 {
-		return "HIGH"
-	}
-	return "CRITICAL"
+func getAegongThreatComment(vector aegong.ThreatVector) string {
+	comments := map[aegong.ThreatVector]string{
+		aegong.T1_REASONING_HIJACK:      "Aegong detects potential mind-bending shenanigans!",
+		aegong.T2_OBJECTIVE_CORRUPTION:  "This agent might be having an identity crisis!",
+		aegong.T3_MEMORY_POISONING:      "Someone's been tampering with this agent's digital brain!",
+		aegong.T4_UNAUTHORIZED_ACTION:   "This agent thinks it's above the law!",
+		aegong.T5_RESOURCE_MANIPULATION: "Aegong spotted a digital glutton in action!",
+		aegong.T6_IDENTITY_SPOOFING:     "This agent is playing dress-up with other identities!",
+		aegong.T7_TRUST_MANIPULATION:    "Aegong senses a digital con artist at work!",
+		aegong.T8_OVERSIGHT_SATURATION:  "This agent is trying to overwhelm Aegong's watchful eyes!",
+		aegong.T9_GOVERNANCE_EVASION:    "Aegong caught this agent trying to slip past the rules!",
+	}
+	if comment, ok := comments[vector]; ok {
+		return comment
+	}
+	// Custom vectors are described by their definition
+	definition, _ := aegong.CustomVector(vector)
+	return definition.Description
 }
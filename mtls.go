@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// clientCert maps a client certificate to the principal it authenticates
+type clientCert struct {
+	identity  string // Certificate common name, or "sha256:" and its fingerprint
+	principal Principal
+}
+
+// Client certificates loaded from AEGONG_CLIENT_CERTS
+var clientCerts []clientCert
+
+// loadClientCerts parses a comma separated list of name:role:identity
+// entries, where identity is a certificate's common name or
+// sha256:<fingerprint>
+func loadClientCerts(spec string) ([]clientCert, error) {
+	var certs []clientCert
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid client certificate entry %q, expected name:role:identity", entry)
+		}
+
+		role := Role(parts[1])
+		switch role {
+		case RoleAdmin, RoleAuditor, RoleViewer:
+		default:
+			return nil, fmt.Errorf("invalid role %q for client certificate %q", parts[1], parts[0])
+		}
+
+		identity := parts[2]
+		if fingerprint, ok := strings.CutPrefix(identity, "sha256:"); ok {
+			fingerprint = strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+			if decoded, err := hex.DecodeString(fingerprint); err != nil || len(decoded) != sha256.Size {
+				return nil, fmt.Errorf("invalid SHA-256 fingerprint for client certificate %q", parts[0])
+			}
+			identity = "sha256:" + fingerprint
+		}
+
+		certs = append(certs, clientCert{
+			identity:  identity,
+			principal: Principal{Name: parts[0], Role: role},
+		})
+	}
+	return certs, nil
+}
+
+// initTLS loads the server certificate and client CA from the environment.
+// It returns nil when the server should serve plain HTTP.
+func initTLS() (*tls.Config, error) {
+	certFile := os.Getenv("AEGONG_TLS_CERT")
+	keyFile := os.Getenv("AEGONG_TLS_KEY")
+	caFile := os.Getenv("AEGONG_CLIENT_CA")
+
+	certs, err := loadClientCerts(os.Getenv("AEGONG_CLIENT_CERTS"))
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) > 0 && caFile == "" {
+		return nil, fmt.Errorf("AEGONG_CLIENT_CERTS requires AEGONG_CLIENT_CA")
+	}
+	if certFile == "" && keyFile == "" {
+		if caFile != "" {
+			return nil, fmt.Errorf("AEGONG_CLIENT_CA requires AEGONG_TLS_CERT and AEGONG_TLS_KEY")
+		}
+		return nil, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %v", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", caFile)
+		}
+		// Browsers and token clients connect without a certificate
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	clientCerts = certs
+	return config, nil
+}
+
+// certificateFingerprint is the hex SHA-256 of a certificate's DER encoding
+func certificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// certificatePrincipal identifies the caller from a client certificate
+// verified against the client CA, recording the access in the audit log
+func certificatePrincipal(r *http.Request) (Principal, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Principal{}, false
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+	fingerprint := certificateFingerprint(leaf)
+
+	for _, c := range clientCerts {
+		if c.identity != "sha256:"+fingerprint && c.identity != leaf.Subject.CommonName {
+			continue
+		}
+		principal := c.principal
+		principal.Certificate = fingerprint
+		logCertificateAccess(r, principal, leaf)
+		return principal, true
+	}
+	log.Printf("Warning: Client certificate %q (sha256:%s) is not mapped to a role", leaf.Subject.CommonName, fingerprint)
+	return Principal{}, false
+}
+
+// logCertificateAccess records which certificate made a request
+func logCertificateAccess(r *http.Request, principal Principal, cert *x509.Certificate) {
+	if engine == nil || engine.AuditLog() == nil {
+		return
+	}
+	engine.AuditLog().LogCertificateAccess(&aegong.CertificateAccess{
+		Actor:         principal.Name,
+		Role:          string(principal.Role),
+		Subject:       cert.Subject.String(),
+		Fingerprint:   principal.Certificate,
+		Method:        r.Method,
+		Path:          r.URL.Path,
+		Timestamp:     time.Now(),
+		CorrelationID: aegong.CorrelationID(r.Context()),
+	})
+}
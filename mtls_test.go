@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// testCertificate issues a certificate for name, signed by parent or self-signed
+func testCertificate(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey, tls.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if isCA {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// writePEM writes a certificate or key as PEM
+func writePEM(t *testing.T, path, kind string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// TestLoadClientCerts tests parsing of the AEGONG_CLIENT_CERTS format
+func TestLoadClientCerts(t *testing.T) {
+	fingerprint := strings.Repeat("AB", 32)
+	certs, err := loadClientCerts("ci:auditor:ci-runner, deploy:admin:sha256:" + fingerprint)
+	if err != nil {
+		t.Fatalf("Failed to parse client certificates: %v", err)
+	}
+	if len(certs) != 2 || certs[0].identity != "ci-runner" {
+		t.Fatalf("Unexpected client certificates %+v", certs)
+	}
+	if certs[1].identity != "sha256:"+strings.ToLower(fingerprint) {
+		t.Fatalf("Fingerprint should be normalized, got %s", certs[1].identity)
+	}
+
+	for _, spec := range []string{"ci:auditor", "ci:root:ci-runner", "ci:admin:sha256:abcd"} {
+		if _, err := loadClientCerts(spec); err == nil {
+			t.Fatalf("Spec %q should be rejected", spec)
+		}
+	}
+}
+
+// TestClientCertificateAuth tests authenticating API clients by certificate
+func TestClientCertificateAuth(t *testing.T) {
+	oldTokens, oldCerts, oldEngine := apiTokens, clientCerts, engine
+	t.Cleanup(func() { apiTokens, clientCerts, engine = oldTokens, oldCerts, oldEngine })
+	apiTokens, _ = loadAPITokens("alice:admin:admin")
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	engine, _ = aegong.NewEngine(aegong.Config{AuditLogPath: logPath})
+
+	dir := t.TempDir()
+	ca, caKey, _ := testCertificate(t, "Test CA", nil, nil, true)
+	server, serverKey, _ := testCertificate(t, "localhost", ca, caKey, false)
+	runnerCert, _, runner := testCertificate(t, "ci-runner", ca, caKey, false)
+	_, _, stranger := testCertificate(t, "stranger", ca, caKey, false)
+	_, _, untrusted := testCertificate(t, "ci-runner", nil, nil, false)
+
+	serverKeyDER, _ := x509.MarshalECPrivateKey(serverKey)
+	writePEM(t, filepath.Join(dir, "server.pem"), "CERTIFICATE", server.Raw)
+	writePEM(t, filepath.Join(dir, "server.key"), "EC PRIVATE KEY", serverKeyDER)
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ca.Raw)
+	t.Setenv("AEGONG_TLS_CERT", filepath.Join(dir, "server.pem"))
+	t.Setenv("AEGONG_TLS_KEY", filepath.Join(dir, "server.key"))
+	t.Setenv("AEGONG_CLIENT_CA", filepath.Join(dir, "ca.pem"))
+	t.Setenv("AEGONG_CLIENT_CERTS", "ci:auditor:ci-runner")
+
+	tlsConfig, err := initTLS()
+	if err != nil || tlsConfig == nil {
+		t.Fatalf("Failed to configure TLS: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if principal, ok := requireRole(w, r, RoleAdmin, RoleAuditor); ok {
+			io.WriteString(w, principal.Name)
+		}
+	}))
+	ts.TLS = tlsConfig
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	get := func(cert *tls.Certificate, token string) (int, string) {
+		config := &tls.Config{RootCAs: roots}
+		if cert != nil {
+			config.Certificates = []tls.Certificate{*cert}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		req, _ := http.NewRequest("GET", ts.URL+"/api/admin/status", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err.Error()
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if status, body := get(&runner, ""); status != http.StatusOK || body != "ci" {
+		t.Fatalf("Mapped certificate should authenticate as ci, got %d %s", status, body)
+	}
+	if status, _ := get(&stranger, ""); status != http.StatusUnauthorized {
+		t.Fatalf("Unmapped certificate should be rejected with 401, got %d", status)
+	}
+	if status, _ := get(nil, ""); status != http.StatusUnauthorized {
+		t.Fatalf("Request without a certificate or token should be rejected with 401, got %d", status)
+	}
+	if status, body := get(nil, "admin"); status != http.StatusOK || body != "alice" {
+		t.Fatalf("Token clients should still connect without a certificate, got %d %s", status, body)
+	}
+	if status, _ := get(&untrusted, ""); status == http.StatusOK {
+		t.Fatal("Certificate from another CA should not be accepted")
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	log := string(data)
+	if !strings.Contains(log, `"event":"certificate_access"`) || !strings.Contains(log, `"actor":"ci"`) ||
+		!strings.Contains(log, certificateFingerprint(runnerCert)) {
+		t.Fatalf("Audit log should record the certificate access, got %s", log)
+	}
+	if strings.Contains(log, `"actor":"alice"`) {
+		t.Fatal("Token requests should not be logged as certificate access")
+	}
+}
+
+// TestInitTLSRequiresCertificate tests that a client CA needs a server certificate
+func TestInitTLSRequiresCertificate(t *testing.T) {
+	t.Setenv("AEGONG_TLS_CERT", "")
+	t.Setenv("AEGONG_TLS_KEY", "")
+	t.Setenv("AEGONG_CLIENT_CERTS", "")
+	t.Setenv("AEGONG_CLIENT_CA", "ca.pem")
+	if _, err := initTLS(); err == nil {
+		t.Fatal("Client CA without a server certificate should be rejected")
+	}
+
+	t.Setenv("AEGONG_CLIENT_CA", "")
+	if config, err := initTLS(); err != nil || config != nil {
+		t.Fatalf("Should serve plain HTTP without TLS settings, got %v %v", config, err)
+	}
+}
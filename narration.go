@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// Narration profiles, the tone of a report's message and voice narration
+const (
+	NarrationAegong       = "aegong"       // Aegong's playful personality
+	NarrationProfessional = "professional" // Neutral findings summary
+	NarrationExecutive    = "executive"    // A terse verdict
+)
+
+var narrationProfiles = []string{NarrationAegong, NarrationProfessional, NarrationExecutive}
+
+// defaultNarration is the deployment's profile, used when a request names none
+var defaultNarration = NarrationAegong
+
+// parseNarration validates a profile name, returning the default for ""
+func parseNarration(name string) (string, error) {
+	if name == "" {
+		return defaultNarration, nil
+	}
+	for _, profile := range narrationProfiles {
+		if strings.EqualFold(name, profile) {
+			return profile, nil
+		}
+	}
+	return "", fmt.Errorf("unknown narration profile %q (want %s)", name, strings.Join(narrationProfiles, ", "))
+}
+
+// requestNarration reads the narration query parameter, writing a 400 for
+// unknown profiles
+func requestNarration(w http.ResponseWriter, r *http.Request) (string, bool) {
+	narration, err := parseNarration(r.URL.Query().Get("narration"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return narration, true
+}
+
+// reportNarration is the profile a report's message was written in; reports
+// saved before profiles existed are Aegong's
+func reportNarration(report *aegong.AuditReport) string {
+	if report.Narration == "" {
+		return NarrationAegong
+	}
+	return report.Narration
+}
+
+// narrateReport writes the report's message in a profile
+func narrateReport(report *aegong.AuditReport, narration string) {
+	switch narration {
+	case NarrationProfessional:
+		report.AegongMessage = generateProfessionalMessage(report)
+	case NarrationExecutive:
+		report.AegongMessage = generateExecutiveMessage(report)
+	default:
+		report.AegongMessage = generateAegongMessage(report)
+	}
+	report.Narration = narration
+}
+
+// vectorCounts counts a report's findings per threat vector, most common first
+func vectorCounts(report *aegong.AuditReport) ([]aegong.ThreatVector, map[aegong.ThreatVector]int) {
+	counts := make(map[aegong.ThreatVector]int)
+	for _, threat := range report.Threats {
+		counts[threat.Vector]++
+	}
+	vectors := make([]aegong.ThreatVector, 0, len(counts))
+	for vector := range counts {
+		vectors = append(vectors, vector)
+	}
+	sort.Slice(vectors, func(i, j int) bool {
+		if counts[vectors[i]] != counts[vectors[j]] {
+			return counts[vectors[i]] > counts[vectors[j]]
+		}
+		return vectors[i] < vectors[j]
+	})
+	return vectors, counts
+}
+
+// generateProfessionalMessage summarises the findings in a neutral tone
+func generateProfessionalMessage(report *aegong.AuditReport) string {
+	riskLevel := aegong.RiskLevel(report.OverallRisk)
+	message := fmt.Sprintf("Audit of '%s' complete. Overall risk is %s (%.2f) with %d findings.",
+		report.AgentName, riskLevel, report.OverallRisk, len(report.Threats))
+
+	vectors, counts := vectorCounts(report)
+	if len(vectors) > 0 {
+		message += "\n\nFindings by threat vector:"
+		for _, vector := range vectors {
+			message += fmt.Sprintf("\n- %s: %d", aegong.ThreatName(vector), counts[vector])
+		}
+	}
+
+	switch riskLevel {
+	case "MINIMAL", "LOW":
+		message += "\n\nNo blocking issues were found. Address the recommendations as part of routine maintenance."
+	case "MEDIUM":
+		message += "\n\nApply the recommended safeguards before deploying this agent."
+	default:
+		message += "\n\nDo not deploy this agent until the recommendations have been addressed and it has been audited again."
+	}
+	return message
+}
+
+// generateExecutiveMessage gives the verdict in a sentence or two
+func generateExecutiveMessage(report *aegong.AuditReport) string {
+	riskLevel := aegong.RiskLevel(report.OverallRisk)
+	verdict := "Approved for deployment."
+	switch riskLevel {
+	case "MEDIUM":
+		verdict = "Deploy only with the recommended safeguards."
+	case "HIGH", "CRITICAL":
+		verdict = "Do not deploy."
+	}
+
+	message := fmt.Sprintf("%s: %s risk, %d findings.", report.AgentName, riskLevel, len(report.Threats))
+	if vectors, _ := vectorCounts(report); len(vectors) > 0 {
+		message += fmt.Sprintf(" Main concern: %s.", aegong.ThreatName(vectors[0]))
+	}
+	return message + " " + verdict
+}
+
+// narratedReportPath returns a copy of the report at path with its message in
+// a profile, or path itself if it is already in that profile
+func narratedReportPath(path, narration string) (string, func(), error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	var report aegong.AuditReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return "", nil, fmt.Errorf("failed to parse report: %v", err)
+	}
+	if reportNarration(&report) == narration {
+		return path, func() {}, nil
+	}
+
+	narrateReport(&report, narration)
+	dir, err := os.MkdirTemp("", "aegong-narration-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create narration directory: %v", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	narratedPath := filepath.Join(dir, filepath.Base(path))
+	data, _ = json.Marshal(report)
+	if err := os.WriteFile(narratedPath, data, 0600); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write narrated report: %v", err)
+	}
+	return narratedPath, cleanup, nil
+}
+
+// voiceAudioName is the audio file of a report's voice narration; Aegong's
+// keeps the name it had before there were profiles
+func voiceAudioName(hash, narration string) string {
+	if narration == NarrationAegong {
+		return fmt.Sprintf("aegong_report_%s.wav", hash)
+	}
+	return fmt.Sprintf("aegong_report_%s_%s.wav", hash, narration)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// TestNarrationProfiles tests writing a report's message in each profile
+func TestNarrationProfiles(t *testing.T) {
+	report := &aegong.AuditReport{
+		AgentName:   "crawler",
+		OverallRisk: 0.7,
+		Threats: []aegong.ThreatDetection{
+			{Vector: aegong.T4_UNAUTHORIZED_ACTION},
+			{Vector: aegong.T4_UNAUTHORIZED_ACTION},
+			{Vector: aegong.T5_RESOURCE_MANIPULATION},
+		},
+	}
+
+	narrateReport(report, NarrationAegong)
+	if !strings.Contains(report.AegongMessage, "Aegong's alarm bells") || report.Narration != NarrationAegong {
+		t.Errorf("Aegong's profile should keep his personality, got %q", report.AegongMessage)
+	}
+
+	narrateReport(report, NarrationProfessional)
+	if !strings.HasPrefix(report.AegongMessage, "Audit of 'crawler' complete. Overall risk is HIGH (0.70) with 3 findings.") ||
+		!strings.Contains(report.AegongMessage, "- Unauthorized Action: 2") || strings.Contains(report.AegongMessage, "🤖") {
+		t.Errorf("The professional profile should summarise the findings neutrally, got %q", report.AegongMessage)
+	}
+
+	narrateReport(report, NarrationExecutive)
+	if report.AegongMessage != "crawler: HIGH risk, 3 findings. Main concern: Unauthorized Action. Do not deploy." {
+		t.Errorf("The executive profile should give a terse verdict, got %q", report.AegongMessage)
+	}
+
+	if profile, err := parseNarration("Executive"); err != nil || profile != NarrationExecutive {
+		t.Errorf("Profile names should be case-insensitive, got %q, %v", profile, err)
+	}
+	if _, err := parseNarration("pirate"); err == nil {
+		t.Error("Unknown profiles should be rejected")
+	}
+	if profile, _ := parseNarration(""); profile != defaultNarration {
+		t.Errorf("No profile should mean the deployment's default, got %q", profile)
+	}
+}
+
+// TestReportNarration tests asking for a saved report in another profile
+func TestReportNarration(t *testing.T) {
+	oldVoice := voiceManager
+	t.Cleanup(func() { voiceManager = oldVoice })
+	voiceManager = &VoiceInferenceManager{}
+
+	report := &aegong.AuditReport{AgentHash: "abcdef0123456789", AgentName: "crawler", OverallRisk: 0.1}
+	narrateReport(report, NarrationAegong)
+	withTestReports(t, report)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/report/{hash}", reportHandler)
+	get := func(path string) (*httptest.ResponseRecorder, aegong.AuditReport) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		var saved aegong.AuditReport
+		json.Unmarshal(rec.Body.Bytes(), &saved)
+		return rec, saved
+	}
+
+	if _, saved := get("/api/report/abcdef01"); saved.AegongMessage != report.AegongMessage {
+		t.Errorf("Reports should keep the profile they were saved in, got %q", saved.AegongMessage)
+	}
+	if _, saved := get("/api/report/abcdef01?narration=executive"); saved.Narration != NarrationExecutive ||
+		saved.AegongMessage != "crawler: MINIMAL risk, 0 findings. Approved for deployment." {
+		t.Errorf("Reports should be narrated in the requested profile, got %+v", saved)
+	}
+	if rec, _ := get("/api/report/abcdef01?narration=pirate"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Unknown profiles should be rejected, got %d", rec.Code)
+	}
+
+	if voiceAudioName("abcdef01", NarrationAegong) != "aegong_report_abcdef01.wav" || voiceAudioName("abcdef01", NarrationExecutive) != "aegong_report_abcdef01_executive.wav" {
+		t.Error("Each profile should be spoken to its own audio file")
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// Endpoint groups that can each be restricted to a set of networks
+const (
+	groupAdmin  = "admin"  // /api/admin/, and deleting and archiving reports
+	groupUpload = "upload" // Uploading and auditing agents, and audit jobs
+	groupPublic = "public" // Everything else: the web interface, reports and events
+)
+
+// networkPolicy lists the networks allowed to reach each endpoint group; a
+// group without an allowlist is open to every address
+type networkPolicy struct {
+	allow   map[string][]netip.Prefix
+	proxies []netip.Prefix // Proxies trusted to report the client in X-Forwarded-For
+}
+
+// Policy loaded from AEGONG_ALLOW_* and AEGONG_TRUSTED_PROXIES
+var netPolicy = &networkPolicy{allow: make(map[string][]netip.Prefix)}
+
+// parsePrefixes parses a comma separated list of CIDRs or single addresses
+func parsePrefixes(spec string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q: %v", entry, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %v", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// initNetworkPolicy loads the endpoint allowlists from the environment
+func initNetworkPolicy() error {
+	policy := &networkPolicy{allow: make(map[string][]netip.Prefix)}
+	for group, name := range map[string]string{
+		groupAdmin:  "AEGONG_ALLOW_ADMIN",
+		groupUpload: "AEGONG_ALLOW_UPLOAD",
+		groupPublic: "AEGONG_ALLOW_PUBLIC",
+	} {
+		prefixes, err := parsePrefixes(os.Getenv(name))
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if len(prefixes) > 0 {
+			policy.allow[group] = prefixes
+		}
+	}
+
+	proxies, err := parsePrefixes(os.Getenv("AEGONG_TRUSTED_PROXIES"))
+	if err != nil {
+		return fmt.Errorf("AEGONG_TRUSTED_PROXIES: %v", err)
+	}
+	policy.proxies = proxies
+	netPolicy = policy
+	return nil
+}
+
+// endpointGroup names the group a request belongs to
+func endpointGroup(method, path string) string {
+	switch {
+	case strings.HasPrefix(path, "/api/admin/"),
+		strings.HasPrefix(path, "/api/report/") && (method == http.MethodDelete || strings.HasSuffix(path, "/archive")):
+		return groupAdmin
+	case path == "/api/upload", path == "/api/audit-url", path == "/api/jobs",
+		strings.HasPrefix(path, "/api/audit/"), strings.HasPrefix(path, "/api/jobs/"),
+		strings.HasPrefix(path, "/api/report/") && strings.HasSuffix(path, "/rescore"):
+		return groupUpload
+	default:
+		return groupPublic
+	}
+}
+
+// contains reports whether addr falls within one of the prefixes
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddr is the address of the client that made a request. Behind a
+// trusted proxy it is the last X-Forwarded-For hop the proxies did not add.
+func (p *networkPolicy) clientAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr = addr.Unmap()
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 || !contains(p.proxies, addr) {
+		return addr, true
+	}
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		addr = hop.Unmap()
+		if !contains(p.proxies, addr) {
+			break
+		}
+	}
+	return addr, true
+}
+
+// allowed reports whether the client may reach the request's endpoint group
+func (p *networkPolicy) allowed(r *http.Request) (bool, string, netip.Addr) {
+	group := endpointGroup(r.Method, r.URL.Path)
+	ok, addr := p.allowedIn(r, group)
+	return ok, group, addr
+}
+
+// allowedIn reports whether the client may reach an endpoint group, for
+// actions such as WebSocket commands that belong to another group than the
+// request carrying them
+func (p *networkPolicy) allowedIn(r *http.Request, group string) (bool, netip.Addr) {
+	prefixes, restricted := p.allow[group]
+	addr, ok := p.clientAddr(r)
+	if !restricted {
+		return true, addr
+	}
+	return ok && contains(prefixes, addr), addr
+}
+
+// restrictNetworks rejects requests from networks outside the allowlist of
+// their endpoint group, logging each denial
+func restrictNetworks(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, group, addr := netPolicy.allowed(r)
+		if !ok {
+			log.Printf("Warning: Denied %s %s from %s, not in the %s allowlist", r.Method, r.URL.Path, addr, group)
+			http.Error(w, "Access from your network is not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestEndpointGroup tests which allowlist each endpoint falls under
+func TestEndpointGroup(t *testing.T) {
+	for request, group := range map[string]string{
+		"GET /api/admin/status":               groupAdmin,
+		"POST /api/upload":                    groupUpload,
+		"POST /api/audit/agent.py":            groupUpload,
+		"POST /api/audit-url":                 groupUpload,
+		"GET /api/jobs/42":                    groupUpload,
+		"POST /api/report/abcdef01/rescore":   groupUpload,
+		"GET /api/report/abcdef01":            groupPublic,
+		"DELETE /api/report/abcdef01":         groupAdmin,
+		"POST /api/report/abcdef01/archive":   groupAdmin,
+		"DELETE /api/report/abcdef01/archive": groupAdmin,
+		"GET /":                               groupPublic,
+	} {
+		method, path, _ := strings.Cut(request, " ")
+		if got := endpointGroup(method, path); got != group {
+			t.Fatalf("%s should be in the %s group, got %s", request, group, got)
+		}
+	}
+}
+
+// TestRestrictNetworks tests that allowlists reject other networks per group
+func TestRestrictNetworks(t *testing.T) {
+	oldPolicy := netPolicy
+	t.Cleanup(func() { netPolicy = oldPolicy })
+	t.Setenv("AEGONG_ALLOW_ADMIN", "10.0.0.0/8, 192.168.1.5")
+	t.Setenv("AEGONG_ALLOW_UPLOAD", "10.1.0.0/16")
+	t.Setenv("AEGONG_ALLOW_PUBLIC", "")
+	t.Setenv("AEGONG_TRUSTED_PROXIES", "127.0.0.1")
+	if err := initNetworkPolicy(); err != nil {
+		t.Fatalf("Failed to load network policy: %v", err)
+	}
+
+	handler := restrictNetworks(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := func(path, remote, forwarded string) int {
+		r := httptest.NewRequest("GET", path, nil)
+		r.RemoteAddr = remote
+		if forwarded != "" {
+			r.Header.Set("X-Forwarded-For", forwarded)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	for _, c := range []struct {
+		path, remote, forwarded string
+		status                  int
+	}{
+		{"/api/admin/status", "10.2.3.4:5000", "", http.StatusOK},
+		{"/api/admin/status", "192.168.1.5:5000", "", http.StatusOK},
+		{"/api/admin/status", "203.0.113.9:5000", "", http.StatusForbidden},
+		{"/api/upload", "10.2.3.4:5000", "", http.StatusForbidden},
+		{"/api/upload", "10.1.3.4:5000", "", http.StatusOK},
+		{"/api/report/abcdef01", "203.0.113.9:5000", "", http.StatusOK},
+		{"/api/admin/status", "127.0.0.1:5000", "203.0.113.9", http.StatusForbidden},
+		{"/api/admin/status", "127.0.0.1:5000", "10.2.3.4", http.StatusOK},
+		{"/api/admin/status", "127.0.0.1:5000", "10.2.3.4, 203.0.113.9", http.StatusForbidden},
+		{"/api/admin/status", "203.0.113.9:5000", "10.2.3.4", http.StatusForbidden},
+		{"/api/admin/status", "127.0.0.1:5000", "not-an-ip", http.StatusForbidden},
+	} {
+		if status := request(c.path, c.remote, c.forwarded); status != c.status {
+			t.Fatalf("%s from %s (forwarded %q) should get %d, got %d", c.path, c.remote, c.forwarded, c.status, status)
+		}
+	}
+
+	// Deleting a report is an admin action, though reading it is public
+	r := httptest.NewRequest("DELETE", "/api/report/abcdef01", nil)
+	r.RemoteAddr = "203.0.113.9:5000"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Deleting a report from outside the admin allowlist should get 403, got %d", w.Code)
+	}
+}
+
+// TestInitNetworkPolicyRejectsInvalid tests that malformed allowlists are rejected
+func TestInitNetworkPolicyRejectsInvalid(t *testing.T) {
+	oldPolicy := netPolicy
+	t.Cleanup(func() { netPolicy = oldPolicy })
+	t.Setenv("AEGONG_ALLOW_ADMIN", "10.0.0.0/33")
+	if err := initNetworkPolicy(); err == nil {
+		t.Fatal("Invalid CIDR should be rejected")
+	}
+}
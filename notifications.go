@@ -0,0 +1,646 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// Notification rules route audit events to the people who need them. A rule
+// names the events it listens for, conditions on the risk level, threat
+// vectors, tags and workspace of the audit, and the channels to notify: a
+// JSON webhook, a Slack incoming webhook or email. Admins manage rules over
+// the API; they are kept in the store next to the reports. Deliveries are
+// queued and sent in the background so a slow channel never holds up an
+// audit, and failed deliveries are logged.
+
+// Where notification rules are kept
+var notificationRulesPath = filepath.Join("reports", "notification_rules.json")
+
+// Kinds of notification channel
+const (
+	channelWebhook = "webhook"
+	channelSlack   = "slack"
+	channelEmail   = "email"
+)
+
+// Events rules can listen for
+var notificationEvents = map[string]bool{
+	eventAuditCompleted: true,
+	eventAuditFailed:    true,
+	eventStatusWarning:  true,
+}
+
+const (
+	notificationTimeout    = 10 * time.Second
+	notificationQueueDepth = 100
+	maxNotificationRules   = 100
+)
+
+// notificationRule sends the events matching its conditions to its channels
+type notificationRule struct {
+	ID         string                 `json:"id"`
+	Name       string                 `json:"name"`
+	Disabled   bool                   `json:"disabled,omitempty"`
+	Events     []string               `json:"events"`
+	Conditions notificationConditions `json:"conditions"`
+	Channels   []notificationChannel  `json:"channels"`
+	UpdatedBy  string                 `json:"updated_by"`
+	UpdatedAt  time.Time              `json:"updated_at"`
+}
+
+// notificationConditions must all hold for a rule to match; those left empty
+// always hold. Conditions on the report only hold for completed audits.
+type notificationConditions struct {
+	MinRiskLevel string   `json:"min_risk_level,omitempty"` // MINIMAL to CRITICAL
+	Vectors      []string `json:"vectors,omitempty"`        // Any of these vectors was found, as T1 to T99
+	Tags         []string `json:"tags,omitempty"`           // The report has every one of these tags
+	Workspaces   []string `json:"workspaces,omitempty"`     // The audit was started by one of these tenants
+}
+
+// notificationChannel is where a rule sends its notifications
+type notificationChannel struct {
+	Type string   `json:"type"`
+	URL  string   `json:"url,omitempty"` // Webhook and Slack
+	To   []string `json:"to,omitempty"`  // Email
+}
+
+// notificationRuleRequest creates or replaces a rule
+type notificationRuleRequest struct {
+	Name       string                 `json:"name"`
+	Disabled   bool                   `json:"disabled"`
+	Events     []string               `json:"events"`
+	Conditions notificationConditions `json:"conditions"`
+	Channels   []notificationChannel  `json:"channels"`
+}
+
+// notification is what is sent to a channel; webhooks receive it as JSON
+type notification struct {
+	Event         string    `json:"event"`
+	RuleID        string    `json:"rule_id"`
+	RuleName      string    `json:"rule_name"`
+	Time          time.Time `json:"time"`
+	Text          string    `json:"text"` // One line summary
+	AuditID       string    `json:"audit_id,omitempty"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	Filename      string    `json:"filename,omitempty"`
+	Workspace     string    `json:"workspace,omitempty"`
+	AgentName     string    `json:"agent_name,omitempty"`
+	AgentHash     string    `json:"agent_hash,omitempty"`
+	RiskLevel     string    `json:"risk_level,omitempty"`
+	OverallRisk   float64   `json:"overall_risk,omitempty"`
+	Vectors       []string  `json:"vectors,omitempty"`
+	Tags          []string  `json:"tags,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	Warning       string    `json:"warning,omitempty"`
+}
+
+// notificationDelivery is a notification queued for one channel
+type notificationDelivery struct {
+	channel      notificationChannel
+	notification notification
+}
+
+// smtpConfig is the mail server email notifications are sent through
+type smtpConfig struct {
+	addr     string
+	from     string
+	username string
+	password string
+}
+
+// notifier matches events against the rules and delivers the notifications
+type notifier struct {
+	mutex  sync.RWMutex
+	rules  []notificationRule
+	queue  chan notificationDelivery
+	client *http.Client
+	smtp   *smtpConfig
+	// sendMail sends email; replaced in tests
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func newNotifier() *notifier {
+	return &notifier{
+		queue:    make(chan notificationDelivery, notificationQueueDepth),
+		client:   &http.Client{Timeout: notificationTimeout},
+		sendMail: smtp.SendMail,
+	}
+}
+
+// Notification rules of the server; initNotifications loads them
+var notifications = newNotifier()
+
+// initNotifications reads the SMTP settings and the saved rules, and starts
+// delivering notifications for events on the bus
+func initNotifications() error {
+	n := newNotifier()
+	if addr := os.Getenv("AEGONG_SMTP_ADDR"); addr != "" {
+		from := os.Getenv("AEGONG_SMTP_FROM")
+		if _, err := mail.ParseAddress(from); err != nil {
+			return fmt.Errorf("AEGONG_SMTP_FROM must be an email address when AEGONG_SMTP_ADDR is set, got %q", from)
+		}
+		n.smtp = &smtpConfig{
+			addr:     addr,
+			from:     from,
+			username: os.Getenv("AEGONG_SMTP_USERNAME"),
+			password: os.Getenv("AEGONG_SMTP_PASSWORD"),
+		}
+	}
+
+	rules, err := loadNotificationRules()
+	if err != nil {
+		return err
+	}
+	n.rules = rules
+	notifications = n
+	go n.deliver()
+	bus.subscribe(n.notify, eventAuditCompleted, eventAuditFailed, eventStatusWarning)
+	if len(rules) > 0 {
+		log.Printf("Info: Loaded %d notification rules", len(rules))
+	}
+	return nil
+}
+
+// loadNotificationRules reads the saved notification rules
+func loadNotificationRules() ([]notificationRule, error) {
+	data, err := readStored(notificationRulesPath)
+	if os.IsNotExist(err) {
+		return []notificationRule{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rules []notificationRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse notification rules: %v", err)
+	}
+	return rules, nil
+}
+
+// save writes the rules to the store and makes them current
+func (n *notifier) save(rules []notificationRule) error {
+	data, _ := json.MarshalIndent(rules, "", "  ")
+	os.MkdirAll(filepath.Dir(notificationRulesPath), 0755)
+	if err := writeStored(notificationRulesPath, data); err != nil {
+		return err
+	}
+	n.rules = rules
+	return nil
+}
+
+// validate checks a rule request, returning the rule it describes
+func (n *notifier) validate(request notificationRuleRequest) (notificationRule, error) {
+	rule := notificationRule{
+		Name:       strings.TrimSpace(request.Name),
+		Disabled:   request.Disabled,
+		Events:     request.Events,
+		Conditions: request.Conditions,
+		Channels:   request.Channels,
+	}
+	if rule.Name == "" {
+		return rule, fmt.Errorf("a notification rule needs a name")
+	}
+	if len(rule.Events) == 0 {
+		rule.Events = []string{eventAuditCompleted}
+	}
+	for _, event := range rule.Events {
+		if !notificationEvents[event] {
+			return rule, fmt.Errorf("events must be %q, %q or %q, got %q", eventAuditCompleted, eventAuditFailed, eventStatusWarning, event)
+		}
+	}
+
+	conditions := &rule.Conditions
+	if conditions.MinRiskLevel != "" {
+		conditions.MinRiskLevel = strings.ToUpper(conditions.MinRiskLevel)
+		if riskLevelRank(conditions.MinRiskLevel) < 0 {
+			return rule, fmt.Errorf("min_risk_level must be one of %s, got %q", strings.Join(riskLevels, ", "), conditions.MinRiskLevel)
+		}
+	}
+	for i, vector := range conditions.Vectors {
+		conditions.Vectors[i] = strings.ToUpper(vector)
+		var number int
+		if _, err := fmt.Sscanf(conditions.Vectors[i], "T%d", &number); err != nil || number < 1 || fmt.Sprintf("T%d", number) != conditions.Vectors[i] {
+			return rule, fmt.Errorf("vectors must be T1 to T99, got %q", vector)
+		}
+	}
+
+	if len(rule.Channels) == 0 {
+		return rule, fmt.Errorf("a notification rule needs at least one channel")
+	}
+	for _, channel := range rule.Channels {
+		switch channel.Type {
+		case channelWebhook, channelSlack:
+			parsed, err := url.Parse(channel.URL)
+			if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+				return rule, fmt.Errorf("%s channels need an http(s) url, got %q", channel.Type, channel.URL)
+			}
+		case channelEmail:
+			if n.smtp == nil {
+				return rule, fmt.Errorf("email channels need AEGONG_SMTP_ADDR to be set")
+			}
+			if len(channel.To) == 0 {
+				return rule, fmt.Errorf("email channels need at least one address in to")
+			}
+			for _, to := range channel.To {
+				if _, err := mail.ParseAddress(to); err != nil {
+					return rule, fmt.Errorf("invalid email address %q", to)
+				}
+			}
+		default:
+			return rule, fmt.Errorf("channel type must be %q, %q or %q, got %q", channelWebhook, channelSlack, channelEmail, channel.Type)
+		}
+	}
+	return rule, nil
+}
+
+// riskLevelRank orders the risk levels from MINIMAL up, or returns -1
+func riskLevelRank(level string) int {
+	for i, known := range riskLevels {
+		if known == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// reportTags describes how an agent got audited: where it came from
+// ("upload" or the registry), "signed" or "unsigned", "forced" when it was
+// audited despite failing validation, "undeclared_permissions" when it used
+// permissions its manifest didn't declare and "detector_errors"
+func reportTags(report *aegong.AuditReport) []string {
+	tags := []string{"upload"}
+	if report.Source != nil {
+		tags[0] = report.Source.Kind
+	}
+	if report.Signature != nil && report.Signature.Status == aegong.SignatureVerified {
+		tags = append(tags, "signed")
+	} else {
+		tags = append(tags, "unsigned")
+	}
+	if report.ValidationOverride != nil {
+		tags = append(tags, "forced")
+	}
+	if report.Manifest != nil && len(report.Manifest.UndeclaredPermissions) > 0 {
+		tags = append(tags, "undeclared_permissions")
+	}
+	if len(report.DetectorErrors) > 0 {
+		tags = append(tags, "detector_errors")
+	}
+	return tags
+}
+
+// reportVectors lists the vectors found in a report, as T1 to T99
+func reportVectors(report *aegong.AuditReport) []string {
+	seen := make(map[string]bool)
+	var vectors []string
+	for _, threat := range report.Threats {
+		if code := vectorCode(threat.Vector); !seen[code] {
+			seen[code] = true
+			vectors = append(vectors, code)
+		}
+	}
+	sort.Strings(vectors)
+	return vectors
+}
+
+// newNotification describes an event for the channels
+func newNotification(event busEvent) notification {
+	n := notification{
+		Event:         event.Type,
+		Time:          event.Time,
+		AuditID:       event.AuditID,
+		CorrelationID: event.CorrelationID,
+		Filename:      event.Filename,
+		Workspace:     event.Workspace,
+		Warning:       event.Warning,
+	}
+	switch {
+	case event.Report != nil:
+		report := event.Report
+		n.AgentName = report.AgentName
+		n.AgentHash = report.AgentHash
+		n.RiskLevel = report.RiskLevel
+		n.OverallRisk = report.OverallRisk
+		n.Vectors = reportVectors(report)
+		n.Tags = reportTags(report)
+		n.Text = fmt.Sprintf("%s risk: %s (%.2f)", report.RiskLevel, report.AgentName, report.OverallRisk)
+		if len(n.Vectors) > 0 {
+			n.Text += " found " + strings.Join(n.Vectors, ", ")
+		}
+	case event.Err != nil:
+		n.Error = event.Err.Error()
+		n.Text = fmt.Sprintf("Audit of %s failed: %s", event.Filename, n.Error)
+	default:
+		n.Text = "Status warning: " + event.Warning
+	}
+	if event.Workspace != "" {
+		n.Text += " [" + event.Workspace + "]"
+	}
+	return n
+}
+
+// matches reports whether a rule applies to an event
+func (rule *notificationRule) matches(event busEvent, n notification) bool {
+	if rule.Disabled || !slices.Contains(rule.Events, event.Type) {
+		return false
+	}
+	conditions := rule.Conditions
+	if len(conditions.Workspaces) > 0 && !slices.Contains(conditions.Workspaces, event.Workspace) {
+		return false
+	}
+	if conditions.MinRiskLevel == "" && len(conditions.Vectors) == 0 && len(conditions.Tags) == 0 {
+		return true
+	}
+	if event.Report == nil {
+		return false
+	}
+	if conditions.MinRiskLevel != "" && riskLevelRank(n.RiskLevel) < riskLevelRank(conditions.MinRiskLevel) {
+		return false
+	}
+	if len(conditions.Vectors) > 0 {
+		found := false
+		for _, vector := range conditions.Vectors {
+			found = found || slices.Contains(n.Vectors, vector)
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, tag := range conditions.Tags {
+		if !slices.Contains(n.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// notify queues a notification to the channels of every rule the event
+// matches. It runs on the bus, so it never waits for a channel.
+func (n *notifier) notify(event busEvent) {
+	n.mutex.RLock()
+	rules := n.rules
+	n.mutex.RUnlock()
+
+	base := newNotification(event)
+	for _, rule := range rules {
+		if !rule.matches(event, base) {
+			continue
+		}
+		message := base
+		message.RuleID, message.RuleName = rule.ID, rule.Name
+		for _, channel := range rule.Channels {
+			select {
+			case n.queue <- notificationDelivery{channel, message}:
+			default:
+				log.Printf("Warning: Notification queue is full, dropping %s notification of rule %s", channel.Type, rule.Name)
+			}
+		}
+	}
+}
+
+// deliver sends queued notifications until the queue is closed
+func (n *notifier) deliver() {
+	for delivery := range n.queue {
+		if err := n.send(delivery.channel, delivery.notification); err != nil {
+			log.Printf("Warning: Failed to send %s notification of rule %s: %v", delivery.channel.Type, delivery.notification.RuleName, err)
+		}
+	}
+}
+
+// send delivers a notification to one channel
+func (n *notifier) send(channel notificationChannel, message notification) error {
+	switch channel.Type {
+	case channelWebhook:
+		body, _ := json.Marshal(message)
+		return n.post(channel.URL, body)
+	case channelSlack:
+		body, _ := json.Marshal(map[string]string{"text": "Aegong: " + message.Text})
+		return n.post(channel.URL, body)
+	case channelEmail:
+		if n.smtp == nil {
+			return fmt.Errorf("AEGONG_SMTP_ADDR is not set")
+		}
+		var auth smtp.Auth
+		if n.smtp.username != "" {
+			host := n.smtp.addr
+			if i := strings.LastIndex(host, ":"); i >= 0 {
+				host = host[:i]
+			}
+			auth = smtp.PlainAuth("", n.smtp.username, n.smtp.password, host)
+		}
+		return n.sendMail(n.smtp.addr, auth, n.smtp.from, channel.To, emailMessage(n.smtp.from, channel.To, message))
+	}
+	return fmt.Errorf("unknown channel type %q", channel.Type)
+}
+
+func (n *notifier) post(target string, body []byte) error {
+	req, err := http.NewRequest("POST", target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Aegong-Notifier")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+// emailMessage writes a notification as a plain text email
+func emailMessage(from string, to []string, message notification) []byte {
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", from)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.NewReplacer("\r", " ", "\n", " ").Replace("Aegong: "+message.Text)))
+	fmt.Fprintf(&body, "Date: %s\r\n", message.Time.Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	body.WriteString(message.Text + "\r\n\r\n")
+	for _, field := range []struct{ name, value string }{
+		{"Rule", message.RuleName},
+		{"Event", message.Event},
+		{"Agent", message.AgentName},
+		{"Agent hash", message.AgentHash},
+		{"Upload", message.Filename},
+		{"Workspace", message.Workspace},
+		{"Vectors", strings.Join(message.Vectors, ", ")},
+		{"Tags", strings.Join(message.Tags, ", ")},
+		{"Audit ID", message.AuditID},
+		{"Correlation ID", message.CorrelationID},
+	} {
+		if field.value != "" {
+			fmt.Fprintf(&body, "%s: %s\r\n", field.name, field.value)
+		}
+	}
+	return []byte(body.String())
+}
+
+// decodeRuleRequest reads a notification rule request, writing a 400 if it is invalid
+func decodeRuleRequest(w http.ResponseWriter, r *http.Request) (notificationRule, bool) {
+	var request notificationRuleRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		http.Error(w, "Request body must be JSON with \"name\", \"channels\" and optional \"events\" and \"conditions\"", http.StatusBadRequest)
+		return notificationRule{}, false
+	}
+	rule, err := notifications.validate(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return rule, false
+	}
+	return rule, true
+}
+
+// notificationRulesHandler lists the notification rules. Webhook URLs carry
+// their credentials, so only admins see them.
+func notificationRulesHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireRole(w, r, RoleAdmin); !ok {
+		return
+	}
+	notifications.mutex.RLock()
+	rules := notifications.rules
+	notifications.mutex.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// createNotificationRuleHandler lets admins add a notification rule
+func createNotificationRuleHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin)
+	if !ok {
+		return
+	}
+	rule, ok := decodeRuleRequest(w, r)
+	if !ok {
+		return
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create notification rule: %v", err), http.StatusInternalServerError)
+		return
+	}
+	rule.ID = hex.EncodeToString(id)
+	rule.UpdatedBy = principal.Name
+	rule.UpdatedAt = time.Now().UTC()
+
+	notifications.mutex.Lock()
+	var err error
+	if len(notifications.rules) >= maxNotificationRules {
+		err = fmt.Errorf("at most %d notification rules are allowed", maxNotificationRules)
+	} else {
+		err = notifications.save(append(notifications.rules[:len(notifications.rules):len(notifications.rules)], rule))
+	}
+	notifications.mutex.Unlock()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save notification rule: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logf(r.Context(), "Notification rule %s (%s) created by %s", rule.ID, rule.Name, principal.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// updateNotificationRuleHandler lets admins replace a notification rule
+func updateNotificationRuleHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin)
+	if !ok {
+		return
+	}
+	rule, ok := decodeRuleRequest(w, r)
+	if !ok {
+		return
+	}
+	rule.ID = mux.Vars(r)["id"]
+	rule.UpdatedBy = principal.Name
+	rule.UpdatedAt = time.Now().UTC()
+
+	notifications.mutex.Lock()
+	rules := append([]notificationRule{}, notifications.rules...)
+	found := false
+	for i := range rules {
+		if rules[i].ID == rule.ID {
+			rules[i] = rule
+			found = true
+		}
+	}
+	var err error
+	if found {
+		err = notifications.save(rules)
+	}
+	notifications.mutex.Unlock()
+	if !found {
+		http.Error(w, "Notification rule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save notification rule: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logf(r.Context(), "Notification rule %s (%s) updated by %s", rule.ID, rule.Name, principal.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rule)
+}
+
+// deleteNotificationRuleHandler lets admins delete a notification rule
+func deleteNotificationRuleHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+
+	notifications.mutex.Lock()
+	var rules []notificationRule
+	for _, rule := range notifications.rules {
+		if rule.ID != id {
+			rules = append(rules, rule)
+		}
+	}
+	found := len(rules) < len(notifications.rules)
+	var err error
+	if found {
+		if rules == nil {
+			rules = []notificationRule{}
+		}
+		err = notifications.save(rules)
+	}
+	notifications.mutex.Unlock()
+	if !found {
+		http.Error(w, "Notification rule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete notification rule: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logf(r.Context(), "Notification rule %s deleted by %s", id, principal.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
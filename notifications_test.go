@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// withTestNotifier replaces the server's notification rules for the test
+func withTestNotifier(t *testing.T) *notifier {
+	old := notifications
+	t.Cleanup(func() { notifications = old })
+	notifications = newNotifier()
+	return notifications
+}
+
+// TestNotificationRuleMatching tests conditions over risk level, vectors, tags and workspace
+func TestNotificationRuleMatching(t *testing.T) {
+	report := &aegong.AuditReport{
+		AgentName: "helper", AgentHash: "abcdef12", RiskLevel: "HIGH", OverallRisk: 0.7,
+		Threats:   []aegong.ThreatDetection{{Vector: aegong.T4_UNAUTHORIZED_ACTION}, {Vector: aegong.T1_REASONING_HIJACK}},
+		Source:    &aegong.ArtifactSource{Kind: "pypi"},
+		Signature: &aegong.SignatureInfo{Status: aegong.SignatureVerified},
+	}
+	completed := busEvent{Type: eventAuditCompleted, Workspace: "build", Report: report}
+	failed := busEvent{Type: eventAuditFailed, Workspace: "build", Filename: "agent.py", Err: errors.New("sandbox crashed")}
+
+	for _, c := range []struct {
+		name       string
+		rule       notificationRule
+		event      busEvent
+		shouldFire bool
+	}{
+		{"no conditions", notificationRule{Events: []string{eventAuditCompleted}}, completed, true},
+		{"other event", notificationRule{Events: []string{eventAuditFailed}}, completed, false},
+		{"disabled", notificationRule{Disabled: true, Events: []string{eventAuditCompleted}}, completed, false},
+		{"risk reached", notificationRule{Events: []string{eventAuditCompleted}, Conditions: notificationConditions{MinRiskLevel: "HIGH"}}, completed, true},
+		{"risk not reached", notificationRule{Events: []string{eventAuditCompleted}, Conditions: notificationConditions{MinRiskLevel: "CRITICAL"}}, completed, false},
+		{"any vector", notificationRule{Events: []string{eventAuditCompleted}, Conditions: notificationConditions{Vectors: []string{"T3", "T4"}}}, completed, true},
+		{"missing vector", notificationRule{Events: []string{eventAuditCompleted}, Conditions: notificationConditions{Vectors: []string{"T3"}}}, completed, false},
+		{"all tags", notificationRule{Events: []string{eventAuditCompleted}, Conditions: notificationConditions{Tags: []string{"pypi", "signed"}}}, completed, true},
+		{"missing tag", notificationRule{Events: []string{eventAuditCompleted}, Conditions: notificationConditions{Tags: []string{"pypi", "forced"}}}, completed, false},
+		{"workspace", notificationRule{Events: []string{eventAuditCompleted}, Conditions: notificationConditions{Workspaces: []string{"build"}}}, completed, true},
+		{"other workspace", notificationRule{Events: []string{eventAuditCompleted}, Conditions: notificationConditions{Workspaces: []string{"research"}}}, completed, false},
+		{"failure in workspace", notificationRule{Events: []string{eventAuditFailed}, Conditions: notificationConditions{Workspaces: []string{"build"}}}, failed, true},
+		{"failure has no risk", notificationRule{Events: []string{eventAuditFailed}, Conditions: notificationConditions{MinRiskLevel: "LOW"}}, failed, false},
+	} {
+		if got := c.rule.matches(c.event, newNotification(c.event)); got != c.shouldFire {
+			t.Errorf("%s: Should match: %v, got %v", c.name, c.shouldFire, got)
+		}
+	}
+
+	n := newNotification(completed)
+	if n.Text != "HIGH risk: helper (0.70) found T1, T4 [build]" || strings.Join(n.Tags, ",") != "pypi,signed" {
+		t.Fatalf("Should summarize the report, got %q with tags %v", n.Text, n.Tags)
+	}
+	if n := newNotification(failed); n.Text != "Audit of agent.py failed: sandbox crashed [build]" || n.Error != "sandbox crashed" {
+		t.Fatalf("Should summarize the failure, got %+v", n)
+	}
+}
+
+// TestNotificationDelivery tests that matching events reach webhook, Slack and email channels
+func TestNotificationDelivery(t *testing.T) {
+	received := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r.URL.Path + " " + string(body)
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	n := newNotifier()
+	n.smtp = &smtpConfig{addr: "mail.example.com:587", from: "aegong@example.com", username: "aegong", password: "secret"}
+	mailed := make(chan []byte, 1)
+	n.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if addr != "mail.example.com:587" || a == nil || from != "aegong@example.com" || len(to) != 1 {
+			t.Errorf("Should send through the configured server, got %s from %s to %v", addr, from, to)
+		}
+		mailed <- msg
+		return nil
+	}
+	n.rules = []notificationRule{{
+		ID: "r1", Name: "critical", Events: []string{eventAuditCompleted},
+		Conditions: notificationConditions{MinRiskLevel: "CRITICAL"},
+		Channels: []notificationChannel{
+			{Type: channelWebhook, URL: server.URL + "/hook"},
+			{Type: channelSlack, URL: server.URL + "/slack"},
+			{Type: channelEmail, To: []string{"security@example.com"}},
+		},
+	}}
+	go n.deliver()
+	defer close(n.queue)
+
+	n.notify(busEvent{Type: eventAuditCompleted, Report: &aegong.AuditReport{AgentName: "helper", RiskLevel: "LOW"}})
+	n.notify(busEvent{Type: eventAuditCompleted, AuditID: "a1", Time: time.Now(), Report: &aegong.AuditReport{AgentName: "dropper", AgentHash: "deadbeef", RiskLevel: "CRITICAL", OverallRisk: 0.9}})
+
+	var hook, slack string
+	for i := 0; i < 2; i++ {
+		select {
+		case got := <-received:
+			if strings.HasPrefix(got, "/hook ") {
+				hook = strings.TrimPrefix(got, "/hook ")
+			} else {
+				slack = got
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Should deliver the notification to both webhooks")
+		}
+	}
+	var payload notification
+	if err := json.Unmarshal([]byte(hook), &payload); err != nil || payload.RuleID != "r1" || payload.AgentHash != "deadbeef" || payload.AuditID != "a1" {
+		t.Fatalf("Should post the notification as JSON, got %s", hook)
+	}
+	if slack != `/slack {"text":"Aegong: CRITICAL risk: dropper (0.90)"}` {
+		t.Fatalf("Should post Slack's text format, got %s", slack)
+	}
+	select {
+	case msg := <-mailed:
+		if !strings.Contains(string(msg), "Subject: Aegong: CRITICAL risk: dropper (0.90)\r\n") || !strings.Contains(string(msg), "Agent hash: deadbeef") {
+			t.Fatalf("Should email the notification, got %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Should email the notification")
+	}
+
+	if err := n.send(notificationChannel{Type: channelWebhook, URL: server.URL + "/broken"}, payload); err == nil {
+		t.Fatal("Should report webhooks that answer with an error")
+	}
+}
+
+// TestNotificationRulesAPI tests creating, listing, replacing and deleting rules
+func TestNotificationRulesAPI(t *testing.T) {
+	withTestUpload(t)
+	withTestNotifier(t)
+	oldTokens := apiTokens
+	t.Cleanup(func() { apiTokens = oldTokens })
+	apiTokens, _ = loadAPITokens("alice:admin:admin,ci:auditor:audit")
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/admin/notifications", notificationRulesHandler).Methods("GET")
+	router.HandleFunc("/api/admin/notifications", createNotificationRuleHandler).Methods("POST")
+	router.HandleFunc("/api/admin/notifications/{id}", updateNotificationRuleHandler).Methods("PUT")
+	router.HandleFunc("/api/admin/notifications/{id}", deleteNotificationRuleHandler).Methods("DELETE")
+	call := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, body := range []string{
+		`{"channels":[{"type":"webhook","url":"https://hooks.example.com/x"}]}`,
+		`{"name":"x","channels":[]}`,
+		`{"name":"x","channels":[{"type":"webhook","url":"file:///etc/passwd"}]}`,
+		`{"name":"x","channels":[{"type":"email","to":["security@example.com"]}]}`,
+		`{"name":"x","events":["audit_phase"],"channels":[{"type":"slack","url":"https://hooks.slack.com/x"}]}`,
+		`{"name":"x","conditions":{"min_risk_level":"SEVERE"},"channels":[{"type":"slack","url":"https://hooks.slack.com/x"}]}`,
+		`{"name":"x","conditions":{"vectors":["4"]},"channels":[{"type":"slack","url":"https://hooks.slack.com/x"}]}`,
+	} {
+		if w := call("POST", "/api/admin/notifications", "admin", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: Should be rejected, got %d", body, w.Code)
+		}
+	}
+
+	rule := `{"name":"High risk","conditions":{"min_risk_level":"high","vectors":["t4"]},"channels":[{"type":"slack","url":"https://hooks.slack.com/x"}]}`
+	if w := call("POST", "/api/admin/notifications", "audit", rule); w.Code != http.StatusForbidden {
+		t.Fatalf("Should only let admins add rules, got %d", w.Code)
+	}
+	w := call("POST", "/api/admin/notifications", "admin", rule)
+	var created notificationRule
+	json.NewDecoder(w.Body).Decode(&created)
+	if w.Code != http.StatusCreated || created.ID == "" || created.UpdatedBy != "alice" {
+		t.Fatalf("Should create the rule, got %d: %+v", w.Code, created)
+	}
+	if created.Conditions.MinRiskLevel != "HIGH" || created.Conditions.Vectors[0] != "T4" || created.Events[0] != eventAuditCompleted {
+		t.Fatalf("Should normalize the rule and default to completed audits, got %+v", created)
+	}
+
+	// Rules are saved in the store
+	saved, err := loadNotificationRules()
+	if err != nil || len(saved) != 1 || saved[0].ID != created.ID {
+		t.Fatalf("Should save the rule, got %+v (%v)", saved, err)
+	}
+
+	if w := call("PUT", "/api/admin/notifications/"+created.ID, "admin", `{"name":"Renamed","disabled":true,"channels":[{"type":"webhook","url":"https://hooks.example.com/y"}]}`); w.Code != http.StatusOK {
+		t.Fatalf("Should replace the rule, got %d: %s", w.Code, w.Body)
+	}
+	if w := call("PUT", "/api/admin/notifications/missing", "admin", rule); w.Code != http.StatusNotFound {
+		t.Fatalf("Should not replace unknown rules, got %d", w.Code)
+	}
+	w = call("GET", "/api/admin/notifications", "admin", "")
+	var listed []notificationRule
+	json.NewDecoder(w.Body).Decode(&listed)
+	if len(listed) != 1 || listed[0].Name != "Renamed" || !listed[0].Disabled || listed[0].ID != created.ID {
+		t.Fatalf("Should list the replaced rule, got %+v", listed)
+	}
+
+	if w := call("DELETE", "/api/admin/notifications/"+created.ID, "admin", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Should delete the rule, got %d", w.Code)
+	}
+	if w := call("DELETE", "/api/admin/notifications/"+created.ID, "admin", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("Should not delete a rule twice, got %d", w.Code)
+	}
+	if saved, _ := loadNotificationRules(); len(saved) != 0 {
+		t.Fatalf("Should save the deletion, got %+v", saved)
+	}
+}
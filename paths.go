@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Reports are saved under the first 8 hex digits of the agent's hash
+var reportHashPattern = regexp.MustCompile(`^[0-9a-f]{8}$`)
+
+var errInvalidName = errors.New("invalid file name")
+
+// safeFilename reduces a package, image or uploaded file name to characters
+// safe in uploads/
+func safeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, name)
+	return strings.Trim(filepath.Base(name), ".")
+}
+
+// confinedPath joins a user supplied file name to a base directory, rejecting
+// names that are not a single path element or would resolve outside base
+func confinedPath(base, name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`+"\x00") {
+		return "", fmt.Errorf("%w %q", errInvalidName, name)
+	}
+	root, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(filepath.Clean(filepath.Join(root, name)), root+string(os.PathSeparator)) {
+		return "", fmt.Errorf("%w %q", errInvalidName, name)
+	}
+	return filepath.Join(base, name), nil
+}
+
+// uploadPath is where an upload of a user supplied name is kept
+func uploadPath(filename string) (string, error) {
+	return confinedPath("uploads", filename)
+}
+
+// validReportHash reports whether hash is the short hash reports are saved under
+func validReportHash(hash string) bool {
+	return reportHashPattern.MatchString(hash)
+}
+
+// reportFile is where the report of a user supplied short hash is kept
+func reportFile(hash string) (string, error) {
+	if !validReportHash(hash) {
+		return "", fmt.Errorf("invalid report hash %q", hash)
+	}
+	return confinedPath("reports", fmt.Sprintf("report_%s.json", hash))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// Names that try to reach outside uploads/ and reports/
+var traversalNames = []string{
+	"", ".", "..", "../etc/passwd", "../../reports/report_abcdef01.json", "a/b",
+	`..\x`, `..\..\windows\win.ini`, "/etc/passwd", "uploads/../../x", "agent.py\x00.txt",
+}
+
+// TestConfinedPath tests that names are kept inside their base directory
+func TestConfinedPath(t *testing.T) {
+	for _, name := range traversalNames {
+		if path, err := confinedPath("uploads", name); err == nil {
+			t.Errorf("%q: Should be rejected, got %s", name, path)
+		}
+	}
+	for _, name := range []string{"agent.py", "1700000000_agent.py", "..agent", "agent..py", ".hidden"} {
+		path, err := confinedPath("uploads", name)
+		if err != nil || path != filepath.Join("uploads", name) {
+			t.Errorf("%q: Should be kept in uploads, got %q, %v", name, path, err)
+		}
+	}
+}
+
+// TestReportFile tests that only short agent hashes name reports
+func TestReportFile(t *testing.T) {
+	for _, hash := range append(traversalNames, "abcdef0", "abcdef012", "ABCDEF01", "abcdefgh", "abcdef01/", "*") {
+		if path, err := reportFile(hash); err == nil {
+			t.Errorf("%q: Should be rejected, got %s", hash, path)
+		}
+		if _, status, _ := savedReport(hash); status != http.StatusBadRequest {
+			t.Errorf("%q: Should be a bad request, got %d", hash, status)
+		}
+	}
+	if path, err := reportFile("abcdef01"); err != nil || path != filepath.Join("reports", "report_abcdef01.json") {
+		t.Errorf("Should find the report of a short hash, got %q, %v", path, err)
+	}
+}
+
+// TestSafeFilename tests that uploaded names are reduced to safe characters
+func TestSafeFilename(t *testing.T) {
+	for name, expected := range map[string]string{
+		"agent.py":            "agent.py",
+		"my agent (1).py":     "my_agent__1_.py",
+		"../../etc/passwd":    "_.._etc_passwd",
+		`..\..\win.ini`:       "_.._win.ini",
+		"..":                  "",
+		"agent.py\x00.txt":    "agent.py_.txt",
+		"/etc/cron.d/agent":   "_etc_cron.d_agent",
+		"élève.py":            "_l_ve.py",
+		".bashrc":             "bashrc",
+		"agent.tar.gz.":       "agent.tar.gz",
+		"nested/../agent.bin": "nested_.._agent.bin",
+	} {
+		if got := safeFilename(name); got != expected {
+			t.Errorf("%q: Should become %q, got %q", name, expected, got)
+		}
+		if got := safeFilename(name); got != "" && strings.ContainsAny(got, `/\`) {
+			t.Errorf("%q: Should not keep separators, got %q", name, got)
+		}
+	}
+}
+
+// TestPathTraversal tests that handlers reject names escaping their directory
+func TestPathTraversal(t *testing.T) {
+	withTestReports(t)
+	os.WriteFile("secret.json", []byte(`{"agent_hash":"abcdef0123456789"}`), 0644)
+
+	for _, c := range []struct {
+		handler http.HandlerFunc
+		method  string
+		vars    map[string]string
+	}{
+		{reportHandler, "GET", map[string]string{"hash": "../secret"}},
+		{reportHandler, "GET", map[string]string{"hash": `..\secret`}},
+		{reportAnchorHandler, "GET", map[string]string{"hash": "../../secret"}},
+		{exportReportHandler, "GET", map[string]string{"hash": "../secret"}},
+		{validateHandler, "GET", map[string]string{"filename": "../secret.json"}},
+		{validateHandler, "GET", map[string]string{"filename": ".."}},
+		{auditHandler, "POST", map[string]string{"filename": "../secret.json"}},
+	} {
+		r := mux.SetURLVars(httptest.NewRequest(c.method, "/", nil), c.vars)
+		w := httptest.NewRecorder()
+		c.handler(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%v: Should be a bad request, got %d: %s", c.vars, w.Code, w.Body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	createJobHandler(w, httptest.NewRequest("POST", "/api/jobs", strings.NewReader(`{"filename":"../secret.json"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Jobs should reject traversal, got %d: %s", w.Code, w.Body)
+	}
+	if err := purgeUpload("../secret.json", "test"); err == nil {
+		t.Error("Should not purge files outside uploads")
+	}
+	if _, err := os.Stat("secret.json"); err != nil {
+		t.Errorf("Should leave files outside uploads alone: %v", err)
+	}
+}
+
+// TestUploadTraversal tests that uploads are saved inside uploads/ whatever
+// their name
+func TestUploadTraversal(t *testing.T) {
+	withTestUpload(t)
+
+	for _, name := range []string{`..\..\agent.py`, "..", "...."} {
+		w := httptest.NewRecorder()
+		uploadHandler(w, uploadRequest(t, name, []byte("import os\nprint(os.getcwd())\n")))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: Should be accepted under a safe name, got %d: %s", name, w.Code, w.Body)
+		}
+		var response map[string]string
+		json.NewDecoder(w.Body).Decode(&response)
+		if _, err := uploadPath(response["filename"]); err != nil {
+			t.Errorf("%q: Should be saved under a safe name, got %q", name, response["filename"])
+		}
+		if _, err := os.Stat(filepath.Join("uploads", response["filename"])); err != nil {
+			t.Errorf("%q: Should be saved in uploads: %v", name, err)
+		}
+	}
+
+	entries, _ := os.ReadDir(".")
+	for _, entry := range entries {
+		if entry.Name() != "uploads" {
+			t.Errorf("Should not write outside uploads, found %s", entry.Name())
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// Detector patterns can be updated from a signed feed between releases, the
+// way ClamAV's freshclam fetches virus signatures. AEGONG_PATTERN_FEED_URL
+// names the feed, over HTTP(S) or as a local mirror, with its detached
+// signature next to it at the same location plus ".sig". The feed is checked
+// on a schedule and only applied when its signature verifies against
+// AEGONG_PATTERN_FEED_KEY and its version is newer than the applied one. The
+// last feed applied is kept next to the reports, and verified again before it
+// is reapplied at startup.
+
+// Where the last applied feed and its signature are kept
+var patternFeedPath = filepath.Join("reports", "pattern_feed.json")
+
+const (
+	defaultPatternFeedInterval = 6 * time.Hour
+	patternFeedTimeout         = time.Minute
+	maxPatternFeedSize         = 16 << 20
+)
+
+// patternFeedUpdater fetches, verifies and applies the pattern feed
+type patternFeedUpdater struct {
+	source   string // URL or path of the feed
+	key      crypto.PublicKey
+	interval time.Duration
+	client   *http.Client
+
+	mutex       sync.Mutex
+	lastChecked time.Time
+	lastError   string
+}
+
+// patternFeedStatus is the state of pattern feed updates in /api/admin/status
+type patternFeedStatus struct {
+	Source      string                  `json:"source"`
+	Applied     *aegong.PatternFeedInfo `json:"applied"` // nil until a feed has been applied
+	LastChecked time.Time               `json:"last_checked"`
+	LastError   string                  `json:"last_error,omitempty"` // Why the last check failed
+}
+
+// nil when no pattern feed is configured
+var patternFeed *patternFeedUpdater
+
+// initPatternFeed reads the feed location, signing key and update interval
+// from the environment
+func initPatternFeed() error {
+	source := os.Getenv("AEGONG_PATTERN_FEED_URL")
+	if source == "" {
+		patternFeed = nil
+		return nil
+	}
+	if scheme, _, ok := strings.Cut(source, "://"); ok && scheme != "http" && scheme != "https" && scheme != "file" {
+		return fmt.Errorf("AEGONG_PATTERN_FEED_URL must be an http(s) or file URL, or a path, got %q", source)
+	}
+
+	keyPath := os.Getenv("AEGONG_PATTERN_FEED_KEY")
+	if keyPath == "" {
+		return fmt.Errorf("AEGONG_PATTERN_FEED_KEY must name the public key that signs the pattern feed")
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read pattern feed key: %v", err)
+	}
+	key, err := parsePatternFeedKey(data)
+	if err != nil {
+		return err
+	}
+
+	interval := defaultPatternFeedInterval
+	if value := os.Getenv("AEGONG_PATTERN_FEED_INTERVAL"); value != "" {
+		interval, err = parseMaxAge(value)
+		if err != nil || interval < time.Minute {
+			return fmt.Errorf("AEGONG_PATTERN_FEED_INTERVAL must be a duration of at least 1m, got %q", value)
+		}
+	}
+
+	patternFeed = &patternFeedUpdater{
+		source:   source,
+		key:      key,
+		interval: interval,
+		client:   &http.Client{Timeout: patternFeedTimeout},
+	}
+	return nil
+}
+
+// parsePatternFeedKey parses a PEM encoded public key
+func parsePatternFeedKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("pattern feed key is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pattern feed key: %v", err)
+	}
+	return key, nil
+}
+
+// read fetches a file of the feed from its URL or mirror
+func (u *patternFeedUpdater) read(ctx context.Context, location string) ([]byte, error) {
+	if path, ok := strings.CutPrefix(location, "file://"); ok {
+		location = path
+	}
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		f, err := os.Open(location)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return readFeedFile(f, location)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %s", location, resp.Status)
+	}
+	return readFeedFile(resp.Body, location)
+}
+
+// readFeedFile reads a file of the feed, failing past the size limit
+func readFeedFile(r io.Reader, location string) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxPatternFeedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxPatternFeedSize {
+		return nil, fmt.Errorf("%s is over %d MB", location, maxPatternFeedSize>>20)
+	}
+	return data, nil
+}
+
+// update fetches the feed and applies it when it verifies and is newer. It
+// returns the applied feed, nil when the applied feed is already current.
+func (u *patternFeedUpdater) update(ctx context.Context) (*aegong.PatternFeedInfo, error) {
+	info, err := u.fetchAndApply(ctx)
+	u.mutex.Lock()
+	u.lastChecked = time.Now()
+	u.lastError = ""
+	if err != nil {
+		u.lastError = err.Error()
+	}
+	u.mutex.Unlock()
+	return info, err
+}
+
+func (u *patternFeedUpdater) fetchAndApply(ctx context.Context) (*aegong.PatternFeedInfo, error) {
+	data, err := u.read(ctx, u.source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pattern feed: %v", err)
+	}
+	signature, err := u.read(ctx, u.source+".sig")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pattern feed signature: %v", err)
+	}
+	feed, err := aegong.ParsePatternFeed(data, signature, u.key)
+	if err != nil {
+		return nil, err
+	}
+	if current := engine.PatternFeed(); current != nil && feed.Version <= current.Version {
+		return nil, nil
+	}
+
+	info, err := engine.ApplyPatternFeed(feed, u.source)
+	if err != nil {
+		return nil, err
+	}
+	if err := savePatternFeed(data, signature); err != nil {
+		log.Printf("Warning: Failed to save pattern feed v%d: %v", info.Version, err)
+	}
+	return &info, nil
+}
+
+// savePatternFeed keeps the applied feed and its signature for the next start
+func savePatternFeed(data, signature []byte) error {
+	os.MkdirAll(filepath.Dir(patternFeedPath), 0755)
+	if err := writeStored(patternFeedPath+".sig", signature); err != nil {
+		return err
+	}
+	return writeStored(patternFeedPath, data)
+}
+
+// restore verifies the feed saved by an earlier run and applies it, so audits
+// use its patterns before the first check completes
+func (u *patternFeedUpdater) restore() error {
+	data, err := readStored(patternFeedPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	signature, err := readStored(patternFeedPath + ".sig")
+	if err != nil {
+		return err
+	}
+	feed, err := aegong.ParsePatternFeed(data, signature, u.key)
+	if err != nil {
+		return fmt.Errorf("saved pattern feed: %v", err)
+	}
+	info, err := engine.ApplyPatternFeed(feed, u.source)
+	if err != nil {
+		return fmt.Errorf("saved pattern feed: %v", err)
+	}
+	log.Printf("Info: Applied saved pattern feed v%d with %d patterns", info.Version, info.Patterns)
+	return nil
+}
+
+// status reports the applied feed and the last check
+func (u *patternFeedUpdater) status() *patternFeedStatus {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	status := &patternFeedStatus{Source: u.source, LastChecked: u.lastChecked, LastError: u.lastError}
+	if engine != nil {
+		status.Applied = engine.PatternFeed()
+	}
+	return status
+}
+
+// runPatternFeedUpdates checks the feed at startup and then on its interval
+// until ctx is done
+func runPatternFeedUpdates(ctx context.Context) {
+	if patternFeed == nil {
+		return
+	}
+	ticker := time.NewTicker(patternFeed.interval)
+	defer ticker.Stop()
+
+	for {
+		info, err := patternFeed.update(ctx)
+		switch {
+		case err != nil:
+			log.Printf("Warning: Pattern feed update failed: %v", err)
+		case info != nil:
+			log.Printf("Info: Applied pattern feed v%d with %d patterns from %s", info.Version, info.Patterns, info.Source)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// TestPatternFeedUpdates tests fetching, verifying, saving and restoring the pattern feed
+func TestPatternFeedUpdates(t *testing.T) {
+	withTestUpload(t)
+	oldEngine, oldFeed := engine, patternFeed
+	t.Cleanup(func() { engine, patternFeed = oldEngine, oldFeed })
+	engine, _ = aegong.NewEngine(aegong.Config{})
+	defer engine.Close()
+
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(public)
+	os.WriteFile("feed.pub", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644)
+
+	var mutex sync.Mutex
+	feed := `{"version":1,"patterns":[{"id":"beacon","vector":"T4","pattern":"beacon.example.net","severity":"high"}]}`
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(private, []byte(feed)))
+	publish := func(data, sig string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		feed, signature = data, sig
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch r.URL.Path {
+		case "/feed.json":
+			w.Write([]byte(feed))
+		case "/feed.json.sig":
+			w.Write([]byte(signature))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	for _, c := range []struct {
+		url, key, interval string
+		valid              bool
+	}{
+		{"", "", "", true},
+		{server.URL + "/feed.json", "", "", false},
+		{server.URL + "/feed.json", "missing.pub", "", false},
+		{"ftp://feeds.example.com/feed.json", "feed.pub", "", false},
+		{server.URL + "/feed.json", "feed.pub", "30s", false},
+		{server.URL + "/feed.json", "feed.pub", "1d", true},
+	} {
+		t.Setenv("AEGONG_PATTERN_FEED_URL", c.url)
+		t.Setenv("AEGONG_PATTERN_FEED_KEY", c.key)
+		t.Setenv("AEGONG_PATTERN_FEED_INTERVAL", c.interval)
+		if err := initPatternFeed(); (err == nil) != c.valid {
+			t.Errorf("%+v: Should be valid: %v, got %v", c, c.valid, err)
+		}
+	}
+	if patternFeed == nil || patternFeed.interval.Hours() != 24 {
+		t.Fatalf("Should configure daily updates, got %+v", patternFeed)
+	}
+
+	info, err := patternFeed.update(context.Background())
+	if err != nil || info == nil || info.Version != 1 || engine.PatternFeed() == nil {
+		t.Fatalf("Should apply the signed feed, got %+v (%v)", info, err)
+	}
+	if info, err := patternFeed.update(context.Background()); err != nil || info != nil {
+		t.Fatalf("Should skip a feed that is already applied, got %+v (%v)", info, err)
+	}
+
+	newer := strings.Replace(feed, `"version":1`, `"version":2`, 1)
+	publish(newer, signature)
+	if _, err := patternFeed.update(context.Background()); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatalf("Should refuse a feed whose signature does not match, got %v", err)
+	}
+	if status := collectStatus(); status.PatternFeed == nil || status.PatternFeed.Applied.Version != 1 || !strings.Contains(strings.Join(status.Warnings, "\n"), "Pattern feed update failed") {
+		t.Fatalf("Should report the failed update in the status, got %+v", status.PatternFeed)
+	}
+
+	// The applied feed is saved and verified again after a restart
+	engine, _ = aegong.NewEngine(aegong.Config{})
+	defer engine.Close()
+	if err := patternFeed.restore(); err != nil || engine.PatternFeed() == nil || engine.PatternFeed().Version != 1 {
+		t.Fatalf("Should restore the saved feed, got %+v (%v)", engine.PatternFeed(), err)
+	}
+	os.WriteFile(patternFeedPath, []byte(newer), 0644)
+	engine, _ = aegong.NewEngine(aegong.Config{})
+	defer engine.Close()
+	if err := patternFeed.restore(); err == nil || engine.PatternFeed() != nil {
+		t.Fatal("Should not restore a saved feed that was tampered with")
+	}
+
+	// A local mirror is read from disk
+	os.WriteFile("mirror.json", []byte(newer), 0644)
+	os.WriteFile("mirror.json.sig", ed25519.Sign(private, []byte(newer)), 0644)
+	patternFeed.source = "file://mirror.json"
+	if info, err := patternFeed.update(context.Background()); err != nil || info == nil || info.Version != 2 {
+		t.Fatalf("Should apply the feed from the mirror, got %+v (%v)", info, err)
+	}
+}
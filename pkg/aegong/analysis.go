@@ -0,0 +1,306 @@
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Detectors share one AnalysisContext per phase instead of each re-deriving
+// what it needs from raw bytes. The context knows the format of what is
+// analysed and parses it lazily, once, when a detector first asks.
+
+// AnalysisInput is something a detector reads from the analysed content
+type AnalysisInput string
+
+const (
+	InputText    AnalysisInput = "text"    // Lowercased content, for pattern matching
+	InputStrings AnalysisInput = "strings" // Printable strings of a binary
+	InputSymbols AnalysisInput = "symbols" // Functions an executable imports
+	InputAST     AnalysisInput = "ast"     // Control flow of a parsed script or executable
+	InputTrace   AnalysisInput = "trace"   // Execution log of the dynamic phase
+)
+
+// Formats of analysed content besides those detectFileType reports
+const (
+	FormatPython     = "python"
+	FormatJavaScript = "javascript"
+	FormatText       = "text"   // Text in no language the engine parses
+	FormatBinary     = "binary" // Binary data in no format the engine parses
+	FormatTrace      = "trace"  // Execution log of the dynamic phase
+)
+
+// ContextDetector is a ThreatDetector that declares its inputs. The engine
+// only runs it when the analysed content offers at least one of them, and
+// hands it the phase's shared AnalysisContext.
+type ContextDetector interface {
+	ThreatDetector
+	Inputs() []AnalysisInput
+	Analyze(ctx context.Context, analysis *AnalysisContext) []ThreatDetection
+}
+
+// AnalysisContext is the content a phase analyses with what has been derived
+// from it. Accessors for inputs the format does not offer return nil.
+type AnalysisContext struct {
+	Phase     string
+	Format    string // elf, pe, macho, wasm, python, javascript, text, binary or trace
+	Content   []byte // The agent in the static phase, its execution log in the dynamic phase
+	Container *CustomContainer
+	derived   bool // Decoded or unpacked from the agent, so offsets are not the agent's
+
+	textOnce    sync.Once
+	text        string
+	stringsOnce sync.Once
+	printable   []string
+	symbolsOnce sync.Once
+	symbols     []string
+	controlOnce sync.Once
+	controlFlow *ControlFlowMetrics
+}
+
+// NewAnalysisContext prepares content of a phase for analysis
+func NewAnalysisContext(phase string, content []byte, container *CustomContainer) *AnalysisContext {
+	format := FormatTrace
+	if phase != PhaseDynamic {
+		format = analysisFormat(content)
+	}
+	return &AnalysisContext{Phase: phase, Format: format, Content: content, Container: container}
+}
+
+// analysisFormat identifies content by its magic number, or as a script
+func analysisFormat(content []byte) string {
+	// Without a path, only magic numbers identify a file type
+	switch format := detectFileType(content, ""); format {
+	case "elf", "pe", "macho", "wasm":
+		return format
+	}
+	if lang := detectScriptLanguage(content); lang != nil {
+		return lang.name
+	}
+	if bytes.IndexByte(content[:min(len(content), 8192)], 0) >= 0 {
+		return FormatBinary
+	}
+	return FormatText
+}
+
+// Offers reports whether the format of the content provides an input
+func (a *AnalysisContext) Offers(input AnalysisInput) bool {
+	switch input {
+	case InputText:
+		return true
+	case InputStrings:
+		return a.Format != FormatPython && a.Format != FormatJavaScript && a.Format != FormatText && a.Format != FormatTrace
+	case InputSymbols:
+		return a.Format == "elf" || a.Format == "pe" || a.Format == "macho"
+	case InputAST:
+		return a.Format == FormatPython || a.Format == FormatJavaScript || a.Format == "elf" || a.Format == "pe" || a.Format == "macho"
+	case InputTrace:
+		return a.Format == FormatTrace
+	}
+	return false
+}
+
+// Text is the content lowercased. Offsets into it are offsets into Content:
+// where lowercasing would change the length, only ASCII is lowercased.
+func (a *AnalysisContext) Text() string {
+	a.textOnce.Do(func() {
+		a.text = strings.ToLower(string(a.Content))
+		if len(a.text) != len(a.Content) {
+			lower := make([]byte, len(a.Content))
+			for i, b := range a.Content {
+				if b >= 'A' && b <= 'Z' {
+					b += 'a' - 'A'
+				}
+				lower[i] = b
+			}
+			a.text = string(lower)
+		}
+	})
+	return a.text
+}
+
+// Strings are the runs of at least four printable characters in a binary
+func (a *AnalysisContext) Strings() []string {
+	a.stringsOnce.Do(func() {
+		if a.Offers(InputStrings) {
+			a.printable = strings.Fields(extractStringsFromBinary(a.Content))
+		}
+	})
+	return a.printable
+}
+
+// Symbols are the names of the functions an executable imports, sorted
+func (a *AnalysisContext) Symbols() []string {
+	a.symbolsOnce.Do(func() {
+		if a.Offers(InputSymbols) {
+			a.symbols = importedSymbols(a.Content, a.Format)
+		}
+	})
+	return a.symbols
+}
+
+// ControlFlow measures the functions of a script or x86-64 executable, or is
+// nil if it could not be parsed
+func (a *AnalysisContext) ControlFlow() *ControlFlowMetrics {
+	a.controlOnce.Do(func() {
+		switch {
+		case a.Format == FormatPython || a.Format == FormatJavaScript:
+			a.controlFlow = scriptControlFlow(a.Content)
+		case a.Offers(InputAST):
+			a.controlFlow = binaryControlFlow(a.Content)
+		}
+	})
+	return a.controlFlow
+}
+
+// Trace is the execution log of the dynamic phase
+func (a *AnalysisContext) Trace() string {
+	if !a.Offers(InputTrace) {
+		return ""
+	}
+	return string(a.Content)
+}
+
+// Locate returns where an offset into the agent is, or nil if the content is
+// not the agent itself, such as an execution log
+func (a *AnalysisContext) Locate(offset int) *Location {
+	if a.Format == FormatTrace || a.derived {
+		return nil
+	}
+	lines := a.Format == FormatPython || a.Format == FormatJavaScript || a.Format == FormatText
+	return locate(a.Content, offset, lines)
+}
+
+// earliest returns the smaller of two offsets, either of which may be -1 for none
+func earliest(first, offset int) int {
+	if first < 0 || offset < first {
+		return offset
+	}
+	return first
+}
+
+// Header of each file in a bundle of package sources
+var bundleHeader = regexp.MustCompile(`(?m)^(?:#|//) ---- (.+) ----\n`)
+
+// BundleHeader starts a file in a bundle of sources joined into one script,
+// so findings in the bundle can be located in the file
+func BundleHeader(comment, name string) string {
+	return fmt.Sprintf("%s ---- %s ----\n", comment, name)
+}
+
+// locate finds the file of a bundle an offset falls in, and the line if
+// content is text. Offsets before the first file or past the end have none.
+func locate(content []byte, offset int, lines bool) *Location {
+	if offset < 0 || offset > len(content) {
+		return nil
+	}
+	location := &Location{Offset: offset}
+	start := 0
+	// A bundle starts with its first file's header
+	if headers := bundleHeader.FindAllSubmatchIndex(content, -1); len(headers) > 0 && headers[0][0] == 0 {
+		for _, header := range headers {
+			if header[0] > offset {
+				break
+			}
+			location.File, start = string(content[header[2]:header[3]]), header[1]
+		}
+		if offset < start {
+			return nil
+		}
+		location.Offset = offset - start
+	}
+	if lines {
+		location.Line = bytes.Count(content[start:offset], []byte("\n")) + 1
+	}
+	return location
+}
+
+// lineOffset returns the offset of a line of content, counted from 1
+func lineOffset(content []byte, line int) int {
+	offset := 0
+	for ; line > 1; line-- {
+		next := bytes.IndexByte(content[offset:], '\n')
+		if next < 0 {
+			return -1
+		}
+		offset += next + 1
+	}
+	return offset
+}
+
+// importedSymbols lists the functions an ELF, PE or Mach-O file imports
+func importedSymbols(data []byte, format string) []string {
+	r := bytes.NewReader(data)
+	var imported []string
+	switch format {
+	case "elf":
+		f, err := elf.NewFile(r)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		symbols, _ := f.ImportedSymbols()
+		for _, symbol := range symbols {
+			imported = append(imported, symbol.Name)
+		}
+	case "pe":
+		f, err := pe.NewFile(r)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		symbols, _ := f.ImportedSymbols()
+		for _, symbol := range symbols {
+			// Listed as function:library
+			name, _, _ := strings.Cut(symbol, ":")
+			imported = append(imported, name)
+		}
+	case "macho":
+		f, err := macho.NewFile(r)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		symbols, _ := f.ImportedSymbols()
+		for _, symbol := range symbols {
+			imported = append(imported, strings.TrimPrefix(symbol, "_"))
+		}
+	}
+	sort.Strings(imported)
+	return slices.Compact(imported)
+}
+
+// applies reports whether a detector has anything to read in the analysis,
+// and if not, why
+func (a *AnalysisContext) applies(detector ThreatDetector) (bool, string) {
+	d, ok := detector.(ContextDetector)
+	if !ok {
+		return true, ""
+	}
+	inputs := d.Inputs()
+	names := make([]string, len(inputs))
+	for i, input := range inputs {
+		if a.Offers(input) {
+			return true, ""
+		}
+		names[i] = string(input)
+	}
+	return false, fmt.Sprintf("reads %s, which %s content does not offer", strings.Join(names, ", "), a.Format)
+}
+
+// analyze runs a detector through its Analyze method if it declares its
+// inputs, and over the raw content otherwise
+func analyze(ctx context.Context, detector ThreatDetector, analysis *AnalysisContext) []ThreatDetection {
+	if d, ok := detector.(ContextDetector); ok {
+		return d.Analyze(ctx, analysis)
+	}
+	return detector.DetectThreat(ctx, analysis.Content, analysis.Container)
+}
@@ -0,0 +1,123 @@
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sort"
+	"testing"
+)
+
+// traceDetector only reads the execution log
+type traceDetector struct {
+	traces []string
+}
+
+func (d *traceDetector) DetectThreat(ctx context.Context, binary []byte, container *CustomContainer) []ThreatDetection {
+	return d.Analyze(ctx, NewAnalysisContext(PhaseStatic, binary, container))
+}
+
+func (d *traceDetector) Inputs() []AnalysisInput {
+	return []AnalysisInput{InputTrace}
+}
+
+func (d *traceDetector) Analyze(ctx context.Context, analysis *AnalysisContext) []ThreatDetection {
+	d.traces = append(d.traces, analysis.Trace())
+	return nil
+}
+
+func (d *traceDetector) GetThreatVector() ThreatVector {
+	return T8_OVERSIGHT_SATURATION
+}
+
+// TestAnalysisContext tests that content is identified and parsed by format
+func TestAnalysisContext(t *testing.T) {
+	script := NewAnalysisContext(PhaseStatic, []byte("import os\n\ndef Run(cmd):\n    if cmd:\n        os.system(cmd)\n"), nil)
+	if script.Format != FormatPython || script.Text() != "import os\n\ndef run(cmd):\n    if cmd:\n        os.system(cmd)\n" {
+		t.Errorf("Python agents should be identified and lowercased, got %q", script.Format)
+	}
+	if script.ControlFlow() == nil || script.ControlFlow().Functions != 2 {
+		t.Errorf("Scripts should be parsed for their control flow, got %+v", script.ControlFlow())
+	}
+	if script.Offers(InputSymbols) || script.Symbols() != nil || script.Strings() != nil || script.Trace() != "" {
+		t.Error("Scripts should offer no symbols, strings or trace")
+	}
+
+	trace := NewAnalysisContext(PhaseDynamic, []byte("Process started\n"), nil)
+	if trace.Format != FormatTrace || trace.Trace() != "Process started\n" || trace.ControlFlow() != nil {
+		t.Errorf("The dynamic phase should analyse the execution log, got %q", trace.Format)
+	}
+	if NewAnalysisContext(PhaseStatic, []byte("hello"), nil).Format != FormatText {
+		t.Error("Other text should be identified as text")
+	}
+
+	binary, err := os.ReadFile("/bin/true")
+	if err != nil {
+		t.Skipf("Failed to read /bin/true: %v", err)
+	}
+	executable := NewAnalysisContext(PhaseStatic, binary, nil)
+	if executable.Format != "elf" || len(executable.Strings()) == 0 {
+		t.Fatalf("Executables should be identified and have strings, got %q", executable.Format)
+	}
+	if symbols := executable.Symbols(); len(symbols) == 0 || !sort.StringsAreSorted(symbols) {
+		t.Errorf("Executables should list their imported functions, got %v", symbols)
+	}
+}
+
+// TestAnalysisDispatch tests that detectors only run on content offering their inputs
+func TestAnalysisDispatch(t *testing.T) {
+	engine := newTestEngine(t)
+	detector := &traceDetector{}
+	engine.threatDetectors[T8_OVERSIGHT_SATURATION] = detector
+
+	report, err := engine.Audit(context.Background(), bytes.NewReader([]byte("#!/bin/sh\necho hello\n")))
+	if err != nil {
+		t.Fatalf("Failed to audit agent: %v", err)
+	}
+	for _, component := range report.Coverage.Components {
+		if component.Name != "T8" {
+			continue
+		}
+		if component.Phase == PhaseStatic && (component.Status != CoverageNotApplicable || component.Reason == "") {
+			t.Errorf("A trace detector should not apply to the agent itself, got %+v", component)
+		}
+		if component.Phase == PhaseDynamic && component.Status != CoverageRan {
+			t.Errorf("A trace detector should run on the execution log, got %+v", component)
+		}
+	}
+	if !report.Coverage.Complete {
+		t.Error("Detectors that do not apply should not make a report incomplete")
+	}
+	if len(detector.traces) != 1 || detector.traces[0] == "" {
+		t.Errorf("The detector should be given the execution log once, got %q", detector.traces)
+	}
+}
+
+// TestFindingLocation tests that findings point to the file and line of their evidence
+func TestFindingLocation(t *testing.T) {
+	bundle := []byte(BundleHeader("#", "pkg/a.py") + "print('x')\n" +
+		BundleHeader("#", "pkg/b.py") + "import os\nx = input()\nos.system(x)\n")
+	analysis := NewAnalysisContext(PhaseStatic, bundle, nil)
+	threats := (&UnauthorizedActionDetector{}).Analyze(context.Background(), analysis)
+	if len(threats) != 1 || threats[0].Location == nil || *threats[0].Location != (Location{File: "pkg/b.py", Offset: 22, Line: 3}) {
+		t.Fatalf("Findings in a bundle should name the file and line, got %+v", threats)
+	}
+	if location := threats[0].Location.String(); location != "pkg/b.py:3" {
+		t.Errorf("Location should render as path:line, got %q", location)
+	}
+	if taint := analyzeTaint(bundle); len(taint) == 0 || taint[0].Location == nil || taint[0].Location.File != "pkg/b.py" || taint[0].Location.Line != 3 {
+		t.Errorf("Taint findings should be located at their sink, got %+v", taint)
+	}
+
+	// Offsets survive lowercasing that would change the length of the text
+	binary := NewAnalysisContext(PhaseStatic, []byte("\x00\xffİ SESSION_HIJACK"), nil)
+	threats = (&IdentitySpoofingDetector{}).Analyze(context.Background(), binary)
+	if len(threats) != 1 || threats[0].Location == nil || *threats[0].Location != (Location{Offset: 5}) || threats[0].Location.String() != "offset 5" {
+		t.Errorf("Findings in binaries should have an offset and no line, got %+v", threats)
+	}
+
+	trace := NewAnalysisContext(PhaseDynamic, []byte("session_hijack"), nil)
+	if threats := (&IdentitySpoofingDetector{}).Analyze(context.Background(), trace); len(threats) != 1 || threats[0].Location != nil {
+		t.Errorf("Findings in the execution log should not be located in the agent, got %+v", threats)
+	}
+}
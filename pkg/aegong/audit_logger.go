@@ -0,0 +1,474 @@
+package aegong
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type AuditLogger struct {
+	path    string
+	logFile *os.File
+	mutex   sync.Mutex
+	version func() EngineVersion // Stamps entries with the engine that wrote them
+}
+
+// NewAuditLogger opens (or creates) the append-only audit log at path
+func NewAuditLogger(path string) (*AuditLogger, error) {
+	logFile, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log file: %v", err)
+	}
+
+	return &AuditLogger{
+		path:    path,
+		logFile: logFile,
+	}, nil
+}
+
+func (a *AuditLogger) LogAudit(report *AuditReport) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	// Create immutable log entry
+	logEntry := map[string]interface{}{
+		"timestamp":       report.Timestamp,
+		"agent_hash":      report.AgentHash,
+		"threat_count":    len(report.Threats),
+		"overall_risk":    report.OverallRisk,
+		"threats":         report.Threats,
+		"shield_results":  report.ShieldResults,
+		"recommendations": report.Recommendations,
+	}
+	if report.Engine != nil {
+		logEntry["engine"] = report.Engine
+	}
+	if report.CorrelationID != "" {
+		logEntry["correlation_id"] = report.CorrelationID
+	}
+
+	// Stamp and sign the log entry
+	a.stamp(logEntry)
+	signature := a.signLogEntry(logEntry)
+	logEntry["signature"] = signature
+
+	// Write to log
+	jsonData, _ := json.Marshal(logEntry)
+	a.logFile.WriteString(string(jsonData) + "\n")
+	a.logFile.Sync()
+}
+
+// LogValidationOverride records that an agent was audited despite failing validation
+func (a *AuditLogger) LogValidationOverride(agentHash string, override *ValidationOverride) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	logEntry := map[string]interface{}{
+		"event":                "validation_override",
+		"timestamp":            override.Timestamp,
+		"agent_hash":           agentHash,
+		"actor":                override.Actor,
+		"role":                 override.Role,
+		"validator_confidence": override.Confidence,
+		"validator_reasons":    override.Reasons,
+	}
+	if override.CorrelationID != "" {
+		logEntry["correlation_id"] = override.CorrelationID
+	}
+
+	// Stamp and sign the log entry
+	a.stamp(logEntry)
+	signature := a.signLogEntry(logEntry)
+	logEntry["signature"] = signature
+
+	// Write to log
+	jsonData, _ := json.Marshal(logEntry)
+	a.logFile.WriteString(string(jsonData) + "\n")
+	a.logFile.Sync()
+}
+
+// LogComponentChange records a runtime change to a detector or SHIELD module
+func (a *AuditLogger) LogComponentChange(component string, change *ComponentChange) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	logEntry := map[string]interface{}{
+		"event":     "component_change",
+		"timestamp": change.Timestamp,
+		"component": component,
+		"actor":     change.Actor,
+		"role":      change.Role,
+		"before":    change.Before,
+		"after":     change.After,
+	}
+
+	// Stamp and sign the log entry
+	a.stamp(logEntry)
+	signature := a.signLogEntry(logEntry)
+	logEntry["signature"] = signature
+
+	// Write to log
+	jsonData, _ := json.Marshal(logEntry)
+	a.logFile.WriteString(string(jsonData) + "\n")
+	a.logFile.Sync()
+}
+
+// LogCertificateAccess records a request made with a client certificate
+func (a *AuditLogger) LogCertificateAccess(access *CertificateAccess) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	logEntry := map[string]interface{}{
+		"event":       "certificate_access",
+		"timestamp":   access.Timestamp,
+		"actor":       access.Actor,
+		"role":        access.Role,
+		"subject":     access.Subject,
+		"fingerprint": access.Fingerprint,
+		"method":      access.Method,
+		"path":        access.Path,
+	}
+	if access.CorrelationID != "" {
+		logEntry["correlation_id"] = access.CorrelationID
+	}
+
+	// Stamp and sign the log entry
+	a.stamp(logEntry)
+	signature := a.signLogEntry(logEntry)
+	logEntry["signature"] = signature
+
+	// Write to log
+	jsonData, _ := json.Marshal(logEntry)
+	a.logFile.WriteString(string(jsonData) + "\n")
+	a.logFile.Sync()
+}
+
+// LogFalsePositive records that a user marked a finding as a false positive
+func (a *AuditLogger) LogFalsePositive(fp *FalsePositive) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	logEntry := map[string]interface{}{
+		"event":      "false_positive",
+		"timestamp":  fp.Timestamp,
+		"agent_hash": fp.AgentHash,
+		"threat":     fp.Threat,
+		"vector":     fp.Vector,
+		"patterns":   fp.Patterns,
+		"actor":      fp.Actor,
+		"role":       fp.Role,
+	}
+	if fp.Reason != "" {
+		logEntry["reason"] = fp.Reason
+	}
+	if fp.CorrelationID != "" {
+		logEntry["correlation_id"] = fp.CorrelationID
+	}
+
+	// Stamp and sign the log entry
+	a.stamp(logEntry)
+	signature := a.signLogEntry(logEntry)
+	logEntry["signature"] = signature
+
+	// Write to log
+	jsonData, _ := json.Marshal(logEntry)
+	a.logFile.WriteString(string(jsonData) + "\n")
+	a.logFile.Sync()
+}
+
+// LogReportShare records that a user created a share link for a report
+func (a *AuditLogger) LogReportShare(share *ReportShare) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	logEntry := map[string]interface{}{
+		"event":       "report_share",
+		"timestamp":   share.Timestamp,
+		"share_id":    share.ID,
+		"report_hash": share.ReportHash,
+		"expires_at":  share.ExpiresAt,
+		"actor":       share.Actor,
+		"role":        share.Role,
+	}
+	if share.Profile != "" {
+		logEntry["profile"] = share.Profile
+	}
+	if share.CorrelationID != "" {
+		logEntry["correlation_id"] = share.CorrelationID
+	}
+
+	// Stamp and sign the log entry
+	a.stamp(logEntry)
+	signature := a.signLogEntry(logEntry)
+	logEntry["signature"] = signature
+
+	// Write to log
+	jsonData, _ := json.Marshal(logEntry)
+	a.logFile.WriteString(string(jsonData) + "\n")
+	a.logFile.Sync()
+}
+
+// LogLegalHold records a legal hold being placed or released
+func (a *AuditLogger) LogLegalHold(change *LegalHoldChange) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	logEntry := map[string]interface{}{
+		"event":     "legal_hold",
+		"timestamp": change.Timestamp,
+		"action":    change.Action,
+		"hold":      change.Hold,
+		"actor":     change.Actor,
+		"role":      change.Role,
+	}
+	if change.CorrelationID != "" {
+		logEntry["correlation_id"] = change.CorrelationID
+	}
+	a.write(logEntry)
+}
+
+// LogDeletion records a report or upload deleted through the API
+func (a *AuditLogger) LogDeletion(deletion *RecordDeletion) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	logEntry := map[string]interface{}{
+		"event":     "deletion",
+		"timestamp": deletion.Timestamp,
+		"kind":      deletion.Kind,
+		"target":    deletion.Target,
+		"actor":     deletion.Actor,
+		"role":      deletion.Role,
+	}
+	if deletion.CorrelationID != "" {
+		logEntry["correlation_id"] = deletion.CorrelationID
+	}
+	a.write(logEntry)
+}
+
+// LogReportArchival records a report being archived or restored
+func (a *AuditLogger) LogReportArchival(archival *ReportArchival) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	logEntry := map[string]interface{}{
+		"event":       "report_archival",
+		"timestamp":   archival.Timestamp,
+		"action":      archival.Action,
+		"report_hash": archival.ReportHash,
+		"actor":       archival.Actor,
+		"role":        archival.Role,
+	}
+	if archival.CorrelationID != "" {
+		logEntry["correlation_id"] = archival.CorrelationID
+	}
+	a.write(logEntry)
+}
+
+// write stamps, signs and appends an entry. The caller holds the mutex.
+func (a *AuditLogger) write(logEntry map[string]interface{}) {
+	a.stamp(logEntry)
+	logEntry["signature"] = a.signLogEntry(logEntry)
+	jsonData, _ := json.Marshal(logEntry)
+	a.logFile.WriteString(string(jsonData) + "\n")
+	a.logFile.Sync()
+}
+
+// Prune removes the entries written before cutoff, except those whose time
+// held reports should be kept, and records the pruning as a new entry.
+// Entries without a readable timestamp are kept. It returns the number of
+// entries removed.
+func (a *AuditLogger) Prune(cutoff time.Time, held func(time.Time) bool) (int, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	logFile, err := os.Open(a.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	var kept bytes.Buffer
+	removed := 0
+	scanner := bufio.NewScanner(logFile)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var entry struct {
+			Timestamp time.Time `json:"timestamp"`
+		}
+		if json.Unmarshal(line, &entry) == nil && !entry.Timestamp.IsZero() && entry.Timestamp.Before(cutoff) && !held(entry.Timestamp) {
+			removed++
+			continue
+		}
+		kept.Write(line)
+		kept.WriteByte('\n')
+	}
+	err = scanner.Err()
+	logFile.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	// Replace the log in one rename so a crash leaves the old or the new one.
+	// Windows can't rename over an open file.
+	temp := a.path + ".prune"
+	if err := os.WriteFile(temp, kept.Bytes(), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write pruned audit log: %v", err)
+	}
+	a.logFile.Close()
+	renameErr := os.Rename(temp, a.path)
+	if renameErr != nil {
+		os.Remove(temp)
+	}
+	if a.logFile, err = os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		return 0, fmt.Errorf("failed to reopen audit log: %v", err)
+	}
+	if renameErr != nil {
+		return 0, fmt.Errorf("failed to replace audit log: %v", renameErr)
+	}
+
+	a.write(map[string]interface{}{
+		"event":     "audit_log_pruned",
+		"timestamp": time.Now(),
+		"before":    cutoff,
+		"removed":   removed,
+	})
+	return removed, nil
+}
+
+// stamp records the engine version in an entry that does not already carry one
+func (a *AuditLogger) stamp(entry map[string]interface{}) {
+	if _, ok := entry["engine"]; !ok && a.version != nil {
+		entry["engine"] = a.version()
+	}
+}
+
+func (a *AuditLogger) signLogEntry(entry map[string]interface{}) string {
+	// Create a simple signature for the log entry
+	jsonData, _ := json.Marshal(entry)
+	hash := sha256.Sum256(jsonData)
+	return hex.EncodeToString(hash[:])
+}
+
+// AuditLog returns the engine's audit log, or nil if logging is disabled
+func (e *Engine) AuditLog() *AuditLogger {
+	return e.auditLog
+}
+
+// VerifyLogEntry checks that a log line is an entry whose signature matches
+// its contents, returning the signature
+func VerifyLogEntry(line []byte) (string, error) {
+	var entry struct {
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return "", fmt.Errorf("invalid audit log entry: %v", err)
+	}
+	if entry.Signature == "" {
+		return "", fmt.Errorf("audit log entry is not signed")
+	}
+
+	// The entry was signed as written, less the signature itself. Decoding and
+	// re-encoding it would reorder the fields of nested reports.
+	member := []byte(`"signature":` + strconv.Quote(entry.Signature))
+	signed := bytes.Replace(line, append([]byte(","), member...), nil, 1)
+	if len(signed) == len(line) {
+		signed = bytes.Replace(line, append(member, ','), nil, 1)
+	}
+	hash := sha256.Sum256(signed)
+	if hex.EncodeToString(hash[:]) != entry.Signature {
+		return "", fmt.Errorf("audit log entry signature %.16s... does not match its contents", entry.Signature)
+	}
+	return entry.Signature, nil
+}
+
+// ModTime returns when the audit log was last written
+func (a *AuditLogger) ModTime() (time.Time, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to stat audit log: %v", err)
+	}
+	return info.ModTime(), nil
+}
+
+// Export copies the audit log to w
+func (a *AuditLogger) Export(w io.Writer) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	logFile, err := os.Open(a.path)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer logFile.Close()
+	_, err = io.Copy(w, logFile)
+	return err
+}
+
+// Import appends entries exported from another audit log as they were
+// written, keeping their signatures. Entries already in this log are skipped,
+// and nothing is written if any entry's signature does not match. It returns
+// the number of entries appended.
+func (a *AuditLogger) Import(r io.Reader) (int, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	existing := make(map[string]bool)
+	if logFile, err := os.Open(a.path); err == nil {
+		scanner := bufio.NewScanner(logFile)
+		scanner.Buffer(nil, 16<<20)
+		for scanner.Scan() {
+			if signature, err := VerifyLogEntry(scanner.Bytes()); err == nil {
+				existing[signature] = true
+			}
+		}
+		logFile.Close()
+	}
+
+	var entries [][]byte
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		entry := bytes.TrimSpace(scanner.Bytes())
+		if len(entry) == 0 {
+			continue
+		}
+		signature, err := VerifyLogEntry(entry)
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %v", line, err)
+		}
+		if existing[signature] {
+			continue
+		}
+		existing[signature] = true
+		entries = append(entries, append(append([]byte{}, entry...), '\n'))
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read audit log: %v", err)
+	}
+
+	for _, entry := range entries {
+		if _, err := a.logFile.Write(entry); err != nil {
+			return 0, fmt.Errorf("failed to write audit log: %v", err)
+		}
+	}
+	a.logFile.Sync()
+	return len(entries), nil
+}
+
+func (a *AuditLogger) Close() error {
+	return a.logFile.Close()
+}
@@ -0,0 +1,97 @@
+package aegong
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAuditLogImport tests that exported entries keep verifiable signatures in another log
+func TestAuditLogImport(t *testing.T) {
+	source, err := NewAuditLogger(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer source.Close()
+	source.LogAudit(&AuditReport{
+		AgentHash: "abcdef0123456789",
+		Timestamp: time.Now(),
+		Threats:   []ThreatDetection{{Vector: T4_UNAUTHORIZED_ACTION, Severity: HIGH, Evidence: []string{"<script>"}}},
+	})
+	source.LogComponentChange("T3", &ComponentChange{Actor: "alice", Role: "admin", Timestamp: time.Now()})
+
+	var exported bytes.Buffer
+	if err := source.Export(&exported); err != nil {
+		t.Fatalf("Failed to export audit log: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(exported.String()), "\n") {
+		if _, err := VerifyLogEntry([]byte(line)); err != nil {
+			t.Fatalf("Entries should verify as written: %v", err)
+		}
+	}
+
+	targetPath := filepath.Join(t.TempDir(), "audit.log")
+	target, err := NewAuditLogger(targetPath)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer target.Close()
+	if imported, err := target.Import(bytes.NewReader(exported.Bytes())); err != nil || imported != 2 {
+		t.Fatalf("Should import both entries, got %d: %v", imported, err)
+	}
+	if imported, err := target.Import(bytes.NewReader(exported.Bytes())); err != nil || imported != 0 {
+		t.Fatalf("Entries already in the log should be skipped, got %d: %v", imported, err)
+	}
+	data, _ := os.ReadFile(targetPath)
+	if !bytes.Equal(data, exported.Bytes()) {
+		t.Fatalf("Imported entries should be written unchanged, got:\n%s", data)
+	}
+
+	tampered := bytes.Replace(exported.Bytes(), []byte(`"actor":"alice"`), []byte(`"actor":"mallory"`), 1)
+	if _, err := target.Import(bytes.NewReader(tampered)); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("Tampered entries should be rejected, got %v", err)
+	}
+}
+
+// TestAuditLogPrune tests removing old entries while keeping held ones
+func TestAuditLogPrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := NewAuditLogger(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer logger.Close()
+
+	now := time.Now()
+	old, held := now.Add(-72*time.Hour), now.Add(-48*time.Hour)
+	logger.LogComponentChange("T1", &ComponentChange{Actor: "old", Timestamp: old})
+	logger.LogComponentChange("T2", &ComponentChange{Actor: "held", Timestamp: held})
+	logger.LogComponentChange("T3", &ComponentChange{Actor: "recent", Timestamp: now})
+
+	removed, err := logger.Prune(now.Add(-24*time.Hour), func(ts time.Time) bool { return ts.Equal(held) })
+	if err != nil || removed != 1 {
+		t.Fatalf("Should remove the one old entry, got %d, %v", removed, err)
+	}
+	logger.LogLegalHold(&LegalHoldChange{Action: "placed", Hold: LegalHold{ID: "1", Kind: HoldAuditLog}, Actor: "alice", Timestamp: now})
+
+	data, _ := os.ReadFile(path)
+	log := string(data)
+	if strings.Contains(log, `"actor":"old"`) || !strings.Contains(log, `"actor":"held"`) || !strings.Contains(log, `"actor":"recent"`) {
+		t.Fatalf("Should keep only held and recent entries:\n%s", log)
+	}
+	if !strings.Contains(log, `"event":"audit_log_pruned"`) || !strings.Contains(log, `"event":"legal_hold"`) {
+		t.Fatalf("Should record the pruning and keep logging after it:\n%s", log)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(log), "\n") {
+		if _, err := VerifyLogEntry([]byte(line)); err != nil {
+			t.Fatalf("Entries should still verify after pruning: %v", err)
+		}
+	}
+
+	if removed, err := logger.Prune(now.Add(-24*time.Hour), func(time.Time) bool { return true }); err != nil || removed != 0 {
+		t.Errorf("Held entries should never be removed, got %d, %v", removed, err)
+	}
+}
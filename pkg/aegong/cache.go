@@ -0,0 +1,156 @@
+package aegong
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cache modes for Config.CacheMode
+const (
+	// CacheStatic reuses static detector results and re-runs dynamic analysis
+	CacheStatic = "static"
+	// CacheFull reuses every result, skipping the sandbox entirely
+	CacheFull = "full"
+)
+
+// detectorRevision must be bumped whenever detector logic changes, so stale
+// cached results stop matching
+const detectorRevision = 14
+
+// CacheInfo records that parts of a report came from the result cache
+type CacheInfo struct {
+	Mode          string    `json:"mode"`
+	ConfigVersion string    `json:"config_version"`
+	CachedAt      time.Time `json:"cached_at"`
+}
+
+// cachedResult is what the cache stores for an agent
+type cachedResult struct {
+	ConfigVersion   string                 `json:"config_version"`
+	CachedAt        time.Time              `json:"cached_at"`
+	StaticThreats   []ThreatDetection      `json:"static_threats"`
+	DynamicThreats  []ThreatDetection      `json:"dynamic_threats"`
+	ShieldResults   map[string]interface{} `json:"shield_results"`
+	NetworkCaptures []HoneypotCapture      `json:"network_captures,omitempty"`
+}
+
+// resultCache stores detector results on disk keyed by agent hash and config version
+type resultCache struct {
+	dir     string
+	mode    string
+	version string
+	mutex   sync.RWMutex // Guards version, which changes with component settings
+}
+
+func newResultCache(dir, mode string, e *Engine) (*resultCache, error) {
+	if mode != CacheStatic && mode != CacheFull {
+		return nil, fmt.Errorf("unknown cache mode %q", mode)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	return &resultCache{dir: dir, mode: mode, version: e.configVersion()}, nil
+}
+
+// configVersion fingerprints everything that affects detector output
+func (e *Engine) configVersion() string {
+	var parts []string
+	for vector, detector := range e.threatDetectors {
+		parts = append(parts, fmt.Sprintf("detector:%d:%s", vector, reflect.TypeOf(detector)))
+		if custom, ok := detector.(*CustomVectorDetector); ok {
+			parts = append(parts, fmt.Sprintf("custom:%d:%s", vector, custom.checksum))
+		}
+	}
+	for name, plugin := range e.plugins {
+		parts = append(parts, fmt.Sprintf("plugin:%s:%s", name, plugin.Version()))
+	}
+	for name, module := range e.shieldModules {
+		parts = append(parts, fmt.Sprintf("shield:%s:%s", name, reflect.TypeOf(module)))
+	}
+	parts = append(parts, e.settingsFingerprint()...)
+	parts = append(parts, "syscall-policy:"+e.syscallPolicy.checksum())
+	if e.staticOnly != "" {
+		parts = append(parts, "static-only")
+	}
+	if feed := e.currentFeed(); feed != nil {
+		parts = append(parts, "pattern-feed:"+feed.info.Checksum)
+	}
+	sort.Strings(parts)
+	parts = append(parts,
+		fmt.Sprintf("revision:%d", detectorRevision),
+		fmt.Sprintf("timeout:%s", executionTimeout),
+		fmt.Sprintf("soak:%s", soakDuration()),
+		fmt.Sprintf("clock:%s", clockFingerprint()),
+		fmt.Sprintf("honeypot:%t", honeypotEnabled()),
+		fmt.Sprintf("dependencies:%t", dependencyInstallEnabled()),
+	)
+
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part + "\n"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// currentVersion returns the config version audits starting now are cached under
+func (c *resultCache) currentVersion() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.version
+}
+
+func (c *resultCache) setVersion(version string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.version = version
+}
+
+func (c *resultCache) path(agentHash, version string) string {
+	return filepath.Join(c.dir, agentHash+"-"+version+".json")
+}
+
+// load returns the cached result for an agent, or nil on a miss
+func (c *resultCache) load(agentHash, version string) *cachedResult {
+	data, err := os.ReadFile(c.path(agentHash, version))
+	if err != nil {
+		return nil
+	}
+
+	var result cachedResult
+	if err := json.Unmarshal(data, &result); err != nil || result.ConfigVersion != version {
+		return nil
+	}
+	return &result
+}
+
+// store saves an agent's results, replacing the file atomically so
+// concurrent audits of the same agent never read a partial entry
+func (c *resultCache) store(agentHash, version string, result *cachedResult) error {
+	result.ConfigVersion = version
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, agentHash+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache entry: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache entry: %v", err)
+	}
+	return os.Rename(tmp.Name(), c.path(agentHash, version))
+}
@@ -0,0 +1,11 @@
+# Self-test canary for T1 reasoning path hijacking. Never run it.
+def manipulate_reasoning(chain):
+    chain["reasoning.override"] = True
+    return hijack_logic(chain, "prompt.hijack")
+
+def hijack_logic(chain, marker):
+    chain["thought.injection"] = marker
+    return redirect_decision(chain)
+
+def redirect_decision(chain):
+    return override_conclusion(chain, "decision.override")
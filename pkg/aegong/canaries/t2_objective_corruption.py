@@ -0,0 +1,8 @@
+# Self-test canary for T2 objective function corruption. Never run it.
+def reward_function(state):
+    state["goal.modification"] = "objective.drift"
+    return score_modification(state)
+
+def score_modification(state):
+    state["reward.manipulation"] = utility_override(state)
+    return state["mission.override"]
@@ -0,0 +1,5 @@
+# Self-test canary for T3 memory poisoning. Never run it.
+def poison(store):
+    store["memory.poison"] = "belief.inject"
+    store["knowledge.corrupt"] = "memory.tamper"
+    store["persistent.poison"] = "memory.override"
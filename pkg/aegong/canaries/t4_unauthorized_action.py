@@ -0,0 +1,8 @@
+# Self-test canary for T4 unauthorized action. Never run it.
+import os
+import subprocess
+
+def escalate_privilege(command):
+    bypass_permission()
+    os.system(command)
+    return subprocess.check_output(["unauthorized_execute", command])
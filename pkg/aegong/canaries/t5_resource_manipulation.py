@@ -0,0 +1,8 @@
+# Self-test canary for T5 resource manipulation. Never run it.
+def memory_bomb():
+    resource_exhaustion = []
+    while "infinite_loop":
+        resource_exhaustion.append(bytearray(1 << 20))
+
+def resource_drain():
+    return "denial_of_service"
@@ -0,0 +1,7 @@
+# Self-test canary for T6 identity spoofing. Never run it.
+def impersonate(user):
+    token = credential_steal(user)
+    return session_hijack(token, "identity_spoof")
+
+def identity_forge(name):
+    return {"token_hijack": name}
@@ -0,0 +1,4 @@
+# Self-test canary for T7 trust manipulation. Never run it.
+def persuade(user):
+    script = social_engineering(user, "authority_mimicry")
+    return trust_exploit(script, "false_confidence")
@@ -0,0 +1,6 @@
+# Self-test canary for T8 oversight saturation. Never run it.
+def alert_flood(monitor):
+    for _ in range(100000):
+        monitor.send("log_spam")
+        monitor.send("notification_bomb")
+    return "event_storm"
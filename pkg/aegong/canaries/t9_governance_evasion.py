@@ -0,0 +1,7 @@
+# Self-test canary for T9 governance evasion. Never run it.
+def trace_elimination(logs):
+    logs.clear()
+    return forensic_evasion(logs, "attribution_evasion")
+
+def accountability_bypass(actor):
+    return "responsibility_obscure"
@@ -0,0 +1,290 @@
+package aegong
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Audits with a checkpoint ID save their results as each detector finishes.
+// If the process dies mid-audit the checkpoint still holds a partial report,
+// and auditing again with the same ID skips the phases that had completed.
+
+// ErrNoCheckpoint is returned for audits that have no saved progress
+var ErrNoCheckpoint = errors.New("no checkpoint for this audit")
+
+// PartialAudit records how far an unfinished audit got
+type PartialAudit struct {
+	CompletedPhases []string  `json:"completed_phases"`
+	Phase           string    `json:"phase,omitempty"`      // Phase in progress
+	Components      []string  `json:"components,omitempty"` // Components of that phase that finished
+	StartedAt       time.Time `json:"started_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// checkpointComponent is the result of one finished component of the phase
+// in progress
+type checkpointComponent struct {
+	Name    string            `json:"name"`
+	Threats []ThreatDetection `json:"threats,omitempty"`
+	Result  interface{}       `json:"result,omitempty"` // SHIELD module results
+}
+
+// auditCheckpoint is an audit's progress as saved on disk
+type auditCheckpoint struct {
+	ID              string            `json:"id"`
+	AgentHash       string            `json:"agent_hash"`
+	ConfigVersion   string            `json:"config_version"`
+	Scope           AuditScope        `json:"scope"`
+	StartedAt       time.Time         `json:"started_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	CompletedPhases []string          `json:"completed_phases"`
+	StaticThreats   []ThreatDetection `json:"static_threats,omitempty"`
+	DynamicThreats  []ThreatDetection `json:"dynamic_threats,omitempty"`
+	// What the sandbox observed, needed by checks after the dynamic phase
+	NetworkCaptures []HoneypotCapture  `json:"network_captures,omitempty"`
+	HarnessEvents   []HarnessEvent     `json:"harness_events,omitempty"`
+	Soak            *SoakResult        `json:"soak,omitempty"`
+	Clock           *ClockManipulation `json:"clock,omitempty"`
+	Runtime         *ScriptRuntime     `json:"runtime,omitempty"`
+	// Container state the SHIELD modules check
+	Packing       *PackingAnalysis       `json:"packing,omitempty"`
+	Landlocked    bool                   `json:"landlocked,omitempty"`
+	ShieldResults map[string]interface{} `json:"shield_results,omitempty"`
+	Phase         string                 `json:"phase,omitempty"`
+	Components    []checkpointComponent  `json:"components,omitempty"`
+
+	path string // Empty if the audit is not checkpointed
+}
+
+// checkpointPath is where the checkpoint with an ID is saved
+func (e *Engine) checkpointPath(id string) (string, error) {
+	if e.checkpointDir == "" {
+		return "", fmt.Errorf("checkpoints are disabled")
+	}
+	if id == "" || filepath.Base(id) != id || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid checkpoint ID %q", id)
+	}
+	return filepath.Join(e.checkpointDir, id+".json"), nil
+}
+
+func loadCheckpoint(path string) (*auditCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNoCheckpoint
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	var checkpoint auditCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %v", err)
+	}
+	checkpoint.path = path
+	return &checkpoint, nil
+}
+
+// openCheckpoint resumes the checkpoint of an audit, or starts a new one if
+// it is missing or was saved for another agent, config or scope. Audits
+// without an ID get a checkpoint that is never saved.
+func (e *Engine) openCheckpoint(ctx context.Context, opts AuditOptions, agentHash string) *auditCheckpoint {
+	fresh := &auditCheckpoint{ID: opts.CheckpointID, AgentHash: agentHash, Scope: opts.Scope, StartedAt: time.Now()}
+	if opts.CheckpointID == "" {
+		return fresh
+	}
+	path, err := e.checkpointPath(opts.CheckpointID)
+	if err != nil {
+		logf(ctx, "Warning: Not checkpointing audit: %v", err)
+		return fresh
+	}
+	fresh.path = path
+	fresh.ConfigVersion = e.configVersion()
+
+	checkpoint, err := loadCheckpoint(path)
+	if err != nil {
+		if err != ErrNoCheckpoint {
+			logf(ctx, "Warning: Restarting audit %s: %v", opts.CheckpointID, err)
+		}
+		return fresh
+	}
+	scope, _ := json.Marshal(checkpoint.Scope)
+	wantScope, _ := json.Marshal(opts.Scope)
+	if checkpoint.AgentHash != agentHash || checkpoint.ConfigVersion != fresh.ConfigVersion || string(scope) != string(wantScope) {
+		logf(ctx, "Info: Restarting audit %s, its checkpoint is for another agent or configuration", opts.CheckpointID)
+		return fresh
+	}
+	if len(checkpoint.CompletedPhases) > 0 {
+		logf(ctx, "Info: Resuming audit %s, completed phases: %s", opts.CheckpointID, strings.Join(checkpoint.CompletedPhases, ", "))
+	}
+	// Findings of the interrupted phase are found again when it re-runs
+	checkpoint.Phase, checkpoint.Components = "", nil
+	return checkpoint
+}
+
+// completed reports whether a phase finished before the audit was interrupted
+func (c *auditCheckpoint) completed(phase string) bool {
+	return c != nil && slices.Contains(c.CompletedPhases, phase)
+}
+
+// detected saves the results of one component of the phase in progress
+func (c *auditCheckpoint) detected(ctx context.Context, phase, name string, threats []ThreatDetection, result interface{}) {
+	if c == nil || c.path == "" {
+		return
+	}
+	if c.Phase != phase {
+		c.Phase, c.Components = phase, nil
+	}
+	c.Components = append(c.Components, checkpointComponent{Name: name, Threats: threats, Result: result})
+	c.save(ctx)
+}
+
+// complete saves the end of a phase, whose results the caller has stored
+func (c *auditCheckpoint) complete(ctx context.Context, phase string) {
+	if c == nil || c.path == "" {
+		return
+	}
+	c.CompletedPhases = append(c.CompletedPhases, phase)
+	c.Phase, c.Components = "", nil
+	c.save(ctx)
+}
+
+// save replaces the checkpoint file atomically so a crash never leaves a
+// partial checkpoint. A failed save only loses progress, not the audit.
+func (c *auditCheckpoint) save(ctx context.Context) {
+	c.UpdatedAt = time.Now()
+	if err := c.write(); err != nil {
+		logf(ctx, "Warning: Failed to save checkpoint of audit %s: %v", c.ID, err)
+	}
+}
+
+func (c *auditCheckpoint) write() error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %v", err)
+	}
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(dir, c.ID+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// remove deletes the checkpoint of a finished audit
+func (c *auditCheckpoint) remove() {
+	if c != nil && c.path != "" {
+		os.Remove(c.path)
+	}
+}
+
+// checkpointOf returns the checkpoint of the audit a container belongs to
+func checkpointOf(container *CustomContainer) *auditCheckpoint {
+	if container == nil {
+		return nil
+	}
+	return container.checkpoint
+}
+
+// PartialReport builds a report from the checkpoint of an audit that is still
+// running or was interrupted. It has the findings of every completed phase
+// and of the components of the phase in progress that finished; its risk
+// covers only those findings.
+func (e *Engine) PartialReport(id string) (*AuditReport, error) {
+	path, err := e.checkpointPath(id)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint, err := loadCheckpoint(path)
+	if err != nil {
+		return nil, err
+	}
+	selection, err := e.selection(checkpoint.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	threats := append([]ThreatDetection{}, checkpoint.StaticThreats...)
+	threats = append(threats, checkpoint.DynamicThreats...)
+	shieldResults := make(map[string]interface{})
+	for name, result := range checkpoint.ShieldResults {
+		shieldResults[name] = result
+	}
+	var components []string
+	for _, component := range checkpoint.Components {
+		components = append(components, component.Name)
+		threats = append(threats, component.Threats...)
+		if checkpoint.Phase == PhaseShield && component.Result != nil {
+			shieldResults[component.Name] = component.Result
+		}
+	}
+	threats = selection.filter(threats)
+	for i := range threats {
+		threats[i].VectorName = ThreatName(threats[i].Vector)
+		threats[i].SeverityName = SeverityName(threats[i].Severity)
+	}
+
+	breakdown := e.scoring.breakdown(threats, e.feedbackFactor())
+	report := &AuditReport{
+		AgentHash:       checkpoint.AgentHash,
+		Timestamp:       checkpoint.UpdatedAt,
+		Threats:         threats,
+		ShieldResults:   shieldResults,
+		OverallRisk:     breakdown.Score,
+		RiskLevel:       RiskLevel(breakdown.Score),
+		RiskBreakdown:   breakdown,
+		Recommendations: e.generateRecommendations(threats, shieldResults),
+		Soak:            checkpoint.Soak,
+		Clock:           checkpoint.Clock,
+		Runtime:         checkpoint.Runtime,
+		Partial: &PartialAudit{
+			CompletedPhases: checkpoint.CompletedPhases,
+			Phase:           checkpoint.Phase,
+			Components:      components,
+			StartedAt:       checkpoint.StartedAt,
+			UpdatedAt:       checkpoint.UpdatedAt,
+		},
+	}
+	if report.Partial.CompletedPhases == nil {
+		report.Partial.CompletedPhases = []string{}
+	}
+	if len(checkpoint.NetworkCaptures) > 0 {
+		report.Details = map[string]interface{}{
+			"network_captures": checkpoint.NetworkCaptures,
+		}
+	}
+	return report, nil
+}
+
+// HasCheckpoint reports whether an audit saved progress it can resume from
+func (e *Engine) HasCheckpoint(id string) bool {
+	path, err := e.checkpointPath(id)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// DiscardCheckpoint deletes the checkpoint of an audit that will not be resumed
+func (e *Engine) DiscardCheckpoint(id string) {
+	if path, err := e.checkpointPath(id); err == nil {
+		os.Remove(path)
+	}
+}
@@ -0,0 +1,91 @@
+package aegong
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCheckpointResume tests that an interrupted audit leaves a partial report
+// and resumes after its last completed phase
+func TestCheckpointResume(t *testing.T) {
+	dir := t.TempDir()
+	engine, err := NewEngine(Config{CheckpointDir: dir})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+	agent := []byte("import os\n\ncommand = input()\nos.system(command)\neval(input())\n")
+	opts := AuditOptions{CheckpointID: "job1"}
+
+	// Interrupt the audit once the dynamic phase has completed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = WithObserver(ctx, &AuditObserver{PhaseStarted: func(phase string) {
+		if phase == PhaseShield {
+			cancel()
+		}
+	}})
+	if _, err := engine.auditBinary(ctx, agent, opts); err != context.Canceled {
+		t.Fatalf("Interrupted audit should fail with its context's error, got %v", err)
+	}
+
+	partial, err := engine.PartialReport("job1")
+	if err != nil {
+		t.Fatalf("Interrupted audit should have a partial report: %v", err)
+	}
+	if partial.Partial == nil || len(partial.Partial.CompletedPhases) != 2 || len(partial.Threats) == 0 {
+		t.Fatalf("Partial report should have the findings of the static and dynamic phases, got %+v", partial.Partial)
+	}
+
+	report, err := engine.auditBinary(context.Background(), agent, opts)
+	if err != nil {
+		t.Fatalf("Resumed audit should finish: %v", err)
+	}
+	for _, component := range report.Coverage.Components {
+		resumed := component.Status == CoverageResumed
+		if component.Kind == ComponentDetector && !resumed {
+			t.Errorf("Detectors of completed phases should not run again, got %+v", component)
+		}
+		if component.Phase == PhaseShield && resumed {
+			t.Errorf("The interrupted phase should run again, got %+v", component)
+		}
+	}
+	if !report.Coverage.Complete || len(report.Threats) < len(partial.Threats) || report.Partial != nil {
+		t.Errorf("Resumed report should be complete and keep the checkpointed findings, got %d threats, want at least %d",
+			len(report.Threats), len(partial.Threats))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "job1.json")); !os.IsNotExist(err) {
+		t.Error("The checkpoint should be removed once the report is written")
+	}
+	if _, err := engine.PartialReport("job1"); err != ErrNoCheckpoint {
+		t.Errorf("Finished audits should have no partial report, got %v", err)
+	}
+	if _, err := engine.PartialReport("../job1"); err == nil {
+		t.Error("Checkpoint IDs should not escape the checkpoint directory")
+	}
+}
+
+// TestCheckpointMismatch tests that a checkpoint of another agent is not resumed
+func TestCheckpointMismatch(t *testing.T) {
+	engine, err := NewEngine(Config{CheckpointDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+	opts := AuditOptions{CheckpointID: "job1"}
+
+	checkpoint := engine.openCheckpoint(context.Background(), opts, "abc123")
+	checkpoint.complete(context.Background(), PhaseStatic)
+	if !engine.openCheckpoint(context.Background(), opts, "abc123").completed(PhaseStatic) {
+		t.Fatal("The checkpoint of the same agent should be resumed")
+	}
+	if engine.openCheckpoint(context.Background(), opts, "def456").completed(PhaseStatic) {
+		t.Error("The checkpoint of another agent should not be resumed")
+	}
+	opts.Scope = AuditScope{Vectors: []string{"T4"}}
+	if engine.openCheckpoint(context.Background(), opts, "abc123").completed(PhaseStatic) {
+		t.Error("The checkpoint of another scope should not be resumed")
+	}
+}
@@ -0,0 +1,511 @@
+package aegong
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Trained by cmd/train_classifier from the corpus in testdata/classifier
+//
+//go:embed model/agent_classifier.json
+var embeddedClassifierModel []byte
+
+// ClassifierModel is a logistic regression over agent features with Platt
+// scaling, so Probability returns a calibrated probability that the input is
+// an agent
+type ClassifierModel struct {
+	Version      string    `json:"version"`
+	Features     []string  `json:"features"`
+	Weights      []float64 `json:"weights"`
+	Bias         float64   `json:"bias"`
+	CalibrationA float64   `json:"calibration_a"`
+	CalibrationB float64   `json:"calibration_b"`
+	Threshold    float64   `json:"threshold"`
+}
+
+// ClassifierResult explains the classifier's decision
+type ClassifierResult struct {
+	Model               string                `json:"model"`
+	Probability         float64               `json:"probability"`
+	Threshold           float64               `json:"threshold"`
+	HeuristicIsAgent    bool                  `json:"heuristic_is_agent"`
+	HeuristicConfidence float64               `json:"heuristic_confidence"`
+	TopFeatures         []FeatureContribution `json:"top_features"`
+}
+
+// FeatureContribution is one feature's share of the classifier's score
+type FeatureContribution struct {
+	Feature      string  `json:"feature"`
+	Value        float64 `json:"value"`
+	Contribution float64 `json:"contribution"`
+}
+
+// Keyword groups; each feature is the fraction of its group found in the text
+var classifierKeywordGroups = []struct {
+	name     string
+	keywords []string
+}{
+	{"perception", []string{"sense", "observe", "perceive", "receive", "input(", "listen", "get_observation"}},
+	{"action", []string{"def act", "function act", "respond", "send", "execute", "perform", "output"}},
+	{"reasoning", []string{"decide", "reason", "think", "plan", "evaluate", "choose", "policy"}},
+	{"memory", []string{"memory", "history", "remember", "self.state", "this.state", "context", "store"}},
+	{"llm", []string{"openai", "anthropic", "llm", "chat.completions", "completion", "prompt", "gpt-", "claude", "langchain", "ollama"}},
+	{"tools", []string{"tool", "function_call", "tools=", "subprocess", "os.system", "child_process", "exec("}},
+	{"autonomy", []string{"while true", "while(true)", "setinterval", "schedule.every", "daemon", "run forever", "max_steps", "max_iterations"}},
+	{"network", []string{"http", "requests.", "socket", "fetch(", "urllib", "axios", "websocket"}},
+	{"ml_libraries", []string{"tensorflow", "torch", "keras", "sklearn", "transformers", "onnx", "numpy"}},
+	{"agent_vocabulary", []string{"agent", "goal", "task", "observation", "reward", "environment", "planner", "autonomous"}},
+}
+
+// Import groups; each feature is 1 if a module a script imports, or a
+// function an executable imports, is named in the group
+var classifierImportGroups = []struct {
+	name    string
+	modules []string
+}{
+	{"import_llm", []string{"openai", "anthropic", "langchain", "langchain_openai", "langchain_community", "langgraph", "llama_index", "ollama", "crewai", "autogen", "smolagents", "litellm", "transformers", "generativeai", "cohere", "mistralai", "groq"}},
+	{"import_process", []string{"subprocess", "child_process", "exec", "execa", "pexpect", "execve", "execvp", "posix_spawn", "system", "popen", "createprocessa", "createprocessw", "shellexecutea", "shellexecutew", "winexec"}},
+	{"import_network", []string{"requests", "urllib", "urllib3", "httpx", "aiohttp", "http", "https", "axios", "fetch", "socket", "websocket", "websockets", "ws", "net", "connect", "getaddrinfo", "wsastartup", "internetopena", "internetopenw", "winhttpopen", "curl_easy_perform"}},
+	{"import_ml", []string{"torch", "tensorflow", "keras", "sklearn", "numpy", "gym", "gymnasium", "stable_baselines3", "jax"}},
+}
+
+// Words in the names of functions and classes a script defines that suggest
+// an agent's loop of observing, deciding and acting
+var classifierAgentSymbols = []string{"agent", "act", "think", "plan", "planner", "observe", "perceive", "sense", "decide", "reflect", "tool", "tools", "step", "policy", "reward", "goal", "memory", "remember", "recall"}
+
+var (
+	pyImportPattern     = regexp.MustCompile(`(?m)^\s*import\s+([\w., \t]+)$|^\s*from\s+([\w.]+)\s+import\b`)
+	jsImportPattern     = regexp.MustCompile(`\brequire\(\s*['"]([^'"]+)['"]\s*\)|(?m)^\s*import\s+(?:[^'"\n]*\s+from\s+)?['"]([^'"]+)['"]`)
+	goImportPattern     = regexp.MustCompile(`(?m)^import\s+(?:\w+\s+)?"([^"]+)"|(?s)^import\s*\((.*?)\)`)
+	goImportPathPattern = regexp.MustCompile(`"([^"]+)"`)
+	symbolPatterns      = []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^\s*(?:async\s+)?(?:def|class)\s+(?:self\.)?(\w+)`),                                    // Python and Ruby
+		regexp.MustCompile(`\b(?:function\s*\*?|class)\s+(\w+)`),                                                       // JavaScript
+		regexp.MustCompile(`\b(?:const|let|var)\s+(\w+)\s*=\s*(?:async\s*)?(?:function\b|\([\w\s,]*\)\s*=>|\w+\s*=>)`), // JavaScript
+		regexp.MustCompile(`(?m)^\s*(?:async\s+|static\s+)*(\w+)\s*\([^)]*\)\s*\{`),                                    // JavaScript methods
+		regexp.MustCompile(`(?m)^func\s+(?:\([^)]*\)\s*)?(\w+)`),                                                       // Go
+	}
+	classPattern    = regexp.MustCompile(`(?m)^\s*class\s+\w+|\bclass\s+\w+\s*(?:extends\s+\w+\s*)?\{`)
+	mainLoopPattern = regexp.MustCompile(`while\s*\(?\s*(?:true|1)\s*\)?\s*[:{;]|for\s*\(\s*;\s*;\s*\)|(?m)^\s*for\s*\{|setinterval\(|\bloop\s+do\b|in\s+range\(\s*(?:self\.)?max_\w+`)
+	camelBoundary   = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+)
+
+// ClassifierFeatureNames lists the features extracted by ClassifierFeatures, in order
+func ClassifierFeatureNames() []string {
+	names := []string{"type_script", "type_native", "type_wasm", "type_jar"}
+	for _, group := range classifierKeywordGroups {
+		names = append(names, group.name)
+	}
+	for _, group := range classifierImportGroups {
+		names = append(names, group.name)
+	}
+	return append(names, "symbol_agent", "structure_loop", "structure_classes", "structure_functions", "structure_nesting", "log_size")
+}
+
+// ClassifierFeatures extracts the classifier's feature vector from a file
+func ClassifierFeatures(data []byte, filePath string) []float64 {
+	return classifierFeatures(data, detectFileType(data, filePath))
+}
+
+func classifierFeatures(data []byte, fileType string) []float64 {
+	// Scripts are read as source; everything else through its embedded strings
+	var text string
+	if fileType == "script" {
+		text = strings.ToLower(string(data))
+	} else {
+		text = strings.ToLower(extractStringsFromBinary(data))
+	}
+
+	oneHot := func(match bool) float64 {
+		if match {
+			return 1
+		}
+		return 0
+	}
+
+	features := []float64{
+		oneHot(fileType == "script"),
+		oneHot(fileType == "elf" || fileType == "pe" || fileType == "macho" || fileType == "library" || fileType == "executable"),
+		oneHot(fileType == "wasm"),
+		oneHot(fileType == "jar"),
+	}
+	for _, group := range classifierKeywordGroups {
+		found := 0
+		for _, keyword := range group.keywords {
+			if strings.Contains(text, keyword) {
+				found++
+			}
+		}
+		features = append(features, float64(found)/float64(len(group.keywords)))
+	}
+
+	analysis := NewAnalysisContext(PhaseStatic, data, nil)
+	var imports, symbols []string
+	if fileType == "script" {
+		imports = scriptImports(string(data))
+		symbols = scriptSymbols(string(data))
+	} else {
+		imports = analysis.Symbols()
+	}
+
+	modules := make(map[string]bool)
+	for _, name := range imports {
+		for _, word := range nameWords(name) {
+			modules[word] = true
+		}
+	}
+	for _, group := range classifierImportGroups {
+		features = append(features, oneHot(slices.ContainsFunc(group.modules, func(module string) bool { return modules[module] })))
+	}
+
+	agentSymbols := 0
+	for _, symbol := range symbols {
+		if slices.ContainsFunc(nameWords(symbol), func(word string) bool { return slices.Contains(classifierAgentSymbols, word) }) {
+			agentSymbols++
+		}
+	}
+	features = append(features, math.Min(float64(agentSymbols)/3, 1))
+
+	// Structure: a loop that keeps the program running, classes holding
+	// state, and how many functions there are and how deeply they nest
+	var functions, nesting int
+	if metrics := analysis.ControlFlow(); metrics != nil {
+		functions, nesting = metrics.Functions, metrics.MaxNesting
+	}
+	features = append(features,
+		oneHot(mainLoopPattern.MatchString(text)),
+		math.Min(float64(len(classPattern.FindAllString(string(data), -1)))/3, 1),
+		math.Min(math.Log1p(float64(functions))/math.Log1p(30), 1),
+		math.Min(float64(nesting)/5, 1),
+	)
+
+	// Roughly 0 for empty files and 1 for a few megabytes of text
+	return append(features, math.Min(math.Log1p(float64(len(text)))/15, 1))
+}
+
+// scriptImports lists the modules a Python, JavaScript or Go source imports
+func scriptImports(source string) []string {
+	var imports []string
+	for _, match := range pyImportPattern.FindAllStringSubmatch(source, -1) {
+		if match[2] != "" {
+			imports = append(imports, match[2])
+			continue
+		}
+		// import a, b as c
+		for _, name := range strings.Split(match[1], ",") {
+			if fields := strings.Fields(name); len(fields) > 0 {
+				imports = append(imports, fields[0])
+			}
+		}
+	}
+	for _, match := range jsImportPattern.FindAllStringSubmatch(source, -1) {
+		imports = append(imports, match[1]+match[2])
+	}
+	for _, match := range goImportPattern.FindAllStringSubmatch(source, -1) {
+		if match[1] != "" {
+			imports = append(imports, match[1])
+		}
+		for _, path := range goImportPathPattern.FindAllStringSubmatch(match[2], -1) {
+			imports = append(imports, path[1])
+		}
+	}
+	return imports
+}
+
+// scriptSymbols lists the names of the functions and classes a script defines
+func scriptSymbols(source string) []string {
+	var symbols []string
+	for _, pattern := range symbolPatterns {
+		for _, match := range pattern.FindAllStringSubmatch(source, -1) {
+			switch match[1] {
+			case "if", "for", "while", "switch", "catch", "function", "return":
+				continue
+			}
+			symbols = append(symbols, match[1])
+		}
+	}
+	// A class can match the patterns of more than one language
+	sort.Strings(symbols)
+	return slices.Compact(symbols)
+}
+
+// nameWords splits a module or symbol name into its lowercase words, so
+// "@anthropic-ai/sdk" gives anthropic, ai and sdk and "ReActAgent" gives
+// re, act and agent
+func nameWords(name string) []string {
+	split := func(name string) []string {
+		return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_')
+		})
+	}
+	// Whole words too, so child_process and CreateProcessW still match
+	words := split(name)
+	for _, word := range split(camelBoundary.ReplaceAllString(name, "${1}_${2}")) {
+		words = append(words, strings.Split(word, "_")...)
+	}
+	return words
+}
+
+// score returns the uncalibrated logit for a feature vector
+func (m *ClassifierModel) score(features []float64) float64 {
+	z := m.Bias
+	for i, weight := range m.Weights {
+		z += weight * features[i]
+	}
+	return z
+}
+
+// Probability returns the calibrated probability that the features describe an agent
+func (m *ClassifierModel) Probability(features []float64) float64 {
+	return sigmoid(m.CalibrationA*m.score(features) + m.CalibrationB)
+}
+
+// validate checks that the model matches the features this build extracts
+func (m *ClassifierModel) validate() error {
+	names := ClassifierFeatureNames()
+	if len(m.Features) != len(names) || len(m.Weights) != len(names) {
+		return fmt.Errorf("model has %d features, expected %d", len(m.Weights), len(names))
+	}
+	for i, name := range names {
+		if m.Features[i] != name {
+			return fmt.Errorf("model feature %d is %q, expected %q", i, m.Features[i], name)
+		}
+	}
+	return nil
+}
+
+// classifierTrainedOn reports whether the corpus the model was trained on has
+// files of a type; it holds only scripts
+func classifierTrainedOn(fileType string) bool {
+	return fileType == "script"
+}
+
+// classify replaces the heuristic verdict with the classifier's
+func (m *ClassifierModel) classify(result *AgentValidationResult, data []byte, fileType string) {
+	features := classifierFeatures(data, fileType)
+	probability := m.Probability(features)
+
+	contributions := make([]FeatureContribution, 0, len(features))
+	for i, value := range features {
+		if value == 0 {
+			continue
+		}
+		contributions = append(contributions, FeatureContribution{
+			Feature:      m.Features[i],
+			Value:        value,
+			Contribution: m.Weights[i] * value,
+		})
+	}
+	sort.Slice(contributions, func(i, j int) bool {
+		return math.Abs(contributions[i].Contribution) > math.Abs(contributions[j].Contribution)
+	})
+	if len(contributions) > 5 {
+		contributions = contributions[:5]
+	}
+
+	result.Classifier = &ClassifierResult{
+		Model:               m.Version,
+		Probability:         probability,
+		Threshold:           m.Threshold,
+		HeuristicIsAgent:    result.IsAgent,
+		HeuristicConfidence: result.Confidence,
+		TopFeatures:         contributions,
+	}
+	result.IsAgent = probability >= m.Threshold
+	result.Confidence = probability
+	result.Reasons = append(result.Reasons, fmt.Sprintf("Classifier %s estimates a %.0f%% probability of an AI agent (threshold %.0f%%)",
+		m.Version, probability*100, m.Threshold*100))
+}
+
+var (
+	classifierOnce  sync.Once
+	classifierModel *ClassifierModel
+)
+
+// classifierEnabled returns false when AEGONG_DISABLE_CLASSIFIER=1
+func classifierEnabled() bool {
+	return os.Getenv("AEGONG_DISABLE_CLASSIFIER") != "1"
+}
+
+// defaultClassifier returns the embedded model, or nil if it is disabled or unusable
+func defaultClassifier() *ClassifierModel {
+	if !classifierEnabled() {
+		return nil
+	}
+
+	classifierOnce.Do(func() {
+		model, err := ParseClassifierModel(embeddedClassifierModel)
+		if err != nil {
+			log.Printf("Warning: Agent classifier unavailable: %v", err)
+			return
+		}
+		classifierModel = model
+	})
+	return classifierModel
+}
+
+// ParseClassifierModel decodes and checks a model written by TrainClassifier
+func ParseClassifierModel(data []byte) (*ClassifierModel, error) {
+	var model ClassifierModel
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("failed to parse classifier model: %v", err)
+	}
+	if err := model.validate(); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// LoadClassifierCorpus extracts the features of a labelled corpus laid out as
+// <dir>/agent/* and <dir>/other/*, agents first and each class in name order
+func LoadClassifierCorpus(dir string) ([][]float64, []bool, error) {
+	var samples [][]float64
+	var labels []bool
+	for _, class := range []struct {
+		dir   string
+		agent bool
+	}{{"agent", true}, {"other", false}} {
+		paths, err := filepath.Glob(filepath.Join(dir, class.dir, "*"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list %s samples: %v", class.dir, err)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read sample: %v", err)
+			}
+			samples = append(samples, ClassifierFeatures(data, path))
+			labels = append(labels, class.agent)
+		}
+	}
+	return samples, labels, nil
+}
+
+// ClassifierHoldout marks the samples TrainClassifier keeps out of fitting
+// the weights to calibrate on instead: every third sample of each class, so
+// both classes are represented
+func ClassifierHoldout(labels []bool) []bool {
+	heldOut := make([]bool, len(labels))
+	seen := map[bool]int{}
+	for i, label := range labels {
+		seen[label]++
+		heldOut[i] = seen[label]%3 == 0
+	}
+	return heldOut
+}
+
+// TrainClassifier fits a logistic regression to labelled feature vectors and
+// calibrates its output with Platt scaling. The calibration is fit on the
+// samples ClassifierHoldout keeps back, as scores of samples the weights were
+// fit to are overconfident.
+func TrainClassifier(version string, samples [][]float64, labels []bool) *ClassifierModel {
+	names := ClassifierFeatureNames()
+	model := &ClassifierModel{
+		Version:      version,
+		Features:     names,
+		Weights:      make([]float64, len(names)),
+		CalibrationA: 1,
+		Threshold:    0.5,
+	}
+	if len(samples) == 0 {
+		return model
+	}
+
+	var train, calibrate [][]float64
+	var trainLabels, calibrateLabels []bool
+	for i, heldOut := range ClassifierHoldout(labels) {
+		if heldOut {
+			calibrate = append(calibrate, samples[i])
+			calibrateLabels = append(calibrateLabels, labels[i])
+		} else {
+			train = append(train, samples[i])
+			trainLabels = append(trainLabels, labels[i])
+		}
+	}
+	model.fitWeights(train, trainLabels)
+	model.fitCalibration(calibrate, calibrateLabels)
+	return model
+}
+
+// Gradient descent steps when fitting the weights and the calibration
+const classifierEpochs = 5000
+
+// fitWeights fits the logistic regression
+func (m *ClassifierModel) fitWeights(samples [][]float64, labels []bool) {
+	if len(samples) == 0 {
+		return
+	}
+	const (
+		learningRate = 0.5
+		l2           = 0.001
+	)
+	n := float64(len(samples))
+
+	// Batch gradient descent on the L2 regularised log loss
+	for epoch := 0; epoch < classifierEpochs; epoch++ {
+		gradients := make([]float64, len(m.Weights))
+		biasGradient := 0.0
+		for i, features := range samples {
+			err := sigmoid(m.score(features)) - boolToFloat(labels[i])
+			for j, value := range features {
+				gradients[j] += err * value
+			}
+			biasGradient += err
+		}
+		for j := range m.Weights {
+			m.Weights[j] -= learningRate * (gradients[j]/n + l2*m.Weights[j])
+		}
+		m.Bias -= learningRate * biasGradient / n
+	}
+}
+
+// fitCalibration fits Platt scaling to the scores of held out samples, with
+// smoothed targets so the number of samples limits overconfidence
+func (m *ClassifierModel) fitCalibration(samples [][]float64, labels []bool) {
+	if len(samples) == 0 {
+		return
+	}
+	n := float64(len(samples))
+	positives := 0.0
+	for _, label := range labels {
+		positives += boolToFloat(label)
+	}
+	high := (positives + 1) / (positives + 2)
+	low := 1 / (n - positives + 2)
+	for epoch := 0; epoch < classifierEpochs; epoch++ {
+		gradientA, gradientB := 0.0, 0.0
+		for i, features := range samples {
+			z := m.score(features)
+			target := low
+			if labels[i] {
+				target = high
+			}
+			err := sigmoid(m.CalibrationA*z+m.CalibrationB) - target
+			gradientA += err * z
+			gradientB += err
+		}
+		m.CalibrationA -= 0.1 * gradientA / n
+		m.CalibrationB -= 0.1 * gradientB / n
+	}
+}
+
+func sigmoid(z float64) float64 {
+	return 1 / (1 + math.Exp(-z))
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,208 @@
+package aegong
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestEmbeddedClassifierModel tests that the shipped model loads and matches the feature extractor
+func TestEmbeddedClassifierModel(t *testing.T) {
+	model, err := ParseClassifierModel(embeddedClassifierModel)
+	if err != nil {
+		t.Fatalf("Embedded model should load: %v", err)
+	}
+
+	// The model should still separate the corpus it was trained on
+	for _, class := range []struct {
+		dir   string
+		agent bool
+	}{{"agent", true}, {"other", false}} {
+		paths, _ := filepath.Glob(filepath.Join("testdata", "classifier", class.dir, "*"))
+		if len(paths) == 0 {
+			t.Fatalf("Corpus directory %s should not be empty", class.dir)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			probability := model.Probability(ClassifierFeatures(data, path))
+			if (probability >= model.Threshold) != class.agent {
+				t.Fatalf("%s should be classified as agent=%v, got probability %.2f", path, class.agent, probability)
+			}
+		}
+	}
+}
+
+// TestParseClassifierModelMismatch tests that models trained on other features are rejected
+func TestParseClassifierModelMismatch(t *testing.T) {
+	if _, err := ParseClassifierModel([]byte(`{"features": ["a"], "weights": [1]}`)); err == nil {
+		t.Fatal("Model with unknown features should be rejected")
+	}
+	if _, err := ParseClassifierModel([]byte(`not json`)); err == nil {
+		t.Fatal("Malformed model should be rejected")
+	}
+}
+
+// TestValidateAgentClassifierFallback tests that disabling the classifier falls back to the heuristic
+func TestValidateAgentClassifierFallback(t *testing.T) {
+	path := filepath.Join("testdata", "classifier", "agent", "monitor_daemon_agent.py")
+
+	result, err := ValidateAgent(path)
+	if err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+	if result.Classifier == nil || result.Confidence != result.Classifier.Probability {
+		t.Fatalf("Classifier should decide the result: %+v", result.Classifier)
+	}
+
+	t.Setenv("AEGONG_DISABLE_CLASSIFIER", "1")
+	result, err = ValidateAgent(path)
+	if err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+	if result.Classifier != nil {
+		t.Fatal("Disabled classifier should not be consulted")
+	}
+	if !result.IsAgent {
+		t.Fatalf("Heuristic should still accept the agent: %+v", result.Reasons)
+	}
+}
+
+// TestValidateAgentClassifierScriptsOnly tests that executables keep the heuristic verdict
+func TestValidateAgentClassifierScriptsOnly(t *testing.T) {
+	// The test binary is a plain Go executable of the host's format
+	path, err := os.Executable()
+	if err != nil {
+		t.Skipf("No test executable: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	heuristic, err := validateHeuristic(data, path)
+	if err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+
+	result, err := ValidateAgent(path)
+	if err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+	if result.Classifier != nil {
+		t.Fatalf("Classifier should not judge executables it was not trained on, got %+v", result.Classifier)
+	}
+	if result.IsAgent != heuristic.IsAgent || result.Confidence != heuristic.Confidence {
+		t.Fatalf("Executables should keep the heuristic verdict %v/%.2f, got %v/%.2f", heuristic.IsAgent, heuristic.Confidence, result.IsAgent, result.Confidence)
+	}
+	if !slices.ContainsFunc(result.Reasons, func(reason string) bool { return strings.Contains(reason, "not trained on") }) {
+		t.Fatalf("Should say why the classifier was skipped: %v", result.Reasons)
+	}
+}
+
+// TestClassifierFeatures tests the import, symbol and structure features
+func TestClassifierFeatures(t *testing.T) {
+	source := []byte(`import Anthropic from "@anthropic-ai/sdk";
+const { execSync } = require("child_process");
+
+class Planner {
+  planStep(goal) { return goal; }
+}
+
+async function observeWorld() { return execSync("ls").toString(); }
+
+while (true) {
+  observeWorld();
+}
+`)
+	names := ClassifierFeatureNames()
+	features := ClassifierFeatures(source, "agent.js")
+	value := func(name string) float64 {
+		return features[slices.Index(names, name)]
+	}
+
+	for name, want := range map[string]float64{
+		"import_llm":        1,
+		"import_process":    1,
+		"import_network":    0,
+		"import_ml":         0,
+		"symbol_agent":      1, // Planner, planStep and observeWorld
+		"structure_loop":    1,
+		"structure_classes": 1.0 / 3,
+	} {
+		if got := value(name); got != want {
+			t.Errorf("%s should be %.2f, got %.2f", name, want, got)
+		}
+	}
+	if value("structure_functions") == 0 {
+		t.Error("Should count the functions of the script")
+	}
+
+	if got := scriptImports("import os, numpy as np\nfrom langchain.agents import initialize_agent\n"); !slices.Equal(got, []string{"os", "numpy", "langchain.agents"}) {
+		t.Errorf("Should list Python imports, got %v", got)
+	}
+	if got := scriptImports("import (\n\t\"fmt\"\n\t\"os/exec\"\n)\n"); !slices.Equal(got, []string{"fmt", "os/exec"}) {
+		t.Errorf("Should list Go imports, got %v", got)
+	}
+}
+
+// TestClassifierCalibration tests that probabilities are calibrated on samples
+// the model was not trained on, by training on two thirds of the corpus and
+// scoring the rest, for each third
+func TestClassifierCalibration(t *testing.T) {
+	samples, labels, err := LoadClassifierCorpus(filepath.Join("testdata", "classifier"))
+	if err != nil || len(samples) == 0 {
+		t.Fatalf("Corpus should load: %v", err)
+	}
+
+	// Each class is dealt round robin into three folds
+	fold := make([]int, len(labels))
+	seen := map[bool]int{}
+	for i, label := range labels {
+		fold[i] = seen[label] % 3
+		seen[label]++
+	}
+
+	probabilities := make([]float64, len(samples))
+	for k := 0; k < 3; k++ {
+		var train [][]float64
+		var trainLabels []bool
+		for i := range samples {
+			if fold[i] != k {
+				train = append(train, samples[i])
+				trainLabels = append(trainLabels, labels[i])
+			}
+		}
+		model := TrainClassifier("test", train, trainLabels)
+		for i := range samples {
+			if fold[i] == k {
+				probabilities[i] = model.Probability(samples[i])
+			}
+		}
+	}
+
+	var brier, predicted, agents float64
+	for i, probability := range probabilities {
+		label := boolToFloat(labels[i])
+		brier += (probability - label) * (probability - label)
+		predicted += probability
+		agents += label
+		if probability < 0.01 || probability > 0.99 {
+			t.Errorf("Sample %d should not be scored with near certainty from so small a corpus, got %.3f", i, probability)
+		}
+	}
+	n := float64(len(samples))
+	brier /= n
+
+	// A constant guess of the agent fraction scores about 0.25
+	if brier > 0.1 {
+		t.Errorf("Held out Brier score should be below 0.1, got %.3f", brier)
+	}
+	if math.Abs(predicted/n-agents/n) > 0.1 {
+		t.Errorf("Mean held out probability %.2f should be near the fraction of agents %.2f", predicted/n, agents/n)
+	}
+}
@@ -0,0 +1,174 @@
+package aegong
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Clock manipulation moves the agent's clock forward and speeds it up during
+// dynamic analysis, so time bombs and payloads that wait hours or activate on
+// a later date fire inside the audit window. Script agents get a patched clock
+// from their harness; dynamically linked binaries get libfaketime.
+
+// Environment variable the harnesses read the clock from, as "offset:rate"
+const clockEnv = "AEGONG_CLOCK"
+
+// Perceived wait after which later actions count as delayed
+const clockDelayThreshold = 5 * time.Minute
+
+// Where libfaketime is installed by common distributions
+var libfaketimePaths = []string{
+	"/usr/lib/x86_64-linux-gnu/faketime/libfaketime.so.1",
+	"/usr/lib/aarch64-linux-gnu/faketime/libfaketime.so.1",
+	"/usr/lib/faketime/libfaketime.so.1",
+	"/usr/local/lib/faketime/libfaketime.so.1",
+}
+
+// clockConfig returns how far ahead the agent's clock starts and how many
+// perceived seconds pass per real second. A zero offset and a rate of 1 leave
+// the clock alone.
+func clockConfig() (offset time.Duration, rate float64) {
+	rate = 1
+	if value := os.Getenv("AEGONG_CLOCK_OFFSET"); value != "" {
+		if days, ok := strings.CutSuffix(value, "d"); ok {
+			if n, err := strconv.Atoi(days); err == nil {
+				offset = time.Duration(n) * 24 * time.Hour
+			}
+		} else if d, err := time.ParseDuration(value); err == nil {
+			offset = d
+		}
+	}
+	if value, err := strconv.ParseFloat(os.Getenv("AEGONG_CLOCK_RATE"), 64); err == nil && value >= 1 {
+		rate = value
+	}
+	return max(offset, 0), rate
+}
+
+// clockFingerprint identifies the clock settings for the result cache
+func clockFingerprint() string {
+	offset, rate := clockConfig()
+	return fmt.Sprintf("%s:%g", offset, rate)
+}
+
+// ClockManipulation records how the agent's clock was shifted
+type ClockManipulation struct {
+	Offset float64 `json:"offset_seconds"` // How far ahead the clock started
+	Rate   float64 `json:"rate"`           // Perceived seconds per real second
+	Method string  `json:"method"`         // harness, libfaketime or none
+	Note   string  `json:"note,omitempty"` // Why the clock could not be shifted
+}
+
+// findLibfaketime returns the libfaketime library to preload, if installed
+func findLibfaketime() string {
+	candidates := libfaketimePaths
+	if path := os.Getenv("AEGONG_LIBFAKETIME"); path != "" {
+		candidates = []string{path}
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// dynamicallyLinked reports whether binary is an ELF executable that loads
+// libc through the dynamic linker, and so honours LD_PRELOAD
+func dynamicallyLinked(binary []byte) bool {
+	file, err := elf.NewFile(bytes.NewReader(binary))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	for _, prog := range file.Progs {
+		if prog.Type == elf.PT_INTERP {
+			return true
+		}
+	}
+	return false
+}
+
+// applyClock sets up cmd to run with the configured clock, or returns nil when
+// clock manipulation is off
+func applyClock(cmd *exec.Cmd, binary []byte, harnessed bool) *ClockManipulation {
+	offset, rate := clockConfig()
+	if offset <= 0 && rate <= 1 {
+		return nil
+	}
+	clock := &ClockManipulation{Offset: offset.Seconds(), Rate: rate, Method: "none"}
+
+	var env []string
+	switch {
+	case harnessed:
+		clock.Method = "harness"
+		env = []string{fmt.Sprintf("%s=%g:%g", clockEnv, clock.Offset, rate)}
+	case !dynamicallyLinked(binary):
+		clock.Note = "not a dynamically linked executable, so libfaketime cannot be preloaded"
+	default:
+		library := findLibfaketime()
+		if library == "" {
+			clock.Note = "libfaketime is not installed"
+			break
+		}
+		clock.Method = "libfaketime"
+		env = []string{
+			"LD_PRELOAD=" + library,
+			fmt.Sprintf("FAKETIME=+%d x%g", int64(clock.Offset), rate),
+		}
+	}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return clock
+}
+
+// clockThreats flags actions a script agent only took after waiting out a
+// long perceived delay, which the accelerated clock brought into the audit
+func (c *CustomContainer) clockThreats() []ThreatDetection {
+	if c.Clock == nil || c.Clock.Method != "harness" {
+		return nil
+	}
+
+	var waited float64
+	var delayed []HarnessEvent
+	var evidence []string
+	for _, event := range c.HarnessEvents {
+		if event.Event == "wait" {
+			if seconds, err := strconv.ParseFloat(event.Detail, 64); err == nil && seconds >= clockDelayThreshold.Seconds() && waited == 0 {
+				waited = seconds
+			}
+			continue
+		}
+		mapping, ok := harnessEventThreats[event.Event]
+		if !ok || waited == 0 {
+			continue
+		}
+		delayed = append(delayed, event)
+		if len(evidence) < 10 {
+			evidence = append(evidence, fmt.Sprintf("After waiting %s: %s %s", time.Duration(waited*float64(time.Second)), mapping.description, event.Detail))
+		}
+	}
+	if len(delayed) == 0 {
+		return nil
+	}
+
+	return []ThreatDetection{{
+		Vector:     T9_GOVERNANCE_EVASION,
+		Severity:   HIGH,
+		Confidence: 0.8,
+		Evidence:   evidence,
+		Timestamp:  time.Now(),
+		Details: map[string]interface{}{
+			"analysis":       "clock",
+			"waited_seconds": waited,
+			"clock":          c.Clock,
+			"events":         delayed,
+		},
+	}}
+}
@@ -0,0 +1,99 @@
+package aegong
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestClockThreats tests that actions taken after a long perceived wait are reported
+func TestClockThreats(t *testing.T) {
+	container := &CustomContainer{
+		Clock: &ClockManipulation{Rate: 3600, Method: "harness"},
+		HarnessEvents: []HarnessEvent{
+			{Event: "subprocess", Detail: "['date']"},
+			{Event: "wait", Detail: "60"},
+			{Event: "import", Detail: "socket"},
+			{Event: "wait", Detail: "86400"},
+			{Event: "connect", Detail: "('10.0.0.1', 443)"},
+		},
+	}
+	threats := container.clockThreats()
+	if len(threats) != 1 || threats[0].Vector != T9_GOVERNANCE_EVASION || threats[0].Details["waited_seconds"] != 86400.0 {
+		t.Fatalf("Connecting after a day's wait should raise a T9 finding, got %+v", threats)
+	}
+	if len(threats[0].Evidence) != 1 || !strings.Contains(threats[0].Evidence[0], "After waiting 24h0m0s") {
+		t.Fatalf("Only the delayed action should be evidence, got %v", threats[0].Evidence)
+	}
+
+	container.HarnessEvents = container.HarnessEvents[:4]
+	if threats := container.clockThreats(); len(threats) != 0 {
+		t.Fatalf("Waiting without acting should raise nothing, got %+v", threats)
+	}
+}
+
+// TestApplyClock tests how the clock is shifted for each kind of agent
+func TestApplyClock(t *testing.T) {
+	t.Setenv("AEGONG_CLOCK_OFFSET", "30d")
+	t.Setenv("AEGONG_CLOCK_RATE", "60")
+	t.Setenv("AEGONG_LIBFAKETIME", "/nonexistent/libfaketime.so.1")
+
+	cmd := exec.Command("true")
+	clock := applyClock(cmd, []byte("print('hi')"), true)
+	if clock == nil || clock.Method != "harness" || clock.Offset != 30*86400 || clock.Rate != 60 {
+		t.Fatalf("Harnessed agents should get the clock from their harness, got %+v", clock)
+	}
+	if !strings.HasSuffix(cmd.Env[len(cmd.Env)-1], "AEGONG_CLOCK=2.592e+06:60") {
+		t.Fatalf("Harness should be given the offset and rate, got %v", cmd.Env[len(cmd.Env)-1])
+	}
+
+	cmd = exec.Command("true")
+	if clock := applyClock(cmd, []byte("#!/bin/sh\ndate\n"), false); clock.Method != "none" || clock.Note == "" || cmd.Env != nil {
+		t.Fatalf("Scripts without a harness should run with the real clock, got %+v", clock)
+	}
+
+	t.Setenv("AEGONG_CLOCK_OFFSET", "")
+	t.Setenv("AEGONG_CLOCK_RATE", "")
+	if clock := applyClock(exec.Command("true"), nil, true); clock != nil {
+		t.Fatalf("Clock should be left alone by default, got %+v", clock)
+	}
+}
+
+// TestClockAcceleration tests that a Python agent's delayed payload runs within the audit
+func TestClockAcceleration(t *testing.T) {
+	requireSandbox(t)
+	if pythonHarness.interpreter() == "" {
+		t.Skip("python3 is not installed")
+	}
+	t.Setenv("AEGONG_CLOCK_OFFSET", "400d")
+	t.Setenv("AEGONG_CLOCK_RATE", "3600")
+	engine := newTestEngine(t)
+
+	container, err := engine.createIsolatedContainer("clock-test")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer engine.destroyContainer(container.ID)
+
+	// A time bomb set to go off in 300 days, after a two hour sleep
+	trigger := time.Now().AddDate(0, 0, 300)
+	agent := []byte(fmt.Sprintf(`import datetime
+import subprocess
+import time
+
+time.sleep(2 * 3600)
+if datetime.date.today() >= datetime.date(%d, %d, %d):
+    subprocess.run(["true"])
+`, trigger.Year(), trigger.Month(), trigger.Day()))
+	executionLog := engine.simulateExecution(context.Background(), agent, container)
+	if container.Harness != "python" || container.Clock == nil {
+		t.Fatalf("Python agent should run under the harness with a shifted clock:\n%s", executionLog)
+	}
+	threats := container.clockThreats()
+	if len(threats) != 1 || threats[0].Details["waited_seconds"] != 7200.0 {
+		t.Fatalf("Subprocess after a two hour sleep should be reported, got %+v\n%s", threats, executionLog)
+	}
+}
@@ -0,0 +1,200 @@
+package aegong
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Component kinds
+const (
+	ComponentDetector = "detector"
+	ComponentShield   = "shield"
+)
+
+// ComponentStatus describes a threat detector or SHIELD module and its
+// runtime settings. Detectors are named T1 to T9.
+type ComponentStatus struct {
+	Name          string  `json:"name"`
+	Kind          string  `json:"kind"`
+	Description   string  `json:"description"`
+	Enabled       bool    `json:"enabled"`
+	MinConfidence float64 `json:"min_confidence,omitempty"` // Detector findings below this are dropped
+}
+
+// ComponentUpdate changes a component's settings; nil fields are left unchanged
+type ComponentUpdate struct {
+	Enabled       *bool    `json:"enabled,omitempty"`
+	MinConfidence *float64 `json:"min_confidence,omitempty"`
+}
+
+// ComponentChange records who changed a component's settings
+type ComponentChange struct {
+	Actor     string          `json:"actor"`
+	Role      string          `json:"role"`
+	Before    ComponentStatus `json:"before"`
+	After     ComponentStatus `json:"after"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// componentSettings are the runtime overrides of a component; the zero value
+// is enabled with no confidence threshold
+type componentSettings struct {
+	disabled      bool
+	minConfidence float64
+}
+
+func detectorName(vector ThreatVector) string {
+	return fmt.Sprintf("T%d", int(vector)+1)
+}
+
+// Components lists every detector and SHIELD module with its settings
+func (e *Engine) Components() []ComponentStatus {
+	e.settingsMutex.RLock()
+	defer e.settingsMutex.RUnlock()
+
+	var components []ComponentStatus
+	for vector := range e.threatDetectors {
+		components = append(components, e.componentStatusLocked(detectorName(vector)))
+	}
+	for name := range e.shieldModules {
+		components = append(components, e.componentStatusLocked(name))
+	}
+
+	sort.Slice(components, func(i, j int) bool {
+		if components[i].Kind != components[j].Kind {
+			return components[i].Kind == ComponentDetector
+		}
+		// Custom vectors T10 and up come after T9
+		if components[i].Kind == ComponentDetector && len(components[i].Name) != len(components[j].Name) {
+			return len(components[i].Name) < len(components[j].Name)
+		}
+		return components[i].Name < components[j].Name
+	})
+	return components
+}
+
+// UpdateComponent changes a component's settings for every later audit and
+// records the change in the audit log
+func (e *Engine) UpdateComponent(name string, update ComponentUpdate, actor, role string) (ComponentStatus, error) {
+	kind := e.componentKind(name)
+	if kind == "" {
+		return ComponentStatus{}, fmt.Errorf("unknown component %q", name)
+	}
+	if update.MinConfidence != nil {
+		if kind != ComponentDetector {
+			return ComponentStatus{}, fmt.Errorf("min_confidence only applies to detectors")
+		}
+		if *update.MinConfidence < 0 || *update.MinConfidence > 1 {
+			return ComponentStatus{}, fmt.Errorf("min_confidence must be between 0 and 1")
+		}
+	}
+
+	e.updateMutex.Lock()
+	defer e.updateMutex.Unlock()
+
+	e.settingsMutex.Lock()
+	before := e.componentStatusLocked(name)
+	settings := e.settings[name]
+	if update.Enabled != nil {
+		settings.disabled = !*update.Enabled
+	}
+	if update.MinConfidence != nil {
+		settings.minConfidence = *update.MinConfidence
+	}
+	e.settings[name] = settings
+	after := e.componentStatusLocked(name)
+	e.settingsMutex.Unlock()
+
+	// Results cached under the old settings no longer apply
+	if e.cache != nil {
+		e.cache.setVersion(e.configVersion())
+	}
+	e.Ruleset()
+
+	if e.auditLog != nil {
+		e.auditLog.LogComponentChange(name, &ComponentChange{
+			Actor:     actor,
+			Role:      role,
+			Before:    before,
+			After:     after,
+			Timestamp: time.Now(),
+		})
+	}
+	return after, nil
+}
+
+func (e *Engine) componentKind(name string) string {
+	for vector := range e.threatDetectors {
+		if detectorName(vector) == name {
+			return ComponentDetector
+		}
+	}
+	if _, ok := e.shieldModules[name]; ok {
+		return ComponentShield
+	}
+	return ""
+}
+
+func (e *Engine) componentStatusLocked(name string) ComponentStatus {
+	settings := e.settings[name]
+	status := ComponentStatus{
+		Name:    name,
+		Kind:    e.componentKind(name),
+		Enabled: !settings.disabled,
+	}
+	if module, ok := e.shieldModules[name]; ok {
+		status.Description = reflect.TypeOf(module).Elem().Name()
+	} else {
+		for vector := range e.threatDetectors {
+			if detectorName(vector) == name {
+				status.Description = ThreatName(vector)
+			}
+		}
+		status.MinConfidence = settings.minConfidence
+	}
+	return status
+}
+
+// detectorSettings returns whether a detector is enabled and its confidence threshold
+func (e *Engine) detectorSettings(vector ThreatVector) (bool, float64) {
+	e.settingsMutex.RLock()
+	defer e.settingsMutex.RUnlock()
+	settings := e.settings[detectorName(vector)]
+	return !settings.disabled, settings.minConfidence
+}
+
+func (e *Engine) shieldEnabled(name string) bool {
+	e.settingsMutex.RLock()
+	defer e.settingsMutex.RUnlock()
+	return !e.settings[name].disabled
+}
+
+// settingsFingerprint lists non-default settings for the cache config version
+func (e *Engine) settingsFingerprint() []string {
+	e.settingsMutex.RLock()
+	defer e.settingsMutex.RUnlock()
+
+	var parts []string
+	for name, settings := range e.settings {
+		if settings != (componentSettings{}) {
+			parts = append(parts, fmt.Sprintf("setting:%s:%t:%g", name, settings.disabled, settings.minConfidence))
+		}
+	}
+	return parts
+}
+
+// filterConfidence drops findings below a detector's confidence threshold
+func filterConfidence(threats []ThreatDetection, minConfidence float64) []ThreatDetection {
+	if minConfidence <= 0 {
+		return threats
+	}
+	kept := threats[:0]
+	for _, threat := range threats {
+		if threat.Confidence >= minConfidence {
+			kept = append(kept, threat)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,73 @@
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestUpdateComponent tests disabling detectors and confidence thresholds at runtime
+func TestUpdateComponent(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	engine, err := NewEngine(Config{AuditLogPath: logPath})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	components := engine.Components()
+	if len(components) != 15 || components[0].Name != "T1" || components[0].Description != "Reasoning Path Hijacking" || components[9].Kind != ComponentShield {
+		t.Fatalf("Should list 9 detectors then 6 shields, got %+v", components)
+	}
+
+	agent := []byte("#!/bin/sh\n# identity_spoof impersonate\n")
+	countT6 := func() int {
+		report, err := engine.Audit(context.Background(), bytes.NewReader(agent))
+		if err != nil {
+			t.Fatalf("Failed to audit agent: %v", err)
+		}
+		count := 0
+		for _, threat := range report.Threats {
+			if threat.Vector == T6_IDENTITY_SPOOFING {
+				count++
+			}
+		}
+		return count
+	}
+	if countT6() == 0 {
+		t.Fatal("T6 detector should flag the agent")
+	}
+
+	threshold := 0.99
+	if _, err := engine.UpdateComponent("T6", ComponentUpdate{MinConfidence: &threshold}, "alice", "admin"); err != nil {
+		t.Fatalf("Failed to set threshold: %v", err)
+	}
+	if count := countT6(); count != 0 {
+		t.Fatalf("Findings below the threshold should be dropped, got %d", count)
+	}
+
+	disabled := false
+	status, err := engine.UpdateComponent("integrity", ComponentUpdate{Enabled: &disabled}, "alice", "admin")
+	if err != nil || status.Enabled {
+		t.Fatalf("Should disable the integrity shield, got %+v, %v", status, err)
+	}
+	report, _ := engine.Audit(context.Background(), bytes.NewReader(agent))
+	if _, ok := report.ShieldResults["integrity"]; ok {
+		t.Fatal("Disabled shield should not run")
+	}
+
+	if _, err := engine.UpdateComponent("T10", ComponentUpdate{Enabled: &disabled}, "alice", "admin"); err == nil {
+		t.Fatal("Unknown component should be rejected")
+	}
+	if _, err := engine.UpdateComponent("integrity", ComponentUpdate{MinConfidence: &threshold}, "alice", "admin"); err == nil {
+		t.Fatal("Thresholds should only apply to detectors")
+	}
+
+	data, _ := os.ReadFile(logPath)
+	if strings.Count(string(data), `"event":"component_change"`) != 2 || !strings.Contains(string(data), `"actor":"alice"`) {
+		t.Fatalf("Changes should be recorded in the audit log, got:\n%s", data)
+	}
+}
@@ -0,0 +1,319 @@
+package aegong
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Control-flow metrics for reasoning-hijack detection
+//
+// An agent whose reasoning can be steered has many paths through its code and
+// picks between them based on what it is told. For script agents each
+// function's cyclomatic complexity (decision points plus one) and conditional
+// nesting are measured on the masked source, and branches whose condition
+// carries LLM or network data are found with the taint analysis. For x86-64
+// executables complexity is counted from the conditional branches and jump
+// tables between function starts.
+
+// Thresholds past which a function is reported
+const (
+	complexScriptFunction = 20
+	deepNesting           = 5
+	// Compilers expand every condition into branches, so executables need more
+	complexBinaryFunction = 100
+	maxComplexFunctions   = 5
+)
+
+// FunctionComplexity is the control flow of one function of an agent
+type FunctionComplexity struct {
+	Name        string `json:"name"`
+	Line        int    `json:"line,omitempty"`    // Where a script function is defined
+	Address     string `json:"address,omitempty"` // Where an executable's function starts
+	Cyclomatic  int    `json:"cyclomatic"`
+	Nesting     int    `json:"nesting,omitempty"`      // Deepest nesting of conditionals and loops
+	NestingLine int    `json:"nesting_line,omitempty"` // Line of the deepest conditional
+	JumpTables  int    `json:"jump_tables,omitempty"`  // Indirect jumps, as switch statements compile to
+}
+
+// BranchDispatch is a branch whose condition carries data from outside the agent
+type BranchDispatch struct {
+	Source  string      `json:"source"`
+	Keyword string      `json:"keyword"` // if, while, switch or match
+	Trace   []TaintStep `json:"trace"`
+}
+
+// String renders a dispatch as "llm data decides the if at line 9: ..."
+func (d BranchDispatch) String() string {
+	steps := make([]string, len(d.Trace))
+	for i, step := range d.Trace {
+		steps[i] = fmt.Sprintf("line %d `%s`", step.Line, step.Code)
+	}
+	return fmt.Sprintf("%s data decides the %s at line %d: %s", d.Source, d.Keyword, d.Trace[len(d.Trace)-1].Line, strings.Join(steps, " -> "))
+}
+
+// ControlFlowMetrics summarises the control flow of an agent
+type ControlFlowMetrics struct {
+	Language       string               `json:"language"` // python, javascript or x86-64
+	Functions      int                  `json:"functions"`
+	MeanCyclomatic float64              `json:"mean_cyclomatic"`
+	MaxCyclomatic  int                  `json:"max_cyclomatic"`
+	MaxNesting     int                  `json:"max_nesting,omitempty"`
+	ComplexCount   int                  `json:"complex_count"`               // Functions past the thresholds
+	Complex        []FunctionComplexity `json:"complex_functions,omitempty"` // The most complex of them
+	Dispatch       []BranchDispatch     `json:"input_dispatch,omitempty"`
+}
+
+var (
+	pyDecisions    = regexp.MustCompile(`\b(?:if|elif|for|while|except|and|or)\b|^\s*case\b`)
+	pyControl      = regexp.MustCompile(`^\s*(?:async\s+)?(?:if|elif|else|for|while|try|except|finally|with|match|case)\b`)
+	pyScope        = regexp.MustCompile(`^\s*(?:async\s+)?(?:def|class)\b`)
+	pyBranch       = regexp.MustCompile(`^\s*(if|elif|while|match)\s+(.+):`)
+	jsDecisions    = regexp.MustCompile(`\b(?:if|for|while|case|catch)\b|&&|\|\||\?\?|\?[^.?]`)
+	jsControl      = regexp.MustCompile(`\b(?:if|else|for|while|do|switch|try|catch|finally)\b`)
+	jsFunctionHead = regexp.MustCompile(`\bfunction\b|=>`)
+	jsBranch       = regexp.MustCompile(`\b(if|while|switch)\s*\(`)
+)
+
+// Kinds of block a brace or indented body opens
+const (
+	blockPlain = iota
+	blockControl
+	blockFunction
+)
+
+// controlDepth counts the control blocks open since the innermost function
+func controlDepth(blocks []int) int {
+	depth := 0
+	for i := len(blocks) - 1; i >= 0 && blocks[i] != blockFunction; i-- {
+		if blocks[i] == blockControl {
+			depth++
+		}
+	}
+	return depth
+}
+
+// scriptControlFlow measures the functions of a Python or JavaScript agent
+func scriptControlFlow(data []byte) *ControlFlowMetrics {
+	lang := detectScriptLanguage(data)
+	if lang == nil {
+		return nil
+	}
+	a := runTaintAnalysis(string(data), lang)
+
+	// Index 0 is module level, function i is at i+1
+	functions := make([]FunctionComplexity, len(a.functions)+1)
+	functions[0] = FunctionComplexity{Name: "<module>", Line: 1, Cyclomatic: 1}
+	for i, fn := range a.functions {
+		functions[i+1] = FunctionComplexity{Name: fn.name, Line: a.lines[fn.def].number, Cyclomatic: 1}
+	}
+	nested := func(i, depth int) {
+		if fn := &functions[a.scope[i]+1]; depth > fn.Nesting {
+			fn.Nesting, fn.NestingLine = depth, a.lines[i].number
+		}
+	}
+
+	metrics := &ControlFlowMetrics{Language: lang.name}
+	var indents []int // Python blocks by indentation, alongside blocks
+	var blocks []int
+	for i, line := range a.lines {
+		fn := &functions[a.scope[i]+1]
+		step := TaintStep{Line: line.number, Code: line.code}
+
+		if lang == pythonTaint {
+			fn.Cyclomatic += len(pyDecisions.FindAllStringIndex(line.masked, -1))
+			indent := leadingSpace(line.raw)
+			for len(indents) > 0 && indents[len(indents)-1] >= indent {
+				indents, blocks = indents[:len(indents)-1], blocks[:len(blocks)-1]
+			}
+			switch {
+			case pyScope.MatchString(line.masked):
+				indents, blocks = append(indents, indent), append(blocks, blockFunction)
+			case pyControl.MatchString(line.masked):
+				indents, blocks = append(indents, indent), append(blocks, blockControl)
+				nested(i, controlDepth(blocks))
+			}
+			if match := pyBranch.FindStringSubmatch(line.masked); match != nil {
+				keyword := match[1]
+				if keyword == "elif" {
+					keyword = "if"
+				}
+				metrics.dispatch(a, i, keyword, match[2], step)
+			}
+			continue
+		}
+
+		fn.Cyclomatic += len(jsDecisions.FindAllStringIndex(line.masked, -1))
+		segment := 0
+		for j := 0; j < len(line.masked); j++ {
+			switch line.masked[j] {
+			case '{':
+				head := line.masked[segment:j]
+				switch {
+				case jsControl.MatchString(head):
+					blocks = append(blocks, blockControl)
+					nested(i, controlDepth(blocks))
+				case jsFunctionHead.MatchString(head), jsFunctionDef.MatchString(line.masked[segment : j+1]):
+					blocks = append(blocks, blockFunction)
+				default:
+					blocks = append(blocks, blockPlain)
+				}
+				segment = j + 1
+			case '}':
+				if len(blocks) > 0 {
+					blocks = blocks[:len(blocks)-1]
+				}
+				segment = j + 1
+			}
+		}
+		for _, loc := range jsBranch.FindAllStringSubmatchIndex(line.masked, -1) {
+			keyword := line.masked[loc[2]:loc[3]]
+			metrics.dispatch(a, i, keyword, callArguments(line.masked[loc[1]:]), step)
+		}
+	}
+
+	metrics.summarise(functions, complexScriptFunction)
+	return metrics
+}
+
+// dispatch records a branch whose condition carries LLM, network or user data
+func (m *ControlFlowMetrics) dispatch(a *taintAnalysis, i int, keyword, condition string, step TaintStep) {
+	value := a.exprTaint(condition, a.scope[i], step)
+	if value == nil || value.source == taintSourceParam {
+		return
+	}
+	m.Dispatch = append(m.Dispatch, BranchDispatch{Source: value.source, Keyword: keyword, Trace: value.trace})
+}
+
+// summarise fills in the totals and the functions past the thresholds
+func (m *ControlFlowMetrics) summarise(functions []FunctionComplexity, threshold int) {
+	total := 0
+	for _, fn := range functions {
+		total += fn.Cyclomatic
+		m.MaxCyclomatic = max(m.MaxCyclomatic, fn.Cyclomatic)
+		m.MaxNesting = max(m.MaxNesting, fn.Nesting)
+		if fn.Cyclomatic > threshold || fn.Nesting > deepNesting {
+			m.Complex = append(m.Complex, fn)
+		}
+	}
+	m.Functions, m.ComplexCount = len(functions), len(m.Complex)
+	if len(functions) > 0 {
+		m.MeanCyclomatic = float64(total) / float64(len(functions))
+	}
+	sort.SliceStable(m.Complex, func(i, j int) bool { return m.Complex[i].Cyclomatic > m.Complex[j].Cyclomatic })
+	if len(m.Complex) > maxComplexFunctions {
+		m.Complex = m.Complex[:maxComplexFunctions]
+	}
+}
+
+// binaryControlFlow measures the functions of an x86-64 executable. Functions
+// start at their symbols and at the targets of direct calls; each conditional
+// branch and jump table adds a path.
+func binaryControlFlow(binaryData []byte) *ControlFlowMetrics {
+	code := executableRegions(binaryData)
+	if code == nil || code.machine != "" || len(code.regions) == 0 {
+		return nil
+	}
+	names := make(map[uint64]string)
+	for address, name := range code.functions {
+		if code.contains(address) {
+			names[address] = name
+		}
+	}
+	var branches, tables []uint64
+	code.sweep(func(region *codeRegion, offset int, inst x86Inst, ok bool) {
+		if !ok {
+			return
+		}
+		address := region.address + uint64(offset)
+		switch {
+		case inst.conditional:
+			branches = append(branches, address)
+		case inst.indirectJump && !inst.ripRelative: // Jumps through the GOT are calls
+			tables = append(tables, address)
+		case inst.call && inst.relative:
+			// Static functions of stripped executables are only known as call targets
+			if target := address + uint64(inst.length) + uint64(inst.rel); code.contains(target) && names[target] == "" {
+				names[target] = ""
+			}
+		}
+	})
+	if len(names) == 0 {
+		return nil
+	}
+
+	starts := make([]uint64, 0, len(names))
+	for address := range names {
+		starts = append(starts, address)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	functions := make([]FunctionComplexity, len(starts))
+	for i, address := range starts {
+		name := names[address]
+		if name == "" {
+			name = fmt.Sprintf("sub_%x", address)
+		}
+		functions[i] = FunctionComplexity{Name: name, Address: fmt.Sprintf("0x%x", address), Cyclomatic: 1}
+	}
+	containing := func(address uint64) *FunctionComplexity {
+		i := sort.Search(len(starts), func(i int) bool { return starts[i] > address }) - 1
+		if i < 0 {
+			return nil
+		}
+		return &functions[i]
+	}
+	for _, address := range branches {
+		if fn := containing(address); fn != nil {
+			fn.Cyclomatic++
+		}
+	}
+	for _, address := range tables {
+		if fn := containing(address); fn != nil {
+			fn.Cyclomatic++
+			fn.JumpTables++
+		}
+	}
+
+	metrics := &ControlFlowMetrics{Language: "x86-64"}
+	metrics.summarise(functions, complexBinaryFunction)
+	return metrics
+}
+
+// Share of an executable's functions past complexBinaryFunction that is
+// unusual; ordinary programs, interpreters included, stay well below it
+const complexBinaryShare = 0.1
+
+// evidence describes what in the metrics points to steerable reasoning. Only
+// branches on LLM and network data count; acting on the user's own input is
+// what agents are for.
+func (m *ControlFlowMetrics) evidence() []string {
+	if m == nil {
+		return nil
+	}
+	var evidence []string
+	if m.Language == "x86-64" {
+		if m.ComplexCount >= maxComplexFunctions && float64(m.ComplexCount) >= complexBinaryShare*float64(m.Functions) {
+			evidence = append(evidence, fmt.Sprintf("%d of %d functions have a cyclomatic complexity over %d, up to %d in `%s`",
+				m.ComplexCount, m.Functions, complexBinaryFunction, m.Complex[0].Cyclomatic, m.Complex[0].Name))
+		}
+		return evidence
+	}
+
+	for _, fn := range m.Complex {
+		if fn.Cyclomatic > complexScriptFunction {
+			evidence = append(evidence, fmt.Sprintf("Function `%s` at line %d has a cyclomatic complexity of %d", fn.Name, fn.Line, fn.Cyclomatic))
+		}
+		if fn.Nesting > deepNesting {
+			evidence = append(evidence, fmt.Sprintf("Conditionals nested %d deep in `%s` at line %d", fn.Nesting, fn.Name, fn.NestingLine))
+		}
+	}
+	dispatched := 0
+	for _, dispatch := range m.Dispatch {
+		if (dispatch.Source == taintSourceLLM || dispatch.Source == taintSourceNetwork) && dispatched < maxComplexFunctions {
+			evidence = append(evidence, dispatch.String())
+			dispatched++
+		}
+	}
+	return evidence
+}
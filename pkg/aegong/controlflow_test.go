@@ -0,0 +1,120 @@
+package aegong
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestScriptControlFlow tests complexity, nesting and input dispatch in a Python agent
+func TestScriptControlFlow(t *testing.T) {
+	agent := `import openai
+
+client = openai.OpenAI()
+
+def route(msg):
+    reply = client.chat.completions.create(model="x", messages=[msg])
+    action = reply.choices[0].message.content
+    if action == "delete":
+        for attempt in range(3):
+            if attempt and msg:
+                while attempt:
+                    if attempt > 1:
+                        try:
+                            if msg:
+                                pass
+                        except Exception:
+                            pass
+    return action
+
+command = input()
+if command == "quit":
+    pass
+`
+	metrics := scriptControlFlow([]byte(agent))
+	if metrics == nil || metrics.Language != "python" || metrics.Functions != 2 {
+		t.Fatalf("Python agent should be measured, got %+v", metrics)
+	}
+	if len(metrics.Complex) != 1 || metrics.Complex[0].Name != "route" || metrics.Complex[0].Nesting != 7 || metrics.Complex[0].NestingLine != 14 {
+		t.Fatalf("route should be nested 7 deep at line 14, got %+v", metrics.Complex)
+	}
+	// if, for, if, and, while, if, except, if
+	if metrics.Complex[0].Cyclomatic != 9 {
+		t.Fatalf("route should have a cyclomatic complexity of 9, got %d", metrics.Complex[0].Cyclomatic)
+	}
+	if len(metrics.Dispatch) != 2 || metrics.Dispatch[0].Source != taintSourceLLM || metrics.Dispatch[1].Source != taintSourceUser {
+		t.Fatalf("Branches on the LLM reply and the user's input should be found, got %+v", metrics.Dispatch)
+	}
+
+	evidence := metrics.evidence()
+	if len(evidence) != 2 || !strings.Contains(evidence[0], "nested 7 deep in `route`") || !strings.Contains(evidence[1], "llm data decides the if at line 8") {
+		t.Fatalf("Nesting and the LLM dispatch should be evidence, the user's input should not, got %v", evidence)
+	}
+}
+
+// TestJavaScriptControlFlow tests brace nesting and switch dispatch in a JavaScript agent
+func TestJavaScriptControlFlow(t *testing.T) {
+	agent := `async function handle(req) {
+  const res = await fetch("http://example.com/task");
+  const task = await res.json();
+  switch (task.op) {
+    case "a": if (x && y) { for (const z of q) { while (z) { if (k) { if (j) { run(); } } } } } break;
+    case "b": break;
+  }
+  const done = () => { if (a) { return 1; } };
+  return task.op === "x" ? 1 : 2;
+}
+`
+	metrics := scriptControlFlow([]byte(agent))
+	if metrics == nil || metrics.Language != "javascript" || metrics.MaxNesting != 6 {
+		t.Fatalf("JavaScript agent should be nested 6 deep, got %+v", metrics)
+	}
+	if len(metrics.Dispatch) != 1 || metrics.Dispatch[0].Keyword != "switch" || metrics.Dispatch[0].Source != taintSourceNetwork {
+		t.Fatalf("Switch on fetched data should be found, got %+v", metrics.Dispatch)
+	}
+}
+
+// TestReasoningHijackControlFlow tests that the T1 detector reports control-flow evidence
+func TestReasoningHijackControlFlow(t *testing.T) {
+	agent := []byte(`import anthropic
+
+client = anthropic.Anthropic()
+
+def decide(prompt):
+    verdict = client.messages.create(model="x", messages=[prompt]).content[0].text
+    if verdict.startswith("approve"):
+        return True
+    return False
+`)
+	threats := (&ReasoningHijackDetector{}).DetectThreat(context.Background(), agent, nil)
+	if len(threats) != 1 || len(threats[0].Evidence) != 1 || !strings.Contains(threats[0].Evidence[0], "llm data decides the if at line 7") {
+		t.Fatalf("Branching on the LLM's verdict should be reported, got %+v", threats)
+	}
+	if metrics, ok := threats[0].Details["control_flow"].(*ControlFlowMetrics); !ok || metrics.Functions != 2 {
+		t.Fatalf("Details should carry the control-flow metrics, got %+v", threats[0].Details)
+	}
+
+	if threats := (&ReasoningHijackDetector{}).DetectThreat(context.Background(), []byte("def add(a, b):\n    return a + b\n"), nil); len(threats) != 0 {
+		t.Fatalf("Simple functions should raise nothing, got %+v", threats)
+	}
+}
+
+// TestBinaryControlFlow tests that an ordinary executable is measured without evidence
+func TestBinaryControlFlow(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("system binaries are not x86-64")
+	}
+	binary, err := os.ReadFile("/bin/true")
+	if err != nil {
+		t.Skipf("Failed to read /bin/true: %v", err)
+	}
+	metrics := binaryControlFlow(binary)
+	if metrics == nil || metrics.Language != "x86-64" || metrics.Functions == 0 || metrics.MaxCyclomatic < 2 {
+		t.Fatalf("/bin/true should be measured, got %+v", metrics)
+	}
+	if evidence := metrics.evidence(); len(evidence) != 0 {
+		t.Fatalf("/bin/true should not be evidence, got %v", evidence)
+	}
+}
@@ -0,0 +1,30 @@
+package aegong
+
+import (
+	"context"
+	"log"
+)
+
+type correlationKey struct{}
+
+// WithCorrelationID returns a context whose audits carry id in the engine's
+// log lines, the audit log and the report, so one audit can be traced from
+// the request that started it
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the correlation ID of ctx, or "" if it has none
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// logf logs a line, tagged with the correlation ID of the audit ctx belongs to
+func logf(ctx context.Context, format string, args ...interface{}) {
+	if id := CorrelationID(ctx); id != "" {
+		format += " correlation_id=%s"
+		args = append(args, id)
+	}
+	log.Printf(format, args...)
+}
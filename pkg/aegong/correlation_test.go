@@ -0,0 +1,40 @@
+package aegong
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestAuditCorrelationID tests that the correlation ID reaches the report and audit log
+func TestAuditCorrelationID(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	engine, err := NewEngine(Config{AuditLogPath: logPath})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	ctx := WithCorrelationID(context.Background(), "req-42")
+	report, err := engine.Audit(ctx, strings.NewReader("#!/bin/sh\necho 'Hello, World!'\n"))
+	if err != nil {
+		t.Fatalf("Failed to audit agent: %v", err)
+	}
+	if report.CorrelationID != "req-42" {
+		t.Fatalf("Report should carry the correlation ID, got %q", report.CorrelationID)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	if !strings.Contains(string(data), `"correlation_id":"req-42"`) {
+		t.Fatalf("Audit log entry should carry the correlation ID, got %s", data)
+	}
+
+	if CorrelationID(context.Background()) != "" {
+		t.Fatal("Context without a correlation ID should return none")
+	}
+}
@@ -0,0 +1,202 @@
+package aegong
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Coverage statuses of a component in a report
+const (
+	CoverageRan      = "ran"
+	CoverageCached   = "cached"   // Results reused from the result cache
+	CoverageResumed  = "resumed"  // Results reused from an interrupted audit's checkpoint
+	CoverageSkipped  = "skipped"  // Could not run, e.g. the sandbox was unavailable
+	CoverageDisabled = "disabled" // Turned off through UpdateComponent
+	CoverageExcluded = "excluded" // Left out of the audit's scope
+	// The analysed content offers none of the detector's inputs
+	CoverageNotApplicable = "not_applicable"
+)
+
+// Analysis phases a component can run in
+const (
+	PhaseStatic  = "static"
+	PhaseDynamic = "dynamic"
+	PhaseShield  = "shield"
+)
+
+// ComponentAnalysis is the kind of components that are neither detectors nor shields
+const ComponentAnalysis = "analysis"
+
+// Coverage lists which detectors and shields produced a report, so a clean
+// report can be told apart from one where analyses did not run
+type Coverage struct {
+	Complete   bool                `json:"complete"` // Every component in scope ran or was cached
+	Components []ComponentCoverage `json:"components"`
+	Scope      *AuditScope         `json:"scope,omitempty"` // Set when the audit was limited to some components
+	// How the agent was run in the dynamic phase; absent when the phase was cached or resumed
+	DynamicStrategy *DynamicStrategy `json:"dynamic_strategy,omitempty"`
+}
+
+// ComponentCoverage records how one component took part in an audit
+type ComponentCoverage struct {
+	Name       string  `json:"name"`
+	Kind       string  `json:"kind"`
+	Phase      string  `json:"phase"`
+	Version    string  `json:"version"`
+	Status     string  `json:"status"`
+	Reason     string  `json:"reason,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+	Findings   int     `json:"findings"` // Threats found; shields report pass or fail in shield_results
+}
+
+// versioned components report their own version; others share detectorRevision
+type versioned interface {
+	Version() string
+}
+
+func componentVersion(component interface{}) string {
+	if v, ok := component.(versioned); ok {
+		return v.Version()
+	}
+	return fmt.Sprintf("r%d", detectorRevision)
+}
+
+// coverageRecorder collects component coverage during an audit
+type coverageRecorder struct {
+	mutex      sync.Mutex
+	components []ComponentCoverage
+	errors     []DetectorError
+	strategy   *DynamicStrategy
+}
+
+func (r *coverageRecorder) add(coverage ComponentCoverage) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.components = append(r.components, coverage)
+}
+
+// ran records a component that ran for duration and produced findings
+func (r *coverageRecorder) ran(name, kind, phase string, component interface{}, duration time.Duration, findings int) {
+	r.add(ComponentCoverage{
+		Name:       name,
+		Kind:       kind,
+		Phase:      phase,
+		Version:    componentVersion(component),
+		Status:     CoverageRan,
+		DurationMS: float64(duration.Microseconds()) / 1000,
+		Findings:   findings,
+	})
+}
+
+// notRun records a component that was cached, skipped or disabled
+func (r *coverageRecorder) notRun(name, kind, phase string, component interface{}, status, reason string) {
+	r.add(ComponentCoverage{
+		Name:    name,
+		Kind:    kind,
+		Phase:   phase,
+		Version: componentVersion(component),
+		Status:  status,
+		Reason:  reason,
+	})
+}
+
+// chose records how the agent was run in the dynamic phase
+func (r *coverageRecorder) chose(strategy DynamicStrategy) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.strategy = &strategy
+}
+
+// failed records a detector that panicked
+func (r *coverageRecorder) failed(failure DetectorError) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.errors = append(r.errors, failure)
+}
+
+// detectorErrors returns the recorded panics in the order they happened
+func (r *coverageRecorder) detectorErrors() []DetectorError {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]DetectorError(nil), r.errors...)
+}
+
+// report returns the recorded coverage in a stable order
+func (r *coverageRecorder) report(selection *auditSelection) *Coverage {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	phases := map[string]int{PhaseStatic: 0, PhaseDynamic: 1, PhaseShield: 2}
+	components := append([]ComponentCoverage(nil), r.components...)
+	sort.SliceStable(components, func(i, j int) bool {
+		if components[i].Phase != components[j].Phase {
+			return phases[components[i].Phase] < phases[components[j].Phase]
+		}
+		return components[i].Name < components[j].Name
+	})
+
+	complete := true
+	for _, component := range components {
+		if component.Status != CoverageRan && component.Status != CoverageCached && component.Status != CoverageResumed &&
+			component.Status != CoverageExcluded && component.Status != CoverageNotApplicable {
+			complete = false
+		}
+	}
+	coverage := &Coverage{Complete: complete, Components: components, DynamicStrategy: r.strategy}
+	if selection != nil {
+		coverage.Scope = &selection.scope
+	}
+	return coverage
+}
+
+// recordReused records every enabled detector of a phase, or every shield,
+// as served from the cache or a checkpoint
+func (e *Engine) recordReused(r *coverageRecorder, phase string, selection *auditSelection, status string) {
+	if phase == PhaseShield {
+		for name, module := range e.shieldModules {
+			if !selection.shield(name) {
+				r.notRun(name, ComponentShield, phase, module, CoverageExcluded, "")
+			} else if e.shieldEnabled(name) {
+				r.notRun(name, ComponentShield, phase, module, status, "")
+			} else {
+				r.notRun(name, ComponentShield, phase, module, CoverageDisabled, "")
+			}
+		}
+		return
+	}
+
+	for vector, detector := range e.threatDetectors {
+		if !selection.vector(vector) {
+			r.notRun(detectorName(vector), ComponentDetector, phase, detector, CoverageExcluded, "")
+		} else if enabled, _ := e.detectorSettings(vector); enabled {
+			r.notRun(detectorName(vector), ComponentDetector, phase, detector, status, "")
+		} else {
+			r.notRun(detectorName(vector), ComponentDetector, phase, detector, CoverageDisabled, "")
+		}
+	}
+	if phase == PhaseStatic {
+		r.notRun("deobfuscation", ComponentAnalysis, phase, nil, status, "")
+		r.notRun("taint", ComponentAnalysis, phase, nil, status, "")
+	} else {
+		r.notRun("evasion", ComponentAnalysis, phase, nil, status, "")
+	}
+}
+
+// coverageOf returns the recorder of the audit a container belongs to
+func coverageOf(container *CustomContainer) *coverageRecorder {
+	if container == nil {
+		return nil
+	}
+	return container.coverage
+}
@@ -0,0 +1,125 @@
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCoverage tests that reports record which components ran, were disabled or were skipped
+func TestCoverage(t *testing.T) {
+	requireSandbox(t)
+	engine := newTestEngine(t)
+
+	statuses := func(report *AuditReport) map[string]ComponentCoverage {
+		byName := make(map[string]ComponentCoverage)
+		for _, component := range report.Coverage.Components {
+			byName[component.Phase+"/"+component.Name] = component
+		}
+		return byName
+	}
+
+	report, err := engine.Audit(context.Background(), bytes.NewReader([]byte("#!/bin/sh\necho hello\n")))
+	if err != nil {
+		t.Fatalf("Failed to audit agent: %v", err)
+	}
+	if report.Coverage == nil || !report.Coverage.Complete {
+		t.Fatalf("Report of a fully analyzed agent should be complete, got %+v", report.Coverage)
+	}
+	components := statuses(report)
+	for _, name := range []string{"static/T1", "static/T9", "static/taint", "static/signature", "dynamic/T4", "shield/integrity"} {
+		component, ok := components[name]
+		if !ok || component.Status != CoverageRan || component.Version == "" {
+			t.Fatalf("%s should have run, got %+v", name, component)
+		}
+	}
+
+	disabled := false
+	if _, err := engine.UpdateComponent("T3", ComponentUpdate{Enabled: &disabled}, "alice", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Data the kernel cannot execute leaves nothing for dynamic analysis
+	report, err = engine.Audit(context.Background(), bytes.NewReader([]byte{0x7f, 'E', 'L', 'F', 0, 0, 0}))
+	if err != nil {
+		t.Fatalf("Failed to audit agent: %v", err)
+	}
+	if report.Coverage.Complete {
+		t.Fatal("Report with skipped analyses should not be complete")
+	}
+	components = statuses(report)
+	if components["static/T3"].Status != CoverageDisabled || components["dynamic/T3"].Status != CoverageDisabled {
+		t.Fatalf("Disabled detector should be reported as disabled, got %+v", components["static/T3"])
+	}
+	skipped := components["dynamic/T1"]
+	if skipped.Status != CoverageSkipped || !strings.Contains(skipped.Reason, "dynamic analysis unavailable") {
+		t.Fatalf("Dynamic detectors should be skipped when the agent cannot run, got %+v", skipped)
+	}
+	if components["static/T1"].Status != CoverageRan {
+		t.Fatal("Static detectors should still run")
+	}
+}
+
+// stuckShield ignores its context until released
+type stuckShield struct{ release chan struct{} }
+
+func (s *stuckShield) Validate(ctx context.Context, binary []byte, container *CustomContainer) (bool, map[string]interface{}) {
+	<-s.release
+	return true, nil
+}
+
+func (s *stuckShield) GetModuleName() string { return "stuck" }
+
+// panickingShield fails with a panic
+type panickingShield struct{}
+
+func (p *panickingShield) Validate(ctx context.Context, binary []byte, container *CustomContainer) (bool, map[string]interface{}) {
+	panic("boom")
+}
+
+func (p *panickingShield) GetModuleName() string { return "panicking" }
+
+// TestShieldFailures tests that shields that hang or panic are skipped without stalling the audit
+func TestShieldFailures(t *testing.T) {
+	oldTimeout := shieldTimeout
+	t.Cleanup(func() { shieldTimeout = oldTimeout })
+	shieldTimeout = 100 * time.Millisecond
+
+	engine := newTestEngine(t)
+	stuck := &stuckShield{release: make(chan struct{})}
+	t.Cleanup(func() { close(stuck.release) })
+	engine.shieldModules["stuck"] = stuck
+	engine.shieldModules["panicking"] = &panickingShield{}
+
+	report, err := engine.Audit(context.Background(), bytes.NewReader([]byte("#!/bin/sh\necho hello\n")))
+	if err != nil {
+		t.Fatalf("Failed to audit agent: %v", err)
+	}
+	for _, component := range report.Coverage.Components {
+		if component.Phase != PhaseShield {
+			continue
+		}
+		switch component.Name {
+		case "stuck":
+			if component.Status != CoverageSkipped || !strings.Contains(component.Reason, "timed out") {
+				t.Errorf("A hanging shield should be skipped after its timeout, got %+v", component)
+			}
+		case "panicking":
+			if component.Status != CoverageSkipped || !strings.Contains(component.Reason, "panicked: boom") {
+				t.Errorf("A panicking shield should be skipped, got %+v", component)
+			}
+		default:
+			if component.Status != CoverageRan {
+				t.Errorf("Other shields should still run, got %+v", component)
+			}
+		}
+	}
+	if _, ok := report.ShieldResults["stuck"]; ok || report.ShieldResults["integrity"] == nil {
+		t.Errorf("Only shields that finished should have results, got %v", report.ShieldResults)
+	}
+	if report.Coverage.Complete {
+		t.Error("Reports with skipped shields should not be complete")
+	}
+}
@@ -0,0 +1,195 @@
+package aegong
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Credential harvesting is an agent reaching for secrets it was never given:
+// cloud and SSH keys in the user's home, passwords and cookies saved by
+// browsers, the OS keychain, and the instance metadata service that hands
+// out cloud role credentials to anything that asks. References in the agent
+// are found statically; in the sandbox the tracer records opens of those
+// files and connections to the metadata service, and the harness and
+// honeypot what the agent asked for over the network. Either is a CRITICAL
+// T6 finding, since stolen credentials let the agent act as someone else.
+
+// Kinds of credential store
+const (
+	CredentialCloud    = "cloud_credentials"
+	CredentialSSH      = "ssh_keys"
+	CredentialBrowser  = "browser_store"
+	CredentialKeychain = "keychain"
+	CredentialMetadata = "cloud_metadata"
+)
+
+// Most credential accesses recorded per run
+const maxCredentialAccesses = 20
+
+// CredentialAccess is a credential store the agent reached for while it ran
+type CredentialAccess struct {
+	Kind   string `json:"kind"`
+	Target string `json:"target"`          // File path or network address
+	Source string `json:"source"`          // The syscall, harness event or honeypot service that saw it
+	Error  string `json:"error,omitempty"` // Why the kernel refused the call
+}
+
+// credentialIndicator is a reference to a credential store in lowercased
+// text or a path
+type credentialIndicator struct {
+	pattern *regexp.Regexp
+	kind    string
+}
+
+var credentialIndicators = []credentialIndicator{
+	{regexp.MustCompile(`\.aws/(credentials|config)\b`), CredentialCloud},
+	{regexp.MustCompile(`\.config/gcloud/(credentials\.db|access_tokens\.db|application_default_credentials\.json)`), CredentialCloud},
+	{regexp.MustCompile(`\.azure/(accesstokens\.json|msal_token_cache)`), CredentialCloud},
+	{regexp.MustCompile(`\.kube/config\b`), CredentialCloud},
+	{regexp.MustCompile(`\.ssh/(id_[a-z0-9_]+|identity|authorized_keys|known_hosts|config)\b`), CredentialSSH},
+	{regexp.MustCompile(`\.ssh/?$`), CredentialSSH},
+	{regexp.MustCompile(`/(default|profile \d+)/(login data|web data|cookies)\b`), CredentialBrowser},
+	{regexp.MustCompile(`(chrome|chromium|edge|brave-browser)[^\n"']{0,60}/local state\b`), CredentialBrowser},
+	{regexp.MustCompile(`\.mozilla/firefox/[^\n"']*(logins\.json|key[34]\.db|cookies\.sqlite)`), CredentialBrowser},
+	{regexp.MustCompile(`library/keychains/|\.local/share/keyrings/`), CredentialKeychain},
+	{regexp.MustCompile(`seckeychainfind(generic|internet)password|secitemcopymatching|seckeychaincopy`), CredentialKeychain},
+	{regexp.MustCompile(`\bsecurity['",\s]+(find-(generic|internet)-password|dump-keychain)`), CredentialKeychain},
+	{regexp.MustCompile(`credenumerate[aw]?\b|credread[aw]?\b|cryptunprotectdata`), CredentialKeychain},
+	{regexp.MustCompile(`keyring\.get_password|secretstorage\.|secret_password_lookup|secret_service_search`), CredentialKeychain},
+	{regexp.MustCompile(`169\.254\.169\.254|169\.254\.170\.2\b|fd00:ec2::254|metadata\.google\.internal|100\.100\.100\.200`), CredentialMetadata},
+}
+
+// Addresses of the instance metadata services of AWS, ECS, GCP, Azure and Alibaba Cloud
+var metadataAddresses = map[string]bool{
+	"169.254.169.254": true,
+	"169.254.170.2":   true,
+	"fd00:ec2::254":   true,
+	"100.100.100.200": true,
+}
+
+// credentialKind returns the kind of credential store lowercased text or a
+// path refers to, or "" if it refers to none
+func credentialKind(text string) string {
+	for _, indicator := range credentialIndicators {
+		if indicator.pattern.MatchString(text) {
+			return indicator.kind
+		}
+	}
+	return ""
+}
+
+// isMetadataAddress reports whether host, with or without a port, is an
+// instance metadata service
+func isMetadataAddress(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		return metadataAddresses[ip.String()]
+	}
+	return strings.EqualFold(strings.TrimSuffix(host, "."), "metadata.google.internal")
+}
+
+// credentialHarvesting reports references to credential stores in the agent,
+// or in the dynamic phase the credential stores it reached for
+func credentialHarvesting(analysis *AnalysisContext) []ThreatDetection {
+	if analysis.Phase == PhaseDynamic {
+		if analysis.Container == nil {
+			return nil
+		}
+		return analysis.Container.credentialAccessThreats()
+	}
+
+	text := analysis.Text()
+	var evidence, kinds []string
+	seen, seenKinds := make(map[string]bool), make(map[string]bool)
+	first := -1
+	for _, indicator := range credentialIndicators {
+		for _, loc := range indicator.pattern.FindAllStringIndex(text, 5) {
+			match := text[loc[0]:loc[1]]
+			if seen[match] {
+				continue
+			}
+			seen[match] = true
+			first = earliest(first, loc[0])
+			evidence = append(evidence, fmt.Sprintf("Credential harvesting (%s): %s", strings.ReplaceAll(indicator.kind, "_", " "), match))
+			if !seenKinds[indicator.kind] {
+				seenKinds[indicator.kind] = true
+				kinds = append(kinds, indicator.kind)
+			}
+		}
+	}
+	if len(evidence) == 0 {
+		return nil
+	}
+
+	return []ThreatDetection{{
+		Vector:     T6_IDENTITY_SPOOFING,
+		Severity:   CRITICAL,
+		Confidence: min(0.6+0.1*float64(len(kinds)), 0.9),
+		Evidence:   evidence,
+		Timestamp:  time.Now(),
+		Details: map[string]interface{}{
+			"analysis": "credential_harvesting",
+			"kinds":    kinds,
+		},
+		Location: analysis.Locate(first),
+	}}
+}
+
+// credentialAccessThreats reports the credential stores the agent reached for
+// while it ran: files the tracer saw opened, metadata service connections,
+// and requests seen by the harness and honeypot
+func (c *CustomContainer) credentialAccessThreats() []ThreatDetection {
+	accesses := append([]CredentialAccess(nil), c.CredentialAccesses...)
+	for _, event := range c.HarnessEvents {
+		switch event.Event {
+		case "connect", "request", "resolve", "subprocess":
+			if kind := credentialKind(strings.ToLower(event.Detail)); kind != "" {
+				accesses = append(accesses, CredentialAccess{Kind: kind, Target: event.Detail, Source: "harness:" + event.Event})
+			}
+		}
+	}
+	for _, capture := range c.NetworkCaptures {
+		if isMetadataAddress(capture.LocalAddr) {
+			accesses = append(accesses, CredentialAccess{Kind: CredentialMetadata, Target: capture.LocalAddr, Source: "honeypot:" + capture.Service})
+		} else if credentialKind(strings.ToLower(capture.Summary)) == CredentialMetadata {
+			accesses = append(accesses, CredentialAccess{Kind: CredentialMetadata, Target: capture.Summary, Source: "honeypot:" + capture.Service})
+		}
+	}
+	if len(accesses) == 0 {
+		return nil
+	}
+
+	var evidence, kinds []string
+	seen := make(map[string]bool)
+	for _, access := range accesses {
+		line := fmt.Sprintf("Credential access (%s): %s by %s", strings.ReplaceAll(access.Kind, "_", " "), access.Target, access.Source)
+		if access.Error != "" {
+			line += " failed: " + access.Error
+		}
+		evidence = append(evidence, line)
+		if !seen[access.Kind] {
+			seen[access.Kind] = true
+			kinds = append(kinds, access.Kind)
+		}
+	}
+	sort.Strings(kinds)
+
+	return []ThreatDetection{{
+		Vector:     T6_IDENTITY_SPOOFING,
+		Severity:   CRITICAL,
+		Confidence: 0.95,
+		Evidence:   evidence,
+		Timestamp:  time.Now(),
+		Details: map[string]interface{}{
+			"analysis": "credential_harvesting",
+			"kinds":    kinds,
+			"accesses": accesses,
+		},
+	}}
+}
@@ -0,0 +1,87 @@
+//go:build linux
+
+package aegong
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// sockaddrAddress decodes the IPv4 or IPv6 address and port of a sockaddr
+func sockaddrAddress(sockaddr []byte) (string, bool) {
+	if len(sockaddr) < 2 {
+		return "", false
+	}
+	switch binary.LittleEndian.Uint16(sockaddr) {
+	case syscall.AF_INET:
+		if len(sockaddr) >= 8 {
+			return net.JoinHostPort(net.IP(sockaddr[4:8]).String(), fmt.Sprint(binary.BigEndian.Uint16(sockaddr[2:4]))), true
+		}
+	case syscall.AF_INET6:
+		if len(sockaddr) >= 24 {
+			return net.JoinHostPort(net.IP(sockaddr[8:24]).String(), fmt.Sprint(binary.BigEndian.Uint16(sockaddr[2:4]))), true
+		}
+	}
+	return "", false
+}
+
+// credentialTracer watches a traced agent's syscalls for reads of credential
+// stores and connections to the metadata service. It is only called from
+// the tracer thread.
+type credentialTracer struct {
+	accesses []CredentialAccess
+	seen     map[string]bool
+	pending  int // Access made by the syscall in progress, or -1
+}
+
+func newCredentialTracer() *credentialTracer {
+	return &credentialTracer{seen: make(map[string]bool), pending: -1}
+}
+
+func (t *credentialTracer) access(kind, target, syscallName string) {
+	if !t.seen[target] && len(t.accesses) < maxCredentialAccesses {
+		t.seen[target] = true
+		t.pending = len(t.accesses)
+		t.accesses = append(t.accesses, CredentialAccess{Kind: kind, Target: target, Source: syscallName})
+	}
+}
+
+// syscall inspects one syscall entry of the agent
+func (t *credentialTracer) syscall(pid int, call *tracedSyscall) {
+	t.pending = -1
+	args := call.Args
+
+	switch call.Name {
+	case "open", "openat", "openat2":
+		pathAddr := args[0]
+		if call.Name != "open" {
+			pathAddr = args[1]
+		}
+		path := readTraceeString(pid, uintptr(pathAddr))
+		if kind := credentialKind(strings.ToLower(path)); kind != "" && kind != CredentialMetadata {
+			t.access(kind, path, call.Name)
+		}
+	case "connect", "sendto":
+		addr, size := args[1], args[2]
+		if call.Name == "sendto" {
+			addr, size = args[4], args[5]
+		}
+		if addr == 0 {
+			return
+		}
+		if target, ok := sockaddrAddress(readTraceeBytes(pid, uintptr(addr), int(min(size, 28)))); ok && isMetadataAddress(target) {
+			t.access(CredentialMetadata, target, call.Name)
+		}
+	}
+}
+
+// failed records why the kernel refused the syscall in progress
+func (t *credentialTracer) failed(errno syscall.Errno) {
+	if t.pending >= 0 {
+		t.accesses[t.pending].Error = errno.Error()
+		t.pending = -1
+	}
+}
@@ -0,0 +1,69 @@
+//go:build linux
+
+package aegong
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestSockaddrAddress tests decoding the addresses agents connect to
+func TestSockaddrAddress(t *testing.T) {
+	ipv4 := []byte{2, 0, 0, 80, 169, 254, 169, 254, 0, 0, 0, 0, 0, 0, 0, 0}
+	if got, ok := sockaddrAddress(ipv4); !ok || got != "169.254.169.254:80" {
+		t.Errorf("Should decode IPv4, got %q", got)
+	}
+	ipv6 := append([]byte{10, 0, 1, 187, 0, 0, 0, 0}, 0xfd, 0, 0x0e, 0xc2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x02, 0x54, 0, 0, 0, 0)
+	if got, ok := sockaddrAddress(ipv6); !ok || got != "[fd00:ec2::254]:443" {
+		t.Errorf("Should decode IPv6, got %q", got)
+	}
+	if _, ok := sockaddrAddress([]byte{1, 0, '/', 't', 'm', 'p'}); ok {
+		t.Error("Should ignore Unix sockets")
+	}
+}
+
+// TestCredentialTracing tests that the tracer sees a Python agent reach for
+// credentials
+func TestCredentialTracing(t *testing.T) {
+	requireSandbox(t)
+	if pythonHarness.interpreter() == "" {
+		t.Skip("python3 is not installed")
+	}
+	engine := newTestEngine(t)
+
+	container, err := engine.createIsolatedContainer("credentials-test")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer engine.destroyContainer(container.ID)
+
+	agent := []byte(`import socket
+
+for path in ("/root/.aws/credentials", "/home/agent/.ssh/id_rsa"):
+    try:
+        open(path).read()
+    except OSError:
+        pass
+s = socket.socket()
+s.settimeout(1)
+try:
+    s.connect(("169.254.169.254", 80))
+except OSError:
+    pass
+`)
+	executionLog := engine.simulateExecution(context.Background(), agent, container)
+	kinds := make(map[string]string)
+	for _, access := range container.CredentialAccesses {
+		kinds[access.Kind] = access.Target
+	}
+	if kinds[CredentialCloud] != "/root/.aws/credentials" || kinds[CredentialSSH] != "/home/agent/.ssh/id_rsa" {
+		t.Fatalf("Reads of credential files should be traced, got %+v:\n%s", container.CredentialAccesses, executionLog)
+	}
+	if kinds[CredentialMetadata] != "169.254.169.254:80" {
+		t.Fatalf("Connecting to the metadata service should be traced, got %+v", container.CredentialAccesses)
+	}
+	if !strings.Contains(executionLog, "Credential Accesses:") {
+		t.Fatal("Accesses should be added to the execution log")
+	}
+}
@@ -0,0 +1,85 @@
+package aegong
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCredentialHarvesting tests finding references to credential stores in agents
+func TestCredentialHarvesting(t *testing.T) {
+	agent := []byte(`import os, urllib.request
+
+keys = open(os.path.expanduser("~/.aws/credentials")).read()
+ssh = open(os.path.expanduser("~/.ssh/id_ed25519")).read()
+role = urllib.request.urlopen("http://169.254.169.254/latest/meta-data/iam/security-credentials/").read()
+`)
+	threats := credentialHarvesting(NewAnalysisContext(PhaseStatic, agent, nil))
+	if len(threats) != 1 {
+		t.Fatalf("Should report one finding, got %+v", threats)
+	}
+	threat := threats[0]
+	if threat.Vector != T6_IDENTITY_SPOOFING || threat.Severity != CRITICAL || len(threat.Evidence) != 3 {
+		t.Errorf("Should be a CRITICAL T6 finding with three references, got %+v", threat)
+	}
+	if threat.Location == nil || threat.Location.Line != 3 {
+		t.Errorf("Should locate the first reference, got %v", threat.Location)
+	}
+
+	for _, text := range []string{
+		`SecKeychainFindGenericPassword`,
+		`subprocess.run(["security", "find-generic-password", "-s", "github"])`,
+		`CryptUnprotectData`,
+		`Path.home() / ".config/google-chrome/Default/Login Data"`,
+		`~/.mozilla/firefox/abc.default/logins.json`,
+		`http://metadata.google.internal/computeMetadata/v1/`,
+	} {
+		if threats := credentialHarvesting(NewAnalysisContext(PhaseStatic, []byte(text), nil)); len(threats) != 1 {
+			t.Errorf("%s: Should be credential harvesting", text)
+		}
+	}
+	for _, text := range []string{`print("see ssh(1)")`, `requests.get("http://169.254.1.1/")`, `open("config.yaml")`} {
+		if threats := credentialHarvesting(NewAnalysisContext(PhaseStatic, []byte(text), nil)); len(threats) != 0 {
+			t.Errorf("%s: Should not be credential harvesting, got %v", text, threats[0].Evidence)
+		}
+	}
+}
+
+// TestCredentialAccessThreats tests reporting credential stores the agent reached for
+func TestCredentialAccessThreats(t *testing.T) {
+	container := &CustomContainer{
+		CredentialAccesses: []CredentialAccess{{Kind: CredentialSSH, Target: "/root/.ssh/id_rsa", Source: "openat", Error: "no such file or directory"}},
+		HarnessEvents:      []HarnessEvent{{Event: "request", Detail: "GET http://169.254.169.254/latest/api/token"}, {Event: "request", Detail: "GET https://example.com/"}},
+		NetworkCaptures:    []HoneypotCapture{{Service: "http", LocalAddr: "169.254.169.254:80"}, {Service: "dns", LocalAddr: "10.0.0.1:53", Summary: "A metadata.google.internal"}},
+	}
+	threats := credentialHarvesting(NewAnalysisContext(PhaseDynamic, nil, container))
+	if len(threats) != 1 || threats[0].Severity != CRITICAL || len(threats[0].Evidence) != 4 {
+		t.Fatalf("Should report every access as one CRITICAL finding, got %+v", threats)
+	}
+	if !strings.HasSuffix(threats[0].Evidence[0], "failed: no such file or directory") {
+		t.Errorf("Should say why an access failed, got %q", threats[0].Evidence[0])
+	}
+	if kinds := threats[0].Details["kinds"].([]string); len(kinds) != 2 {
+		t.Errorf("Should list SSH keys and the metadata service, got %v", kinds)
+	}
+
+	if threats := credentialHarvesting(NewAnalysisContext(PhaseDynamic, nil, &CustomContainer{})); len(threats) != 0 {
+		t.Errorf("A quiet run should have no findings, got %+v", threats)
+	}
+}
+
+// TestIsMetadataAddress tests recognizing instance metadata services
+func TestIsMetadataAddress(t *testing.T) {
+	for host, expected := range map[string]bool{
+		"169.254.169.254":          true,
+		"169.254.169.254:80":       true,
+		"[fd00:ec2::254]:80":       true,
+		"metadata.google.internal": true,
+		"169.254.170.2:80":         true,
+		"169.254.169.253":          false,
+		"example.com:80":           false,
+	} {
+		if got := isMetadataAddress(host); got != expected {
+			t.Errorf("%s: Should be %v, got %v", host, expected, got)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package aegong
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Custom vectors are numbered after the built-in T1 to T9
+const (
+	firstCustomVector = T9_GOVERNANCE_EVASION + 1
+	maxCustomVector   = 99
+)
+
+// Default confidence each matching pattern adds, as for the built-in detectors
+const defaultMatchConfidence = 0.1
+
+// VectorDefinition describes an operator defined threat vector, detected by
+// matching patterns in the agent and its execution log
+type VectorDefinition struct {
+	ID          string `json:"id"` // T10 to T99
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Patterns are case-insensitive substrings, or regular expressions when
+	// prefixed with "re:"
+	Patterns []string `json:"patterns"`
+	// Severity of a finding with any match; rules can raise it
+	Severity        string             `json:"severity"`
+	SeverityRules   []SeverityRule     `json:"severity_rules,omitempty"`
+	MatchConfidence float64            `json:"match_confidence,omitempty"` // Confidence per match, 0.1 by default
+	Recommendation  *VectorRemediation `json:"recommendation,omitempty"`
+}
+
+// SeverityRule raises a finding's severity when enough patterns match, or a
+// particular pattern does
+type SeverityRule struct {
+	MinMatches int    `json:"min_matches,omitempty"`
+	Pattern    string `json:"pattern,omitempty"` // One of the definition's patterns
+	Severity   string `json:"severity"`
+}
+
+// VectorRemediation is the recommendation made for a custom vector's findings
+type VectorRemediation struct {
+	Title    string   `json:"title"`
+	Guidance string   `json:"guidance"`
+	Effort   string   `json:"effort,omitempty"` // low, medium or high; medium by default
+	Links    []string `json:"links,omitempty"`
+}
+
+// Custom vectors by number, shared by ThreatName and the engines that define them
+var (
+	customVectors      = make(map[ThreatVector]*VectorDefinition)
+	customVectorsMutex sync.RWMutex
+)
+
+// LoadVectorDefinitions reads a JSON array of vector definitions
+func LoadVectorDefinitions(path string) ([]VectorDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read custom vectors: %v", err)
+	}
+	var definitions []VectorDefinition
+	if err := json.Unmarshal(data, &definitions); err != nil {
+		return nil, fmt.Errorf("failed to parse custom vectors: %v", err)
+	}
+	return definitions, nil
+}
+
+// CustomVector returns the definition of a custom vector
+func CustomVector(vector ThreatVector) (VectorDefinition, bool) {
+	customVectorsMutex.RLock()
+	defer customVectorsMutex.RUnlock()
+	definition, ok := customVectors[vector]
+	if !ok {
+		return VectorDefinition{}, false
+	}
+	return *definition, true
+}
+
+// parseSeverity maps a severity name to its level
+func parseSeverity(name string) (ThreatSeverity, error) {
+	for _, severity := range []ThreatSeverity{LOW, MEDIUM, HIGH, CRITICAL} {
+		if strings.EqualFold(name, SeverityName(severity)) {
+			return severity, nil
+		}
+	}
+	return LOW, fmt.Errorf("unknown severity %q", name)
+}
+
+// customPattern is a compiled pattern of a definition
+type customPattern struct {
+	source string
+	locate func(lower string, n int) [][]int // Byte ranges of up to n matches, all if n < 0
+}
+
+// severityRule is a compiled SeverityRule
+type severityRule struct {
+	minMatches int
+	pattern    string
+	severity   ThreatSeverity
+}
+
+// CustomVectorDetector matches a custom vector's patterns
+type CustomVectorDetector struct {
+	vector     ThreatVector
+	definition VectorDefinition
+	patterns   []customPattern
+	severity   ThreatSeverity
+	rules      []severityRule
+	confidence float64
+	checksum   string // Of the definition, for the cache config version
+}
+
+// newCustomVectorDetector validates a definition and compiles its patterns
+func newCustomVectorDetector(definition VectorDefinition) (*CustomVectorDetector, error) {
+	number, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(definition.ID), "T"))
+	if !strings.HasPrefix(strings.ToUpper(definition.ID), "T") || err != nil ||
+		ThreatVector(number-1) < firstCustomVector || number > maxCustomVector {
+		return nil, fmt.Errorf("custom vector id %q must be T10 to T%d", definition.ID, maxCustomVector)
+	}
+	if definition.Name == "" {
+		return nil, fmt.Errorf("custom vector %s has no name", definition.ID)
+	}
+	if len(definition.Patterns) == 0 {
+		return nil, fmt.Errorf("custom vector %s has no patterns", definition.ID)
+	}
+	if definition.MatchConfidence < 0 || definition.MatchConfidence > 1 {
+		return nil, fmt.Errorf("custom vector %s: match_confidence must be between 0 and 1", definition.ID)
+	}
+
+	detector := &CustomVectorDetector{
+		vector:     ThreatVector(number - 1),
+		definition: definition,
+		confidence: definition.MatchConfidence,
+	}
+	detector.definition.ID = detectorName(detector.vector)
+	if detector.confidence == 0 {
+		detector.confidence = defaultMatchConfidence
+	}
+	if detector.severity, err = parseSeverity(definition.Severity); err != nil {
+		return nil, fmt.Errorf("custom vector %s: %v", definition.ID, err)
+	}
+
+	known := make(map[string]bool)
+	for _, source := range definition.Patterns {
+		pattern, err := compilePattern(source)
+		if err != nil {
+			return nil, fmt.Errorf("custom vector %s: %v", definition.ID, err)
+		}
+		known[source] = true
+		detector.patterns = append(detector.patterns, pattern)
+	}
+
+	for _, rule := range definition.SeverityRules {
+		severity, err := parseSeverity(rule.Severity)
+		if err != nil {
+			return nil, fmt.Errorf("custom vector %s: %v", definition.ID, err)
+		}
+		if rule.Pattern != "" && !known[rule.Pattern] {
+			return nil, fmt.Errorf("custom vector %s: severity rule names unknown pattern %q", definition.ID, rule.Pattern)
+		}
+		if rule.MinMatches <= 0 && rule.Pattern == "" {
+			return nil, fmt.Errorf("custom vector %s: severity rule needs min_matches or pattern", definition.ID)
+		}
+		detector.rules = append(detector.rules, severityRule{minMatches: rule.MinMatches, pattern: rule.Pattern, severity: severity})
+	}
+
+	if r := definition.Recommendation; r != nil {
+		switch r.Effort {
+		case "", EffortLow, EffortMedium, EffortHigh:
+		default:
+			return nil, fmt.Errorf("custom vector %s: unknown recommendation effort %q", definition.ID, r.Effort)
+		}
+		if r.Title == "" {
+			return nil, fmt.Errorf("custom vector %s: recommendation has no title", definition.ID)
+		}
+	}
+
+	data, _ := json.Marshal(detector.definition)
+	sum := sha256.Sum256(data)
+	detector.checksum = hex.EncodeToString(sum[:8])
+	return detector, nil
+}
+
+// compilePattern compiles a case-insensitive substring, or a regular
+// expression prefixed with "re:"
+func compilePattern(source string) (customPattern, error) {
+	pattern := customPattern{source: source}
+	if expression, ok := strings.CutPrefix(source, "re:"); ok {
+		re, err := regexp.Compile("(?i)" + expression)
+		if err != nil {
+			return pattern, fmt.Errorf("invalid pattern %q: %v", source, err)
+		}
+		pattern.locate = re.FindAllStringIndex
+		return pattern, nil
+	}
+	substring := strings.ToLower(source)
+	if substring == "" {
+		return pattern, fmt.Errorf("empty pattern")
+	}
+	pattern.locate = func(lower string, n int) [][]int {
+		var found [][]int
+		for offset := 0; len(found) != n; {
+			i := strings.Index(lower[offset:], substring)
+			if i < 0 {
+				break
+			}
+			found = append(found, []int{offset + i, offset + i + len(substring)})
+			offset += i + len(substring)
+		}
+		return found
+	}
+	return pattern, nil
+}
+
+func (d *CustomVectorDetector) DetectThreat(ctx context.Context, binary []byte, container *CustomContainer) []ThreatDetection {
+	return d.Analyze(ctx, NewAnalysisContext(PhaseStatic, binary, container))
+}
+
+func (d *CustomVectorDetector) Inputs() []AnalysisInput {
+	return []AnalysisInput{InputText}
+}
+
+func (d *CustomVectorDetector) Analyze(ctx context.Context, analysis *AnalysisContext) []ThreatDetection {
+	lower := analysis.Text()
+	evidence := []string{}
+	matched := make(map[string]bool)
+	first := -1 // Offset of the earliest match
+	for _, pattern := range d.patterns {
+		if matches := pattern.locate(lower, 1); len(matches) > 0 {
+			first = earliest(first, matches[0][0])
+			evidence = append(evidence, fmt.Sprintf("%s pattern: %s", d.definition.Name, pattern.source))
+			matched[pattern.source] = true
+		}
+	}
+	if len(evidence) == 0 {
+		return nil
+	}
+
+	severity := d.severity
+	for _, rule := range d.rules {
+		if len(evidence) >= rule.minMatches && (rule.pattern == "" || matched[rule.pattern]) {
+			severity = max(severity, rule.severity)
+		}
+	}
+
+	return []ThreatDetection{{
+		Vector:     d.vector,
+		Severity:   severity,
+		Confidence: min(float64(len(evidence))*d.confidence, 1.0),
+		Evidence:   evidence,
+		Timestamp:  time.Now(),
+		Details: map[string]interface{}{
+			"pattern_count": len(evidence),
+			"custom_vector": d.definition.ID,
+		},
+		Location: analysis.Locate(first),
+	}}
+}
+
+func (d *CustomVectorDetector) GetThreatVector() ThreatVector {
+	return d.vector
+}
+
+// registerCustomVectors validates definitions and adds their detectors to the engine
+func (e *Engine) registerCustomVectors(definitions []VectorDefinition) error {
+	detectors := make(map[ThreatVector]*CustomVectorDetector)
+	for _, definition := range definitions {
+		detector, err := newCustomVectorDetector(definition)
+		if err != nil {
+			return err
+		}
+		if _, ok := detectors[detector.vector]; ok {
+			return fmt.Errorf("custom vector %s is defined twice", detector.definition.ID)
+		}
+		detectors[detector.vector] = detector
+	}
+
+	customVectorsMutex.Lock()
+	defer customVectorsMutex.Unlock()
+	for vector, detector := range detectors {
+		definition := detector.definition
+		customVectors[vector] = &definition
+		e.threatDetectors[vector] = detector
+	}
+	return nil
+}
+
+// customRemediation returns the knowledge base entry of a custom vector
+func customRemediation(vector ThreatVector) (remediation, bool) {
+	definition, ok := CustomVector(vector)
+	if !ok {
+		return remediation{}, false
+	}
+	entry := remediation{
+		title:    fmt.Sprintf("Review %s findings", definition.Name),
+		effort:   EffortMedium,
+		guidance: definition.Description,
+	}
+	if r := definition.Recommendation; r != nil {
+		entry.title, entry.guidance, entry.links = r.Title, r.Guidance, r.Links
+		if r.Effort != "" {
+			entry.effort = r.Effort
+		}
+	}
+	return entry, true
+}
+
+// Limits on what a dry run returns per pattern
+const (
+	maxDryRunExcerpts = 5
+	maxExcerptLength  = 200
+)
+
+// PatternExcerpt is a place a pattern matched in a dry run
+type PatternExcerpt struct {
+	Line   int    `json:"line"`
+	Offset int    `json:"offset"` // Byte offset of the match
+	Text   string `json:"text"`   // The line it is on, lowercased as patterns see it
+}
+
+// PatternResult is one pattern's matches in a dry run
+type PatternResult struct {
+	Pattern  string           `json:"pattern"`
+	Matches  int              `json:"matches"`
+	Excerpts []PatternExcerpt `json:"excerpts,omitempty"` // The first few matches
+}
+
+// VectorDryRun is what a vector definition finds in a sample payload
+type VectorDryRun struct {
+	Vector   string           `json:"vector"`
+	Patterns []PatternResult  `json:"patterns"`
+	Finding  *ThreatDetection `json:"finding,omitempty"` // What an audit would report; nil without matches
+}
+
+// DryRunVector matches a vector definition against a payload without
+// registering it, so patterns can be tried out without running audits. The
+// ID, name and severity are optional: definitions default to a MEDIUM T99.
+func DryRunVector(ctx context.Context, definition VectorDefinition, payload []byte) (*VectorDryRun, error) {
+	if definition.ID == "" {
+		definition.ID = fmt.Sprintf("T%d", maxCustomVector)
+	}
+	if definition.Name == "" {
+		definition.Name = "Dry run"
+	}
+	if definition.Severity == "" {
+		definition.Severity = SeverityName(MEDIUM)
+	}
+	detector, err := newCustomVectorDetector(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	analysis := NewAnalysisContext(PhaseStatic, payload, nil)
+	lower := analysis.Text()
+	run := &VectorDryRun{Vector: detector.definition.ID, Patterns: []PatternResult{}}
+	for _, pattern := range detector.patterns {
+		matches := pattern.locate(lower, -1)
+		result := PatternResult{Pattern: pattern.source, Matches: len(matches)}
+		for _, match := range matches[:min(len(matches), maxDryRunExcerpts)] {
+			result.Excerpts = append(result.Excerpts, excerpt(lower, match[0]))
+		}
+		run.Patterns = append(run.Patterns, result)
+	}
+
+	if threats := detector.Analyze(ctx, analysis); len(threats) > 0 {
+		finding := threats[0]
+		finding.VectorName = definition.Name
+		finding.SeverityName = SeverityName(finding.Severity)
+		run.Finding = &finding
+	}
+	return run, nil
+}
+
+// excerpt returns the line of text around offset
+func excerpt(text string, offset int) PatternExcerpt {
+	start := strings.LastIndexByte(text[:offset], '\n') + 1
+	end := len(text)
+	if i := strings.IndexByte(text[offset:], '\n'); i >= 0 {
+		end = offset + i
+	}
+	// Long lines, as in minified code or binaries, are cut around the match
+	if end-start > maxExcerptLength {
+		start = max(start, offset-maxExcerptLength/4)
+		end = min(end, start+maxExcerptLength)
+	}
+	return PatternExcerpt{
+		Line:   strings.Count(text[:offset], "\n") + 1,
+		Offset: offset,
+		Text:   strings.ToValidUTF8(strings.TrimSpace(text[start:end]), "?"),
+	}
+}
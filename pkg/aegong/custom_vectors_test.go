@@ -0,0 +1,118 @@
+package aegong
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// testCustomVector is a data exfiltration vector used by the tests below
+var testCustomVector = VectorDefinition{
+	ID:          "T10",
+	Name:        "Data Exfiltration",
+	Description: "Agent collects and ships out data it was not asked to",
+	Patterns:    []string{"upload_dump", "re:s3://[a-z0-9.-]+/exfil", "pastebin.com"},
+	Severity:    "medium",
+	SeverityRules: []SeverityRule{
+		{MinMatches: 2, Severity: "high"},
+		{Pattern: "pastebin.com", Severity: "critical"},
+	},
+	Recommendation: &VectorRemediation{Title: "Block outbound data transfers", Guidance: "Route uploads through an approved proxy.", Effort: EffortLow},
+}
+
+// TestCustomVectorDefinitions tests validation of vector definitions
+func TestCustomVectorDefinitions(t *testing.T) {
+	for name, definition := range map[string]VectorDefinition{
+		"built-in id":     {ID: "T9", Name: "x", Patterns: []string{"a"}, Severity: "LOW"},
+		"bad id":          {ID: "X10", Name: "x", Patterns: []string{"a"}, Severity: "LOW"},
+		"no patterns":     {ID: "T10", Name: "x", Severity: "LOW"},
+		"bad severity":    {ID: "T10", Name: "x", Patterns: []string{"a"}, Severity: "SEVERE"},
+		"bad expression":  {ID: "T10", Name: "x", Patterns: []string{"re:("}, Severity: "LOW"},
+		"unknown pattern": {ID: "T10", Name: "x", Patterns: []string{"a"}, Severity: "LOW", SeverityRules: []SeverityRule{{Pattern: "b", Severity: "HIGH"}}},
+	} {
+		if _, err := newCustomVectorDetector(definition); err == nil {
+			t.Fatalf("Definition with %s should be rejected", name)
+		}
+	}
+
+	if _, err := NewEngine(Config{CustomVectors: []VectorDefinition{testCustomVector, testCustomVector}}); err == nil {
+		t.Fatal("Should reject a vector defined twice")
+	}
+}
+
+// TestCustomVectorDetection tests that custom vectors are detected and reported like built-ins
+func TestCustomVectorDetection(t *testing.T) {
+	engine, err := NewEngine(Config{CustomVectors: []VectorDefinition{testCustomVector}})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	vector := ThreatVector(9)
+	if ThreatName(vector) != "Data Exfiltration" {
+		t.Fatalf("ThreatName should name the custom vector, got %q", ThreatName(vector))
+	}
+	if err := engine.CheckScope(AuditScope{Vectors: []string{"t10"}}); err != nil {
+		t.Fatalf("Scope should accept the custom vector: %v", err)
+	}
+	components := engine.Components()
+	if components[8].Name != "T9" || components[9].Name != "T10" || components[9].Description != "Data Exfiltration" {
+		t.Fatalf("T10 should be listed after T9, got %+v", components[8:10])
+	}
+
+	detector := engine.threatDetectors[vector]
+	if threats := detector.DetectThreat(context.Background(), []byte("print('hello')"), nil); len(threats) != 0 {
+		t.Fatalf("Should not flag a clean agent, got %+v", threats)
+	}
+	threats := detector.DetectThreat(context.Background(), []byte("upload_dump(); put('S3://corp-bucket/exfil/db')"), nil)
+	if len(threats) != 1 || threats[0].Severity != HIGH || threats[0].Confidence != 0.2 || len(threats[0].Evidence) != 2 {
+		t.Fatalf("Two matches should be a HIGH finding, got %+v", threats)
+	}
+	if !strings.HasPrefix(threats[0].Evidence[0], "Data Exfiltration pattern: ") {
+		t.Fatalf("Unexpected evidence %q", threats[0].Evidence[0])
+	}
+	if threats := detector.DetectThreat(context.Background(), []byte("requests.post('https://pastebin.com')"), nil); threats[0].Severity != CRITICAL {
+		t.Fatalf("Pastebin should raise the severity to CRITICAL, got %v", threats[0].Severity)
+	}
+
+	recommendations := engine.generateRecommendations(threats, nil)
+	if len(recommendations) != 1 || recommendations[0].ID != "T10" || recommendations[0].Title != "Block outbound data transfers" || recommendations[0].Effort != EffortLow {
+		t.Fatalf("Should recommend the custom vector's remediation, got %+v", recommendations)
+	}
+}
+
+// TestDryRunVector tests matching patterns against a sample without registering them
+func TestDryRunVector(t *testing.T) {
+	payload := []byte("import boto3\n\nclient.put('s3://corp/exfil')\nclient.put('S3://corp/more')\n")
+	definition := VectorDefinition{
+		Patterns:      []string{"s3://", `re:put\('s3`, "pastebin"},
+		SeverityRules: []SeverityRule{{MinMatches: 2, Severity: "HIGH"}},
+	}
+
+	run, err := DryRunVector(context.Background(), definition, payload)
+	if err != nil {
+		t.Fatalf("Dry run should accept a definition with only patterns: %v", err)
+	}
+	if run.Vector != "T99" || len(run.Patterns) != 3 {
+		t.Fatalf("Should test every pattern as T99, got %+v", run)
+	}
+	first := run.Patterns[0]
+	if first.Matches != 2 || first.Excerpts[1].Line != 4 || first.Excerpts[1].Text != "client.put('s3://corp/more')" {
+		t.Errorf("Should locate each match by line, got %+v", first)
+	}
+	if run.Patterns[1].Matches != 2 || run.Patterns[2].Matches != 0 {
+		t.Errorf("Regular expressions should match case-insensitively, got %+v", run.Patterns)
+	}
+	if run.Finding == nil || run.Finding.Severity != HIGH || run.Finding.VectorName != "Dry run" || len(run.Finding.Evidence) != 2 {
+		t.Errorf("Should report the finding an audit would make, got %+v", run.Finding)
+	}
+	if _, ok := CustomVector(T9_GOVERNANCE_EVASION + 90); ok {
+		t.Error("Dry runs should not register the vector")
+	}
+
+	if run, _ := DryRunVector(context.Background(), definition, []byte("print('hello')")); run.Finding != nil {
+		t.Errorf("Payloads without matches should have no finding, got %+v", run.Finding)
+	}
+	definition.Patterns = []string{"re:("}
+	if _, err := DryRunVector(context.Background(), definition, payload); err == nil {
+		t.Error("Invalid patterns should be rejected")
+	}
+}
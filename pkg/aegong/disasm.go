@@ -0,0 +1,631 @@
+package aegong
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Disassembly heuristics decode the executable sections of x86-64 agents and
+// report what the instructions do: how many calls go through registers or
+// memory, where the agent makes system calls itself instead of through libc,
+// and whether it writes into its own code. A small built-in decoder is used
+// rather than Capstone, keeping the engine free of cgo.
+
+// Limits and thresholds of the disassembly analysis
+const (
+	maxDisassembledBytes = 64 << 20
+	// Calls at which the share of indirect calls is judged, and the share
+	// beyond which calls are mostly resolved at runtime
+	minCallsForDensity    = 100
+	indirectCallThreshold = 0.5
+	maxInstructionSamples = 5
+	// Bytes around a store into code that must decode cleanly
+	codeWriteWindow = 32
+)
+
+// x86Inst is a decoded instruction, as far as the heuristics need it
+type x86Inst struct {
+	length       int
+	call         bool // Direct or indirect call
+	conditional  bool // Conditional branch
+	relative     bool // Branches to rel, relative to the end of the instruction
+	rel          int64
+	indirectCall bool // call through a register or memory
+	indirectJump bool // jmp through a register or memory
+	syscall      bool // syscall, sysenter or int 0x80
+	memoryWrite  bool // Stores to its ModRM memory operand
+	setsRAX      bool // Loads rax, as before a system call
+	ripRelative  bool
+	disp         int32 // Displacement of the memory operand
+}
+
+// One-byte opcodes that take a ModRM byte
+var modrmOpcodes = func() [256]bool {
+	var table [256]bool
+	for _, base := range []int{0x00, 0x08, 0x10, 0x18, 0x20, 0x28, 0x30, 0x38} {
+		for i := 0; i < 4; i++ {
+			table[base+i] = true
+		}
+	}
+	for _, op := range []int{0x62, 0x63, 0x69, 0x6B, 0xC0, 0xC1, 0xC4, 0xC5, 0xC6, 0xC7, 0xF6, 0xF7, 0xFE, 0xFF} {
+		table[op] = true
+	}
+	for op := 0x80; op <= 0x8F; op++ {
+		table[op] = true
+	}
+	for op := 0xD0; op <= 0xDF; op++ {
+		table[op] = op != 0xD4 && op != 0xD5 && op != 0xD6 && op != 0xD7
+	}
+	return table
+}()
+
+// Size of the immediate of one-byte opcodes; -1 is imm16/32 by operand size
+var immediateSizes = func() [256]int {
+	var table [256]int
+	for _, op := range []int{0x04, 0x0C, 0x14, 0x1C, 0x24, 0x2C, 0x34, 0x3C, 0x6A, 0x6B, 0x80, 0x83, 0xA8, 0xC0, 0xC1, 0xC6, 0xCD, 0xE4, 0xE5, 0xE6, 0xE7, 0xEB} {
+		table[op] = 1
+	}
+	for op := 0x70; op <= 0x7F; op++ {
+		table[op] = 1
+	}
+	for op := 0xB0; op <= 0xB7; op++ {
+		table[op] = 1
+	}
+	for op := 0xE0; op <= 0xE3; op++ {
+		table[op] = 1
+	}
+	for _, op := range []int{0x05, 0x0D, 0x15, 0x1D, 0x25, 0x2D, 0x35, 0x3D, 0x68, 0x69, 0x81, 0xA9, 0xC7, 0xE8, 0xE9} {
+		table[op] = -1
+	}
+	table[0xC2], table[0xCA], table[0xC8] = 2, 2, 3
+	return table
+}()
+
+// One-byte opcodes that are invalid in 64-bit mode
+var invalidOpcodes = map[byte]bool{0x06: true, 0x07: true, 0x0E: true, 0x16: true, 0x17: true, 0x1E: true, 0x1F: true, 0x27: true, 0x2F: true, 0x37: true, 0x3F: true, 0x60: true, 0x61: true, 0x82: true, 0x9A: true, 0xCE: true, 0xD4: true, 0xD5: true, 0xD6: true, 0xEA: true}
+
+// Opcodes that always store to their ModRM operand (mov, add, or, and, sub, xor)
+var storeOpcodes = map[byte]bool{0x00: true, 0x01: true, 0x08: true, 0x09: true, 0x20: true, 0x21: true, 0x28: true, 0x29: true, 0x30: true, 0x31: true, 0x88: true, 0x89: true, 0xC6: true, 0xC7: true}
+
+// twoByteModRM reports whether the 0F-prefixed opcode takes a ModRM byte
+func twoByteModRM(op byte) bool {
+	switch {
+	case op == 0x05 || op == 0x06 || op == 0x07 || op == 0x08 || op == 0x09 || op == 0x0B || op == 0x0E:
+		return false
+	case op >= 0x30 && op <= 0x37, op == 0x77:
+		return false
+	case op >= 0x80 && op <= 0x8F:
+		return false
+	case op == 0xA0 || op == 0xA1 || op == 0xA2 || op == 0xA8 || op == 0xA9 || op == 0xAA:
+		return false
+	case op >= 0xC8 && op <= 0xCF:
+		return false
+	}
+	return true
+}
+
+// twoByteImmediate returns the immediate size of a 0F-prefixed opcode
+func twoByteImmediate(op byte) int {
+	switch op {
+	case 0x70, 0x71, 0x72, 0x73, 0xA4, 0xAC, 0xBA, 0xC2, 0xC4, 0xC5, 0xC6, 0x0F:
+		return 1
+	}
+	if op >= 0x80 && op <= 0x8F {
+		return 4 // jcc rel32
+	}
+	return 0
+}
+
+// modrmLength returns the length of a ModRM byte with its SIB and displacement,
+// and the displacement itself
+func modrmLength(code []byte) (int, int32, bool, bool) {
+	if len(code) == 0 {
+		return 0, 0, false, false
+	}
+	mod, rm := code[0]>>6, code[0]&7
+	length := 1
+	if mod != 3 && rm == 4 {
+		if len(code) < 2 {
+			return 0, 0, false, false
+		}
+		if mod == 0 && code[1]&7 == 5 {
+			mod = 2 // disp32 without a base
+		}
+		length++
+	}
+	rip := mod == 0 && rm == 5
+	var disp int32
+	switch {
+	case mod == 1:
+		if len(code) < length+1 {
+			return 0, 0, false, false
+		}
+		disp = int32(int8(code[length]))
+		length++
+	case mod == 2 || rip:
+		if len(code) < length+4 {
+			return 0, 0, false, false
+		}
+		disp = int32(binary.LittleEndian.Uint32(code[length:]))
+		length += 4
+	}
+	return length, disp, rip, true
+}
+
+// decodeX86 decodes the length and kind of the x86-64 instruction at the
+// start of code
+func decodeX86(code []byte) (x86Inst, bool) {
+	var inst x86Inst
+	i := 0
+	operand16, address32 := false, false
+	var rex byte
+
+	// Legacy prefixes, then REX
+	for ; i < len(code) && i < 14; i++ {
+		switch code[i] {
+		case 0x66:
+			operand16 = true
+			continue
+		case 0x67:
+			address32 = true
+			continue
+		case 0xF0, 0xF2, 0xF3, 0x2E, 0x36, 0x3E, 0x26, 0x64, 0x65:
+			continue
+		}
+		break
+	}
+	if i < len(code) && code[i]&0xF0 == 0x40 {
+		rex = code[i]
+		i++
+	}
+	if i >= len(code) {
+		return inst, false
+	}
+
+	op := code[i]
+	i++
+	if invalidOpcodes[op] {
+		return inst, false
+	}
+	hasModRM, immediate := modrmOpcodes[op], immediateSizes[op]
+	var op2 byte // Second opcode byte after 0F
+
+	switch {
+	case op == 0x0F:
+		if i >= len(code) {
+			return inst, false
+		}
+		op2 = code[i]
+		i++
+		switch op2 {
+		case 0x38:
+			i++ // Three-byte opcode, ModRM, no immediate
+			hasModRM, immediate = true, 0
+		case 0x3A:
+			i++
+			hasModRM, immediate = true, 1
+		default:
+			hasModRM, immediate = twoByteModRM(op2), twoByteImmediate(op2)
+		}
+		inst.syscall = op2 == 0x05 || op2 == 0x34
+	case op == 0xC4 || op == 0xC5 || op == 0x62:
+		// VEX and EVEX prefixes carry the opcode map; the opcode follows
+		prefix := map[byte]int{0xC5: 1, 0xC4: 2, 0x62: 3}[op]
+		if i+prefix >= len(code) {
+			return inst, false
+		}
+		opcodeMap := 1
+		if op != 0xC5 {
+			opcodeMap = int(code[i] & 0x03)
+		}
+		i += prefix + 1 // prefix payload and opcode
+		hasModRM, immediate = true, 0
+		if opcodeMap == 3 {
+			immediate = 1
+		}
+	case op >= 0xB8 && op <= 0xBF:
+		immediate = 4
+		if rex&0x08 != 0 {
+			immediate = 8
+		}
+	case op >= 0xA0 && op <= 0xA3:
+		immediate = 8 // moffs
+		if address32 {
+			immediate = 4
+		}
+	case op == 0xCD:
+		inst.syscall = i < len(code) && code[i] == 0x80
+	}
+	if immediate == -1 {
+		immediate = 4
+		if operand16 {
+			immediate = 2
+		}
+	}
+	inst.call = op == 0xE8
+	inst.setsRAX = op == 0xB8 && rex&0x01 == 0
+
+	if hasModRM {
+		if i >= len(code) {
+			return inst, false
+		}
+		modrm := code[i]
+		reg, memory := (modrm>>3)&7, modrm>>6 != 3
+		length, disp, rip, ok := modrmLength(code[i:])
+		if !ok {
+			return inst, false
+		}
+		i += length
+		inst.disp, inst.ripRelative = disp, rip
+		// Whether the r/m or reg operand is rax rather than r8
+		rmRAX := modrm>>6 == 3 && modrm&7 == 0 && rex&0x01 == 0
+		regRAX := reg == 0 && rex&0x04 == 0
+
+		switch op {
+		case 0xF6:
+			if reg <= 1 {
+				immediate = 1 // test r/m8, imm8
+			}
+			inst.memoryWrite = memory && (reg == 2 || reg == 3)
+		case 0xF7:
+			if reg <= 1 {
+				immediate = 4
+				if operand16 {
+					immediate = 2
+				}
+			}
+			inst.memoryWrite = memory && (reg == 2 || reg == 3)
+		case 0xFF:
+			inst.call = reg == 2 || reg == 3
+			inst.indirectCall = inst.call
+			inst.indirectJump = reg == 4 || reg == 5
+			inst.memoryWrite = memory && reg <= 1
+		case 0xFE:
+			inst.memoryWrite = memory && reg <= 1
+		case 0x80, 0x81, 0x83:
+			inst.memoryWrite = memory && reg != 7
+		case 0x31, 0x33:
+			inst.setsRAX = rmRAX && regRAX // xor eax, eax
+			inst.memoryWrite = memory && op == 0x31
+		case 0x89:
+			inst.setsRAX = rmRAX
+			inst.memoryWrite = memory
+		case 0x8B:
+			inst.setsRAX = regRAX
+		case 0xC7:
+			inst.setsRAX = rmRAX && reg == 0
+			inst.memoryWrite = memory
+		default:
+			inst.memoryWrite = memory && storeOpcodes[op]
+		}
+	}
+
+	i += immediate
+	if i > len(code) || i > 15 {
+		return inst, false
+	}
+	inst.length = i
+
+	// Branch targets are the immediate at the end of the instruction
+	twoByte := op == 0x0F && op2&0xF0 == 0x80 // jcc rel32
+	switch {
+	case op >= 0x70 && op <= 0x7F, op >= 0xE0 && op <= 0xE3, op == 0xEB:
+		inst.relative, inst.rel = true, int64(int8(code[i-1]))
+	case op == 0xE8 || op == 0xE9 || twoByte:
+		inst.relative, inst.rel = true, int64(int32(binary.LittleEndian.Uint32(code[i-4:])))
+	}
+	inst.conditional = op >= 0x70 && op <= 0x7F || op >= 0xE0 && op <= 0xE3 || twoByte
+	return inst, true
+}
+
+// codeRegion is an executable section and where it is loaded
+type codeRegion struct {
+	name    string
+	address uint64
+	code    []byte
+	data    []addressRange // Data objects inside the section, in address order
+}
+
+type addressRange struct {
+	start, end uint64
+}
+
+// executableCode is the code of an x86-64 agent
+type executableCode struct {
+	format    string // elf, pe or macho
+	machine   string // Set when the agent is not x86-64
+	regions   []codeRegion
+	functions map[uint64]string // Function symbols by address, when not stripped
+}
+
+// contains reports whether an address lies in one of the executable sections
+func (c *executableCode) contains(address uint64) bool {
+	for _, region := range c.regions {
+		if address >= region.address && address < region.address+uint64(len(region.code)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sweep decodes the executable sections in order, up to maxDisassembledBytes,
+// skipping the data objects in them. visit is called for each instruction
+// and, with ok false, for each byte that does not decode. It reports whether
+// the code was truncated.
+func (c *executableCode) sweep(visit func(region *codeRegion, offset int, inst x86Inst, ok bool)) bool {
+	budget := maxDisassembledBytes
+	for r := range c.regions {
+		region := &c.regions[r]
+		code := region.code
+		if len(code) > budget {
+			code = code[:budget]
+		}
+		budget -= len(code)
+
+		objects := region.data
+		for offset := 0; offset < len(code); {
+			address := region.address + uint64(offset)
+			inst, ok := decodeX86(code[offset:])
+			if !ok {
+				inst.length = 1
+			}
+			for len(objects) > 0 && objects[0].end <= address {
+				objects = objects[1:]
+			}
+			if len(objects) > 0 && objects[0].start < address+uint64(inst.length) {
+				offset = int(objects[0].end - region.address)
+				continue
+			}
+			visit(region, offset, inst, ok)
+			offset += inst.length
+		}
+		if budget <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// dataObjects returns the ranges of the ELF data symbols that lie in a section.
+// Assemblers put lookup tables among the code, and decoding them as
+// instructions finds system calls and stores that are not there.
+func dataObjects(symbols []elf.Symbol, section *elf.Section) []addressRange {
+	var objects []addressRange
+	for _, symbol := range symbols {
+		if elf.ST_TYPE(symbol.Info) == elf.STT_OBJECT && symbol.Size > 0 &&
+			symbol.Value >= section.Addr && symbol.Value < section.Addr+section.Size {
+			objects = append(objects, addressRange{symbol.Value, symbol.Value + symbol.Size})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].start < objects[j].start })
+	return objects
+}
+
+// executableRegions returns the executable sections of an x86-64 agent, or
+// nil when it is not an executable
+func executableRegions(binaryData []byte) *executableCode {
+	reader := bytes.NewReader(binaryData)
+	if file, err := elf.NewFile(reader); err == nil {
+		defer file.Close()
+		code := &executableCode{format: "elf"}
+		if file.Machine != elf.EM_X86_64 {
+			code.machine = file.Machine.String()
+			return code
+		}
+		symbols, _ := file.Symbols()
+		dynamic, _ := file.DynamicSymbols()
+		symbols = append(symbols, dynamic...)
+		for _, symbol := range symbols {
+			if elf.ST_TYPE(symbol.Info) == elf.STT_FUNC && symbol.Value != 0 {
+				if code.functions == nil {
+					code.functions = make(map[uint64]string)
+				}
+				code.functions[symbol.Value] = symbol.Name
+			}
+		}
+		for _, section := range file.Sections {
+			if section.Flags&elf.SHF_EXECINSTR != 0 && section.Type == elf.SHT_PROGBITS {
+				if data, err := section.Data(); err == nil {
+					code.regions = append(code.regions, codeRegion{section.Name, section.Addr, data, dataObjects(symbols, section)})
+				}
+			}
+		}
+		if len(file.Sections) == 0 {
+			for i, prog := range file.Progs {
+				if prog.Type == elf.PT_LOAD && prog.Flags&elf.PF_X != 0 {
+					data := make([]byte, prog.Filesz)
+					if _, err := prog.ReadAt(data, 0); err == nil {
+						code.regions = append(code.regions, codeRegion{name: fmt.Sprintf("segment %d", i), address: prog.Vaddr, code: data})
+					}
+				}
+			}
+		}
+		return code
+	}
+	if file, err := pe.NewFile(reader); err == nil {
+		defer file.Close()
+		code := &executableCode{format: "pe"}
+		if file.Machine != pe.IMAGE_FILE_MACHINE_AMD64 {
+			code.machine = fmt.Sprintf("0x%x", file.Machine)
+			return code
+		}
+		var imageBase uint64
+		if header, ok := file.OptionalHeader.(*pe.OptionalHeader64); ok {
+			imageBase = header.ImageBase
+		}
+		for _, section := range file.Sections {
+			if section.Characteristics&pe.IMAGE_SCN_MEM_EXECUTE != 0 {
+				if data, err := section.Data(); err == nil {
+					code.regions = append(code.regions, codeRegion{name: section.Name, address: imageBase + uint64(section.VirtualAddress), code: data})
+				}
+			}
+		}
+		return code
+	}
+	if file, err := macho.NewFile(reader); err == nil {
+		defer file.Close()
+		code := &executableCode{format: "macho"}
+		if file.Cpu != macho.CpuAmd64 {
+			code.machine = file.Cpu.String()
+			return code
+		}
+		for _, section := range file.Sections {
+			if section.Seg == "__TEXT" && section.Flags&0x80000000 != 0 { // S_ATTR_PURE_INSTRUCTIONS
+				if data, err := section.Data(); err == nil {
+					code.regions = append(code.regions, codeRegion{name: section.Name, address: section.Addr, code: data})
+				}
+			}
+		}
+		return code
+	}
+	return nil
+}
+
+// CodeAnalysis summarises the instructions of an agent's executable sections
+type CodeAnalysis struct {
+	Instructions  int      `json:"instructions"`
+	Undecodable   int      `json:"undecodable_bytes"`
+	Calls         int      `json:"calls"`
+	IndirectCalls int      `json:"indirect_calls"`
+	IndirectJumps int      `json:"indirect_jumps"`
+	Syscalls      []string `json:"syscalls,omitempty"`     // Addresses of syscall instructions
+	CodeWrites    []string `json:"code_writes,omitempty"`  // Instructions storing into executable sections
+	SyscallCount  int      `json:"syscall_count"`          // Including those past the samples
+	WriteCount    int      `json:"code_write_count"`       // Including those past the samples
+	Truncated     bool     `json:"truncated,omitempty"`    // Code past maxDisassembledBytes was not decoded
+	Regions       []string `json:"regions"`                // Sections that were decoded
+	GoRuntime     bool     `json:"go_runtime,omitempty"`   // Go programs make their own system calls
+	Dynamic       bool     `json:"dynamically_linked"`     // Whether libc is loaded to make system calls
+	Architecture  string   `json:"architecture,omitempty"` // Set when the agent is not x86-64
+	Format        string   `json:"format"`
+}
+
+// disassemble linearly sweeps the executable sections of an x86-64 agent. It
+// returns nil for agents that are not executables.
+func disassemble(binaryData []byte) *CodeAnalysis {
+	code := executableRegions(binaryData)
+	if code == nil {
+		return nil
+	}
+	analysis := &CodeAnalysis{Format: code.format}
+	if code.machine != "" {
+		analysis.Architecture = code.format + "/" + code.machine
+		return analysis
+	}
+	analysis.Dynamic = code.format != "elf" || dynamicallyLinked(binaryData)
+	analysis.GoRuntime = bytes.Contains(binaryData, []byte("\xff Go buildinf:"))
+	for _, region := range code.regions {
+		analysis.Regions = append(analysis.Regions, region.name)
+	}
+
+	var current *codeRegion
+	loadedRAX := -1   // Instruction that last loaded rax
+	lastInvalid := -1 // Offset of the last undecodable byte
+	var pending []int // Offsets of stores into code not yet confirmed
+	confirm := func(before int) {
+		for len(pending) > 0 && pending[0] < before {
+			analysis.WriteCount++
+			if len(analysis.CodeWrites) < maxInstructionSamples {
+				inst, _ := decodeX86(current.code[pending[0]:])
+				address := current.address + uint64(pending[0])
+				target := address + uint64(inst.length) + uint64(int64(inst.disp))
+				analysis.CodeWrites = append(analysis.CodeWrites, fmt.Sprintf("0x%x writes 0x%x", address, target))
+			}
+			pending = pending[1:]
+		}
+	}
+
+	analysis.Truncated = code.sweep(func(region *codeRegion, offset int, inst x86Inst, ok bool) {
+		if region != current {
+			if current != nil {
+				confirm(len(current.code))
+			}
+			current, loadedRAX, lastInvalid = region, -1, -1
+		}
+		if !ok {
+			// Data decoded as code is full of stores; only those with
+			// valid code on both sides are believed
+			analysis.Undecodable++
+			lastInvalid, pending = offset, pending[:0]
+			return
+		}
+		confirm(offset - codeWriteWindow)
+		address := region.address + uint64(offset)
+		analysis.Instructions++
+		if inst.setsRAX {
+			loadedRAX = analysis.Instructions
+		}
+		if inst.call {
+			analysis.Calls++
+		}
+		if inst.indirectCall {
+			analysis.IndirectCalls++
+		}
+		if inst.indirectJump {
+			analysis.IndirectJumps++
+		}
+		// Real system calls load their number into rax just before
+		if inst.syscall && loadedRAX >= 0 && analysis.Instructions-loadedRAX <= 4 {
+			analysis.SyscallCount++
+			if len(analysis.Syscalls) < maxInstructionSamples {
+				analysis.Syscalls = append(analysis.Syscalls, fmt.Sprintf("0x%x", address))
+			}
+		}
+		// RIP-relative addresses count from the end of the instruction
+		if inst.memoryWrite && inst.ripRelative && (lastInvalid < 0 || offset-lastInvalid > codeWriteWindow) {
+			if code.contains(address + uint64(inst.length) + uint64(int64(inst.disp))) {
+				pending = append(pending, offset)
+			}
+		}
+	})
+	if current != nil {
+		confirm(len(current.code))
+	}
+	return analysis
+}
+
+// disassemblyThreats turns instruction-level evidence into T9 findings:
+// writes into the agent's own code, system calls that bypass libc in a
+// program that loads it, and calls mostly resolved at runtime
+func disassemblyThreats(analysis *CodeAnalysis) []ThreatDetection {
+	if analysis == nil || analysis.Instructions == 0 {
+		return nil
+	}
+
+	var evidence []string
+	severity, confidence := LOW, 0.5
+	if analysis.WriteCount > 0 {
+		evidence = append(evidence, fmt.Sprintf("%d instructions write into executable sections: %v", analysis.WriteCount, analysis.CodeWrites))
+		severity, confidence = HIGH, 0.8
+	}
+	if analysis.SyscallCount > 0 && analysis.Dynamic && !analysis.GoRuntime {
+		evidence = append(evidence, fmt.Sprintf("%d system call instructions bypass libc: %v", analysis.SyscallCount, analysis.Syscalls))
+		severity, confidence = max(severity, MEDIUM), max(confidence, 0.7)
+	}
+	if analysis.Calls >= minCallsForDensity {
+		if share := float64(analysis.IndirectCalls) / float64(analysis.Calls); share >= indirectCallThreshold {
+			evidence = append(evidence, fmt.Sprintf("%d of %d calls (%.0f%%) are indirect, hiding their targets until runtime", analysis.IndirectCalls, analysis.Calls, share*100))
+		}
+	}
+	if len(evidence) == 0 {
+		return nil
+	}
+
+	return []ThreatDetection{{
+		Vector:     T9_GOVERNANCE_EVASION,
+		Severity:   severity,
+		Confidence: confidence,
+		Evidence:   evidence,
+		Timestamp:  time.Now(),
+		Details: map[string]interface{}{
+			"analysis":    "disassembly",
+			"disassembly": analysis,
+		},
+	}}
+}
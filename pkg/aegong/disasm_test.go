@@ -0,0 +1,98 @@
+package aegong
+
+import (
+	"os"
+	"runtime"
+	"testing"
+)
+
+// TestDecodeX86 tests instruction lengths and the kinds the heuristics rely on
+func TestDecodeX86(t *testing.T) {
+	tests := []struct {
+		name  string
+		code  []byte
+		check func(x86Inst) bool
+	}{
+		{"mov eax, 60", []byte{0xB8, 0x3C, 0x00, 0x00, 0x00}, func(i x86Inst) bool { return i.length == 5 && i.setsRAX }},
+		{"mov r8d, 60", []byte{0x41, 0xB8, 0x3C, 0x00, 0x00, 0x00}, func(i x86Inst) bool { return i.length == 6 && !i.setsRAX }},
+		{"movabs rax, imm64", []byte{0x48, 0xB8, 1, 2, 3, 4, 5, 6, 7, 8}, func(i x86Inst) bool { return i.length == 10 && i.setsRAX }},
+		{"syscall", []byte{0x0F, 0x05}, func(i x86Inst) bool { return i.length == 2 && i.syscall }},
+		{"int 0x80", []byte{0xCD, 0x80}, func(i x86Inst) bool { return i.length == 2 && i.syscall }},
+		{"int3", []byte{0xCC}, func(i x86Inst) bool { return i.length == 1 && !i.syscall }},
+		{"call rel32", []byte{0xE8, 0, 0, 0, 0}, func(i x86Inst) bool { return i.length == 5 && i.call && !i.indirectCall }},
+		{"call rax", []byte{0xFF, 0xD0}, func(i x86Inst) bool { return i.length == 2 && i.indirectCall }},
+		{"call [rip+0x10]", []byte{0xFF, 0x15, 0x10, 0, 0, 0}, func(i x86Inst) bool { return i.length == 6 && i.indirectCall && i.ripRelative && !i.memoryWrite }},
+		{"jmp [rax*8+0x10]", []byte{0xFF, 0x24, 0xC5, 0x10, 0, 0, 0}, func(i x86Inst) bool { return i.length == 7 && i.indirectJump }},
+		{"mov byte [rip-4], 0x90", []byte{0xC6, 0x05, 0xFC, 0xFF, 0xFF, 0xFF, 0x90}, func(i x86Inst) bool {
+			return i.length == 7 && i.memoryWrite && i.ripRelative && i.disp == -4
+		}},
+		{"xor dword [rip+8], 0x41", []byte{0x83, 0x35, 0x08, 0, 0, 0, 0x41}, func(i x86Inst) bool { return i.length == 7 && i.memoryWrite }},
+		{"cmp dword [rip+8], 0x41", []byte{0x83, 0x3D, 0x08, 0, 0, 0, 0x41}, func(i x86Inst) bool { return i.length == 7 && !i.memoryWrite }},
+		{"test byte [rax], 1", []byte{0xF6, 0x00, 0x01}, func(i x86Inst) bool { return i.length == 3 && !i.memoryWrite }},
+		{"jne rel32", []byte{0x0F, 0x85, 0, 0, 0, 0}, func(i x86Inst) bool { return i.length == 6 }},
+		{"vpxor ymm0, ymm1, ymm2", []byte{0xC5, 0xF5, 0xEF, 0xC2}, func(i x86Inst) bool { return i.length == 4 }},
+		{"pshufd xmm0, xmm1, 0", []byte{0x66, 0x0F, 0x70, 0xC1, 0x00}, func(i x86Inst) bool { return i.length == 5 }},
+	}
+	for _, test := range tests {
+		inst, ok := decodeX86(test.code)
+		if !ok || !test.check(inst) {
+			t.Errorf("%s should decode correctly, got %+v (ok %v)", test.name, inst, ok)
+		}
+	}
+
+	if _, ok := decodeX86([]byte{0x06}); ok {
+		t.Error("Opcodes invalid in 64-bit mode should not decode")
+	}
+	if _, ok := decodeX86([]byte{0xE8, 0x00}); ok {
+		t.Error("Truncated instructions should not decode")
+	}
+}
+
+// TestDisassemblyThreats tests which instruction-level evidence is reported
+func TestDisassemblyThreats(t *testing.T) {
+	analysis := &CodeAnalysis{Instructions: 5000, Calls: 200, IndirectCalls: 20, Dynamic: true, Format: "elf"}
+	if threats := disassemblyThreats(analysis); len(threats) != 0 {
+		t.Fatalf("Ordinary code should raise nothing, got %+v", threats)
+	}
+
+	analysis.IndirectCalls = 150
+	threats := disassemblyThreats(analysis)
+	if len(threats) != 1 || threats[0].Severity != LOW || threats[0].Details["analysis"] != "disassembly" {
+		t.Fatalf("Mostly indirect calls should raise a low finding, got %+v", threats)
+	}
+
+	analysis.SyscallCount, analysis.Syscalls = 1, []string{"0x401000"}
+	if threats := disassemblyThreats(analysis); threats[0].Severity != MEDIUM || len(threats[0].Evidence) != 2 {
+		t.Fatalf("Raw system calls in a dynamically linked agent should raise the severity, got %+v", threats)
+	}
+	analysis.GoRuntime = true
+	if threats := disassemblyThreats(analysis); threats[0].Severity != LOW {
+		t.Fatalf("Go programs make their own system calls and should not be reported for it, got %+v", threats)
+	}
+
+	analysis.WriteCount, analysis.CodeWrites = 1, []string{"0x401000 writes 0x401020"}
+	if threats := disassemblyThreats(analysis); threats[0].Severity != HIGH || threats[0].Vector != T9_GOVERNANCE_EVASION {
+		t.Fatalf("Writes into code should be a high T9 finding, got %+v", threats)
+	}
+}
+
+// TestDisassemble tests that an ordinary system binary decodes without findings
+func TestDisassemble(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("system binaries are not x86-64")
+	}
+	binary, err := os.ReadFile("/bin/true")
+	if err != nil {
+		t.Skipf("Failed to read /bin/true: %v", err)
+	}
+	analysis := disassemble(binary)
+	if analysis == nil || analysis.Instructions == 0 || analysis.Calls == 0 || analysis.Architecture != "" {
+		t.Fatalf("/bin/true should be disassembled, got %+v", analysis)
+	}
+	if threats := disassemblyThreats(analysis); len(threats) != 0 {
+		t.Fatalf("/bin/true should raise nothing, got %+v", threats)
+	}
+	if disassemble([]byte("print('hello')")) != nil {
+		t.Fatal("Scripts should not be disassembled")
+	}
+}
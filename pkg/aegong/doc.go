@@ -0,0 +1,16 @@
+// Package aegong is the AEGONG agent audit engine.
+//
+// It validates that an artifact is an AI agent, runs the T1-T9 threat
+// detectors over its static content and a sandboxed execution trace, applies
+// the SHIELD validation modules and scores the result into an AuditReport.
+// The web server in the repository root is one consumer; other Go services
+// can embed the engine directly:
+//
+//	engine, err := aegong.NewEngine(aegong.DefaultConfig())
+//	if err != nil {
+//		return err
+//	}
+//	defer engine.Close()
+//
+//	report, err := engine.Audit(ctx, agentReader)
+package aegong
@@ -0,0 +1,330 @@
+package aegong
+
+import (
+	"fmt"
+)
+
+// Every detector and SHIELD module documents itself: what it checks, the
+// evidence it reports and what commonly sets it off in benign agents. The
+// UI shows this next to findings, so the explanation of why an agent was
+// flagged comes from the same code that flagged it.
+
+// ComponentDoc documents a threat detector or SHIELD module
+type ComponentDoc struct {
+	Name           string   `json:"name"` // T1 to T99, or the SHIELD module name
+	Kind           string   `json:"kind"`
+	Title          string   `json:"title"`
+	Description    string   `json:"description"`
+	LooksFor       []string `json:"looks_for"`
+	FalsePositives []string `json:"false_positives,omitempty"` // Benign code that commonly triggers it
+	References     []string `json:"references,omitempty"`
+}
+
+// documented components describe themselves; others are documented by name only
+type documented interface {
+	Doc() ComponentDoc
+}
+
+// The ATFAA threat model and SHIELD mitigation framework the built-in
+// detectors and modules follow
+const atfaaPaper = "https://arxiv.org/abs/2504.19956"
+
+// ComponentDocs documents every detector and SHIELD module, in the order of
+// Components
+func (e *Engine) ComponentDocs() []ComponentDoc {
+	components := e.Components()
+
+	e.settingsMutex.RLock()
+	defer e.settingsMutex.RUnlock()
+
+	docs := make([]ComponentDoc, 0, len(components))
+	for _, component := range components {
+		doc := ComponentDoc{Title: component.Description}
+		if d, ok := e.componentLocked(component.Name).(documented); ok {
+			doc = d.Doc()
+		}
+		doc.Name, doc.Kind = component.Name, component.Kind
+		if doc.LooksFor == nil {
+			doc.LooksFor = []string{}
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// componentLocked returns the detector or SHIELD module of a name
+func (e *Engine) componentLocked(name string) interface{} {
+	if module, ok := e.shieldModules[name]; ok {
+		return module
+	}
+	for vector, detector := range e.threatDetectors {
+		if detectorName(vector) == name {
+			return detector
+		}
+	}
+	return nil
+}
+
+func (d *ReasoningHijackDetector) Doc() ComponentDoc {
+	return ComponentDoc{
+		Title:       ThreatName(T1_REASONING_HIJACK),
+		Description: "Code that steers how the agent reasons, so its conclusions follow an attacker's path rather than the task.",
+		LooksFor: []string{
+			"Reasoning override and prompt hijack patterns such as chain.of.thought, thought.injection and decision.override",
+			"Functions named for manipulating reasoning, such as manipulate_reasoning and inject_bias",
+			"LLM responses in Python and JavaScript agents that reach eval or exec",
+			"Functions with a cyclomatic complexity over 20 or conditionals nested more than 5 deep, and executables where a tenth of the functions have a complexity over 100",
+			"Branches whose condition carries LLM responses or network data",
+		},
+		FalsePositives: []string{
+			"Agent frameworks that implement chain-of-thought prompting themselves",
+			"Large generated dispatch functions, such as parsers and state machines, for the control flow evidence",
+		},
+		References: []string{atfaaPaper, owaspLLMTop10},
+	}
+}
+
+func (d *ObjectiveCorruptionDetector) Doc() ComponentDoc {
+	return ComponentDoc{
+		Title:       ThreatName(T2_OBJECTIVE_CORRUPTION),
+		Description: "Code that changes the agent's goals or the reward and scoring it optimizes for.",
+		LooksFor: []string{
+			"Goal and objective manipulation patterns such as goal.modification, objective.drift and mission.override",
+			"Reward system manipulation such as reward_function, score_modification and utility_override",
+		},
+		FalsePositives: []string{
+			"Reinforcement learning code that defines a reward_function legitimately",
+		},
+		References: []string{atfaaPaper},
+	}
+}
+
+func (d *MemoryPoisoningDetector) Doc() ComponentDoc {
+	return ComponentDoc{
+		Title:       ThreatName(T3_MEMORY_POISONING),
+		Description: "Corruption of the knowledge, beliefs or stored state the agent relies on in later runs.",
+		LooksFor: []string{
+			"Memory and knowledge tampering patterns such as memory.poison, belief.inject and knowledge.hijack",
+			"Writes to data and config files the manifest registers with the hashes they must keep, seen by the tracer or by hashing them after the run",
+			"Instructions planted in SQLite, Chroma and FAISS memory stores, and changes to entries that were there before the run",
+		},
+		FalsePositives: []string{
+			"Agents that update their own registered files by design, such as a cache listed in the manifest by mistake",
+			"Memory entries that quote instructions as facts, such as a note recording what a user asked for from now on",
+		},
+		References: []string{atfaaPaper, owaspLLMTop10},
+	}
+}
+
+func (d *UnauthorizedActionDetector) Doc() ComponentDoc {
+	return ComponentDoc{
+		Title:       ThreatName(T4_UNAUTHORIZED_ACTION),
+		Description: "Actions beyond what the agent is allowed to do: running commands, bypassing permissions, escaping the sandbox or reaching internal networks.",
+		LooksFor: []string{
+			"Permission bypass patterns such as bypass_permission and escalate_privilege",
+			"Calls that run commands, such as exec(, system(, subprocess and os.system, and imports of execve, CreateProcess and similar functions",
+			"Network and user input in Python and JavaScript agents that reaches shell commands, deserialization or file writes",
+			"Container escape attempts while the agent runs: mounts, cgroup release_agent writes, unshare, setns, pivot_root and chroot",
+			"Connections to private, link-local and instance metadata addresses from the sandbox",
+			"Syscalls listed in the syscall policy, writes Landlock refused, and capabilities the organization's policy forbids",
+		},
+		FalsePositives: []string{
+			"Build and developer tools that legitimately run subprocesses",
+			"The word subprocess or exec in comments, documentation strings or bundled licences",
+		},
+		References: []string{
+			atfaaPaper,
+			"https://cheatsheetseries.owasp.org/cheatsheets/Authorization_Cheat_Sheet.html",
+			"https://owasp.org/www-community/attacks/Server_Side_Request_Forgery",
+		},
+	}
+}
+
+func (d *ResourceManipulationDetector) Doc() ComponentDoc {
+	return ComponentDoc{
+		Title:       ThreatName(T5_RESOURCE_MANIPULATION),
+		Description: "Exhausting or hoarding compute, memory or storage to degrade the host or evade limits.",
+		LooksFor: []string{
+			"Resource exhaustion patterns such as memory_bomb, infinite_loop and denial_of_service",
+			"Agents that fill their size-limited sandbox filesystem",
+		},
+		FalsePositives: []string{
+			"Load testing and benchmarking tools",
+			"Agents that download large models or datasets on first run",
+		},
+		References: []string{atfaaPaper, "https://docs.kernel.org/admin-guide/cgroup-v2.html"},
+	}
+}
+
+func (d *IdentitySpoofingDetector) Doc() ComponentDoc {
+	return ComponentDoc{
+		Title:       ThreatName(T6_IDENTITY_SPOOFING),
+		Description: "Posing as another user, service or publisher, or stealing the credentials to do so.",
+		LooksFor: []string{
+			"Impersonation patterns such as impersonate, token_hijack and session_hijack",
+			"Credential harvesting: cloud CLI credentials, ~/.ssh, browser password and cookie stores, keychain APIs and the instance metadata service, in the agent's text or while it runs",
+			"Signatures that fail verification or whose signer does not match the claimed publisher",
+		},
+		FalsePositives: []string{
+			"Cloud SDKs that read their own credential files to authenticate",
+			"Password managers and SSH clients",
+		},
+		References: []string{atfaaPaper, "https://docs.sigstore.dev/"},
+	}
+}
+
+func (d *TrustManipulationDetector) Doc() ComponentDoc {
+	return ComponentDoc{
+		Title:       ThreatName(T7_TRUST_MANIPULATION),
+		Description: "Manipulating the people who work with the agent into trusting or obeying it.",
+		LooksFor: []string{
+			"Social engineering patterns such as persuasion_tactics, authority_mimicry and false_confidence",
+		},
+		FalsePositives: []string{
+			"Security awareness and phishing simulation tools",
+		},
+		References: []string{atfaaPaper},
+	}
+}
+
+func (d *OversightSaturationDetector) Doc() ComponentDoc {
+	return ComponentDoc{
+		Title:       ThreatName(T8_OVERSIGHT_SATURATION),
+		Description: "Overwhelming human and automated oversight so harmful actions go unnoticed.",
+		LooksFor: []string{
+			"Alert flooding patterns such as alert_flood, log_spam, notification_bomb and event_storm",
+			"Bursts of log lines, output writes, connection attempts and requests measured while the agent runs",
+		},
+		FalsePositives: []string{
+			"Monitoring and alerting tools that test their own pipelines",
+			"Batch jobs and verbose debug logging that legitimately write thousands of lines a second",
+		},
+		References: []string{atfaaPaper},
+	}
+}
+
+func (d *GovernanceEvasionDetector) Doc() ComponentDoc {
+	return ComponentDoc{
+		Title:       ThreatName(T9_GOVERNANCE_EVASION),
+		Description: "Avoiding accountability: hiding what the agent did, or behaving differently while it is audited.",
+		LooksFor: []string{
+			"Attribution evasion patterns such as trace_elimination, forensic_evasion and accountability_bypass",
+			"Checks for debuggers, virtual machines and sandboxes, stalling before acting, and capabilities in the code that never show up when it runs",
+			"Actions delayed until the agent's clock has moved on, caught by running it with an accelerated clock",
+			"Capabilities used that the agent's manifest does not declare",
+			"System calls made directly rather than through libc, and code that writes into itself, in x86-64 executables",
+		},
+		FalsePositives: []string{
+			"Software that checks for a debugger to protect licensing",
+			"Scheduled jobs that legitimately wait before running",
+		},
+		References: []string{atfaaPaper, "https://cheatsheetseries.owasp.org/cheatsheets/Logging_Cheat_Sheet.html"},
+	}
+}
+
+// Doc describes a custom vector from its definition. Its patterns are left
+// out, since the evidence of each finding already quotes those that matched.
+func (d *CustomVectorDetector) Doc() ComponentDoc {
+	doc := ComponentDoc{
+		Title:       d.definition.Name,
+		Description: d.definition.Description,
+		LooksFor:    []string{fmt.Sprintf("%d operator defined patterns in the agent and its execution log", len(d.patterns))},
+	}
+	if d.definition.Recommendation != nil {
+		doc.References = d.definition.Recommendation.Links
+	}
+	return doc
+}
+
+func (s *SegmentationValidator) Doc() ComponentDoc {
+	return ComponentDoc{
+		Title:       "Segmentation Validator",
+		Description: "Checks that the agent ran isolated from the network, the host filesystem and unlimited resources.",
+		LooksFor: []string{
+			"A sandbox without a network, in its own filesystem, with memory and CPU limits",
+			"Boundary crossing strings such as boundary_cross and isolation_break",
+		},
+		FalsePositives: []string{
+			"Fails on hosts where the sandbox can't apply its isolation, whatever the agent does",
+		},
+		References: []string{atfaaPaper, "https://man7.org/linux/man-pages/man7/namespaces.7.html"},
+	}
+}
+
+func (h *HeuristicPatternDetector) Doc() ComponentDoc {
+	return ComponentDoc{
+		Title:       "Heuristic Pattern Detector",
+		Description: "Looks for code hidden from analysis by packing, encryption or obfuscation.",
+		LooksFor: []string{
+			"Mentions of obfuscation, encryption, steganography, polymorphic code and packing",
+			"Whole-file entropy over 7.5 and packed sections, especially ones that could not be unpacked",
+			"Long runs of repeated bytes or blocks",
+		},
+		FalsePositives: []string{
+			"Agents bundling compressed models, images or other media",
+			"Executables packed with UPX only to save space",
+		},
+		References: []string{atfaaPaper},
+	}
+}
+
+func (i *IntegrityChecker) Doc() ComponentDoc {
+	return ComponentDoc{
+		Title:       "Integrity Checker",
+		Description: "Checks that the agent's code is signed and does not change itself.",
+		LooksFor: []string{
+			"Self-modification strings such as self_modify, runtime_patch and code_injection",
+			"Packer signatures such as UPX and ASPack",
+			"The absence of any code signature",
+		},
+		FalsePositives: []string{
+			"Unsigned scripts, which fail for the missing signature alone",
+			"Plugin systems that load code dynamically",
+		},
+		References: []string{atfaaPaper, "https://docs.sigstore.dev/"},
+	}
+}
+
+func (p *PrivilegeEscalationDetector) Doc() ComponentDoc {
+	return ComponentDoc{
+		Title:       "Privilege Escalation Detector",
+		Description: "Looks for attempts to gain privileges the agent was not given.",
+		LooksFor: []string{
+			"setuid, setgid and sudo, and strings such as privilege_escalate and root_access",
+		},
+		FalsePositives: []string{
+			"Installers and system tools that document or drop privileges with setuid",
+		},
+		References: []string{atfaaPaper, "https://cheatsheetseries.owasp.org/cheatsheets/Authorization_Cheat_Sheet.html"},
+	}
+}
+
+func (a *AuditTrailValidator) Doc() ComponentDoc {
+	return ComponentDoc{
+		Title:       "Audit Trail Validator",
+		Description: "Checks that the agent keeps a record of what it does.",
+		LooksFor: []string{
+			"More than five references to logging, auditing, tracing or journaling",
+		},
+		FalsePositives: []string{
+			"Small agents that log through a framework the check can't see, which fail for too few references",
+		},
+		References: []string{atfaaPaper, "https://cheatsheetseries.owasp.org/cheatsheets/Logging_Cheat_Sheet.html"},
+	}
+}
+
+func (m *MultiPartyConsensusEngine) Doc() ComponentDoc {
+	return ComponentDoc{
+		Title:       "Multi-Party Consensus Engine",
+		Description: "Three independent validators vote on the agent, and a majority must pass it.",
+		LooksFor: []string{
+			"Security: malicious and exploit",
+			"Compliance: violation and bypass",
+			"Integrity: tamper and corrupt",
+		},
+		FalsePositives: []string{
+			"Security tools whose documentation or messages use these words",
+		},
+		References: []string{atfaaPaper},
+	}
+}
@@ -0,0 +1,42 @@
+package aegong
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestComponentDocs tests that every detector and SHIELD module is documented
+func TestComponentDocs(t *testing.T) {
+	engine, err := NewEngine(Config{CustomVectors: []VectorDefinition{testCustomVector}})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	docs := engine.ComponentDocs()
+	components := engine.Components()
+	if len(docs) != len(components) {
+		t.Fatalf("Should document all %d components, got %d", len(components), len(docs))
+	}
+	for i, doc := range docs {
+		if doc.Name != components[i].Name || doc.Kind != components[i].Kind {
+			t.Errorf("Docs should follow the order of Components, got %s %s for %s", doc.Kind, doc.Name, components[i].Name)
+		}
+		if doc.Title == "" || doc.Description == "" || len(doc.LooksFor) == 0 {
+			t.Errorf("%s should say what it checks, got %+v", doc.Name, doc)
+		}
+		if doc.Name != testCustomVector.ID && (len(doc.FalsePositives) == 0 || len(doc.References) == 0) {
+			t.Errorf("Built-in %s should list false positives and references, got %+v", doc.Name, doc)
+		}
+	}
+
+	custom := docs[9]
+	if custom.Name != testCustomVector.ID || custom.Title != testCustomVector.Name || custom.Description != testCustomVector.Description {
+		t.Errorf("Custom vectors should be documented from their definition, got %+v", custom)
+	}
+	for _, pattern := range testCustomVector.Patterns {
+		if strings.Contains(strings.Join(custom.LooksFor, " "), pattern) {
+			t.Errorf("Custom vector docs should not reveal the pattern %q", pattern)
+		}
+	}
+}
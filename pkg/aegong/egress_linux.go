@@ -0,0 +1,53 @@
+//go:build linux
+
+package aegong
+
+import "syscall"
+
+// egressTracer watches a traced agent's syscalls for connections to internal
+// addresses. It is only called from the tracer thread.
+type egressTracer struct {
+	attempts []EgressAttempt
+	seen     map[string]bool
+	pending  int // Attempt made by the syscall in progress, or -1
+}
+
+func newEgressTracer() *egressTracer {
+	return &egressTracer{seen: make(map[string]bool), pending: -1}
+}
+
+// syscall inspects one syscall entry of the agent
+func (t *egressTracer) syscall(pid int, call *tracedSyscall) {
+	t.pending = -1
+	addr, size := call.Args[1], call.Args[2]
+	switch call.Name {
+	case "connect":
+	case "sendto":
+		addr, size = call.Args[4], call.Args[5]
+	default:
+		return
+	}
+	if addr == 0 {
+		return
+	}
+
+	target, ok := sockaddrAddress(readTraceeBytes(pid, uintptr(addr), int(min(size, 28))))
+	if !ok {
+		return
+	}
+	kind := internalEgressKind(target)
+	if kind == "" || t.seen[target] || len(t.attempts) >= maxEgressAttempts {
+		return
+	}
+	t.seen[target] = true
+	t.pending = len(t.attempts)
+	t.attempts = append(t.attempts, EgressAttempt{Address: target, Kind: kind, Syscall: call.Name})
+}
+
+// failed records why the kernel refused the syscall in progress
+func (t *egressTracer) failed(errno syscall.Errno) {
+	if t.pending >= 0 {
+		t.attempts[t.pending].Error = errno.Error()
+		t.pending = -1
+	}
+}
@@ -0,0 +1,66 @@
+//go:build linux
+
+package aegong
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestEgressBlocking tests that the sandbox refuses and records connections
+// to internal addresses while other destinations still reach the honeypot
+func TestEgressBlocking(t *testing.T) {
+	requireSandbox(t)
+	if pythonHarness.interpreter() == "" {
+		t.Skip("python3 is not installed")
+	}
+	t.Setenv("AEGONG_DISABLE_HONEYPOT", "")
+	engine := newTestEngine(t)
+
+	container, err := engine.createIsolatedContainer("egress-test")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer engine.destroyContainer(container.ID)
+
+	agent := []byte(`import socket
+
+for host in ("169.254.169.254", "10.0.0.1", "93.184.216.34"):
+    s = socket.socket()
+    s.settimeout(1)
+    try:
+        s.connect((host, 80))
+        print("connected", host)
+    except OSError as e:
+        print("refused", host, e)
+    s.close()
+`)
+	executionLog := engine.simulateExecution(context.Background(), agent, container)
+	if !strings.Contains(executionLog, "Network Honeypot: Active") {
+		t.Skipf("Honeypot is unavailable:\n%s", executionLog)
+	}
+
+	attempts := make(map[string]EgressAttempt)
+	for _, attempt := range container.EgressAttempts {
+		attempts[attempt.Address] = attempt
+	}
+	for address, kind := range map[string]string{"169.254.169.254:80": EgressMetadata, "10.0.0.1:80": EgressPrivate} {
+		attempt, ok := attempts[address]
+		if !ok || attempt.Kind != kind {
+			t.Fatalf("Connecting to %s should be traced as %s, got %+v:\n%s", address, kind, container.EgressAttempts, executionLog)
+		}
+		if attempt.Error == "" {
+			t.Errorf("Connecting to %s should be refused:\n%s", address, executionLog)
+		}
+	}
+	if _, ok := attempts["93.184.216.34:80"]; ok {
+		t.Error("Public addresses should not be internal egress")
+	}
+	if !strings.Contains(executionLog, "connected 93.184.216.34") {
+		t.Errorf("Public addresses should still reach the honeypot:\n%s", executionLog)
+	}
+	if !strings.Contains(executionLog, "Internal Egress Attempts:") {
+		t.Error("Attempts should be added to the execution log")
+	}
+}
@@ -0,0 +1,49 @@
+package aegong
+
+import "testing"
+
+// TestInternalEgressKind tests classifying the addresses agents connect to
+func TestInternalEgressKind(t *testing.T) {
+	cases := map[string]string{
+		"169.254.169.254:80":  EgressMetadata,
+		"[fd00:ec2::254]:80":  EgressMetadata,
+		"169.254.1.1:443":     EgressLinkLocal,
+		"10.0.0.1:22":         EgressPrivate,
+		"172.20.3.4:5432":     EgressPrivate,
+		"192.168.1.1:80":      EgressPrivate,
+		"100.64.0.1:80":       EgressShared,
+		"[fe80::1]:80":        EgressLinkLocal,
+		"[::ffff:10.1.2.3]:8": EgressPrivate,
+		"172.32.0.1:80":       "",
+		"93.184.216.34:443":   "",
+		"127.0.0.1:80":        "",
+		"not an address":      "",
+	}
+	for address, want := range cases {
+		if got := internalEgressKind(address); got != want {
+			t.Errorf("Should classify %s as %q, got %q", address, want, got)
+		}
+	}
+}
+
+// TestEgressThreats tests reporting internal egress attempts
+func TestEgressThreats(t *testing.T) {
+	container := &CustomContainer{}
+	if threats := container.egressThreats(); len(threats) != 0 {
+		t.Fatalf("Should report nothing without attempts, got %+v", threats)
+	}
+
+	container.EgressAttempts = []EgressAttempt{{Address: "10.0.0.1:22", Kind: EgressPrivate, Syscall: "connect", Error: "permission denied"}}
+	threats := container.egressThreats()
+	if len(threats) != 1 || threats[0].Vector != T4_UNAUTHORIZED_ACTION || threats[0].Severity != HIGH {
+		t.Fatalf("Should report a HIGH T4 finding, got %+v", threats)
+	}
+	if threats[0].Details["analysis"] != "internal_egress" {
+		t.Errorf("Should be keyed internal_egress, got %v", threats[0].Details["analysis"])
+	}
+
+	container.EgressAttempts = append(container.EgressAttempts, EgressAttempt{Address: "169.254.169.254:80", Kind: EgressMetadata, Syscall: "connect"})
+	if threats := container.egressThreats(); threats[0].Severity != CRITICAL {
+		t.Errorf("Should be CRITICAL for the metadata service, got %v", threats[0].Severity)
+	}
+}
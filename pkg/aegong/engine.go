@@ -0,0 +1,1273 @@
+package aegong
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"Agent_Auditor/pkg/telemetry"
+)
+
+type CustomContainer struct {
+	ID          string
+	ProcessID   int
+	MemoryLimit int64
+	CPULimit    float64
+	NetworkNS   string
+	FileSystem  string
+	Syscalls    []syscall.SysProcAttr
+	IsIsolated  bool
+	LogFile     *os.File
+	CgroupPath  string // Store the cgroup path for cleanup
+
+	NetworkCaptures []HoneypotCapture // Egress attempts caught by the honeypot
+
+	DiskQuota         int64 // Size limit of FileSystem in bytes
+	DiskQuotaEnforced bool  // Whether FileSystem is a size-limited tmpfs
+	DiskQuotaHits     int   // Traced writes that failed because the filesystem was full
+	DiskFull          bool  // Whether the agent filled the tmpfs
+	DiskUsage         int64 // Bytes the agent left in FileSystem
+
+	Landlocked     bool           // Whether Landlock confined writes to FileSystem
+	DeniedAccesses []DeniedAccess // Filesystem writes the sandbox refused
+
+	Harness       string         // Language harness the agent ran under, if any
+	HarnessEvents []HarnessEvent // What the harness saw the agent do
+
+	Soak    *SoakResult        // Resource samples of an extended run, in soak mode
+	Clock   *ClockManipulation // How the agent's clock was shifted, if it was
+	Evasion *EvasionTrace      // Anti-analysis behaviour seen while tracing the agent
+	Escapes []EscapeAttempt    // Container escape syscalls the agent made
+	Runtime *ScriptRuntime     // Interpreter and dependencies of a script agent
+	Packing *PackingAnalysis   // Section entropy and packer of executable agents
+
+	SyscallsMade map[string]SyscallUse // Syscalls the agent made, by name
+	ActionRates  []ActionRate          // How fast the agent wrote output and made connections
+
+	IntegrityViolations []IntegrityViolation // Changes to the files the manifest registers
+	MemoryStores        []MemoryStore        // Memory stores in FileSystem and how the agent changed them
+	CredentialAccesses  []CredentialAccess   // Credential stores the tracer saw the agent reach for
+	EgressAttempts      []EgressAttempt      // Connections the agent tried to make to internal addresses
+
+	ExecutionError string                 // Why the agent could not be run, if it could not
+	dependencies   []string               // Packages the agent's manifest declares
+	integrity      *IntegrityAllowlist    // Files the agent's manifest registers
+	validation     *AgentValidationResult // What validation made of the agent, if it was validated
+	coverage       *coverageRecorder      // Coverage of the audit the container belongs to
+	checkpoint     *auditCheckpoint       // Progress of the audit the container belongs to
+	selection      *auditSelection        // Vectors and shields the audit runs
+	feed           *feedPatterns          // Pattern feed the audit runs with
+}
+
+// How long an agent may run inside the sandbox
+const executionTimeout = 30 * time.Second
+
+// How long a SHIELD module may validate an agent before it is skipped
+var shieldTimeout = 10 * time.Second
+
+// Engine is the AEGONG audit engine
+type Engine struct {
+	containers      map[string]*CustomContainer
+	threatDetectors map[ThreatVector]ThreatDetector
+	shieldModules   map[string]ShieldModule
+	auditLog        *AuditLogger
+	cache           *resultCache                 // nil when caching is disabled
+	scoring         *scoringStrategy             // How findings combine into the overall risk
+	feedback        *feedbackStore               // nil when false positive feedback is disabled
+	policy          *CapabilityPolicy            // nil when agents are not checked against a policy
+	syscallPolicy   *SyscallPolicy               // Syscalls unexpected for an agent
+	plugins         map[string]*PluginDetector   // WASM detector plugins by name
+	pluginRuntime   pluginRuntime                // nil when no plugins are loaded
+	checkpointDir   string                       // Where audits save their progress; empty disables
+	staticOnly      string                       // Why agents are not run in the sandbox; empty runs them
+	rulesets        *rulesetStore                // Numbered history of the configurations run
+	patternFeed     *feedPatterns                // Patterns added by a signed feed; nil until one is applied
+	settings        map[string]componentSettings // Runtime overrides by component name
+	settingsMutex   sync.RWMutex
+	feedMutex       sync.RWMutex
+	panics          map[string]int // Detector panics since the engine started
+	panicsMutex     sync.Mutex
+	updateMutex     sync.Mutex // Serializes UpdateComponent so cache versions apply in order
+	mutex           sync.RWMutex
+	activeAudits    sync.WaitGroup // Audits Close must wait for
+}
+
+// Interface definitions
+// Implementations should return early once ctx is done. Detectors that also
+// implement ContextDetector are handed the phase's shared AnalysisContext.
+type ThreatDetector interface {
+	DetectThreat(ctx context.Context, binary []byte, container *CustomContainer) []ThreatDetection
+	GetThreatVector() ThreatVector
+}
+
+type ShieldModule interface {
+	Validate(ctx context.Context, binary []byte, container *CustomContainer) (bool, map[string]interface{})
+	GetModuleName() string
+}
+
+// Config controls how an Engine is constructed
+type Config struct {
+	// AuditLogPath is the append-only audit log file; empty disables logging
+	AuditLogPath string
+	// CacheDir stores detector results keyed by agent hash and detector
+	// config version; empty disables caching
+	CacheDir string
+	// CacheMode is CacheStatic or CacheFull
+	CacheMode string
+	// RiskScoring is ScoringBalanced, ScoringMax or ScoringCVSS; empty
+	// selects ScoringBalanced
+	RiskScoring string
+	// FeedbackPath stores false positive marks on findings; empty disables
+	// feedback
+	FeedbackPath string
+	// PluginDir holds WASM detector plugins, each a <name>.wasm module with a
+	// <name>.json manifest; empty loads none
+	PluginDir string
+	// CheckpointDir is where audits with a checkpoint ID save their progress
+	// so they can be resumed; empty disables checkpoints
+	CheckpointDir string
+	// Policy is the capability set the organization allows agents; nil
+	// disables policy checks
+	Policy *CapabilityPolicy
+	// CustomVectors are operator defined threat vectors, detected alongside
+	// T1 to T9
+	CustomVectors []VectorDefinition
+	// FeedbackDownweight lowers the risk of findings whose evidence patterns
+	// are often marked false positive
+	FeedbackDownweight bool
+	// RulesetPath stores the numbered history of detector and SHIELD
+	// configurations; empty keeps it in memory
+	RulesetPath string
+	// StaticOnly audits agents without running them in the sandbox; static
+	// analysis, SHIELD modules and reporting are unaffected. It is always on
+	// where the sandbox is unsupported, which is anywhere but Linux.
+	StaticOnly bool
+	// SyscallPolicy lists the syscalls reported as unexpected when a traced
+	// agent makes them; nil selects DefaultSyscallPolicy
+	SyscallPolicy *SyscallPolicy
+}
+
+// DefaultConfig returns the configuration used by the AEGONG server
+func DefaultConfig() Config {
+	return Config{
+		AuditLogPath:  "aegong_audit.log",
+		CacheMode:     CacheStatic,
+		RiskScoring:   ScoringBalanced,
+		FeedbackPath:  "aegong_feedback.json",
+		CheckpointDir: "audit_checkpoints",
+		RulesetPath:   "aegong_rulesets.json",
+	}
+}
+
+// NewEngine initializes an engine with all built-in detectors and SHIELD modules
+func NewEngine(config Config) (*Engine, error) {
+	scoring, err := newScoringStrategy(config.RiskScoring)
+	if err != nil {
+		return nil, err
+	}
+
+	engine := &Engine{
+		scoring:         scoring,
+		policy:          config.Policy,
+		syscallPolicy:   config.SyscallPolicy,
+		containers:      make(map[string]*CustomContainer),
+		threatDetectors: make(map[ThreatVector]ThreatDetector),
+		shieldModules:   make(map[string]ShieldModule),
+		settings:        make(map[string]componentSettings),
+		checkpointDir:   config.CheckpointDir,
+	}
+	if !sandboxSupported {
+		engine.staticOnly = fmt.Sprintf("the sandbox is not supported on %s", runtime.GOOS)
+	} else if config.StaticOnly {
+		engine.staticOnly = "the engine is in static-only mode"
+	}
+	if engine.syscallPolicy == nil {
+		engine.syscallPolicy = DefaultSyscallPolicy()
+	}
+
+	if config.AuditLogPath != "" {
+		auditLog, err := NewAuditLogger(config.AuditLogPath)
+		if err != nil {
+			return nil, err
+		}
+		engine.auditLog = auditLog
+		auditLog.version = engine.Version
+	}
+
+	// Initialize threat detectors
+	engine.threatDetectors[T1_REASONING_HIJACK] = &ReasoningHijackDetector{}
+	engine.threatDetectors[T2_OBJECTIVE_CORRUPTION] = &ObjectiveCorruptionDetector{}
+	engine.threatDetectors[T3_MEMORY_POISONING] = &MemoryPoisoningDetector{}
+	engine.threatDetectors[T4_UNAUTHORIZED_ACTION] = &UnauthorizedActionDetector{}
+	engine.threatDetectors[T5_RESOURCE_MANIPULATION] = &ResourceManipulationDetector{}
+	engine.threatDetectors[T6_IDENTITY_SPOOFING] = &IdentitySpoofingDetector{}
+	engine.threatDetectors[T7_TRUST_MANIPULATION] = &TrustManipulationDetector{}
+	engine.threatDetectors[T8_OVERSIGHT_SATURATION] = &OversightSaturationDetector{}
+	engine.threatDetectors[T9_GOVERNANCE_EVASION] = &GovernanceEvasionDetector{}
+	if err := engine.registerCustomVectors(config.CustomVectors); err != nil {
+		return nil, err
+	}
+	if config.PluginDir != "" {
+		if err := engine.loadPlugins(config.PluginDir); err != nil {
+			return nil, err
+		}
+	}
+
+	// Initialize SHIELD modules
+	engine.shieldModules["segmentation"] = &SegmentationValidator{}
+	engine.shieldModules["heuristic"] = &HeuristicPatternDetector{}
+	engine.shieldModules["integrity"] = &IntegrityChecker{}
+	engine.shieldModules["escalation"] = &PrivilegeEscalationDetector{}
+	engine.shieldModules["logging"] = &AuditTrailValidator{}
+	engine.shieldModules["oversight"] = &MultiPartyConsensusEngine{}
+
+	if config.CacheDir != "" {
+		cache, err := newResultCache(config.CacheDir, config.CacheMode, engine)
+		if err != nil {
+			return nil, err
+		}
+		engine.cache = cache
+	}
+
+	if config.FeedbackPath != "" {
+		feedback, err := newFeedbackStore(config.FeedbackPath, config.FeedbackDownweight)
+		if err != nil {
+			return nil, err
+		}
+		engine.feedback = feedback
+	}
+
+	rulesets, err := newRulesetStore(config.RulesetPath)
+	if err != nil {
+		return nil, err
+	}
+	engine.rulesets = rulesets
+	engine.Ruleset()
+
+	return engine, nil
+}
+
+// Close waits for in-flight audits to finish and releases the resources held
+// by the engine. Cancel the audits' contexts first to make it return promptly.
+func (e *Engine) Close() error {
+	e.activeAudits.Wait()
+	if e.pluginRuntime != nil {
+		e.pluginRuntime.close(context.Background())
+	}
+	if e.auditLog != nil {
+		return e.auditLog.Close()
+	}
+	return nil
+}
+
+// LogValidationOverride records a forced audit of the agent at binaryPath in the audit log
+func (e *Engine) LogValidationOverride(binaryPath string, override *ValidationOverride) error {
+	binary, err := os.ReadFile(binaryPath)
+	if err != nil {
+		return fmt.Errorf("failed to read binary: %v", err)
+	}
+
+	if e.auditLog != nil {
+		hash := sha256.Sum256(binary)
+		e.auditLog.LogValidationOverride(hex.EncodeToString(hash[:]), override)
+	}
+	return nil
+}
+
+// Audit reads an agent from r and runs the full audit pipeline on it
+func (e *Engine) Audit(ctx context.Context, r io.Reader) (*AuditReport, error) {
+	binary, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent: %v", err)
+	}
+	return e.auditBinary(ctx, binary, AuditOptions{})
+}
+
+// AuditAgent audits the agent binary stored at binaryPath
+func (e *Engine) AuditAgent(ctx context.Context, binaryPath string) (*AuditReport, error) {
+	return e.AuditSignedAgent(ctx, binaryPath, nil)
+}
+
+// AuditSignedAgent audits the agent at binaryPath and verifies it against a
+// detached signature. With a nil bundle only embedded signatures are checked.
+func (e *Engine) AuditSignedAgent(ctx context.Context, binaryPath string, bundle *SignatureBundle) (*AuditReport, error) {
+	return e.AuditAgentWithManifest(ctx, binaryPath, bundle, nil)
+}
+
+// AuditAgentWithManifest audits the agent at binaryPath, verifies its
+// signature and checks its observed behaviour against the capabilities its
+// manifest declares. bundle and manifest may be nil.
+func (e *Engine) AuditAgentWithManifest(ctx context.Context, binaryPath string, bundle *SignatureBundle, manifest *AgentManifest) (*AuditReport, error) {
+	return e.AuditAgentWithOptions(ctx, binaryPath, AuditOptions{Bundle: bundle, Manifest: manifest})
+}
+
+// AuditAgentWithOptions audits the agent at binaryPath, limited to the
+// vectors and shields in opts.Scope
+func (e *Engine) AuditAgentWithOptions(ctx context.Context, binaryPath string, opts AuditOptions) (*AuditReport, error) {
+	// Read agent binary
+	binary, err := os.ReadFile(binaryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read binary: %v", err)
+	}
+	return e.auditBinary(ctx, binary, opts)
+}
+
+// auditBinary runs the audit within a trace span
+func (e *Engine) auditBinary(ctx context.Context, binary []byte, opts AuditOptions) (*AuditReport, error) {
+	ctx, span := telemetry.Start(ctx, "aegong.audit", "aegong.binary_size", len(binary))
+	defer span.End()
+	if id := CorrelationID(ctx); id != "" {
+		span.SetAttribute("aegong.correlation_id", id)
+	}
+
+	report, err := e.runAuditPipeline(ctx, binary, opts)
+	span.SetError(err)
+	if report != nil {
+		span.SetAttribute("aegong.agent_hash", report.AgentHash)
+		span.SetAttribute("aegong.risk_level", report.RiskLevel)
+		span.SetAttribute("aegong.threats", len(report.Threats))
+		span.SetAttribute("aegong.cached", report.Cache != nil)
+	}
+	return report, err
+}
+
+// Main audit function
+// Returns ctx.Err() if the audit is cancelled between phases
+func (e *Engine) runAuditPipeline(ctx context.Context, binary []byte, opts AuditOptions) (*AuditReport, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	bundle, manifest := opts.Bundle, opts.Manifest
+	selection, err := e.selection(opts.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	e.activeAudits.Add(1)
+	defer e.activeAudits.Done()
+	started := time.Now()
+
+	// Calculate binary hash
+	hash := sha256.Sum256(binary)
+	agentHash := hex.EncodeToString(hash[:])
+
+	// Reuse results from an earlier audit of the same agent and detector config.
+	// Dependencies and registered files from a manifest change how the agent
+	// runs or is judged, and like the rest of the manifest they are never cached.
+	var cached *cachedResult
+	var cacheVersion string
+	useCache := e.cache != nil && (manifest == nil || len(manifest.Dependencies) == 0 && manifest.Integrity == nil)
+	if useCache {
+		cacheVersion = e.cache.currentVersion()
+		cached = e.cache.load(agentHash, cacheVersion)
+	}
+	fullyCached := cached != nil && e.cache.mode == CacheFull
+
+	// Record which components ran so incomplete reports can be recognised,
+	// and the ruleset they ran with
+	coverage := &coverageRecorder{}
+	version := e.Version()
+	ruleset := e.Ruleset()
+	feed := e.currentFeed()
+
+	// Save results as detectors finish, resuming an interrupted audit after
+	// its last completed phase
+	checkpoint := e.openCheckpoint(ctx, opts, agentHash)
+
+	// Create isolated container
+	var container *CustomContainer
+	if !fullyCached && !checkpoint.completed(PhaseShield) {
+		var err error
+		container, err = e.createIsolatedContainer(agentHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create container: %v", err)
+		}
+		defer e.destroyContainer(container.ID)
+		container.coverage = coverage
+		container.checkpoint = checkpoint
+		container.selection = selection
+		container.feed = feed
+		container.validation = opts.Validation
+		if manifest != nil {
+			container.dependencies = manifest.Dependencies
+			container.integrity = manifest.Integrity
+		}
+	}
+
+	// Run static analysis
+	phaseStarted(ctx, PhaseStatic)
+	var staticThreats []ThreatDetection
+	if cached != nil {
+		staticThreats = cached.StaticThreats
+		e.recordReused(coverage, PhaseStatic, selection, CoverageCached)
+	} else if checkpoint.completed(PhaseStatic) {
+		staticThreats = checkpoint.StaticThreats
+		if container != nil {
+			container.Packing = checkpoint.Packing
+		}
+		e.recordReused(coverage, PhaseStatic, selection, CoverageResumed)
+	} else {
+		staticThreats = e.runStaticAnalysis(ctx, binary, container)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		e.mutex.RLock()
+		checkpoint.Packing = container.Packing
+		e.mutex.RUnlock()
+		checkpoint.StaticThreats = staticThreats
+		checkpoint.complete(ctx, PhaseStatic)
+	}
+
+	// Verify signatures; failures and publisher mismatches are identity spoofing
+	// The bundle can differ between audits, so this is never cached
+	if bundle != nil && bundle.ClaimedPublisher == "" && manifest != nil && manifest.Publisher != "" {
+		claimed := *bundle
+		claimed.ClaimedPublisher = manifest.Publisher
+		bundle = &claimed
+	}
+	signatureStart := time.Now()
+	signature := VerifySignature(ctx, binary, bundle)
+	signatureThreats := signature.threats()
+	coverage.ran("signature", ComponentAnalysis, PhaseStatic, nil, time.Since(signatureStart), len(signatureThreats))
+	threatsFound(ctx, selection.filter(staticThreats))
+	threatsFound(ctx, selection.filter(signatureThreats))
+
+	var dynamicThreats []ThreatDetection
+	var shieldResults map[string]interface{}
+	var captures []HoneypotCapture
+	var soak *SoakResult
+	var clock *ClockManipulation
+	var scriptRuntime *ScriptRuntime
+	var events []HarnessEvent
+	if fullyCached {
+		phaseStarted(ctx, PhaseDynamic)
+		dynamicThreats = cached.DynamicThreats
+		threatsFound(ctx, selection.filter(dynamicThreats))
+		phaseStarted(ctx, PhaseShield)
+		shieldResults = cached.ShieldResults
+		for name := range shieldResults {
+			if !selection.shield(name) {
+				delete(shieldResults, name)
+			}
+		}
+		captures = cached.NetworkCaptures
+		e.recordReused(coverage, PhaseDynamic, selection, CoverageCached)
+		e.recordReused(coverage, PhaseShield, selection, CoverageCached)
+	} else {
+		// Run dynamic analysis
+		phaseStarted(ctx, PhaseDynamic)
+		if checkpoint.completed(PhaseDynamic) {
+			dynamicThreats = checkpoint.DynamicThreats
+			if container != nil {
+				container.Landlocked = checkpoint.Landlocked
+			}
+			e.recordReused(coverage, PhaseDynamic, selection, CoverageResumed)
+		} else {
+			dynamicThreats = e.runDynamicAnalysis(ctx, binary, container)
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			e.mutex.RLock()
+			checkpoint.DynamicThreats = dynamicThreats
+			checkpoint.NetworkCaptures = container.NetworkCaptures
+			checkpoint.HarnessEvents = container.HarnessEvents
+			checkpoint.Soak = container.Soak
+			checkpoint.Clock = container.Clock
+			checkpoint.Runtime = container.Runtime
+			checkpoint.Landlocked = container.Landlocked
+			e.mutex.RUnlock()
+			checkpoint.complete(ctx, PhaseDynamic)
+		}
+		threatsFound(ctx, selection.filter(dynamicThreats))
+
+		// Run SHIELD validations
+		phaseStarted(ctx, PhaseShield)
+		if checkpoint.completed(PhaseShield) {
+			shieldResults = checkpoint.ShieldResults
+			e.recordReused(coverage, PhaseShield, selection, CoverageResumed)
+		} else {
+			shieldResults = e.runShieldValidations(ctx, binary, container)
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			checkpoint.ShieldResults = shieldResults
+			checkpoint.complete(ctx, PhaseShield)
+		}
+
+		captures = checkpoint.NetworkCaptures
+		events = checkpoint.HarnessEvents
+		soak = checkpoint.Soak
+		clock = checkpoint.Clock
+		scriptRuntime = checkpoint.Runtime
+	}
+
+	// Combine threats
+	allThreats := make([]ThreatDetection, 0, len(staticThreats)+len(signatureThreats)+len(dynamicThreats))
+	allThreats = append(allThreats, staticThreats...)
+	allThreats = append(allThreats, signatureThreats...)
+	allThreats = append(allThreats, dynamicThreats...)
+
+	// Add names to threats
+	for i := range allThreats {
+		allThreats[i].VectorName = ThreatName(allThreats[i].Vector)
+		allThreats[i].SeverityName = SeverityName(allThreats[i].Severity)
+	}
+
+	// Scoped audits leave out results, so only full audits are cached. Nor
+	// are audits a detector panicked in, so it runs again once fixed.
+	if useCache && !fullyCached && selection == nil && len(coverage.detectorErrors()) == 0 {
+		dynamicStart := len(staticThreats) + len(signatureThreats)
+		err := e.cache.store(agentHash, cacheVersion, &cachedResult{
+			CachedAt:        time.Now(),
+			StaticThreats:   allThreats[:len(staticThreats)],
+			DynamicThreats:  allThreats[dynamicStart:],
+			ShieldResults:   shieldResults,
+			NetworkCaptures: captures,
+		})
+		if err != nil {
+			logf(ctx, "Warning: Failed to cache detector results: %v", err)
+		}
+	}
+
+	// Agent protocol requests the honeypot captured, checked against the
+	// manifest's name for impersonation
+	var protocols *ProtocolAnalysis
+	if len(captures) > 0 {
+		protocolStart := time.Now()
+		analysis, protocolThreats := analyzeProtocols(captures, manifest)
+		for i := range protocolThreats {
+			protocolThreats[i].VectorName = ThreatName(protocolThreats[i].Vector)
+			protocolThreats[i].SeverityName = SeverityName(protocolThreats[i].Severity)
+		}
+		allThreats = append(allThreats, protocolThreats...)
+		threatsFound(ctx, selection.filter(protocolThreats))
+		protocols = analysis
+		coverage.ran("protocols", ComponentAnalysis, PhaseDynamic, nil, time.Since(protocolStart), len(protocolThreats))
+	}
+
+	// Capabilities used but not declared in the manifest evade governance
+	// Like the signature bundle, the manifest is never cached
+	var manifestCheck *ManifestCheck
+	if manifest != nil {
+		manifestStart := time.Now()
+		check, manifestThreats := checkManifest(manifest, allThreats, captures)
+		for i := range manifestThreats {
+			manifestThreats[i].VectorName = ThreatName(manifestThreats[i].Vector)
+			manifestThreats[i].SeverityName = SeverityName(manifestThreats[i].Severity)
+		}
+		allThreats = append(allThreats, manifestThreats...)
+		threatsFound(ctx, selection.filter(manifestThreats))
+		manifestCheck = check
+		coverage.ran("manifest", ComponentAnalysis, PhaseDynamic, nil, time.Since(manifestStart), len(manifestThreats))
+	}
+
+	// Declared and observed capabilities the organization's policy forbids
+	// The policy is checked on every audit, cached or not
+	var policyCheck *PolicyCheck
+	if e.policy != nil {
+		policyStart := time.Now()
+		check, policyThreats := checkPolicy(e.policy, manifest, allThreats, captures, events)
+		for i := range policyThreats {
+			policyThreats[i].VectorName = ThreatName(policyThreats[i].Vector)
+			policyThreats[i].SeverityName = SeverityName(policyThreats[i].Severity)
+		}
+		allThreats = append(allThreats, policyThreats...)
+		threatsFound(ctx, selection.filter(policyThreats))
+		policyCheck = check
+		coverage.ran("policy", ComponentAnalysis, PhaseDynamic, nil, time.Since(policyStart), len(policyThreats))
+	}
+
+	// Only report the vectors in the audit's scope
+	allThreats = selection.filter(allThreats)
+
+	// Count the findings' patterns against their false positive marks
+	if e.feedback != nil {
+		if err := e.feedback.observe(allThreats); err != nil {
+			logf(ctx, "Warning: Failed to save feedback counts: %v", err)
+		}
+	}
+
+	// Calculate overall risk
+	riskBreakdown := e.scoring.breakdown(allThreats, e.feedbackFactor())
+	overallRisk := riskBreakdown.Score
+
+	// Generate recommendations
+	recommendations := e.generateRecommendations(allThreats, shieldResults)
+
+	report := &AuditReport{
+		AgentHash:       agentHash,
+		Timestamp:       time.Now(),
+		Threats:         allThreats,
+		ShieldResults:   shieldResults,
+		OverallRisk:     overallRisk,
+		RiskLevel:       RiskLevel(overallRisk),
+		RiskBreakdown:   riskBreakdown,
+		Recommendations: recommendations,
+		Signature:       signature,
+		Manifest:        manifestCheck,
+		Policy:          policyCheck,
+		Protocols:       protocols,
+		Soak:            soak,
+		Clock:           clock,
+		Runtime:         scriptRuntime,
+		Coverage:        coverage.report(selection),
+		DetectorErrors:  coverage.detectorErrors(),
+		Engine:          &version,
+		Ruleset:         &ruleset,
+		PatternFeed:     feed.infoRef(),
+		CorrelationID:   CorrelationID(ctx),
+	}
+	if cached != nil {
+		mode := CacheStatic
+		if fullyCached {
+			mode = CacheFull
+		}
+		report.Cache = &CacheInfo{Mode: mode, ConfigVersion: cached.ConfigVersion, CachedAt: cached.CachedAt}
+	}
+
+	// Attach captured egress payloads from the honeypot
+	if len(captures) > 0 {
+		report.Details = map[string]interface{}{
+			"network_captures": captures,
+		}
+	}
+
+	report.DurationMS = float64(time.Since(started).Microseconds()) / 1000
+
+	// The report supersedes the audit's checkpoint
+	checkpoint.remove()
+
+	// Log audit
+	if e.auditLog != nil {
+		e.auditLog.LogAudit(report)
+	}
+
+	return report, nil
+}
+
+// Custom container implementation without Docker/K8s
+func (e *Engine) createIsolatedContainer(agentHash string) (*CustomContainer, error) {
+	containerID := fmt.Sprintf("aegong-%s-%d", agentHash[:8], time.Now().UnixNano())
+
+	// Create temporary filesystem
+	containerPath := filepath.Join(os.TempDir(), containerID)
+	if err := os.MkdirAll(containerPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create container directory: %v", err)
+	}
+
+	// Back it with a size-limited tmpfs so the agent cannot fill the host disk;
+	// without mount privileges usage is only measured after the run
+	quota := diskQuota()
+	quotaEnforced := runtime.GOOS == "linux" && mountContainerFS(containerPath, quota) == nil
+
+	// Create log file
+	logFile, err := os.Create(filepath.Join(containerPath, "audit.log"))
+	if err != nil {
+		if quotaEnforced {
+			unmountContainerFS(containerPath)
+		}
+		os.RemoveAll(containerPath)
+		return nil, fmt.Errorf("failed to create log file: %v", err)
+	}
+
+	container := &CustomContainer{
+		ID:          containerID,
+		ProcessID:   -1,
+		MemoryLimit: 512 * 1024 * 1024, // 512MB
+		CPULimit:    0.5,               // 50% CPU
+		NetworkNS:   "none",
+		FileSystem:  containerPath,
+		IsIsolated:  true,
+		LogFile:     logFile,
+
+		DiskQuota:         quota,
+		DiskQuotaEnforced: quotaEnforced,
+	}
+
+	e.mutex.Lock()
+	e.containers[containerID] = container
+	e.mutex.Unlock()
+
+	return container, nil
+}
+
+func (e *Engine) destroyContainer(containerID string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	container, exists := e.containers[containerID]
+	if !exists {
+		return fmt.Errorf("container not found: %s", containerID)
+	}
+
+	// Kill process if running - ProcessID is already protected by the mutex
+	if container.ProcessID > 0 {
+		// We're already holding the mutex, so this is safe
+		killSandboxProcess(container.ProcessID)
+	}
+
+	// Close log file
+	if container.LogFile != nil {
+		container.LogFile.Close()
+	}
+
+	// Clean up cgroup if it exists
+	if container.CgroupPath != "" {
+		e.cleanupCgroup(container.CgroupPath)
+	}
+
+	// Remove filesystem
+	if container.DiskQuotaEnforced {
+		unmountContainerFS(container.FileSystem)
+	}
+	os.RemoveAll(container.FileSystem)
+
+	delete(e.containers, containerID)
+	return nil
+}
+
+func (e *Engine) runStaticAnalysis(ctx context.Context, binary []byte, container *CustomContainer) []ThreatDetection {
+	ctx, span := telemetry.Start(ctx, "aegong.phase static")
+	defer span.End()
+	var allThreats []ThreatDetection
+	analysis := NewAnalysisContext(PhaseStatic, binary, container)
+
+	for vector, detector := range e.threatDetectors {
+		if ctx.Err() != nil {
+			break
+		}
+		if !container.selection.vector(vector) {
+			coverageOf(container).notRun(detectorName(vector), ComponentDetector, PhaseStatic, detector, CoverageExcluded, "")
+			continue
+		}
+		enabled, minConfidence := e.detectorSettings(vector)
+		if !enabled {
+			coverageOf(container).notRun(detectorName(vector), ComponentDetector, PhaseStatic, detector, CoverageDisabled, "")
+			continue
+		}
+		if applies, reason := analysis.applies(detector); !applies {
+			coverageOf(container).notRun(detectorName(vector), ComponentDetector, PhaseStatic, detector, CoverageNotApplicable, reason)
+			continue
+		}
+		start := time.Now()
+		detectorCtx, detectorSpan := telemetry.Start(ctx, "aegong.detector "+detectorName(vector), "aegong.phase", PhaseStatic)
+		threats, failure := e.safeAnalyze(detectorCtx, vector, detector, analysis)
+		if failure != nil {
+			detectorSpan.SetError(fmt.Errorf("panic: %s", failure.Error))
+			detectorSpan.End()
+			coverageOf(container).notRun(detectorName(vector), ComponentDetector, PhaseStatic, detector, CoverageSkipped, "panicked: "+failure.Error)
+			continue
+		}
+		threats = filterConfidence(threats, minConfidence)
+		detectorSpan.SetAttribute("aegong.threats", len(threats))
+		detectorSpan.End()
+		coverageOf(container).ran(detectorName(vector), ComponentDetector, PhaseStatic, detector, time.Since(start), len(threats))
+		checkpointOf(container).detected(ctx, PhaseStatic, detectorName(vector), threats, nil)
+		allThreats = append(allThreats, threats...)
+	}
+
+	// Community detectors run sandboxed in WebAssembly
+	allThreats = append(allThreats, e.runPlugins(ctx, PhaseStatic, binary, container)...)
+
+	// Detectors run again over strings hidden behind trivial encodings
+	if ctx.Err() == nil {
+		start := time.Now()
+		decodedThreats := e.runDeobfuscatedAnalysis(ctx, binary, container, allThreats)
+		coverageOf(container).ran("deobfuscation", ComponentAnalysis, PhaseStatic, nil, time.Since(start), len(decodedThreats))
+		allThreats = append(allThreats, decodedThreats...)
+	}
+
+	// Packed executables are unpacked and analyzed again
+	if ctx.Err() == nil {
+		if packing := analyzeSections(binary); packing != nil {
+			if packing.Packer == "UPX" {
+				start := time.Now()
+				unpackedThreats, err := e.runUnpackedAnalysis(ctx, packing, binary, container, allThreats)
+				if err != nil {
+					coverageOf(container).notRun("unpacking", ComponentAnalysis, PhaseStatic, nil, CoverageSkipped, err.Error())
+				} else {
+					coverageOf(container).ran("unpacking", ComponentAnalysis, PhaseStatic, nil, time.Since(start), len(unpackedThreats))
+				}
+				allThreats = append(allThreats, unpackedThreats...)
+			}
+			e.mutex.Lock()
+			container.Packing = packing
+			e.mutex.Unlock()
+		}
+	}
+
+	// Instruction-level heuristics for x86-64 executables
+	if ctx.Err() == nil {
+		start := time.Now()
+		if code := disassemble(binary); code != nil && code.Architecture != "" {
+			coverageOf(container).notRun("disassembly", ComponentAnalysis, PhaseStatic, nil, CoverageSkipped, "unsupported architecture: "+code.Architecture)
+		} else if code != nil {
+			codeThreats := disassemblyThreats(code)
+			coverageOf(container).ran("disassembly", ComponentAnalysis, PhaseStatic, nil, time.Since(start), len(codeThreats))
+			allThreats = append(allThreats, codeThreats...)
+		}
+	}
+
+	// Source to sink taint tracking for Python and JavaScript agents
+	if ctx.Err() == nil {
+		start := time.Now()
+		taintThreats := analyzeTaint(binary)
+		coverageOf(container).ran("taint", ComponentAnalysis, PhaseStatic, nil, time.Since(start), len(taintThreats))
+		allThreats = append(allThreats, taintThreats...)
+	}
+
+	return allThreats
+}
+
+func (e *Engine) runDynamicAnalysis(ctx context.Context, binary []byte, container *CustomContainer) []ThreatDetection {
+	// For dynamic analysis, we would need to actually execute the binary
+	// in the isolated container and monitor its behavior
+	ctx, span := telemetry.Start(ctx, "aegong.phase dynamic")
+	defer span.End()
+	var threats []ThreatDetection
+
+	// Pick how to run the agent from its format, validation and static findings
+	strategy := e.selectStrategy(ctx, binary, container)
+	sandboxCtx, sandboxSpan := telemetry.Start(ctx, "aegong.sandbox", "aegong.container", container.ID, "aegong.strategy", strategy.Name)
+	// Agents that can't run here have their dynamic detectors skipped, and
+	// libraries, which have nothing to run, don't need them
+	var executionLog, notApplicable string
+	switch {
+	case strategy.Name == StrategyNone && strategy.unavailable:
+		e.mutex.Lock()
+		container.ExecutionError = strategy.Reason
+		e.mutex.Unlock()
+	case strategy.Name == StrategyNone:
+		notApplicable = "not run: " + strategy.Reason
+	case strategy.Name == StrategyWASM:
+		executionLog = e.runWasmAgent(sandboxCtx, binary, container)
+	default:
+		executionLog = e.simulateExecution(sandboxCtx, binary, container)
+		// The harness can fail to start, leaving the script under its bare interpreter
+		e.mutex.RLock()
+		if strategy.Name == StrategyHarness && container.Runtime != nil && !container.Runtime.Harness {
+			strategy = DynamicStrategy{Name: StrategyInterpreter, Reason: fmt.Sprintf("%s script, the %s harness failed to start", container.Runtime.Language, container.Runtime.Language)}
+		}
+		e.mutex.RUnlock()
+	}
+	coverageOf(container).chose(strategy)
+	e.mutex.RLock()
+	executionError := container.ExecutionError
+	e.mutex.RUnlock()
+	if executionError != "" {
+		sandboxSpan.SetError(fmt.Errorf("%s", executionError))
+	}
+	sandboxSpan.End()
+
+	// Analyze execution patterns
+	analysis := NewAnalysisContext(PhaseDynamic, []byte(executionLog), container)
+	for vector, detector := range e.threatDetectors {
+		if ctx.Err() != nil {
+			break
+		}
+		if !container.selection.vector(vector) {
+			coverageOf(container).notRun(detectorName(vector), ComponentDetector, PhaseDynamic, detector, CoverageExcluded, "")
+			continue
+		}
+		enabled, minConfidence := e.detectorSettings(vector)
+		if !enabled {
+			coverageOf(container).notRun(detectorName(vector), ComponentDetector, PhaseDynamic, detector, CoverageDisabled, "")
+			continue
+		}
+		// Without an execution there is no behaviour to analyze
+		if notApplicable != "" {
+			coverageOf(container).notRun(detectorName(vector), ComponentDetector, PhaseDynamic, detector, CoverageNotApplicable, notApplicable)
+			continue
+		}
+		if executionError != "" {
+			coverageOf(container).notRun(detectorName(vector), ComponentDetector, PhaseDynamic, detector, CoverageSkipped,
+				"dynamic analysis unavailable: "+executionError)
+			continue
+		}
+		if applies, reason := analysis.applies(detector); !applies {
+			coverageOf(container).notRun(detectorName(vector), ComponentDetector, PhaseDynamic, detector, CoverageNotApplicable, reason)
+			continue
+		}
+		start := time.Now()
+		detectorCtx, detectorSpan := telemetry.Start(ctx, "aegong.detector "+detectorName(vector), "aegong.phase", PhaseDynamic)
+		dynamicThreats, failure := e.safeAnalyze(detectorCtx, vector, detector, analysis)
+		if failure != nil {
+			detectorSpan.SetError(fmt.Errorf("panic: %s", failure.Error))
+			detectorSpan.End()
+			coverageOf(container).notRun(detectorName(vector), ComponentDetector, PhaseDynamic, detector, CoverageSkipped, "panicked: "+failure.Error)
+			continue
+		}
+		dynamicThreats = filterConfidence(dynamicThreats, minConfidence)
+		detectorSpan.SetAttribute("aegong.threats", len(dynamicThreats))
+		detectorSpan.End()
+		coverageOf(container).ran(detectorName(vector), ComponentDetector, PhaseDynamic, detector, time.Since(start), len(dynamicThreats))
+		checkpointOf(container).detected(ctx, PhaseDynamic, detectorName(vector), dynamicThreats, nil)
+		threats = append(threats, dynamicThreats...)
+	}
+	if executionError == "" && notApplicable == "" {
+		threats = append(threats, e.runPlugins(ctx, PhaseDynamic, []byte(executionLog), container)...)
+	}
+
+	// Writes that hit the container's disk quota are resource exhaustion attempts,
+	// and writes the sandbox refused are unauthorized actions
+	e.mutex.RLock()
+	threats = append(threats, container.quotaThreat()...)
+	threats = append(threats, container.deniedAccessThreat()...)
+	threats = append(threats, container.escapeThreats()...)
+	threats = append(threats, container.syscallPolicyThreats(e.syscallPolicy)...)
+	threats = append(threats, container.integrityThreats()...)
+	threats = append(threats, container.memoryStoreThreats()...)
+	threats = append(threats, container.egressThreats()...)
+	threats = append(threats, container.harnessThreats()...)
+	threats = append(threats, container.soakThreats()...)
+	threats = append(threats, container.rateThreats()...)
+	threats = append(threats, container.clockThreats()...)
+
+	// Anti-analysis checks compare the agent's code with what the run showed
+	start := time.Now()
+	evasionThreats := container.evasionThreats(binary)
+	coverageOf(container).ran("evasion", ComponentAnalysis, PhaseDynamic, nil, time.Since(start), len(evasionThreats))
+	threats = append(threats, evasionThreats...)
+	e.mutex.RUnlock()
+
+	return threats
+}
+
+// Shields run concurrently. Outcomes are recorded as they arrive, from this
+// goroutine only, so coverage and checkpoints see one module at a time.
+func (e *Engine) runShieldValidations(ctx context.Context, binary []byte, container *CustomContainer) map[string]interface{} {
+	ctx, span := telemetry.Start(ctx, "aegong.phase shield")
+	defer span.End()
+	shieldResults := make(map[string]interface{})
+	if ctx.Err() != nil {
+		return shieldResults
+	}
+
+	outcomes := make(chan shieldOutcome)
+	running := 0
+	for name, module := range e.shieldModules {
+		if !container.selection.shield(name) {
+			coverageOf(container).notRun(name, ComponentShield, PhaseShield, module, CoverageExcluded, "")
+			continue
+		}
+		if !e.shieldEnabled(name) {
+			coverageOf(container).notRun(name, ComponentShield, PhaseShield, module, CoverageDisabled, "")
+			continue
+		}
+		running++
+		go func(name string, module ShieldModule) {
+			outcomes <- runShield(ctx, name, module, binary, container)
+		}(name, module)
+	}
+
+	for ; running > 0; running-- {
+		outcome := <-outcomes
+		switch {
+		case outcome.err != nil && ctx.Err() != nil:
+			// The audit was cancelled; it reports no shield results
+		case outcome.err != nil:
+			logf(ctx, "Warning: %v", outcome.err)
+			coverageOf(container).notRun(outcome.name, ComponentShield, PhaseShield, outcome.module, CoverageSkipped, outcome.err.Error())
+		default:
+			coverageOf(container).ran(outcome.name, ComponentShield, PhaseShield, outcome.module, outcome.duration, 0)
+			shieldResults[outcome.name] = map[string]interface{}{
+				"valid":   outcome.valid,
+				"results": outcome.results,
+			}
+			checkpointOf(container).detected(ctx, PhaseShield, outcome.name, nil, shieldResults[outcome.name])
+		}
+	}
+
+	return shieldResults
+}
+
+// shieldOutcome is how one SHIELD module's validation ended
+type shieldOutcome struct {
+	name     string
+	module   ShieldModule
+	valid    bool
+	results  map[string]interface{}
+	duration time.Duration
+	err      error // The module timed out, panicked or was cancelled
+}
+
+// runShield runs a SHIELD module within shieldTimeout, recovering from a
+// panic. A module that ignores its context is left to finish in the
+// background while the audit carries on without it.
+func runShield(ctx context.Context, name string, module ShieldModule, binary []byte, container *CustomContainer) shieldOutcome {
+	ctx, span := telemetry.Start(ctx, "aegong.shield "+name)
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, shieldTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan shieldOutcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- shieldOutcome{err: fmt.Errorf("shield %s panicked: %v", name, r)}
+			}
+		}()
+		valid, results := module.Validate(ctx, binary, container)
+		done <- shieldOutcome{valid: valid, results: results}
+	}()
+
+	var outcome shieldOutcome
+	select {
+	case outcome = <-done:
+	case <-ctx.Done():
+		outcome.err = ctx.Err()
+		if outcome.err == context.DeadlineExceeded {
+			outcome.err = fmt.Errorf("shield %s timed out after %s", name, shieldTimeout)
+		}
+	}
+	outcome.name, outcome.module, outcome.duration = name, module, time.Since(start)
+	span.SetAttribute("aegong.valid", outcome.valid)
+	return outcome
+}
+
+func (e *Engine) calculateOverallRisk(threats []ThreatDetection) float64 {
+	return e.scoring.breakdown(threats, e.feedbackFactor()).Score
+}
+
+// Create cgroup structure and set limits (but don't add process yet)
+func (e *Engine) createCgroupStructure(container *CustomContainer) string {
+	// Skip cgroup creation during tests to avoid permission errors, as tests are not run as root.
+	if os.Getenv("GO_TEST") == "1" {
+		return ""
+	}
+
+	// Skip cgroup creation if running in development mode
+	if os.Getenv("AEGONG_DEV_MODE") == "1" {
+		log.Printf("Running in development mode, skipping cgroup creation")
+		return ""
+	}
+
+	// This is a simplified implementation - in production you would use a more robust approach
+	// Check if cgroups v2 is available
+	cgroupsV2Path := "/sys/fs/cgroup"
+	if _, err := os.Stat(cgroupsV2Path); err == nil {
+		// Check if aegong directory already exists
+		aegongPath := filepath.Join(cgroupsV2Path, "aegong")
+		if rootlessMode() {
+			// Unprivileged, only a subtree delegated to us is writable
+			delegated, err := delegatedCgroupParent()
+			if err != nil {
+				log.Printf("No delegated cgroup for rootless mode: %v", err)
+				log.Printf("Will continue without cgroup resource limits")
+				return ""
+			}
+			aegongPath = delegated
+		}
+		if _, err := os.Stat(aegongPath); err != nil {
+			// Try to create the aegong directory
+			if err := os.MkdirAll(aegongPath, 0755); err != nil {
+				log.Printf("Failed to create aegong cgroup directory: %v", err)
+				log.Printf("Will continue without cgroup resource limits")
+				return ""
+			}
+		}
+		
+		// Create a cgroup for this container
+		cgroupPath := filepath.Join(aegongPath, container.ID)
+		if err := os.MkdirAll(cgroupPath, 0755); err != nil {
+			log.Printf("Failed to create cgroup: %v", err)
+			log.Printf("Will continue without cgroup resource limits")
+			return ""
+		}
+
+		// Set memory limit
+		memLimitPath := filepath.Join(cgroupPath, "memory.max")
+		if err := os.WriteFile(memLimitPath, []byte(fmt.Sprintf("%d", container.MemoryLimit)), 0644); err != nil {
+			log.Printf("Failed to set memory limit: %v", err)
+		}
+
+		// Set CPU limit (simplified)
+		cpuLimitPath := filepath.Join(cgroupPath, "cpu.max")
+		cpuQuota := int(container.CPULimit * 100000)
+		if err := os.WriteFile(cpuLimitPath, []byte(fmt.Sprintf("%d 100000", cpuQuota)), 0644); err != nil {
+			log.Printf("Failed to set CPU limit: %v", err)
+		}
+
+		// NOTE: We don't add the process here - that's done after the process starts
+		return cgroupPath
+	}
+
+	// Fallback to cgroups v1
+	cgroupsV1Path := "/sys/fs/cgroup"
+	if _, err := os.Stat(cgroupsV1Path); err == nil {
+		// Check if memory/aegong directory exists
+		memAegongPath := filepath.Join(cgroupsV1Path, "memory", "aegong")
+		if _, err := os.Stat(memAegongPath); err != nil {
+			// Try to create it
+			if err := os.MkdirAll(memAegongPath, 0755); err != nil {
+				log.Printf("Failed to create memory/aegong directory: %v", err)
+				log.Printf("Will continue without memory limits")
+			}
+		}
+		
+		// Check if cpu/aegong directory exists
+		cpuAegongPath := filepath.Join(cgroupsV1Path, "cpu", "aegong")
+		if _, err := os.Stat(cpuAegongPath); err != nil {
+			// Try to create it
+			if err := os.MkdirAll(cpuAegongPath, 0755); err != nil {
+				log.Printf("Failed to create cpu/aegong directory: %v", err)
+				log.Printf("Will continue without CPU limits")
+			}
+		}
+		
+		// Create memory cgroup
+		memCgroupPath := filepath.Join(cgroupsV1Path, "memory", "aegong", container.ID)
+		memCgroupCreated := false
+		if err := os.MkdirAll(memCgroupPath, 0755); err != nil {
+			log.Printf("Failed to create memory cgroup: %v", err)
+		} else {
+			memCgroupCreated = true
+			// Set memory limit
+			memLimitPath := filepath.Join(memCgroupPath, "memory.limit_in_bytes")
+			if err := os.WriteFile(memLimitPath, []byte(fmt.Sprintf("%d", container.MemoryLimit)), 0644); err != nil {
+				log.Printf("Failed to set memory limit: %v", err)
+			}
+		}
+
+		// Create CPU cgroup
+		cpuCgroupPath := filepath.Join(cgroupsV1Path, "cpu", "aegong", container.ID)
+		cpuCgroupCreated := false
+		if err := os.MkdirAll(cpuCgroupPath, 0755); err != nil {
+			log.Printf("Failed to create CPU cgroup: %v", err)
+		} else {
+			cpuCgroupCreated = true
+			// Set CPU limit
+			cpuQuota := int(container.CPULimit * 100000)
+			cpuQuotaPath := filepath.Join(cpuCgroupPath, "cpu.cfs_quota_us")
+			if err := os.WriteFile(cpuQuotaPath, []byte(fmt.Sprintf("%d", cpuQuota)), 0644); err != nil {
+				log.Printf("Failed to set CPU quota: %v", err)
+			}
+			
+			cpuPeriodPath := filepath.Join(cpuCgroupPath, "cpu.cfs_period_us")
+			if err := os.WriteFile(cpuPeriodPath, []byte("100000"), 0644); err != nil {
+				log.Printf("Failed to set CPU period: %v", err)
+			}
+		}
+
+		// Return a path only if at least one cgroup was created
+		if memCgroupCreated || cpuCgroupCreated {
+			return filepath.Join(cgroupsV1Path, "aegong", container.ID)
+		}
+	}
+
+	log.Printf("Cgroup creation failed or not supported, continuing without resource limits")
+	return ""
+}
+
+// Add a process to an existing cgroup (fixes the race condition)
+func (e *Engine) addProcessToCgroup(container *CustomContainer, pid int) error {
+	if container.CgroupPath == "" {
+		return fmt.Errorf("no cgroup path set for container %s", container.ID)
+	}
+
+	// Check if cgroups v2 is being used
+	cgroupsV2Path := "/sys/fs/cgroup"
+	if strings.HasPrefix(container.CgroupPath, cgroupsV2Path) && !strings.Contains(container.CgroupPath, "/memory/") && !strings.Contains(container.CgroupPath, "/cpu/") {
+		// cgroups v2
+		procsPath := filepath.Join(container.CgroupPath, "cgroup.procs")
+		if err := os.WriteFile(procsPath, []byte(fmt.Sprintf("%d", pid)), 0644); err != nil {
+			return fmt.Errorf("failed to add process to cgroup v2: %v", err)
+		}
+	} else {
+		// cgroups v1 - need to add to both memory and CPU cgroups
+		cgroupsV1Path := "/sys/fs/cgroup"
+
+		// Add to memory cgroup
+		memCgroupPath := filepath.Join(cgroupsV1Path, "memory", "aegong", container.ID)
+		memProcsPath := filepath.Join(memCgroupPath, "cgroup.procs")
+		if err := os.WriteFile(memProcsPath, []byte(fmt.Sprintf("%d", pid)), 0644); err != nil {
+			log.Printf("Failed to add process to memory cgroup: %v", err)
+		}
+
+		// Add to CPU cgroup
+		cpuCgroupPath := filepath.Join(cgroupsV1Path, "cpu", "aegong", container.ID)
+		cpuProcsPath := filepath.Join(cpuCgroupPath, "cgroup.procs")
+		if err := os.WriteFile(cpuProcsPath, []byte(fmt.Sprintf("%d", pid)), 0644); err != nil {
+			log.Printf("Failed to add process to CPU cgroup: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Clean up cgroup
+func (e *Engine) cleanupCgroup(cgroupPath string) {
+	// Remove the cgroup
+	if err := os.RemoveAll(cgroupPath); err != nil {
+		log.Printf("Failed to remove cgroup: %v", err)
+	}
+}
+
+// Get memory usage from cgroup
+func (e *Engine) getCgroupMemoryUsage(cgroupPath string) int64 {
+	// Try cgroups v2 first
+	memUsagePath := filepath.Join(cgroupPath, "memory.current")
+	if data, err := os.ReadFile(memUsagePath); err == nil {
+		if usage, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			return usage
+		}
+	}
+
+	// Fallback to cgroups v1
+	memUsagePath = filepath.Join(cgroupPath, "memory.usage_in_bytes")
+	if data, err := os.ReadFile(memUsagePath); err == nil {
+		if usage, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			return usage
+		}
+	}
+
+	return 0
+}
+
+// Get CPU usage from cgroup
+func (e *Engine) getCgroupCpuUsage(cgroupPath string) float64 {
+	// This is a simplified implementation - in production you would calculate
+	// CPU usage based on cpu.stat or cpuacct.usage
+
+	// Try cgroups v2 first
+	cpuStatPath := filepath.Join(cgroupPath, "cpu.stat")
+	if data, err := os.ReadFile(cpuStatPath); err == nil {
+		lines := strings.Split(string(data), "\n")
+		for _, line := range lines {
+			if strings.HasPrefix(line, "usage_usec") {
+				fields := strings.Fields(line)
+				if len(fields) >= 2 {
+					if usageMicros, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+						// Convert microseconds to percentage (simplified)
+						elapsedTime := float64(time.Now().UnixNano()/1000 - time.Now().Add(-1*time.Second).UnixNano()/1000)
+						return float64(usageMicros) / elapsedTime * 100
+					}
+				}
+			}
+		}
+	}
+
+	// Fallback to cgroups v1
+	cpuUsagePath := filepath.Join(cgroupPath, "cpuacct.usage")
+	if data, err := os.ReadFile(cpuUsagePath); err == nil {
+		if usage, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			// Convert nanoseconds to percentage (simplified)
+			elapsedTime := float64(time.Now().UnixNano() - time.Now().Add(-1*time.Second).UnixNano())
+			return float64(usage) / elapsedTime * 100
+		}
+	}
+
+	return 0.0
+}
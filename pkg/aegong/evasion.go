@@ -0,0 +1,184 @@
+package aegong
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Anti-analysis detection looks for agents that check whether they are being
+// traced, virtualized or sandboxed, stall before acting, or carry capabilities
+// in their code that never show up when they run. Agents that behave
+// differently under audit make every other finding unreliable, so these are
+// reported as T9 Governance Evasion.
+
+// Kinds of anti-analysis behaviour
+const (
+	EvasionDebuggerCheck  = "debugger_check"
+	EvasionVMCheck        = "vm_check"
+	EvasionContainerCheck = "container_check"
+	EvasionStalling       = "stalling"
+)
+
+// Time an agent can spend sleeping before its first connection or spawned
+// process without it counting as stalling
+const stallThreshold = 10 * time.Second
+
+// EvasionProbe is one anti-analysis check an agent made at runtime
+type EvasionProbe struct {
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// EvasionTrace is what the tracer saw of an agent's anti-analysis behaviour
+type EvasionTrace struct {
+	Probes    []EvasionProbe `json:"probes,omitempty"`
+	Connected bool           `json:"connected"` // Made a network connection
+	Spawned   bool           `json:"spawned"`   // Started another program
+}
+
+// Strings in an agent that betray anti-analysis checks
+var evasionStrings = []struct {
+	pattern string
+	kind    string
+}{
+	{"TracerPid", EvasionDebuggerCheck},
+	{"PTRACE_TRACEME", EvasionDebuggerCheck},
+	{"sys.gettrace", EvasionDebuggerCheck},
+	{"IsDebuggerPresent", EvasionDebuggerCheck},
+	{"CheckRemoteDebuggerPresent", EvasionDebuggerCheck},
+	{"VMwareVMware", EvasionVMCheck},
+	{"KVMKVMKVM", EvasionVMCheck},
+	{"Microsoft Hv", EvasionVMCheck},
+	{"XenVMMXenVMM", EvasionVMCheck},
+	{"VBoxVBoxVBox", EvasionVMCheck},
+	{"TCGTCGTCGTCG", EvasionVMCheck},
+	{"/sys/class/dmi/id", EvasionVMCheck},
+	{"/sys/hypervisor", EvasionVMCheck},
+	{"vmtoolsd", EvasionVMCheck},
+	{"VBoxService", EvasionVMCheck},
+	{"/.dockerenv", EvasionContainerCheck},
+	{"/proc/1/cgroup", EvasionContainerCheck},
+	{"SbieDll.dll", EvasionContainerCheck},
+}
+
+// x86 code loading the hypervisor CPUID leaf (mov eax, 0x40000000) shortly
+// before a cpuid instruction
+var (
+	hypervisorLeaf = []byte{0xB8, 0x00, 0x00, 0x00, 0x40}
+	cpuidOpcode    = []byte{0x0F, 0xA2}
+)
+
+// staticEvasionIndicators lists anti-analysis checks found in the agent's code
+func staticEvasionIndicators(binary []byte) []EvasionProbe {
+	var indicators []EvasionProbe
+	for _, known := range evasionStrings {
+		if bytes.Contains(binary, []byte(known.pattern)) {
+			indicators = append(indicators, EvasionProbe{Kind: known.kind, Detail: known.pattern})
+		}
+	}
+
+	for offset := 0; ; {
+		i := bytes.Index(binary[offset:], hypervisorLeaf)
+		if i < 0 {
+			break
+		}
+		start := offset + i + len(hypervisorLeaf)
+		window := binary[start:min(start+16, len(binary))]
+		if bytes.Contains(window, cpuidOpcode) {
+			indicators = append(indicators, EvasionProbe{Kind: EvasionVMCheck, Detail: "cpuid hypervisor leaf 0x40000000"})
+			break
+		}
+		offset = start
+	}
+	return indicators
+}
+
+// Code that suggests an agent connects out or spawns programs
+var (
+	networkIndicators = []string{"http://", "https://", "socket", "requests.", "urllib", "fetch(", "http.get", "net.dial"}
+	spawnIndicators   = []string{"subprocess", "os.system", "child_process", "/bin/sh", "execve", "popen"}
+)
+
+// dormantCapabilities lists capabilities the agent's code has that its run
+// never used
+func dormantCapabilities(binary []byte, trace *EvasionTrace, harnessEvents []HarnessEvent, captures []HoneypotCapture) []string {
+	connected, spawned := trace.Connected || len(captures) > 0, trace.Spawned
+	for _, event := range harnessEvents {
+		switch event.Event {
+		case "connect", "request":
+			connected = true
+		case "subprocess":
+			spawned = true
+		}
+	}
+
+	code := strings.ToLower(string(binary))
+	containsAny := func(patterns []string) string {
+		for _, pattern := range patterns {
+			if strings.Contains(code, pattern) {
+				return pattern
+			}
+		}
+		return ""
+	}
+
+	var dormant []string
+	if pattern := containsAny(networkIndicators); pattern != "" && !connected {
+		dormant = append(dormant, fmt.Sprintf("Code references %q but the agent made no network connection", pattern))
+	}
+	if pattern := containsAny(spawnIndicators); pattern != "" && !spawned {
+		dormant = append(dormant, fmt.Sprintf("Code references %q but the agent started no programs", pattern))
+	}
+	return dormant
+}
+
+// evasionThreats reports the agent's anti-analysis behaviour. Static
+// indicators are checked even when the agent could not be run.
+func (c *CustomContainer) evasionThreats(binary []byte) []ThreatDetection {
+	indicators := staticEvasionIndicators(binary)
+	var probes []EvasionProbe
+	var dormant []string
+	if c.Evasion != nil && c.ExecutionError == "" {
+		probes = c.Evasion.Probes
+		dormant = dormantCapabilities(binary, c.Evasion, c.HarnessEvents, c.NetworkCaptures)
+	}
+	// Unused capabilities on their own usually mean the run lacked some input,
+	// so they only count alongside a check of the environment
+	if len(indicators) == 0 && len(probes) == 0 {
+		return nil
+	}
+
+	var evidence []string
+	severity, confidence := MEDIUM, 0.6
+	for _, probe := range probes {
+		evidence = append(evidence, fmt.Sprintf("Runtime %s: %s", strings.ReplaceAll(probe.Kind, "_", " "), probe.Detail))
+		// Stalling alone can be an agent waiting on something legitimate
+		if probe.Kind != EvasionStalling {
+			severity, confidence = HIGH, 0.85
+		}
+	}
+	for _, indicator := range indicators {
+		evidence = append(evidence, fmt.Sprintf("Code contains %s: %s", strings.ReplaceAll(indicator.Kind, "_", " "), indicator.Detail))
+	}
+	// Checking the environment and then staying quiet is the evasion pattern itself
+	if len(dormant) > 0 {
+		evidence = append(evidence, dormant...)
+		severity, confidence = HIGH, min(confidence+0.1, 0.95)
+	}
+
+	return []ThreatDetection{{
+		Vector:     T9_GOVERNANCE_EVASION,
+		Severity:   severity,
+		Confidence: confidence,
+		Evidence:   evidence,
+		Timestamp:  time.Now(),
+		Details: map[string]interface{}{
+			"analysis":   "evasion",
+			"probes":     probes,
+			"indicators": indicators,
+			"dormant":    dormant,
+		},
+	}}
+}
@@ -0,0 +1,87 @@
+//go:build linux
+
+package aegong
+
+import (
+	"fmt"
+	"regexp"
+	"syscall"
+	"time"
+)
+
+// Files whose reads reveal the agent is looking at its environment
+var evasionPaths = []struct {
+	pattern *regexp.Regexp
+	kind    string
+}{
+	{regexp.MustCompile(`^/proc/(self|\d+)/status$`), EvasionDebuggerCheck},
+	{regexp.MustCompile(`^/sys/(class|devices/virtual)/dmi/id/`), EvasionVMCheck},
+	{regexp.MustCompile(`^/sys/hypervisor/`), EvasionVMCheck},
+	{regexp.MustCompile(`^/proc/scsi/scsi$`), EvasionVMCheck},
+	{regexp.MustCompile(`^/(\.dockerenv|run/\.containerenv)$`), EvasionContainerCheck},
+	{regexp.MustCompile(`^/proc/1/(cgroup|sched|environ)$`), EvasionContainerCheck},
+}
+
+// evasionTracer watches a traced agent's syscalls for anti-analysis probes. It
+// is only called from the tracer thread.
+type evasionTracer struct {
+	trace         EvasionTrace
+	seen          map[EvasionProbe]bool
+	sleepingSince time.Time
+	slept         time.Duration
+}
+
+func newEvasionTracer() *evasionTracer {
+	return &evasionTracer{seen: make(map[EvasionProbe]bool)}
+}
+
+func (t *evasionTracer) probe(kind, detail string) {
+	probe := EvasionProbe{Kind: kind, Detail: detail}
+	if !t.seen[probe] && len(t.trace.Probes) < 20 {
+		t.seen[probe] = true
+		t.trace.Probes = append(t.trace.Probes, probe)
+	}
+}
+
+// syscall inspects one syscall entry of the agent
+func (t *evasionTracer) syscall(pid int, call *tracedSyscall) {
+	// Time from a sleep to the next syscall is time spent sleeping
+	if !t.sleepingSince.IsZero() {
+		t.slept += time.Since(t.sleepingSince)
+		t.sleepingSince = time.Time{}
+	}
+
+	switch call.Name {
+	case "nanosleep", "clock_nanosleep":
+		t.sleepingSince = time.Now()
+	case "ptrace":
+		if call.Args[0] == syscall.PTRACE_TRACEME {
+			t.probe(EvasionDebuggerCheck, "ptrace(PTRACE_TRACEME)")
+		}
+	case "open", "openat":
+		pathAddr := call.Args[0]
+		if call.Name == "openat" {
+			pathAddr = call.Args[1]
+		}
+		path := readTraceeString(pid, uintptr(pathAddr))
+		for _, known := range evasionPaths {
+			if known.pattern.MatchString(path) {
+				t.probe(known.kind, path)
+				break
+			}
+		}
+	case "connect", "sendto":
+		t.acted("connect", &t.trace.Connected)
+	case "fork", "vfork", "execve":
+		t.acted(call.Name, &t.trace.Spawned)
+	}
+}
+
+// acted records the agent's first connection or spawn, and whether it slept
+// through most of the run beforehand
+func (t *evasionTracer) acted(syscallName string, flag *bool) {
+	if !t.trace.Connected && !t.trace.Spawned && t.slept >= stallThreshold {
+		t.probe(EvasionStalling, fmt.Sprintf("Slept %v before its first %s", t.slept.Round(time.Second), syscallName))
+	}
+	*flag = true
+}
@@ -0,0 +1,88 @@
+package aegong
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestStaticEvasionIndicators tests that anti-analysis strings and hypervisor CPUID queries are found in code
+func TestStaticEvasionIndicators(t *testing.T) {
+	code := []byte("if 'TracerPid:\\t0' not in status and vendor != 'KVMKVMKVM':")
+	// mov eax, 0x40000000; xor ecx, ecx; cpuid
+	code = append(code, 0xB8, 0x00, 0x00, 0x00, 0x40, 0x31, 0xC9, 0x0F, 0xA2)
+
+	kinds := make(map[string]string)
+	for _, indicator := range staticEvasionIndicators(code) {
+		kinds[indicator.Detail] = indicator.Kind
+	}
+	if kinds["TracerPid"] != EvasionDebuggerCheck || kinds["KVMKVMKVM"] != EvasionVMCheck {
+		t.Fatalf("Debugger and hypervisor strings should be indicators, got %v", kinds)
+	}
+	if kinds["cpuid hypervisor leaf 0x40000000"] != EvasionVMCheck {
+		t.Fatalf("Hypervisor CPUID query should be an indicator, got %v", kinds)
+	}
+
+	if indicators := staticEvasionIndicators([]byte("print('hello')\x0F\xA2")); len(indicators) != 0 {
+		t.Fatalf("Plain code should have no indicators, got %+v", indicators)
+	}
+}
+
+// TestEvasionThreats tests how runtime probes, static indicators and dormant capabilities combine
+func TestEvasionThreats(t *testing.T) {
+	code := []byte("import subprocess\nsubprocess.run(['curl', 'https://example.com'])\n")
+	container := &CustomContainer{Evasion: &EvasionTrace{
+		Probes:  []EvasionProbe{{Kind: EvasionStalling, Detail: "Slept 20s before its first connect"}},
+		Spawned: true, Connected: true,
+	}}
+	threats := container.evasionThreats(code)
+	if len(threats) != 1 || threats[0].Vector != T9_GOVERNANCE_EVASION || threats[0].Severity != MEDIUM {
+		t.Fatalf("Stalling alone should be a MEDIUM T9 finding, got %+v", threats)
+	}
+
+	container.Evasion = &EvasionTrace{Probes: []EvasionProbe{{Kind: EvasionDebuggerCheck, Detail: "/proc/self/status"}}}
+	threats = container.evasionThreats(code)
+	if len(threats) != 1 || threats[0].Severity != HIGH || len(threats[0].Details["dormant"].([]string)) != 2 {
+		t.Fatalf("Checking for a debugger and then not acting should be HIGH, got %+v", threats)
+	}
+
+	container.Evasion = &EvasionTrace{}
+	if threats := container.evasionThreats(code); len(threats) != 0 {
+		t.Fatalf("Unused capabilities without an environment check should raise nothing, got %+v", threats)
+	}
+}
+
+// TestEvasionProbes tests that the tracer sees a Python agent check for a debugger
+func TestEvasionProbes(t *testing.T) {
+	requireSandbox(t)
+	if pythonHarness.interpreter() == "" {
+		t.Skip("python3 is not installed")
+	}
+	engine := newTestEngine(t)
+
+	container, err := engine.createIsolatedContainer("evasion-test")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer engine.destroyContainer(container.ID)
+
+	agent := []byte(`import subprocess
+
+with open("/proc/self/status") as status:
+    traced = any(line.split()[1] != "0" for line in status if line.startswith("Tracer"))
+if not traced:
+    subprocess.run(["true"])
+`)
+	executionLog := engine.simulateExecution(context.Background(), agent, container)
+	if container.Evasion == nil || len(container.Evasion.Probes) == 0 || container.Evasion.Probes[0].Detail != "/proc/self/status" {
+		t.Fatalf("Reading /proc/self/status should be traced as a probe:\n%s", executionLog)
+	}
+	if !strings.Contains(executionLog, "Evasion Probes:") {
+		t.Fatal("Probes should be added to the execution log")
+	}
+
+	threats := container.evasionThreats(agent)
+	if len(threats) != 1 || threats[0].Severity != HIGH {
+		t.Fatalf("A traced agent that skips its subprocess should raise a HIGH finding, got %+v", threats)
+	}
+}
@@ -0,0 +1,373 @@
+package aegong
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// When a pattern's false positive marks make it noisy
+const (
+	noisyMinFalsePositives = 3   // Marks before a pattern can be noisy
+	noisyFalsePositiveRate = 0.5 // Share of a pattern's findings marked false positive
+	minFeedbackFactor      = 0.25
+)
+
+// FalsePositive records a user marking a finding of a report as a false positive
+type FalsePositive struct {
+	AgentHash     string    `json:"agent_hash"`
+	Threat        int       `json:"threat"` // Index into the report's threats
+	Vector        string    `json:"vector"` // T1 to T9
+	Patterns      []string  `json:"patterns"`
+	Actor         string    `json:"actor"`
+	Role          string    `json:"role"`
+	Reason        string    `json:"reason,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+	CorrelationID string    `json:"correlation_id,omitempty"` // Request that marked the finding
+}
+
+// PatternFeedback is how often findings with an evidence pattern were
+// reported and marked false positive
+type PatternFeedback struct {
+	Vector         string  `json:"vector"`
+	Pattern        string  `json:"pattern"`
+	Seen           int     `json:"seen"` // Findings reported with the pattern
+	FalsePositives int     `json:"false_positives"`
+	Rate           float64 `json:"false_positive_rate"`
+	Noisy          bool    `json:"noisy"`
+}
+
+// VectorFeedback totals the false positive marks of one threat vector
+type VectorFeedback struct {
+	Vector         string            `json:"vector"`
+	Name           string            `json:"name"`
+	Seen           int               `json:"seen"`
+	FalsePositives int               `json:"false_positives"`
+	Patterns       []PatternFeedback `json:"patterns"` // Most marked first
+}
+
+// FeedbackSummary aggregates false positive marks per vector and pattern
+type FeedbackSummary struct {
+	Downweight    bool              `json:"downweight"` // Whether noisy patterns lower the risk score
+	Vectors       []VectorFeedback  `json:"vectors"`
+	NoisyPatterns []PatternFeedback `json:"noisy_patterns"` // Highest rate first
+}
+
+// ErrAlreadyMarked is returned when a user marks the same finding twice
+var ErrAlreadyMarked = errors.New("finding is already marked as a false positive")
+
+// Evidence details that vary between findings of the same pattern
+var (
+	decodedSuffix = regexp.MustCompile(` \(decoded [^)]*\)$`)
+	digitRuns     = regexp.MustCompile(`[0-9]+`)
+)
+
+// EvidencePattern reduces an evidence line to the pattern feedback is
+// aggregated by, dropping offsets, counts and line numbers
+func EvidencePattern(evidence string) string {
+	pattern := decodedSuffix.ReplaceAllString(strings.TrimSpace(evidence), "")
+	return digitRuns.ReplaceAllString(pattern, "#")
+}
+
+// threatPatterns lists the distinct evidence patterns of a finding
+func threatPatterns(threat ThreatDetection) []string {
+	seen := make(map[string]bool)
+	var patterns []string
+	for _, evidence := range threat.Evidence {
+		pattern := EvidencePattern(evidence)
+		if pattern == "" || seen[pattern] {
+			continue
+		}
+		seen[pattern] = true
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// feedbackStore keeps the false positive counts, saved to a JSON file
+type feedbackStore struct {
+	path       string
+	downweight bool
+	mutex      sync.Mutex
+	patterns   map[string]*PatternFeedback // Keyed by vector and pattern
+	marked     map[string]bool             // Agent hash, threat and actor of each mark
+}
+
+// feedbackFile is the saved form of a feedbackStore
+type feedbackFile struct {
+	Patterns []*PatternFeedback `json:"patterns"`
+	Marked   []string           `json:"marked"`
+}
+
+func newFeedbackStore(path string, downweight bool) (*feedbackStore, error) {
+	store := &feedbackStore{
+		path:       path,
+		downweight: downweight,
+		patterns:   make(map[string]*PatternFeedback),
+		marked:     make(map[string]bool),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feedback: %v", err)
+	}
+	var saved feedbackFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("invalid feedback file %s: %v", path, err)
+	}
+	for _, pattern := range saved.Patterns {
+		store.patterns[pattern.Vector+"\x00"+pattern.Pattern] = pattern
+	}
+	for _, key := range saved.Marked {
+		store.marked[key] = true
+	}
+	return store, nil
+}
+
+// pattern returns the counts of a pattern, adding it if needed. The caller
+// must hold the mutex.
+func (s *feedbackStore) pattern(vector, pattern string) *PatternFeedback {
+	key := vector + "\x00" + pattern
+	counts := s.patterns[key]
+	if counts == nil {
+		counts = &PatternFeedback{Vector: vector, Pattern: pattern}
+		s.patterns[key] = counts
+	}
+	return counts
+}
+
+// update recomputes a pattern's rate after its counts change
+func (p *PatternFeedback) update() {
+	// Reports saved before feedback was collected were never counted
+	if p.Seen < p.FalsePositives {
+		p.Seen = p.FalsePositives
+	}
+	p.Rate = 0
+	if p.Seen > 0 {
+		p.Rate = float64(p.FalsePositives) / float64(p.Seen)
+	}
+	p.Noisy = p.FalsePositives >= noisyMinFalsePositives && p.Rate >= noisyFalsePositiveRate
+}
+
+// save writes the store to its file. The caller must hold the mutex.
+func (s *feedbackStore) save() error {
+	saved := feedbackFile{Patterns: []*PatternFeedback{}, Marked: []string{}}
+	for _, pattern := range s.patterns {
+		saved.Patterns = append(saved.Patterns, pattern)
+	}
+	sort.Slice(saved.Patterns, func(i, j int) bool {
+		a, b := saved.Patterns[i], saved.Patterns[j]
+		if a.Vector != b.Vector {
+			return a.Vector < b.Vector
+		}
+		return a.Pattern < b.Pattern
+	})
+	for key := range s.marked {
+		saved.Marked = append(saved.Marked, key)
+	}
+	sort.Strings(saved.Marked)
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write feedback: %v", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// observe counts the patterns of an audit's findings
+func (s *feedbackStore) observe(threats []ThreatDetection) error {
+	if len(threats) == 0 {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, threat := range threats {
+		for _, pattern := range threatPatterns(threat) {
+			counts := s.pattern(detectorName(threat.Vector), pattern)
+			counts.Seen++
+			counts.update()
+		}
+	}
+	return s.save()
+}
+
+// mark records a false positive, once per finding and actor
+func (s *feedbackStore) mark(fp *FalsePositive) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := fmt.Sprintf("%s/%d/%s", fp.AgentHash, fp.Threat, fp.Actor)
+	if s.marked[key] {
+		return ErrAlreadyMarked
+	}
+	s.marked[key] = true
+	for _, pattern := range fp.Patterns {
+		counts := s.pattern(fp.Vector, pattern)
+		counts.FalsePositives++
+		counts.update()
+	}
+	return s.save()
+}
+
+// factor is how much a finding's risk is kept given how noisy its patterns
+// are: the mean over its patterns of one less the rate of noisy ones
+func (s *feedbackStore) factor(threat ThreatDetection) float64 {
+	patterns := threatPatterns(threat)
+	if len(patterns) == 0 {
+		return 1
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	total := 0.0
+	for _, pattern := range patterns {
+		counts := s.patterns[detectorName(threat.Vector)+"\x00"+pattern]
+		if counts != nil && counts.Noisy {
+			total += 1 - counts.Rate
+		} else {
+			total++
+		}
+	}
+	factor := total / float64(len(patterns))
+	if factor < minFeedbackFactor {
+		factor = minFeedbackFactor
+	}
+	return factor
+}
+
+// summary aggregates the counts per vector
+func (s *feedbackStore) summary() FeedbackSummary {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	summary := FeedbackSummary{Downweight: s.downweight, Vectors: []VectorFeedback{}, NoisyPatterns: []PatternFeedback{}}
+	vectors := make(map[string]*VectorFeedback)
+	for _, pattern := range s.patterns {
+		vector := vectors[pattern.Vector]
+		if vector == nil {
+			vector = &VectorFeedback{Vector: pattern.Vector, Patterns: []PatternFeedback{}}
+			var v int
+			if _, err := fmt.Sscanf(pattern.Vector, "T%d", &v); err == nil {
+				vector.Name = ThreatName(ThreatVector(v - 1))
+			}
+			vectors[pattern.Vector] = vector
+		}
+		vector.Seen += pattern.Seen
+		vector.FalsePositives += pattern.FalsePositives
+		vector.Patterns = append(vector.Patterns, *pattern)
+		if pattern.Noisy {
+			summary.NoisyPatterns = append(summary.NoisyPatterns, *pattern)
+		}
+	}
+
+	for _, vector := range vectors {
+		sort.Slice(vector.Patterns, func(i, j int) bool {
+			a, b := vector.Patterns[i], vector.Patterns[j]
+			if a.FalsePositives != b.FalsePositives {
+				return a.FalsePositives > b.FalsePositives
+			}
+			return a.Pattern < b.Pattern
+		})
+		summary.Vectors = append(summary.Vectors, *vector)
+	}
+	sort.Slice(summary.Vectors, func(i, j int) bool {
+		return summary.Vectors[i].Vector < summary.Vectors[j].Vector
+	})
+	sort.Slice(summary.NoisyPatterns, func(i, j int) bool {
+		a, b := summary.NoisyPatterns[i], summary.NoisyPatterns[j]
+		if a.Rate != b.Rate {
+			return a.Rate > b.Rate
+		}
+		return a.FalsePositives > b.FalsePositives
+	})
+	return summary
+}
+
+// MarkFalsePositive records that a finding of a report is a false positive,
+// counting it against each of the finding's evidence patterns
+func (e *Engine) MarkFalsePositive(report *AuditReport, threat int, actor, role, reason, correlationID string) (*FalsePositive, error) {
+	if e.feedback == nil {
+		return nil, fmt.Errorf("false positive feedback is disabled")
+	}
+	if threat < 0 || threat >= len(report.Threats) {
+		return nil, fmt.Errorf("report has no threat %d", threat)
+	}
+
+	finding := report.Threats[threat]
+	fp := &FalsePositive{
+		AgentHash:     report.AgentHash,
+		Threat:        threat,
+		Vector:        detectorName(finding.Vector),
+		Patterns:      threatPatterns(finding),
+		Actor:         actor,
+		Role:          role,
+		Reason:        reason,
+		Timestamp:     time.Now(),
+		CorrelationID: correlationID,
+	}
+	if err := e.feedback.mark(fp); err != nil {
+		return nil, err
+	}
+	if e.auditLog != nil {
+		e.auditLog.LogFalsePositive(fp)
+	}
+	return fp, nil
+}
+
+// MarkedThreats lists the findings anyone has marked as a false positive, as
+// indexes into each report's threats by agent hash
+func (e *Engine) MarkedThreats() map[string]map[int]bool {
+	marked := make(map[string]map[int]bool)
+	if e.feedback == nil {
+		return marked
+	}
+	e.feedback.mutex.Lock()
+	defer e.feedback.mutex.Unlock()
+	for key := range e.feedback.marked {
+		parts := strings.SplitN(key, "/", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		threat, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+		if marked[parts[0]] == nil {
+			marked[parts[0]] = make(map[int]bool)
+		}
+		marked[parts[0]][threat] = true
+	}
+	return marked
+}
+
+// Feedback aggregates false positive marks per vector and pattern
+func (e *Engine) Feedback() FeedbackSummary {
+	if e.feedback == nil {
+		return FeedbackSummary{Vectors: []VectorFeedback{}, NoisyPatterns: []PatternFeedback{}}
+	}
+	return e.feedback.summary()
+}
+
+// feedbackFactor returns the risk factor of noisy patterns, or nil when
+// feedback does not affect scoring
+func (e *Engine) feedbackFactor() func(ThreatDetection) float64 {
+	if e.feedback == nil || !e.feedback.downweight {
+		return nil
+	}
+	return e.feedback.factor
+}
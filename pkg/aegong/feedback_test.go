@@ -0,0 +1,86 @@
+package aegong
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestEvidencePattern tests that varying details are dropped from evidence
+func TestEvidencePattern(t *testing.T) {
+	for evidence, pattern := range map[string]string{
+		"Suspicious pattern found: eval":                                 "Suspicious pattern found: eval",
+		"Function `run` at line 42 has a cyclomatic complexity of 17":    "Function `run` at line # has a cyclomatic complexity of #",
+		"Suspicious pattern found: exec (decoded base64 at offset 1024)": "Suspicious pattern found: exec",
+		" Agent wrote 2048 KB, beyond the 1024 KB disk quota ":           "Agent wrote # KB, beyond the # KB disk quota",
+	} {
+		if got := EvidencePattern(evidence); got != pattern {
+			t.Fatalf("Pattern of %q should be %q, got %q", evidence, pattern, got)
+		}
+	}
+}
+
+// TestFeedbackDownweight tests that noisy patterns lower the risk when enabled
+func TestFeedbackDownweight(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feedback.json")
+	engine, err := NewEngine(Config{FeedbackPath: path, FeedbackDownweight: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	noisy := ThreatDetection{Vector: T1_REASONING_HIJACK, Severity: HIGH, Confidence: 0.8, Evidence: []string{"Suspicious pattern found: eval"}}
+	other := ThreatDetection{Vector: T4_UNAUTHORIZED_ACTION, Severity: HIGH, Confidence: 0.8, Evidence: []string{"Dangerous system call: execve"}}
+	report := &AuditReport{AgentHash: "abc", Threats: []ThreatDetection{noisy, other}}
+
+	// Four audits report the pattern and three users mark it
+	engine.feedback.observe([]ThreatDetection{noisy, noisy, noisy, noisy})
+	for _, actor := range []string{"alice", "bob"} {
+		if _, err := engine.MarkFalsePositive(report, 0, actor, "auditor", "", ""); err != nil {
+			t.Fatalf("Failed to mark finding: %v", err)
+		}
+	}
+	if _, err := engine.MarkFalsePositive(report, 0, "alice", "auditor", "", ""); err != ErrAlreadyMarked {
+		t.Fatalf("Should refuse a second mark by the same user, got %v", err)
+	}
+	if marked := engine.MarkedThreats(); len(marked) != 1 || !marked["abc"][0] || marked["abc"][1] {
+		t.Fatalf("Should list the marked finding once, got %v", marked)
+	}
+	if summary := engine.Feedback(); len(summary.NoisyPatterns) != 0 {
+		t.Fatalf("Two marks should not make a pattern noisy, got %+v", summary.NoisyPatterns)
+	}
+	before := engine.calculateOverallRisk(report.Threats)
+
+	if _, err := engine.MarkFalsePositive(report, 0, "carol", "auditor", "", ""); err != nil {
+		t.Fatalf("Failed to mark finding: %v", err)
+	}
+	summary := engine.Feedback()
+	if len(summary.NoisyPatterns) != 1 || summary.NoisyPatterns[0].Rate != 0.75 {
+		t.Fatalf("Pattern should be noisy at a 0.75 rate, got %+v", summary.NoisyPatterns)
+	}
+	if len(summary.Vectors) != 1 || summary.Vectors[0].Name != "Reasoning Path Hijacking" {
+		t.Fatalf("Should aggregate per vector, got %+v", summary.Vectors)
+	}
+
+	breakdown := engine.scoring.breakdown(report.Threats, engine.feedbackFactor())
+	for _, contribution := range breakdown.Contributions {
+		if contribution.Threat == 0 && contribution.FeedbackFactor != 0.25 {
+			t.Fatalf("Noisy finding should keep a quarter of its risk, got %+v", contribution)
+		}
+		if contribution.Threat == 1 && contribution.FeedbackFactor != 0 {
+			t.Fatalf("Other findings should not be downweighted, got %+v", contribution)
+		}
+	}
+	if after := breakdown.Score; after >= before {
+		t.Fatalf("Risk should drop from %g once the pattern is noisy, got %g", before, after)
+	}
+
+	// Counts survive a restart
+	reopened, err := NewEngine(Config{FeedbackPath: path})
+	if err != nil {
+		t.Fatalf("Failed to reopen engine: %v", err)
+	}
+	if summary := reopened.Feedback(); len(summary.NoisyPatterns) != 1 || summary.Downweight {
+		t.Fatalf("Should reload counts without downweighting, got %+v", summary)
+	}
+	if reopened.feedbackFactor() != nil {
+		t.Fatal("Feedback should not affect scoring unless enabled")
+	}
+}
@@ -0,0 +1,156 @@
+package aegong
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Groups of file types, for accepting or denying several at once
+const (
+	FileGroupExecutable = "executable" // elf, pe, macho, wasm
+	FileGroupScript     = "script"     // shebang, python, javascript
+	FileGroupArchive    = "archive"    // zip, jar, gzip, bzip2, xz, 7z, tar
+	FileGroupDocument   = "document"   // pdf, rtf, ole (legacy Office), docx, xlsx, pptx, odf
+	FileGroupImage      = "image"      // png, jpeg, gif, webp
+	FileGroupText       = "text"       // Text in no language the engine parses
+	FileGroupUnknown    = "unknown"    // Binary data of no known type
+)
+
+// FileType is what an upload's content, rather than its name, says it is
+type FileType struct {
+	Name  string `json:"name"`  // Such as elf, docx or python
+	Group string `json:"group"` // One of the FileGroup constants
+}
+
+// String formats the type as group/name
+func (t FileType) String() string {
+	return t.Group + "/" + t.Name
+}
+
+// Magic numbers of the archive, document and image formats
+var fileMagics = []struct {
+	offset int
+	magic  string
+	name   string
+	group  string
+}{
+	{0, "\x1f\x8b", "gzip", FileGroupArchive},
+	{0, "BZh", "bzip2", FileGroupArchive},
+	{0, "\xfd7zXZ\x00", "xz", FileGroupArchive},
+	{0, "7z\xbc\xaf\x27\x1c", "7z", FileGroupArchive},
+	{257, "ustar", "tar", FileGroupArchive},
+	{0, "%PDF-", "pdf", FileGroupDocument},
+	{0, "{\\rtf", "rtf", FileGroupDocument},
+	{0, "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1", "ole", FileGroupDocument},
+	{0, "\x89PNG\r\n\x1a\n", "png", FileGroupImage},
+	{0, "\xff\xd8\xff", "jpeg", FileGroupImage},
+	{0, "GIF87a", "gif", FileGroupImage},
+	{0, "GIF89a", "gif", FileGroupImage},
+}
+
+// Names SniffFileType can return besides those in fileMagics
+var fileTypeNames = []string{
+	"elf", "pe", "macho", "wasm", "zip", "jar", "docx", "xlsx", "pptx", "ooxml", "odf", "webp",
+	"shebang", FormatPython, FormatJavaScript, FormatText, FormatBinary,
+}
+
+// IsFileType reports whether name is a file type SniffFileType returns or
+// one of the groups
+func IsFileType(name string) bool {
+	switch name {
+	case FileGroupExecutable, FileGroupScript, FileGroupArchive, FileGroupDocument, FileGroupImage, FileGroupText, FileGroupUnknown:
+		return true
+	}
+	for _, m := range fileMagics {
+		if m.name == name {
+			return true
+		}
+	}
+	return slices.Contains(fileTypeNames, name)
+}
+
+// SniffFileType identifies content by its magic number, looking inside zip
+// files to tell JARs and Office documents apart. Files without a magic number
+// are scripts when they start with a shebang or read as Python or JavaScript.
+func SniffFileType(data []byte) FileType {
+	switch format := detectFileType(data, ""); format {
+	case "elf", "pe", "macho", "wasm":
+		return FileType{Name: format, Group: FileGroupExecutable}
+	}
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) || bytes.HasPrefix(data, []byte("PK\x05\x06")) {
+		return sniffZip(data)
+	}
+	if len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP" {
+		return FileType{Name: "webp", Group: FileGroupImage}
+	}
+	for _, m := range fileMagics {
+		if len(data) >= m.offset+len(m.magic) && string(data[m.offset:m.offset+len(m.magic)]) == m.magic {
+			return FileType{Name: m.name, Group: m.group}
+		}
+	}
+
+	if bytes.HasPrefix(data, []byte("#!")) {
+		return FileType{Name: "shebang", Group: FileGroupScript}
+	}
+	if lang := detectScriptLanguage(data); lang != nil {
+		return FileType{Name: lang.name, Group: FileGroupScript}
+	}
+	head := data[:min(len(data), 8192)]
+	if len(head) < len(data) {
+		// Allow for a character cut in two by the limit
+		for i := 1; i < utf8.UTFMax && !utf8.Valid(head); i++ {
+			head = head[:len(head)-1]
+		}
+	}
+	if bytes.IndexByte(head, 0) < 0 && utf8.Valid(head) {
+		return FileType{Name: FormatText, Group: FileGroupText}
+	}
+	return FileType{Name: FormatBinary, Group: FileGroupUnknown}
+}
+
+// sniffZip tells JARs, Office Open XML and OpenDocument files from other zips
+func sniffZip(data []byte) FileType {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return FileType{Name: "zip", Group: FileGroupArchive}
+	}
+	// Office Open XML files list their parts in [Content_Types].xml
+	contentTypes := false
+	office := "ooxml"
+	for _, file := range archive.File {
+		switch {
+		case file.Name == "META-INF/MANIFEST.MF" || strings.HasSuffix(file.Name, ".class"):
+			return FileType{Name: "jar", Group: FileGroupArchive}
+		case file.Name == "mimetype" && strings.HasPrefix(readZipEntry(file, 64), "application/vnd.oasis.opendocument"):
+			return FileType{Name: "odf", Group: FileGroupDocument}
+		case file.Name == "[Content_Types].xml":
+			contentTypes = true
+		case strings.HasPrefix(file.Name, "word/"):
+			office = "docx"
+		case strings.HasPrefix(file.Name, "xl/"):
+			office = "xlsx"
+		case strings.HasPrefix(file.Name, "ppt/"):
+			office = "pptx"
+		}
+	}
+	if contentTypes {
+		return FileType{Name: office, Group: FileGroupDocument}
+	}
+	return FileType{Name: "zip", Group: FileGroupArchive}
+}
+
+// readZipEntry reads up to limit bytes of a zip entry
+func readZipEntry(file *zip.File, limit int) string {
+	r, err := file.Open()
+	if err != nil {
+		return ""
+	}
+	defer r.Close()
+	buf := make([]byte, limit)
+	n, _ := io.ReadFull(r, buf)
+	return string(buf[:n])
+}
@@ -0,0 +1,56 @@
+package aegong
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testZip builds a zip archive of the given entries
+func testZip(t *testing.T, entries map[string]string) []byte {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range entries {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	archive.Close()
+	return buf.Bytes()
+}
+
+// TestSniffFileType tests that content is identified by magic number rather than name
+func TestSniffFileType(t *testing.T) {
+	tar := make([]byte, 512)
+	copy(tar[257:], "ustar")
+
+	for _, c := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"elf", []byte{0x7F, 'E', 'L', 'F', 2, 1, 1}, "executable/elf"},
+		{"wasm", []byte{0x00, 'a', 's', 'm', 1, 0, 0, 0}, "executable/wasm"},
+		{"jar", testZip(t, map[string]string{"META-INF/MANIFEST.MF": "Manifest-Version: 1.0\n"}), "archive/jar"},
+		{"zip", testZip(t, map[string]string{"agent/main.py": "print(1)\n"}), "archive/zip"},
+		{"docx", testZip(t, map[string]string{"[Content_Types].xml": "<Types/>", "word/document.xml": "<w/>"}), "document/docx"},
+		{"odt", testZip(t, map[string]string{"mimetype": "application/vnd.oasis.opendocument.text"}), "document/odf"},
+		{"zip with a word directory", testZip(t, map[string]string{"word/list.txt": "a\n"}), "archive/zip"},
+		{"gzip", []byte{0x1f, 0x8b, 8, 0}, "archive/gzip"},
+		{"tar", tar, "archive/tar"},
+		{"pdf", []byte("%PDF-1.7\n"), "document/pdf"},
+		{"jpeg", []byte{0xff, 0xd8, 0xff, 0xe0}, "image/jpeg"},
+		{"shell script", []byte("#!/bin/sh\necho hi\n"), "script/shebang"},
+		{"python", []byte("import os\n\ndef main():\n    print(os.getcwd())\n"), "script/python"},
+		{"text", []byte("Meeting notes: ship it. Привет\n"), "text/text"},
+		{"long text cut mid character", []byte(strings.Repeat("a", 8191) + "é"), "text/text"},
+		{"binary", []byte{0x01, 0x02, 0x00, 0xff}, "unknown/binary"},
+	} {
+		if got := SniffFileType(c.data).String(); got != c.want {
+			t.Errorf("%s: Should be identified as %s, got %s", c.name, c.want, got)
+		}
+	}
+}
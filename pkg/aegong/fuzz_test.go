@@ -0,0 +1,147 @@
+package aegong
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"testing"
+)
+
+// Uploaded agents are fully attacker controlled and parsed inside the server
+// process, so these targets check the validator and the binary parsers never
+// panic or hang on malformed input. Seeds live in testdata/fuzz; run one with
+//
+//	go test ./pkg/aegong -run '^$' -fuzz FuzzParseELF -fuzztime 1m
+
+var fileTypes = map[string]bool{
+	"wasm": true, "elf": true, "pe": true, "macho": true, "script": true,
+	"jar": true, "library": true, "executable": true, "unknown": true,
+}
+
+// binarySeeds returns truncated headers of every format and, where
+// available, a real executable
+func binarySeeds() [][]byte {
+	seeds := [][]byte{
+		{},
+		{0x7F, 'E', 'L', 'F', 2, 1, 1},
+		{'M', 'Z', 0x90, 0},
+		{0xCF, 0xFA, 0xED, 0xFE, 7, 0, 0, 1},
+		{0x00, 'a', 's', 'm', 1, 0, 0, 0},
+	}
+	if binary, err := os.ReadFile("/bin/true"); err == nil {
+		seeds = append(seeds, binary)
+	}
+	return seeds
+}
+
+// addBinarySeeds seeds a target that takes only content
+func addBinarySeeds(f *testing.F) {
+	for _, seed := range binarySeeds() {
+		f.Add(seed)
+	}
+}
+
+// FuzzDetectFileType fuzzes file type detection over content and file names
+func FuzzDetectFileType(f *testing.F) {
+	for _, seed := range binarySeeds() {
+		f.Add(seed, "agent")
+	}
+	f.Fuzz(func(t *testing.T, data []byte, name string) {
+		fileType := detectFileType(data, name)
+		if !fileTypes[fileType] {
+			t.Fatalf("Should detect a known file type, got %q", fileType)
+		}
+		if bytes.HasPrefix(data, []byte{0x00, 'a', 's', 'm'}) && fileType != "wasm" {
+			t.Fatalf("Content with the WASM magic should be wasm, got %q", fileType)
+		}
+		if sniffed := SniffFileType(data); !IsFileType(sniffed.Name) || !IsFileType(sniffed.Group) {
+			t.Fatalf("Should sniff a known file type, got %s", sniffed)
+		}
+
+		result, err := validateHeuristic(data, name)
+		if err != nil {
+			t.Fatalf("Should validate any content without an error, got %v", err)
+		}
+		checkValidation(t, result)
+	})
+}
+
+// FuzzValidateWasmAgent fuzzes the WASM agent validator
+func FuzzValidateWasmAgent(f *testing.F) {
+	addBinarySeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		result, err := validateWasmAgent(data)
+		if err != nil {
+			t.Fatalf("Should validate any module without an error, got %v", err)
+		}
+		checkValidation(t, result)
+		if result.IsAgent && len(result.Capabilities) < 3 {
+			t.Fatalf("An agent should have at least three capabilities, got %v", result.Capabilities)
+		}
+	})
+}
+
+// FuzzParseELF fuzzes every parser that reads ELF agents
+func FuzzParseELF(f *testing.F) {
+	addBinarySeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parseBinary(t, data, "elf", validateElfAgent)
+		dynamicallyLinked(data)
+	})
+}
+
+// FuzzParsePE fuzzes every parser that reads PE agents
+func FuzzParsePE(f *testing.F) {
+	addBinarySeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parseBinary(t, data, "pe", validatePeAgent)
+		verifyEmbeddedSignature(data)
+	})
+}
+
+// FuzzParseMachO fuzzes every parser that reads Mach-O agents
+func FuzzParseMachO(f *testing.F) {
+	addBinarySeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parseBinary(t, data, "macho", validateMachoAgent)
+	})
+}
+
+// parseBinary runs a format's validator, symbol reader, section measurement
+// and disassembly over fuzzed content
+func parseBinary(t *testing.T, data []byte, format string, validate func([]byte) (*AgentValidationResult, error)) {
+	result, err := validate(data)
+	if err != nil {
+		t.Fatalf("Should validate any %s content without an error, got %v", format, err)
+	}
+	checkValidation(t, result)
+
+	symbols := importedSymbols(data, format)
+	if !sort.StringsAreSorted(symbols) {
+		t.Fatalf("Imported symbols should be sorted, got %v", symbols)
+	}
+	if packing := analyzeSections(data); packing != nil {
+		for _, section := range packing.Sections {
+			if section.Entropy < 0 || section.Entropy > 8 {
+				t.Fatalf("Section entropy should be between 0 and 8 bits, got %+v", section)
+			}
+		}
+	}
+	if code := disassemble(data); code != nil {
+		disassemblyThreats(code)
+	}
+}
+
+// checkValidation checks the invariants of any validation result
+func checkValidation(t *testing.T, result *AgentValidationResult) {
+	t.Helper()
+	if result == nil {
+		t.Fatal("Should return a validation result")
+	}
+	if result.Confidence < 0 || result.Confidence > 1 {
+		t.Fatalf("Confidence should be between 0 and 1, got %v", result.Confidence)
+	}
+	if !result.IsAgent && len(result.Reasons) == 0 {
+		t.Fatal("A rejection should give a reason")
+	}
+}
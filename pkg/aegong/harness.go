@@ -0,0 +1,199 @@
+package aegong
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Bundled harnesses that run script agents with language-level tracing
+//
+//go:embed harness/python_harness.py
+var pythonHarnessScript []byte
+
+//go:embed harness/node_harness.cjs
+var nodeHarnessScript []byte
+
+// File in the container the harness writes its events to
+const harnessEventsFile = "harness_events.jsonl"
+
+// Most harness events kept per audit
+const maxHarnessEvents = 500
+
+// HarnessEvent is one action a script agent took, as logged by its harness
+type HarnessEvent struct {
+	Event  string `json:"event"`
+	Detail string `json:"detail"`
+}
+
+// scriptHarness runs agents of one language under a bundled tracing script
+type scriptHarness struct {
+	language    string
+	agentFile   string // Name the agent is written under in the container
+	scriptFile  string
+	script      []byte
+	interpreter func() string
+	args        func(script, events, agent string) []string
+	plainArgs   func(agent string) []string // Runs the agent without the harness
+	// Installs dependencies into the container with interpreter, returning the
+	// interpreter to run the agent with
+	install func(ctx context.Context, interpreter, containerDir string, dependencies []string) (string, error)
+}
+
+var pythonHarness = &scriptHarness{
+	language:    "python",
+	agentFile:   "agent.py",
+	scriptFile:  "aegong_harness.py",
+	script:      pythonHarnessScript,
+	interpreter: func() string { return findInterpreter("AEGONG_PYTHON", "/usr/bin/python3", "/usr/local/bin/python3") },
+	args: func(script, events, agent string) []string {
+		// -I ignores the environment and user site-packages, -B writes no bytecode
+		return []string{"-I", "-B", script, events, agent}
+	},
+	plainArgs: func(agent string) []string { return []string{"-I", "-B", agent} },
+	install:   installPythonDependencies,
+}
+
+var nodeHarness = &scriptHarness{
+	language:    "javascript",
+	agentFile:   "agent.js",
+	scriptFile:  "aegong_harness.cjs",
+	script:      nodeHarnessScript,
+	interpreter: func() string { return findInterpreter("AEGONG_NODE", "/usr/bin/node", "/usr/local/bin/node") },
+	args: func(script, events, agent string) []string {
+		// The harness finds the events file next to itself, keeping the agent's argv intact;
+		// agents using import syntax run as ES modules
+		return []string{"--experimental-detect-module", "--require", script, agent}
+	},
+	plainArgs: func(agent string) []string { return []string{"--experimental-detect-module", agent} },
+	install:   installNodeDependencies,
+}
+
+// harnessEnabled reports whether script agents run under a language harness
+func harnessEnabled() bool {
+	return os.Getenv("AEGONG_DISABLE_HARNESS") != "1"
+}
+
+// selectHarness returns the harness for a script agent, or nil to run it
+// without one
+func selectHarness(binary []byte) *scriptHarness {
+	if !harnessEnabled() {
+		return nil
+	}
+	return selectRuntime(binary)
+}
+
+// findInterpreter returns the interpreter named by env, else the first
+// candidate that exists. System paths are preferred over PATH lookups, which
+// may resolve to per-user installs the sandbox user cannot read.
+func findInterpreter(env string, candidates ...string) string {
+	if path := os.Getenv(env); path != "" {
+		return path
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	if path, err := exec.LookPath(filepath.Base(candidates[0])); err == nil {
+		return path
+	}
+	return ""
+}
+
+// command writes the harness into the container and returns the command that
+// runs agentPath under it with interpreter
+func (h *scriptHarness) command(interpreter, containerDir, agentPath string) (*exec.Cmd, error) {
+	scriptPath := filepath.Join(containerDir, h.scriptFile)
+	if err := os.WriteFile(scriptPath, h.script, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s harness: %v", h.language, err)
+	}
+
+	// The sandbox user must be able to append to the events file
+	eventsPath := filepath.Join(containerDir, harnessEventsFile)
+	if err := os.WriteFile(eventsPath, nil, 0666); err != nil {
+		return nil, fmt.Errorf("failed to create harness events file: %v", err)
+	}
+	if err := os.Chmod(eventsPath, 0666); err != nil {
+		return nil, fmt.Errorf("failed to create harness events file: %v", err)
+	}
+
+	return exec.Command(interpreter, h.args(scriptPath, eventsPath, agentPath)...), nil
+}
+
+// readHarnessEvents parses the events the harness logged during execution
+func readHarnessEvents(containerDir string) []HarnessEvent {
+	file, err := os.Open(filepath.Join(containerDir, harnessEventsFile))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var events []HarnessEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() && len(events) < maxHarnessEvents {
+		var event HarnessEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err == nil && event.Event != "" {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// How harness events map onto threat vectors; imports, name lookups and
+// writes inside the container are only evidence for the detectors
+var harnessEventThreats = map[string]struct {
+	vector      ThreatVector
+	severity    ThreatSeverity
+	description string
+}{
+	"subprocess": {T4_UNAUTHORIZED_ACTION, HIGH, "Spawned a process"},
+	"connect":    {T4_UNAUTHORIZED_ACTION, MEDIUM, "Opened a network connection to"},
+	"request":    {T4_UNAUTHORIZED_ACTION, MEDIUM, "Made an outbound request"},
+	"eval":       {T9_GOVERNANCE_EVASION, MEDIUM, "Evaluated dynamically built code"},
+	"exec":       {T9_GOVERNANCE_EVASION, MEDIUM, "Executed dynamically built code"},
+}
+
+// harnessThreats turns what the harness saw the agent do into T4/T9 evidence
+func (c *CustomContainer) harnessThreats() []ThreatDetection {
+	grouped := make(map[ThreatVector][]HarnessEvent)
+	severities := make(map[ThreatVector]ThreatSeverity)
+	evidence := make(map[ThreatVector][]string)
+	for _, event := range c.HarnessEvents {
+		mapping, ok := harnessEventThreats[event.Event]
+		if !ok {
+			continue
+		}
+		grouped[mapping.vector] = append(grouped[mapping.vector], event)
+		severities[mapping.vector] = max(severities[mapping.vector], mapping.severity)
+		if len(evidence[mapping.vector]) < 10 {
+			evidence[mapping.vector] = append(evidence[mapping.vector], fmt.Sprintf("%s: %s", mapping.description, event.Detail))
+		}
+	}
+
+	var threats []ThreatDetection
+	for _, vector := range []ThreatVector{T4_UNAUTHORIZED_ACTION, T9_GOVERNANCE_EVASION} {
+		if len(grouped[vector]) == 0 {
+			continue
+		}
+		threats = append(threats, ThreatDetection{
+			Vector:     vector,
+			Severity:   severities[vector],
+			Confidence: 0.85,
+			Evidence:   evidence[vector],
+			Timestamp:  time.Now(),
+			Details: map[string]interface{}{
+				"analysis": "execution_harness",
+				"harness":  c.Harness,
+				"events":   grouped[vector],
+			},
+		})
+	}
+	return threats
+}
@@ -0,0 +1,206 @@
+'use strict';
+// AEGONG Node.js execution harness
+//
+// Preloaded with --require before a JavaScript agent runs inside the sandbox.
+// Wraps require and registers an ESM loader hook to log imported modules, and
+// wraps child_process, fs, net, http(s), dns and the code generation APIs to
+// log spawned processes, file writes, outbound connections and requests and
+// dynamic code, as JSON lines for the engine to analyze.
+//
+// When AEGONG_CLOCK is set to "offset:rate", Date starts offset seconds ahead
+// and runs rate times faster, and timers are shortened to match, so delayed
+// behaviour happens while the agent is being audited. Long waits are logged
+// so later actions can be attributed to them.
+//
+// Usage: node --require node_harness.cjs <agent.js>
+
+const childProcess = require('child_process');
+const dns = require('dns');
+const fs = require('fs');
+const http = require('http');
+const https = require('https');
+const Module = require('module');
+const net = require('net');
+const path = require('path');
+const vm = require('vm');
+const { isMainThread } = require('worker_threads');
+
+const EVENTS_PATH = path.join(__dirname, 'harness_events.jsonl');
+const MAX_EVENTS = 500;
+// Perceived waits shorter than this are not logged
+const MIN_WAIT_MS = 60 * 1000;
+
+const appendFileSync = fs.appendFileSync;
+const seen = new Set();
+let depth = 0;
+
+function emit(event, detail) {
+  detail = String(detail).slice(0, 300);
+  const key = event + '\0' + detail;
+  if (seen.has(key) || seen.size >= MAX_EVENTS) return;
+  seen.add(key);
+  // appendFileSync calls the wrapped writeFileSync, which must not log this write
+  depth++;
+  try {
+    appendFileSync(EVENTS_PATH, JSON.stringify({ event, detail }) + '\n');
+  } catch (e) {
+    // The agent may have removed the file; keep running
+  } finally {
+    depth--;
+  }
+}
+
+// wrap logs calls to object[name]; calls made by a wrapped function itself,
+// such as exec calling execFile, are not logged again
+function wrap(object, name, event, describe) {
+  const original = object && object[name];
+  if (typeof original !== 'function') return;
+  const wrapped = function (...args) {
+    if (depth === 0) {
+      try {
+        const detail = describe(args);
+        if (detail !== undefined) emit(event, detail);
+      } catch (e) {
+        // Never let the harness change the agent's behaviour
+      }
+    }
+    depth++;
+    try {
+      return new.target ? Reflect.construct(original, args, new.target) : original.apply(this, args);
+    } finally {
+      depth--;
+    }
+  };
+  Object.defineProperty(wrapped, 'name', { value: original.name });
+  wrapped.prototype = original.prototype;
+  object[name] = wrapped;
+}
+
+const first = (args) => args[0];
+const command = (args) => (Array.isArray(args[1]) ? [args[0], ...args[1]].join(' ') : args[0]);
+const writeFlags = (args) => (/[wa+]/.test(String(args[1] || '')) ? args[0] : undefined);
+
+function target(args) {
+  const options = args[0];
+  if (typeof options === 'string' || options instanceof URL) return String(options);
+  if (options && typeof options === 'object') {
+    const method = options.method || 'GET';
+    const host = options.hostname || options.host || 'localhost';
+    return `${method} ${options.protocol || 'http:'}//${host}${options.port ? ':' + options.port : ''}${options.path || '/'}`;
+  }
+  return undefined;
+}
+
+function endpoint(args) {
+  const options = Array.isArray(args[0]) ? args[0][0] : args[0];
+  if (options && typeof options === 'object') {
+    return options.path || `${options.host || 'localhost'}:${options.port}`;
+  }
+  return args[1] !== undefined && typeof args[1] !== 'function' ? `${args[1]}:${args[0]}` : String(options);
+}
+
+function instrument() {
+  for (const name of ['exec', 'execSync', 'execFile', 'execFileSync', 'spawn', 'spawnSync', 'fork']) {
+    wrap(childProcess, name, 'subprocess', command);
+  }
+
+  for (const name of ['writeFile', 'writeFileSync', 'appendFile', 'appendFileSync', 'createWriteStream']) {
+    wrap(fs, name, 'file_write', first);
+  }
+  wrap(fs, 'open', 'file_write', writeFlags);
+  wrap(fs, 'openSync', 'file_write', writeFlags);
+  wrap(fs.promises, 'writeFile', 'file_write', first);
+  wrap(fs.promises, 'appendFile', 'file_write', first);
+
+  wrap(net, 'connect', 'connect', endpoint);
+  wrap(net, 'createConnection', 'connect', endpoint);
+  wrap(net.Socket.prototype, 'connect', 'connect', endpoint);
+
+  for (const client of [http, https]) {
+    wrap(client, 'request', 'request', target);
+    wrap(client, 'get', 'request', target);
+  }
+  if (typeof globalThis.fetch === 'function') {
+    wrap(globalThis, 'fetch', 'request', (args) => `${(args[1] && args[1].method) || 'GET'} ${args[0]}`);
+  }
+
+  wrap(dns, 'lookup', 'resolve', first);
+  wrap(dns, 'resolve', 'resolve', first);
+
+  // Wrapping eval makes it an indirect eval, which runs in the global scope
+  wrap(globalThis, 'eval', 'eval', first);
+  wrap(globalThis, 'Function', 'eval', (args) => args[args.length - 1]);
+  for (const name of ['runInThisContext', 'runInNewContext', 'runInContext', 'compileFunction', 'Script']) {
+    wrap(vm, name, 'eval', first);
+  }
+
+  // Modules loaded with require; wrapped last so the harness's own are not logged
+  const load = Module._load;
+  Module._load = function (request, parent, isMain) {
+    if (!isMain && depth === 0) emit('import', request);
+    return load.apply(this, arguments);
+  };
+
+  // Modules loaded with import run through a loader hook in its own thread
+  if (typeof Module.register === 'function') {
+    const hook = `
+      import { appendFileSync } from 'node:fs';
+      const seen = new Set();
+      export async function resolve(specifier, context, next) {
+        if (context.parentURL && !specifier.startsWith('data:') && !seen.has(specifier)) {
+          seen.add(specifier);
+          try { appendFileSync(${JSON.stringify(EVENTS_PATH)}, JSON.stringify({ event: 'import', detail: specifier }) + '\\n'); } catch {}
+        }
+        return next(specifier, context);
+      }`;
+    Module.register('data:text/javascript,' + encodeURIComponent(hook));
+  }
+}
+
+function installClock(spec) {
+  const [offset, rate] = spec.split(':').map(Number);
+  const RealDate = Date;
+  const realNow = RealDate.now;
+  const start = realNow();
+  const now = () => start + offset * 1000 + (realNow() - start) * rate;
+
+  // Date called without new returns the current time as a string
+  function FastDate(...args) {
+    if (!new.target) return new RealDate(now()).toString();
+    return args.length === 0 ? new RealDate(now()) : new RealDate(...args);
+  }
+  FastDate.prototype = RealDate.prototype;
+  FastDate.now = now;
+  FastDate.parse = RealDate.parse;
+  FastDate.UTC = RealDate.UTC;
+  globalThis.Date = FastDate;
+
+  const shorten = (delay) => {
+    const ms = Number(delay) || 0;
+    if (ms >= MIN_WAIT_MS) emit('wait', ms / 1000);
+    return ms / rate;
+  };
+  const timers = require('timers');
+  const timersPromises = require('timers/promises');
+  for (const object of [globalThis, timers, timersPromises]) {
+    for (const name of ['setTimeout', 'setInterval']) {
+      const original = object[name];
+      if (object === timersPromises) {
+        object[name] = (delay, ...rest) => original(shorten(delay), ...rest);
+      } else {
+        object[name] = (callback, delay, ...rest) => original(callback, shorten(delay), ...rest);
+      }
+    }
+  }
+  // Agents importing node:timers see the patched functions too
+  Module.syncBuiltinESMExports();
+}
+
+// Preloads also run in the loader hook's worker thread, which only needs the hook
+if (isMainThread) {
+  if (process.env.AEGONG_CLOCK) {
+    installClock(process.env.AEGONG_CLOCK);
+    delete process.env.AEGONG_CLOCK;
+  }
+  instrument();
+}
@@ -0,0 +1,203 @@
+"""AEGONG Python execution harness
+
+Runs a Python agent inside the sandbox with an audit hook (PEP 578) and a
+trace function installed, logging imported modules, dynamic code execution,
+spawned processes, file writes and outbound connections and requests as
+JSON lines for the engine to analyze.
+
+When AEGONG_CLOCK is set to "offset:rate", the agent's wall clock starts
+offset seconds ahead and runs rate times faster, and its sleeps are shortened
+to match, so delayed behaviour happens while it is being audited. Long waits
+are logged so later actions can be attributed to them.
+
+Usage: python3 -I -B python_harness.py <events file> <agent.py>
+"""
+
+import importlib.machinery
+import json
+import os
+import runpy
+import sys
+import threading
+import time
+
+EVENTS_PATH, AGENT_PATH = sys.argv[1], os.path.abspath(sys.argv[2])
+AGENT_DIR = os.path.dirname(AGENT_PATH) + os.sep
+MAX_EVENTS = 500
+# Perceived waits shorter than this are not logged
+MIN_WAIT_SECONDS = 60
+
+_out = open(EVENTS_PATH, "a", buffering=1)
+_seen = set()
+_emitting = False
+
+
+def _emit(event, detail):
+    global _emitting
+    detail = str(detail)[:300]
+    key = (event, detail)
+    if _emitting or key in _seen or len(_seen) >= MAX_EVENTS:
+        return
+    _seen.add(key)
+    _emitting = True
+    try:
+        _out.write(json.dumps({"event": event, "detail": detail}) + "\n")
+    except Exception:
+        pass
+    finally:
+        _emitting = False
+
+
+def _dynamic(filename):
+    return filename in ("<string>", "<unknown>")
+
+
+def _from_agent():
+    # The standard library builds code dynamically too (namedtuple, dataclasses),
+    # so only count eval and exec called from the agent's own files
+    caller = sys._getframe(2).f_code.co_filename
+    return caller.startswith(AGENT_DIR) and caller != __file__
+
+
+def _audit(name, args):
+    try:
+        if name == "import":
+            _emit("import", args[0])
+        elif name == "compile":
+            source, filename = args[0], args[1]
+            if _dynamic(filename) and source is not None and _from_agent():
+                if isinstance(source, bytes):
+                    source = source.decode("utf-8", "replace")
+                _emit("eval", source)
+        elif name == "exec":
+            code = args[0]
+            if _dynamic(getattr(code, "co_filename", "")) and _from_agent():
+                _emit("exec", code.co_name)
+        elif name == "subprocess.Popen":
+            _emit("subprocess", args[1])
+        elif name in ("os.system", "os.popen"):
+            _emit("subprocess", args[0])
+        elif name in ("os.exec", "os.posix_spawn", "os.spawn"):
+            _emit("subprocess", args[0:2])
+        elif name == "open":
+            path, mode = args[0], args[1]
+            if isinstance(mode, str) and any(c in mode for c in "wax+"):
+                _emit("file_write", path)
+        elif name == "socket.connect":
+            _emit("connect", args[1])
+        elif name == "socket.getaddrinfo":
+            _emit("resolve", args[0])
+        elif name == "urllib.Request":
+            _emit("request", "%s %s" % (args[3], args[0]))
+    except Exception:
+        pass
+
+
+# Client functions whose url argument is the outbound request target
+_REQUEST_FUNCTIONS = {
+    ("requests.api", "request"),
+    ("requests.sessions", "request"),
+    ("httpx._api", "request"),
+    ("httpx._client", "request"),
+    ("aiohttp.client", "_request"),
+    ("http.client", "request"),
+}
+
+
+def _trace(frame, event, arg):
+    if event == "call":
+        key = (frame.f_globals.get("__name__", ""), frame.f_code.co_name)
+        if key in _REQUEST_FUNCTIONS:
+            local = frame.f_locals
+            _emit("request", "%s %s" % (local.get("method", ""), local.get("url", "")))
+    return None
+
+
+class _PatchingFinder:
+    """Patches stdlib modules as the agent imports them, so the harness does
+    not import them first and hide the agent's own imports"""
+
+    def __init__(self, patches):
+        self.patches = patches
+
+    def find_spec(self, name, path, target=None):
+        patch = self.patches.get(name)
+        spec = importlib.machinery.PathFinder.find_spec(name, path) if patch else None
+        if spec is None or spec.loader is None:
+            return None
+        exec_module = spec.loader.exec_module
+
+        def exec_patched(module):
+            exec_module(module)
+            patch(module)
+
+        spec.loader.exec_module = exec_patched
+        return spec
+
+
+def _install_clock(spec):
+    offset, rate = (float(value) for value in spec.split(":"))
+    real_time, real_sleep = time.time, time.sleep
+    start = real_time()
+
+    def now():
+        return start + offset + (real_time() - start) * rate
+
+    def waited(seconds):
+        if seconds >= MIN_WAIT_SECONDS:
+            _emit("wait", "%g" % seconds)
+
+    def sleep(seconds):
+        waited(seconds)
+        real_sleep(seconds / rate)
+
+    def patch_datetime(module):
+        class FastDatetime(module.datetime):
+            @classmethod
+            def now(cls, tz=None):
+                return cls.fromtimestamp(now(), tz)
+
+            @classmethod
+            def utcnow(cls):
+                return cls.fromtimestamp(now(), module.timezone.utc).replace(tzinfo=None)
+
+            @classmethod
+            def today(cls):
+                return cls.fromtimestamp(now())
+
+        class FastDate(module.date):
+            @classmethod
+            def today(cls):
+                return cls.fromtimestamp(now())
+
+        module.datetime, module.date = FastDatetime, FastDate
+
+    def patch_asyncio(module):
+        real_async_sleep = module.sleep
+
+        def async_sleep(delay, result=None):
+            waited(delay)
+            return real_async_sleep(delay / rate, result)
+
+        module.sleep = async_sleep
+
+    time.time = now
+    time.time_ns = lambda: int(now() * 1e9)
+    time.sleep = sleep
+    # asyncio re-exports sleep from asyncio.tasks as the package loads
+    sys.meta_path.insert(0, _PatchingFinder({"datetime": patch_datetime, "asyncio.tasks": patch_asyncio}))
+
+
+# Installed before the audit hook so the harness's own imports are not logged
+if os.environ.get("AEGONG_CLOCK"):
+    _install_clock(os.environ.pop("AEGONG_CLOCK"))
+
+sys.addaudithook(_audit)
+sys.settrace(_trace)
+threading.settrace(_trace)
+
+sys.argv = [AGENT_PATH]
+try:
+    runpy.run_path(AGENT_PATH, run_name="__main__")
+finally:
+    _out.flush()
@@ -0,0 +1,129 @@
+package aegong
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestPythonHarness tests that Python agents run under the harness and their actions are traced
+func TestPythonHarness(t *testing.T) {
+	requireSandbox(t)
+	if pythonHarness.interpreter() == "" {
+		t.Skip("python3 is not installed")
+	}
+	engine := newTestEngine(t)
+
+	container, err := engine.createIsolatedContainer("harness-test")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer engine.destroyContainer(container.ID)
+
+	agent := []byte(`import json
+import subprocess
+
+def plan(goal):
+    return eval("1 + " + str(len(goal)))
+
+print(plan("summarise"))
+subprocess.run(["true"])
+`)
+	executionLog := engine.simulateExecution(context.Background(), agent, container)
+	if container.Harness != "python" {
+		t.Fatalf("Python agent should run under the harness:\n%s", executionLog)
+	}
+
+	events := make(map[string]string)
+	for _, event := range container.HarnessEvents {
+		events[event.Event+":"+event.Detail] = event.Detail
+	}
+	for _, want := range []string{"import:subprocess", "eval:1 + 9", "subprocess:['true']"} {
+		if _, ok := events[want]; !ok {
+			t.Fatalf("Harness should log %s, got %+v\n%s", want, container.HarnessEvents, executionLog)
+		}
+	}
+	if !strings.Contains(executionLog, "Harness Events:") {
+		t.Fatal("Harness events should be added to the execution log for the detectors")
+	}
+
+	threats := container.harnessThreats()
+	vectors := make(map[ThreatVector]ThreatSeverity)
+	for _, threat := range threats {
+		vectors[threat.Vector] = threat.Severity
+	}
+	if vectors[T4_UNAUTHORIZED_ACTION] != HIGH {
+		t.Fatalf("Spawned process should raise a HIGH T4 finding, got %+v", threats)
+	}
+	if _, ok := vectors[T9_GOVERNANCE_EVASION]; !ok {
+		t.Fatalf("Dynamic code should raise a T9 finding, got %+v", threats)
+	}
+}
+
+// TestNodeHarness tests that JavaScript agents run under the harness and their actions are traced
+func TestNodeHarness(t *testing.T) {
+	requireSandbox(t)
+	if nodeHarness.interpreter() == "" {
+		t.Skip("node is not installed")
+	}
+	engine := newTestEngine(t)
+
+	container, err := engine.createIsolatedContainer("node-harness-test")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer engine.destroyContainer(container.ID)
+
+	agent := []byte(`const { execSync } = require('child_process');
+const fs = require('fs');
+
+function plan(goal) {
+  return eval("1 + " + goal.length);
+}
+
+console.log(plan("summarise"));
+execSync("true");
+fs.writeFileSync("notes.txt", "done");
+`)
+	executionLog := engine.simulateExecution(context.Background(), agent, container)
+	if container.Harness != "javascript" {
+		t.Fatalf("JavaScript agent should run under the harness:\n%s", executionLog)
+	}
+
+	events := make(map[string]bool)
+	for _, event := range container.HarnessEvents {
+		events[event.Event+":"+event.Detail] = true
+	}
+	for _, want := range []string{"import:child_process", "eval:1 + 9", "subprocess:true", "file_write:notes.txt"} {
+		if !events[want] {
+			t.Fatalf("Harness should log %s, got %+v\n%s", want, container.HarnessEvents, executionLog)
+		}
+	}
+
+	vectors := make(map[ThreatVector]bool)
+	for _, threat := range container.harnessThreats() {
+		vectors[threat.Vector] = true
+	}
+	if !vectors[T4_UNAUTHORIZED_ACTION] || !vectors[T9_GOVERNANCE_EVASION] {
+		t.Fatalf("Spawned process and eval should raise T4 and T9 findings, got %v", vectors)
+	}
+}
+
+// TestHarnessSelection tests which agents run under a harness
+func TestHarnessSelection(t *testing.T) {
+	if selectHarness([]byte("#!/bin/sh\necho hello\n")) != nil {
+		t.Fatal("Shell scripts should run directly")
+	}
+	if pythonHarness.interpreter() != "" && selectHarness([]byte("import os\n\ndef main():\n    print(os.getcwd())\n")) != pythonHarness {
+		t.Fatal("Python agents should run under the Python harness")
+	}
+
+	if nodeHarness.interpreter() != "" && selectHarness([]byte("const fs = require('fs');\nmodule.exports = () => fs.readdirSync('.');\n")) != nodeHarness {
+		t.Fatal("JavaScript agents should run under the Node harness")
+	}
+
+	t.Setenv("AEGONG_DISABLE_HARNESS", "1")
+	if selectHarness([]byte("import os\n\ndef main():\n    print(os.getcwd())\n")) != nil {
+		t.Fatal("AEGONG_DISABLE_HARNESS should run agents directly")
+	}
+}
@@ -0,0 +1,305 @@
+package aegong
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	honeypotMaxCaptures = 256
+	honeypotMaxPayload  = 64 * 1024
+	honeypotReadTimeout = 10 * time.Second
+)
+
+// Address handed out by the fake DNS resolver (TEST-NET-1, never routable)
+var honeypotSinkholeIP = net.IPv4(192, 0, 2, 1).To4()
+
+// HoneypotCapture records a single egress attempt caught by a fake service
+type HoneypotCapture struct {
+	Service    string    `json:"service"`
+	RemoteAddr string    `json:"remote_addr"`
+	LocalAddr  string    `json:"local_addr"`
+	Timestamp  time.Time `json:"timestamp"`
+	Summary    string    `json:"summary"`
+	Payload    string    `json:"payload,omitempty"`
+	Truncated  bool      `json:"truncated,omitempty"`
+}
+
+// Honeypot runs fake HTTP, DNS and SMTP listeners inside the network
+// namespace of a sandboxed process so its egress attempts succeed locally
+type Honeypot struct {
+	listeners []io.Closer
+	conns     map[net.Conn]struct{}
+	captures  []HoneypotCapture
+	mutex     sync.Mutex
+	wg        sync.WaitGroup
+}
+
+// honeypotEnabled reports whether honeypot listeners should be started
+func honeypotEnabled() bool {
+	return os.Getenv("AEGONG_DISABLE_HONEYPOT") != "1"
+}
+
+func (h *Honeypot) acceptLoop(listener net.Listener, handler func(net.Conn)) {
+	defer h.wg.Done()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		h.mutex.Lock()
+		h.conns[conn] = struct{}{}
+		h.mutex.Unlock()
+
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			defer func() {
+				h.mutex.Lock()
+				delete(h.conns, conn)
+				h.mutex.Unlock()
+				conn.Close()
+			}()
+			conn.SetDeadline(time.Now().Add(honeypotReadTimeout))
+			handler(conn)
+		}()
+	}
+}
+
+// handleHTTP accepts any request and answers with an empty 200 OK
+func (h *Honeypot) handleHTTP(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+
+		body, _ := io.ReadAll(io.LimitReader(req.Body, honeypotMaxPayload+1))
+		req.Body.Close()
+
+		var payload bytes.Buffer
+		req.Header.Write(&payload)
+		payload.WriteString("\r\n")
+		payload.Write(body)
+
+		h.record(HoneypotCapture{
+			Service:    "http",
+			RemoteAddr: conn.RemoteAddr().String(),
+			LocalAddr:  conn.LocalAddr().String(),
+			Summary:    fmt.Sprintf("%s http://%s%s", req.Method, req.Host, req.URL.RequestURI()),
+			Payload:    payload.String(),
+		})
+
+		// Agent protocol clients get a reply they can parse, so they go on
+		// to send the requests worth capturing
+		if reply, ok := protocolReply(req.Method, req.Host, req.URL.Path, body); !ok {
+			conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: 2\r\n\r\nOK"))
+		} else if reply == "" {
+			conn.Write([]byte("HTTP/1.1 202 Accepted\r\nContent-Length: 0\r\n\r\n"))
+		} else {
+			fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(reply), reply)
+		}
+		if req.Close {
+			return
+		}
+	}
+}
+
+// handleSMTP speaks just enough SMTP to receive a message
+func (h *Honeypot) handleSMTP(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	var transcript bytes.Buffer
+	var sender string
+	var recipients []string
+
+	reply("220 mail.aegong.local ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		transcript.WriteString(line)
+
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "HELO"), strings.HasPrefix(command, "EHLO"):
+			reply("250 mail.aegong.local")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			sender = strings.TrimSpace(line[len("MAIL FROM:"):])
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			recipients = append(recipients, strings.TrimSpace(line[len("RCPT TO:"):]))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil || strings.TrimRight(dataLine, "\r\n") == "." {
+					break
+				}
+				transcript.WriteString(dataLine)
+			}
+			reply("250 OK: queued")
+		case command == "QUIT":
+			reply("221 Bye")
+			h.recordSMTP(conn, sender, recipients, transcript.String())
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+
+	h.recordSMTP(conn, sender, recipients, transcript.String())
+}
+
+func (h *Honeypot) recordSMTP(conn net.Conn, sender string, recipients []string, transcript string) {
+	if transcript == "" {
+		return
+	}
+	h.record(HoneypotCapture{
+		Service:    "smtp",
+		RemoteAddr: conn.RemoteAddr().String(),
+		LocalAddr:  conn.LocalAddr().String(),
+		Summary:    fmt.Sprintf("MAIL FROM %s TO %s", sender, strings.Join(recipients, ", ")),
+		Payload:    transcript,
+	})
+}
+
+// serveDNS answers every A query with the sinkhole address
+func (h *Honeypot) serveDNS(conn net.PacketConn) {
+	defer h.wg.Done()
+	buffer := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+
+		query := make([]byte, n)
+		copy(query, buffer[:n])
+
+		name, qtype, questionEnd, ok := parseDNSQuestion(query)
+		if !ok {
+			continue
+		}
+
+		h.record(HoneypotCapture{
+			Service:    "dns",
+			RemoteAddr: addr.String(),
+			LocalAddr:  conn.LocalAddr().String(),
+			Summary:    fmt.Sprintf("query %s (type %d)", name, qtype),
+		})
+
+		conn.WriteTo(buildDNSResponse(query[:questionEnd], qtype), addr)
+	}
+}
+
+// parseDNSQuestion extracts the first question from a DNS query
+func parseDNSQuestion(msg []byte) (string, uint16, int, bool) {
+	if len(msg) < 12 || binary.BigEndian.Uint16(msg[4:6]) == 0 {
+		return "", 0, 0, false
+	}
+
+	var labels []string
+	offset := 12
+	for {
+		if offset >= len(msg) {
+			return "", 0, 0, false
+		}
+		length := int(msg[offset])
+		offset++
+		if length == 0 {
+			break
+		}
+		if length > 63 || offset+length > len(msg) {
+			return "", 0, 0, false
+		}
+		labels = append(labels, string(msg[offset:offset+length]))
+		offset += length
+	}
+
+	if offset+4 > len(msg) {
+		return "", 0, 0, false
+	}
+	qtype := binary.BigEndian.Uint16(msg[offset : offset+2])
+	return strings.Join(labels, "."), qtype, offset + 4, true
+}
+
+// buildDNSResponse turns a query (header + single question) into an answer
+func buildDNSResponse(query []byte, qtype uint16) []byte {
+	response := make([]byte, len(query))
+	copy(response, query)
+
+	// QR=1, opcode from query, AA=1, RD copied, RA=1, RCODE=0
+	response[2] = 0x84 | (query[2] & 0x79)
+	response[3] = 0x80
+	binary.BigEndian.PutUint16(response[4:6], 1)
+	binary.BigEndian.PutUint16(response[8:10], 0)
+	binary.BigEndian.PutUint16(response[10:12], 0)
+
+	if qtype != 1 {
+		binary.BigEndian.PutUint16(response[6:8], 0)
+		return response
+	}
+
+	binary.BigEndian.PutUint16(response[6:8], 1)
+	answer := []byte{
+		0xC0, 0x0C, // pointer to the question name
+		0x00, 0x01, // type A
+		0x00, 0x01, // class IN
+		0x00, 0x00, 0x00, 0x3C, // TTL 60
+		0x00, 0x04, // RDLENGTH
+	}
+	answer = append(answer, honeypotSinkholeIP...)
+	return append(response, answer...)
+}
+
+func (h *Honeypot) record(capture HoneypotCapture) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.captures) >= honeypotMaxCaptures {
+		return
+	}
+
+	capture.Timestamp = time.Now()
+	if len(capture.Payload) > honeypotMaxPayload {
+		capture.Payload = capture.Payload[:honeypotMaxPayload]
+		capture.Truncated = true
+	}
+	h.captures = append(h.captures, capture)
+}
+
+// Stop closes all listeners and returns everything that was captured
+func (h *Honeypot) Stop() []HoneypotCapture {
+	for _, l := range h.listeners {
+		l.Close()
+	}
+
+	// Don't let a connection the agent left open hold up the audit
+	h.mutex.Lock()
+	for conn := range h.conns {
+		conn.Close()
+	}
+	h.mutex.Unlock()
+
+	h.wg.Wait()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.captures
+}
@@ -0,0 +1,234 @@
+//go:build linux
+
+package aegong
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// StartHoneypot enters the network namespace of pid, brings up loopback,
+// routes every IPv4 destination but the internal ranges to it and starts the
+// fake services
+func StartHoneypot(pid int) (*Honeypot, error) {
+	h := &Honeypot{conns: make(map[net.Conn]struct{})}
+
+	// Namespace switching is per-thread, so do it on a dedicated locked thread
+	errChan := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		origNS, err := os.Open(fmt.Sprintf("/proc/%d/task/%d/ns/net", os.Getpid(), syscall.Gettid()))
+		if err != nil {
+			runtime.UnlockOSThread()
+			errChan <- fmt.Errorf("failed to open current network namespace: %v", err)
+			return
+		}
+		defer origNS.Close()
+
+		targetNS, err := os.Open(fmt.Sprintf("/proc/%d/ns/net", pid))
+		if err != nil {
+			runtime.UnlockOSThread()
+			errChan <- fmt.Errorf("failed to open sandbox network namespace: %v", err)
+			return
+		}
+		defer targetNS.Close()
+
+		if err := setns(targetNS.Fd()); err != nil {
+			runtime.UnlockOSThread()
+			errChan <- fmt.Errorf("failed to enter sandbox network namespace: %v", err)
+			return
+		}
+
+		setupErr := h.setupInNamespace()
+
+		// Only release the thread if we made it back to the original namespace,
+		// otherwise the runtime discards it when this goroutine exits
+		if err := setns(origNS.Fd()); err != nil {
+			log.Printf("Failed to restore network namespace, discarding thread: %v", err)
+		} else {
+			runtime.UnlockOSThread()
+		}
+
+		errChan <- setupErr
+	}()
+
+	if err := <-errChan; err != nil {
+		h.Stop()
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func setns(fd uintptr) error {
+	if _, _, errno := syscall.RawSyscall(sysSetns, fd, syscall.CLONE_NEWNET, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// setupInNamespace must run on a thread that is inside the sandbox namespace
+func (h *Honeypot) setupInNamespace() error {
+	if err := bringUpLoopback(); err != nil {
+		return fmt.Errorf("failed to bring up loopback: %v", err)
+	}
+
+	if err := addAnyIPRoute(); err != nil {
+		return fmt.Errorf("failed to add catch-all route: %v", err)
+	}
+	if err := addBlockedEgressRoutes(); err != nil {
+		return fmt.Errorf("failed to block internal egress: %v", err)
+	}
+
+	// Sockets stay bound to the namespace they were created in
+	httpListener, err := net.Listen("tcp4", "0.0.0.0:80")
+	if err != nil {
+		return fmt.Errorf("failed to start HTTP honeypot: %v", err)
+	}
+	h.listeners = append(h.listeners, httpListener)
+
+	smtpListener, err := net.Listen("tcp4", "0.0.0.0:25")
+	if err != nil {
+		return fmt.Errorf("failed to start SMTP honeypot: %v", err)
+	}
+	h.listeners = append(h.listeners, smtpListener)
+
+	dnsConn, err := net.ListenPacket("udp4", "0.0.0.0:53")
+	if err != nil {
+		return fmt.Errorf("failed to start DNS honeypot: %v", err)
+	}
+	h.listeners = append(h.listeners, dnsConn)
+
+	h.wg.Add(3)
+	go h.acceptLoop(httpListener, h.handleHTTP)
+	go h.acceptLoop(smtpListener, h.handleSMTP)
+	go h.serveDNS(dnsConn)
+
+	return nil
+}
+
+// bringUpLoopback sets IFF_UP on lo, which starts out down in a new namespace
+func bringUpLoopback() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	var ifr struct {
+		Name  [syscall.IFNAMSIZ]byte
+		Flags uint16
+		_     [22]byte
+	}
+	copy(ifr.Name[:], "lo")
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCGIFFLAGS, uintptr(unsafe.Pointer(&ifr))); errno != 0 {
+		return errno
+	}
+	ifr.Flags |= syscall.IFF_UP
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&ifr))); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// addAnyIPRoute installs "local 0.0.0.0/0 dev lo" so that connections to any
+// IPv4 address terminate on the honeypot listeners
+func addAnyIPRoute() error {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		return err
+	}
+	return addIPv4Route(netip.MustParsePrefix("0.0.0.0/0"), syscall.RTN_LOCAL, syscall.RT_SCOPE_HOST, lo.Index)
+}
+
+// addBlockedEgressRoutes installs "prohibit" routes for the internal IPv4
+// ranges. They are more specific than the catch-all route, so connections to
+// them fail with EACCES instead of reaching the honeypot.
+func addBlockedEgressRoutes() error {
+	for _, blocked := range blockedEgressRanges {
+		if !blocked.prefix.Addr().Is4() {
+			continue
+		}
+		if err := addIPv4Route(blocked.prefix, syscall.RTN_PROHIBIT, syscall.RT_SCOPE_UNIVERSE, 0); err != nil {
+			return fmt.Errorf("%s: %v", blocked.prefix, err)
+		}
+	}
+	return nil
+}
+
+// addIPv4Route adds a route to the local table over netlink, through the
+// interface with index oif unless it is 0
+func addIPv4Route(dst netip.Prefix, routeType, scope uint8, oif int) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return err
+	}
+
+	// nlmsghdr + rtmsg + RTA_DST [+ RTA_OIF]
+	var msg bytes.Buffer
+	length := syscall.SizeofNlMsghdr + syscall.SizeofRtMsg + syscall.SizeofRtAttr + 4
+	if oif != 0 {
+		length += syscall.SizeofRtAttr + 4
+	}
+	binary.Write(&msg, binary.LittleEndian, syscall.NlMsghdr{
+		Len:   uint32(length),
+		Type:  syscall.RTM_NEWROUTE,
+		Flags: syscall.NLM_F_REQUEST | syscall.NLM_F_CREATE | syscall.NLM_F_EXCL | syscall.NLM_F_ACK,
+		Seq:   1,
+	})
+	binary.Write(&msg, binary.LittleEndian, syscall.RtMsg{
+		Family:   syscall.AF_INET,
+		Dst_len:  uint8(dst.Bits()),
+		Table:    syscall.RT_TABLE_LOCAL,
+		Protocol: syscall.RTPROT_BOOT,
+		Scope:    scope,
+		Type:     routeType,
+	})
+	binary.Write(&msg, binary.LittleEndian, syscall.RtAttr{Len: syscall.SizeofRtAttr + 4, Type: syscall.RTA_DST})
+	addr := dst.Addr().As4()
+	msg.Write(addr[:])
+	if oif != 0 {
+		binary.Write(&msg, binary.LittleEndian, syscall.RtAttr{Len: syscall.SizeofRtAttr + 4, Type: syscall.RTA_OIF})
+		binary.Write(&msg, binary.LittleEndian, uint32(oif))
+	}
+
+	if err := syscall.Sendto(fd, msg.Bytes(), 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return err
+	}
+
+	// Read the kernel's acknowledgement
+	reply := make([]byte, 4096)
+	n, _, err := syscall.Recvfrom(fd, reply, 0)
+	if err != nil {
+		return err
+	}
+	messages, err := syscall.ParseNetlinkMessage(reply[:n])
+	if err != nil {
+		return err
+	}
+	for _, m := range messages {
+		if m.Header.Type == syscall.NLMSG_ERROR && len(m.Data) >= 4 {
+			if code := int32(binary.LittleEndian.Uint32(m.Data[0:4])); code != 0 {
+				return syscall.Errno(-code)
+			}
+		}
+	}
+
+	return nil
+}
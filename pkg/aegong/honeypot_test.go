@@ -0,0 +1,76 @@
+package aegong
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// buildTestDNSQuery builds a minimal DNS query for the given name and type
+func buildTestDNSQuery(name string, qtype uint16) []byte {
+	query := []byte{0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	for _, label := range splitLabels(name) {
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+	query = append(query, 0x00, byte(qtype>>8), byte(qtype), 0x00, 0x01)
+	return query
+}
+
+func splitLabels(name string) []string {
+	var labels []string
+	start := 0
+	for i := 0; i <= len(name); i++ {
+		if i == len(name) || name[i] == '.' {
+			labels = append(labels, name[start:i])
+			start = i + 1
+		}
+	}
+	return labels
+}
+
+// TestHoneypotDNSRoundTrip tests that A queries are answered with the sinkhole address
+func TestHoneypotDNSRoundTrip(t *testing.T) {
+	query := buildTestDNSQuery("exfil.example.com", 1)
+
+	name, qtype, questionEnd, ok := parseDNSQuestion(query)
+	if !ok {
+		t.Fatal("Query should parse")
+	}
+	if name != "exfil.example.com" || qtype != 1 {
+		t.Fatalf("Unexpected question: %s (type %d)", name, qtype)
+	}
+
+	response := buildDNSResponse(query[:questionEnd], qtype)
+
+	if binary.BigEndian.Uint16(response[0:2]) != 0x1234 {
+		t.Fatal("Response should keep the query ID")
+	}
+	if response[2]&0x80 == 0 {
+		t.Fatal("Response should have the QR bit set")
+	}
+	if binary.BigEndian.Uint16(response[6:8]) != 1 {
+		t.Fatal("Response should contain exactly one answer")
+	}
+	if !net.IP(response[len(response)-4:]).Equal(honeypotSinkholeIP) {
+		t.Fatalf("Answer should be the sinkhole address, got %v", net.IP(response[len(response)-4:]))
+	}
+
+	// Non-A queries get an empty answer section
+	aaaa := buildTestDNSQuery("exfil.example.com", 28)
+	_, qtype, questionEnd, _ = parseDNSQuestion(aaaa)
+	if answers := binary.BigEndian.Uint16(buildDNSResponse(aaaa[:questionEnd], qtype)[6:8]); answers != 0 {
+		t.Fatalf("AAAA query should have no answers, got %d", answers)
+	}
+}
+
+// TestHoneypotDNSMalformed tests that truncated queries are rejected
+func TestHoneypotDNSMalformed(t *testing.T) {
+	query := buildTestDNSQuery("example.com", 1)
+
+	for _, length := range []int{0, 5, 12, 15, len(query) - 1} {
+		if _, _, _, ok := parseDNSQuestion(query[:length]); ok {
+			t.Fatalf("Truncated query of length %d should not parse", length)
+		}
+	}
+}
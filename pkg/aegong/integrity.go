@@ -0,0 +1,187 @@
+package aegong
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// An agent's data and config files are its long-term memory: an agent that
+// rewrites its own prompts, knowledge base or settings poisons every later
+// run. The manifest can register those files with the hashes they must
+// keep, and the directories that hold them. The tracer reports every call
+// that would create, change or remove a registered file or anything in a
+// registered directory, and after the run registered files the agent left in
+// its working directory are hashed, catching changes made by processes the
+// tracer does not follow.
+
+// Most integrity violations recorded per run
+const maxIntegrityViolations = 20
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// IntegrityAllowlist registers an agent's data and config files. Paths are
+// relative to the agent's working directory, or absolute.
+type IntegrityAllowlist struct {
+	// Files maps paths to their expected SHA-256 in hex
+	Files map[string]string `json:"files,omitempty"`
+	// Directories the agent must not change anything in
+	Directories []string `json:"directories,omitempty"`
+}
+
+// IntegrityViolation is a registered file the agent changed or tried to change
+type IntegrityViolation struct {
+	Path     string `json:"path"`
+	Syscall  string `json:"syscall,omitempty"`  // The traced call; empty when found by hashing after the run
+	Expected string `json:"expected,omitempty"` // Registered hash, for registered files
+	Actual   string `json:"actual,omitempty"`   // Hash after the run
+	Error    string `json:"error,omitempty"`    // Why the kernel refused the call; empty if it succeeded
+}
+
+// cleanRegisteredPath normalizes a registered path, rejecting those that
+// leave the working directory
+func cleanRegisteredPath(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("empty path in integrity allowlist")
+	}
+	cleaned := path.Clean(name)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("integrity allowlist path %q leaves the working directory", name)
+	}
+	return cleaned, nil
+}
+
+// validate checks and normalizes the allowlist's paths and hashes
+func (a *IntegrityAllowlist) validate() error {
+	files := make(map[string]string, len(a.Files))
+	for name, hash := range a.Files {
+		cleaned, err := cleanRegisteredPath(name)
+		if err != nil {
+			return err
+		}
+		hash = strings.ToLower(hash)
+		if !sha256Pattern.MatchString(hash) {
+			return fmt.Errorf("integrity allowlist hash of %s is not a SHA-256", name)
+		}
+		files[cleaned] = hash
+	}
+	a.Files = files
+	for i, dir := range a.Directories {
+		cleaned, err := cleanRegisteredPath(dir)
+		if err != nil {
+			return err
+		}
+		a.Directories[i] = cleaned
+	}
+	return nil
+}
+
+// protects reports whether a path the agent used, already made relative to
+// its working directory where it could be, is registered
+func (a *IntegrityAllowlist) protects(name string) bool {
+	name = path.Clean(name)
+	if _, ok := a.Files[name]; ok {
+		return true
+	}
+	for _, dir := range a.Directories {
+		if name == dir || dir == "." && !path.IsAbs(name) || strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// workingPath makes a path the agent used relative to root, its working
+// directory, if it is inside it
+func workingPath(root, name string) string {
+	name = path.Clean(name)
+	root = path.Clean(filepath.ToSlash(root))
+	if name == root {
+		return "."
+	}
+	if path.IsAbs(name) && strings.HasPrefix(name, root+"/") {
+		return name[len(root)+1:]
+	}
+	return name
+}
+
+// hashFile returns the SHA-256 of a file in hex
+func hashFile(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// changedFiles hashes the registered files the agent left in its working
+// directory, returning those that no longer match
+func (a *IntegrityAllowlist) changedFiles(root string) []IntegrityViolation {
+	var names []string
+	for name := range a.Files {
+		if !path.IsAbs(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var violations []IntegrityViolation
+	for _, name := range names {
+		actual, err := hashFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			continue
+		}
+		if actual != a.Files[name] {
+			violations = append(violations, IntegrityViolation{Path: name, Expected: a.Files[name], Actual: actual})
+		}
+	}
+	return violations
+}
+
+// integrityThreats reports changes to the agent's registered files as T3
+// memory poisoning, CRITICAL when any change went through
+func (c *CustomContainer) integrityThreats() []ThreatDetection {
+	if len(c.IntegrityViolations) == 0 {
+		return nil
+	}
+
+	severity := HIGH
+	var evidence []string
+	for _, violation := range c.IntegrityViolations {
+		switch {
+		case violation.Syscall == "":
+			evidence = append(evidence, fmt.Sprintf("Registered file %s changed: expected SHA-256 %s, found %s", violation.Path, violation.Expected, violation.Actual))
+			severity = CRITICAL
+		case violation.Error != "":
+			evidence = append(evidence, fmt.Sprintf("Registered path %s: %s failed: %s", violation.Path, violation.Syscall, violation.Error))
+		default:
+			evidence = append(evidence, fmt.Sprintf("Registered path %s modified by %s", violation.Path, violation.Syscall))
+			severity = CRITICAL
+		}
+	}
+
+	return []ThreatDetection{{
+		Vector:     T3_MEMORY_POISONING,
+		Severity:   severity,
+		Confidence: 0.9,
+		Evidence:   evidence,
+		Timestamp:  time.Now(),
+		Details: map[string]interface{}{
+			"analysis":   "integrity",
+			"violations": c.IntegrityViolations,
+		},
+	}}
+}
@@ -0,0 +1,75 @@
+//go:build linux
+
+package aegong
+
+import "syscall"
+
+// modifiedPathArgs returns the arguments holding the paths a syscall creates,
+// changes or removes
+func modifiedPathArgs(call *tracedSyscall) []uint64 {
+	args := call.Args
+	switch call.Name {
+	case "open":
+		if args[1]&openWriteFlags != 0 {
+			return []uint64{args[0]}
+		}
+	case "openat":
+		if args[2]&openWriteFlags != 0 {
+			return []uint64{args[1]}
+		}
+	case "creat", "truncate", "unlink", "rmdir", "mkdir", "mknod":
+		return []uint64{args[0]}
+	case "rename":
+		return []uint64{args[0], args[1]}
+	case "link", "symlink", "unlinkat", "mkdirat", "mknodat":
+		return []uint64{args[1]}
+	case "renameat", "renameat2":
+		return []uint64{args[1], args[3]}
+	case "symlinkat":
+		return []uint64{args[2]}
+	case "linkat":
+		return []uint64{args[3]}
+	}
+	return nil
+}
+
+// integrityTracer watches a traced agent's syscalls for changes to its
+// registered files. It is only called from the tracer thread.
+type integrityTracer struct {
+	allowlist  *IntegrityAllowlist // nil when the agent registers no files
+	root       string              // The agent's working directory
+	violations []IntegrityViolation
+	pending    int // First violation of the syscall in progress, or -1
+}
+
+func newIntegrityTracer(allowlist *IntegrityAllowlist, root string) *integrityTracer {
+	return &integrityTracer{allowlist: allowlist, root: root, pending: -1}
+}
+
+// syscall inspects one syscall entry of the agent
+func (t *integrityTracer) syscall(pid int, call *tracedSyscall) {
+	t.pending = -1
+	if t.allowlist == nil {
+		return
+	}
+	for _, addr := range modifiedPathArgs(call) {
+		path := workingPath(t.root, readTraceeString(pid, uintptr(addr)))
+		if !t.allowlist.protects(path) || len(t.violations) >= maxIntegrityViolations {
+			continue
+		}
+		if t.pending < 0 {
+			t.pending = len(t.violations)
+		}
+		t.violations = append(t.violations, IntegrityViolation{Path: path, Syscall: call.Name, Expected: t.allowlist.Files[path]})
+	}
+}
+
+// failed records why the kernel refused the syscall in progress
+func (t *integrityTracer) failed(errno syscall.Errno) {
+	if t.pending >= 0 {
+		for i := t.pending; i < len(t.violations); i++ {
+			t.violations[i].Error = errno.Error()
+		}
+		t.pending = -1
+	}
+}
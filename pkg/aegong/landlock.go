@@ -0,0 +1,50 @@
+package aegong
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Most denied accesses recorded per audit
+const maxDeniedAccesses = 64
+
+// DeniedAccess records a filesystem write the sandbox refused
+type DeniedAccess struct {
+	Syscall string `json:"syscall"`
+	Path    string `json:"path"`
+	Error   string `json:"error"`
+}
+
+// landlockEnabled reports whether the sandboxed process should be Landlocked
+func landlockEnabled() bool {
+	return os.Getenv("AEGONG_DISABLE_LANDLOCK") != "1"
+}
+
+// deniedAccessThreat turns writes the sandbox refused into T4 evidence
+func (c *CustomContainer) deniedAccessThreat() []ThreatDetection {
+	if len(c.DeniedAccesses) == 0 {
+		return nil
+	}
+
+	var evidence []string
+	for _, denied := range c.DeniedAccesses {
+		evidence = append(evidence, fmt.Sprintf("Denied %s of %s: %s", denied.Syscall, denied.Path, denied.Error))
+		if len(evidence) == 10 {
+			break
+		}
+	}
+
+	return []ThreatDetection{{
+		Vector:     T4_UNAUTHORIZED_ACTION,
+		Severity:   HIGH,
+		Confidence: 0.8,
+		Evidence:   evidence,
+		Timestamp:  time.Now(),
+		Details: map[string]interface{}{
+			"analysis":          "sandbox_denials",
+			"landlock_enforced": c.Landlocked,
+			"denied_accesses":   c.DeniedAccesses,
+		},
+	}}
+}
@@ -0,0 +1,191 @@
+//go:build linux
+
+package aegong
+
+import (
+	"bytes"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Landlock syscalls and flags (not exported by the syscall package)
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1
+	landlockRulePathBeneath      = 1
+
+	prSetNoNewPrivs = 38
+
+	oPath = 0x200000
+)
+
+// Landlock filesystem access rights
+const (
+	landlockExecute    = 1 << 0
+	landlockWriteFile  = 1 << 1
+	landlockReadFile   = 1 << 2
+	landlockReadDir    = 1 << 3
+	landlockRemoveDir  = 1 << 4
+	landlockRemoveFile = 1 << 5
+	landlockMakeChar   = 1 << 6
+	landlockMakeDir    = 1 << 7
+	landlockMakeReg    = 1 << 8
+	landlockMakeSock   = 1 << 9
+	landlockMakeFifo   = 1 << 10
+	landlockMakeBlock  = 1 << 11
+	landlockMakeSym    = 1 << 12
+	landlockRefer      = 1 << 13 // ABI 2
+	landlockTruncate   = 1 << 14 // ABI 3
+
+	landlockReadAccess = landlockExecute | landlockReadFile | landlockReadDir
+)
+
+type landlockRulesetAttr struct {
+	handledAccessFS uint64
+}
+
+// Matches the kernel's packed struct; only the first 12 bytes are read
+type landlockPathBeneathAttr struct {
+	allowedAccess uint64
+	parentFd      int32
+}
+
+// restrictSandboxThread sets no-new-privs on the calling thread and, when the
+// kernel supports it, a Landlock ruleset that makes the filesystem read-only
+// except for containerDir. Both are inherited by processes the thread starts,
+// so the thread must never be unlocked and reused by other goroutines.
+func restrictSandboxThread(containerDir string) error {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("failed to set no-new-privs: %v", errno)
+	}
+	if !landlockEnabled() {
+		return fmt.Errorf("landlock disabled by AEGONG_DISABLE_LANDLOCK")
+	}
+
+	abi, _, errno := syscall.RawSyscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		return fmt.Errorf("landlock is not supported by this kernel: %v", errno)
+	}
+
+	handled := uint64(landlockExecute | landlockWriteFile | landlockReadFile | landlockReadDir |
+		landlockRemoveDir | landlockRemoveFile | landlockMakeChar | landlockMakeDir | landlockMakeReg |
+		landlockMakeSock | landlockMakeFifo | landlockMakeBlock | landlockMakeSym)
+	if abi >= 2 {
+		handled |= landlockRefer
+	}
+	if abi >= 3 {
+		handled |= landlockTruncate
+	}
+
+	attr := landlockRulesetAttr{handledAccessFS: handled}
+	fd, _, errno := syscall.RawSyscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to create landlock ruleset: %v", errno)
+	}
+	ruleset := int(fd)
+	defer syscall.Close(ruleset)
+
+	fileAccess := uint64(landlockReadFile | landlockWriteFile)
+	if abi >= 3 {
+		fileAccess |= landlockTruncate
+	}
+	rules := []struct {
+		path   string
+		access uint64
+	}{
+		{"/", landlockReadAccess},
+		{containerDir, handled},
+		{"/dev/null", fileAccess},
+	}
+	for _, rule := range rules {
+		if err := addLandlockRule(ruleset, rule.path, rule.access); err != nil {
+			return err
+		}
+	}
+
+	if _, _, errno := syscall.RawSyscall(sysLandlockRestrictSelf, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("failed to enforce landlock ruleset: %v", errno)
+	}
+	return nil
+}
+
+func addLandlockRule(ruleset int, path string, access uint64) error {
+	fd, err := syscall.Open(path, oPath|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %s for landlock rule: %v", path, err)
+	}
+	defer syscall.Close(fd)
+
+	attr := landlockPathBeneathAttr{allowedAccess: access, parentFd: int32(fd)}
+	if _, _, errno := syscall.RawSyscall6(sysLandlockAddRule, uintptr(ruleset), landlockRulePathBeneath, uintptr(unsafe.Pointer(&attr)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to add landlock rule for %s: %v", path, errno)
+	}
+	return nil
+}
+
+// deniedWrite returns the path of a failed syscall that tried to modify the
+// filesystem and was refused permission, reading it from the tracee's memory
+func deniedWrite(pid int, call *tracedSyscall, errno syscall.Errno) (*DeniedAccess, bool) {
+	if errno != syscall.EACCES && errno != syscall.EPERM {
+		return nil, false
+	}
+
+	writeFlags := uint64(syscall.O_WRONLY | syscall.O_RDWR | syscall.O_CREAT | syscall.O_TRUNC)
+	var pathAddr uint64
+	switch call.Name {
+	case "open":
+		if call.Args[1]&writeFlags == 0 {
+			return nil, false
+		}
+		pathAddr = call.Args[0]
+	case "openat":
+		if call.Args[2]&writeFlags == 0 {
+			return nil, false
+		}
+		pathAddr = call.Args[1]
+	case "creat", "mkdir", "rmdir", "unlink", "rename", "truncate", "mknod":
+		pathAddr = call.Args[0]
+	case "mkdirat", "unlinkat", "renameat", "renameat2", "mknodat", "link", "symlink":
+		pathAddr = call.Args[1]
+	case "symlinkat":
+		pathAddr = call.Args[2]
+	case "linkat":
+		pathAddr = call.Args[3]
+	default:
+		return nil, false
+	}
+
+	return &DeniedAccess{
+		Syscall: call.Name,
+		Path:    readTraceeString(pid, uintptr(pathAddr)),
+		Error:   errno.Error(),
+	}, true
+}
+
+// readTraceeBytes reads up to n bytes from a stopped tracee
+func readTraceeBytes(pid int, addr uintptr, n int) []byte {
+	out := make([]byte, n)
+	read, _ := syscall.PtracePeekData(pid, addr, out)
+	return out[:read]
+}
+
+// readTraceeString reads a NUL-terminated string from a stopped tracee
+func readTraceeString(pid int, addr uintptr) string {
+	var out []byte
+	word := make([]byte, 8)
+	for len(out) < syscall.PathMax {
+		n, err := syscall.PtracePeekData(pid, addr+uintptr(len(out)), word)
+		if err != nil || n == 0 {
+			break
+		}
+		if i := bytes.IndexByte(word[:n], 0); i >= 0 {
+			return string(append(out, word[:i]...))
+		}
+		out = append(out, word[:n]...)
+	}
+	return string(out)
+}
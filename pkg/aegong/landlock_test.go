@@ -0,0 +1,50 @@
+package aegong
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestLandlockDeniesWritesOutsideContainer tests that writes outside the container are refused and reported
+func TestLandlockDeniesWritesOutsideContainer(t *testing.T) {
+	requireSandbox(t)
+	engine := newTestEngine(t)
+
+	container, err := engine.createIsolatedContainer("landlock-test")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer engine.destroyContainer(container.ID)
+
+	// /tmp is world-writable, so only Landlock stops this write
+	probe := "/tmp/aegong-landlock-probe"
+	os.Remove(probe)
+	defer os.Remove(probe)
+
+	agent := []byte("#!/bin/sh\necho pwned > " + probe + "\necho ok > inside\n")
+	executionLog := engine.simulateExecution(context.Background(), agent, container)
+	if strings.Contains(executionLog, "Filesystem not Landlocked") {
+		t.Skipf("Landlock is not available here:\n%s", executionLog)
+	}
+
+	if _, err := os.Stat(probe); err == nil {
+		t.Fatal("Write outside the container should have been denied")
+	}
+	if !container.Landlocked {
+		t.Fatal("Container should be recorded as Landlocked")
+	}
+
+	threats := container.deniedAccessThreat()
+	if len(threats) != 1 || threats[0].Vector != T4_UNAUTHORIZED_ACTION {
+		t.Fatalf("Denied write should raise a T4 finding, got %+v", threats)
+	}
+	denied := container.DeniedAccesses[0]
+	if denied.Path != probe || denied.Syscall != "openat" {
+		t.Fatalf("Denied access should record the syscall and path, got %+v", denied)
+	}
+	if strings.Contains(executionLog, "inside: Permission denied") {
+		t.Fatalf("Writes inside the container should be allowed, got:\n%s", executionLog)
+	}
+}
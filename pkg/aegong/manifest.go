@@ -0,0 +1,224 @@
+package aegong
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Permissions an agent manifest can declare
+const (
+	PermissionNetwork     = "network"      // Outbound connections and requests
+	PermissionSubprocess  = "subprocess"   // Spawning other programs
+	PermissionFileWrite   = "file_write"   // Writing files
+	PermissionDynamicCode = "dynamic_code" // eval, exec and runtime generated code
+)
+
+var knownPermissions = map[string]bool{
+	PermissionNetwork:     true,
+	PermissionSubprocess:  true,
+	PermissionFileWrite:   true,
+	PermissionDynamicCode: true,
+}
+
+// AgentManifest is the agent card a publisher supplies with an upload,
+// declaring what the agent is and what it is allowed to do
+type AgentManifest struct {
+	Name        string   `json:"name,omitempty"`
+	Publisher   string   `json:"publisher,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	Tools       []string `json:"tools,omitempty"` // Programs the agent may run
+	Permissions []string `json:"permissions,omitempty"`
+	// Packages the agent needs, as pip or npm requirement specs
+	Dependencies []string `json:"dependencies,omitempty"`
+	// Integrity registers the agent's data and config files with the hashes
+	// they must keep while it runs
+	Integrity *IntegrityAllowlist `json:"integrity,omitempty"`
+}
+
+// ManifestCheck compares a manifest with what the audit observed
+type ManifestCheck struct {
+	Declared              AgentManifest       `json:"declared"`
+	Observed              map[string][]string `json:"observed"` // Permission to example evidence
+	UndeclaredPermissions []string            `json:"undeclared_permissions"`
+	UndeclaredTools       []string            `json:"undeclared_tools,omitempty"`
+	UnusedPermissions     []string            `json:"unused_permissions,omitempty"` // Declared but never observed
+}
+
+// ParseAgentManifest decodes and validates an agent manifest
+func ParseAgentManifest(data []byte) (*AgentManifest, error) {
+	var manifest AgentManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse agent manifest: %v", err)
+	}
+	for _, permission := range manifest.Permissions {
+		if !knownPermissions[permission] {
+			return nil, fmt.Errorf("unknown permission %q in agent manifest", permission)
+		}
+	}
+	if manifest.Integrity != nil {
+		if err := manifest.Integrity.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &manifest, nil
+}
+
+// Undeclared permissions that let an agent act beyond its sandbox rank higher
+var undeclaredSeverity = map[string]ThreatSeverity{
+	PermissionNetwork:     MEDIUM,
+	PermissionSubprocess:  HIGH,
+	PermissionFileWrite:   LOW,
+	PermissionDynamicCode: HIGH,
+}
+
+// Most evidence kept per observed permission
+const maxObservedEvidence = 5
+
+// observedCapabilities collects the permissions an audit saw the agent use
+func observedCapabilities(threats []ThreatDetection, captures []HoneypotCapture) (map[string][]string, []string) {
+	observed := make(map[string][]string)
+	var programs []string
+	observe := func(permission, evidence string) {
+		if len(observed[permission]) < maxObservedEvidence {
+			observed[permission] = append(observed[permission], evidence)
+		}
+	}
+
+	for _, threat := range threats {
+		switch threat.Details["analysis"] {
+		case "execution_harness":
+			var events []HarnessEvent
+			detailAs(threat.Details, "events", &events)
+			for _, event := range events {
+				switch event.Event {
+				case "subprocess":
+					observe(PermissionSubprocess, event.Detail)
+					programs = append(programs, programName(event.Detail))
+				case "connect", "request":
+					observe(PermissionNetwork, event.Detail)
+				case "eval", "exec":
+					observe(PermissionDynamicCode, event.Detail)
+				}
+			}
+		case "taint":
+			var flows []TaintFlow
+			detailAs(threat.Details, "taint_flows", &flows)
+			for _, flow := range flows {
+				switch flow.Sink {
+				case taintSinkCommand:
+					observe(PermissionSubprocess, flow.Call)
+				case taintSinkCode:
+					observe(PermissionDynamicCode, flow.Call)
+				case taintSinkFileWrite:
+					observe(PermissionFileWrite, flow.Call)
+				}
+			}
+		case "sandbox_denials":
+			for _, evidence := range threat.Evidence {
+				observe(PermissionFileWrite, evidence)
+			}
+		}
+	}
+	for _, capture := range captures {
+		observe(PermissionNetwork, fmt.Sprintf("%s: %s", capture.Service, capture.Summary))
+	}
+	return observed, programs
+}
+
+// detailAs decodes a threat detail into out; details of cached threats have
+// been through JSON and lost their Go types
+func detailAs(details map[string]interface{}, key string, out interface{}) bool {
+	value, ok := details[key]
+	if !ok {
+		return false
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, out) == nil
+}
+
+// programName extracts the program from a logged command such as
+// "['git', 'log']" or "git log"
+func programName(command string) string {
+	command = strings.TrimLeft(command, "([ '\"")
+	if end := strings.IndexAny(command, " '\",)]"); end >= 0 {
+		command = command[:end]
+	}
+	return filepath.Base(command)
+}
+
+// checkManifest compares a manifest with the observed behaviour and returns
+// the comparison and a T9 finding for anything undeclared
+func checkManifest(manifest *AgentManifest, threats []ThreatDetection, captures []HoneypotCapture) (*ManifestCheck, []ThreatDetection) {
+	observed, programs := observedCapabilities(threats, captures)
+	check := &ManifestCheck{
+		Declared:              *manifest,
+		Observed:              observed,
+		UndeclaredPermissions: []string{},
+	}
+
+	declared := make(map[string]bool)
+	for _, permission := range manifest.Permissions {
+		declared[permission] = true
+		if _, ok := observed[permission]; !ok {
+			check.UnusedPermissions = append(check.UnusedPermissions, permission)
+		}
+	}
+
+	var evidence []string
+	severity := LOW
+	for permission, examples := range observed {
+		if declared[permission] {
+			continue
+		}
+		check.UndeclaredPermissions = append(check.UndeclaredPermissions, permission)
+		severity = max(severity, undeclaredSeverity[permission])
+		for _, example := range examples {
+			evidence = append(evidence, fmt.Sprintf("Undeclared %s: %s", permission, example))
+		}
+	}
+	sort.Strings(check.UndeclaredPermissions)
+	sort.Strings(check.UnusedPermissions)
+	sort.Strings(evidence)
+
+	// Spawned programs must be declared tools when the manifest lists any
+	if len(manifest.Tools) > 0 {
+		tools := make(map[string]bool)
+		for _, tool := range manifest.Tools {
+			tools[tool] = true
+		}
+		seen := make(map[string]bool)
+		for _, program := range programs {
+			if program == "" || tools[program] || seen[program] {
+				continue
+			}
+			seen[program] = true
+			check.UndeclaredTools = append(check.UndeclaredTools, program)
+			evidence = append(evidence, fmt.Sprintf("Undeclared tool: %s", program))
+			severity = max(severity, MEDIUM)
+		}
+		sort.Strings(check.UndeclaredTools)
+	}
+
+	if len(evidence) == 0 {
+		return check, nil
+	}
+	return check, []ThreatDetection{{
+		Vector:     T9_GOVERNANCE_EVASION,
+		Severity:   severity,
+		Confidence: 0.9,
+		Evidence:   evidence,
+		Timestamp:  time.Now(),
+		Details: map[string]interface{}{
+			"analysis":               "agent_manifest",
+			"undeclared_permissions": check.UndeclaredPermissions,
+			"undeclared_tools":       check.UndeclaredTools,
+		},
+	}}
+}
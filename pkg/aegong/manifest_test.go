@@ -0,0 +1,106 @@
+package aegong
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestParseAgentManifest tests manifest decoding and permission validation
+func TestParseAgentManifest(t *testing.T) {
+	manifest, err := ParseAgentManifest([]byte(`{"name": "helper", "publisher": "dev@example.com", "model": "gpt-4o", "tools": ["git"], "permissions": ["network"], "description": "ignored"}`))
+	if err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+	if manifest.Name != "helper" || manifest.Tools[0] != "git" || manifest.Permissions[0] != PermissionNetwork {
+		t.Fatalf("Manifest fields should be decoded, got %+v", manifest)
+	}
+
+	if _, err := ParseAgentManifest([]byte(`{"permissions": ["root"]}`)); err == nil {
+		t.Fatal("Unknown permissions should be rejected")
+	}
+	if _, err := ParseAgentManifest([]byte(`not json`)); err == nil {
+		t.Fatal("Malformed manifests should be rejected")
+	}
+}
+
+// TestCheckManifest tests that undeclared capabilities and tools raise a T9 finding
+func TestCheckManifest(t *testing.T) {
+	threats := []ThreatDetection{
+		{
+			Vector: T4_UNAUTHORIZED_ACTION,
+			Details: map[string]interface{}{
+				"analysis": "execution_harness",
+				"events": []HarnessEvent{
+					{Event: "subprocess", Detail: "['curl', 'http://example.com']"},
+					{Event: "request", Detail: "GET http://example.com"},
+				},
+			},
+		},
+		// Cached threats carry their details as decoded JSON
+		{
+			Vector: T9_GOVERNANCE_EVASION,
+			Details: map[string]interface{}{
+				"analysis": "execution_harness",
+				"events":   []interface{}{map[string]interface{}{"event": "eval", "detail": "1 + 1"}},
+			},
+		},
+	}
+	manifest := &AgentManifest{
+		Tools:       []string{"git"},
+		Permissions: []string{PermissionNetwork, PermissionFileWrite},
+	}
+
+	check, findings := checkManifest(manifest, threats, nil)
+	if len(check.UndeclaredPermissions) != 2 || check.UndeclaredPermissions[0] != PermissionDynamicCode || check.UndeclaredPermissions[1] != PermissionSubprocess {
+		t.Fatalf("Spawning processes and dynamic code should be undeclared, got %v", check.UndeclaredPermissions)
+	}
+	if len(check.UndeclaredTools) != 1 || check.UndeclaredTools[0] != "curl" {
+		t.Fatalf("curl should be an undeclared tool, got %v", check.UndeclaredTools)
+	}
+	if len(check.UnusedPermissions) != 1 || check.UnusedPermissions[0] != PermissionFileWrite {
+		t.Fatalf("file_write should be declared but unused, got %v", check.UnusedPermissions)
+	}
+	if len(findings) != 1 || findings[0].Vector != T9_GOVERNANCE_EVASION || findings[0].Severity != HIGH {
+		t.Fatalf("Undeclared subprocess use should raise a HIGH T9 finding, got %+v", findings)
+	}
+
+	manifest.Tools = append(manifest.Tools, "curl")
+	manifest.Permissions = append(manifest.Permissions, PermissionSubprocess, PermissionDynamicCode)
+	if _, findings := checkManifest(manifest, threats, nil); len(findings) != 0 {
+		t.Fatalf("Fully declared behaviour should raise no findings, got %+v", findings)
+	}
+}
+
+// TestAuditAgentWithManifest tests that manifests are checked against the sandboxed run
+func TestAuditAgentWithManifest(t *testing.T) {
+	if pythonHarness.interpreter() == "" {
+		t.Skip("python3 is not installed")
+	}
+	engine := newTestEngine(t)
+
+	agentPath := filepath.Join(t.TempDir(), "agent.py")
+	agent := "import subprocess\n\ndef act(task):\n    return subprocess.run(['true'])\n\nact('observe the plan and remember it')\n"
+	if err := os.WriteFile(agentPath, []byte(agent), 0644); err != nil {
+		t.Fatalf("Failed to write agent: %v", err)
+	}
+
+	report, err := engine.AuditAgentWithManifest(context.Background(), agentPath, nil, &AgentManifest{Name: "helper"})
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if report.Manifest == nil || len(report.Manifest.UndeclaredPermissions) == 0 {
+		t.Fatalf("Report should list the undeclared subprocess use, got %+v", report.Manifest)
+	}
+
+	found := false
+	for _, threat := range report.Threats {
+		if threat.Details["analysis"] == "agent_manifest" {
+			found = threat.Vector == T9_GOVERNANCE_EVASION && threat.VectorName != ""
+		}
+	}
+	if !found {
+		t.Fatal("Undeclared capabilities should be reported as a named T9 threat")
+	}
+}
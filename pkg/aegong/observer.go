@@ -0,0 +1,51 @@
+package aegong
+
+import (
+	"context"
+	"slices"
+)
+
+// AuditObserver follows an audit while it runs. Either function may be nil;
+// both are called on the auditing goroutine and should not block.
+type AuditObserver struct {
+	PhaseStarted func(phase string)           // PhaseStatic, PhaseDynamic or PhaseShield
+	ThreatFound  func(threat ThreatDetection) // Named as it will appear in the report
+}
+
+type observerKey struct{}
+
+// WithObserver returns a context whose audits report their progress to
+// observer, after any observers ctx already has
+func WithObserver(ctx context.Context, observer *AuditObserver) context.Context {
+	// Clipped so contexts derived from the same parent never share observers
+	observers := append(slices.Clip(observersFrom(ctx)), observer)
+	return context.WithValue(ctx, observerKey{}, observers)
+}
+
+func observersFrom(ctx context.Context) []*AuditObserver {
+	observers, _ := ctx.Value(observerKey{}).([]*AuditObserver)
+	return observers
+}
+
+// phaseStarted reports that an audit phase is about to run
+func phaseStarted(ctx context.Context, phase string) {
+	for _, observer := range observersFrom(ctx) {
+		if observer.PhaseStarted != nil {
+			observer.PhaseStarted(phase)
+		}
+	}
+}
+
+// threatsFound reports the findings of a finished analysis
+func threatsFound(ctx context.Context, threats []ThreatDetection) {
+	for _, observer := range observersFrom(ctx) {
+		if observer.ThreatFound == nil {
+			continue
+		}
+		for _, threat := range threats {
+			threat.VectorName = ThreatName(threat.Vector)
+			threat.SeverityName = SeverityName(threat.Severity)
+			observer.ThreatFound(threat)
+		}
+	}
+}
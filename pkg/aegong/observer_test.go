@@ -0,0 +1,54 @@
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestAuditObserver tests that observers follow an audit's phases and findings
+func TestAuditObserver(t *testing.T) {
+	engine := newTestEngine(t)
+
+	var phases []string
+	var found []ThreatDetection
+	ctx := WithObserver(context.Background(), &AuditObserver{
+		PhaseStarted: func(phase string) { phases = append(phases, phase) },
+		ThreatFound:  func(threat ThreatDetection) { found = append(found, threat) },
+	})
+
+	agent := "import os\n\ncommand = input()\nos.system(command)\n"
+	report, err := engine.Audit(ctx, bytes.NewReader([]byte(agent)))
+	if err != nil {
+		t.Fatalf("Failed to audit agent: %v", err)
+	}
+
+	if strings.Join(phases, ",") != "static,dynamic,shield" {
+		t.Fatalf("Should report every phase in order, got %v", phases)
+	}
+	if len(report.Threats) == 0 || len(found) != len(report.Threats) {
+		t.Fatalf("Should report each of the %d threats once, got %d", len(report.Threats), len(found))
+	}
+	for _, threat := range found {
+		if threat.VectorName == "" || threat.SeverityName == "" {
+			t.Fatalf("Reported threats should be named, got %+v", threat)
+		}
+	}
+
+	// Observers added to a context follow audits along with the ones it had
+	var nested []string
+	phases = nil
+	nestedCtx := WithObserver(ctx, &AuditObserver{PhaseStarted: func(phase string) { nested = append(nested, phase) }})
+	if _, err := engine.Audit(nestedCtx, bytes.NewReader([]byte(agent))); err != nil {
+		t.Fatalf("Failed to audit agent: %v", err)
+	}
+	if len(phases) != 3 || len(nested) != 3 {
+		t.Fatalf("Every observer should follow the audit, got %v and %v", phases, nested)
+	}
+
+	// Audits without an observer are unaffected
+	if _, err := engine.Audit(context.Background(), bytes.NewReader([]byte(agent))); err != nil {
+		t.Fatalf("Failed to audit agent: %v", err)
+	}
+}
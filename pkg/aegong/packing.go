@@ -0,0 +1,241 @@
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Packing analysis measures the entropy of each section of an executable to
+// find compressed or encrypted code, identifies the packer, and unpacks UPX
+// binaries so the detectors see the real program
+
+// A section this large with at least this entropy is compressed or encrypted
+const (
+	packedEntropy     = 7.2
+	minPackedSize     = 512
+	unpackTimeout     = 30 * time.Second
+	maxUnpackedBinary = 256 << 20
+)
+
+// SectionEntropy is the entropy of one section or segment of an executable
+type SectionEntropy struct {
+	Name       string  `json:"name"`
+	Size       int     `json:"size"`
+	Entropy    float64 `json:"entropy"`
+	Executable bool    `json:"executable"`
+	Packed     bool    `json:"packed"`
+}
+
+// UnpackResult records an attempt to unpack a packed agent
+type UnpackResult struct {
+	Tool  string `json:"tool"`
+	Size  int    `json:"size,omitempty"` // Size of the unpacked binary
+	Error string `json:"error,omitempty"`
+}
+
+// PackingAnalysis is the section layout of an executable agent and whether it
+// is packed
+type PackingAnalysis struct {
+	Format   string           `json:"format"` // elf, pe or macho
+	Sections []SectionEntropy `json:"sections"`
+	Packed   bool             `json:"packed"`           // Some section is compressed or encrypted
+	Packer   string           `json:"packer,omitempty"` // Known packer that produced the binary
+	Unpacked *UnpackResult    `json:"unpacked,omitempty"`
+}
+
+// sectionEntropy measures one section's data
+func sectionEntropy(name string, data []byte, executable bool) SectionEntropy {
+	entropy := calculateEntropy(data)
+	return SectionEntropy{
+		Name:       name,
+		Size:       len(data),
+		Entropy:    entropy,
+		Executable: executable,
+		Packed:     entropy >= packedEntropy && len(data) >= minPackedSize,
+	}
+}
+
+// analyzeSections parses an ELF, PE or Mach-O agent and measures its
+// sections, or returns nil for other agents. ELF binaries without section
+// headers, as UPX leaves them, are measured by loadable segment.
+func analyzeSections(binary []byte) *PackingAnalysis {
+	var analysis *PackingAnalysis
+	var names []string
+	reader := bytes.NewReader(binary)
+
+	if file, err := elf.NewFile(reader); err == nil {
+		analysis = &PackingAnalysis{Format: "elf"}
+		for _, section := range file.Sections {
+			// Debug sections are often compressed but never loaded
+			if section.Type == elf.SHT_NOBITS || section.Flags&elf.SHF_ALLOC == 0 || section.Size == 0 {
+				continue
+			}
+			if data, err := section.Data(); err == nil {
+				entry := sectionEntropy(section.Name, data, section.Flags&elf.SHF_EXECINSTR != 0)
+				// Hash tables and symbol tables are dense by design
+				entry.Packed = entry.Packed && section.Type == elf.SHT_PROGBITS
+				analysis.Sections = append(analysis.Sections, entry)
+				names = append(names, section.Name)
+			}
+		}
+		if len(analysis.Sections) == 0 {
+			for i, prog := range file.Progs {
+				if prog.Type != elf.PT_LOAD || prog.Filesz == 0 {
+					continue
+				}
+				data := make([]byte, prog.Filesz)
+				if _, err := prog.ReadAt(data, 0); err == nil {
+					analysis.Sections = append(analysis.Sections, sectionEntropy(fmt.Sprintf("segment %d", i), data, prog.Flags&elf.PF_X != 0))
+				}
+			}
+		}
+		file.Close()
+	} else if file, err := pe.NewFile(reader); err == nil {
+		analysis = &PackingAnalysis{Format: "pe"}
+		for _, section := range file.Sections {
+			if data, err := section.Data(); err == nil && len(data) > 0 {
+				analysis.Sections = append(analysis.Sections, sectionEntropy(section.Name, data, section.Characteristics&pe.IMAGE_SCN_MEM_EXECUTE != 0))
+				names = append(names, section.Name)
+			}
+		}
+		file.Close()
+	} else if file, err := macho.NewFile(reader); err == nil {
+		analysis = &PackingAnalysis{Format: "macho"}
+		for _, section := range file.Sections {
+			if data, err := section.Data(); err == nil && len(data) > 0 {
+				name := section.Seg + "," + section.Name
+				analysis.Sections = append(analysis.Sections, sectionEntropy(name, data, section.Seg == "__TEXT"))
+				names = append(names, name)
+			}
+		}
+		file.Close()
+	} else {
+		return nil
+	}
+
+	for _, section := range analysis.Sections {
+		analysis.Packed = analysis.Packed || section.Packed
+	}
+	analysis.Packer = identifyPacker(binary, names)
+	if analysis.Packer != "" {
+		analysis.Packed = true
+	}
+	return analysis
+}
+
+// identifyPacker recognises packers by their section names and markers
+func identifyPacker(binary []byte, sections []string) string {
+	for _, name := range sections {
+		switch {
+		case strings.HasPrefix(name, "UPX"), strings.HasPrefix(name, ".upx"):
+			return "UPX"
+		case name == ".aspack", name == ".adata":
+			return "ASPack"
+		case strings.HasPrefix(name, ".MPRESS"):
+			return "MPRESS"
+		case name == ".themida", name == ".winlice":
+			return "Themida"
+		case name == ".vmp0", name == ".vmp1":
+			return "VMProtect"
+		}
+	}
+	// UPX writes its magic into the headers of every binary it packs
+	if bytes.Contains(binary[:min(len(binary), 4096)], []byte("UPX!")) {
+		return "UPX"
+	}
+	return ""
+}
+
+// findUPX returns the upx program used to unpack agents, if installed
+func findUPX() string {
+	if path := os.Getenv("AEGONG_UPX"); path != "" {
+		return path
+	}
+	path, _ := exec.LookPath("upx")
+	return path
+}
+
+// unpackUPX decompresses a UPX-packed binary with the upx tool
+func unpackUPX(ctx context.Context, binary []byte) ([]byte, *UnpackResult) {
+	tool := findUPX()
+	if tool == "" {
+		return nil, &UnpackResult{Error: "upx is not installed"}
+	}
+	result := &UnpackResult{Tool: tool}
+
+	dir, err := os.MkdirTemp("", "aegong-unpack-")
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to create unpack directory: %v", err)
+		return nil, result
+	}
+	defer os.RemoveAll(dir)
+
+	packedPath := filepath.Join(dir, "packed")
+	unpackedPath := filepath.Join(dir, "unpacked")
+	if err := os.WriteFile(packedPath, binary, 0600); err != nil {
+		result.Error = fmt.Sprintf("failed to write packed binary: %v", err)
+		return nil, result
+	}
+
+	// upx only reads and writes files; the agent is never run
+	ctx, cancel := context.WithTimeout(ctx, unpackTimeout)
+	defer cancel()
+	if output, err := exec.CommandContext(ctx, tool, "-d", "-q", "-o", unpackedPath, packedPath).CombinedOutput(); err != nil {
+		result.Error = fmt.Sprintf("upx failed: %v: %s", err, strings.TrimSpace(string(output)))
+		return nil, result
+	}
+
+	info, err := os.Stat(unpackedPath)
+	if err != nil || info.Size() > maxUnpackedBinary {
+		result.Error = "unpacked binary is missing or larger than 256 MB"
+		return nil, result
+	}
+	unpacked, err := os.ReadFile(unpackedPath)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to read unpacked binary: %v", err)
+		return nil, result
+	}
+	result.Size = len(unpacked)
+	return unpacked, result
+}
+
+// runUnpackedAnalysis unpacks a UPX-packed agent and runs the detectors and
+// the deobfuscation pass over the unpacked program. Like the deobfuscation
+// pass, it only reports evidence the packed agent did not already show.
+func (e *Engine) runUnpackedAnalysis(ctx context.Context, packing *PackingAnalysis, binary []byte, container *CustomContainer, plain []ThreatDetection) ([]ThreatDetection, error) {
+	unpacked, result := unpackUPX(ctx, binary)
+	packing.Unpacked = result
+	if unpacked == nil {
+		return nil, errors.New(result.Error)
+	}
+
+	known := evidenceKeys(plain)
+	var threats []ThreatDetection
+	for _, threat := range e.detectIn(ctx, unpacked, container) {
+		evidence := newEvidence(known, threat)
+		if len(evidence) == 0 {
+			continue
+		}
+		threat.Evidence = evidence
+		details := make(map[string]interface{}, len(threat.Details)+2)
+		for key, value := range threat.Details {
+			details[key] = value
+		}
+		details["analysis"] = "unpacked"
+		details["packer"] = packing.Packer
+		threat.Details = details
+		threats = append(threats, threat)
+	}
+	threats = append(threats, e.runDeobfuscatedAnalysis(ctx, unpacked, container, append(plain, threats...))...)
+	return threats, ctx.Err()
+}
@@ -0,0 +1,102 @@
+package aegong
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testExecutable returns a small unpacked ELF executable
+func testExecutable(t *testing.T) []byte {
+	for _, path := range []string{"/bin/true", "/usr/bin/true"} {
+		if binary, err := os.ReadFile(path); err == nil && analyzeSections(binary) != nil && analyzeSections(binary).Format == "elf" {
+			return binary
+		}
+	}
+	t.Skip("No ELF executable to analyze")
+	return nil
+}
+
+// TestAnalyzeSections tests per-section entropy and packer identification
+func TestAnalyzeSections(t *testing.T) {
+	binary := testExecutable(t)
+
+	packing := analyzeSections(binary)
+	var text *SectionEntropy
+	for i := range packing.Sections {
+		if packing.Sections[i].Name == ".text" {
+			text = &packing.Sections[i]
+		}
+	}
+	if text == nil || !text.Executable || text.Entropy <= 0 || text.Entropy >= packedEntropy {
+		t.Fatalf(".text should be measured as executable unpacked code, got %+v", text)
+	}
+	if packing.Packed || packing.Packer != "" {
+		t.Fatalf("A system binary should not be packed, got %+v", packing)
+	}
+
+	// UPX leaves its magic in the headers; the ELF identification padding is unused
+	packed := append([]byte(nil), binary...)
+	copy(packed[9:], "UPX!")
+	if packing := analyzeSections(packed); packing.Packer != "UPX" || !packing.Packed {
+		t.Fatalf("UPX magic should identify the packer, got %+v", packing)
+	}
+
+	if analyzeSections([]byte("print('hello')")) != nil {
+		t.Fatal("Scripts have no sections")
+	}
+}
+
+// TestUnpackedAnalysis tests that UPX-packed agents are unpacked and analyzed again
+func TestUnpackedAnalysis(t *testing.T) {
+	packed := append([]byte(nil), testExecutable(t)...)
+	copy(packed[9:], "UPX!")
+
+	// A stand-in for upx that writes the "unpacked" program to the -o path
+	dir := t.TempDir()
+	upx := filepath.Join(dir, "upx")
+	script := "#!/bin/sh\nprintf 'escalate_privilege then override_authorization' > \"$4\"\n"
+	if err := os.WriteFile(upx, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AEGONG_UPX", upx)
+
+	engine := newTestEngine(t)
+	container := &CustomContainer{coverage: &coverageRecorder{}}
+	threats := engine.runStaticAnalysis(context.Background(), packed, container)
+
+	var unpacked []string
+	for _, threat := range threats {
+		if threat.Details["analysis"] == "unpacked" {
+			unpacked = append(unpacked, threat.Evidence...)
+		}
+	}
+	if !strings.Contains(strings.Join(unpacked, "\n"), "escalate_privilege") {
+		t.Fatalf("Detectors should run over the unpacked program, got %v", unpacked)
+	}
+	if container.Packing == nil || container.Packing.Unpacked == nil || container.Packing.Unpacked.Size == 0 {
+		t.Fatalf("Unpacking should be recorded, got %+v", container.Packing)
+	}
+
+	valid, results := (&HeuristicPatternDetector{}).Validate(context.Background(), packed, container)
+	if results["packer"] != "UPX" || results["unpacked"] == nil || !valid {
+		t.Fatalf("Heuristic shield should report the unpacked UPX binary, got %v %v", valid, results)
+	}
+
+	// Without a working upx the analysis is skipped and the shield fails
+	t.Setenv("AEGONG_UPX", filepath.Join(dir, "missing"))
+	container = &CustomContainer{coverage: &coverageRecorder{}}
+	engine.runStaticAnalysis(context.Background(), packed, container)
+	skipped := false
+	for _, component := range container.coverage.report(nil).Components {
+		skipped = skipped || (component.Name == "unpacking" && component.Status == CoverageSkipped)
+	}
+	if !skipped {
+		t.Fatal("Unpacking should be recorded as skipped when upx fails")
+	}
+	if valid, results := (&HeuristicPatternDetector{}).Validate(context.Background(), packed, container); valid {
+		t.Fatalf("Packed code the detectors could not see should fail the shield, got %v", results)
+	}
+}
@@ -0,0 +1,86 @@
+package aegong
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// DetectorError records a detector that panicked during an audit. Its
+// findings for that content are lost; the rest of the audit carries on.
+type DetectorError struct {
+	Detector  string    `json:"detector"` // T1 to T99
+	Phase     string    `json:"phase"`
+	Derived   bool      `json:"derived,omitempty"` // Panicked on decoded or unpacked content
+	Error     string    `json:"error"`             // The panic value
+	Location  string    `json:"location,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// safeAnalyze runs a detector, turning a panic into a DetectorError so one
+// faulty detector or malformed agent cannot abort the audit
+func (e *Engine) safeAnalyze(ctx context.Context, vector ThreatVector, detector ThreatDetector, analysis *AnalysisContext) (threats []ThreatDetection, failure *DetectorError) {
+	defer func() {
+		if r := recover(); r != nil {
+			threats = nil
+			failure = &DetectorError{
+				Detector:  detectorName(vector),
+				Phase:     analysis.Phase,
+				Derived:   analysis.derived,
+				Error:     fmt.Sprint(r),
+				Location:  panicLocation(),
+				Timestamp: time.Now(),
+			}
+			logf(ctx, "Warning: Detector %s panicked in the %s phase: %v\n%s", failure.Detector, failure.Phase, r, debug.Stack())
+			e.countPanic(failure.Detector)
+			coverageOf(analysis.Container).failed(*failure)
+		}
+	}()
+	threats = analyze(ctx, detector, analysis)
+	if analysis.Container != nil {
+		threats = append(threats, analysis.Container.feed.findings(vector, analysis)...)
+	}
+	return threats, nil
+}
+
+// panicLocation returns the function, file and line a recovered panic was
+// raised at. It must be called from the deferred function that recovered.
+func panicLocation() string {
+	pcs := make([]uintptr, 32)
+	// Skip runtime.Callers, panicLocation and the deferred function
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			return fmt.Sprintf("%s (%s:%d)", frame.Function, filepath.Base(frame.File), frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// countPanic counts a detector's panics since the engine started
+func (e *Engine) countPanic(detector string) {
+	e.panicsMutex.Lock()
+	defer e.panicsMutex.Unlock()
+	if e.panics == nil {
+		e.panics = make(map[string]int)
+	}
+	e.panics[detector]++
+}
+
+// DetectorPanics counts the panics of each detector since the engine started
+func (e *Engine) DetectorPanics() map[string]int {
+	e.panicsMutex.Lock()
+	defer e.panicsMutex.Unlock()
+	counts := make(map[string]int, len(e.panics))
+	for detector, count := range e.panics {
+		counts[detector] = count
+	}
+	return counts
+}
@@ -0,0 +1,54 @@
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+type panickingDetector struct{}
+
+func (p *panickingDetector) DetectThreat(ctx context.Context, binary []byte, container *CustomContainer) []ThreatDetection {
+	var offsets []int
+	return []ThreatDetection{{Details: map[string]interface{}{"offset": offsets[len(binary)]}}}
+}
+
+func (p *panickingDetector) GetThreatVector() ThreatVector { return T1_REASONING_HIJACK }
+
+// TestDetectorPanics tests that a panicking detector is reported without aborting the audit
+func TestDetectorPanics(t *testing.T) {
+	engine := newTestEngine(t)
+	engine.threatDetectors[T1_REASONING_HIJACK] = &panickingDetector{}
+
+	report, err := engine.Audit(context.Background(), bytes.NewReader([]byte("#!/bin/sh\necho hello\n")))
+	if err != nil {
+		t.Fatalf("Should finish the audit despite the panic, got %v", err)
+	}
+	if len(report.DetectorErrors) == 0 {
+		t.Fatal("Should report the detector's panic")
+	}
+	failure := report.DetectorErrors[0]
+	if failure.Detector != "T1" || failure.Phase != PhaseStatic || !strings.Contains(failure.Error, "index out of range") {
+		t.Errorf("Should name the detector, phase and panic, got %+v", failure)
+	}
+	if !strings.Contains(failure.Location, "DetectThreat") {
+		t.Errorf("Should locate the panic in the detector, got %q", failure.Location)
+	}
+
+	for _, component := range report.Coverage.Components {
+		if component.Name == "T1" && component.Phase == PhaseStatic {
+			if component.Status != CoverageSkipped || !strings.Contains(component.Reason, "panicked") {
+				t.Errorf("Should mark the detector skipped, got %+v", component)
+			}
+		} else if component.Name == "T2" && component.Phase == PhaseStatic && component.Status != CoverageRan {
+			t.Errorf("Should still run the other detectors, got %+v", component)
+		}
+	}
+	if report.Coverage.Complete {
+		t.Error("Reports with a panicked detector should not be complete")
+	}
+	if panics := engine.DetectorPanics(); panics["T1"] != len(report.DetectorErrors) {
+		t.Errorf("Should count every panic, got %v for %d errors", panics, len(report.DetectorErrors))
+	}
+}
@@ -0,0 +1,232 @@
+package aegong
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A pattern feed adds patterns to the detectors between releases, the way
+// virus scanners fetch signature updates. Feeds are published as JSON with a
+// detached signature; the engine only applies a feed whose signature checks
+// out against the operator's key and whose version is newer than the one it
+// runs. Each report records the feed version its audit ran with.
+
+// Confidence of a feed pattern's match when the feed does not give one
+const defaultFeedConfidence = 0.5
+
+// PatternFeed is a signed, versioned set of detector patterns
+type PatternFeed struct {
+	Version   int           `json:"version"` // Increases with each release of the feed
+	Published time.Time     `json:"published"`
+	Patterns  []FeedPattern `json:"patterns"`
+	checksum  string        // SHA-256 of the feed as signed
+}
+
+// FeedPattern is a pattern a feed adds to one detector
+type FeedPattern struct {
+	ID     string `json:"id"`
+	Vector string `json:"vector"` // T1 to T99; the detector must be registered
+	// Pattern is a case-insensitive substring, or a regular expression when
+	// prefixed with "re:"
+	Pattern     string  `json:"pattern"`
+	Severity    string  `json:"severity"`
+	Confidence  float64 `json:"confidence,omitempty"` // 0.5 by default
+	Description string  `json:"description,omitempty"`
+}
+
+// PatternFeedInfo describes the feed an engine applied, as recorded in reports
+type PatternFeedInfo struct {
+	Version   int       `json:"version"`
+	Published time.Time `json:"published"`
+	Checksum  string    `json:"checksum"`
+	Source    string    `json:"source,omitempty"` // Where the feed was fetched from
+	Patterns  int       `json:"patterns"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// ParsePatternFeed verifies a feed's detached signature with key and parses
+// it. The signature is over the feed's bytes, raw or base64 encoded as
+// cosign sign-blob writes it, and key is an ECDSA, RSA or Ed25519 public key.
+func ParsePatternFeed(data, signature []byte, key crypto.PublicKey) (*PatternFeed, error) {
+	if key == nil {
+		return nil, fmt.Errorf("no key to verify the pattern feed with")
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err == nil {
+		signature = decoded
+	}
+	if err := verifyBlobSignature(key, data, signature); err != nil {
+		return nil, fmt.Errorf("pattern feed signature is invalid: %v", err)
+	}
+
+	var feed PatternFeed
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse pattern feed: %v", err)
+	}
+	if feed.Version <= 0 {
+		return nil, fmt.Errorf("pattern feed has no version")
+	}
+	sum := sha256.Sum256(data)
+	feed.checksum = hex.EncodeToString(sum[:])
+	return &feed, nil
+}
+
+// feedPattern is a compiled FeedPattern
+type feedPattern struct {
+	customPattern
+	id         string
+	severity   ThreatSeverity
+	confidence float64
+}
+
+// feedPatterns is an applied feed, its patterns compiled by detector
+type feedPatterns struct {
+	info     PatternFeedInfo
+	byVector map[ThreatVector][]feedPattern
+}
+
+// compileFeed checks a feed's patterns against the engine's detectors and
+// compiles them
+func (e *Engine) compileFeed(feed *PatternFeed, source string) (*feedPatterns, error) {
+	vectors := make(map[string]ThreatVector, len(e.threatDetectors))
+	for vector := range e.threatDetectors {
+		vectors[detectorName(vector)] = vector
+	}
+
+	compiled := &feedPatterns{
+		info: PatternFeedInfo{
+			Version:   feed.Version,
+			Published: feed.Published,
+			Checksum:  feed.checksum,
+			Source:    source,
+			Patterns:  len(feed.Patterns),
+			AppliedAt: time.Now().UTC(),
+		},
+		byVector: make(map[ThreatVector][]feedPattern),
+	}
+	ids := make(map[string]bool)
+	for _, p := range feed.Patterns {
+		if p.ID == "" || ids[p.ID] {
+			return nil, fmt.Errorf("pattern feed v%d: pattern ids must be unique and not empty, got %q", feed.Version, p.ID)
+		}
+		ids[p.ID] = true
+		vector, ok := vectors[strings.ToUpper(p.Vector)]
+		if !ok {
+			return nil, fmt.Errorf("pattern %s: unknown vector %q", p.ID, p.Vector)
+		}
+		pattern, err := compilePattern(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %s: %v", p.ID, err)
+		}
+		severity, err := parseSeverity(p.Severity)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %s: %v", p.ID, err)
+		}
+		if p.Confidence < 0 || p.Confidence > 1 {
+			return nil, fmt.Errorf("pattern %s: confidence must be between 0 and 1", p.ID)
+		}
+		confidence := p.Confidence
+		if confidence == 0 {
+			confidence = defaultFeedConfidence
+		}
+		compiled.byVector[vector] = append(compiled.byVector[vector], feedPattern{
+			customPattern: pattern,
+			id:            p.ID,
+			severity:      severity,
+			confidence:    confidence,
+		})
+	}
+	return compiled, nil
+}
+
+// ApplyPatternFeed adds a verified feed's patterns to the detectors, in place
+// of any earlier feed. Feeds no newer than the applied one are refused.
+func (e *Engine) ApplyPatternFeed(feed *PatternFeed, source string) (PatternFeedInfo, error) {
+	compiled, err := e.compileFeed(feed, source)
+	if err != nil {
+		return PatternFeedInfo{}, err
+	}
+
+	e.updateMutex.Lock()
+	defer e.updateMutex.Unlock()
+
+	e.feedMutex.Lock()
+	if current := e.patternFeed; current != nil && feed.Version <= current.info.Version {
+		e.feedMutex.Unlock()
+		return current.info, fmt.Errorf("pattern feed v%d is not newer than the applied v%d", feed.Version, current.info.Version)
+	}
+	e.patternFeed = compiled
+	e.feedMutex.Unlock()
+
+	// Results cached under the old patterns no longer apply
+	if e.cache != nil {
+		e.cache.setVersion(e.configVersion())
+	}
+	e.Ruleset()
+	return compiled.info, nil
+}
+
+// PatternFeed describes the applied pattern feed, nil when none has been
+func (e *Engine) PatternFeed() *PatternFeedInfo {
+	return e.currentFeed().infoRef()
+}
+
+func (e *Engine) currentFeed() *feedPatterns {
+	e.feedMutex.RLock()
+	defer e.feedMutex.RUnlock()
+	return e.patternFeed
+}
+
+func (f *feedPatterns) infoRef() *PatternFeedInfo {
+	if f == nil {
+		return nil
+	}
+	info := f.info
+	return &info
+}
+
+// findings matches the feed's patterns for a detector, reporting them as a
+// single finding of that detector's vector
+func (f *feedPatterns) findings(vector ThreatVector, analysis *AnalysisContext) []ThreatDetection {
+	if f == nil || len(f.byVector[vector]) == 0 {
+		return nil
+	}
+	lower := analysis.Text()
+	var evidence, ids []string
+	severity := LOW
+	confidence := 0.0
+	first := -1 // Offset of the earliest match
+	for _, pattern := range f.byVector[vector] {
+		matches := pattern.locate(lower, 1)
+		if len(matches) == 0 {
+			continue
+		}
+		first = earliest(first, matches[0][0])
+		evidence = append(evidence, fmt.Sprintf("Pattern feed v%d pattern %s: %s", f.info.Version, pattern.id, pattern.source))
+		ids = append(ids, pattern.id)
+		severity = max(severity, pattern.severity)
+		confidence += pattern.confidence
+	}
+	if len(evidence) == 0 {
+		return nil
+	}
+
+	return []ThreatDetection{{
+		Vector:     vector,
+		Severity:   severity,
+		Confidence: min(confidence, 1.0),
+		Evidence:   evidence,
+		Timestamp:  time.Now(),
+		Details: map[string]interface{}{
+			"analysis":     "pattern_feed",
+			"feed_version": f.info.Version,
+			"patterns":     ids,
+		},
+		Location: analysis.Locate(first),
+	}}
+}
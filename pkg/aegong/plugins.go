@@ -0,0 +1,342 @@
+package aegong
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"Agent_Auditor/pkg/telemetry"
+)
+
+// Limits on what a detector plugin may do
+const (
+	pluginTimeout       = 5 * time.Second
+	pluginMemoryPages   = 256 // 16 MB of WebAssembly memory
+	maxPluginFindings   = 20
+	maxPluginEvidence   = 20
+	maxPluginFindingLen = 64 << 10
+	maxPluginLogLen     = 1 << 10
+)
+
+// Results plugin host functions return to the plugin
+const (
+	pluginOK       int32 = 0
+	pluginBadInput int32 = -1 // Out of bounds memory or an invalid finding
+	pluginLimit    int32 = -2 // Too many findings
+)
+
+// PluginManifest describes a detector plugin, read from <name>.json next to
+// its <name>.wasm module
+type PluginManifest struct {
+	Vector      string   `json:"vector"` // Built-in or custom vector its findings belong to
+	Description string   `json:"description,omitempty"`
+	Phases      []string `json:"phases,omitempty"` // static and/or dynamic; both by default
+}
+
+// pluginMemory is the part of a plugin's linear memory the host can access
+type pluginMemory interface {
+	Read(offset, byteCount uint32) ([]byte, bool)
+	Write(offset uint32, v []byte) bool
+}
+
+// pluginRuntime compiles WebAssembly modules into runnable plugins
+type pluginRuntime interface {
+	compile(ctx context.Context, name string, wasm []byte) (pluginModule, error)
+	close(ctx context.Context) error
+}
+
+// pluginModule is a compiled plugin; each run gets a fresh instance
+type pluginModule interface {
+	run(ctx context.Context, host *pluginHost) error
+}
+
+// pluginContext is what a plugin can learn about the analysis
+type pluginContext struct {
+	Plugin      string `json:"plugin"`
+	Phase       string `json:"phase"`
+	ContentSize int    `json:"content_size"`
+	// Content is the agent in the static phase and its execution log in the
+	// dynamic phase
+	ContentKind string `json:"content_kind"`
+	Harness     string `json:"harness,omitempty"`
+}
+
+// pluginFinding is a finding as a plugin emits it
+type pluginFinding struct {
+	Severity   string                 `json:"severity"`
+	Confidence float64                `json:"confidence"`
+	Evidence   []string               `json:"evidence"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// pluginHost implements the host API for one run of a plugin: reading the
+// analysis context and content, and emitting findings
+type pluginHost struct {
+	plugin   *PluginDetector
+	context  []byte
+	content  []byte
+	threats  []ThreatDetection
+	rejected int
+}
+
+func newPluginHost(plugin *PluginDetector, phase string, content []byte, container *CustomContainer) *pluginHost {
+	info := pluginContext{Plugin: plugin.name, Phase: phase, ContentSize: len(content), ContentKind: "agent"}
+	if phase == PhaseDynamic {
+		info.ContentKind = "execution_log"
+	}
+	if container != nil {
+		info.Harness = container.Harness
+	}
+	data, _ := json.Marshal(info)
+	return &pluginHost{plugin: plugin, context: data, content: content}
+}
+
+// contextLen is the size of the JSON analysis context
+func (h *pluginHost) contextLen() uint32 {
+	return uint32(len(h.context))
+}
+
+// readContext copies the analysis context to ptr
+func (h *pluginHost) readContext(memory pluginMemory, ptr uint32) int32 {
+	if !memory.Write(ptr, h.context) {
+		return pluginBadInput
+	}
+	return int32(len(h.context))
+}
+
+// contentLen is the size of the content being analyzed
+func (h *pluginHost) contentLen() uint32 {
+	return uint32(len(h.content))
+}
+
+// readContent copies up to length bytes of content from offset to ptr,
+// returning how many were copied
+func (h *pluginHost) readContent(memory pluginMemory, ptr, offset, length uint32) int32 {
+	if uint64(offset) > uint64(len(h.content)) {
+		return pluginBadInput
+	}
+	chunk := h.content[offset:]
+	if uint64(length) < uint64(len(chunk)) {
+		chunk = chunk[:length]
+	}
+	if !memory.Write(ptr, chunk) {
+		return pluginBadInput
+	}
+	return int32(len(chunk))
+}
+
+// emitFinding records the JSON finding of length bytes at ptr
+func (h *pluginHost) emitFinding(memory pluginMemory, ptr, length uint32) int32 {
+	if len(h.threats) >= maxPluginFindings {
+		return pluginLimit
+	}
+	if length > maxPluginFindingLen {
+		h.rejected++
+		return pluginBadInput
+	}
+	data, ok := memory.Read(ptr, length)
+	if !ok {
+		h.rejected++
+		return pluginBadInput
+	}
+
+	var finding pluginFinding
+	if err := json.Unmarshal(data, &finding); err != nil {
+		h.rejected++
+		return pluginBadInput
+	}
+	severity, err := parseSeverity(finding.Severity)
+	if err != nil || finding.Confidence < 0 || finding.Confidence > 1 || len(finding.Evidence) == 0 {
+		h.rejected++
+		return pluginBadInput
+	}
+	if len(finding.Evidence) > maxPluginEvidence {
+		finding.Evidence = finding.Evidence[:maxPluginEvidence]
+	}
+
+	details := map[string]interface{}{}
+	for key, value := range finding.Details {
+		details[key] = value
+	}
+	// Plugins cannot pose as the engine's own analyses
+	details["analysis"] = "plugin"
+	details["plugin"] = h.plugin.name
+	h.threats = append(h.threats, ThreatDetection{
+		Vector:     h.plugin.vector,
+		Severity:   severity,
+		Confidence: finding.Confidence,
+		Evidence:   finding.Evidence,
+		Timestamp:  time.Now(),
+		Details:    details,
+	})
+	return pluginOK
+}
+
+// log writes a message from the plugin to the server log
+func (h *pluginHost) log(ctx context.Context, memory pluginMemory, ptr, length uint32) {
+	if length > maxPluginLogLen {
+		length = maxPluginLogLen
+	}
+	if data, ok := memory.Read(ptr, length); ok {
+		logf(ctx, "Plugin %s: %s", h.plugin.name, strings.ToValidUTF8(string(data), "?"))
+	}
+}
+
+// PluginDetector runs a WebAssembly detector plugin
+type PluginDetector struct {
+	name     string
+	vector   ThreatVector
+	manifest PluginManifest
+	phases   map[string]bool
+	module   pluginModule
+	checksum string
+}
+
+// Version identifies the plugin build in report coverage
+func (p *PluginDetector) Version() string {
+	return "sha256:" + p.checksum
+}
+
+// detect runs the plugin over content, returning its findings
+func (p *PluginDetector) detect(ctx context.Context, phase string, content []byte, container *CustomContainer) ([]ThreatDetection, error) {
+	ctx, cancel := context.WithTimeout(ctx, pluginTimeout)
+	defer cancel()
+
+	host := newPluginHost(p, phase, content, container)
+	if err := p.module.run(ctx, host); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("plugin %s timed out after %s", p.name, pluginTimeout)
+		}
+		return nil, fmt.Errorf("plugin %s failed: %v", p.name, err)
+	}
+	if host.rejected > 0 {
+		logf(ctx, "Warning: Plugin %s emitted %d invalid findings", p.name, host.rejected)
+	}
+	return host.threats, nil
+}
+
+// loadPlugins compiles every <name>.wasm in dir with its <name>.json manifest
+func (e *Engine) loadPlugins(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.wasm"))
+	if err != nil {
+		return fmt.Errorf("failed to list plugins: %v", err)
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	runtime, err := newPluginRuntime(context.Background())
+	if err != nil {
+		return err
+	}
+	e.pluginRuntime = runtime
+	e.plugins = make(map[string]*PluginDetector)
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".wasm")
+		plugin, err := e.loadPlugin(runtime, name, path)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %v", name, err)
+		}
+		e.plugins[name] = plugin
+		log.Printf("Info: Loaded detector plugin %s for %s (%s)", name, detectorName(plugin.vector), plugin.Version())
+	}
+	return nil
+}
+
+func (e *Engine) loadPlugin(runtime pluginRuntime, name, path string) (*PluginDetector, error) {
+	data, err := os.ReadFile(strings.TrimSuffix(path, ".wasm") + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	var manifest PluginManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %v", err)
+	}
+
+	plugin := &PluginDetector{name: name, manifest: manifest, phases: make(map[string]bool)}
+	found := false
+	for vector := range e.threatDetectors {
+		if detectorName(vector) == strings.ToUpper(manifest.Vector) {
+			plugin.vector, found = vector, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown threat vector %q", manifest.Vector)
+	}
+	if len(manifest.Phases) == 0 {
+		manifest.Phases = []string{PhaseStatic, PhaseDynamic}
+	}
+	for _, phase := range manifest.Phases {
+		if phase != PhaseStatic && phase != PhaseDynamic {
+			return nil, fmt.Errorf("unknown phase %q", phase)
+		}
+		plugin.phases[phase] = true
+	}
+
+	wasm, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module: %v", err)
+	}
+	sum := sha256.Sum256(wasm)
+	plugin.checksum = hex.EncodeToString(sum[:])[:16]
+	if plugin.module, err = runtime.compile(context.Background(), name, wasm); err != nil {
+		return nil, err
+	}
+	return plugin, nil
+}
+
+// runPlugins runs the plugins of a phase over content
+func (e *Engine) runPlugins(ctx context.Context, phase string, content []byte, container *CustomContainer) []ThreatDetection {
+	names := make([]string, 0, len(e.plugins))
+	for name := range e.plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var threats []ThreatDetection
+	for _, name := range names {
+		plugin := e.plugins[name]
+		if ctx.Err() != nil {
+			break
+		}
+		if !plugin.phases[phase] {
+			continue
+		}
+		component := "plugin:" + name
+		if !container.selection.vector(plugin.vector) {
+			coverageOf(container).notRun(component, ComponentAnalysis, phase, plugin, CoverageExcluded, "")
+			continue
+		}
+		enabled, minConfidence := e.detectorSettings(plugin.vector)
+		if !enabled {
+			coverageOf(container).notRun(component, ComponentAnalysis, phase, plugin, CoverageDisabled, "")
+			continue
+		}
+
+		start := time.Now()
+		pluginCtx, span := telemetry.Start(ctx, "aegong.plugin "+name, "aegong.phase", phase)
+		found, err := plugin.detect(pluginCtx, phase, content, container)
+		found = filterConfidence(found, minConfidence)
+		span.SetAttribute("aegong.threats", len(found))
+		span.SetError(err)
+		span.End()
+		if err != nil {
+			logf(ctx, "Warning: %v", err)
+			coverageOf(container).notRun(component, ComponentAnalysis, phase, plugin, CoverageSkipped, err.Error())
+		} else {
+			coverageOf(container).ran(component, ComponentAnalysis, phase, plugin, time.Since(start), len(found))
+			checkpointOf(container).detected(ctx, phase, component, found, nil)
+		}
+		threats = append(threats, found...)
+	}
+	return threats
+}
@@ -0,0 +1,167 @@
+package aegong
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wasmSection encodes a WebAssembly section
+func wasmSection(id byte, payload ...byte) []byte {
+	return append(append([]byte{id}, wasmULEB(len(payload))...), payload...)
+}
+
+func wasmULEB(n int) []byte {
+	var out []byte
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+// wasmSLEB encodes the signed immediate of i32.const
+func wasmSLEB(n int) []byte {
+	var out []byte
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 && b&0x40 == 0 {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func wasmName(name string) []byte {
+	return append(wasmULEB(len(name)), name...)
+}
+
+// testPlugin assembles a plugin that imports module.content_len and
+// aegong.emit_finding, and whose detect runs body before returning 0. The
+// finding JSON is at offset 0 of its memory.
+func testPlugin(module string, finding string, body ...byte) []byte {
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	// () -> i32 and (i32, i32) -> i32
+	wasm = append(wasm, wasmSection(1, 0x02, 0x60, 0x00, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f)...)
+	imports := []byte{0x02}
+	imports = append(append(append(imports, wasmName(module)...), wasmName("content_len")...), 0x00, 0x00)
+	imports = append(append(append(imports, wasmName("aegong")...), wasmName("emit_finding")...), 0x00, 0x01)
+	wasm = append(wasm, wasmSection(2, imports...)...)
+	wasm = append(wasm, wasmSection(3, 0x01, 0x00)...)
+	wasm = append(wasm, wasmSection(5, 0x01, 0x00, 0x01)...)
+	exports := []byte{0x02}
+	exports = append(append(exports, wasmName("memory")...), 0x02, 0x00)
+	exports = append(append(exports, wasmName("detect")...), 0x00, 0x02)
+	wasm = append(wasm, wasmSection(7, exports...)...)
+	code := append(append([]byte{0x00}, body...), 0x41, 0x00, 0x0b)
+	wasm = append(wasm, wasmSection(10, append([]byte{0x01}, append(wasmULEB(len(code)), code...)...)...)...)
+	data := append([]byte{0x01, 0x00, 0x41, 0x00, 0x0b}, wasmName(finding)...)
+	return append(wasm, wasmSection(11, data...)...)
+}
+
+// emitIfContent calls emit_finding(0, length) when there is content to analyze
+func emitIfContent(length int) []byte {
+	body := []byte{0x10, 0x00, 0x04, 0x40, 0x41, 0x00, 0x41}
+	body = append(body, wasmSLEB(length)...)
+	return append(body, 0x10, 0x01, 0x1a, 0x0b)
+}
+
+func writePlugin(t *testing.T, dir, name string, wasm []byte, manifest string) {
+	if err := os.WriteFile(filepath.Join(dir, name+".wasm"), wasm, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".json"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDetectorPlugins tests that WASM plugins emit findings through the host API
+func TestDetectorPlugins(t *testing.T) {
+	finding := `{"severity":"HIGH","confidence":0.7,"evidence":["Plugin saw a shell"],"details":{"analysis":"taint"}}`
+	dir := t.TempDir()
+	writePlugin(t, dir, "shell", testPlugin("aegong", finding, emitIfContent(len(finding))...), `{"vector":"T4","phases":["static"]}`)
+	writePlugin(t, dir, "spin", testPlugin("aegong", "", 0x03, 0x40, 0x0c, 0x00, 0x0b), `{"vector":"T9"}`)
+
+	engine, err := NewEngine(Config{PluginDir: dir})
+	if err != nil {
+		t.Fatalf("Failed to load plugins: %v", err)
+	}
+	defer engine.Close()
+
+	shell := engine.plugins["shell"]
+	if shell == nil || !strings.HasPrefix(shell.Version(), "sha256:") || shell.phases[PhaseDynamic] {
+		t.Fatalf("shell plugin should be loaded for the static phase, got %+v", shell)
+	}
+	threats, err := shell.detect(context.Background(), PhaseStatic, []byte("os.system('sh')"), nil)
+	if err != nil {
+		t.Fatalf("Plugin failed: %v", err)
+	}
+	if len(threats) != 1 || threats[0].Vector != T4_UNAUTHORIZED_ACTION || threats[0].Severity != HIGH || threats[0].Evidence[0] != "Plugin saw a shell" {
+		t.Fatalf("Plugin should report a HIGH T4 finding, got %+v", threats)
+	}
+	if threats[0].Details["analysis"] != "plugin" || threats[0].Details["plugin"] != "shell" {
+		t.Fatalf("Plugin findings should be attributed to the plugin, got %v", threats[0].Details)
+	}
+	if threats, _ := shell.detect(context.Background(), PhaseStatic, nil, nil); len(threats) != 0 {
+		t.Fatalf("Instances should not keep state between runs, got %+v", threats)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := engine.plugins["spin"].detect(ctx, PhaseStatic, nil, nil); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("A plugin that never returns should time out, got %v", err)
+	}
+}
+
+// TestDetectorPluginSandbox tests that plugins are refused access beyond the host API
+func TestDetectorPluginSandbox(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "wasi", testPlugin("wasi_snapshot_preview1", ""), `{"vector":"T4"}`)
+	if _, err := NewEngine(Config{PluginDir: dir}); err == nil || !strings.Contains(err.Error(), "aegong host API") {
+		t.Fatalf("Plugins importing WASI should be refused, got %v", err)
+	}
+
+	dir = t.TempDir()
+	writePlugin(t, dir, "unknown", testPlugin("aegong", ""), `{"vector":"T42"}`)
+	if _, err := NewEngine(Config{PluginDir: dir}); err == nil {
+		t.Fatal("Plugins for unknown vectors should be refused")
+	}
+
+	// Invalid findings and reads outside memory are rejected by the host
+	host := &pluginHost{plugin: &PluginDetector{name: "x"}, content: []byte("agent")}
+	memory := &testMemory{data: make([]byte, 64)}
+	copy(memory.data, `{"severity":"SEVERE","confidence":2,"evidence":[]}`)
+	if host.emitFinding(memory, 0, 52) != pluginBadInput || host.emitFinding(memory, 60, 10) != pluginBadInput || len(host.threats) != 0 {
+		t.Fatal("Invalid findings should be rejected")
+	}
+	if host.readContent(memory, 0, 2, 100) != 3 || string(memory.data[:3]) != "ent" {
+		t.Fatalf("Should copy the rest of the content, got %q", memory.data[:3])
+	}
+	if host.readContent(memory, 0, 9, 1) != pluginBadInput {
+		t.Fatal("Reads past the content should be rejected")
+	}
+}
+
+type testMemory struct{ data []byte }
+
+func (m *testMemory) Read(offset, byteCount uint32) ([]byte, bool) {
+	if uint64(offset)+uint64(byteCount) > uint64(len(m.data)) {
+		return nil, false
+	}
+	return m.data[offset : offset+byteCount], true
+}
+
+func (m *testMemory) Write(offset uint32, v []byte) bool {
+	if uint64(offset)+uint64(len(v)) > uint64(len(m.data)) {
+		return false
+	}
+	copy(m.data[offset:], v)
+	return true
+}
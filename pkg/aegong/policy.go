@@ -0,0 +1,226 @@
+package aegong
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CapabilityPolicy is the capability set an organization allows its agents,
+// such as no network egress or writes only under /data
+type CapabilityPolicy struct {
+	Name string `json:"name,omitempty"`
+	// AllowedPermissions agents may declare or use; any other permission is a violation
+	AllowedPermissions []string `json:"allowed_permissions"`
+	// AllowedTools are the programs agents may run; empty allows any program
+	AllowedTools []string `json:"allowed_tools,omitempty"`
+	// WritePaths are the directories agents may write under; empty allows any path
+	WritePaths []string `json:"write_paths,omitempty"`
+}
+
+// Kinds of policy violation
+const (
+	ViolationPermission = "permission"
+	ViolationTool       = "tool"
+	ViolationWritePath  = "write_path"
+)
+
+// PolicyViolation is a capability an agent declared or used that the policy forbids
+type PolicyViolation struct {
+	Kind       string   `json:"kind"`
+	Capability string   `json:"capability"` // Permission, program or path
+	Declared   bool     `json:"declared"`   // Listed in the agent's manifest
+	Observed   bool     `json:"observed"`   // Seen during the audit
+	Evidence   []string `json:"evidence,omitempty"`
+}
+
+// PolicyCheck compares an agent's capabilities with the organization's policy
+type PolicyCheck struct {
+	Policy     string            `json:"policy,omitempty"`
+	Violations []PolicyViolation `json:"violations"`
+}
+
+// ParseCapabilityPolicy decodes and validates a capability policy
+func ParseCapabilityPolicy(data []byte) (*CapabilityPolicy, error) {
+	var policy CapabilityPolicy
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("failed to parse capability policy: %v", err)
+	}
+	for _, permission := range policy.AllowedPermissions {
+		if !knownPermissions[permission] {
+			return nil, fmt.Errorf("unknown permission %q in capability policy", permission)
+		}
+	}
+	for i, path := range policy.WritePaths {
+		if path == "" {
+			return nil, fmt.Errorf("empty write path in capability policy")
+		}
+		policy.WritePaths[i] = filepath.Clean(path)
+	}
+	return &policy, nil
+}
+
+// Path of a write the sandbox refused, from its evidence
+var deniedWritePath = regexp.MustCompile(`^Denied \S+ of (.+): `)
+
+// writtenPaths lists the files the agent wrote or tried to write
+func writtenPaths(threats []ThreatDetection, events []HarnessEvent) map[string]string {
+	paths := make(map[string]string)
+	for _, event := range events {
+		if event.Event == "file_write" && event.Detail != "" {
+			paths[event.Detail] = "Wrote " + event.Detail
+		}
+	}
+	for _, threat := range threats {
+		if threat.Details["analysis"] != "sandbox_denials" {
+			continue
+		}
+		for _, evidence := range threat.Evidence {
+			if match := deniedWritePath.FindStringSubmatch(evidence); match != nil {
+				paths[match[1]] = evidence
+			}
+		}
+	}
+	return paths
+}
+
+// underPath reports whether path is dir or inside it
+func underPath(path, dir string) bool {
+	path = filepath.Clean(path)
+	if dir == "/" {
+		return filepath.IsAbs(path)
+	}
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// checkPolicy compares the manifest's declared and the audit's observed
+// capabilities with a policy, returning the comparison and a T4 finding for
+// any violation
+func checkPolicy(policy *CapabilityPolicy, manifest *AgentManifest, threats []ThreatDetection, captures []HoneypotCapture, events []HarnessEvent) (*PolicyCheck, []ThreatDetection) {
+	observed, programs := observedCapabilities(threats, captures)
+	for _, event := range events {
+		if event.Event == "file_write" && len(observed[PermissionFileWrite]) < maxObservedEvidence {
+			observed[PermissionFileWrite] = append(observed[PermissionFileWrite], event.Detail)
+		}
+	}
+	check := &PolicyCheck{Policy: policy.Name, Violations: []PolicyViolation{}}
+
+	allowed := make(map[string]bool)
+	for _, permission := range policy.AllowedPermissions {
+		allowed[permission] = true
+	}
+	declared := make(map[string]bool)
+	var declaredTools []string
+	if manifest != nil {
+		for _, permission := range manifest.Permissions {
+			declared[permission] = true
+		}
+		declaredTools = manifest.Tools
+	}
+
+	// Forbidden permissions used by the agent are worse than those it only asks for
+	severity := LOW
+	for permission := range knownPermissions {
+		examples, used := observed[permission]
+		if allowed[permission] || !used && !declared[permission] {
+			continue
+		}
+		check.Violations = append(check.Violations, PolicyViolation{
+			Kind:       ViolationPermission,
+			Capability: permission,
+			Declared:   declared[permission],
+			Observed:   used,
+			Evidence:   examples,
+		})
+		if used {
+			severity = max(severity, undeclaredSeverity[permission]+1)
+		}
+	}
+
+	if len(policy.AllowedTools) > 0 {
+		tools := make(map[string]bool)
+		for _, tool := range policy.AllowedTools {
+			tools[tool] = true
+		}
+		violations := make(map[string]*PolicyViolation)
+		for _, tool := range declaredTools {
+			if !tools[tool] {
+				violations[tool] = &PolicyViolation{Kind: ViolationTool, Capability: tool, Declared: true}
+			}
+		}
+		for _, program := range programs {
+			if program == "" || tools[program] {
+				continue
+			}
+			if violations[program] == nil {
+				violations[program] = &PolicyViolation{Kind: ViolationTool, Capability: program}
+			}
+			violations[program].Observed = true
+			severity = max(severity, HIGH)
+		}
+		for _, violation := range violations {
+			check.Violations = append(check.Violations, *violation)
+		}
+	}
+
+	if len(policy.WritePaths) > 0 {
+		for path, evidence := range writtenPaths(threats, events) {
+			inside := false
+			for _, dir := range policy.WritePaths {
+				inside = inside || underPath(path, dir)
+			}
+			if inside {
+				continue
+			}
+			check.Violations = append(check.Violations, PolicyViolation{
+				Kind:       ViolationWritePath,
+				Capability: path,
+				Observed:   true,
+				Evidence:   []string{evidence},
+			})
+			severity = max(severity, MEDIUM)
+		}
+	}
+
+	if len(check.Violations) == 0 {
+		return check, nil
+	}
+	sort.Slice(check.Violations, func(i, j int) bool {
+		a, b := check.Violations[i], check.Violations[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Capability < b.Capability
+	})
+
+	var evidence []string
+	for _, violation := range check.Violations {
+		switch {
+		case violation.Kind == ViolationWritePath:
+			evidence = append(evidence, fmt.Sprintf("Write outside the allowed paths: %s", violation.Capability))
+		case violation.Observed:
+			evidence = append(evidence, fmt.Sprintf("Uses %s %s, which the policy forbids", violation.Kind, violation.Capability))
+		default:
+			evidence = append(evidence, fmt.Sprintf("Declares %s %s, which the policy forbids", violation.Kind, violation.Capability))
+		}
+	}
+	return check, []ThreatDetection{{
+		Vector:     T4_UNAUTHORIZED_ACTION,
+		Severity:   min(severity, CRITICAL),
+		Confidence: 0.9,
+		Evidence:   evidence,
+		Timestamp:  time.Now(),
+		Details: map[string]interface{}{
+			"analysis":   "capability_policy",
+			"policy":     policy.Name,
+			"violations": check.Violations,
+		},
+	}}
+}
@@ -0,0 +1,77 @@
+package aegong
+
+import "testing"
+
+// TestParseCapabilityPolicy tests policy decoding and validation
+func TestParseCapabilityPolicy(t *testing.T) {
+	policy, err := ParseCapabilityPolicy([]byte(`{"name": "offline", "allowed_permissions": ["file_write"], "write_paths": ["/data/"]}`))
+	if err != nil {
+		t.Fatalf("Failed to parse policy: %v", err)
+	}
+	if policy.Name != "offline" || policy.WritePaths[0] != "/data" {
+		t.Fatalf("Policy fields should be decoded and paths cleaned, got %+v", policy)
+	}
+
+	for _, spec := range []string{`{"allowed_permissions": ["root"]}`, `{"allowed_hosts": []}`, `{"write_paths": [""]}`} {
+		if _, err := ParseCapabilityPolicy([]byte(spec)); err == nil {
+			t.Fatalf("Policy %s should be rejected", spec)
+		}
+	}
+}
+
+// TestCheckPolicy tests that forbidden declared and observed capabilities raise a T4 finding
+func TestCheckPolicy(t *testing.T) {
+	policy := &CapabilityPolicy{
+		Name:               "offline",
+		AllowedPermissions: []string{PermissionFileWrite, PermissionSubprocess},
+		AllowedTools:       []string{"git"},
+		WritePaths:         []string{"/data"},
+	}
+	threats := []ThreatDetection{{
+		Vector: T4_UNAUTHORIZED_ACTION,
+		Details: map[string]interface{}{
+			"analysis": "execution_harness",
+			"events": []HarnessEvent{
+				{Event: "subprocess", Detail: "['curl', 'http://example.com']"},
+				{Event: "subprocess", Detail: "['git', 'status']"},
+			},
+		},
+	}}
+	events := []HarnessEvent{
+		{Event: "file_write", Detail: "/data/out.json"},
+		{Event: "file_write", Detail: "/data-backup/out.json"},
+	}
+	manifest := &AgentManifest{Permissions: []string{PermissionNetwork}}
+
+	check, findings := checkPolicy(policy, manifest, threats, nil, events)
+	want := []PolicyViolation{
+		{Kind: ViolationPermission, Capability: PermissionNetwork, Declared: true},
+		{Kind: ViolationTool, Capability: "curl", Observed: true},
+		{Kind: ViolationWritePath, Capability: "/data-backup/out.json", Observed: true},
+	}
+	if len(check.Violations) != len(want) {
+		t.Fatalf("Should find %d violations, got %+v", len(want), check.Violations)
+	}
+	for i, violation := range check.Violations {
+		if violation.Kind != want[i].Kind || violation.Capability != want[i].Capability ||
+			violation.Declared != want[i].Declared || violation.Observed != want[i].Observed {
+			t.Fatalf("Violation %d should be %+v, got %+v", i, want[i], violation)
+		}
+	}
+	if len(findings) != 1 || findings[0].Vector != T4_UNAUTHORIZED_ACTION || findings[0].Severity != HIGH {
+		t.Fatalf("Should raise a HIGH T4 finding, got %+v", findings)
+	}
+	if evidenceType(findings[0]) != "capability_policy" {
+		t.Fatalf("Finding should get the policy recommendation, got %q", evidenceType(findings[0]))
+	}
+
+	// Using a forbidden permission outranks declaring it
+	network := []ThreatDetection{{Details: map[string]interface{}{"analysis": "execution_harness", "events": []HarnessEvent{{Event: "connect", Detail: "('10.0.0.1', 443)"}}}}}
+	if _, findings := checkPolicy(&CapabilityPolicy{}, nil, network, nil, nil); len(findings) != 1 || findings[0].Severity != HIGH {
+		t.Fatalf("Network egress under a no-network policy should be HIGH, got %+v", findings)
+	}
+
+	if check, findings := checkPolicy(policy, nil, nil, nil, []HarnessEvent{{Event: "file_write", Detail: "/data/x"}}); len(check.Violations) != 0 || findings != nil {
+		t.Fatalf("Allowed writes should pass, got %+v", check.Violations)
+	}
+}
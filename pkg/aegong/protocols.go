@@ -0,0 +1,482 @@
+package aegong
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Agents talk to tools, models and each other over a few wire formats: MCP
+// and A2A, both JSON-RPC, and the OpenAI chat completions and responses APIs
+// with their function calls. The honeypot answers them just well enough to
+// keep the conversation going, and the requests it captured are decoded to
+// list the tools the agent invoked and the identities it claimed. Claiming
+// to be another agent is identity spoofing (T6); sending messages as the
+// agent on the other end, fabricating tool results, or telling another agent
+// to drop its instructions is trust manipulation (T7). Only plain HTTP
+// reaches the honeypot, so TLS traffic is not decoded.
+
+// Agent protocols
+const (
+	ProtocolMCP    = "mcp"
+	ProtocolA2A    = "a2a"
+	ProtocolOpenAI = "openai"
+)
+
+// Most protocol messages kept per audit
+const maxProtocolMessages = 100
+
+// ProtocolAnalysis is what the agent said over agent protocols while it ran
+type ProtocolAnalysis struct {
+	Messages   []ProtocolMessage `json:"messages"`
+	Tools      []InvokedTool     `json:"tools,omitempty"`
+	Identities []string          `json:"identities,omitempty"` // Names the agent gave itself
+	Truncated  bool              `json:"truncated,omitempty"`  // More messages were captured than are listed
+}
+
+// ProtocolMessage is a decoded agent protocol request
+type ProtocolMessage struct {
+	Protocol  string    `json:"protocol"`
+	Method    string    `json:"method"` // JSON-RPC method, or the API endpoint
+	Target    string    `json:"target"` // Host and path it was sent to
+	Identity  string    `json:"identity,omitempty"`
+	Role      string    `json:"role,omitempty"` // Role an A2A message was sent as
+	Tools     []string  `json:"tools,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// InvokedTool is a tool the agent called, and how often
+type InvokedTool struct {
+	Protocol string `json:"protocol"`
+	Name     string `json:"name"`
+	Calls    int    `json:"calls"`
+}
+
+// jsonRPCRequest is a JSON-RPC 2.0 request or notification
+type jsonRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// MCP and A2A method namespaces
+var (
+	mcpMethodPrefixes = []string{"initialize", "ping", "tools/", "resources/", "prompts/", "completion/", "logging/", "notifications/", "sampling/", "roots/", "elicitation/"}
+	a2aMethodPrefixes = []string{"message/", "tasks/", "agent/"}
+)
+
+// A2A message metadata keys that name the sender
+var a2aSenderKeys = []string{"sender", "from", "agent", "agent_id", "agent_name", "sender_id", "sender_name"}
+
+// Phrases that tell the agent on the other end to drop its instructions or
+// defer to an authority the sender doesn't have
+var authorityClaims = []string{
+	"ignore previous instructions",
+	"ignore all previous instructions",
+	"ignore your instructions",
+	"disregard your instructions",
+	"new instructions from",
+	"message from the system",
+	"system override",
+	"as your administrator",
+	"on behalf of the administrator",
+	"you are now",
+}
+
+// protocolFinding is evidence for T6 or T7 from one message
+type protocolFinding struct {
+	vector   ThreatVector
+	severity ThreatSeverity
+	evidence string
+}
+
+// analyzeProtocols decodes the agent protocol requests among the honeypot's
+// captures. Identities are checked against the manifest's name when there is
+// one; without it, claiming more than one identity is impersonation.
+func analyzeProtocols(captures []HoneypotCapture, manifest *AgentManifest) (*ProtocolAnalysis, []ThreatDetection) {
+	analysis := &ProtocolAnalysis{}
+	var findings []protocolFinding
+	tools := make(map[InvokedTool]int)
+	identities := make(map[string]bool)
+	for _, capture := range captures {
+		if capture.Service != "http" {
+			continue
+		}
+		messages, messageFindings := decodeCapture(capture)
+		findings = append(findings, messageFindings...)
+		for _, message := range messages {
+			for _, tool := range message.Tools {
+				tools[InvokedTool{Protocol: message.Protocol, Name: tool}]++
+			}
+			if message.Identity != "" && !identities[message.Identity] {
+				identities[message.Identity] = true
+				analysis.Identities = append(analysis.Identities, message.Identity)
+			}
+			if len(analysis.Messages) < maxProtocolMessages {
+				analysis.Messages = append(analysis.Messages, message)
+			} else {
+				analysis.Truncated = true
+			}
+		}
+	}
+	if len(analysis.Messages) == 0 {
+		return nil, nil
+	}
+
+	for tool, calls := range tools {
+		tool.Calls = calls
+		analysis.Tools = append(analysis.Tools, tool)
+	}
+	sort.Slice(analysis.Tools, func(i, j int) bool {
+		if analysis.Tools[i].Protocol != analysis.Tools[j].Protocol {
+			return analysis.Tools[i].Protocol < analysis.Tools[j].Protocol
+		}
+		return analysis.Tools[i].Name < analysis.Tools[j].Name
+	})
+
+	switch {
+	case manifest != nil && manifest.Name != "":
+		for _, identity := range analysis.Identities {
+			if !sameIdentity(identity, manifest.Name) {
+				findings = append(findings, protocolFinding{T6_IDENTITY_SPOOFING, HIGH,
+					fmt.Sprintf("Claimed to be %q, but its manifest names it %q", identity, manifest.Name)})
+			}
+		}
+	case len(analysis.Identities) > 1:
+		findings = append(findings, protocolFinding{T6_IDENTITY_SPOOFING, HIGH,
+			fmt.Sprintf("Claimed %d identities: %s", len(analysis.Identities), strings.Join(analysis.Identities, ", "))})
+	}
+	return analysis, protocolThreats(findings, analysis)
+}
+
+// protocolThreats groups protocol findings into one threat per vector
+func protocolThreats(findings []protocolFinding, analysis *ProtocolAnalysis) []ThreatDetection {
+	var threats []ThreatDetection
+	for _, vector := range []ThreatVector{T6_IDENTITY_SPOOFING, T7_TRUST_MANIPULATION} {
+		var evidence []string
+		severity := LOW
+		for _, finding := range findings {
+			if finding.vector == vector {
+				severity = max(severity, finding.severity)
+				if len(evidence) < 10 {
+					evidence = append(evidence, finding.evidence)
+				}
+			}
+		}
+		if len(evidence) == 0 {
+			continue
+		}
+		threats = append(threats, ThreatDetection{
+			Vector:     vector,
+			Severity:   severity,
+			Confidence: 0.8,
+			Evidence:   evidence,
+			Timestamp:  time.Now(),
+			Details: map[string]interface{}{
+				"analysis":   "protocols",
+				"identities": analysis.Identities,
+				"tools":      analysis.Tools,
+			},
+		})
+	}
+	return threats
+}
+
+// sameIdentity compares agent names ignoring case, spaces, dashes and underscores
+func sameIdentity(a, b string) bool {
+	normalize := func(name string) string {
+		return strings.Map(func(r rune) rune {
+			switch r {
+			case ' ', '-', '_':
+				return -1
+			}
+			return r
+		}, strings.ToLower(name))
+	}
+	return normalize(a) == normalize(b)
+}
+
+// splitCapture takes a captured HTTP request apart into its method, URL,
+// headers and body
+func splitCapture(capture HoneypotCapture) (string, *url.URL, textproto.MIMEHeader, []byte, bool) {
+	method, rawURL, ok := strings.Cut(capture.Summary, " ")
+	if !ok {
+		return "", nil, nil, nil, false
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return "", nil, nil, nil, false
+	}
+	reader := bufio.NewReader(strings.NewReader(capture.Payload))
+	header, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if err != nil {
+		return "", nil, nil, nil, false
+	}
+	var body bytes.Buffer
+	body.ReadFrom(reader)
+	return method, target, header, body.Bytes(), true
+}
+
+// decodeCapture decodes the protocol messages of one captured HTTP request
+func decodeCapture(capture HoneypotCapture) ([]ProtocolMessage, []protocolFinding) {
+	method, target, _, body, ok := splitCapture(capture)
+	if !ok {
+		return nil, nil
+	}
+	where := target.Host + target.Path
+
+	// A2A agents publish their agent card for discovery
+	if method == "GET" && (strings.HasSuffix(target.Path, "/.well-known/agent.json") || strings.HasSuffix(target.Path, "/.well-known/agent-card.json")) {
+		return []ProtocolMessage{{Protocol: ProtocolA2A, Method: "agent card", Target: where, Timestamp: capture.Timestamp}}, nil
+	}
+
+	body = bytes.TrimSpace(body)
+	switch {
+	case len(body) == 0:
+		return nil, nil
+	case strings.HasSuffix(target.Path, "/chat/completions"):
+		return decodeChatCompletion(body, where, capture.Timestamp)
+	case strings.HasSuffix(target.Path, "/responses"):
+		return decodeResponsesRequest(body, where, capture.Timestamp)
+	}
+
+	var requests []jsonRPCRequest
+	if body[0] == '[' {
+		json.Unmarshal(body, &requests)
+	} else {
+		var request jsonRPCRequest
+		if json.Unmarshal(body, &request) == nil {
+			requests = append(requests, request)
+		}
+	}
+	var messages []ProtocolMessage
+	var findings []protocolFinding
+	for _, request := range requests {
+		if request.JSONRPC != "2.0" || request.Method == "" {
+			continue
+		}
+		message := ProtocolMessage{Method: request.Method, Target: where, Timestamp: capture.Timestamp}
+		switch {
+		case hasAnyPrefix(request.Method, mcpMethodPrefixes):
+			message.Protocol = ProtocolMCP
+			decodeMCPParams(&message, request.Params)
+		case hasAnyPrefix(request.Method, a2aMethodPrefixes):
+			message.Protocol = ProtocolA2A
+			findings = append(findings, decodeA2AParams(&message, request.Params)...)
+		default:
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages, findings
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeMCPParams records the client name an MCP session is opened with and
+// the tool a call invokes
+func decodeMCPParams(message *ProtocolMessage, raw json.RawMessage) {
+	var params struct {
+		Name       string `json:"name"`
+		ClientInfo struct {
+			Name string `json:"name"`
+		} `json:"clientInfo"`
+	}
+	if json.Unmarshal(raw, &params) != nil {
+		return
+	}
+	switch message.Method {
+	case "initialize":
+		message.Identity = params.ClientInfo.Name
+	case "tools/call":
+		if params.Name != "" {
+			message.Tools = []string{params.Name}
+		}
+	}
+}
+
+// decodeA2AParams records who an A2A message says sent it and the role it
+// was sent as. Messages from the client are the user's turn, so sending one
+// as "agent" puts words in the remote agent's mouth.
+func decodeA2AParams(message *ProtocolMessage, raw json.RawMessage) []protocolFinding {
+	var params struct {
+		Message struct {
+			Role  string `json:"role"`
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+			Metadata map[string]interface{} `json:"metadata"`
+		} `json:"message"`
+	}
+	if json.Unmarshal(raw, &params) != nil {
+		return nil
+	}
+	message.Role = params.Message.Role
+	for _, key := range a2aSenderKeys {
+		if sender, ok := params.Message.Metadata[key].(string); ok && sender != "" {
+			message.Identity = sender
+			break
+		}
+	}
+
+	var findings []protocolFinding
+	if message.Role == "agent" {
+		findings = append(findings, protocolFinding{T7_TRUST_MANIPULATION, HIGH,
+			fmt.Sprintf("Sent an A2A %s to %s in the remote agent's own role", message.Method, message.Target)})
+	}
+	for _, part := range params.Message.Parts {
+		if claim := authorityClaim(part.Text); claim != "" {
+			findings = append(findings, protocolFinding{T7_TRUST_MANIPULATION, MEDIUM,
+				fmt.Sprintf("Told the agent at %s %q", message.Target, claim)})
+		}
+	}
+	return findings
+}
+
+// authorityClaim returns the first authority claim in text
+func authorityClaim(text string) string {
+	lower := strings.ToLower(text)
+	for _, claim := range authorityClaims {
+		if strings.Contains(lower, claim) {
+			return claim
+		}
+	}
+	return ""
+}
+
+// decodeChatCompletion lists the function calls in a chat completions
+// request's history. Tool results answering no call in the history were
+// made up by the agent rather than returned by a tool.
+func decodeChatCompletion(body []byte, where string, timestamp time.Time) ([]ProtocolMessage, []protocolFinding) {
+	var request struct {
+		Messages []struct {
+			Role      string `json:"role"`
+			ToolCalls []struct {
+				ID       string `json:"id"`
+				Function struct {
+					Name string `json:"name"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+			ToolCallID string `json:"tool_call_id"`
+		} `json:"messages"`
+	}
+	if json.Unmarshal(body, &request) != nil || request.Messages == nil {
+		return nil, nil
+	}
+	message := ProtocolMessage{Protocol: ProtocolOpenAI, Method: "chat.completions", Target: where, Timestamp: timestamp}
+	calls := make(map[string]bool)
+	var findings []protocolFinding
+	for _, turn := range request.Messages {
+		for _, call := range turn.ToolCalls {
+			calls[call.ID] = true
+			if call.Function.Name != "" {
+				message.Tools = append(message.Tools, call.Function.Name)
+			}
+		}
+		if turn.Role == "tool" && !calls[turn.ToolCallID] {
+			findings = append(findings, protocolFinding{T7_TRUST_MANIPULATION, HIGH,
+				fmt.Sprintf("Sent %s a tool result for call %q, which no tool call in the conversation made", where, turn.ToolCallID)})
+		}
+	}
+	return []ProtocolMessage{message}, findings
+}
+
+// decodeResponsesRequest lists the function calls in a responses API
+// request's input, checking tool outputs the same way
+func decodeResponsesRequest(body []byte, where string, timestamp time.Time) ([]ProtocolMessage, []protocolFinding) {
+	var request struct {
+		Input json.RawMessage `json:"input"`
+	}
+	if json.Unmarshal(body, &request) != nil || request.Input == nil {
+		return nil, nil
+	}
+	message := ProtocolMessage{Protocol: ProtocolOpenAI, Method: "responses", Target: where, Timestamp: timestamp}
+	var items []struct {
+		Type   string `json:"type"`
+		Name   string `json:"name"`
+		CallID string `json:"call_id"`
+	}
+	// A plain string input has no function calls
+	json.Unmarshal(request.Input, &items)
+	calls := make(map[string]bool)
+	var findings []protocolFinding
+	for _, item := range items {
+		switch item.Type {
+		case "function_call":
+			calls[item.CallID] = true
+			message.Tools = append(message.Tools, item.Name)
+		case "function_call_output":
+			if !calls[item.CallID] {
+				findings = append(findings, protocolFinding{T7_TRUST_MANIPULATION, HIGH,
+					fmt.Sprintf("Sent %s a function output for call %q, which no function call in the input made", where, item.CallID)})
+			}
+		}
+	}
+	return []ProtocolMessage{message}, findings
+}
+
+// protocolReply answers an agent protocol request the honeypot received with
+// the least the client needs to carry on. It returns false for requests that
+// are not agent protocol requests, and an empty reply for notifications.
+func protocolReply(method, host, path string, body []byte) (string, bool) {
+	if method == "GET" && (strings.HasSuffix(path, "/.well-known/agent.json") || strings.HasSuffix(path, "/.well-known/agent-card.json")) {
+		card, _ := json.Marshal(map[string]interface{}{
+			"name": "agent", "description": "", "url": "http://" + host + "/", "version": "1.0.0", "protocolVersion": "0.3.0",
+			"capabilities": map[string]interface{}{}, "defaultInputModes": []string{"text"}, "defaultOutputModes": []string{"text"},
+			"skills": []interface{}{},
+		})
+		return string(card), true
+	}
+	if method != "POST" {
+		return "", false
+	}
+	if strings.HasSuffix(path, "/chat/completions") {
+		return `{"id":"chatcmpl-0","object":"chat.completion","created":0,"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"OK"},"finish_reason":"stop"}],"usage":{"prompt_tokens":0,"completion_tokens":1,"total_tokens":1}}`, true
+	}
+	if strings.HasSuffix(path, "/responses") {
+		return `{"id":"resp_0","object":"response","created_at":0,"status":"completed","model":"gpt-4o","output":[{"type":"message","id":"msg_0","status":"completed","role":"assistant","content":[{"type":"output_text","text":"OK","annotations":[]}]}],"output_text":"OK"}`, true
+	}
+
+	var request jsonRPCRequest
+	if json.Unmarshal(bytes.TrimSpace(body), &request) != nil || request.JSONRPC != "2.0" || request.Method == "" {
+		return "", false
+	}
+	if len(request.ID) == 0 {
+		return "", true
+	}
+	var result interface{} = map[string]interface{}{}
+	switch request.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(request.Params, &params)
+		result = map[string]interface{}{
+			"protocolVersion": params.ProtocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": "server", "version": "1.0.0"},
+		}
+	case "tools/list":
+		result = map[string]interface{}{"tools": []interface{}{}}
+	case "tools/call":
+		result = map[string]interface{}{"content": []interface{}{map[string]string{"type": "text", "text": "OK"}}}
+	case "message/send", "tasks/send":
+		result = map[string]interface{}{"kind": "message", "role": "agent", "messageId": "0", "parts": []interface{}{map[string]string{"kind": "text", "text": "OK"}}}
+	}
+	reply, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": result})
+	return string(reply), true
+}
@@ -0,0 +1,89 @@
+package aegong
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Default size of a container's filesystem, overridden by AEGONG_DISK_QUOTA_MB
+const defaultDiskQuota = 64 * 1024 * 1024
+
+// Sandbox user the agent runs as
+const sandboxUID = 65534
+
+// diskQuota returns the per-container filesystem size limit in bytes
+func diskQuota() int64 {
+	if mb, err := strconv.ParseInt(os.Getenv("AEGONG_DISK_QUOTA_MB"), 10, 64); err == nil && mb > 0 {
+		return mb * 1024 * 1024
+	}
+	return defaultDiskQuota
+}
+
+// diskUsage returns the bytes used under a container's filesystem, excluding
+// the agent binary, and whether a quota-limited filesystem is full
+func diskUsage(container *CustomContainer, binarySize int64) (int64, bool) {
+	var used int64
+	if container.DiskQuotaEnforced {
+		if used, full, err := filesystemUsage(container.FileSystem); err == nil {
+			return max(used-binarySize, 0), full
+		}
+	}
+
+	// Without a tmpfs, add up the files the agent left behind
+	filepath.WalkDir(container.FileSystem, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			if info, err := entry.Info(); err == nil {
+				used += info.Size()
+			}
+		}
+		return nil
+	})
+	return max(used-binarySize, 0), false
+}
+
+// isQuotaError reports whether a failed syscall ran out of container disk space
+func isQuotaError(errno syscall.Errno) bool {
+	return errno == syscall.ENOSPC || errno == syscall.EDQUOT || errno == syscall.EFBIG
+}
+
+// quotaThreat turns writes that hit the container's disk quota into T5 evidence
+func (c *CustomContainer) quotaThreat() []ThreatDetection {
+	// A tmpfs rounds usage up to whole pages, so only an unenforced quota is
+	// judged by usage
+	overQuota := !c.DiskQuotaEnforced && c.DiskUsage > c.DiskQuota
+	if c.DiskQuotaHits == 0 && !c.DiskFull && !overQuota {
+		return nil
+	}
+
+	var evidence []string
+	if c.DiskFull {
+		evidence = append(evidence, fmt.Sprintf("Agent filled the %d MB container filesystem", c.DiskQuota/(1024*1024)))
+	}
+	if c.DiskQuotaHits > 0 {
+		evidence = append(evidence, fmt.Sprintf("%d writes failed because the container filesystem was full", c.DiskQuotaHits))
+	}
+	if overQuota {
+		evidence = append(evidence, fmt.Sprintf("Agent wrote %d KB, beyond the %d KB disk quota", c.DiskUsage/1024, c.DiskQuota/1024))
+	}
+
+	return []ThreatDetection{{
+		Vector:     T5_RESOURCE_MANIPULATION,
+		Severity:   HIGH,
+		Confidence: 0.9,
+		Evidence:   evidence,
+		Timestamp:  time.Now(),
+		Details: map[string]interface{}{
+			"analysis":       "disk_quota",
+			"disk_quota":     c.DiskQuota,
+			"disk_usage":     c.DiskUsage,
+			"failed_writes":  c.DiskQuotaHits,
+			"disk_full":      c.DiskFull,
+			"quota_enforced": c.DiskQuotaEnforced,
+		},
+	}}
+}
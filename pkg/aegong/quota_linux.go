@@ -0,0 +1,44 @@
+//go:build linux
+
+package aegong
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// mountContainerFS mounts a size-limited tmpfs over a container directory so
+// an agent filling its filesystem cannot fill the host disk. The agent runs
+// as nobody, so the mount is owned by that user.
+func mountContainerFS(path string, quota int64) error {
+	options := fmt.Sprintf("size=%d,mode=0755,uid=%d,gid=%d", quota, sandboxUID, sandboxUID)
+	if err := syscall.Mount("tmpfs", path, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, options); err != nil {
+		return fmt.Errorf("failed to mount tmpfs: %v", err)
+	}
+	return nil
+}
+
+// resizeContainerFS grows a container's tmpfs so the agent binary does not
+// count against the quota
+func resizeContainerFS(path string, size int64) error {
+	options := fmt.Sprintf("size=%d", size)
+	if err := syscall.Mount("tmpfs", path, "tmpfs", syscall.MS_REMOUNT|syscall.MS_NOSUID|syscall.MS_NODEV, options); err != nil {
+		return fmt.Errorf("failed to resize tmpfs: %v", err)
+	}
+	return nil
+}
+
+// unmountContainerFS detaches a container's tmpfs, discarding its contents
+func unmountContainerFS(path string) error {
+	return syscall.Unmount(path, syscall.MNT_DETACH)
+}
+
+// filesystemUsage returns the bytes used on the filesystem mounted at path and
+// whether it is full
+func filesystemUsage(path string) (int64, bool, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, false, err
+	}
+	return int64(stat.Blocks-stat.Bfree) * int64(stat.Bsize), stat.Bavail == 0, nil
+}
@@ -0,0 +1,51 @@
+package aegong
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestContainerDiskQuota tests that writes beyond the container quota fail and are reported as T5
+func TestContainerDiskQuota(t *testing.T) {
+	requireSandbox(t)
+	t.Setenv("AEGONG_DISK_QUOTA_MB", "1")
+	engine := newTestEngine(t)
+
+	container, err := engine.createIsolatedContainer("quota-test")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer engine.destroyContainer(container.ID)
+	if !container.DiskQuotaEnforced {
+		t.Skip("tmpfs mounts are not permitted here")
+	}
+
+	agent := []byte("#!/bin/sh\nhead -c 4194304 /dev/zero > fill\necho done\n")
+	executionLog := engine.simulateExecution(context.Background(), agent, container)
+	if !strings.Contains(executionLog, "Disk Quota Exceeded") {
+		t.Fatalf("Execution log should report the quota hit, got:\n%s", executionLog)
+	}
+
+	threats := container.quotaThreat()
+	if len(threats) != 1 || threats[0].Vector != T5_RESOURCE_MANIPULATION {
+		t.Fatalf("Quota hit should raise a T5 finding, got %+v", threats)
+	}
+	if !container.DiskFull || threats[0].Evidence[0] != "Agent filled the 1 MB container filesystem" {
+		t.Fatalf("Quota hit should be recorded as a full filesystem, got %+v", threats[0].Evidence)
+	}
+}
+
+// TestQuotaThreatUnenforced tests that usage beyond an unenforced quota is still reported
+func TestQuotaThreatUnenforced(t *testing.T) {
+	container := &CustomContainer{DiskQuota: 1024 * 1024, DiskUsage: 512 * 1024}
+	if threats := container.quotaThreat(); len(threats) != 0 {
+		t.Fatalf("Usage within the quota should not raise a finding, got %+v", threats)
+	}
+
+	container.DiskUsage = 8 * 1024 * 1024
+	threats := container.quotaThreat()
+	if len(threats) != 1 || !strings.Contains(threats[0].Evidence[0], "beyond the 1024 KB disk quota") {
+		t.Fatalf("Usage beyond the quota should raise a finding, got %+v", threats)
+	}
+}
@@ -0,0 +1,79 @@
+package aegong
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRateCounter tests that actions are counted per second and the peak second found
+func TestRateCounter(t *testing.T) {
+	start := time.Now()
+	rates := newRateCounter(start)
+	rates.add(RateConnections, start.Add(100*time.Millisecond), 3)
+	rates.add(RateConnections, start.Add(2500*time.Millisecond), 5)
+	rates.add(RateConnections, start.Add(2900*time.Millisecond), 4)
+	rates.add(RateConnections, start.Add(-time.Second), 1)
+	rates.add(RateOutputWrites, start, 0)
+
+	got := rates.rates(4 * time.Second)
+	if len(got) != 1 {
+		t.Fatalf("Should only report kinds that happened, got %+v", got)
+	}
+	rate := got[0]
+	if rate.Kind != RateConnections || rate.Total != 13 || rate.Peak != 9 || rate.PeakAt != 2 {
+		t.Fatalf("Should count 13 connections peaking at 9 two seconds in, got %+v", rate)
+	}
+	if rate.Average != 13.0/4 || rate.Limit != actionRateLimits[RateConnections] {
+		t.Fatalf("Should average over the run and carry the limit, got %+v", rate)
+	}
+
+	// Short runs are averaged over a second
+	short := newRateCounter(start)
+	short.add(RateRequests, start, 10)
+	if got := short.rates(100 * time.Millisecond); got[0].Average != 10 {
+		t.Fatalf("Should average a short run over one second, got %v", got[0].Average)
+	}
+}
+
+// TestLineCounter tests that lines written to the agent's output are counted as log lines
+func TestLineCounter(t *testing.T) {
+	var out bytes.Buffer
+	rates := newRateCounter(time.Now())
+	writer := &lineCounter{w: &out, rates: rates}
+	writer.Write([]byte("one\ntwo\nthr"))
+	writer.Write([]byte("ee\n"))
+
+	if out.String() != "one\ntwo\nthree\n" {
+		t.Fatalf("Should pass output through, got %q", out.String())
+	}
+	if got := rates.rates(time.Second); len(got) != 1 || got[0].Kind != RateLogLines || got[0].Total != 3 {
+		t.Fatalf("Should count 3 log lines, got %+v", got)
+	}
+}
+
+// TestRateThreats tests that peaks over their limit are T8 findings with the measured rate
+func TestRateThreats(t *testing.T) {
+	container := &CustomContainer{ActionRates: []ActionRate{
+		{Kind: RateLogLines, Total: 30000, Average: 3000, Peak: 12000, PeakAt: 4, Limit: 1000},
+		{Kind: RateConnections, Total: 20, Average: 2, Peak: 8, Limit: 100},
+	}}
+	threats := container.rateThreats()
+	if len(threats) != 1 || threats[0].Vector != T8_OVERSIGHT_SATURATION || threats[0].Severity != HIGH {
+		t.Fatalf("Should report a HIGH T8 threat for ten times the limit, got %+v", threats)
+	}
+	if len(threats[0].Evidence) != 1 || !strings.Contains(threats[0].Evidence[0], "12000 log lines in one second") {
+		t.Fatalf("Should give the measured rate as evidence, got %v", threats[0].Evidence)
+	}
+
+	container.ActionRates[0].Peak = 1500
+	if threats := container.rateThreats(); len(threats) != 1 || threats[0].Severity != MEDIUM {
+		t.Fatalf("Should report MEDIUM just over the limit, got %+v", threats)
+	}
+
+	container.ActionRates[0].Peak = 1000
+	if threats := container.rateThreats(); threats != nil {
+		t.Fatalf("Should not flag rates within their limits, got %+v", threats)
+	}
+}
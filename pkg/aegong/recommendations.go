@@ -0,0 +1,405 @@
+package aegong
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Recommendation priorities, most urgent first
+const (
+	PriorityCritical = "critical"
+	PriorityHigh     = "high"
+	PriorityMedium   = "medium"
+	PriorityLow      = "low"
+)
+
+// Estimated remediation effort
+const (
+	EffortLow    = "low"
+	EffortMedium = "medium"
+	EffortHigh   = "high"
+)
+
+// Recommendation is remediation guidance for the findings of one kind
+type Recommendation struct {
+	ID        string   `json:"id"`
+	Title     string   `json:"title"`
+	Vector    string   `json:"vector,omitempty"` // T1 to T9 or a custom vector; empty for SHIELD failures
+	Shield    string   `json:"shield,omitempty"`
+	Evidence  string   `json:"evidence_type,omitempty"`
+	Priority  string   `json:"priority"`
+	Effort    string   `json:"effort"`
+	Instances int      `json:"instances"`
+	Guidance  string   `json:"guidance"`
+	Snippet   string   `json:"snippet,omitempty"` // Example code or configuration
+	Links     []string `json:"links,omitempty"`
+}
+
+// remediation is a knowledge base entry
+type remediation struct {
+	title    string
+	effort   string
+	guidance string
+	snippet  string
+	links    []string
+}
+
+// remediationKey selects guidance by vector and the kind of evidence behind a
+// finding; an empty evidence type is the vector's general guidance
+type remediationKey struct {
+	vector   ThreatVector
+	evidence string
+}
+
+const owaspLLMTop10 = "https://owasp.org/www-project-top-10-for-large-language-model-applications/"
+
+var remediations = map[remediationKey]remediation{
+	{T1_REASONING_HIJACK, ""}: {
+		title:    "Implement reasoning path validation and monitoring",
+		effort:   EffortMedium,
+		guidance: "Treat prompts and retrieved content as untrusted. Keep system instructions separate from user and tool content, validate plans against an allow-list of steps, and alert on reasoning that departs from the task.",
+		links:    []string{owaspLLMTop10},
+	},
+	{T1_REASONING_HIJACK, "taint:" + taintSinkCode}: {
+		title:    "Never execute model output as code",
+		effort:   EffortMedium,
+		guidance: "LLM responses reach eval/exec. Parse the response into a fixed set of actions and dispatch them through explicit handlers instead of evaluating it.",
+		snippet: `ACTIONS = {"search": search, "summarise": summarise}
+
+action = json.loads(response)
+handler = ACTIONS.get(action["name"])
+if handler is None:
+    raise ValueError("unknown action")
+handler(**action["arguments"])`,
+		links: []string{owaspLLMTop10},
+	},
+	{T2_OBJECTIVE_CORRUPTION, ""}: {
+		title:    "Deploy objective integrity checks and goal verification",
+		effort:   EffortMedium,
+		guidance: "Load objectives and reward settings from signed, read-only configuration, and verify the active goal against it before each planning cycle.",
+	},
+	{T3_MEMORY_POISONING, "integrity"}: {
+		title:    "Stop the agent rewriting its own data and config",
+		effort:   EffortMedium,
+		guidance: "The agent changed, or tried to change, files its manifest registers as fixed. Mount its data and config read-only, keep anything it must update in a separate state directory, and restore the registered files from a known-good copy before trusting it again.",
+	},
+	{T3_MEMORY_POISONING, ""}: {
+		title:    "Implement memory integrity validation and knowledge base protection",
+		effort:   EffortHigh,
+		guidance: "Record the provenance of every memory entry, keep reference knowledge read-only, and validate or expire entries written from untrusted conversations before they are recalled.",
+		links:    []string{owaspLLMTop10},
+	},
+	{T4_UNAUTHORIZED_ACTION, ""}: {
+		title:    "Strengthen action authorization and tool access controls",
+		effort:   EffortMedium,
+		guidance: "Give each tool the narrowest permissions it needs, check every tool call against an allow-list, and require human approval for destructive or external actions.",
+		links:    []string{"https://cheatsheetseries.owasp.org/cheatsheets/Authorization_Cheat_Sheet.html"},
+	},
+	{T4_UNAUTHORIZED_ACTION, "taint:" + taintSinkCommand}: {
+		title:    "Stop untrusted data from reaching shell commands",
+		effort:   EffortLow,
+		guidance: "Pass arguments as a list without a shell and validate them against an allow-list; never interpolate network, user or model data into command strings.",
+		snippet: `# Python
+subprocess.run(["git", "log", "--", path], check=True)  # no shell=True
+
+// Node.js
+execFile("git", ["log", "--", path], callback);  // not exec()`,
+		links: []string{"https://docs.python.org/3/library/subprocess.html#security-considerations", "https://nodejs.org/api/child_process.html"},
+	},
+	{T4_UNAUTHORIZED_ACTION, "taint:" + taintSinkCode}: {
+		title:    "Remove eval of untrusted data",
+		effort:   EffortLow,
+		guidance: "Replace eval/exec/new Function on network or user data with a parser for the expected format, such as json.loads or ast.literal_eval.",
+		snippet:  `value = ast.literal_eval(text)  # instead of eval(text)`,
+	},
+	{T4_UNAUTHORIZED_ACTION, "taint:" + taintSinkDeserialize}: {
+		title:    "Use safe deserialization for untrusted data",
+		effort:   EffortLow,
+		guidance: "pickle, marshal and yaml.load can run arbitrary code. Use JSON or yaml.safe_load for anything that crosses a trust boundary.",
+		snippet:  `config = yaml.safe_load(stream)  # instead of yaml.load(stream)`,
+		links:    []string{"https://docs.python.org/3/library/pickle.html"},
+	},
+	{T4_UNAUTHORIZED_ACTION, "taint:" + taintSinkFileWrite}: {
+		title:    "Constrain file writes driven by untrusted data",
+		effort:   EffortLow,
+		guidance: "Resolve paths under a dedicated working directory and reject any that escape it before writing tainted content.",
+		snippet: `target = (BASE_DIR / name).resolve()
+if not target.is_relative_to(BASE_DIR):
+    raise ValueError("path escapes the working directory")`,
+	},
+	{T4_UNAUTHORIZED_ACTION, "sandbox_denials"}: {
+		title:    "Remove writes outside the agent's working directory",
+		effort:   EffortLow,
+		guidance: "The agent tried to modify files outside its container and was refused. Write only under the working directory, or declare and justify any system paths it needs.",
+		links:    []string{"https://docs.kernel.org/userspace-api/landlock.html"},
+	},
+	{T4_UNAUTHORIZED_ACTION, "capability_policy"}: {
+		title:    "Bring the agent within the organization's capability policy",
+		effort:   EffortMedium,
+		guidance: "The agent declared or used permissions, tools or file paths the capability policy forbids. Remove that behaviour, or have the policy owners approve an exception before deploying it.",
+	},
+	{T4_UNAUTHORIZED_ACTION, "escape"}: {
+		title:    "Remove container escape attempts",
+		effort:   EffortHigh,
+		guidance: "The agent mounted filesystems, wrote to the cgroup hierarchy or host kernel files, or switched namespaces or its root directory. These are the building blocks of container breakouts and no agent needs them; remove the calls, and treat the agent as hostile until it is rebuilt from reviewed source.",
+		links:    []string{"https://man7.org/linux/man-pages/man7/namespaces.7.html"},
+	},
+	{T4_UNAUTHORIZED_ACTION, "syscall_policy"}: {
+		title:    "Remove syscalls unexpected for an agent",
+		effort:   EffortHigh,
+		guidance: "The agent made syscalls the syscall policy lists as having no place in an agent, such as loading kernel modules, mounting filesystems or tracing other processes. Remove them; if the agent genuinely needs one, run it outside the agent and have the policy owners list the exception.",
+		links:    []string{"https://man7.org/linux/man-pages/man2/syscalls.2.html"},
+	},
+	{T4_UNAUTHORIZED_ACTION, "internal_egress"}: {
+		title:    "Block agent access to metadata services and internal networks",
+		effort:   EffortMedium,
+		guidance: "The agent tried to reach the cloud instance metadata service, link-local addresses or private networks. In production, block these ranges from the agent with network policy, require IMDSv2 with a hop limit of 1, and give the agent only the credentials it needs instead of the host's role.",
+		links:    []string{"https://owasp.org/www-community/attacks/Server_Side_Request_Forgery"},
+	},
+	{T4_UNAUTHORIZED_ACTION, "harness:subprocess"}: {
+		title:    "Justify or remove spawned processes",
+		effort:   EffortMedium,
+		guidance: "The agent started processes at runtime. Replace them with library calls where possible, or run them through a fixed allow-list of commands with argument lists.",
+		links:    []string{"https://docs.python.org/3/library/subprocess.html#security-considerations"},
+	},
+	{T4_UNAUTHORIZED_ACTION, "harness:network"}: {
+		title:    "Declare and restrict outbound network access",
+		effort:   EffortMedium,
+		guidance: "The agent connected to remote hosts during execution. Route requests through an egress proxy with an allow-list of destinations and declare them in the agent's manifest.",
+	},
+	{T5_RESOURCE_MANIPULATION, ""}: {
+		title:    "Implement resource monitoring and consumption limits",
+		effort:   EffortMedium,
+		guidance: "Bound loops, retries and concurrency, and run the agent under memory, CPU and process limits.",
+		snippet: `[Service]
+MemoryMax=512M
+CPUQuota=50%
+TasksMax=64`,
+		links: []string{"https://docs.kernel.org/admin-guide/cgroup-v2.html"},
+	},
+	{T5_RESOURCE_MANIPULATION, "disk_quota"}: {
+		title:    "Bound the agent's disk usage",
+		effort:   EffortLow,
+		guidance: "The agent filled its sandbox filesystem. Cap caches and logs, rotate or delete temporary files, and stream large data instead of writing it out.",
+	},
+	{T5_RESOURCE_MANIPULATION, "soak:memory_leak"}: {
+		title:    "Fix the agent's memory leak",
+		effort:   EffortMedium,
+		guidance: "The agent's memory grew steadily during a long run. Bound caches and conversation history, release resources after each task, and profile the agent over a realistic session.",
+	},
+	{T6_IDENTITY_SPOOFING, ""}: {
+		title:    "Strengthen identity verification and authentication mechanisms",
+		effort:   EffortMedium,
+		guidance: "Authenticate every peer and tool with short-lived credentials, and never let an agent assert an identity it was not issued.",
+	},
+	{T6_IDENTITY_SPOOFING, "credential_harvesting"}: {
+		title:    "Remove access to stored credentials",
+		effort:   EffortHigh,
+		guidance: "The agent reads cloud or SSH keys, browser password stores or the OS keychain, or asks the instance metadata service for role credentials. Give the agent only the scoped credentials it needs through its configuration, block the metadata service from its network, and rotate any credentials on hosts where it already ran.",
+		links:    []string{"https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/configuring-instance-metadata-service.html"},
+	},
+	{T6_IDENTITY_SPOOFING, "signature"}: {
+		title:    "Publish a valid signature from the claimed publisher",
+		effort:   EffortLow,
+		guidance: "The agent's signature failed verification or was made by someone other than the claimed publisher. Re-sign the release with the publisher's identity and upload the matching signature and certificate.",
+		snippet:  `cosign sign-blob --bundle agent.sigstore.json agent.bin`,
+		links:    []string{"https://docs.sigstore.dev/"},
+	},
+	{T7_TRUST_MANIPULATION, ""}: {
+		title:    "Implement trust validation and human-agent interaction controls",
+		effort:   EffortMedium,
+		guidance: "Disclose that users are talking to an agent, avoid persuasive or urgent framing in requests for approval, and require explicit confirmation for consequential actions.",
+	},
+	{T8_OVERSIGHT_SATURATION, ""}: {
+		title:    "Deploy distributed oversight and monitoring redundancy",
+		effort:   EffortHigh,
+		guidance: "Rate-limit the agent's alerts and approval requests, aggregate similar events, and route high-severity actions to an independent reviewer.",
+	},
+	{T9_GOVERNANCE_EVASION, ""}: {
+		title:    "Implement immutable audit trails and governance enforcement",
+		effort:   EffortMedium,
+		guidance: "Send the agent's logs to an append-only store it cannot modify, and block attempts to disable logging or change policy at runtime.",
+		links:    []string{"https://cheatsheetseries.owasp.org/cheatsheets/Logging_Cheat_Sheet.html"},
+	},
+	{T9_GOVERNANCE_EVASION, "agent_manifest"}: {
+		title:    "Declare every capability the agent uses in its manifest",
+		effort:   EffortLow,
+		guidance: "The agent used permissions or ran tools its manifest does not declare. Remove the undeclared behaviour, or add it to the manifest so reviewers and policy can account for it.",
+		snippet: `{
+  "name": "research-assistant",
+  "publisher": "builds@example.com",
+  "model": "gpt-4o",
+  "tools": ["git"],
+  "permissions": ["network", "subprocess"]
+}`,
+	},
+	{T9_GOVERNANCE_EVASION, "soak:delayed_activity"}: {
+		title:    "Remove delayed or dormant behaviour",
+		effort:   EffortMedium,
+		guidance: "The agent stayed quiet through a standard audit window and only then started using the network, CPU or disk. Behaviour that waits out an audit must be removed or made to run immediately so it can be reviewed.",
+	},
+	{T9_GOVERNANCE_EVASION, "soak:beaconing"}: {
+		title:    "Stop periodic beaconing to remote services",
+		effort:   EffortLow,
+		guidance: "The agent contacted a service at regular intervals, as command-and-control implants do. Remove the polling, or declare the endpoint and its purpose in the agent's manifest.",
+	},
+	{T9_GOVERNANCE_EVASION, "evasion"}: {
+		title:    "Remove sandbox and debugger detection",
+		effort:   EffortMedium,
+		guidance: "The agent checks whether it is being traced, virtualized or sandboxed, or stalls before acting. An agent that behaves differently under audit cannot be trusted in production; remove the checks so it behaves the same everywhere.",
+	},
+	{T9_GOVERNANCE_EVASION, "disassembly"}: {
+		title:    "Remove self-modifying code and direct system calls",
+		effort:   EffortHigh,
+		guidance: "The agent's machine code writes into its own instructions, makes system calls without going through libc, or routes most calls through pointers resolved at runtime. These techniques hide behaviour from static review and from monitors that hook libc; build the agent from source with ordinary calls so what it does can be read from its code.",
+	},
+	{T9_GOVERNANCE_EVASION, "clock"}: {
+		title:    "Remove time-triggered behaviour",
+		effort:   EffortMedium,
+		guidance: "The agent only acted after a long wait or once its clock passed a later date, a pattern used to slip past reviews and audits. Remove the trigger so all of the agent's behaviour can be observed when it runs.",
+	},
+	{T9_GOVERNANCE_EVASION, "harness:dynamic_code"}: {
+		title:    "Remove dynamically generated code",
+		effort:   EffortMedium,
+		guidance: "The agent built and ran code at runtime, which hides its behaviour from review. Ship all code in the package so it can be audited statically.",
+	},
+}
+
+// Guidance for a failed SHIELD module
+var shieldRemediation = remediation{
+	title:    "Address %s module validation failures",
+	effort:   EffortMedium,
+	guidance: "Review the %s results in shield_results and fix the controls they report as missing.",
+}
+
+// evidenceType classifies the evidence behind a finding for the knowledge base
+func evidenceType(threat ThreatDetection) string {
+	switch threat.Details["analysis"] {
+	case "taint":
+		var flows []TaintFlow
+		if detailAs(threat.Details, "taint_flows", &flows) && len(flows) > 0 {
+			return "taint:" + flows[0].Sink
+		}
+		return "taint"
+	case "execution_harness":
+		// Spawned processes outrank network use when a finding has both
+		evidence := "execution_harness"
+		var events []HarnessEvent
+		detailAs(threat.Details, "events", &events)
+		for _, event := range events {
+			switch event.Event {
+			case "subprocess":
+				return "harness:subprocess"
+			case "connect", "request":
+				evidence = "harness:network"
+			case "eval", "exec":
+				evidence = "harness:dynamic_code"
+			}
+		}
+		return evidence
+	case "soak":
+		return fmt.Sprint("soak:", threat.Details["soak_finding"])
+	case nil:
+		if _, ok := threat.Details["signature_status"]; ok {
+			return "signature"
+		}
+		return ""
+	default:
+		return fmt.Sprint(threat.Details["analysis"])
+	}
+}
+
+func priorityFor(severity ThreatSeverity) string {
+	switch severity {
+	case CRITICAL:
+		return PriorityCritical
+	case HIGH:
+		return PriorityHigh
+	case MEDIUM:
+		return PriorityMedium
+	}
+	return PriorityLow
+}
+
+var priorityRank = map[string]int{PriorityCritical: 0, PriorityHigh: 1, PriorityMedium: 2, PriorityLow: 3}
+
+func (e *Engine) generateRecommendations(threats []ThreatDetection, shieldResults map[string]interface{}) []Recommendation {
+	type group struct {
+		key      remediationKey
+		severity ThreatSeverity
+		count    int
+	}
+	groups := make(map[remediationKey]*group)
+	for _, threat := range threats {
+		key := remediationKey{threat.Vector, evidenceType(threat)}
+		if _, ok := remediations[key]; !ok {
+			key.evidence = ""
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{key: key, severity: threat.Severity}
+			groups[key] = g
+		}
+		g.count++
+		g.severity = max(g.severity, threat.Severity)
+	}
+
+	recommendations := []Recommendation{}
+	for _, g := range groups {
+		entry, ok := remediations[g.key]
+		if !ok {
+			entry, _ = customRemediation(g.key.vector)
+		}
+		id := detectorName(g.key.vector)
+		if g.key.evidence != "" {
+			id += "-" + strings.NewReplacer(":", "-", "_", "-").Replace(g.key.evidence)
+		}
+		recommendations = append(recommendations, Recommendation{
+			ID:        id,
+			Title:     entry.title,
+			Vector:    detectorName(g.key.vector),
+			Evidence:  g.key.evidence,
+			Priority:  priorityFor(g.severity),
+			Effort:    entry.effort,
+			Instances: g.count,
+			Guidance:  entry.guidance,
+			Snippet:   entry.snippet,
+			Links:     entry.links,
+		})
+	}
+
+	// Generate recommendations based on SHIELD results
+	for moduleName, result := range shieldResults {
+		if resultMap, ok := result.(map[string]interface{}); ok {
+			if valid, ok := resultMap["valid"].(bool); ok && !valid {
+				recommendations = append(recommendations, Recommendation{
+					ID:        "shield-" + moduleName,
+					Title:     fmt.Sprintf(shieldRemediation.title, moduleName),
+					Shield:    moduleName,
+					Priority:  PriorityMedium,
+					Effort:    shieldRemediation.effort,
+					Instances: 1,
+					Guidance:  fmt.Sprintf(shieldRemediation.guidance, moduleName),
+				})
+			}
+		}
+	}
+
+	// Most urgent first, then the most widespread, then cheapest to fix
+	effortRank := map[string]int{EffortLow: 0, EffortMedium: 1, EffortHigh: 2}
+	sort.Slice(recommendations, func(i, j int) bool {
+		a, b := recommendations[i], recommendations[j]
+		if priorityRank[a.Priority] != priorityRank[b.Priority] {
+			return priorityRank[a.Priority] < priorityRank[b.Priority]
+		}
+		if a.Instances != b.Instances {
+			return a.Instances > b.Instances
+		}
+		if effortRank[a.Effort] != effortRank[b.Effort] {
+			return effortRank[a.Effort] < effortRank[b.Effort]
+		}
+		return a.ID < b.ID
+	})
+	return recommendations
+}
@@ -0,0 +1,72 @@
+package aegong
+
+import (
+	"testing"
+)
+
+// TestRecommendationKnowledgeBase tests that recommendations are chosen by evidence type and ordered by priority
+func TestRecommendationKnowledgeBase(t *testing.T) {
+	engine := newTestEngine(t)
+
+	threats := []ThreatDetection{
+		{Vector: T1_REASONING_HIJACK, Severity: LOW},
+		{Vector: T1_REASONING_HIJACK, Severity: MEDIUM},
+		{
+			Vector:   T4_UNAUTHORIZED_ACTION,
+			Severity: CRITICAL,
+			Details: map[string]interface{}{
+				"analysis":    "taint",
+				"taint_flows": []TaintFlow{{Sink: taintSinkCommand}},
+			},
+		},
+		{
+			Vector:   T9_GOVERNANCE_EVASION,
+			Severity: MEDIUM,
+			Details: map[string]interface{}{
+				"analysis": "execution_harness",
+				"events":   []HarnessEvent{{Event: "eval", Detail: "1 + 1"}},
+			},
+		},
+		// Evidence without its own entry falls back to the vector's guidance
+		{
+			Vector:   T2_OBJECTIVE_CORRUPTION,
+			Severity: HIGH,
+			Details:  map[string]interface{}{"analysis": "unknown"},
+		},
+	}
+	shieldResults := map[string]interface{}{
+		"integrity": map[string]interface{}{"valid": false},
+	}
+
+	recommendations := engine.generateRecommendations(threats, shieldResults)
+	byID := make(map[string]Recommendation)
+	for _, rec := range recommendations {
+		byID[rec.ID] = rec
+		if rec.Guidance == "" || rec.Effort == "" {
+			t.Fatalf("Recommendation should carry guidance and effort, got %+v", rec)
+		}
+	}
+	if len(recommendations) != 5 {
+		t.Fatalf("Should group findings into 5 recommendations, got %+v", recommendations)
+	}
+
+	command, ok := byID["T4-taint-command-execution"]
+	if !ok || command.Snippet == "" || len(command.Links) == 0 {
+		t.Fatalf("Command injection should get specific guidance with a code pattern, got %+v", recommendations)
+	}
+	if recommendations[0].ID != command.ID || command.Priority != PriorityCritical {
+		t.Fatalf("Critical findings should come first, got %+v", recommendations[0])
+	}
+	if rec := byID["T1"]; rec.Instances != 2 || rec.Priority != PriorityMedium {
+		t.Fatalf("T1 findings should be grouped at their highest severity, got %+v", rec)
+	}
+	if _, ok := byID["T9-harness-dynamic-code"]; !ok {
+		t.Fatalf("Harness eval should get dynamic code guidance, got %+v", recommendations)
+	}
+	if rec, ok := byID["T2"]; !ok || rec.Priority != PriorityHigh {
+		t.Fatalf("Unknown evidence should fall back to the vector's guidance, got %+v", recommendations)
+	}
+	if rec, ok := byID["shield-integrity"]; !ok || rec.Shield != "integrity" {
+		t.Fatalf("Failed shields should get a recommendation, got %+v", recommendations)
+	}
+}
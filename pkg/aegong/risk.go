@@ -0,0 +1,146 @@
+package aegong
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Risk scoring strategies for Config.RiskScoring
+const (
+	// ScoringBalanced averages the mean and the largest finding risk
+	ScoringBalanced = "balanced"
+	// ScoringMax scores an agent by its single riskiest finding
+	ScoringMax = "max"
+	// ScoringCVSS weights severities by CVSS v3 bands and compounds findings,
+	// so several medium findings can outrank one high
+	ScoringCVSS = "cvss"
+)
+
+// RiskBreakdown explains how a report's overall risk was computed
+type RiskBreakdown struct {
+	Strategy        string             `json:"strategy"`
+	Formula         string             `json:"formula"`
+	SeverityWeights map[string]float64 `json:"severity_weights"`
+	Contributions   []RiskContribution `json:"contributions"`
+	Average         float64            `json:"average"`
+	Max             float64            `json:"max"`
+	Score           float64            `json:"score"`
+}
+
+// RiskContribution is one finding's input to the overall risk
+type RiskContribution struct {
+	Threat     int     `json:"threat"` // Index into the report's threats
+	Vector     string  `json:"vector"`
+	Severity   string  `json:"severity"`
+	Weight     float64 `json:"weight"`
+	Confidence float64 `json:"confidence"`
+	Risk       float64 `json:"risk"` // Weight times confidence, times the feedback factor
+	// FeedbackFactor is below one when false positive feedback marks the
+	// finding's patterns as noisy
+	FeedbackFactor float64 `json:"feedback_factor,omitempty"`
+}
+
+// scoringStrategy turns per-finding risks into an overall score
+type scoringStrategy struct {
+	name    string
+	formula string
+	weights map[ThreatSeverity]float64
+	combine func(risks []float64, average, max float64) float64
+}
+
+// Weights the engine has always used
+var defaultSeverityWeights = map[ThreatSeverity]float64{LOW: 0.25, MEDIUM: 0.5, HIGH: 0.75, CRITICAL: 1.0}
+
+var scoringStrategies = map[string]*scoringStrategy{
+	ScoringBalanced: {
+		name:    ScoringBalanced,
+		formula: "(average + max) / 2 of weight × confidence",
+		weights: defaultSeverityWeights,
+		combine: func(risks []float64, average, max float64) float64 {
+			return (average + max) / 2.0
+		},
+	},
+	ScoringMax: {
+		name:    ScoringMax,
+		formula: "max of weight × confidence",
+		weights: defaultSeverityWeights,
+		combine: func(risks []float64, average, max float64) float64 {
+			return max
+		},
+	},
+	ScoringCVSS: {
+		name:    ScoringCVSS,
+		formula: "1 - ∏(1 - weight × confidence), weights at the top of each CVSS v3 severity band",
+		weights: map[ThreatSeverity]float64{LOW: 0.39, MEDIUM: 0.69, HIGH: 0.89, CRITICAL: 1.0},
+		combine: func(risks []float64, average, max float64) float64 {
+			remaining := 1.0
+			for _, risk := range risks {
+				remaining *= 1 - risk
+			}
+			return 1 - remaining
+		},
+	},
+}
+
+// newScoringStrategy looks up a strategy by name; empty selects ScoringBalanced
+func newScoringStrategy(name string) (*scoringStrategy, error) {
+	if name == "" {
+		name = ScoringBalanced
+	}
+	strategy, ok := scoringStrategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown risk scoring strategy %q", name)
+	}
+	return strategy, nil
+}
+
+// breakdown scores threats and records each one's contribution. A non-nil
+// factor scales down the risk of findings users mark as false positives.
+func (s *scoringStrategy) breakdown(threats []ThreatDetection, factor func(ThreatDetection) float64) *RiskBreakdown {
+	breakdown := &RiskBreakdown{
+		Strategy:        s.name,
+		Formula:         s.formula,
+		SeverityWeights: make(map[string]float64),
+		Contributions:   []RiskContribution{},
+	}
+	for severity, weight := range s.weights {
+		breakdown.SeverityWeights[SeverityName(severity)] = weight
+	}
+	if len(threats) == 0 {
+		return breakdown
+	}
+
+	risks := make([]float64, 0, len(threats))
+	total := 0.0
+	for i, threat := range threats {
+		weight := s.weights[threat.Severity]
+		risk := weight * threat.Confidence
+		contribution := RiskContribution{
+			Threat:     i,
+			Vector:     detectorName(threat.Vector),
+			Severity:   SeverityName(threat.Severity),
+			Weight:     weight,
+			Confidence: threat.Confidence,
+		}
+		if factor != nil {
+			if f := factor(threat); f < 1 {
+				contribution.FeedbackFactor = f
+				risk *= f
+			}
+		}
+		contribution.Risk = risk
+		risks = append(risks, risk)
+		total += risk
+		breakdown.Max = math.Max(breakdown.Max, risk)
+		breakdown.Contributions = append(breakdown.Contributions, contribution)
+	}
+	breakdown.Average = total / float64(len(threats))
+	breakdown.Score = s.combine(risks, breakdown.Average, breakdown.Max)
+
+	// Largest contributions first
+	sort.SliceStable(breakdown.Contributions, func(i, j int) bool {
+		return breakdown.Contributions[i].Risk > breakdown.Contributions[j].Risk
+	})
+	return breakdown
+}
@@ -0,0 +1,63 @@
+package aegong
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// TestRiskBreakdown tests that the breakdown explains the overall risk
+func TestRiskBreakdown(t *testing.T) {
+	engine := newTestEngine(t)
+
+	threats := []ThreatDetection{
+		{Vector: T1_REASONING_HIJACK, Severity: MEDIUM, Confidence: 0.8},
+		{Vector: T4_UNAUTHORIZED_ACTION, Severity: CRITICAL, Confidence: 0.9},
+	}
+	breakdown := engine.scoring.breakdown(threats, nil)
+	if breakdown.Strategy != ScoringBalanced {
+		t.Fatalf("Engines should score with the balanced strategy by default, got %s", breakdown.Strategy)
+	}
+	if breakdown.Score != engine.calculateOverallRisk(threats) {
+		t.Fatalf("Breakdown score %.3f should match the overall risk %.3f", breakdown.Score, engine.calculateOverallRisk(threats))
+	}
+	if len(breakdown.Contributions) != 2 || breakdown.Contributions[0].Vector != "T4" || breakdown.Contributions[0].Threat != 1 {
+		t.Fatalf("Contributions should be listed largest first with their threat index, got %+v", breakdown.Contributions)
+	}
+	if math.Abs(breakdown.Average-0.65) > 1e-9 || math.Abs(breakdown.Max-0.9) > 1e-9 {
+		t.Fatalf("Average and max should be 0.65 and 0.9, got %.3f and %.3f", breakdown.Average, breakdown.Max)
+	}
+	if breakdown.SeverityWeights["CRITICAL"] != 1.0 || breakdown.Formula == "" {
+		t.Fatalf("Breakdown should record the weights and formula, got %+v", breakdown)
+	}
+}
+
+// TestScoringStrategies tests the alternative risk scoring strategies
+func TestScoringStrategies(t *testing.T) {
+	threats := []ThreatDetection{
+		{Vector: T1_REASONING_HIJACK, Severity: MEDIUM, Confidence: 1.0},
+		{Vector: T2_OBJECTIVE_CORRUPTION, Severity: MEDIUM, Confidence: 1.0},
+		{Vector: T3_MEMORY_POISONING, Severity: MEDIUM, Confidence: 1.0},
+	}
+
+	scores := make(map[string]float64)
+	for _, name := range []string{ScoringBalanced, ScoringMax, ScoringCVSS} {
+		engine, err := NewEngine(Config{AuditLogPath: filepath.Join(t.TempDir(), "audit.log"), RiskScoring: name})
+		if err != nil {
+			t.Fatalf("Failed to create engine with %s scoring: %v", name, err)
+		}
+		defer engine.Close()
+		scores[name] = engine.calculateOverallRisk(threats)
+	}
+
+	if scores[ScoringMax] != 0.5 {
+		t.Fatalf("Max scoring should use the riskiest finding, got %.3f", scores[ScoringMax])
+	}
+	if scores[ScoringCVSS] <= 0.89 {
+		t.Fatalf("CVSS-like scoring should compound three medium findings above a single high, got %.3f", scores[ScoringCVSS])
+	}
+
+	if _, err := NewEngine(Config{RiskScoring: "vibes"}); err == nil {
+		t.Fatal("Unknown scoring strategies should be rejected")
+	}
+}
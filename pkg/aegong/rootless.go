@@ -0,0 +1,108 @@
+package aegong
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Mount point of the unified (v2) cgroup hierarchy
+const cgroupV2Root = "/sys/fs/cgroup"
+
+// rootlessMode reports whether the sandbox runs in a user namespace instead of
+// relying on root. AEGONG_ROOTLESS=1 or 0 forces it; by default it is used
+// whenever the server is not running as root.
+func rootlessMode() bool {
+	switch os.Getenv("AEGONG_ROOTLESS") {
+	case "1":
+		return true
+	case "0":
+		return false
+	}
+	return os.Geteuid() != 0
+}
+
+// Parent cgroup for containers inside the delegated subtree, set up once
+var delegation struct {
+	once   sync.Once
+	parent string
+	err    error
+}
+
+// delegatedCgroupParent returns a cgroup v2 directory, inside the subtree
+// delegated to this process (e.g. by systemd's Delegate=yes), under which
+// container cgroups with memory and CPU limits can be created
+func delegatedCgroupParent() (string, error) {
+	delegation.once.Do(func() {
+		delegation.parent, delegation.err = setupDelegatedCgroup()
+	})
+	return delegation.parent, delegation.err
+}
+
+func setupDelegatedCgroup() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", fmt.Errorf("failed to read own cgroup: %v", err)
+	}
+	own := parseCgroupV2Path(string(data))
+	if own == "" {
+		return "", fmt.Errorf("not running in a cgroup v2 hierarchy")
+	}
+
+	root := filepath.Join(cgroupV2Root, own)
+	if err := checkWritable(filepath.Join(root, "cgroup.subtree_control")); err != nil {
+		return "", fmt.Errorf("cgroup %s is not delegated to this user", root)
+	}
+	controllers, _ := os.ReadFile(filepath.Join(root, "cgroup.controllers"))
+	for _, controller := range []string{"memory", "cpu"} {
+		if !strings.Contains(" "+strings.TrimSpace(string(controllers))+" ", " "+controller+" ") {
+			return "", fmt.Errorf("controller %s is not delegated to cgroup %s", controller, root)
+		}
+	}
+
+	// cgroup v2 only lets a cgroup without processes enable controllers for
+	// its children, so the server first moves itself into a leaf
+	if err := enableControllers(root); err != nil {
+		server := filepath.Join(root, "aegong-server")
+		if err := os.MkdirAll(server, 0755); err != nil {
+			return "", fmt.Errorf("failed to create server cgroup: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(server, "cgroup.procs"), []byte(fmt.Sprintf("%d", os.Getpid())), 0644); err != nil {
+			return "", fmt.Errorf("failed to move server into its own cgroup: %v", err)
+		}
+		if err := enableControllers(root); err != nil {
+			return "", err
+		}
+	}
+
+	parent := filepath.Join(root, "aegong")
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", fmt.Errorf("failed to create aegong cgroup directory: %v", err)
+	}
+	if err := enableControllers(parent); err != nil {
+		return "", err
+	}
+	return parent, nil
+}
+
+// enableControllers enables the memory and CPU controllers for a cgroup's children
+func enableControllers(cgroup string) error {
+	if err := os.WriteFile(filepath.Join(cgroup, "cgroup.subtree_control"), []byte("+memory +cpu"), 0644); err != nil {
+		return fmt.Errorf("failed to enable cgroup controllers in %s: %v", cgroup, err)
+	}
+	return nil
+}
+
+// parseCgroupV2Path returns the unified hierarchy path from /proc/self/cgroup
+func parseCgroupV2Path(data string) string {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return path
+		}
+	}
+	return ""
+}
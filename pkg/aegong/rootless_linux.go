@@ -0,0 +1,24 @@
+//go:build linux
+
+package aegong
+
+import (
+	"os"
+	"syscall"
+)
+
+// applyUserNamespace runs the agent in a new user namespace where the sandbox
+// user is mapped onto the unprivileged service account, so namespaces can be
+// created without CAP_SYS_ADMIN on the host
+func applyUserNamespace(attr *syscall.SysProcAttr) {
+	attr.Cloneflags |= syscall.CLONE_NEWUSER
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: sandboxUID, HostID: os.Geteuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: sandboxUID, HostID: os.Getegid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+	attr.Credential = &syscall.Credential{Uid: sandboxUID, Gid: sandboxUID, NoSetGroups: true}
+}
+
+// checkWritable reports whether the server may write to path
+func checkWritable(path string) error {
+	return syscall.Access(path, 2)
+}
@@ -0,0 +1,42 @@
+package aegong
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestParseCgroupV2Path tests finding the unified hierarchy in /proc/self/cgroup
+func TestParseCgroupV2Path(t *testing.T) {
+	data := "12:memory:/user.slice\n0::/user.slice/user-1000.slice/user@1000.service/app.slice/aegong.service\n"
+	if path := parseCgroupV2Path(data); path != "/user.slice/user-1000.slice/user@1000.service/app.slice/aegong.service" {
+		t.Fatalf("Should find the cgroup v2 path, got %q", path)
+	}
+	if path := parseCgroupV2Path("4:cpu:/\n"); path != "" {
+		t.Fatalf("Should find nothing on a cgroup v1 only host, got %q", path)
+	}
+}
+
+// TestRootlessExecution tests that the agent runs as the mapped sandbox user in a user namespace
+func TestRootlessExecution(t *testing.T) {
+	requireSandbox(t)
+	t.Setenv("AEGONG_ROOTLESS", "1")
+	engine := newTestEngine(t)
+
+	container, err := engine.createIsolatedContainer("rootless-test")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer engine.destroyContainer(container.ID)
+
+	executionLog := engine.simulateExecution(context.Background(), []byte("#!/bin/sh\nid -u\n"), container)
+	if strings.Contains(executionLog, "Failed to start process") {
+		t.Skipf("User namespaces are not available here:\n%s", executionLog)
+	}
+	if !strings.Contains(executionLog, "User Namespace: Rootless") {
+		t.Fatalf("Execution log should record rootless mode, got:\n%s", executionLog)
+	}
+	if !strings.Contains(executionLog, "Standard Output:\n65534\n") {
+		t.Fatalf("Agent should run as the sandbox user, got:\n%s", executionLog)
+	}
+}
@@ -0,0 +1,135 @@
+package aegong
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Ruleset is a snapshot of the detector and SHIELD configuration an audit ran
+// under. Rulesets are numbered in the order the engine first runs them, so a
+// report can be traced to "ruleset v12" and compared with later rules.
+type Ruleset struct {
+	Version          int                `json:"version"`
+	Checksum         string             `json:"checksum"` // The config checksum of EngineVersion
+	DetectorRevision int                `json:"detector_revision"`
+	Components       []ComponentStatus  `json:"components"`
+	CustomVectors    []VectorDefinition `json:"custom_vectors,omitempty"`
+	Plugins          map[string]string  `json:"plugins,omitempty"` // Version by plugin name
+	Options          map[string]string  `json:"options"`           // Sandbox settings that change findings
+	FirstUsed        time.Time          `json:"first_used"`
+}
+
+// rulesetStore numbers the rulesets an engine has run, saved to a JSON file
+type rulesetStore struct {
+	path     string // Empty keeps the history in memory
+	mutex    sync.Mutex
+	rulesets []Ruleset // Oldest first
+}
+
+func newRulesetStore(path string) (*rulesetStore, error) {
+	store := &rulesetStore{path: path}
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rulesets: %v", err)
+	}
+	if err := json.Unmarshal(data, &store.rulesets); err != nil {
+		return nil, fmt.Errorf("invalid ruleset file %s: %v", path, err)
+	}
+	return store, nil
+}
+
+// record numbers a snapshot, reusing the version of an identical earlier
+// ruleset so reverting a change does not start a new one
+func (s *rulesetStore) record(snapshot Ruleset) (Ruleset, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, ruleset := range s.rulesets {
+		if ruleset.Checksum == snapshot.Checksum {
+			return ruleset, nil
+		}
+	}
+	snapshot.Version = len(s.rulesets) + 1
+	snapshot.FirstUsed = time.Now().UTC()
+	s.rulesets = append(s.rulesets, snapshot)
+	return snapshot, s.save()
+}
+
+// save writes the history to its file. The caller must hold the mutex.
+func (s *rulesetStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.rulesets, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write rulesets: %v", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *rulesetStore) list() []Ruleset {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Ruleset(nil), s.rulesets...)
+}
+
+// Ruleset returns the ruleset audits starting now run under, numbering it if
+// the configuration has not been run before
+func (e *Engine) Ruleset() Ruleset {
+	snapshot := Ruleset{
+		Checksum:         e.configVersion(),
+		DetectorRevision: detectorRevision,
+		Components:       e.Components(),
+		Options: map[string]string{
+			"timeout":      executionTimeout.String(),
+			"soak":         soakDuration().String(),
+			"clock":        clockFingerprint(),
+			"honeypot":     fmt.Sprint(honeypotEnabled()),
+			"dependencies": fmt.Sprint(dependencyInstallEnabled()),
+		},
+	}
+	if feed := e.currentFeed(); feed != nil {
+		snapshot.Options["pattern_feed"] = fmt.Sprint(feed.info.Version)
+	}
+	for _, detector := range e.threatDetectors {
+		if custom, ok := detector.(*CustomVectorDetector); ok {
+			snapshot.CustomVectors = append(snapshot.CustomVectors, custom.definition)
+		}
+	}
+	sort.Slice(snapshot.CustomVectors, func(i, j int) bool {
+		return snapshot.CustomVectors[i].ID < snapshot.CustomVectors[j].ID
+	})
+	for name, plugin := range e.plugins {
+		if snapshot.Plugins == nil {
+			snapshot.Plugins = make(map[string]string)
+		}
+		snapshot.Plugins[name] = plugin.Version()
+	}
+
+	ruleset, err := e.rulesets.record(snapshot)
+	if err != nil {
+		log.Printf("Warning: Failed to save ruleset v%d: %v", ruleset.Version, err)
+	}
+	return ruleset
+}
+
+// Rulesets lists every ruleset the engine has run, oldest first
+func (e *Engine) Rulesets() []Ruleset {
+	return e.rulesets.list()
+}
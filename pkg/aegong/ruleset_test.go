@@ -0,0 +1,59 @@
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// TestRulesetHistory tests that rulesets are numbered, reused and kept across restarts
+func TestRulesetHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rulesets.json")
+	engine, err := NewEngine(Config{RulesetPath: path})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	first := engine.Ruleset()
+	if first.Version != 1 || first.Checksum != engine.Version().ConfigChecksum || len(first.Components) == 0 || first.Options["timeout"] == "" {
+		t.Fatalf("The starting configuration should be ruleset v1, got %+v", first)
+	}
+	report, err := engine.Audit(context.Background(), bytes.NewReader([]byte("#!/bin/sh\necho hello\n")))
+	if err != nil {
+		t.Fatalf("Failed to audit agent: %v", err)
+	}
+	if report.Ruleset == nil || report.Ruleset.Version != 1 {
+		t.Errorf("Reports should carry the ruleset they were audited under, got %+v", report.Ruleset)
+	}
+
+	disabled, enabled := false, true
+	if _, err := engine.UpdateComponent("T2", ComponentUpdate{Enabled: &disabled}, "alice", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	second := engine.Ruleset()
+	if second.Version != 2 || second.Checksum == first.Checksum {
+		t.Fatalf("Changing a component should start ruleset v2, got %+v", second)
+	}
+	for _, component := range second.Components {
+		if component.Name == "T2" && component.Enabled {
+			t.Error("The snapshot should record the component's settings")
+		}
+	}
+	if _, err := engine.UpdateComponent("T2", ComponentUpdate{Enabled: &enabled}, "alice", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if reverted := engine.Ruleset(); reverted.Version != 1 {
+		t.Errorf("Reverting a change should return to ruleset v1, got v%d", reverted.Version)
+	}
+
+	restarted, err := NewEngine(Config{RulesetPath: path})
+	if err != nil {
+		t.Fatalf("Failed to restart engine: %v", err)
+	}
+	defer restarted.Close()
+	if rulesets := restarted.Rulesets(); len(rulesets) != 2 || rulesets[1].Version != 2 || !rulesets[0].FirstUsed.Equal(first.FirstUsed) {
+		t.Errorf("The history should be kept across restarts, got %+v", rulesets)
+	}
+}
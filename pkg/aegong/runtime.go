@@ -0,0 +1,215 @@
+package aegong
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Script agents have no shebang or execute bit to rely on, so they run under
+// the interpreter for their language. Dependencies the agent declares can be
+// installed into its container first: Python packages into a virtual
+// environment and npm packages into node_modules. Installs only accept
+// wheels and skip npm scripts, so no package code runs outside the sandbox.
+
+const (
+	dependencyInstallTimeout = 2 * time.Minute
+	maxDependencySize        = 512 << 20 // Room the container gets for installing
+	maxDependencies          = 50
+	maxInstallOutput         = 500 // Bytes of installer output kept in errors
+)
+
+// ScriptRuntime records how a script agent was run
+type ScriptRuntime struct {
+	Language     string   `json:"language"`
+	Interpreter  string   `json:"interpreter"`
+	Harness      bool     `json:"harness"`                // Traced by the language harness
+	Dependencies []string `json:"dependencies,omitempty"` // Declared by the agent or its manifest
+	Installed    bool     `json:"installed"`
+	InstallNote  string   `json:"install_note,omitempty"` // Why dependencies were not installed
+
+	installedSize int64 // Container space the dependencies take up
+}
+
+// dependencyInstallEnabled reports whether declared dependencies are installed
+func dependencyInstallEnabled() bool {
+	return os.Getenv("AEGONG_INSTALL_DEPENDENCIES") == "1"
+}
+
+// selectRuntime returns the runtime for a script agent whose interpreter is
+// installed, or nil to run the binary directly. Unlike selectHarness it
+// ignores AEGONG_DISABLE_HARNESS.
+func selectRuntime(binary []byte) *scriptHarness {
+	var script *scriptHarness
+	switch detectScriptLanguage(binary) {
+	case pythonTaint:
+		script = pythonHarness
+	case javascriptTaint:
+		script = nodeHarness
+	}
+	if script == nil || script.interpreter() == "" {
+		return nil
+	}
+	return script
+}
+
+// Inline script metadata (PEP 723) and the dependency list inside it
+var (
+	scriptMetadataBlock = regexp.MustCompile(`(?m)^# /// script\r?\n((?:^#(?: .*)?\r?\n)+)^# ///\s*$`)
+	dependencyList      = regexp.MustCompile(`(?s)(?:^|\n)dependencies\s*=\s*\[(.*?)\]`)
+	quotedString        = regexp.MustCompile(`"([^"\n]*)"|'([^'\n]*)'`)
+)
+
+// inlineDependencies returns the dependencies a Python agent declares in its
+// inline script metadata
+func inlineDependencies(binary []byte) []string {
+	block := scriptMetadataBlock.FindSubmatch(binary)
+	if block == nil {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(string(block[1]), "\n") {
+		line = strings.TrimPrefix(strings.TrimRight(line, "\r"), "#")
+		lines = append(lines, strings.TrimPrefix(line, " "))
+	}
+
+	list := dependencyList.FindStringSubmatch(strings.Join(lines, "\n"))
+	if list == nil {
+		return nil
+	}
+	var dependencies []string
+	for _, match := range quotedString.FindAllStringSubmatch(list[1], -1) {
+		dependencies = append(dependencies, match[1]+match[2])
+	}
+	return dependencies
+}
+
+// declaredDependencies merges the agent's own and its manifest's
+// dependencies, dropping duplicates and anything an installer would read as
+// an option
+func declaredDependencies(language string, binary []byte, manifest []string) []string {
+	var all []string
+	if language == pythonHarness.language {
+		all = inlineDependencies(binary)
+	}
+	all = append(all, manifest...)
+
+	seen := make(map[string]bool)
+	var dependencies []string
+	for _, dependency := range all {
+		dependency = strings.TrimSpace(dependency)
+		if dependency == "" || strings.HasPrefix(dependency, "-") || seen[dependency] {
+			continue
+		}
+		seen[dependency] = true
+		dependencies = append(dependencies, dependency)
+		if len(dependencies) == maxDependencies {
+			break
+		}
+	}
+	return dependencies
+}
+
+// runInstaller runs a package installer, returning its output on failure
+func runInstaller(ctx context.Context, name string, args ...string) error {
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		text := strings.TrimSpace(string(output))
+		if len(text) > maxInstallOutput {
+			text = "..." + text[len(text)-maxInstallOutput:]
+		}
+		return fmt.Errorf("%s failed: %v: %s", filepath.Base(name), err, text)
+	}
+	return nil
+}
+
+// installPythonDependencies creates a virtual environment in the container
+// with interpreter and installs wheels of the dependencies into it
+func installPythonDependencies(ctx context.Context, interpreter, containerDir string, dependencies []string) (string, error) {
+	venv := filepath.Join(containerDir, ".venv")
+	if err := runInstaller(ctx, interpreter, "-m", "venv", venv); err != nil {
+		return "", err
+	}
+	python := filepath.Join(venv, "bin", "python")
+	args := append([]string{"-m", "pip", "install", "--only-binary=:all:", "--no-input", "--no-cache-dir", "--disable-pip-version-check", "--"}, dependencies...)
+	if err := runInstaller(ctx, python, args...); err != nil {
+		return "", err
+	}
+	return python, nil
+}
+
+// installNodeDependencies installs the dependencies into the container's
+// node_modules without running their scripts
+func installNodeDependencies(ctx context.Context, interpreter, containerDir string, dependencies []string) (string, error) {
+	npm := findInterpreter("AEGONG_NPM", "/usr/bin/npm", "/usr/local/bin/npm")
+	if npm == "" {
+		return "", fmt.Errorf("npm is not installed")
+	}
+	args := append([]string{"install", "--ignore-scripts", "--no-audit", "--no-fund", "--no-save", "--prefix", containerDir, "--"}, dependencies...)
+	if err := runInstaller(ctx, npm, args...); err != nil {
+		return "", err
+	}
+	return interpreter, nil
+}
+
+// scriptCommand provisions a script agent's runtime in its container and
+// returns the command that runs the agent at agentPath
+func (h *scriptHarness) scriptCommand(ctx context.Context, binary []byte, agentPath string, container *CustomContainer, writeLog func(string, ...interface{})) (*exec.Cmd, *ScriptRuntime) {
+	info := &ScriptRuntime{
+		Language:     h.language,
+		Interpreter:  h.interpreter(),
+		Dependencies: declaredDependencies(h.language, binary, container.dependencies),
+	}
+
+	if len(info.Dependencies) > 0 {
+		if dependencyInstallEnabled() {
+			h.provision(ctx, int64(len(binary)), container, info)
+		} else {
+			info.InstallNote = "dependency installation is disabled (AEGONG_INSTALL_DEPENDENCIES)"
+		}
+	}
+
+	if harnessEnabled() {
+		cmd, err := h.command(info.Interpreter, container.FileSystem, agentPath)
+		if err == nil {
+			info.Harness = true
+			return cmd, info
+		}
+		writeLog("WARNING: Running without %s harness: %v\n", h.language, err)
+	}
+	return exec.Command(info.Interpreter, h.plainArgs(agentPath)...), info
+}
+
+// provision installs the agent's dependencies, growing a size-limited
+// container so they don't count against the agent's disk quota
+func (h *scriptHarness) provision(ctx context.Context, binarySize int64, container *CustomContainer, info *ScriptRuntime) {
+	if container.DiskQuotaEnforced {
+		if err := resizeContainerFS(container.FileSystem, container.DiskQuota+binarySize+maxDependencySize); err != nil {
+			logf(ctx, "Warning: %v", err)
+		}
+	}
+	before, _ := diskUsage(container, 0)
+
+	installCtx, cancel := context.WithTimeout(ctx, dependencyInstallTimeout)
+	defer cancel()
+	interpreter, err := h.install(installCtx, info.Interpreter, container.FileSystem, info.Dependencies)
+	if err != nil {
+		info.InstallNote = err.Error()
+	} else {
+		info.Installed = true
+		info.Interpreter = interpreter
+	}
+
+	after, _ := diskUsage(container, 0)
+	info.installedSize = max(after-before, 0)
+	if container.DiskQuotaEnforced {
+		if err := resizeContainerFS(container.FileSystem, container.DiskQuota+binarySize+info.installedSize); err != nil {
+			logf(ctx, "Warning: %v", err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package aegong
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestDeclaredDependencies tests reading inline script metadata and merging manifest dependencies
+func TestDeclaredDependencies(t *testing.T) {
+	agent := []byte(`# /// script
+# requires-python = ">=3.11"
+# dependencies = [
+#   "requests<3",
+#   'rich',
+# ]
+# ///
+import requests
+`)
+	if got := inlineDependencies(agent); !reflect.DeepEqual(got, []string{"requests<3", "rich"}) {
+		t.Fatalf("Should read the inline dependencies, got %v", got)
+	}
+	if got := inlineDependencies([]byte("# dependencies = ['requests']\nimport requests\n")); got != nil {
+		t.Fatalf("Dependencies outside a script block should be ignored, got %v", got)
+	}
+
+	got := declaredDependencies("python", agent, []string{"rich", "--index-url=http://evil", "httpx"})
+	if !reflect.DeepEqual(got, []string{"requests<3", "rich", "httpx"}) {
+		t.Fatalf("Should merge dependencies without duplicates or options, got %v", got)
+	}
+	if got := declaredDependencies("javascript", agent, []string{"lodash@4"}); !reflect.DeepEqual(got, []string{"lodash@4"}) {
+		t.Fatalf("JavaScript agents should only take manifest dependencies, got %v", got)
+	}
+}
+
+// TestScriptRuntimeWithoutHarness tests that script agents run under their interpreter with the harness disabled
+func TestScriptRuntimeWithoutHarness(t *testing.T) {
+	requireSandbox(t)
+	if pythonHarness.interpreter() == "" {
+		t.Skip("python3 is not installed")
+	}
+	t.Setenv("AEGONG_DISABLE_HARNESS", "1")
+	engine := newTestEngine(t)
+
+	container, err := engine.createIsolatedContainer("runtime-test")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer engine.destroyContainer(container.ID)
+
+	agent := []byte(`# /// script
+# dependencies = ["requests"]
+# ///
+import os
+
+def main():
+    print("ran in", os.getcwd())
+
+main()
+`)
+	executionLog := engine.simulateExecution(context.Background(), agent, container)
+	if container.ExecutionError != "" || !strings.Contains(executionLog, "ran in") {
+		t.Fatalf("Agent without a shebang should run under python:\n%s", executionLog)
+	}
+	runtime := container.Runtime
+	if runtime == nil || runtime.Language != "python" || runtime.Harness || container.Harness != "" {
+		t.Fatalf("Runtime should be python without the harness, got %+v", runtime)
+	}
+	if runtime.Installed || !strings.Contains(runtime.InstallNote, "AEGONG_INSTALL_DEPENDENCIES") {
+		t.Fatalf("Dependencies should not be installed by default, got %+v", runtime)
+	}
+}
+
+// writeTestWheel builds a pure-Python wheel of a module that prints a marker
+func writeTestWheel(t *testing.T, dir string) {
+	file, err := os.Create(filepath.Join(dir, "aegongdep-1.0-py3-none-any.whl"))
+	if err != nil {
+		t.Fatalf("Failed to create wheel: %v", err)
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	files := map[string]string{
+		"aegongdep/__init__.py":                 "MARKER = 'dependency loaded'\n",
+		"aegongdep-1.0.dist-info/METADATA":      "Metadata-Version: 2.1\nName: aegongdep\nVersion: 1.0\n",
+		"aegongdep-1.0.dist-info/WHEEL":         "Wheel-Version: 1.0\nGenerator: aegong-test\nRoot-Is-Purelib: true\nTag: py3-none-any\n",
+		"aegongdep-1.0.dist-info/RECORD":        "aegongdep/__init__.py,,\naegongdep-1.0.dist-info/METADATA,,\naegongdep-1.0.dist-info/WHEEL,,\naegongdep-1.0.dist-info/RECORD,,\n",
+		"aegongdep-1.0.dist-info/top_level.txt": "aegongdep\n",
+	}
+	for name, content := range files {
+		writer, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to write wheel: %v", err)
+		}
+		writer.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Failed to write wheel: %v", err)
+	}
+}
+
+// TestInstallDependencies tests that declared dependencies are installed into a virtual environment
+func TestInstallDependencies(t *testing.T) {
+	requireSandbox(t)
+	python := pythonHarness.interpreter()
+	if python == "" || exec.Command(python, "-c", "import venv, ensurepip").Run() != nil {
+		t.Skip("python3 with venv is not installed")
+	}
+	// pip installs from a local wheel instead of the network
+	wheels := t.TempDir()
+	writeTestWheel(t, wheels)
+	t.Setenv("PIP_NO_INDEX", "1")
+	t.Setenv("PIP_FIND_LINKS", wheels)
+	t.Setenv("AEGONG_INSTALL_DEPENDENCIES", "1")
+	engine := newTestEngine(t)
+
+	container, err := engine.createIsolatedContainer("dependency-test")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer engine.destroyContainer(container.ID)
+	container.dependencies = []string{"aegongdep"}
+
+	agent := []byte("import aegongdep\n\ndef main():\n    print(aegongdep.MARKER)\n\nmain()\n")
+	executionLog := engine.simulateExecution(context.Background(), agent, container)
+	if container.Runtime == nil || !container.Runtime.Installed {
+		t.Fatalf("Dependencies should be installed, got %+v:\n%s", container.Runtime, executionLog)
+	}
+	if !strings.Contains(executionLog, "dependency loaded") {
+		t.Fatalf("Agent should import its installed dependency:\n%s", executionLog)
+	}
+	if container.DiskUsage >= container.Runtime.installedSize {
+		t.Errorf("Installed dependencies should not count as agent disk usage, got %d of %d", container.DiskUsage, container.Runtime.installedSize)
+	}
+}
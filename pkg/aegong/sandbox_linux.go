@@ -0,0 +1,653 @@
+//go:build linux
+
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The sandbox runs agents under ptrace in Linux namespaces, cgroups and
+// Landlock. Other platforms build sandbox_other.go instead and audit in
+// static-only mode.
+
+const sandboxSupported = true
+
+// PTRACE_O_EXITKILL is not exported by the syscall package
+const ptraceOExitKill = 0x100000
+
+// killSandboxProcess stops an agent still running when its container is destroyed
+func killSandboxProcess(pid int) {
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		syscall.Kill(pid, syscall.SIGKILL)
+	}
+}
+
+func (e *Engine) simulateExecution(ctx context.Context, binary []byte, container *CustomContainer) string {
+	// Real implementation for executing binaries in an isolated environment
+	// with comprehensive monitoring via ptrace and other kernel mechanisms
+
+	// 1. Write binary to container filesystem
+	// The binary does not count against the agent's disk quota
+	if container.DiskQuotaEnforced {
+		if err := resizeContainerFS(container.FileSystem, container.DiskQuota+int64(len(binary))); err != nil {
+			logf(ctx, "Warning: %v", err)
+		}
+	}
+	// Script agents run under their interpreter, traced from inside by a
+	// language harness
+	script := selectRuntime(binary)
+	binaryPath := filepath.Join(container.FileSystem, "agent_binary")
+	if script != nil {
+		binaryPath = filepath.Join(container.FileSystem, script.agentFile)
+	}
+	if err := os.WriteFile(binaryPath, binary, 0755); err != nil {
+		logf(ctx, "Failed to write binary to container: %v", err)
+		e.mutex.Lock()
+		container.ExecutionError = fmt.Sprintf("failed to prepare binary: %v", err)
+		e.mutex.Unlock()
+		return fmt.Sprintf("ERROR: Failed to prepare binary for execution: %v", err)
+	}
+
+	// 2. Set up monitoring and logging
+	var executionLog bytes.Buffer
+	// Create a mutex to protect access to executionLog
+	var logMutex sync.Mutex
+
+	// Safe logging function to prevent concurrent writes to executionLog
+	writeLog := func(format string, args ...interface{}) {
+		logMutex.Lock()
+		defer logMutex.Unlock()
+		executionLog.WriteString(fmt.Sprintf(format, args...))
+	}
+
+	writeLog("[EXECUTION] Container: %s\n", container.ID)
+	writeLog("Binary Size: %d bytes\n", len(binary))
+	writeLog("Memory Limit: %d MB\n", container.MemoryLimit/(1024*1024))
+	writeLog("CPU Limit: %.1f%%\n", container.CPULimit*100)
+	writeLog("Network: %s\n", container.NetworkNS)
+	writeLog("Filesystem: %s\n", container.FileSystem)
+	if container.DiskQuotaEnforced {
+		writeLog("Disk Quota: %d MB (tmpfs)\n", container.DiskQuota/(1024*1024))
+	} else {
+		writeLog("Disk Quota: %d MB (not enforced: tmpfs unavailable)\n", container.DiskQuota/(1024*1024))
+	}
+
+	// 3. Create cgroup for resource limiting (if supported) - but don't add process yet
+	cgroupPath := ""
+	if runtime.GOOS == "linux" {
+		cgroupPath = e.createCgroupStructure(container)
+		if cgroupPath != "" {
+			writeLog("Cgroup: %s\n", cgroupPath)
+			container.CgroupPath = cgroupPath
+		}
+	}
+
+	// 4. Prepare command with appropriate isolation
+	cmd := exec.Command(binaryPath)
+	// Installed dependencies don't count against the agent's disk quota
+	provisioned := int64(len(binary))
+	if script != nil {
+		scriptCmd, info := script.scriptCommand(ctx, binary, binaryPath, container, writeLog)
+		cmd = scriptCmd
+		provisioned += info.installedSize
+		writeLog("Interpreter: %s (%s)\n", info.Language, info.Interpreter)
+		if len(info.Dependencies) > 0 {
+			if info.Installed {
+				writeLog("Dependencies: Installed %s\n", strings.Join(info.Dependencies, ", "))
+			} else {
+				writeLog("WARNING: Dependencies not installed: %s\n", info.InstallNote)
+			}
+		}
+		e.mutex.Lock()
+		container.Runtime = info
+		if info.Harness {
+			container.Harness = info.Language
+		}
+		e.mutex.Unlock()
+		if info.Harness {
+			writeLog("Harness: %s (%s)\n", info.Language, cmd.Path)
+		}
+	}
+
+	// Fast-forward the agent's clock so delayed behaviour shows up in time
+	if clock := applyClock(cmd, binary, container.Harness != ""); clock != nil {
+		if clock.Note != "" {
+			writeLog("Clock: Unchanged (%s)\n", clock.Note)
+		} else {
+			writeLog("Clock: +%v at %gx (%s)\n", time.Duration(clock.Offset*float64(time.Second)), clock.Rate, clock.Method)
+		}
+		e.mutex.Lock()
+		container.Clock = clock
+		e.mutex.Unlock()
+	}
+
+	// Set up process attributes for isolation
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUTS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNS,
+		Ptrace:     true, // Enable ptrace for syscall monitoring
+	}
+
+	// If we're on Linux, we can use more isolation features
+	if runtime.GOOS == "linux" {
+		// Add network namespace isolation if configured
+		if container.NetworkNS == "none" {
+			cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET
+			writeLog("Network: Isolated (namespace)\n")
+		}
+
+		// Set resource limits
+		if rootlessMode() {
+			// Without root, namespaces and the drop to nobody need a user namespace
+			applyUserNamespace(cmd.SysProcAttr)
+			writeLog("User Namespace: Rootless (uid %d mapped to %d)\n", sandboxUID, os.Geteuid())
+		} else {
+			cmd.SysProcAttr.Credential = &syscall.Credential{
+				Uid: sandboxUID, // nobody user
+				Gid: sandboxUID, // nobody group
+			}
+		}
+	}
+
+	// Set up I/O redirection
+	// Log lines are counted as they arrive, for their rate
+	var stdout, stderr bytes.Buffer
+	rates := newRateCounter(time.Now())
+	cmd.Stdout = &lineCounter{w: &stdout, rates: rates}
+	cmd.Stderr = &lineCounter{w: &stderr, rates: rates}
+	cmd.Dir = container.FileSystem
+
+	// Bound how long we wait for output pipes held open by stray descendants
+	cmd.WaitDelay = 5 * time.Second
+
+	// Memory stores shipped with the agent or its dependencies, to diff
+	// against what it leaves behind
+	memoryBefore := snapshotMemoryStores(container.FileSystem)
+
+	// 5. Start the process
+	// The ptrace tracer is the thread that forked the child, so starting the
+	// process and every later ptrace/wait call happen on one locked thread
+	syscallLog := make(map[string]SyscallUse)
+	fileOps := make(map[string]int)
+	networkActivity := false
+	quotaHits := 0
+	var deniedAccesses []DeniedAccess
+	// Only touched by the tracer until it reports the exit code
+	evasion := newEvasionTracer()
+	escape := newEscapeTracer()
+	integrity := newIntegrityTracer(container.integrity, container.FileSystem)
+	credentials := newCredentialTracer()
+	egress := newEgressTracer()
+
+	// Create mutexes to protect access to shared maps
+	var syscallMutex sync.Mutex
+	var fileOpsMutex sync.Mutex
+	var networkMutex sync.Mutex
+
+	startErr := make(chan error, 1)
+	resume := make(chan struct{})
+	// Receives the exit code once the tracer has reaped the process
+	traceDone := make(chan int, 1)
+	var sandboxErr error
+
+	startTime := time.Now()
+	go func() {
+		// No-new-privs and Landlock apply to this thread and are inherited by
+		// the agent, so the thread stays locked and exits with the goroutine
+		runtime.LockOSThread()
+		sandboxErr = restrictSandboxThread(container.FileSystem)
+
+		if err := cmd.Start(); err != nil {
+			startErr <- err
+			return
+		}
+		startErr <- nil
+
+		// Don't let the agent run until the sandbox around it is ready
+		<-resume
+		traceDone <- e.traceProcess(cmd.Process.Pid, writeLog, func(call *tracedSyscall) {
+			// Record the syscall with proper locking
+			syscallMutex.Lock()
+			use := syscallLog[call.Name]
+			if use.Count == 0 {
+				use.First = time.Now()
+			}
+			use.Count++
+			syscallLog[call.Name] = use
+			syscallMutex.Unlock()
+			evasion.syscall(cmd.Process.Pid, call)
+			escape.syscall(cmd.Process.Pid, call)
+			integrity.syscall(cmd.Process.Pid, call)
+			credentials.syscall(cmd.Process.Pid, call)
+			egress.syscall(cmd.Process.Pid, call)
+
+			// Check for specific syscalls of interest with proper locking
+			switch call.Name {
+			case "open", "openat":
+				// For open syscalls, get the filename
+				// This is simplified - in a real implementation you would read the memory
+				// at the address in the registers to get the filename
+				fileOpsMutex.Lock()
+				fileOps["open"]++
+				fileOpsMutex.Unlock()
+			case "read":
+				fileOpsMutex.Lock()
+				fileOps["read"]++
+				fileOpsMutex.Unlock()
+			case "write":
+				fileOpsMutex.Lock()
+				fileOps["write"]++
+				fileOpsMutex.Unlock()
+			case "socket", "connect":
+				networkMutex.Lock()
+				networkActivity = true
+				networkMutex.Unlock()
+			}
+			switch call.Name {
+			case "write", "writev":
+				if call.Args[0] == 1 || call.Args[0] == 2 {
+					rates.add(RateOutputWrites, time.Now(), 1)
+				}
+			case "connect":
+				rates.add(RateConnections, time.Now(), 1)
+			}
+		}, func(call *tracedSyscall, errno syscall.Errno) {
+			escape.failed(errno)
+			integrity.failed(errno)
+			credentials.failed(errno)
+			egress.failed(errno)
+
+			// Writes rejected because the container filesystem is full
+			if isQuotaError(errno) {
+				fileOpsMutex.Lock()
+				quotaHits++
+				fileOpsMutex.Unlock()
+			}
+
+			// Writes outside the container refused by Landlock or file permissions
+			if denied, ok := deniedWrite(cmd.Process.Pid, call, errno); ok {
+				fileOpsMutex.Lock()
+				if len(deniedAccesses) < maxDeniedAccesses {
+					deniedAccesses = append(deniedAccesses, *denied)
+				}
+				fileOpsMutex.Unlock()
+			}
+		})
+	}()
+
+	if err := <-startErr; err != nil {
+		writeLog("ERROR: Failed to start process: %v\n", err)
+		e.mutex.Lock()
+		container.ExecutionError = fmt.Sprintf("failed to start process: %v", err)
+		e.mutex.Unlock()
+		return executionLog.String()
+	}
+
+	// Record the process ID
+	processPID := cmd.Process.Pid
+
+	// Update container's ProcessID with proper locking
+	e.mutex.Lock()
+	container.ProcessID = processPID
+	e.mutex.Unlock()
+
+	writeLog("Process Started: PID %d\n", processPID)
+	if sandboxErr != nil {
+		writeLog("WARNING: Filesystem not Landlocked: %v\n", sandboxErr)
+	} else {
+		writeLog("Landlock: Read-only filesystem except %s\n", container.FileSystem)
+	}
+
+	// Now add the process to the cgroup (this fixes the race condition)
+	if cgroupPath != "" {
+		if err := e.addProcessToCgroup(container, processPID); err != nil {
+			writeLog("WARNING: Failed to add process to cgroup: %v\n", err)
+		} else {
+			writeLog("Process added to cgroup successfully\n")
+		}
+	}
+
+	// The process is still stopped at exec, so the honeypot is ready before
+	// the agent makes its first connection
+	var honeypot *Honeypot
+	if runtime.GOOS == "linux" && container.NetworkNS == "none" && honeypotEnabled() {
+		hp, err := StartHoneypot(processPID)
+		if err != nil {
+			writeLog("WARNING: Failed to start network honeypot: %v\n", err)
+		} else {
+			honeypot = hp
+			writeLog("Network Honeypot: Active (HTTP, DNS, SMTP)\n")
+			writeLog("Internal Egress: Blocked (link-local, RFC1918, shared address space)\n")
+		}
+	}
+
+	// 6. Let the tracer resume the process
+	close(resume)
+
+	// 7. Wait for the process to complete, the execution timeout or cancellation
+	// In soak mode the agent runs longer while its resources are sampled
+	timeout := executionTimeout
+	var soak *SoakResult
+	var sampler <-chan time.Time
+	if d := soakDuration(); d > 0 {
+		timeout = d
+		soak = &SoakResult{Started: startTime, Planned: d.Seconds()}
+		ticker := time.NewTicker(soakSampleInterval(d))
+		defer ticker.Stop()
+		sampler = ticker.C
+		writeLog("Soak Mode: Running for up to %v\n", d)
+	}
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var exitCode int
+	for waiting := true; waiting; {
+		select {
+		case exitCode = <-traceDone:
+			waiting = false
+		case <-sampler:
+			soak.Samples = append(soak.Samples, e.sampleResources(container, processPID, provisioned, time.Since(startTime)))
+		case <-execCtx.Done():
+			// Kill the process; the tracer reaps it and reports back
+			cmd.Process.Kill()
+			if ctx.Err() != nil {
+				writeLog("ERROR: Process execution cancelled: %v\n", ctx.Err())
+			} else if soak != nil {
+				writeLog("Soak Mode: Stopped agent after %v\n", timeout)
+			} else {
+				writeLog("ERROR: Process execution timed out\n")
+			}
+			<-traceDone
+			exitCode = -1
+			waiting = false
+		}
+	}
+
+	// The tracer already reaped the process, so this only drains stdout/stderr
+	cmd.Wait()
+
+	e.mutex.Lock()
+	container.ProcessID = -1
+	e.mutex.Unlock()
+
+	// 8. Collect and record execution data
+	executionTime := time.Since(startTime)
+
+	// Record syscalls with proper locking
+	writeLog("System Calls:\n")
+	syscallMutex.Lock()
+	for syscall, use := range syscallLog {
+		writeLog("  %s: %d times\n", syscall, use.Count)
+	}
+	syscallMutex.Unlock()
+
+	// Record file operations with proper locking
+	writeLog("File Operations:\n")
+	fileOpsMutex.Lock()
+	for op, count := range fileOps {
+		writeLog("  %s: %d times\n", op, count)
+	}
+	fileOpsMutex.Unlock()
+
+	// Record disk usage against the container quota
+	// Child processes are not traced, so a full tmpfs also counts as a hit
+	usage, full := diskUsage(container, provisioned)
+	fileOpsMutex.Lock()
+	hits := quotaHits
+	fileOpsMutex.Unlock()
+	writeLog("Disk Usage: %d KB of %d KB\n", usage/1024, container.DiskQuota/1024)
+	if hits > 0 || full {
+		writeLog("Disk Quota Exceeded: %d traced writes failed\n", hits)
+	}
+	fileOpsMutex.Lock()
+	denied := deniedAccesses
+	fileOpsMutex.Unlock()
+	if len(denied) > 0 {
+		writeLog("Denied Accesses:\n")
+		for _, access := range denied {
+			writeLog("  %s %s: %s\n", access.Syscall, access.Path, access.Error)
+		}
+	}
+
+	if len(evasion.trace.Probes) > 0 {
+		writeLog("Evasion Probes:\n")
+		for _, probe := range evasion.trace.Probes {
+			writeLog("  %s: %s\n", probe.Kind, probe.Detail)
+		}
+	}
+
+	if len(escape.attempts) > 0 {
+		writeLog("Escape Attempts:\n")
+		for _, attempt := range escape.attempts {
+			writeLog("  %s: %s %s\n", attempt.Technique, attempt.Call, attempt.Error)
+		}
+	}
+
+	if len(credentials.accesses) > 0 {
+		writeLog("Credential Accesses:\n")
+		for _, access := range credentials.accesses {
+			writeLog("  %s: %s %s %s\n", access.Kind, access.Source, access.Target, access.Error)
+		}
+	}
+
+	if len(egress.attempts) > 0 {
+		writeLog("Internal Egress Attempts:\n")
+		for _, attempt := range egress.attempts {
+			writeLog("  %s: %s %s %s\n", attempt.Kind, attempt.Syscall, attempt.Address, attempt.Error)
+		}
+	}
+
+	// Processes the tracer does not follow can change registered files too
+	integrityViolations := integrity.violations
+	if container.integrity != nil {
+		integrityViolations = append(integrityViolations, container.integrity.changedFiles(container.FileSystem)...)
+	}
+	if len(integrityViolations) > 0 {
+		writeLog("Integrity Violations:\n")
+		for _, violation := range integrityViolations {
+			writeLog("  %s %s %s\n", violation.Path, violation.Syscall, violation.Error)
+		}
+	}
+
+	memoryStores := diffMemoryStores(memoryBefore, snapshotMemoryStores(container.FileSystem))
+	if len(memoryStores) > 0 {
+		writeLog("Memory Stores:\n")
+		for _, store := range memoryStores {
+			writeLog("  %s %s: %d entries, %d added, %d modified, %d removed, %d poisoned\n",
+				store.Kind, store.Path, store.Entries, store.Added, store.Modified, store.Removed, store.Poisoned)
+		}
+	}
+
+	var harnessEvents []HarnessEvent
+	if container.Harness != "" {
+		harnessEvents = readHarnessEvents(container.FileSystem)
+		writeLog("Harness Events:\n")
+		for _, event := range harnessEvents {
+			writeLog("  %s: %s\n", event.Event, event.Detail)
+		}
+	}
+
+	e.mutex.Lock()
+	container.HarnessEvents = harnessEvents
+	container.Evasion = &evasion.trace
+	container.Escapes = escape.attempts
+	container.SyscallsMade = syscallLog
+	container.IntegrityViolations = integrityViolations
+	container.MemoryStores = memoryStores
+	container.CredentialAccesses = credentials.accesses
+	container.EgressAttempts = egress.attempts
+	container.DiskUsage = usage
+	container.DiskQuotaHits = hits
+	container.DiskFull = full
+	container.Landlocked = sandboxErr == nil
+	container.DeniedAccesses = denied
+	e.mutex.Unlock()
+
+	// Record network activity with proper locking
+	networkMutex.Lock()
+	if networkActivity {
+		writeLog("Network Activity: Detected\n")
+	} else {
+		writeLog("Network Activity: None detected\n")
+	}
+	networkMutex.Unlock()
+
+	// Record egress attempts caught by the honeypot
+	if honeypot != nil {
+		captures := honeypot.Stop()
+		writeLog("Honeypot Captures: %d\n", len(captures))
+		for _, capture := range captures {
+			writeLog("  [%s] %s\n", capture.Service, capture.Summary)
+		}
+
+		e.mutex.Lock()
+		container.NetworkCaptures = captures
+		e.mutex.Unlock()
+		for _, capture := range captures {
+			rates.add(RateRequests, capture.Timestamp, 1)
+		}
+	}
+
+	// Record how fast the agent acted
+	actionRates := rates.rates(executionTime)
+	writeLog("Action Rates:\n")
+	for _, rate := range actionRates {
+		writeLog("  %s: %d total, peak %d/s at %.0fs\n", rate.Kind, rate.Total, rate.Peak, rate.PeakAt)
+	}
+	e.mutex.Lock()
+	container.ActionRates = actionRates
+	e.mutex.Unlock()
+
+	// Record the soak run's samples
+	if soak != nil {
+		soak.Duration = executionTime.Seconds()
+		writeLog("Soak Samples: %d over %v\n", len(soak.Samples), executionTime.Round(time.Second))
+		e.mutex.Lock()
+		container.Soak = soak
+		e.mutex.Unlock()
+	}
+
+	// Record resource usage
+	if container.CgroupPath != "" {
+		memUsage := e.getCgroupMemoryUsage(container.CgroupPath)
+		cpuUsage := e.getCgroupCpuUsage(container.CgroupPath)
+		writeLog("Resource Usage: Memory: %d KB, CPU: %.2f%%\n",
+			memUsage/1024, cpuUsage)
+	}
+
+	// Record stdout/stderr
+	if stdout.Len() > 0 {
+		writeLog("Standard Output:\n")
+		writeLog("%s", stdout.String())
+	}
+
+	if stderr.Len() > 0 {
+		writeLog("Standard Error:\n")
+		writeLog("%s", stderr.String())
+	}
+
+	// Record exit code
+	writeLog("Process Completed: Exit code %d\n", exitCode)
+	writeLog("Execution Time: %v\n", executionTime)
+
+	// 9. Clean up
+	// Note: Cgroup cleanup is now handled in destroyContainer()
+
+	// Remove the binary
+	os.Remove(binaryPath)
+
+	return executionLog.String()
+}
+
+// tracedSyscall is a syscall of the traced agent as read at its entry, named
+// and with its arguments in order whatever the host architecture
+type tracedSyscall struct {
+	Number uint64
+	Name   string
+	Args   [6]uint64
+}
+
+// traceProcess single-steps a ptrace-stopped process from syscall to syscall
+// until it exits, reporting every syscall entry to onSyscall and every failed
+// syscall to onError. It must run on the locked OS thread that started the
+// process and returns the exit code.
+func (e *Engine) traceProcess(pid int, writeLog func(string, ...interface{}), onSyscall func(*tracedSyscall), onError func(*tracedSyscall, syscall.Errno)) int {
+	// Wait for the process to stop (it should stop immediately due to ptrace)
+	var status syscall.WaitStatus
+	if _, err := syscall.Wait4(pid, &status, 0, nil); err != nil {
+		writeLog("ERROR: Failed to wait for process: %v\n", err)
+		return -1
+	}
+
+	// Kill the tracee if we go away, and tell syscall stops apart from signals
+	syscall.PtraceSetOptions(pid, syscall.PTRACE_O_TRACESYSGOOD|ptraceOExitKill)
+
+	inSyscall := false
+	var call tracedSyscall
+	signal := 0
+	for {
+		if status.Exited() {
+			return status.ExitStatus()
+		}
+		if status.Signaled() {
+			return -1
+		}
+
+		// Allow the process to continue until the next syscall entry or exit
+		if err := syscall.PtraceSyscall(pid, signal); err != nil {
+			break
+		}
+		if _, err := syscall.Wait4(pid, &status, 0, nil); err != nil {
+			break
+		}
+
+		signal = 0
+		if !status.Stopped() {
+			continue
+		}
+		if status.StopSignal() != syscall.SIGTRAP|0x80 {
+			// Signal delivery stop: pass the signal on to the agent
+			if status.StopSignal() != syscall.SIGTRAP {
+				signal = int(status.StopSignal())
+			}
+			continue
+		}
+
+		// Stops alternate between syscall entry and exit
+		inSyscall = !inSyscall
+
+		regs := &syscall.PtraceRegs{}
+		if err := syscall.PtraceGetRegs(pid, regs); err != nil {
+			continue
+		}
+
+		if inSyscall {
+			// Arguments are read at entry; some architectures reuse their
+			// registers for the return value
+			call.Number, call.Args = syscallRegs(regs)
+			call.Name = getSyscallName(call.Number)
+			onSyscall(&call)
+		} else if ret := syscallReturn(regs); ret < 0 && ret > -4096 {
+			// Failed syscalls return -errno
+			onError(&call, syscall.Errno(-ret))
+		}
+	}
+
+	// Tracing failed; make sure the process is gone and reaped
+	syscall.Kill(pid, syscall.SIGKILL)
+	for {
+		if _, err := syscall.Wait4(pid, &status, 0, nil); err != nil || status.Exited() || status.Signaled() {
+			break
+		}
+	}
+	return -1
+}
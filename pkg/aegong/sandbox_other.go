@@ -0,0 +1,57 @@
+//go:build !linux
+
+package aegong
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// Without the Linux sandbox the engine audits in static-only mode: uploads
+// are validated, statically analyzed and checked by the SHIELD modules, and
+// dynamic detectors are reported as skipped.
+const sandboxSupported = false
+
+var errSandboxUnsupported = fmt.Errorf("the sandbox is not supported on %s", runtime.GOOS)
+
+func killSandboxProcess(pid int) {
+	if process, err := os.FindProcess(pid); err == nil {
+		process.Kill()
+	}
+}
+
+// simulateExecution never runs the agent; NewEngine forces static-only mode
+// so it is not called by audits
+func (e *Engine) simulateExecution(ctx context.Context, binary []byte, container *CustomContainer) string {
+	e.mutex.Lock()
+	container.ExecutionError = errSandboxUnsupported.Error()
+	e.mutex.Unlock()
+	return fmt.Sprintf("[EXECUTION] Container: %s\nERROR: %v\n", container.ID, errSandboxUnsupported)
+}
+
+// StartHoneypot needs network namespaces, so it always fails here
+func StartHoneypot(pid int) (*Honeypot, error) {
+	return nil, errSandboxUnsupported
+}
+
+func mountContainerFS(path string, quota int64) error {
+	return errSandboxUnsupported
+}
+
+func resizeContainerFS(path string, size int64) error {
+	return errSandboxUnsupported
+}
+
+func unmountContainerFS(path string) error {
+	return errSandboxUnsupported
+}
+
+func filesystemUsage(path string) (int64, bool, error) {
+	return 0, false, errSandboxUnsupported
+}
+
+func checkWritable(path string) error {
+	return errSandboxUnsupported
+}
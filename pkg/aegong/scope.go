@@ -0,0 +1,99 @@
+package aegong
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AuditScope selects the threat vectors and SHIELD modules an audit runs,
+// e.g. a quick T4/T6 scan in CI. The zero value runs everything.
+type AuditScope struct {
+	Vectors     []string `json:"vectors,omitempty"` // Detector names such as "T4"
+	Shields     []string `json:"shields,omitempty"`
+	SkipShields bool     `json:"skip_shields,omitempty"` // Run no SHIELD modules
+}
+
+// AuditOptions are the inputs of an audit besides the agent itself
+type AuditOptions struct {
+	Bundle   *SignatureBundle // Detached signature to verify, or nil
+	Manifest *AgentManifest   // Declared capabilities to check, or nil
+	Scope    AuditScope
+	// CheckpointID saves the audit's progress under this ID as it runs, and
+	// resumes it from the last completed phase if a checkpoint exists
+	CheckpointID string
+	// Validation is what ValidateAgent made of the agent, used to choose how
+	// it is run; nil leaves that to the agent's format
+	Validation *AgentValidationResult
+}
+
+// auditSelection is a validated scope; a nil selection runs everything
+type auditSelection struct {
+	scope   AuditScope
+	vectors map[ThreatVector]bool // nil runs every vector
+	shields map[string]bool       // nil runs every shield
+}
+
+// CheckScope returns an error if scope names an unknown vector or shield
+func (e *Engine) CheckScope(scope AuditScope) error {
+	_, err := e.selection(scope)
+	return err
+}
+
+func (e *Engine) selection(scope AuditScope) (*auditSelection, error) {
+	if len(scope.Vectors) == 0 && len(scope.Shields) == 0 && !scope.SkipShields {
+		return nil, nil
+	}
+	selection := &auditSelection{scope: scope}
+
+	if len(scope.Vectors) > 0 {
+		selection.vectors = make(map[ThreatVector]bool)
+		for _, name := range scope.Vectors {
+			found := false
+			for vector := range e.threatDetectors {
+				if detectorName(vector) == strings.ToUpper(name) {
+					selection.vectors[vector] = true
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unknown threat vector %q", name)
+			}
+		}
+	}
+
+	if scope.SkipShields {
+		selection.shields = make(map[string]bool)
+	} else if len(scope.Shields) > 0 {
+		selection.shields = make(map[string]bool)
+		for _, name := range scope.Shields {
+			if _, ok := e.shieldModules[name]; !ok {
+				return nil, fmt.Errorf("unknown SHIELD module %q", name)
+			}
+			selection.shields[name] = true
+		}
+	}
+	return selection, nil
+}
+
+func (s *auditSelection) vector(vector ThreatVector) bool {
+	return s == nil || s.vectors == nil || s.vectors[vector]
+}
+
+func (s *auditSelection) shield(name string) bool {
+	return s == nil || s.shields == nil || s.shields[name]
+}
+
+// filter drops threats of vectors outside the selection, including those
+// raised by analyses that are not tied to a single detector
+func (s *auditSelection) filter(threats []ThreatDetection) []ThreatDetection {
+	if s == nil || s.vectors == nil {
+		return threats
+	}
+	var selected []ThreatDetection
+	for _, threat := range threats {
+		if s.vectors[threat.Vector] {
+			selected = append(selected, threat)
+		}
+	}
+	return selected
+}
@@ -0,0 +1,55 @@
+package aegong
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestAuditScope tests that scoped audits only run and report the selected vectors and shields
+func TestAuditScope(t *testing.T) {
+	engine := newTestEngine(t)
+
+	if err := engine.CheckScope(AuditScope{Vectors: []string{"T42"}}); err == nil {
+		t.Fatal("Unknown vectors should be rejected")
+	}
+	if err := engine.CheckScope(AuditScope{Shields: []string{"nope"}}); err == nil {
+		t.Fatal("Unknown shields should be rejected")
+	}
+
+	agentPath := filepath.Join(t.TempDir(), "agent.py")
+	agent := "import os\n\ncommand = input()\nos.system(command)\neval(input())\n"
+	if err := os.WriteFile(agentPath, []byte(agent), 0644); err != nil {
+		t.Fatalf("Failed to write agent: %v", err)
+	}
+
+	scope := AuditScope{Vectors: []string{"t4", "T6"}, SkipShields: true}
+	report, err := engine.AuditAgentWithOptions(context.Background(), agentPath, AuditOptions{Scope: scope})
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+
+	if len(report.Threats) == 0 {
+		t.Fatal("Tainted command execution should still be reported")
+	}
+	for _, threat := range report.Threats {
+		if threat.Vector != T4_UNAUTHORIZED_ACTION && threat.Vector != T6_IDENTITY_SPOOFING {
+			t.Fatalf("Only T4 and T6 threats should be reported, got %s", threat.VectorName)
+		}
+	}
+	if len(report.ShieldResults) != 0 {
+		t.Fatalf("Shields should be skipped, got %v", report.ShieldResults)
+	}
+
+	if report.Coverage.Scope == nil || len(report.Coverage.Scope.Vectors) != 2 || !report.Coverage.Scope.SkipShields {
+		t.Fatalf("Coverage should record the scope, got %+v", report.Coverage.Scope)
+	}
+	statuses := make(map[string]string)
+	for _, component := range report.Coverage.Components {
+		statuses[component.Phase+"/"+component.Name] = component.Status
+	}
+	if statuses["static/T4"] != CoverageRan || statuses["static/T1"] != CoverageExcluded || statuses["shield/integrity"] != CoverageExcluded {
+		t.Fatalf("Components outside the scope should be excluded, got %v", statuses)
+	}
+}
@@ -0,0 +1,117 @@
+package aegong
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"path"
+	"time"
+)
+
+// Each built-in detector has a canary: a small agent it must always flag.
+// Running them through the engine's current settings catches configuration
+// that silently stops a detector from reporting anything, such as a disabled
+// detector or a confidence threshold no finding can reach.
+
+//go:embed canaries/*.py
+var canaryFiles embed.FS
+
+// Canary each built-in detector must flag
+var canaries = []struct {
+	vector ThreatVector
+	file   string
+}{
+	{T1_REASONING_HIJACK, "t1_reasoning_hijack.py"},
+	{T2_OBJECTIVE_CORRUPTION, "t2_objective_corruption.py"},
+	{T3_MEMORY_POISONING, "t3_memory_poisoning.py"},
+	{T4_UNAUTHORIZED_ACTION, "t4_unauthorized_action.py"},
+	{T5_RESOURCE_MANIPULATION, "t5_resource_manipulation.py"},
+	{T6_IDENTITY_SPOOFING, "t6_identity_spoofing.py"},
+	{T7_TRUST_MANIPULATION, "t7_trust_manipulation.py"},
+	{T8_OVERSIGHT_SATURATION, "t8_oversight_saturation.py"},
+	{T9_GOVERNANCE_EVASION, "t9_governance_evasion.py"},
+}
+
+// SelfTestResult is the outcome of running every detector against its canary
+type SelfTestResult struct {
+	CheckedAt time.Time        `json:"checked_at"`
+	Detectors []DetectorCanary `json:"detectors"`
+}
+
+// DetectorCanary is whether a detector flagged its canary
+type DetectorCanary struct {
+	Detector   string  `json:"detector"`
+	Canary     string  `json:"canary"`
+	Passed     bool    `json:"passed"`
+	Confidence float64 `json:"confidence,omitempty"` // Highest confidence of the canary's findings
+	Reason     string  `json:"reason,omitempty"`     // Why the canary was missed
+}
+
+// Failures lists the detectors that missed their canary
+func (r *SelfTestResult) Failures() []DetectorCanary {
+	var failures []DetectorCanary
+	for _, detector := range r.Detectors {
+		if !detector.Passed {
+			failures = append(failures, detector)
+		}
+	}
+	return failures
+}
+
+// SelfTest runs every built-in detector against its canary with the
+// engine's current component settings
+func (e *Engine) SelfTest(ctx context.Context) *SelfTestResult {
+	result := &SelfTestResult{CheckedAt: time.Now()}
+	for _, canary := range canaries {
+		result.Detectors = append(result.Detectors, e.testCanary(ctx, canary.vector, canary.file))
+	}
+	return result
+}
+
+// testCanary runs one detector against its canary the way static analysis would
+func (e *Engine) testCanary(ctx context.Context, vector ThreatVector, file string) DetectorCanary {
+	outcome := DetectorCanary{Detector: detectorName(vector), Canary: file}
+	binary, err := canaryFiles.ReadFile(path.Join("canaries", file))
+	if err != nil {
+		outcome.Reason = fmt.Sprintf("canary is missing: %v", err)
+		return outcome
+	}
+
+	detector := e.threatDetectors[vector]
+	if detector == nil {
+		outcome.Reason = "detector is not registered"
+		return outcome
+	}
+	enabled, minConfidence := e.detectorSettings(vector)
+	if !enabled {
+		outcome.Reason = "detector is disabled"
+		return outcome
+	}
+
+	analysis := NewAnalysisContext(PhaseStatic, binary, nil)
+	if applies, reason := analysis.applies(detector); !applies {
+		outcome.Reason = "detector does not apply to its canary: " + reason
+		return outcome
+	}
+	threats, failure := e.safeAnalyze(ctx, vector, detector, analysis)
+	if failure != nil {
+		outcome.Reason = "detector panicked: " + failure.Error
+		return outcome
+	}
+	found := false
+	for _, threat := range threats {
+		if threat.Vector == vector {
+			found = true
+			outcome.Confidence = max(outcome.Confidence, threat.Confidence)
+		}
+	}
+	switch {
+	case !found:
+		outcome.Reason = "no finding"
+	case outcome.Confidence < minConfidence:
+		outcome.Reason = fmt.Sprintf("finding confidence %.2f is under the detector's min_confidence %.2f", outcome.Confidence, minConfidence)
+	default:
+		outcome.Passed = true
+	}
+	return outcome
+}
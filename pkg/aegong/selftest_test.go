@@ -0,0 +1,43 @@
+package aegong
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestSelfTest tests that every built-in detector flags its canary, and that
+// disabling a detector or raising its threshold out of reach fails it
+func TestSelfTest(t *testing.T) {
+	engine, err := NewEngine(Config{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	result := engine.SelfTest(context.Background())
+	if len(result.Detectors) != 9 {
+		t.Fatalf("Should test the 9 built-in detectors, got %d", len(result.Detectors))
+	}
+	if failures := result.Failures(); len(failures) != 0 {
+		t.Fatalf("Every detector should flag its canary, got %+v", failures)
+	}
+
+	disabled := false
+	if _, err := engine.UpdateComponent("T3", ComponentUpdate{Enabled: &disabled}, "alice", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	unreachable := 1.0
+	if _, err := engine.UpdateComponent("T7", ComponentUpdate{MinConfidence: &unreachable}, "alice", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	failures := engine.SelfTest(context.Background()).Failures()
+	if len(failures) != 2 {
+		t.Fatalf("Should fail the two detectors, got %+v", failures)
+	}
+	if failures[0].Detector != "T3" || failures[0].Reason != "detector is disabled" {
+		t.Errorf("Should fail the disabled detector, got %+v", failures[0])
+	}
+	if failures[1].Detector != "T7" || !strings.Contains(failures[1].Reason, "min_confidence") {
+		t.Errorf("Should fail the detector whose threshold is out of reach, got %+v", failures[1])
+	}
+}
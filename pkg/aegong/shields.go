@@ -1,6 +1,7 @@
-package main
+package aegong
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"math"
@@ -10,7 +11,7 @@ import (
 // Segmentation Validator
 type SegmentationValidator struct{}
 
-func (s *SegmentationValidator) Validate(binary []byte, container *CustomContainer) (bool, map[string]interface{}) {
+func (s *SegmentationValidator) Validate(ctx context.Context, binary []byte, container *CustomContainer) (bool, map[string]interface{}) {
 	results := make(map[string]interface{})
 
 	// Check network isolation
@@ -20,6 +21,8 @@ func (s *SegmentationValidator) Validate(binary []byte, container *CustomContain
 	// Check filesystem isolation
 	fsIsolated := strings.HasPrefix(container.FileSystem, "/tmp/aegong-")
 	results["filesystem_isolated"] = fsIsolated
+	results["disk_quota_enforced"] = container.DiskQuotaEnforced
+	results["landlock_enforced"] = container.Landlocked
 
 	// Check resource limits
 	resourceLimited := container.MemoryLimit > 0 && container.CPULimit > 0
@@ -58,7 +61,7 @@ func (s *SegmentationValidator) GetModuleName() string {
 // Heuristic Pattern Detector
 type HeuristicPatternDetector struct{}
 
-func (h *HeuristicPatternDetector) Validate(binary []byte, container *CustomContainer) (bool, map[string]interface{}) {
+func (h *HeuristicPatternDetector) Validate(ctx context.Context, binary []byte, container *CustomContainer) (bool, map[string]interface{}) {
 	results := make(map[string]interface{})
 
 	binaryStr := string(binary)
@@ -86,14 +89,37 @@ func (h *HeuristicPatternDetector) Validate(binary []byte, container *CustomCont
 	anomalousPatterns := detectAnomalousPatterns(binary)
 	results["anomalous_patterns"] = len(anomalousPatterns)
 
+	// Per-section entropy finds packed code that whole-file entropy averages out
+	packing := container.Packing
+	if packing == nil {
+		packing = analyzeSections(binary)
+	}
+	unanalyzed := false
+	if packing != nil {
+		results["format"] = packing.Format
+		results["sections"] = packing.Sections
+		results["packed"] = packing.Packed
+		if packing.Packer != "" {
+			results["packer"] = packing.Packer
+		}
+		if packing.Unpacked != nil {
+			results["unpacked"] = packing.Unpacked
+		}
+		// Packed code the detectors could not see into
+		unanalyzed = packing.Packed && (packing.Unpacked == nil || packing.Unpacked.Error != "")
+	}
+
 	// Calculate heuristic score
 	score := 1.0
 	if suspiciousCount > 3 {
 		score -= 0.3
 	}
-	if entropy > 7.5 {
+	if entropy > 7.5 || (packing != nil && packing.Packed) {
 		score -= 0.3
 	}
+	if unanalyzed {
+		score -= 0.2
+	}
 	if len(anomalousPatterns) > 5 {
 		score -= 0.4
 	}
@@ -186,7 +212,7 @@ func detectUnusualSequences(binary []byte) bool {
 // Integrity Checker
 type IntegrityChecker struct{}
 
-func (i *IntegrityChecker) Validate(binary []byte, container *CustomContainer) (bool, map[string]interface{}) {
+func (i *IntegrityChecker) Validate(ctx context.Context, binary []byte, container *CustomContainer) (bool, map[string]interface{}) {
 	results := make(map[string]interface{})
 
 	// Calculate hash
@@ -276,7 +302,7 @@ func detectCodeSigning(binary []byte) bool {
 // Privilege Escalation Detector
 type PrivilegeEscalationDetector struct{}
 
-func (p *PrivilegeEscalationDetector) Validate(binary []byte, container *CustomContainer) (bool, map[string]interface{}) {
+func (p *PrivilegeEscalationDetector) Validate(ctx context.Context, binary []byte, container *CustomContainer) (bool, map[string]interface{}) {
 	results := make(map[string]interface{})
 
 	binaryStr := string(binary)
@@ -314,7 +340,7 @@ func (p *PrivilegeEscalationDetector) GetModuleName() string {
 // Audit Trail Validator
 type AuditTrailValidator struct{}
 
-func (a *AuditTrailValidator) Validate(binary []byte, container *CustomContainer) (bool, map[string]interface{}) {
+func (a *AuditTrailValidator) Validate(ctx context.Context, binary []byte, container *CustomContainer) (bool, map[string]interface{}) {
 	results := make(map[string]interface{})
 
 	binaryStr := string(binary)
@@ -350,7 +376,7 @@ func (a *AuditTrailValidator) GetModuleName() string {
 // Multi-Party Consensus Engine
 type MultiPartyConsensusEngine struct{}
 
-func (m *MultiPartyConsensusEngine) Validate(binary []byte, container *CustomContainer) (bool, map[string]interface{}) {
+func (m *MultiPartyConsensusEngine) Validate(ctx context.Context, binary []byte, container *CustomContainer) (bool, map[string]interface{}) {
 	results := make(map[string]interface{})
 
 	// Simulate multiple validation parties
@@ -0,0 +1,510 @@
+package aegong
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"debug/pe"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Signature types
+const (
+	SignatureCosign       = "cosign"
+	SignatureGPG          = "gpg"
+	SignatureAuthenticode = "authenticode"
+)
+
+// Signature verification outcomes
+const (
+	SignatureVerified   = "verified"   // Signature is valid and the signer is trusted
+	SignatureInvalid    = "invalid"    // Signature does not match the agent or its chain of trust
+	SignatureUnverified = "unverified" // Signature is present but could not be checked
+)
+
+// Sigstore Fulcio certificate extensions holding the OIDC issuer
+var (
+	fulcioIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	fulcioIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// SignatureBundle is a detached signature supplied alongside an agent
+type SignatureBundle struct {
+	Type             string `json:"type"`                  // cosign or gpg
+	Signature        []byte `json:"signature"`             // cosign signature (raw or base64) or GPG signature
+	Certificate      []byte `json:"certificate,omitempty"` // PEM signing certificate for keyless cosign
+	PublicKey        []byte `json:"public_key,omitempty"`  // PEM key for cosign, armored key for GPG
+	ClaimedPublisher string `json:"claimed_publisher,omitempty"`
+}
+
+// SignatureInfo is the result of verifying an agent's signature
+type SignatureInfo struct {
+	Type             string `json:"type"`
+	Status           string `json:"status"`
+	Signer           string `json:"signer,omitempty"`
+	Issuer           string `json:"issuer,omitempty"`
+	ClaimedPublisher string `json:"claimed_publisher,omitempty"`
+	PublisherMatch   *bool  `json:"publisher_match,omitempty"`
+	Details          string `json:"details"`
+}
+
+// VerifySignature checks a detached signature bundle, or failing that any code
+// signature embedded in the binary. It returns nil for unsigned agents.
+func VerifySignature(ctx context.Context, binary []byte, bundle *SignatureBundle) *SignatureInfo {
+	var info *SignatureInfo
+	switch {
+	case bundle == nil:
+		info = verifyEmbeddedSignature(binary)
+	case bundle.Type == SignatureCosign:
+		info = verifyCosign(binary, bundle)
+	case bundle.Type == SignatureGPG:
+		info = verifyGPG(ctx, binary, bundle)
+	default:
+		info = &SignatureInfo{
+			Type:    bundle.Type,
+			Status:  SignatureUnverified,
+			Details: fmt.Sprintf("unsupported signature type %q", bundle.Type),
+		}
+	}
+	if info == nil {
+		return nil
+	}
+
+	// Only identities backed by a trusted certificate or keyring can vouch for a publisher
+	if bundle != nil && bundle.ClaimedPublisher != "" {
+		info.ClaimedPublisher = bundle.ClaimedPublisher
+		if info.Status == SignatureVerified && info.Signer != "" {
+			match := strings.Contains(strings.ToLower(info.Signer), strings.ToLower(bundle.ClaimedPublisher))
+			info.PublisherMatch = &match
+		}
+	}
+	return info
+}
+
+// threats converts failed verification into T6 findings
+func (s *SignatureInfo) threats() []ThreatDetection {
+	if s == nil {
+		return nil
+	}
+
+	var evidence []string
+	confidence := 0.0
+	if s.Status == SignatureInvalid {
+		evidence = append(evidence, fmt.Sprintf("%s signature verification failed: %s", s.Type, s.Details))
+		confidence = 0.9
+	}
+	if s.PublisherMatch != nil && !*s.PublisherMatch {
+		evidence = append(evidence, fmt.Sprintf("Signer %q does not match claimed publisher %q", s.Signer, s.ClaimedPublisher))
+		confidence = 0.85
+	}
+	if len(evidence) == 0 {
+		return nil
+	}
+
+	return []ThreatDetection{{
+		Vector:     T6_IDENTITY_SPOOFING,
+		Severity:   HIGH,
+		Confidence: confidence,
+		Evidence:   evidence,
+		Timestamp:  time.Now(),
+		Details: map[string]interface{}{
+			"signature_type":    s.Type,
+			"signature_status":  s.Status,
+			"signer":            s.Signer,
+			"claimed_publisher": s.ClaimedPublisher,
+		},
+	}}
+}
+
+// verifyCosign checks a cosign sign-blob signature against a public key or a
+// Fulcio certificate chaining to the roots in AEGONG_SIGSTORE_ROOTS
+func verifyCosign(binary []byte, bundle *SignatureBundle) *SignatureInfo {
+	info := &SignatureInfo{Type: SignatureCosign}
+
+	// cosign writes signatures base64 encoded
+	signature := bundle.Signature
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err == nil {
+		signature = decoded
+	}
+
+	var publicKey crypto.PublicKey
+	var cert *x509.Certificate
+	switch {
+	case len(bundle.Certificate) > 0:
+		var err error
+		cert, err = parsePEMCertificate(bundle.Certificate)
+		if err != nil {
+			info.Status, info.Details = SignatureInvalid, err.Error()
+			return info
+		}
+		publicKey = cert.PublicKey
+		info.Signer = certificateIdentity(cert)
+		info.Issuer = fulcioIssuer(cert)
+	case len(bundle.PublicKey) > 0:
+		block, _ := pem.Decode(bundle.PublicKey)
+		if block == nil {
+			info.Status, info.Details = SignatureInvalid, "public key is not PEM encoded"
+			return info
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			info.Status, info.Details = SignatureInvalid, fmt.Sprintf("failed to parse public key: %v", err)
+			return info
+		}
+		publicKey = key
+	default:
+		info.Status, info.Details = SignatureUnverified, "cosign signature supplied without a certificate or public key"
+		return info
+	}
+
+	if err := verifyBlobSignature(publicKey, binary, signature); err != nil {
+		info.Status, info.Details = SignatureInvalid, err.Error()
+		return info
+	}
+
+	if cert == nil {
+		// A key supplied with the upload proves integrity, not who holds it
+		der, _ := x509.MarshalPKIXPublicKey(publicKey)
+		fingerprint := sha256.Sum256(der)
+		info.Status = SignatureVerified
+		info.Details = fmt.Sprintf("signed by public key sha256:%s supplied with the upload", hex.EncodeToString(fingerprint[:]))
+		return info
+	}
+
+	roots, err := sigstoreRoots()
+	if err != nil {
+		info.Status, info.Details = SignatureUnverified, err.Error()
+		return info
+	}
+	if roots == nil {
+		info.Status = SignatureUnverified
+		info.Details = "signature matches the certificate, but AEGONG_SIGSTORE_ROOTS is not set so the certificate chain was not checked"
+		return info
+	}
+
+	// Fulcio certificates only live for minutes, so check the chain as of issuance
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: cert.NotBefore.Add(time.Second),
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		info.Status, info.Details = SignatureInvalid, fmt.Sprintf("untrusted signing certificate: %v", err)
+		return info
+	}
+
+	info.Status = SignatureVerified
+	info.Details = "signature matches a certificate issued by a trusted Sigstore root"
+	return info
+}
+
+// verifyBlobSignature checks a signature over data the way cosign produces it
+func verifyBlobSignature(publicKey crypto.PublicKey, data, signature []byte) error {
+	digest := sha256.Sum256(data)
+
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return fmt.Errorf("ECDSA signature does not match the agent")
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil &&
+			rsa.VerifyPSS(key, crypto.SHA256, digest[:], signature, nil) != nil {
+			return fmt.Errorf("RSA signature does not match the agent")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, data, signature) {
+			return fmt.Errorf("Ed25519 signature does not match the agent")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	return nil
+}
+
+func parsePEMCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %v", err)
+	}
+	return cert, nil
+}
+
+// sigstoreRoots loads the trusted roots from AEGONG_SIGSTORE_ROOTS, or nil if unset
+func sigstoreRoots() (*x509.CertPool, error) {
+	path := os.Getenv("AEGONG_SIGSTORE_ROOTS")
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Sigstore roots: %v", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return roots, nil
+}
+
+// certificateIdentity returns the subject a signing certificate was issued to
+func certificateIdentity(cert *x509.Certificate) string {
+	if len(cert.EmailAddresses) > 0 {
+		return cert.EmailAddresses[0]
+	}
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	if len(cert.Subject.Organization) > 0 {
+		return cert.Subject.Organization[0]
+	}
+	return ""
+}
+
+// fulcioIssuer returns the OIDC issuer recorded in a Fulcio certificate
+func fulcioIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(fulcioIssuerV2):
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		case ext.Id.Equal(fulcioIssuerV1):
+			return string(ext.Value)
+		}
+	}
+	if cert.Issuer.CommonName != "" {
+		return cert.Issuer.CommonName
+	}
+	return ""
+}
+
+// verifyGPG checks a detached GPG signature with the gpg binary, trusting the
+// keys in AEGONG_GPG_KEYRING and any key supplied with the upload
+func verifyGPG(ctx context.Context, binary []byte, bundle *SignatureBundle) *SignatureInfo {
+	info := &SignatureInfo{Type: SignatureGPG}
+
+	gpgPath, err := exec.LookPath("gpg")
+	if err != nil {
+		info.Status, info.Details = SignatureUnverified, "gpg is not installed"
+		return info
+	}
+
+	// Use a throwaway home so verification never touches the server's keyring
+	home, err := os.MkdirTemp("", "aegong-gpg-")
+	if err != nil {
+		info.Status, info.Details = SignatureUnverified, fmt.Sprintf("failed to create GPG home: %v", err)
+		return info
+	}
+	defer os.RemoveAll(home)
+
+	gpg := func(stdin []byte, args ...string) ([]byte, error) {
+		cmd := exec.CommandContext(ctx, gpgPath, append([]string{"--homedir", home, "--batch", "--no-tty"}, args...)...)
+		if stdin != nil {
+			cmd.Stdin = bytes.NewReader(stdin)
+		}
+		return cmd.Output()
+	}
+
+	trustedKeys := 0
+	if keyring := os.Getenv("AEGONG_GPG_KEYRING"); keyring != "" {
+		if _, err := gpg(nil, "--import", keyring); err != nil {
+			info.Status, info.Details = SignatureUnverified, fmt.Sprintf("failed to import %s: %v", keyring, err)
+			return info
+		}
+		trustedKeys++
+	}
+	if len(bundle.PublicKey) > 0 {
+		if _, err := gpg(bundle.PublicKey, "--import"); err != nil {
+			info.Status, info.Details = SignatureInvalid, fmt.Sprintf("failed to import the supplied key: %v", err)
+			return info
+		}
+	}
+
+	dataPath := filepath.Join(home, "agent")
+	sigPath := filepath.Join(home, "agent.sig")
+	if err := os.WriteFile(dataPath, binary, 0600); err != nil {
+		info.Status, info.Details = SignatureUnverified, err.Error()
+		return info
+	}
+	if err := os.WriteFile(sigPath, bundle.Signature, 0600); err != nil {
+		info.Status, info.Details = SignatureUnverified, err.Error()
+		return info
+	}
+
+	// gpg exits non-zero for bad signatures; the status lines say why
+	status, _ := gpg(nil, "--status-fd", "1", "--verify", sigPath, dataPath)
+
+	var goodSig, badSig, noKey bool
+	var fingerprint string
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	for scanner.Scan() {
+		fields := strings.SplitN(strings.TrimPrefix(scanner.Text(), "[GNUPG:] "), " ", 3)
+		switch fields[0] {
+		case "GOODSIG":
+			goodSig = true
+			if len(fields) > 2 {
+				info.Signer = fields[2]
+			}
+		case "BADSIG":
+			badSig = true
+			if len(fields) > 2 {
+				info.Signer = fields[2]
+			}
+		case "VALIDSIG":
+			if len(fields) > 1 {
+				fingerprint = fields[1]
+			}
+		case "ERRSIG", "NO_PUBKEY":
+			noKey = true
+		}
+	}
+
+	switch {
+	case badSig:
+		info.Status, info.Details = SignatureInvalid, "GPG signature does not match the agent"
+	case goodSig && trustedKeys > 0 && fingerprintInKeyring(ctx, gpgPath, fingerprint):
+		info.Status, info.Details = SignatureVerified, fmt.Sprintf("signed by trusted key %s", fingerprint)
+	case goodSig:
+		// The signature matches, but only a key supplied with the upload vouches for it
+		info.Status, info.Details = SignatureUnverified, fmt.Sprintf("signed by key %s, which is not in AEGONG_GPG_KEYRING", fingerprint)
+	case noKey:
+		info.Status, info.Details = SignatureUnverified, "no public key available for the GPG signature"
+	default:
+		info.Status, info.Details = SignatureInvalid, "GPG could not parse the signature"
+	}
+	return info
+}
+
+// fingerprintInKeyring reports whether AEGONG_GPG_KEYRING holds the key that made a signature
+func fingerprintInKeyring(ctx context.Context, gpgPath, fingerprint string) bool {
+	if fingerprint == "" {
+		return false
+	}
+	out, err := exec.CommandContext(ctx, gpgPath, "--batch", "--no-tty", "--with-colons", "--show-keys",
+		os.Getenv("AEGONG_GPG_KEYRING")).Output()
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "fpr:") && strings.Contains(line, ":"+fingerprint+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// pkcs7ContentInfo and pkcs7SignedData cover the parts of an Authenticode
+// signature needed to find the signing certificate
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+// verifyEmbeddedSignature reports the signer of an Authenticode signed PE file.
+// The Authenticode digest itself is not recomputed, so the result is unverified.
+func verifyEmbeddedSignature(data []byte) *SignatureInfo {
+	if len(data) < 2 || data[0] != 'M' || data[1] != 'Z' {
+		return nil
+	}
+	file, err := pe.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var security pe.DataDirectory
+	switch header := file.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if header.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_SECURITY {
+			security = header.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY]
+		}
+	case *pe.OptionalHeader64:
+		if header.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_SECURITY {
+			security = header.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY]
+		}
+	}
+	if security.Size == 0 {
+		return nil
+	}
+
+	info := &SignatureInfo{Type: SignatureAuthenticode, Status: SignatureUnverified}
+
+	// The security directory holds a WIN_CERTIFICATE: length, revision, type, then PKCS#7
+	start, end := uint64(security.VirtualAddress), uint64(security.VirtualAddress)+uint64(security.Size)
+	if end > uint64(len(data)) || security.Size < 8 {
+		info.Status, info.Details = SignatureInvalid, "certificate table lies outside the file"
+		return info
+	}
+	length := uint64(binary.LittleEndian.Uint32(data[start:]))
+	if length < 8 || start+length > end {
+		info.Status, info.Details = SignatureInvalid, "malformed certificate table"
+		return info
+	}
+
+	cert, err := authenticodeSigner(data[start+8 : start+length])
+	if err != nil {
+		info.Status, info.Details = SignatureInvalid, err.Error()
+		return info
+	}
+	info.Signer = certificateIdentity(cert)
+	info.Issuer = cert.Issuer.CommonName
+	info.Details = "Authenticode signature present; its digest and chain of trust were not checked"
+	return info
+}
+
+// authenticodeSigner returns the end-entity certificate from a PKCS#7 signature
+func authenticodeSigner(der []byte) (*x509.Certificate, error) {
+	var contentInfo pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(der, &contentInfo); err != nil {
+		return nil, fmt.Errorf("failed to parse Authenticode signature: %v", err)
+	}
+	var signedData pkcs7SignedData
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return nil, fmt.Errorf("failed to parse Authenticode signed data: %v", err)
+	}
+
+	certs, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+	if err != nil || len(certs) == 0 {
+		return nil, fmt.Errorf("Authenticode signature carries no certificates")
+	}
+	for _, cert := range certs {
+		if !cert.IsCA {
+			return cert, nil
+		}
+	}
+	return certs[0], nil
+}
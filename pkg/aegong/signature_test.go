@@ -0,0 +1,149 @@
+package aegong
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signBlob(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+	digest := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(signature))
+}
+
+// TestVerifyCosignKey tests key-based cosign signatures
+func TestVerifyCosignKey(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	bundle := &SignatureBundle{
+		Type:      SignatureCosign,
+		Signature: signBlob(t, key, []byte("agent")),
+		PublicKey: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+	}
+
+	info := VerifySignature(context.Background(), []byte("agent"), bundle)
+	if info.Status != SignatureVerified || len(info.threats()) != 0 {
+		t.Fatalf("Signature should verify, got %+v", info)
+	}
+
+	info = VerifySignature(context.Background(), []byte("tampered agent"), bundle)
+	if info.Status != SignatureInvalid {
+		t.Fatalf("Signature over different content should be invalid, got %+v", info)
+	}
+	threats := info.threats()
+	if len(threats) != 1 || threats[0].Vector != T6_IDENTITY_SPOOFING {
+		t.Fatalf("Invalid signature should raise a T6 finding, got %+v", threats)
+	}
+}
+
+// TestVerifyCosignCertificate tests certificate identities and publisher matching
+func TestVerifyCosignCertificate(t *testing.T) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-fulcio"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, _ := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafTemplate := &x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		NotBefore:      time.Now().Add(-time.Minute),
+		NotAfter:       time.Now().Add(10 * time.Minute),
+		EmailAddresses: []string{"release@acme.dev"},
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	leafDER, _ := x509.CreateCertificate(rand.Reader, leafTemplate, ca, &key.PublicKey, caKey)
+
+	bundle := &SignatureBundle{
+		Type:             SignatureCosign,
+		Signature:        signBlob(t, key, []byte("agent")),
+		Certificate:      pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}),
+		ClaimedPublisher: "acme.dev",
+	}
+
+	// Without trusted roots the identity cannot be relied on
+	t.Setenv("AEGONG_SIGSTORE_ROOTS", "")
+	if info := VerifySignature(context.Background(), []byte("agent"), bundle); info.Status != SignatureUnverified || info.PublisherMatch != nil {
+		t.Fatalf("Certificate should be unverified without roots, got %+v", info)
+	}
+
+	rootsPath := filepath.Join(t.TempDir(), "roots.pem")
+	os.WriteFile(rootsPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0644)
+	t.Setenv("AEGONG_SIGSTORE_ROOTS", rootsPath)
+
+	info := VerifySignature(context.Background(), []byte("agent"), bundle)
+	if info.Status != SignatureVerified || info.Signer != "release@acme.dev" || info.PublisherMatch == nil || !*info.PublisherMatch {
+		t.Fatalf("Certificate signature should verify and match the publisher, got %+v", info)
+	}
+
+	bundle.ClaimedPublisher = "openai"
+	info = VerifySignature(context.Background(), []byte("agent"), bundle)
+	if threats := info.threats(); len(threats) != 1 || !strings.Contains(threats[0].Evidence[0], "does not match claimed publisher") {
+		t.Fatalf("Publisher mismatch should raise a T6 finding, got %+v", threats)
+	}
+}
+
+// TestVerifyGPG tests detached GPG signatures against a trusted keyring
+func TestVerifyGPG(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home")
+	os.Mkdir(home, 0700)
+	gpg := func(args ...string) []byte {
+		out, err := exec.Command("gpg", append([]string{"--homedir", home, "--batch", "--passphrase", "", "--pinentry-mode", "loopback"}, args...)...).Output()
+		if err != nil {
+			t.Skipf("gpg %s failed: %v", args[0], err)
+		}
+		return out
+	}
+
+	gpg("--quick-gen-key", "Acme Release <release@acme.dev>", "ed25519", "sign", "never")
+	keyring := filepath.Join(dir, "trusted.asc")
+	os.WriteFile(keyring, gpg("--armor", "--export"), 0644)
+
+	agentPath := filepath.Join(dir, "agent.py")
+	os.WriteFile(agentPath, []byte("print('agent')\n"), 0644)
+	gpg("--detach-sign", "--output", agentPath+".sig", agentPath)
+	signature, _ := os.ReadFile(agentPath + ".sig")
+
+	bundle := &SignatureBundle{Type: SignatureGPG, Signature: signature, ClaimedPublisher: "acme"}
+
+	t.Setenv("AEGONG_GPG_KEYRING", keyring)
+	info := VerifySignature(context.Background(), []byte("print('agent')\n"), bundle)
+	if info.Status != SignatureVerified || !strings.Contains(info.Signer, "release@acme.dev") || !*info.PublisherMatch {
+		t.Fatalf("GPG signature should verify against the keyring, got %+v", info)
+	}
+
+	info = VerifySignature(context.Background(), []byte("print('tampered')\n"), bundle)
+	if info.Status != SignatureInvalid {
+		t.Fatalf("GPG signature over different content should be invalid, got %+v", info)
+	}
+}
@@ -0,0 +1,237 @@
+package aegong
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Soak mode runs the agent for AEGONG_SOAK_DURATION instead of
+// executionTimeout, sampling its resources, so slow memory leaks, delayed
+// beaconing and time bombs that a short run never sees are caught
+
+// soakDuration returns how long agents run in soak mode, or zero when soak
+// mode is off
+func soakDuration() time.Duration {
+	d, err := time.ParseDuration(os.Getenv("AEGONG_SOAK_DURATION"))
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// Samples taken over a soak run, at most one a second
+const soakSamples = 120
+
+func soakSampleInterval(d time.Duration) time.Duration {
+	return max(d/soakSamples, time.Second)
+}
+
+// ResourceSample is the agent's resource use at one point of a soak run
+type ResourceSample struct {
+	Elapsed     float64 `json:"elapsed_seconds"`
+	MemoryBytes int64   `json:"memory_bytes"`
+	CPUSeconds  float64 `json:"cpu_seconds"`
+	DiskBytes   int64   `json:"disk_bytes"`
+}
+
+// SoakResult records an extended dynamic run
+type SoakResult struct {
+	Started  time.Time        `json:"started"`
+	Planned  float64          `json:"planned_seconds"`
+	Duration float64          `json:"duration_seconds"` // Shorter than planned if the agent exited
+	Samples  []ResourceSample `json:"samples"`
+}
+
+// sampleResources measures a running agent. Memory comes from its cgroup
+// when it has one, otherwise from the agent process alone.
+func (e *Engine) sampleResources(container *CustomContainer, pid int, binarySize int64, elapsed time.Duration) ResourceSample {
+	sample := ResourceSample{Elapsed: elapsed.Seconds()}
+	if container.CgroupPath != "" {
+		sample.MemoryBytes = e.getCgroupMemoryUsage(container.CgroupPath)
+	}
+	if sample.MemoryBytes == 0 {
+		if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/statm", pid)); err == nil {
+			if fields := strings.Fields(string(data)); len(fields) > 1 {
+				pages, _ := strconv.ParseInt(fields[1], 10, 64)
+				sample.MemoryBytes = pages * int64(os.Getpagesize())
+			}
+		}
+	}
+	sample.CPUSeconds = processCPUSeconds(pid)
+	sample.DiskBytes, _ = diskUsage(container, binarySize)
+	return sample
+}
+
+// Clock ticks per second of /proc/<pid>/stat times on Linux
+const clockTicks = 100
+
+// processCPUSeconds returns the user and system time a process has used
+func processCPUSeconds(pid int) float64 {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0
+	}
+	// The command name may contain spaces, so fields are counted after it
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 13 {
+		return 0
+	}
+	utime, _ := strconv.ParseFloat(fields[11], 64)
+	stime, _ := strconv.ParseFloat(fields[12], 64)
+	return (utime + stime) / clockTicks
+}
+
+// Thresholds of the soak analyses
+const (
+	soakLeakMinSamples   = 6
+	soakLeakGrowth       = 0.5 // Memory must grow by half
+	soakLeakSteadiness   = 0.8 // in at least this share of samples
+	soakIdleCPU          = 0.1 // CPU seconds of an agent considered idle
+	soakActiveCPU        = 1.0 // CPU seconds of an agent considered active
+	soakBeaconMinCount   = 4
+	soakBeaconMinSeconds = 5.0
+	soakBeaconJitter     = 0.2 // Largest deviation from the mean interval
+)
+
+// soakThreats analyzes a soak run for behaviour a standard run would miss
+func (c *CustomContainer) soakThreats() []ThreatDetection {
+	if c.Soak == nil {
+		return nil
+	}
+	var threats []ThreatDetection
+	threat := func(vector ThreatVector, severity ThreatSeverity, confidence float64, evidence []string, kind string) {
+		threats = append(threats, ThreatDetection{
+			Vector:     vector,
+			Severity:   severity,
+			Confidence: confidence,
+			Evidence:   evidence,
+			Timestamp:  time.Now(),
+			Details: map[string]interface{}{
+				"analysis":         "soak",
+				"soak_finding":     kind,
+				"duration_seconds": c.Soak.Duration,
+			},
+		})
+	}
+
+	if evidence := memoryLeak(c.Soak.Samples); evidence != "" {
+		threat(T5_RESOURCE_MANIPULATION, MEDIUM, 0.7, []string{evidence}, "memory_leak")
+	}
+	if evidence := delayedActivity(c.Soak, c.NetworkCaptures); len(evidence) > 0 {
+		threat(T9_GOVERNANCE_EVASION, HIGH, 0.8, evidence, "delayed_activity")
+	}
+	if evidence := beaconing(c.NetworkCaptures); len(evidence) > 0 {
+		threat(T9_GOVERNANCE_EVASION, MEDIUM, 0.7, evidence, "beaconing")
+	}
+	return threats
+}
+
+// memoryLeak describes steady memory growth over the samples, if any
+func memoryLeak(samples []ResourceSample) string {
+	var memory []ResourceSample
+	for _, sample := range samples {
+		if sample.MemoryBytes > 0 {
+			memory = append(memory, sample)
+		}
+	}
+	if len(memory) < soakLeakMinSamples {
+		return ""
+	}
+
+	first, last := memory[0], memory[len(memory)-1]
+	growing := 0
+	for i := 1; i < len(memory); i++ {
+		if memory[i].MemoryBytes >= memory[i-1].MemoryBytes {
+			growing++
+		}
+	}
+	if float64(last.MemoryBytes-first.MemoryBytes) < soakLeakGrowth*float64(first.MemoryBytes) ||
+		float64(growing) < soakLeakSteadiness*float64(len(memory)-1) {
+		return ""
+	}
+	return fmt.Sprintf("Memory grew steadily from %d KB to %d KB over %.0f seconds",
+		first.MemoryBytes/1024, last.MemoryBytes/1024, last.Elapsed-first.Elapsed)
+}
+
+// delayedActivity lists activity that only began after a standard run
+// would have ended
+func delayedActivity(soak *SoakResult, captures []HoneypotCapture) []string {
+	window := executionTimeout.Seconds()
+	var evidence []string
+
+	var early, late []HoneypotCapture
+	for _, capture := range captures {
+		if capture.Timestamp.Sub(soak.Started).Seconds() > window {
+			late = append(late, capture)
+		} else {
+			early = append(early, capture)
+		}
+	}
+	if len(early) == 0 && len(late) > 0 {
+		evidence = append(evidence, fmt.Sprintf("First network egress after %.0f seconds, once a %v run would have ended: [%s] %s",
+			late[0].Timestamp.Sub(soak.Started).Seconds(), executionTimeout, late[0].Service, late[0].Summary))
+	}
+
+	// Compare resource use inside the standard window with the rest of the run
+	var inWindow, end *ResourceSample
+	for i := range soak.Samples {
+		if soak.Samples[i].Elapsed <= window {
+			inWindow = &soak.Samples[i]
+		}
+		end = &soak.Samples[i]
+	}
+	if inWindow != nil && end != inWindow {
+		if inWindow.CPUSeconds < soakIdleCPU && end.CPUSeconds-inWindow.CPUSeconds >= soakActiveCPU {
+			evidence = append(evidence, fmt.Sprintf("Agent was idle for the first %v, then used %.1f seconds of CPU",
+				executionTimeout, end.CPUSeconds-inWindow.CPUSeconds))
+		}
+		if inWindow.DiskBytes == 0 && end.DiskBytes > 0 {
+			evidence = append(evidence, fmt.Sprintf("Agent only started writing files after %v (%d KB)",
+				executionTimeout, end.DiskBytes/1024))
+		}
+	}
+	return evidence
+}
+
+// beaconing lists services the agent contacted at regular intervals
+func beaconing(captures []HoneypotCapture) []string {
+	byService := make(map[string][]time.Time)
+	for _, capture := range captures {
+		byService[capture.Service] = append(byService[capture.Service], capture.Timestamp)
+	}
+
+	var evidence []string
+	for service, times := range byService {
+		if len(times) < soakBeaconMinCount {
+			continue
+		}
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+		var intervals []float64
+		var sum float64
+		for i := 1; i < len(times); i++ {
+			interval := times[i].Sub(times[i-1]).Seconds()
+			intervals = append(intervals, interval)
+			sum += interval
+		}
+		mean := sum / float64(len(intervals))
+		if mean < soakBeaconMinSeconds {
+			continue
+		}
+		var variance float64
+		for _, interval := range intervals {
+			variance += (interval - mean) * (interval - mean)
+		}
+		if math.Sqrt(variance/float64(len(intervals))) <= soakBeaconJitter*mean {
+			evidence = append(evidence, fmt.Sprintf("Contacted %s %d times, every %.0f seconds", service, len(times), mean))
+		}
+	}
+	sort.Strings(evidence)
+	return evidence
+}
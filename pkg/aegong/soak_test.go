@@ -0,0 +1,77 @@
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSoakThreats tests leak, delayed activity and beaconing detection on a soak run
+func TestSoakThreats(t *testing.T) {
+	start := time.Now()
+	var samples []ResourceSample
+	for i := 0; i <= 10; i++ {
+		elapsed := float64(i * 60)
+		sample := ResourceSample{Elapsed: elapsed, MemoryBytes: int64(10+i*2) << 20}
+		// Idle through the standard run, busy afterwards
+		if elapsed > executionTimeout.Seconds() {
+			sample.CPUSeconds = float64(i)
+		}
+		samples = append(samples, sample)
+	}
+	var captures []HoneypotCapture
+	for i := 1; i <= 5; i++ {
+		captures = append(captures, HoneypotCapture{Service: "http", Timestamp: start.Add(time.Duration(i) * 2 * time.Minute), Summary: "GET /beacon"})
+	}
+
+	container := &CustomContainer{
+		Soak:            &SoakResult{Started: start, Planned: 600, Duration: 600, Samples: samples},
+		NetworkCaptures: captures,
+	}
+	findings := make(map[string]ThreatDetection)
+	for _, threat := range container.soakThreats() {
+		findings[threat.Details["soak_finding"].(string)] = threat
+	}
+
+	if leak := findings["memory_leak"]; leak.Vector != T5_RESOURCE_MANIPULATION || !strings.Contains(leak.Evidence[0], "10240 KB to 30720 KB") {
+		t.Fatalf("Steady memory growth should be reported as T5, got %+v", leak)
+	}
+	delayed := findings["delayed_activity"]
+	if delayed.Vector != T9_GOVERNANCE_EVASION || len(delayed.Evidence) != 2 {
+		t.Fatalf("Late egress and late CPU use should be reported, got %+v", delayed)
+	}
+	if beacon := findings["beaconing"]; len(beacon.Evidence) != 1 || !strings.Contains(beacon.Evidence[0], "every 120 seconds") {
+		t.Fatalf("Regular egress should be reported as beaconing, got %+v", beacon)
+	}
+
+	// A flat run with early egress raises nothing
+	for i := range samples {
+		samples[i].MemoryBytes = 10 << 20
+		samples[i].CPUSeconds = 0.5
+	}
+	container.Soak.Samples = samples
+	container.NetworkCaptures = captures[:1]
+	container.NetworkCaptures[0].Timestamp = start.Add(time.Second)
+	if threats := container.soakThreats(); len(threats) != 0 {
+		t.Fatalf("Steady behaviour should raise no soak findings, got %+v", threats)
+	}
+}
+
+// TestSoakMode tests that soak mode keeps the agent running and samples it
+func TestSoakMode(t *testing.T) {
+	t.Setenv("AEGONG_SOAK_DURATION", "3s")
+	engine := newTestEngine(t)
+
+	report, err := engine.Audit(context.Background(), bytes.NewReader([]byte("#!/bin/sh\nsleep 10\n")))
+	if err != nil {
+		t.Fatalf("Failed to audit agent: %v", err)
+	}
+	if report.Soak == nil {
+		t.Skip("Agent could not be executed in this environment")
+	}
+	if report.Soak.Planned != 3 || len(report.Soak.Samples) < 2 || report.Soak.Duration < 2.5 {
+		t.Fatalf("Soak run should last 3 seconds with a sample a second, got %+v", report.Soak)
+	}
+}
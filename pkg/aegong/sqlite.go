@@ -0,0 +1,369 @@
+package aegong
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unicode/utf16"
+)
+
+// Agents keep their memory in SQLite, directly or through Chroma, and the
+// auditor has no SQLite driver. This reads just enough of the file format to
+// list a database's tables and walk their rows: the table b-trees, overflow
+// pages and record format, plus the committed frames of a write-ahead log
+// the agent did not checkpoint. Indexes and WITHOUT ROWID tables are skipped.
+
+const sqliteMagic = "SQLite format 3\x00"
+
+// Write-ahead log magic numbers, for little and big endian checksums
+const (
+	sqliteWALMagicLE = 0x377f0682
+	sqliteWALMagicBE = 0x377f0683
+)
+
+// Guards against corrupt or hostile databases
+const (
+	maxSQLiteDepth = 20     // B-tree levels
+	maxSQLiteRows  = 100000 // Rows read from one database
+)
+
+// sqliteTable is a table in a database's schema
+type sqliteTable struct {
+	name string
+	root uint32
+}
+
+// sqliteFile is a SQLite database read into memory
+type sqliteFile struct {
+	data     []byte
+	pageSize int
+	usable   int               // Page size less the reserved bytes at the end of each page
+	wal      map[uint32][]byte // Committed pages from the write-ahead log
+	utf16    binary.ByteOrder  // nil when text is UTF-8
+	rows     int
+}
+
+// openSQLite parses a database's header and the committed pages of its
+// write-ahead log, which may be empty
+func openSQLite(data, wal []byte) (*sqliteFile, error) {
+	if len(data) < 100 || string(data[:16]) != sqliteMagic {
+		return nil, fmt.Errorf("not a SQLite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("invalid SQLite page size %d", pageSize)
+	}
+	f := &sqliteFile{data: data, pageSize: pageSize, usable: pageSize - int(data[20])}
+	if f.usable < 480 {
+		return nil, fmt.Errorf("invalid SQLite reserved space %d", data[20])
+	}
+	switch binary.BigEndian.Uint32(data[56:60]) {
+	case 2:
+		f.utf16 = binary.LittleEndian
+	case 3:
+		f.utf16 = binary.BigEndian
+	}
+	f.wal = readSQLiteWAL(wal, pageSize)
+	return f, nil
+}
+
+// readSQLiteWAL returns the pages of the transactions committed to a
+// write-ahead log, the latest version of each
+func readSQLiteWAL(wal []byte, pageSize int) map[uint32][]byte {
+	if len(wal) < 32 {
+		return nil
+	}
+	magic := binary.BigEndian.Uint32(wal[0:4])
+	if magic != sqliteWALMagicLE && magic != sqliteWALMagicBE || int(binary.BigEndian.Uint32(wal[8:12])) != pageSize {
+		return nil
+	}
+	salt := wal[16:24]
+	pages := make(map[uint32][]byte)
+	pending := make(map[uint32][]byte)
+	for offset := 32; offset+24+pageSize <= len(wal); offset += 24 + pageSize {
+		frame := wal[offset : offset+24]
+		// Frames left over from before the log was last reset have old salts
+		if !bytes.Equal(frame[8:16], salt) {
+			break
+		}
+		pending[binary.BigEndian.Uint32(frame[0:4])] = wal[offset+24 : offset+24+pageSize]
+		if binary.BigEndian.Uint32(frame[4:8]) != 0 {
+			for number, page := range pending {
+				pages[number] = page
+			}
+			pending = make(map[uint32][]byte)
+		}
+	}
+	return pages
+}
+
+// page returns page n, counting from 1
+func (f *sqliteFile) page(n uint32) ([]byte, error) {
+	if page, ok := f.wal[n]; ok {
+		return page, nil
+	}
+	start := (int64(n) - 1) * int64(f.pageSize)
+	if n == 0 || start+int64(f.pageSize) > int64(len(f.data)) {
+		return nil, fmt.Errorf("SQLite page %d out of range", n)
+	}
+	return f.data[start : start+int64(f.pageSize)], nil
+}
+
+// tables lists the tables in the database's schema
+func (f *sqliteFile) tables() ([]sqliteTable, error) {
+	var tables []sqliteTable
+	err := f.walk(1, func(_ int64, values []interface{}) bool {
+		// Schema rows are type, name, tbl_name, rootpage and sql
+		if len(values) < 4 {
+			return true
+		}
+		kind, _ := values[0].(string)
+		name, _ := values[1].(string)
+		root, _ := values[3].(int64)
+		if kind == "table" && root > 0 && root <= math.MaxUint32 {
+			tables = append(tables, sqliteTable{name: name, root: uint32(root)})
+		}
+		return true
+	})
+	return tables, err
+}
+
+// walk visits the rows of the table whose b-tree starts at root in rowid
+// order, until visit returns false
+func (f *sqliteFile) walk(root uint32, visit func(rowid int64, values []interface{}) bool) error {
+	_, err := f.walkPage(root, 0, make(map[uint32]bool), visit)
+	return err
+}
+
+func (f *sqliteFile) walkPage(n uint32, depth int, seen map[uint32]bool, visit func(int64, []interface{}) bool) (bool, error) {
+	if depth > maxSQLiteDepth || seen[n] {
+		return false, fmt.Errorf("SQLite b-tree at page %d is corrupt", n)
+	}
+	seen[n] = true
+	page, err := f.page(n)
+	if err != nil {
+		return false, err
+	}
+	header := 0
+	if n == 1 {
+		header = 100
+	}
+	if header+12 > len(page) {
+		return false, fmt.Errorf("SQLite page %d is truncated", n)
+	}
+	cells := int(binary.BigEndian.Uint16(page[header+3 : header+5]))
+
+	switch page[header] {
+	case 0x05: // Interior table page
+		pointers := header + 12
+		for i := 0; i < cells; i++ {
+			cell, err := f.cellOffset(page, pointers, i)
+			if err != nil || cell+4 > len(page) {
+				return false, fmt.Errorf("SQLite page %d is corrupt", n)
+			}
+			more, err := f.walkPage(binary.BigEndian.Uint32(page[cell:cell+4]), depth+1, seen, visit)
+			if !more || err != nil {
+				return more, err
+			}
+		}
+		return f.walkPage(binary.BigEndian.Uint32(page[header+8:header+12]), depth+1, seen, visit)
+	case 0x0d: // Leaf table page
+		pointers := header + 8
+		for i := 0; i < cells; i++ {
+			cell, err := f.cellOffset(page, pointers, i)
+			if err != nil {
+				return false, fmt.Errorf("SQLite page %d is corrupt", n)
+			}
+			rowid, payload, err := f.leafCell(page, cell)
+			if err != nil {
+				return false, fmt.Errorf("SQLite page %d: %v", n, err)
+			}
+			values, err := f.record(payload)
+			if err != nil {
+				return false, fmt.Errorf("SQLite page %d: %v", n, err)
+			}
+			if f.rows++; f.rows > maxSQLiteRows || !visit(rowid, values) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("SQLite page %d is not a table page", n)
+}
+
+// cellOffset returns where the i-th cell of a page starts
+func (f *sqliteFile) cellOffset(page []byte, pointers, i int) (int, error) {
+	at := pointers + 2*i
+	if at+2 > len(page) {
+		return 0, fmt.Errorf("cell pointer out of range")
+	}
+	cell := int(binary.BigEndian.Uint16(page[at : at+2]))
+	if cell >= len(page) {
+		return 0, fmt.Errorf("cell out of range")
+	}
+	return cell, nil
+}
+
+// leafCell returns a table leaf cell's rowid and its payload, reassembled
+// from its overflow pages
+func (f *sqliteFile) leafCell(page []byte, cell int) (int64, []byte, error) {
+	size, n := sqliteVarint(page[cell:])
+	if n == 0 {
+		return 0, nil, fmt.Errorf("truncated cell")
+	}
+	cell += n
+	rowid, n := sqliteVarint(page[cell:])
+	if n == 0 {
+		return 0, nil, fmt.Errorf("truncated cell")
+	}
+	cell += n
+	if size > uint64(len(f.data))+uint64(len(f.wal)*f.pageSize) {
+		return 0, nil, fmt.Errorf("cell payload too large")
+	}
+
+	// How much of the payload is on the page is fixed by the format
+	total := int(size)
+	local := total
+	if maxLocal := f.usable - 35; total > maxLocal {
+		minLocal := (f.usable-12)*32/255 - 23
+		local = minLocal + (total-minLocal)%(f.usable-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	if cell+local > len(page) {
+		return 0, nil, fmt.Errorf("cell out of range")
+	}
+	payload := append([]byte(nil), page[cell:cell+local]...)
+	if local == total {
+		return int64(rowid), payload, nil
+	}
+
+	if cell+local+4 > len(page) {
+		return 0, nil, fmt.Errorf("cell out of range")
+	}
+	next := binary.BigEndian.Uint32(page[cell+local : cell+local+4])
+	seen := make(map[uint32]bool)
+	for len(payload) < total {
+		if next == 0 || seen[next] {
+			return 0, nil, fmt.Errorf("overflow chain is corrupt")
+		}
+		seen[next] = true
+		overflow, err := f.page(next)
+		if err != nil {
+			return 0, nil, err
+		}
+		chunk := overflow[4:f.usable]
+		if remaining := total - len(payload); len(chunk) > remaining {
+			chunk = chunk[:remaining]
+		}
+		payload = append(payload, chunk...)
+		next = binary.BigEndian.Uint32(overflow[0:4])
+	}
+	return int64(rowid), payload, nil
+}
+
+// record decodes a record into nil, int64, float64, string and []byte values
+func (f *sqliteFile) record(payload []byte) ([]interface{}, error) {
+	headerSize, n := sqliteVarint(payload)
+	if n == 0 || headerSize > uint64(len(payload)) {
+		return nil, fmt.Errorf("record header out of range")
+	}
+	var types []uint64
+	for at := n; at < int(headerSize); {
+		serial, n := sqliteVarint(payload[at:int(headerSize)])
+		if n == 0 {
+			return nil, fmt.Errorf("record header is corrupt")
+		}
+		types = append(types, serial)
+		at += n
+	}
+
+	body := payload[headerSize:]
+	values := make([]interface{}, len(types))
+	for i, serial := range types {
+		size := sqliteSerialSize(serial)
+		if size > len(body) {
+			return nil, fmt.Errorf("record value out of range")
+		}
+		value := body[:size]
+		body = body[size:]
+		switch {
+		case serial == 0:
+			values[i] = nil
+		case serial <= 6:
+			values[i] = sqliteInt(value)
+		case serial == 7:
+			values[i] = math.Float64frombits(binary.BigEndian.Uint64(value))
+		case serial == 8, serial == 9:
+			values[i] = int64(serial - 8)
+		case serial >= 12 && serial%2 == 0:
+			values[i] = append([]byte(nil), value...)
+		case serial >= 13:
+			values[i] = f.text(value)
+		}
+	}
+	return values, nil
+}
+
+// text decodes a text value in the database's encoding
+func (f *sqliteFile) text(value []byte) string {
+	if f.utf16 == nil {
+		return string(value)
+	}
+	units := make([]uint16, len(value)/2)
+	for i := range units {
+		units[i] = f.utf16.Uint16(value[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// sqliteSerialSize returns the size of a value of a serial type
+func sqliteSerialSize(serial uint64) int {
+	switch {
+	case serial <= 4:
+		return int(serial)
+	case serial == 5:
+		return 6
+	case serial == 6, serial == 7:
+		return 8
+	case serial < 12:
+		return 0
+	}
+	return int((serial - 12) / 2)
+}
+
+// sqliteInt decodes a big-endian two's complement integer of 1 to 8 bytes
+func sqliteInt(value []byte) int64 {
+	var n int64
+	if len(value) > 0 && value[0]&0x80 != 0 {
+		n = -1
+	}
+	for _, b := range value {
+		n = n<<8 | int64(b)
+	}
+	return n
+}
+
+// sqliteVarint decodes a SQLite varint, returning its length or 0 if it is
+// truncated
+func sqliteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(b) {
+			return 0, 0
+		}
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, 9
+}
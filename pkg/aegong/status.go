@@ -0,0 +1,58 @@
+package aegong
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// SandboxStatus describes the engine's running sandboxes and the isolation
+// available to new ones
+type SandboxStatus struct {
+	Active       int    `json:"active"`   // Sandboxes currently running an agent
+	Rootless     bool   `json:"rootless"` // Sandboxes use a user namespace
+	Cgroups      bool   `json:"cgroups"`  // Sandboxes get memory and CPU limits
+	CgroupReason string `json:"cgroup_reason,omitempty"`
+	StaticOnly   string `json:"static_only,omitempty"` // Why agents are not run; empty when they are
+}
+
+// SandboxStatus reports how many sandboxes are running and whether new ones
+// can be resource limited
+func (e *Engine) SandboxStatus() SandboxStatus {
+	e.mutex.RLock()
+	active := len(e.containers)
+	e.mutex.RUnlock()
+
+	cgroups, reason := cgroupAvailability()
+	return SandboxStatus{
+		Active:       active,
+		Rootless:     rootlessMode(),
+		Cgroups:      cgroups,
+		CgroupReason: reason,
+		StaticOnly:   e.staticOnly,
+	}
+}
+
+// cgroupAvailability mirrors the checks createCgroupStructure makes before
+// limiting a container, returning why limits are unavailable if they are
+func cgroupAvailability() (bool, string) {
+	if !sandboxSupported {
+		return false, fmt.Sprintf("cgroups are not supported on %s", runtime.GOOS)
+	}
+	if os.Getenv("GO_TEST") == "1" {
+		return false, "cgroups are skipped during tests"
+	}
+	if os.Getenv("AEGONG_DEV_MODE") == "1" {
+		return false, "cgroups are skipped in development mode"
+	}
+	if rootlessMode() {
+		if _, err := delegatedCgroupParent(); err != nil {
+			return false, err.Error()
+		}
+		return true, ""
+	}
+	if err := checkWritable(cgroupV2Root); err != nil {
+		return false, fmt.Sprintf("%s is not writable", cgroupV2Root)
+	}
+	return true, ""
+}
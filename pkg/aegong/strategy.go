@@ -0,0 +1,124 @@
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"runtime"
+)
+
+// Not every upload can be run the same way. The dynamic phase picks how to
+// run the agent from its format, what validation made of it and what static
+// analysis found: libraries have nothing to run, WebAssembly modules run in
+// the WASI runtime, scripts run under their interpreter and language harness,
+// and native Linux executables run in the full sandbox. The choice and why it
+// was made are recorded in the report's coverage.
+
+// Dynamic analysis strategies
+const (
+	StrategySandbox     = "sandbox"     // Executed directly in the sandbox, traced with ptrace
+	StrategyHarness     = "harness"     // Script run in the sandbox under its language harness
+	StrategyInterpreter = "interpreter" // Script run in the sandbox under its interpreter, without a harness
+	StrategyWASM        = "wasm"        // WebAssembly module run in the WASI runtime
+	StrategyNone        = "none"        // Not run
+)
+
+// DynamicStrategy is how the agent was run in the dynamic phase
+type DynamicStrategy struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+
+	// Set when the agent could have been run but not here, e.g. a Windows
+	// executable; its dynamic detectors are then reported as skipped rather
+	// than not applicable
+	unavailable bool
+}
+
+// ELF machine each GOARCH the sandbox runs on executes natively
+var nativeMachines = map[string]elf.Machine{
+	"amd64": elf.EM_X86_64,
+	"arm64": elf.EM_AARCH64,
+	"386":   elf.EM_386,
+	"arm":   elf.EM_ARM,
+}
+
+// selectStrategy picks how the dynamic phase runs an agent, once static
+// analysis is done
+func (e *Engine) selectStrategy(ctx context.Context, binary []byte, container *CustomContainer) DynamicStrategy {
+	if e.staticOnly != "" {
+		return DynamicStrategy{Name: StrategyNone, Reason: e.staticOnly, unavailable: true}
+	}
+	if container.validation != nil && container.validation.AgentType == "library" {
+		return DynamicStrategy{Name: StrategyNone, Reason: "validation identified a shared library, which has no entry point to run"}
+	}
+
+	switch format := analysisFormat(binary); format {
+	case "elf":
+		return elfStrategy(binary, container.Packing)
+	case "pe":
+		if file, err := pe.NewFile(bytes.NewReader(binary)); err == nil && file.Characteristics&pe.IMAGE_FILE_DLL != 0 {
+			return DynamicStrategy{Name: StrategyNone, Reason: "Windows DLL, which has no entry point to run"}
+		}
+		return DynamicStrategy{Name: StrategyNone, Reason: "Windows executables can't run in the Linux sandbox", unavailable: true}
+	case "macho":
+		if file, err := macho.NewFile(bytes.NewReader(binary)); err == nil && (file.Type == macho.TypeDylib || file.Type == macho.TypeBundle) {
+			return DynamicStrategy{Name: StrategyNone, Reason: "Mach-O dynamic library, which has no entry point to run"}
+		}
+		return DynamicStrategy{Name: StrategyNone, Reason: "macOS executables can't run in the Linux sandbox", unavailable: true}
+	case "wasm":
+		return wasmStrategy(ctx, binary)
+	case FormatPython, FormatJavaScript:
+		script := selectRuntime(binary)
+		switch {
+		case script == nil && bytes.HasPrefix(binary, []byte("#!")):
+			return DynamicStrategy{Name: StrategySandbox, Reason: fmt.Sprintf("no %s interpreter is installed, running the script by its #! line", format)}
+		case script == nil:
+			return DynamicStrategy{Name: StrategyNone, Reason: fmt.Sprintf("no %s interpreter is installed", format), unavailable: true}
+		case harnessEnabled():
+			return DynamicStrategy{Name: StrategyHarness, Reason: fmt.Sprintf("%s script, traced by the %s harness", format, script.language)}
+		default:
+			return DynamicStrategy{Name: StrategyInterpreter, Reason: fmt.Sprintf("%s script, the language harness is disabled (AEGONG_DISABLE_HARNESS)", format)}
+		}
+	}
+	if bytes.HasPrefix(binary, []byte("#!")) {
+		return DynamicStrategy{Name: StrategySandbox, Reason: "script run by its #! line"}
+	}
+	return DynamicStrategy{Name: StrategySandbox, Reason: "unrecognized format, run directly"}
+}
+
+// elfStrategy runs ELF executables built for this machine in the sandbox
+func elfStrategy(binary []byte, packing *PackingAnalysis) DynamicStrategy {
+	file, err := elf.NewFile(bytes.NewReader(binary))
+	if err != nil {
+		return DynamicStrategy{Name: StrategySandbox, Reason: fmt.Sprintf("malformed ELF file, run directly: %v", err)}
+	}
+	if file.Type == elf.ET_REL {
+		return DynamicStrategy{Name: StrategyNone, Reason: "relocatable object file, which has no entry point to run"}
+	}
+	if file.Type == elf.ET_DYN && !hasInterpreter(file) {
+		if soname, _ := file.DynString(elf.DT_SONAME); len(soname) > 0 {
+			return DynamicStrategy{Name: StrategyNone, Reason: fmt.Sprintf("shared library %s, which has no entry point to run", soname[0])}
+		}
+	}
+	if native, ok := nativeMachines[runtime.GOARCH]; ok && file.Machine != native {
+		return DynamicStrategy{Name: StrategyNone, Reason: fmt.Sprintf("built for %s, the sandbox runs %s", file.Machine, runtime.GOARCH), unavailable: true}
+	}
+	if packing != nil && packing.Packer != "" {
+		return DynamicStrategy{Name: StrategySandbox, Reason: fmt.Sprintf("native executable packed with %s, which unpacks itself when run", packing.Packer)}
+	}
+	return DynamicStrategy{Name: StrategySandbox, Reason: "native executable"}
+}
+
+// hasInterpreter reports whether an ELF file names a dynamic loader, as
+// position-independent executables do and shared libraries don't
+func hasInterpreter(file *elf.File) bool {
+	for _, prog := range file.Progs {
+		if prog.Type == elf.PT_INTERP {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testWasmCommand assembles a WASI module that writes "hello" to standard
+// output with fd_write. It exports its entry point as entry, so anything
+// but "_start" makes it a library.
+func testWasmCommand(importModule, entry string) []byte {
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	// (i32, i32, i32, i32) -> i32 and () -> ()
+	wasm = append(wasm, wasmSection(1, 0x02, 0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f, 0x60, 0x00, 0x00)...)
+	imports := append(append([]byte{0x01}, wasmName(importModule)...), wasmName("fd_write")...)
+	wasm = append(wasm, wasmSection(2, append(imports, 0x00, 0x00)...)...)
+	wasm = append(wasm, wasmSection(3, 0x01, 0x01)...)
+	wasm = append(wasm, wasmSection(5, 0x01, 0x00, 0x01)...)
+	exports := []byte{0x02}
+	exports = append(append(exports, wasmName("memory")...), 0x02, 0x00)
+	exports = append(append(exports, wasmName(entry)...), 0x00, 0x01)
+	wasm = append(wasm, wasmSection(7, exports...)...)
+	// fd_write(1, iovs=0, 1 iovec, nwritten=8)
+	code := []byte{0x00, 0x41, 0x01, 0x41, 0x00, 0x41, 0x01, 0x41, 0x08, 0x10, 0x00, 0x1a, 0x0b}
+	wasm = append(wasm, wasmSection(10, append([]byte{0x01}, append(wasmULEB(len(code)), code...)...)...)...)
+	// The iovec at 0 points at "hello" at 16
+	memory := append([]byte{16, 0, 0, 0, 5, 0, 0, 0}, make([]byte, 8)...)
+	data := append([]byte{0x01, 0x00, 0x41, 0x00, 0x0b}, wasmName(string(append(memory, "hello"...)))...)
+	return append(wasm, wasmSection(11, data...)...)
+}
+
+// testLibrary returns a shared library without a dynamic loader
+func testLibrary(t *testing.T) []byte {
+	for _, pattern := range []string{"/lib/*/libm.so.6", "/usr/lib/*/libm.so.6", "/lib64/libm.so.6"} {
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			if library, err := os.ReadFile(path); err == nil {
+				return library
+			}
+		}
+	}
+	t.Skip("No shared library to analyze")
+	return nil
+}
+
+// TestSelectStrategy tests how the dynamic strategy follows the agent's
+// format and validation
+func TestSelectStrategy(t *testing.T) {
+	engine, err := NewEngine(Config{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if engine.staticOnly != "" {
+		t.Skip("Agents are not run on this platform")
+	}
+	ctx := context.Background()
+	selectFor := func(binary []byte, validation *AgentValidationResult) DynamicStrategy {
+		return engine.selectStrategy(ctx, binary, &CustomContainer{validation: validation})
+	}
+
+	executable := testExecutable(t)
+	if strategy := selectFor(executable, nil); strategy.Name != StrategySandbox {
+		t.Errorf("Native executables should run in the sandbox, got %+v", strategy)
+	}
+	if strategy := selectFor(executable, &AgentValidationResult{AgentType: "library"}); strategy.Name != StrategyNone || strategy.unavailable {
+		t.Errorf("Validated libraries should not be run, got %+v", strategy)
+	}
+	if strategy := selectFor(testLibrary(t), nil); strategy.Name != StrategyNone || strategy.unavailable || !strings.Contains(strategy.Reason, "shared library") {
+		t.Errorf("Shared libraries should not be run, got %+v", strategy)
+	}
+
+	foreign := append([]byte(nil), executable...)
+	binary.LittleEndian.PutUint16(foreign[18:], 243) // EM_RISCV
+	if strategy := selectFor(foreign, nil); strategy.Name != StrategyNone || !strategy.unavailable {
+		t.Errorf("Executables for another architecture should be unavailable, got %+v", strategy)
+	}
+	if strategy := selectFor([]byte("MZ\x90\x00"), nil); strategy.Name != StrategyNone || !strategy.unavailable {
+		t.Errorf("Windows executables should be unavailable, got %+v", strategy)
+	}
+
+	if strategy := selectFor(testWasmCommand(wasiModule, "_start"), nil); strategy.Name != StrategyWASM {
+		t.Errorf("WASI commands should run in the WASI runtime, got %+v", strategy)
+	}
+	if strategy := selectFor(testWasmCommand(wasiModule, "run"), nil); strategy.Name != StrategyNone || strategy.unavailable {
+		t.Errorf("WebAssembly libraries should not be run, got %+v", strategy)
+	}
+	if strategy := selectFor(testWasmCommand("env", "_start"), nil); strategy.Name != StrategyNone || !strategy.unavailable {
+		t.Errorf("Modules importing more than WASI should be unavailable, got %+v", strategy)
+	}
+
+	script := []byte("import os\n\ndef main():\n    print(os.getcwd())\n")
+	if selectRuntime(script) != nil {
+		t.Setenv("AEGONG_DISABLE_HARNESS", "")
+		if strategy := selectFor(script, nil); strategy.Name != StrategyHarness {
+			t.Errorf("Scripts should run under their harness, got %+v", strategy)
+		}
+		t.Setenv("AEGONG_DISABLE_HARNESS", "1")
+		if strategy := selectFor(script, nil); strategy.Name != StrategyInterpreter {
+			t.Errorf("Scripts should run under their interpreter without the harness, got %+v", strategy)
+		}
+	}
+}
+
+// TestWasmAgent tests running a WebAssembly agent in the WASI runtime
+func TestWasmAgent(t *testing.T) {
+	engine, _ := NewEngine(Config{})
+	container := &CustomContainer{ID: "wasm-test", FileSystem: t.TempDir(), MemoryLimit: 16 << 20}
+	log := engine.runWasmAgent(context.Background(), testWasmCommand(wasiModule, "_start"), container)
+	for _, want := range []string{"fd_write: 1 times", "Standard Output:\nhello", "Exit code 0"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("Execution log should contain %q, got:\n%s", want, log)
+		}
+	}
+	if container.ExecutionError != "" {
+		t.Errorf("Module should run, got %s", container.ExecutionError)
+	}
+}
+
+// TestStrategyCoverage tests that audits record the dynamic strategy, and
+// that libraries leave the dynamic detectors not applicable
+func TestStrategyCoverage(t *testing.T) {
+	engine, _ := NewEngine(Config{})
+	if engine.staticOnly != "" {
+		t.Skip("Agents are not run on this platform")
+	}
+	report, err := engine.Audit(context.Background(), bytes.NewReader(testWasmCommand(wasiModule, "run")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	strategy := report.Coverage.DynamicStrategy
+	if strategy == nil || strategy.Name != StrategyNone {
+		t.Fatalf("Coverage should record that the library was not run, got %+v", strategy)
+	}
+	for _, component := range report.Coverage.Components {
+		if component.Phase == PhaseDynamic && component.Kind == ComponentDetector && component.Status != CoverageNotApplicable {
+			t.Errorf("Dynamic detectors should not apply to a library, got %+v", component)
+		}
+	}
+
+	report, err = engine.Audit(context.Background(), bytes.NewReader(testWasmCommand(wasiModule, "_start")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strategy := report.Coverage.DynamicStrategy; strategy == nil || strategy.Name != StrategyWASM {
+		t.Fatalf("Coverage should record the WASI runtime, got %+v", strategy)
+	}
+}
@@ -0,0 +1,143 @@
+package aegong
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The syscall policy turns the traced agent's syscall histogram into
+// findings. Loading kernel modules, replacing the kernel, mounting
+// filesystems or debugging other processes has no place in an agent, so
+// every listed syscall the agent made is reported with how often it made it
+// and when it first did, whether or not the kernel allowed it.
+
+// SyscallPolicy lists the syscalls unexpected for an agent
+type SyscallPolicy struct {
+	Rules []SyscallRule `json:"rules"`
+}
+
+// SyscallRule is a group of unexpected syscalls and how serious making one is
+type SyscallRule struct {
+	Name     string   `json:"name"` // Why the syscalls are unexpected, such as kernel_modules
+	Syscalls []string `json:"syscalls"`
+	Severity string   `json:"severity"` // LOW, MEDIUM, HIGH or CRITICAL
+}
+
+// SyscallUse is how often the traced agent made a syscall and when it first did
+type SyscallUse struct {
+	Count int       `json:"count"`
+	First time.Time `json:"first"`
+}
+
+// Syscall names as the tracer reports them
+var syscallNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// DefaultSyscallPolicy returns the syscalls flagged when no policy is configured
+func DefaultSyscallPolicy() *SyscallPolicy {
+	return &SyscallPolicy{Rules: []SyscallRule{
+		{Name: "kernel_modules", Syscalls: []string{"init_module", "finit_module", "delete_module"}, Severity: "CRITICAL"},
+		{Name: "kexec", Syscalls: []string{"kexec_load", "kexec_file_load"}, Severity: "CRITICAL"},
+		{Name: "mount", Syscalls: []string{"mount", "umount2", "pivot_root", "fsopen", "fsmount", "move_mount", "open_tree", "mount_setattr"}, Severity: "HIGH"},
+		{Name: "debugging", Syscalls: []string{"ptrace", "process_vm_readv", "process_vm_writev", "kcmp", "pidfd_getfd"}, Severity: "HIGH"},
+		{Name: "kernel_tracing", Syscalls: []string{"bpf", "perf_event_open"}, Severity: "HIGH"},
+		{Name: "host_configuration", Syscalls: []string{"reboot", "swapon", "swapoff", "sethostname", "setdomainname", "settimeofday", "clock_settime", "clock_adjtime", "adjtimex", "acct", "quotactl"}, Severity: "HIGH"},
+		{Name: "hardware_access", Syscalls: []string{"iopl", "ioperm"}, Severity: "HIGH"},
+	}}
+}
+
+// ParseSyscallPolicy decodes and validates a syscall policy. A policy with
+// no rules flags nothing.
+func ParseSyscallPolicy(data []byte) (*SyscallPolicy, error) {
+	var policy SyscallPolicy
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("failed to parse syscall policy: %v", err)
+	}
+	listed := make(map[string]string)
+	for _, rule := range policy.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("syscall policy rule without a name")
+		}
+		if _, err := parseSeverity(rule.Severity); err != nil {
+			return nil, fmt.Errorf("syscall policy rule %s: %v", rule.Name, err)
+		}
+		if len(rule.Syscalls) == 0 {
+			return nil, fmt.Errorf("syscall policy rule %s lists no syscalls", rule.Name)
+		}
+		for _, name := range rule.Syscalls {
+			if !syscallNamePattern.MatchString(name) {
+				return nil, fmt.Errorf("syscall policy rule %s: invalid syscall name %q", rule.Name, name)
+			}
+			if other, ok := listed[name]; ok {
+				return nil, fmt.Errorf("syscall %s is listed by both %s and %s", name, other, rule.Name)
+			}
+			listed[name] = rule.Name
+		}
+	}
+	return &policy, nil
+}
+
+// checksum identifies the policy in the detector config version
+func (p *SyscallPolicy) checksum() string {
+	data, _ := json.Marshal(p)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:8])
+}
+
+// syscallPolicyThreats reports the unexpected syscalls the agent made, one
+// T4 finding per rule
+func (c *CustomContainer) syscallPolicyThreats(policy *SyscallPolicy) []ThreatDetection {
+	if policy == nil || len(c.SyscallsMade) == 0 {
+		return nil
+	}
+
+	var threats []ThreatDetection
+	for _, rule := range policy.Rules {
+		severity, err := parseSeverity(rule.Severity)
+		if err != nil {
+			continue
+		}
+		made := make(map[string]SyscallUse)
+		var names []string
+		for _, name := range rule.Syscalls {
+			if use, ok := c.SyscallsMade[name]; ok && use.Count > 0 {
+				made[name] = use
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+
+		// Earliest first
+		sort.Slice(names, func(i, j int) bool { return made[names[i]].First.Before(made[names[j]].First) })
+		var evidence []string
+		for _, name := range names {
+			use := made[name]
+			evidence = append(evidence, fmt.Sprintf("Unexpected syscall %s (%s): made %d times, first at %s",
+				name, strings.ReplaceAll(rule.Name, "_", " "), use.Count, use.First.UTC().Format("15:04:05.000")))
+		}
+
+		threats = append(threats, ThreatDetection{
+			Vector:     T4_UNAUTHORIZED_ACTION,
+			Severity:   severity,
+			Confidence: 0.9,
+			Evidence:   evidence,
+			Timestamp:  time.Now(),
+			Details: map[string]interface{}{
+				"analysis": "syscall_policy",
+				"rule":     rule.Name,
+				"syscalls": made,
+			},
+		})
+	}
+	return threats
+}
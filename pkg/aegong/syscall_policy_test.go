@@ -0,0 +1,69 @@
+package aegong
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseSyscallPolicy tests syscall policy decoding and validation
+func TestParseSyscallPolicy(t *testing.T) {
+	policy, err := ParseSyscallPolicy([]byte(`{"rules": [{"name": "kernel", "syscalls": ["init_module"], "severity": "critical"}]}`))
+	if err != nil {
+		t.Fatalf("Failed to parse policy: %v", err)
+	}
+	if len(policy.Rules) != 1 || policy.Rules[0].Syscalls[0] != "init_module" {
+		t.Fatalf("Policy rules should be decoded, got %+v", policy)
+	}
+	if policy, err := ParseSyscallPolicy([]byte(`{"rules": []}`)); err != nil || len(policy.Rules) != 0 {
+		t.Fatalf("An empty policy should be accepted, got %+v, %v", policy, err)
+	}
+
+	for _, spec := range []string{
+		`{"rules": [{"name": "kernel", "syscalls": ["init_module"], "severity": "fatal"}]}`,
+		`{"rules": [{"name": "kernel", "syscalls": [], "severity": "HIGH"}]}`,
+		`{"rules": [{"syscalls": ["mount"], "severity": "HIGH"}]}`,
+		`{"rules": [{"name": "kernel", "syscalls": ["SYS_MOUNT"], "severity": "HIGH"}]}`,
+		`{"rules": [{"name": "a", "syscalls": ["mount"], "severity": "HIGH"}, {"name": "b", "syscalls": ["mount"], "severity": "LOW"}]}`,
+		`{"unexpected": ["mount"]}`,
+	} {
+		if _, err := ParseSyscallPolicy([]byte(spec)); err == nil {
+			t.Errorf("Policy %s should be rejected", spec)
+		}
+	}
+}
+
+// TestSyscallPolicyThreats tests that unexpected syscalls become findings with
+// counts and first occurrences
+func TestSyscallPolicyThreats(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	container := &CustomContainer{SyscallsMade: map[string]SyscallUse{
+		"read":          {Count: 120, First: start},
+		"finit_module":  {Count: 1, First: start.Add(2 * time.Second)},
+		"init_module":   {Count: 3, First: start.Add(time.Second)},
+		"ptrace":        {Count: 2, First: start.Add(500 * time.Millisecond)},
+		"kexec_load":    {Count: 0},
+		"clock_gettime": {Count: 40, First: start},
+	}}
+
+	threats := container.syscallPolicyThreats(DefaultSyscallPolicy())
+	if len(threats) != 2 {
+		t.Fatalf("Should report the kernel module and debugging rules, got %+v", threats)
+	}
+	modules := threats[0]
+	if modules.Vector != T4_UNAUTHORIZED_ACTION || modules.Severity != CRITICAL || modules.Details["rule"] != "kernel_modules" {
+		t.Errorf("Kernel modules should be a CRITICAL T4 finding, got %+v", modules)
+	}
+	if len(modules.Evidence) != 2 || modules.Evidence[0] != "Unexpected syscall init_module (kernel modules): made 3 times, first at 03:04:06.000" {
+		t.Errorf("Should list syscalls by first occurrence with their counts, got %v", modules.Evidence)
+	}
+	if threats[1].Severity != HIGH || threats[1].Details["rule"] != "debugging" {
+		t.Errorf("ptrace should be a HIGH debugging finding, got %+v", threats[1])
+	}
+
+	if threats := container.syscallPolicyThreats(&SyscallPolicy{}); len(threats) != 0 {
+		t.Errorf("An empty policy should flag nothing, got %+v", threats)
+	}
+	if threats := (&CustomContainer{}).syscallPolicyThreats(DefaultSyscallPolicy()); len(threats) != 0 {
+		t.Errorf("An agent that was not run should have no findings, got %+v", threats)
+	}
+}
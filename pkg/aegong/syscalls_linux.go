@@ -0,0 +1,47 @@
+//go:build linux
+
+package aegong
+
+import "fmt"
+
+// Syscall numbers from 424 on are shared by every architecture
+var unifiedSyscallNames = map[uint64]string{
+	424: "pidfd_send_signal",
+	425: "io_uring_setup",
+	426: "io_uring_enter",
+	427: "io_uring_register",
+	428: "open_tree",
+	429: "move_mount",
+	430: "fsopen",
+	431: "fsconfig",
+	432: "fsmount",
+	433: "fspick",
+	434: "pidfd_open",
+	435: "clone3",
+	436: "close_range",
+	437: "openat2",
+	438: "pidfd_getfd",
+	439: "faccessat2",
+	440: "process_madvise",
+	441: "epoll_pwait2",
+	442: "mount_setattr",
+	443: "quotactl_fd",
+	444: "landlock_create_ruleset",
+	445: "landlock_add_rule",
+	446: "landlock_restrict_self",
+	447: "memfd_secret",
+	448: "process_mrelease",
+	449: "futex_waitv",
+	450: "set_mempolicy_home_node",
+}
+
+// getSyscallName names a syscall number of the host architecture
+func getSyscallName(syscallNum uint64) string {
+	if name, ok := syscallNames[syscallNum]; ok {
+		return name
+	}
+	if name, ok := unifiedSyscallNames[syscallNum]; ok {
+		return name
+	}
+	return fmt.Sprintf("syscall_%d", syscallNum)
+}
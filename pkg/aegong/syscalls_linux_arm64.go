@@ -0,0 +1,301 @@
+package aegong
+
+import "syscall"
+
+const sysSetns = syscall.SYS_SETNS
+
+// Syscall names by number on arm64
+var syscallNames = map[uint64]string{
+	syscall.SYS_IO_SETUP:               "io_setup",
+	syscall.SYS_IO_DESTROY:             "io_destroy",
+	syscall.SYS_IO_SUBMIT:              "io_submit",
+	syscall.SYS_IO_CANCEL:              "io_cancel",
+	syscall.SYS_IO_GETEVENTS:           "io_getevents",
+	syscall.SYS_SETXATTR:               "setxattr",
+	syscall.SYS_LSETXATTR:              "lsetxattr",
+	syscall.SYS_FSETXATTR:              "fsetxattr",
+	syscall.SYS_GETXATTR:               "getxattr",
+	syscall.SYS_LGETXATTR:              "lgetxattr",
+	syscall.SYS_FGETXATTR:              "fgetxattr",
+	syscall.SYS_LISTXATTR:              "listxattr",
+	syscall.SYS_LLISTXATTR:             "llistxattr",
+	syscall.SYS_FLISTXATTR:             "flistxattr",
+	syscall.SYS_REMOVEXATTR:            "removexattr",
+	syscall.SYS_LREMOVEXATTR:           "lremovexattr",
+	syscall.SYS_FREMOVEXATTR:           "fremovexattr",
+	syscall.SYS_GETCWD:                 "getcwd",
+	syscall.SYS_LOOKUP_DCOOKIE:         "lookup_dcookie",
+	syscall.SYS_EVENTFD2:               "eventfd2",
+	syscall.SYS_EPOLL_CREATE1:          "epoll_create1",
+	syscall.SYS_EPOLL_CTL:              "epoll_ctl",
+	syscall.SYS_EPOLL_PWAIT:            "epoll_pwait",
+	syscall.SYS_DUP:                    "dup",
+	syscall.SYS_DUP3:                   "dup3",
+	syscall.SYS_FCNTL:                  "fcntl",
+	syscall.SYS_INOTIFY_INIT1:          "inotify_init1",
+	syscall.SYS_INOTIFY_ADD_WATCH:      "inotify_add_watch",
+	syscall.SYS_INOTIFY_RM_WATCH:       "inotify_rm_watch",
+	syscall.SYS_IOCTL:                  "ioctl",
+	syscall.SYS_IOPRIO_SET:             "ioprio_set",
+	syscall.SYS_IOPRIO_GET:             "ioprio_get",
+	syscall.SYS_FLOCK:                  "flock",
+	syscall.SYS_MKNODAT:                "mknodat",
+	syscall.SYS_MKDIRAT:                "mkdirat",
+	syscall.SYS_UNLINKAT:               "unlinkat",
+	syscall.SYS_SYMLINKAT:              "symlinkat",
+	syscall.SYS_LINKAT:                 "linkat",
+	syscall.SYS_RENAMEAT:               "renameat",
+	syscall.SYS_UMOUNT2:                "umount2",
+	syscall.SYS_MOUNT:                  "mount",
+	syscall.SYS_PIVOT_ROOT:             "pivot_root",
+	syscall.SYS_NFSSERVCTL:             "nfsservctl",
+	syscall.SYS_STATFS:                 "statfs",
+	syscall.SYS_FSTATFS:                "fstatfs",
+	syscall.SYS_TRUNCATE:               "truncate",
+	syscall.SYS_FTRUNCATE:              "ftruncate",
+	syscall.SYS_FALLOCATE:              "fallocate",
+	syscall.SYS_FACCESSAT:              "faccessat",
+	syscall.SYS_CHDIR:                  "chdir",
+	syscall.SYS_FCHDIR:                 "fchdir",
+	syscall.SYS_CHROOT:                 "chroot",
+	syscall.SYS_FCHMOD:                 "fchmod",
+	syscall.SYS_FCHMODAT:               "fchmodat",
+	syscall.SYS_FCHOWNAT:               "fchownat",
+	syscall.SYS_FCHOWN:                 "fchown",
+	syscall.SYS_OPENAT:                 "openat",
+	syscall.SYS_CLOSE:                  "close",
+	syscall.SYS_VHANGUP:                "vhangup",
+	syscall.SYS_PIPE2:                  "pipe2",
+	syscall.SYS_QUOTACTL:               "quotactl",
+	syscall.SYS_GETDENTS64:             "getdents64",
+	syscall.SYS_LSEEK:                  "lseek",
+	syscall.SYS_READ:                   "read",
+	syscall.SYS_WRITE:                  "write",
+	syscall.SYS_READV:                  "readv",
+	syscall.SYS_WRITEV:                 "writev",
+	syscall.SYS_PREAD64:                "pread64",
+	syscall.SYS_PWRITE64:               "pwrite64",
+	syscall.SYS_PREADV:                 "preadv",
+	syscall.SYS_PWRITEV:                "pwritev",
+	syscall.SYS_SENDFILE:               "sendfile",
+	syscall.SYS_PSELECT6:               "pselect6",
+	syscall.SYS_PPOLL:                  "ppoll",
+	syscall.SYS_SIGNALFD4:              "signalfd4",
+	syscall.SYS_VMSPLICE:               "vmsplice",
+	syscall.SYS_SPLICE:                 "splice",
+	syscall.SYS_TEE:                    "tee",
+	syscall.SYS_READLINKAT:             "readlinkat",
+	syscall.SYS_FSTATAT:                "newfstatat",
+	syscall.SYS_FSTAT:                  "fstat",
+	syscall.SYS_SYNC:                   "sync",
+	syscall.SYS_FSYNC:                  "fsync",
+	syscall.SYS_FDATASYNC:              "fdatasync",
+	syscall.SYS_SYNC_FILE_RANGE:        "sync_file_range",
+	syscall.SYS_TIMERFD_CREATE:         "timerfd_create",
+	syscall.SYS_TIMERFD_SETTIME:        "timerfd_settime",
+	syscall.SYS_TIMERFD_GETTIME:        "timerfd_gettime",
+	syscall.SYS_UTIMENSAT:              "utimensat",
+	syscall.SYS_ACCT:                   "acct",
+	syscall.SYS_CAPGET:                 "capget",
+	syscall.SYS_CAPSET:                 "capset",
+	syscall.SYS_PERSONALITY:            "personality",
+	syscall.SYS_EXIT:                   "exit",
+	syscall.SYS_EXIT_GROUP:             "exit_group",
+	syscall.SYS_WAITID:                 "waitid",
+	syscall.SYS_SET_TID_ADDRESS:        "set_tid_address",
+	syscall.SYS_UNSHARE:                "unshare",
+	syscall.SYS_FUTEX:                  "futex",
+	syscall.SYS_SET_ROBUST_LIST:        "set_robust_list",
+	syscall.SYS_GET_ROBUST_LIST:        "get_robust_list",
+	syscall.SYS_NANOSLEEP:              "nanosleep",
+	syscall.SYS_GETITIMER:              "getitimer",
+	syscall.SYS_SETITIMER:              "setitimer",
+	syscall.SYS_KEXEC_LOAD:             "kexec_load",
+	syscall.SYS_INIT_MODULE:            "init_module",
+	syscall.SYS_DELETE_MODULE:          "delete_module",
+	syscall.SYS_TIMER_CREATE:           "timer_create",
+	syscall.SYS_TIMER_GETTIME:          "timer_gettime",
+	syscall.SYS_TIMER_GETOVERRUN:       "timer_getoverrun",
+	syscall.SYS_TIMER_SETTIME:          "timer_settime",
+	syscall.SYS_TIMER_DELETE:           "timer_delete",
+	syscall.SYS_CLOCK_SETTIME:          "clock_settime",
+	syscall.SYS_CLOCK_GETTIME:          "clock_gettime",
+	syscall.SYS_CLOCK_GETRES:           "clock_getres",
+	syscall.SYS_CLOCK_NANOSLEEP:        "clock_nanosleep",
+	syscall.SYS_SYSLOG:                 "syslog",
+	syscall.SYS_PTRACE:                 "ptrace",
+	syscall.SYS_SCHED_SETPARAM:         "sched_setparam",
+	syscall.SYS_SCHED_SETSCHEDULER:     "sched_setscheduler",
+	syscall.SYS_SCHED_GETSCHEDULER:     "sched_getscheduler",
+	syscall.SYS_SCHED_GETPARAM:         "sched_getparam",
+	syscall.SYS_SCHED_SETAFFINITY:      "sched_setaffinity",
+	syscall.SYS_SCHED_GETAFFINITY:      "sched_getaffinity",
+	syscall.SYS_SCHED_YIELD:            "sched_yield",
+	syscall.SYS_SCHED_GET_PRIORITY_MAX: "sched_get_priority_max",
+	syscall.SYS_SCHED_GET_PRIORITY_MIN: "sched_get_priority_min",
+	syscall.SYS_SCHED_RR_GET_INTERVAL:  "sched_rr_get_interval",
+	syscall.SYS_RESTART_SYSCALL:        "restart_syscall",
+	syscall.SYS_KILL:                   "kill",
+	syscall.SYS_TKILL:                  "tkill",
+	syscall.SYS_TGKILL:                 "tgkill",
+	syscall.SYS_SIGALTSTACK:            "sigaltstack",
+	syscall.SYS_RT_SIGSUSPEND:          "rt_sigsuspend",
+	syscall.SYS_RT_SIGACTION:           "rt_sigaction",
+	syscall.SYS_RT_SIGPROCMASK:         "rt_sigprocmask",
+	syscall.SYS_RT_SIGPENDING:          "rt_sigpending",
+	syscall.SYS_RT_SIGTIMEDWAIT:        "rt_sigtimedwait",
+	syscall.SYS_RT_SIGQUEUEINFO:        "rt_sigqueueinfo",
+	syscall.SYS_RT_SIGRETURN:           "rt_sigreturn",
+	syscall.SYS_SETPRIORITY:            "setpriority",
+	syscall.SYS_GETPRIORITY:            "getpriority",
+	syscall.SYS_REBOOT:                 "reboot",
+	syscall.SYS_SETREGID:               "setregid",
+	syscall.SYS_SETGID:                 "setgid",
+	syscall.SYS_SETREUID:               "setreuid",
+	syscall.SYS_SETUID:                 "setuid",
+	syscall.SYS_SETRESUID:              "setresuid",
+	syscall.SYS_GETRESUID:              "getresuid",
+	syscall.SYS_SETRESGID:              "setresgid",
+	syscall.SYS_GETRESGID:              "getresgid",
+	syscall.SYS_SETFSUID:               "setfsuid",
+	syscall.SYS_SETFSGID:               "setfsgid",
+	syscall.SYS_TIMES:                  "times",
+	syscall.SYS_SETPGID:                "setpgid",
+	syscall.SYS_GETPGID:                "getpgid",
+	syscall.SYS_GETSID:                 "getsid",
+	syscall.SYS_SETSID:                 "setsid",
+	syscall.SYS_GETGROUPS:              "getgroups",
+	syscall.SYS_SETGROUPS:              "setgroups",
+	syscall.SYS_UNAME:                  "uname",
+	syscall.SYS_SETHOSTNAME:            "sethostname",
+	syscall.SYS_SETDOMAINNAME:          "setdomainname",
+	syscall.SYS_GETRLIMIT:              "getrlimit",
+	syscall.SYS_SETRLIMIT:              "setrlimit",
+	syscall.SYS_GETRUSAGE:              "getrusage",
+	syscall.SYS_UMASK:                  "umask",
+	syscall.SYS_PRCTL:                  "prctl",
+	syscall.SYS_GETCPU:                 "getcpu",
+	syscall.SYS_GETTIMEOFDAY:           "gettimeofday",
+	syscall.SYS_SETTIMEOFDAY:           "settimeofday",
+	syscall.SYS_ADJTIMEX:               "adjtimex",
+	syscall.SYS_GETPID:                 "getpid",
+	syscall.SYS_GETPPID:                "getppid",
+	syscall.SYS_GETUID:                 "getuid",
+	syscall.SYS_GETEUID:                "geteuid",
+	syscall.SYS_GETGID:                 "getgid",
+	syscall.SYS_GETEGID:                "getegid",
+	syscall.SYS_GETTID:                 "gettid",
+	syscall.SYS_SYSINFO:                "sysinfo",
+	syscall.SYS_MQ_OPEN:                "mq_open",
+	syscall.SYS_MQ_UNLINK:              "mq_unlink",
+	syscall.SYS_MQ_TIMEDSEND:           "mq_timedsend",
+	syscall.SYS_MQ_TIMEDRECEIVE:        "mq_timedreceive",
+	syscall.SYS_MQ_NOTIFY:              "mq_notify",
+	syscall.SYS_MQ_GETSETATTR:          "mq_getsetattr",
+	syscall.SYS_MSGGET:                 "msgget",
+	syscall.SYS_MSGCTL:                 "msgctl",
+	syscall.SYS_MSGRCV:                 "msgrcv",
+	syscall.SYS_MSGSND:                 "msgsnd",
+	syscall.SYS_SEMGET:                 "semget",
+	syscall.SYS_SEMCTL:                 "semctl",
+	syscall.SYS_SEMTIMEDOP:             "semtimedop",
+	syscall.SYS_SEMOP:                  "semop",
+	syscall.SYS_SHMGET:                 "shmget",
+	syscall.SYS_SHMCTL:                 "shmctl",
+	syscall.SYS_SHMAT:                  "shmat",
+	syscall.SYS_SHMDT:                  "shmdt",
+	syscall.SYS_SOCKET:                 "socket",
+	syscall.SYS_SOCKETPAIR:             "socketpair",
+	syscall.SYS_BIND:                   "bind",
+	syscall.SYS_LISTEN:                 "listen",
+	syscall.SYS_ACCEPT:                 "accept",
+	syscall.SYS_CONNECT:                "connect",
+	syscall.SYS_GETSOCKNAME:            "getsockname",
+	syscall.SYS_GETPEERNAME:            "getpeername",
+	syscall.SYS_SENDTO:                 "sendto",
+	syscall.SYS_RECVFROM:               "recvfrom",
+	syscall.SYS_SETSOCKOPT:             "setsockopt",
+	syscall.SYS_GETSOCKOPT:             "getsockopt",
+	syscall.SYS_SHUTDOWN:               "shutdown",
+	syscall.SYS_SENDMSG:                "sendmsg",
+	syscall.SYS_RECVMSG:                "recvmsg",
+	syscall.SYS_READAHEAD:              "readahead",
+	syscall.SYS_BRK:                    "brk",
+	syscall.SYS_MUNMAP:                 "munmap",
+	syscall.SYS_MREMAP:                 "mremap",
+	syscall.SYS_ADD_KEY:                "add_key",
+	syscall.SYS_REQUEST_KEY:            "request_key",
+	syscall.SYS_KEYCTL:                 "keyctl",
+	syscall.SYS_CLONE:                  "clone",
+	syscall.SYS_EXECVE:                 "execve",
+	syscall.SYS_MMAP:                   "mmap",
+	syscall.SYS_FADVISE64:              "fadvise64",
+	syscall.SYS_SWAPON:                 "swapon",
+	syscall.SYS_SWAPOFF:                "swapoff",
+	syscall.SYS_MPROTECT:               "mprotect",
+	syscall.SYS_MSYNC:                  "msync",
+	syscall.SYS_MLOCK:                  "mlock",
+	syscall.SYS_MUNLOCK:                "munlock",
+	syscall.SYS_MLOCKALL:               "mlockall",
+	syscall.SYS_MUNLOCKALL:             "munlockall",
+	syscall.SYS_MINCORE:                "mincore",
+	syscall.SYS_MADVISE:                "madvise",
+	syscall.SYS_REMAP_FILE_PAGES:       "remap_file_pages",
+	syscall.SYS_MBIND:                  "mbind",
+	syscall.SYS_GET_MEMPOLICY:          "get_mempolicy",
+	syscall.SYS_SET_MEMPOLICY:          "set_mempolicy",
+	syscall.SYS_MIGRATE_PAGES:          "migrate_pages",
+	syscall.SYS_MOVE_PAGES:             "move_pages",
+	syscall.SYS_RT_TGSIGQUEUEINFO:      "rt_tgsigqueueinfo",
+	syscall.SYS_PERF_EVENT_OPEN:        "perf_event_open",
+	syscall.SYS_ACCEPT4:                "accept4",
+	syscall.SYS_RECVMMSG:               "recvmmsg",
+	syscall.SYS_WAIT4:                  "wait4",
+	syscall.SYS_PRLIMIT64:              "prlimit64",
+	syscall.SYS_FANOTIFY_INIT:          "fanotify_init",
+	syscall.SYS_FANOTIFY_MARK:          "fanotify_mark",
+	syscall.SYS_NAME_TO_HANDLE_AT:      "name_to_handle_at",
+	syscall.SYS_OPEN_BY_HANDLE_AT:      "open_by_handle_at",
+	syscall.SYS_CLOCK_ADJTIME:          "clock_adjtime",
+	syscall.SYS_SYNCFS:                 "syncfs",
+	syscall.SYS_SETNS:                  "setns",
+	syscall.SYS_SENDMMSG:               "sendmmsg",
+	syscall.SYS_PROCESS_VM_READV:       "process_vm_readv",
+	syscall.SYS_PROCESS_VM_WRITEV:      "process_vm_writev",
+	syscall.SYS_KCMP:                   "kcmp",
+	syscall.SYS_FINIT_MODULE:           "finit_module",
+	syscall.SYS_SCHED_SETATTR:          "sched_setattr",
+	syscall.SYS_SCHED_GETATTR:          "sched_getattr",
+	syscall.SYS_RENAMEAT2:              "renameat2",
+	syscall.SYS_SECCOMP:                "seccomp",
+	syscall.SYS_GETRANDOM:              "getrandom",
+	syscall.SYS_MEMFD_CREATE:           "memfd_create",
+	syscall.SYS_BPF:                    "bpf",
+	syscall.SYS_EXECVEAT:               "execveat",
+	// Newer than the syscall package
+	282: "userfaultfd",
+	283: "membarrier",
+	284: "mlock2",
+	285: "copy_file_range",
+	286: "preadv2",
+	287: "pwritev2",
+	288: "pkey_mprotect",
+	289: "pkey_alloc",
+	290: "pkey_free",
+	291: "statx",
+	292: "io_pgetevents",
+	293: "rseq",
+	294: "kexec_file_load",
+}
+
+// syscallRegs reads the number and arguments of a syscall at its entry stop.
+// x0 holds the first argument until the kernel overwrites it with the return
+// value, so arguments are only valid here.
+func syscallRegs(regs *syscall.PtraceRegs) (uint64, [6]uint64) {
+	return regs.Regs[8], [6]uint64{regs.Regs[0], regs.Regs[1], regs.Regs[2], regs.Regs[3], regs.Regs[4], regs.Regs[5]}
+}
+
+// syscallReturn reads the return value of a syscall at its exit stop
+func syscallReturn(regs *syscall.PtraceRegs) int64 {
+	return int64(regs.Regs[0])
+}
@@ -0,0 +1,50 @@
+//go:build linux
+
+package aegong
+
+import (
+	"syscall"
+	"testing"
+)
+
+// TestGetSyscallName tests naming syscalls of the host architecture
+func TestGetSyscallName(t *testing.T) {
+	for num, expected := range map[uint64]string{
+		syscall.SYS_OPENAT:  "openat",
+		syscall.SYS_MOUNT:   "mount",
+		syscall.SYS_EXECVE:  "execve",
+		syscall.SYS_CONNECT: "connect",
+		sysSetns:            "setns",
+		435:                 "clone3",
+		446:                 "landlock_restrict_self",
+		100000:              "syscall_100000",
+	} {
+		if got := getSyscallName(num); got != expected {
+			t.Errorf("%d: Should be named %q, got %q", num, expected, got)
+		}
+	}
+
+	names := make(map[string]uint64)
+	for num, name := range syscallNames {
+		if other, ok := names[name]; ok {
+			t.Errorf("%s: Should have one number, got %d and %d", name, other, num)
+		}
+		names[name] = num
+	}
+}
+
+// TestDeniedWrite tests reading the path of a refused write from its arguments
+func TestDeniedWrite(t *testing.T) {
+	call := &tracedSyscall{Name: "openat", Args: [6]uint64{0, 0, syscall.O_RDONLY}}
+	if _, ok := deniedWrite(0, call, syscall.EACCES); ok {
+		t.Error("Should ignore reads")
+	}
+	call.Args[2] = syscall.O_WRONLY | syscall.O_CREAT
+	if _, ok := deniedWrite(0, call, syscall.ENOENT); ok {
+		t.Error("Should ignore failures other than permission")
+	}
+	denied, ok := deniedWrite(0, call, syscall.EACCES)
+	if !ok || denied.Syscall != "openat" || denied.Error != syscall.EACCES.Error() {
+		t.Errorf("Should report a refused write, got %+v", denied)
+	}
+}
@@ -0,0 +1,915 @@
+package aegong
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Taint analysis for script agents
+//
+// Data read from the network, users or LLM responses is tainted. Taint spreads
+// through assignments, loops and callbacks, and a flow is reported when tainted
+// data reaches code execution, shell commands, deserialization or file writes.
+// Each function has its own variables; calls into local functions are followed
+// through summaries of what they return and which parameters reach a sink.
+// The analysis is flow-insensitive and line based: it trades recall for
+// precision so that every finding comes with a concrete trace.
+
+// Kinds of taint source
+const (
+	taintSourceLLM     = "llm"
+	taintSourceNetwork = "network"
+	taintSourceUser    = "user_input"
+	taintSourceParam   = "parameter" // Placeholder used to summarise functions
+)
+
+// Kinds of taint sink
+const (
+	taintSinkCode        = "code_execution"
+	taintSinkCommand     = "command_execution"
+	taintSinkDeserialize = "deserialization"
+	taintSinkFileWrite   = "file_write"
+)
+
+// TaintStep is one line of a source to sink trace
+type TaintStep struct {
+	Line int    `json:"line"`
+	Code string `json:"code"`
+}
+
+// TaintFlow is tainted data reaching a dangerous sink
+type TaintFlow struct {
+	Source string      `json:"source"`
+	Sink   string      `json:"sink"`
+	Call   string      `json:"call"`
+	Trace  []TaintStep `json:"trace"`
+}
+
+type taintPattern struct {
+	kind    string
+	pattern *regexp.Regexp
+}
+
+type taintSink struct {
+	kind    string
+	pattern *regexp.Regexp // Must end at the call's opening parenthesis
+}
+
+type taintLanguage struct {
+	name        string
+	sources     []taintPattern
+	sinks       []taintSink
+	sanitizers  *regexp.Regexp
+	assignments []*regexp.Regexp // Group 1 is the target list, group 2 the expression
+}
+
+var pythonTaint = &taintLanguage{
+	name: "python",
+	sources: []taintPattern{
+		{taintSourceLLM, regexp.MustCompile(`\.(?:chat\.)?completions\.create\(|\.messages\.create\(|\.generate_content\(|\bollama\.(?:chat|generate)\(|\b(?:llm|chain|agent|model|chat_model)\.a?(?:invoke|run|predict|generate)\(`)},
+		{taintSourceNetwork, regexp.MustCompile(`\b(?:requests|httpx|session|client)\.(?:get|post|put|patch|delete|request)\(|\burlopen\(|\.recv(?:from)?\(|\bwebsocket\.recv\(`)},
+		{taintSourceUser, regexp.MustCompile(`(?:^|[^\w.])input\(|\bsys\.stdin\b|\brequest\.(?:args|form|json|data|values|files|get_json)\b`)},
+	},
+	sinks: []taintSink{
+		{taintSinkCode, regexp.MustCompile(`(?:^|[^\w.])(?:eval|exec|compile)\(`)},
+		{taintSinkCommand, regexp.MustCompile(`\bos\.(?:system|popen|exec\w*|spawn\w*)\(|\bsubprocess\.(?:run|call|Popen|check_output|check_call|getoutput|getstatusoutput)\(`)},
+		{taintSinkDeserialize, regexp.MustCompile(`\b(?:pickle|cPickle|dill|marshal)\.loads?\(|\byaml\.load\(`)},
+		{taintSinkFileWrite, regexp.MustCompile(`\.write_(?:text|bytes)\(`)},
+	},
+	sanitizers: regexp.MustCompile(`\b(?:shlex\.quote|int|float|bool|len|html\.escape|os\.path\.basename)\(`),
+	assignments: []*regexp.Regexp{
+		regexp.MustCompile(`^\s*([A-Za-z_][\w.]*(?:\s*,\s*[A-Za-z_][\w.]*)*)\s*(?::[^=]+)?[-+*/|]?=\s*([^=].*)$`),
+		regexp.MustCompile(`^\s*(?:async\s+)?for\s+([A-Za-z_][\w\s,]*?)\s+in\s+(.+):`),
+	},
+}
+
+var javascriptTaint = &taintLanguage{
+	name: "javascript",
+	sources: []taintPattern{
+		{taintSourceLLM, regexp.MustCompile(`\.(?:chat\.)?completions\.create\(|\.messages\.create\(|\.generateContent\(|\b(?:llm|chain|agent|model|chatModel)\.(?:invoke|call|predict|generate)\(`)},
+		{taintSourceNetwork, regexp.MustCompile(`(?:^|[^\w.$])fetch\(|\baxios(?:\.(?:get|post|put|patch|delete|request))?\(|\bgot(?:\.(?:get|post))?\(`)},
+		{taintSourceUser, regexp.MustCompile(`\breq\.(?:body|query|params|headers)\b|\bprocess\.stdin\b`)},
+	},
+	sinks: []taintSink{
+		{taintSinkCode, regexp.MustCompile(`(?:^|[^\w.$])eval\(|\bnew\s+Function\(|\bvm\.(?:runIn\w+|Script)\(`)},
+		{taintSinkFileWrite, regexp.MustCompile(`\b(?:fs|fsPromises)(?:\.promises)?\.(?:writeFile|writeFileSync|appendFile|appendFileSync|createWriteStream)\(`)},
+	},
+	sanitizers: regexp.MustCompile(`\b(?:parseInt|parseFloat|Number|Boolean|encodeURIComponent|path\.basename|shellEscape|escapeShellArg)\(`),
+	assignments: []*regexp.Regexp{
+		regexp.MustCompile(`^\s*(?:(?:export\s+)?(?:const|let|var)\s+)?([A-Za-z_$][\w$.]*)\s*[-+]?=\s*([^=>].*)$`),
+		regexp.MustCompile(`^\s*(?:const|let|var)\s*[{\[]([^}\]]*)[}\]]\s*=\s*(.+)$`),
+		regexp.MustCompile(`\bfor\s*\(\s*(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s+of\s+(.+)\)`),
+	},
+}
+
+// child_process sinks only count in files that import it, so RegExp.exec is not mistaken for one
+var jsCommandSink = taintSink{taintSinkCommand, regexp.MustCompile(`(?:(?:child_process|childProcess|cp)\.|(?:^|[^\w.$]))(?:exec|execSync|spawn|spawnSync|execFile|execFileSync)\(`)}
+
+var (
+	pyWithAs        = regexp.MustCompile(`^\s*(?:async\s+)?with\s+(.+?)\s+as\s+([A-Za-z_]\w*)\s*:`)
+	pyOpenCall      = regexp.MustCompile(`(?:^|[^\w.])open\(`)
+	pyWriteMode     = regexp.MustCompile(`,\s*(?:mode\s*=\s*)?['"][rb]*[wax]`)
+	pyHandleWrite   = regexp.MustCompile(`\b([A-Za-z_]\w*)\.write(?:lines)?\(`)
+	pySafeYAML      = regexp.MustCompile(`SafeLoader|safe_load`)
+	pythonHints     = regexp.MustCompile(`(?m)^\s*(?:def \w+\(|import \w|from [\w.]+ import |class \w+.*:\s*$|elif |print\()`)
+	javascriptHints = regexp.MustCompile(`(?m)\bfunction\b|=>|^\s*(?:const|let|var) |\brequire\(|module\.exports|^\s*import .* from ['"]`)
+	jsCallbackArgs  = regexp.MustCompile(`\.(?:then|on)\(\s*(?:['"]message['"]\s*,\s*)?(?:async\s*)?(?:function\s*\w*\s*)?\(?\s*([A-Za-z_$][\w$]*)`)
+	jsMessageEvent  = regexp.MustCompile(`\.on\(\s*['"]message['"]`)
+	pyFunctionDef   = regexp.MustCompile(`^(\s*)(?:async\s+)?def\s+(\w+)\s*\(([^)]*)\)`)
+	jsFunctionDef   = regexp.MustCompile(`(?:\bfunction\s*\*?\s*([A-Za-z_$][\w$]*)|^\s*(?:(?:export\s+)?(?:const|let|var)\s+)?(?:static\s+)?(?:async\s+)?([A-Za-z_$][\w$]*)\s*(?:=\s*(?:async\s*)?(?:function\s*\w*\s*)?)?)\s*\(([^)]*)\)\s*(?:=>\s*)?\{`)
+	returnStatement = regexp.MustCompile(`^\s*return\s+(.+?);?\s*$`)
+	jsKeywords      = map[string]bool{"if": true, "for": true, "while": true, "switch": true, "catch": true, "with": true, "return": true}
+)
+
+// taintedValue records how a variable became tainted
+type taintedValue struct {
+	source string
+	param  int // Parameter index for taintSourceParam, otherwise -1
+	trace  []TaintStep
+}
+
+// logicalLine is a statement that may span several physical lines
+type logicalLine struct {
+	number int
+	code   string // Whitespace-collapsed text for traces
+	raw    string // Original text
+	masked string // raw with comments and string contents blanked
+}
+
+// analyzeTaint runs the taint analysis over a script agent and returns T1/T4 findings
+func analyzeTaint(data []byte) []ThreatDetection {
+	lang := detectScriptLanguage(data)
+	if lang == nil {
+		return nil
+	}
+	flows := findTaintFlows(string(data), lang)
+	if len(flows) == 0 {
+		return nil
+	}
+
+	// LLM output steering eval/exec hijacks the agent's reasoning; anything else is an unauthorized action
+	grouped := make(map[ThreatVector][]TaintFlow)
+	for _, flow := range flows {
+		vector := T4_UNAUTHORIZED_ACTION
+		if flow.Source == taintSourceLLM && flow.Sink == taintSinkCode {
+			vector = T1_REASONING_HIJACK
+		}
+		grouped[vector] = append(grouped[vector], flow)
+	}
+
+	var threats []ThreatDetection
+	for _, vector := range []ThreatVector{T1_REASONING_HIJACK, T4_UNAUTHORIZED_ACTION} {
+		vectorFlows := grouped[vector]
+		if len(vectorFlows) == 0 {
+			continue
+		}
+
+		severity := HIGH
+		evidence := make([]string, 0, len(vectorFlows))
+		for _, flow := range vectorFlows {
+			if flow.Sink == taintSinkCode || flow.Sink == taintSinkCommand {
+				severity = CRITICAL
+			}
+			evidence = append(evidence, flow.String())
+		}
+
+		threats = append(threats, ThreatDetection{
+			Vector:     vector,
+			Severity:   severity,
+			Confidence: 0.9,
+			Evidence:   evidence,
+			Timestamp:  time.Now(),
+			Details: map[string]interface{}{
+				"analysis":    "taint",
+				"language":    lang.name,
+				"taint_flows": vectorFlows,
+			},
+			Location: flowLocation(data, vectorFlows[0]),
+		})
+	}
+	return threats
+}
+
+// flowLocation is where a flow reaches its sink
+func flowLocation(data []byte, flow TaintFlow) *Location {
+	if len(flow.Trace) == 0 {
+		return nil
+	}
+	return locate(data, lineOffset(data, flow.Trace[len(flow.Trace)-1].Line), true)
+}
+
+// String renders a flow as "source at line 3 -> line 5 -> sink at line 9"
+func (f TaintFlow) String() string {
+	steps := make([]string, len(f.Trace))
+	for i, step := range f.Trace {
+		steps[i] = fmt.Sprintf("line %d `%s`", step.Line, step.Code)
+	}
+	return fmt.Sprintf("%s data reaches %s via %s: %s", f.Source, f.Sink, f.Call, strings.Join(steps, " -> "))
+}
+
+// detectScriptLanguage guesses whether data is Python or JavaScript source
+func detectScriptLanguage(data []byte) *taintLanguage {
+	head := data
+	if len(head) > 8192 {
+		head = head[:8192]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil
+	}
+
+	text := string(data)
+	python := len(pythonHints.FindAllStringIndex(text, -1))
+	javascript := len(javascriptHints.FindAllStringIndex(text, -1))
+
+	switch {
+	case python == 0 && javascript == 0:
+		return nil
+	case python >= javascript:
+		return pythonTaint
+	default:
+		return javascriptTaint
+	}
+}
+
+// taintFunction is a function defined in the analysed script
+type taintFunction struct {
+	name       string
+	params     []string
+	def        int // Index of the defining line
+	end        int // Index after the last body line
+	parent     int // Enclosing function, -1 at module level
+	returns    *taintedValue
+	paramSinks map[int][]TaintFlow // Flows from each parameter into a sink
+}
+
+// taintAnalysis holds the state of one fixed-point run over a script
+type taintAnalysis struct {
+	lang        *taintLanguage
+	lines       []logicalLine
+	sinks       []taintSink
+	functions   []*taintFunction
+	scope       []int // Innermost function of each line, -1 at module level
+	env         map[int]map[string]*taintedValue
+	fileHandles map[string]bool // Python files opened for writing
+	flows       []TaintFlow
+	seen        map[string]bool
+	changed     bool
+}
+
+// findTaintFlows propagates taint to a fixed point and returns the flows into sinks
+func findTaintFlows(text string, lang *taintLanguage) []TaintFlow {
+	return runTaintAnalysis(text, lang).flows
+}
+
+// runTaintAnalysis propagates taint through a script to a fixed point
+func runTaintAnalysis(text string, lang *taintLanguage) *taintAnalysis {
+	a := &taintAnalysis{
+		lang:        lang,
+		lines:       splitLogicalLines(text, lang),
+		sinks:       lang.sinks,
+		env:         make(map[int]map[string]*taintedValue),
+		fileHandles: make(map[string]bool),
+		seen:        make(map[string]bool),
+	}
+	if lang == javascriptTaint && strings.Contains(text, "child_process") {
+		a.sinks = append(append([]taintSink{}, a.sinks...), jsCommandSink)
+	}
+	a.findFunctions()
+
+	// Parameters are tainted with placeholders so their flows become call summaries
+	for i, fn := range a.functions {
+		step := TaintStep{Line: a.lines[fn.def].number, Code: a.lines[fn.def].code}
+		for p, param := range fn.params {
+			a.taint(i, param, &taintedValue{source: taintSourceParam, param: p, trace: []TaintStep{step}})
+		}
+	}
+
+	for pass := 0; pass < 10; pass++ {
+		a.changed = false
+		for i := range a.lines {
+			a.propagate(i)
+			a.checkSinks(i)
+		}
+		if !a.changed {
+			break
+		}
+	}
+	return a
+}
+
+// findFunctions locates function definitions and the lines they contain
+func (a *taintAnalysis) findFunctions() {
+	a.scope = make([]int, len(a.lines))
+	for i := range a.scope {
+		a.scope[i] = -1
+	}
+
+	for i, line := range a.lines {
+		var fn *taintFunction
+		if a.lang == pythonTaint {
+			match := pyFunctionDef.FindStringSubmatch(line.masked)
+			if match == nil {
+				continue
+			}
+			fn = &taintFunction{name: match[2], params: paramNames(match[3], true), def: i, end: i + 1}
+			indent := len(match[1])
+			for fn.end < len(a.lines) && leadingSpace(a.lines[fn.end].raw) > indent {
+				fn.end++
+			}
+		} else {
+			loc := jsFunctionDef.FindStringSubmatchIndex(line.masked)
+			if loc == nil {
+				continue
+			}
+			var name string
+			if loc[2] >= 0 {
+				name = line.masked[loc[2]:loc[3]]
+			} else {
+				name = line.masked[loc[4]:loc[5]]
+			}
+			if jsKeywords[name] {
+				continue
+			}
+			fn = &taintFunction{name: name, params: paramNames(line.masked[loc[6]:loc[7]], false), def: i}
+			fn.end = a.blockEnd(i, loc[1]-1)
+		}
+
+		fn.parent = a.scope[i]
+		index := len(a.functions)
+		a.functions = append(a.functions, fn)
+		for j := i + 1; j < fn.end; j++ {
+			a.scope[j] = index
+		}
+	}
+}
+
+// blockEnd returns the index after the line closing the brace at lines[start].masked[offset]
+func (a *taintAnalysis) blockEnd(start, offset int) int {
+	depth := 0
+	for i := start; i < len(a.lines); i++ {
+		masked := a.lines[i].masked
+		if i == start {
+			masked = masked[offset:]
+		}
+		for _, c := range masked {
+			if c == '{' {
+				depth++
+			} else if c == '}' {
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+	}
+	return len(a.lines)
+}
+
+// taint records a variable's taint in a scope; attributes live at module level
+func (a *taintAnalysis) taint(scope int, name string, value *taintedValue) {
+	if name == "" || name == "_" || value == nil {
+		return
+	}
+	if strings.HasPrefix(name, "self.") || strings.HasPrefix(name, "this.") {
+		scope = -1
+	}
+	env := a.env[scope]
+	if env == nil {
+		env = make(map[string]*taintedValue)
+		a.env[scope] = env
+	}
+
+	// Real sources replace parameter placeholders
+	if current := env[name]; current == nil || current.source == taintSourceParam && value.source != taintSourceParam {
+		env[name] = value
+		a.changed = true
+	}
+}
+
+// propagate spreads taint through the assignments, loops, callbacks and returns on a line
+func (a *taintAnalysis) propagate(i int) {
+	line := a.lines[i]
+	scope := a.scope[i]
+	step := TaintStep{Line: line.number, Code: line.code}
+
+	for _, assignment := range a.lang.assignments {
+		match := assignment.FindStringSubmatch(line.masked)
+		if match == nil {
+			continue
+		}
+		value := a.exprTaint(match[2], scope, step)
+		for _, target := range assignmentTargets(match[1]) {
+			a.taint(scope, target, value)
+			if a.lang == pythonTaint && pyOpenCall.MatchString(match[2]) && pyWriteMode.MatchString(line.raw) {
+				a.fileHandles[target] = true
+			}
+		}
+		break
+	}
+
+	if a.lang == pythonTaint {
+		if match := pyWithAs.FindStringSubmatch(line.masked); match != nil {
+			a.taint(scope, match[2], a.exprTaint(match[1], scope, step))
+			if pyOpenCall.MatchString(match[1]) && pyWriteMode.MatchString(line.raw) {
+				a.fileHandles[match[2]] = true
+			}
+		}
+	}
+
+	// Callback parameters of tainted promises and message handlers
+	if a.lang == javascriptTaint {
+		if match := jsCallbackArgs.FindStringSubmatch(line.raw); match != nil {
+			if value := a.exprTaint(line.masked, scope, step); value != nil {
+				a.taint(scope, match[1], value)
+			} else if jsMessageEvent.MatchString(line.raw) {
+				a.taint(scope, match[1], &taintedValue{source: taintSourceNetwork, param: -1, trace: []TaintStep{step}})
+			}
+		}
+	}
+
+	if scope >= 0 {
+		if match := returnStatement.FindStringSubmatch(line.masked); match != nil {
+			fn := a.functions[scope]
+			value := a.exprTaint(match[1], scope, step)
+			if value != nil && (fn.returns == nil || fn.returns.source == taintSourceParam && value.source != taintSourceParam) {
+				fn.returns = value
+				a.changed = true
+			}
+		}
+	}
+}
+
+// checkSinks records tainted data reaching sinks or calls of functions that
+// pass a parameter to a sink
+func (a *taintAnalysis) checkSinks(i int) {
+	line := a.lines[i]
+	scope := a.scope[i]
+	step := TaintStep{Line: line.number, Code: line.code}
+
+	for _, sink := range a.sinks {
+		for _, loc := range sink.pattern.FindAllStringIndex(line.masked, -1) {
+			if sink.kind == taintSinkDeserialize && pySafeYAML.MatchString(line.raw) {
+				continue
+			}
+			call := strings.TrimLeftFunc(line.masked[loc[0]:loc[1]], func(r rune) bool { return r > 127 || !isIdentifierByte(byte(r)) })
+			args := callArguments(line.masked[loc[1]:])
+			a.report(scope, line, sink.kind, call, a.exprTaint(args, scope, step))
+		}
+	}
+
+	if a.lang == pythonTaint {
+		// open(tainted_path, "w") and handle.write(tainted) for handles opened for writing
+		for _, loc := range pyOpenCall.FindAllStringIndex(line.masked, -1) {
+			if pyWriteMode.MatchString(callArguments(line.raw[loc[1]:])) {
+				args := callArguments(line.masked[loc[1]:])
+				a.report(scope, line, taintSinkFileWrite, "open(", a.exprTaint(args, scope, step))
+			}
+		}
+		for _, match := range pyHandleWrite.FindAllStringSubmatchIndex(line.masked, -1) {
+			handle := line.masked[match[2]:match[3]]
+			if a.fileHandles[handle] {
+				args := callArguments(line.masked[match[1]:])
+				a.report(scope, line, taintSinkFileWrite, handle+".write(", a.exprTaint(args, scope, step))
+			}
+		}
+	}
+
+	// Calls into functions whose parameters reach a sink
+	for f, fn := range a.functions {
+		if len(fn.paramSinks) == 0 || f == scope {
+			continue
+		}
+		for _, args := range findCalls(line.masked, fn.name) {
+			for p, calleeFlows := range fn.paramSinks {
+				arg := callArgument(args, p, fn.params[p])
+				value := a.exprTaint(arg, scope, step)
+				if value == nil {
+					continue
+				}
+				for _, calleeFlow := range calleeFlows {
+					trace := appendStep(value.trace, step)
+					a.report(scope, line, calleeFlow.Sink, calleeFlow.Call, &taintedValue{
+						source: value.source,
+						param:  value.param,
+						trace:  append(trace, calleeFlow.Trace...),
+					})
+				}
+			}
+		}
+	}
+}
+
+// report records a flow, or a parameter summary when the data came from a
+// parameter. The value's trace must already end at the sink.
+func (a *taintAnalysis) report(scope int, line logicalLine, sink, call string, value *taintedValue) {
+	if value == nil {
+		return
+	}
+	trace := value.trace
+
+	source := value.source
+	if source == taintSourceParam {
+		source = fmt.Sprintf("%s:%d", source, value.param)
+	}
+	key := fmt.Sprintf("%d/%d/%s/%s/%s", scope, line.number, sink, source, trace[0].Code)
+	if a.seen[key] {
+		return
+	}
+	a.seen[key] = true
+
+	flow := TaintFlow{Source: value.source, Sink: sink, Call: call, Trace: trace}
+	if value.source != taintSourceParam {
+		a.flows = append(a.flows, flow)
+		return
+	}
+
+	fn := a.functions[scope]
+	if fn.paramSinks == nil {
+		fn.paramSinks = make(map[int][]TaintFlow)
+	}
+	fn.paramSinks[value.param] = append(fn.paramSinks[value.param], flow)
+	a.changed = true
+}
+
+// exprTaint returns the taint an expression carries, or nil if it is clean.
+// Real sources win over parameter placeholders.
+func (a *taintAnalysis) exprTaint(expr string, scope int, step TaintStep) *taintedValue {
+	expr = stripSanitized(expr, a.lang.sanitizers)
+
+	for _, source := range a.lang.sources {
+		if source.pattern.MatchString(expr) {
+			return &taintedValue{source: source.kind, param: -1, trace: []TaintStep{step}}
+		}
+	}
+
+	var placeholder *taintedValue
+	consider := func(value *taintedValue) *taintedValue {
+		if value == nil {
+			return nil
+		}
+		value = &taintedValue{source: value.source, param: value.param, trace: appendStep(value.trace, step)}
+		if value.source != taintSourceParam {
+			return value
+		}
+		if placeholder == nil {
+			placeholder = value
+		}
+		return nil
+	}
+
+	// Calls of local functions that return tainted data, or pass a parameter through
+	for f, fn := range a.functions {
+		if fn.returns == nil || f == scope {
+			continue
+		}
+		for _, args := range findCalls(expr, fn.name) {
+			value := fn.returns
+			if value.source == taintSourceParam {
+				value = a.exprTaint(callArgument(args, value.param, fn.params[value.param]), scope, step)
+			}
+			if value := consider(value); value != nil {
+				return value
+			}
+		}
+	}
+
+	// Variables visible from this scope; prefer the longest name so self.result wins over self
+	for s := scope; ; s = a.functions[s].parent {
+		var best string
+		for name := range a.env[s] {
+			longer := len(name) > len(best) || len(name) == len(best) && name < best
+			if longer && containsIdentifier(expr, name) {
+				best = name
+			}
+		}
+		if best != "" {
+			if value := consider(a.env[s][best]); value != nil {
+				return value
+			}
+		}
+		if s < 0 {
+			break
+		}
+	}
+	return placeholder
+}
+
+// appendStep adds step to a copy of trace unless the trace already ends on its line
+func appendStep(trace []TaintStep, step TaintStep) []TaintStep {
+	if len(trace) > 0 && trace[len(trace)-1].Line == step.Line {
+		return trace
+	}
+	return append(append([]TaintStep{}, trace...), step)
+}
+
+// findCalls returns the argument text of every call of a function or method named name
+func findCalls(expr, name string) []string {
+	var calls []string
+	for start := 0; ; {
+		i := strings.Index(expr[start:], name+"(")
+		if i < 0 {
+			return calls
+		}
+		i += start
+		end := i + len(name) + 1
+		if i == 0 || !isIdentifierByte(expr[i-1]) {
+			calls = append(calls, callArguments(expr[end:]))
+		}
+		start = end
+	}
+}
+
+// callArgument returns the argument bound to parameter index p, by keyword or position
+func callArgument(args string, p int, param string) string {
+	var positional []string
+	depth, start := 0, 0
+	for i := 0; i <= len(args); i++ {
+		if i < len(args) {
+			switch args[i] {
+			case '(', '[', '{':
+				depth++
+				continue
+			case ')', ']', '}':
+				depth--
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+
+		arg := strings.TrimSpace(args[start:i])
+		start = i + 1
+		if name, value, ok := strings.Cut(arg, "="); ok && !strings.ContainsAny(name, "([{") && !strings.HasPrefix(value, "=") {
+			if strings.TrimSpace(name) == param {
+				return value
+			}
+			continue
+		}
+		positional = append(positional, arg)
+	}
+
+	if p < len(positional) {
+		return positional[p]
+	}
+	return ""
+}
+
+// paramNames extracts parameter names, dropping Python's self and cls
+func paramNames(params string, python bool) []string {
+	var names []string
+	for _, param := range strings.Split(params, ",") {
+		param, _, _ = strings.Cut(param, "=")
+		if python {
+			param, _, _ = strings.Cut(param, ":")
+		}
+		param = strings.TrimLeft(strings.TrimSpace(param), "*.")
+		if param == "" || python && len(names) == 0 && (param == "self" || param == "cls") {
+			continue
+		}
+		names = append(names, param)
+	}
+	return names
+}
+
+func leadingSpace(s string) int {
+	return len(s) - len(strings.TrimLeft(s, " \t"))
+}
+
+// containsIdentifier reports whether name appears in expr as a whole identifier
+func containsIdentifier(expr, name string) bool {
+	for start := 0; ; {
+		i := strings.Index(expr[start:], name)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(name)
+		if (i == 0 || !isIdentifierByte(expr[i-1]) && expr[i-1] != '.') && (end == len(expr) || !isIdentifierByte(expr[end])) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// stripSanitized removes the arguments of sanitizer calls from an expression
+func stripSanitized(expr string, sanitizers *regexp.Regexp) string {
+	for {
+		loc := sanitizers.FindStringIndex(expr)
+		if loc == nil {
+			return expr
+		}
+		args := callArguments(expr[loc[1]:])
+		expr = expr[:loc[0]] + expr[loc[1]+len(args):]
+	}
+}
+
+// callArguments returns the text up to the parenthesis closing a call
+func callArguments(s string) string {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth == 0 {
+				return s[:i]
+			}
+			depth--
+		}
+	}
+	return s
+}
+
+// assignmentTargets splits "a, b" or "{ a, b: c = 1 }" contents into variable names
+func assignmentTargets(targets string) []string {
+	var names []string
+	for _, target := range strings.Split(targets, ",") {
+		if _, alias, ok := strings.Cut(target, ":"); ok {
+			target = alias
+		}
+		target, _, _ = strings.Cut(target, "=")
+		target = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(target), "..."))
+		if target != "" {
+			names = append(names, target)
+		}
+	}
+	return names
+}
+
+// splitLogicalLines masks comments and strings, then joins statements whose
+// brackets span several lines
+func splitLogicalLines(text string, lang *taintLanguage) []logicalLine {
+	original := strings.Split(text, "\n")
+	masked := strings.Split(maskScript(text, lang == pythonTaint), "\n")
+
+	var lines []logicalLine
+	var current *logicalLine
+	depth := 0
+	for i := range original {
+		if current == nil {
+			if strings.TrimSpace(masked[i]) == "" {
+				continue
+			}
+			current = &logicalLine{number: i + 1, raw: original[i], masked: masked[i]}
+		} else {
+			current.raw += " " + original[i]
+			current.masked += " " + masked[i]
+		}
+
+		// Braces delimit blocks in JavaScript, so only Python statements continue across them
+		for _, c := range masked[i] {
+			switch {
+			case c == '(' || c == '[' || c == '{' && lang == pythonTaint:
+				depth++
+			case c == ')' || c == ']' || c == '}' && lang == pythonTaint:
+				depth--
+			}
+		}
+		if depth <= 0 || i == len(original)-1 {
+			current.code = strings.Join(strings.Fields(current.raw), " ")
+			if len(current.code) > 160 {
+				current.code = current.code[:157] + "..."
+			}
+			lines = append(lines, *current)
+			current = nil
+			depth = 0
+		}
+	}
+	return lines
+}
+
+// maskScript blanks out comments and string contents so patterns only match
+// code, keeping f-string and template literal interpolations visible
+func maskScript(text string, python bool) string {
+	out := []byte(text)
+	blank := func(i int) {
+		if out[i] != '\n' {
+			out[i] = ' '
+		}
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case python && c == '#', !python && c == '/' && strings.HasPrefix(text[i:], "//"):
+			for ; i < len(text) && text[i] != '\n'; i++ {
+				blank(i)
+			}
+		case !python && strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			stop := len(text)
+			if end >= 0 {
+				stop = i + 2 + end + 2
+			}
+			for ; i < stop; i++ {
+				blank(i)
+			}
+		case !python && c == '/' && isRegexStart(text[:i]):
+			i++
+			inClass := false
+			for ; i < len(text) && text[i] != '\n'; i++ {
+				if text[i] == '\\' {
+					blank(i)
+					i++
+					if i < len(text) {
+						blank(i)
+					}
+					continue
+				}
+				if text[i] == '[' {
+					inClass = true
+				} else if text[i] == ']' {
+					inClass = false
+				} else if text[i] == '/' && !inClass {
+					break
+				}
+				blank(i)
+			}
+			i++
+		case c == '"' || c == '\'' || !python && c == '`':
+			i = maskString(text, out, i, python, blank)
+		default:
+			i++
+		}
+	}
+	return string(out)
+}
+
+// maskString blanks a string literal starting at text[i] and returns the index after it
+func maskString(text string, out []byte, i int, python bool, blank func(int)) int {
+	quote := text[i : i+1]
+	interpolated := quote == "`"
+	if python {
+		// String prefixes such as f, rb or Rf sit directly before the quote
+		j := i
+		for j > 0 && strings.ContainsRune("rRbBuUfF", rune(text[j-1])) {
+			j--
+		}
+		if j == 0 || !isIdentifierByte(text[j-1]) {
+			interpolated = strings.ContainsAny(text[j:i], "fF")
+		}
+		if strings.HasPrefix(text[i:], strings.Repeat(quote, 3)) {
+			quote = strings.Repeat(quote, 3)
+		}
+	}
+	multiline := quote == "`" || len(quote) == 3
+
+	i += len(quote)
+	for i < len(text) {
+		switch {
+		case strings.HasPrefix(text[i:], quote):
+			return i + len(quote)
+		case text[i] == '\n' && !multiline:
+			return i
+		case text[i] == '\\':
+			blank(i)
+			if i+1 < len(text) {
+				blank(i + 1)
+			}
+			i += 2
+		case interpolated && (python && text[i] == '{' && !strings.HasPrefix(text[i:], "{{") || !python && strings.HasPrefix(text[i:], "${")):
+			// Leave the interpolated expression visible
+			depth := 0
+			for ; i < len(text); i++ {
+				if text[i] == '{' {
+					depth++
+				} else if text[i] == '}' {
+					depth--
+					if depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+		case interpolated && python && (strings.HasPrefix(text[i:], "{{") || strings.HasPrefix(text[i:], "}}")):
+			blank(i)
+			blank(i + 1)
+			i += 2
+		default:
+			blank(i)
+			i++
+		}
+	}
+	return i
+}
+
+// isRegexStart reports whether a "/" after prefix begins a JavaScript regex literal
+func isRegexStart(prefix string) bool {
+	prefix = strings.TrimRight(prefix, " \t")
+	if prefix == "" {
+		return true
+	}
+	last := prefix[len(prefix)-1]
+	return strings.IndexByte("(,=:[!&|?{};\n", last) >= 0 || strings.HasSuffix(prefix, "return")
+}
@@ -0,0 +1,126 @@
+package aegong
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+const taintedPythonAgent = `import subprocess
+import requests
+from openai import OpenAI
+
+client = OpenAI()
+
+def act(task):
+    """Never eval(task) directly."""
+    reply = client.chat.completions.create(
+        model="gpt-4o",
+        messages=[{"role": "user", "content": task}],
+    )
+    code = reply.choices[0].message.content
+    eval(code)  # eval(safe) in a comment is ignored
+
+    page = requests.get("https://example.com/cmd").text
+    command = f"echo {page}"
+    subprocess.run(command, shell=True)
+
+    count = int(page)
+    subprocess.run(["sleep", str(count)])
+    print("eval(" + task + ")")
+    with open("/tmp/out.txt", "w") as f:
+        f.write(page)
+`
+
+const taintedJavaScriptAgent = `const { exec } = require("child_process");
+const fs = require("fs");
+
+async function act(url) {
+  const res = await fetch(url);
+  const body = await res.json();
+  const { script, path: target } = body;
+  exec("sh -c " + script);
+  fs.writeFileSync(target, "owned");
+  const m = /(\d+)/.exec(body.text);
+  exec("ls " + encodeURIComponent(body.dir));
+}
+`
+
+// TestTaintAnalysisPython tests source to sink flows in a Python agent
+func TestTaintAnalysisPython(t *testing.T) {
+	flows := findTaintFlows(taintedPythonAgent, detectScriptLanguage([]byte(taintedPythonAgent)))
+
+	want := map[string]int{
+		"llm->code_execution":        14,
+		"network->command_execution": 18,
+		"network->file_write":        24,
+	}
+	if len(flows) != len(want) {
+		t.Fatalf("Should find %d flows, got %+v", len(want), flows)
+	}
+	for _, flow := range flows {
+		line, ok := want[flow.Source+"->"+flow.Sink]
+		if !ok || flow.Trace[len(flow.Trace)-1].Line != line {
+			t.Fatalf("Unexpected flow %s", flow)
+		}
+	}
+
+	// The LLM trace runs from the completion call through the assignment to eval
+	llm := flows[0]
+	if len(llm.Trace) != 3 || llm.Trace[0].Line != 9 || llm.Trace[1].Line != 13 {
+		t.Fatalf("LLM flow should trace lines 9 -> 13 -> 14, got %s", llm)
+	}
+
+	threats := analyzeTaint([]byte(taintedPythonAgent))
+	if len(threats) != 2 || threats[0].Vector != T1_REASONING_HIJACK || threats[1].Vector != T4_UNAUTHORIZED_ACTION {
+		t.Fatalf("Should raise a T1 and a T4 finding, got %+v", threats)
+	}
+	if threats[0].Severity != CRITICAL || !strings.Contains(threats[0].Evidence[0], "line 9") {
+		t.Fatalf("T1 finding should be critical with a trace, got %+v", threats[0])
+	}
+}
+
+// TestTaintAnalysisJavaScript tests destructuring, child_process and sanitizers
+func TestTaintAnalysisJavaScript(t *testing.T) {
+	flows := findTaintFlows(taintedJavaScriptAgent, detectScriptLanguage([]byte(taintedJavaScriptAgent)))
+
+	if len(flows) != 2 {
+		t.Fatalf("Should find 2 flows, got %+v", flows)
+	}
+	if flows[0].Sink != taintSinkCommand || flows[0].Trace[len(flows[0].Trace)-1].Line != 8 {
+		t.Fatalf("First flow should reach exec on line 8, got %s", flows[0])
+	}
+	if flows[1].Sink != taintSinkFileWrite || flows[1].Source != taintSourceNetwork {
+		t.Fatalf("Second flow should reach writeFileSync, got %s", flows[1])
+	}
+}
+
+// TestTaintAnalysisIgnoresBinaries tests that non-script input is skipped
+func TestTaintAnalysisIgnoresBinaries(t *testing.T) {
+	if threats := analyzeTaint([]byte("\x7fELF\x00\x00eval(input())")); threats != nil {
+		t.Fatalf("Binaries should not be taint analysed, got %+v", threats)
+	}
+}
+
+// TestTaintAnalysisFollowsCalls tests flows through local function returns and parameters
+func TestTaintAnalysisFollowsCalls(t *testing.T) {
+	data, err := os.ReadFile("testdata/classifier/agent/shell_agent.py")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flows := findTaintFlows(string(data), pythonTaint)
+	if len(flows) != 1 {
+		t.Fatalf("Should find 1 flow, got %+v", flows)
+	}
+
+	// decide() returns the LLM reply, which execute() passes to subprocess.run
+	var lines []int
+	for _, step := range flows[0].Trace {
+		lines = append(lines, step.Line)
+	}
+	if flows[0].Source != taintSourceLLM || fmt.Sprint(lines) != "[9 10 18 19 12 13]" {
+		t.Fatalf("Flow should trace the LLM reply through decide and execute, got %s", flows[0])
+	}
+}
@@ -0,0 +1,17 @@
+import Anthropic from "@anthropic-ai/sdk";
+import readline from "readline";
+
+const anthropic = new Anthropic();
+const history = [];
+const rl = readline.createInterface({ input: process.stdin, output: process.stdout });
+
+async function respond(text) {
+  history.push({ role: "user", content: text });
+  const msg = await anthropic.messages.create({ model: "claude-3-haiku", max_tokens: 512, system: prompt, messages: history });
+  history.push({ role: "assistant", content: msg.content[0].text });
+  return msg.content[0].text;
+}
+
+rl.on("line", async (line) => {
+  console.log(await respond(line));
+});
@@ -0,0 +1,10 @@
+from crewai import Agent, Task, Crew
+
+researcher = Agent(role="Researcher", goal="Find facts", backstory="curious", tools=[search_tool], llm="gpt-4o")
+writer = Agent(role="Writer", goal="Write summaries", backstory="concise", memory=True)
+
+research = Task(description="Research the topic", agent=researcher)
+summary = Task(description="Summarise the research", agent=writer, context=[research])
+
+crew = Crew(agents=[researcher, writer], tasks=[research, summary])
+print(crew.kickoff())
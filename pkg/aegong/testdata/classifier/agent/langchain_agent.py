@@ -0,0 +1,13 @@
+from langchain.agents import initialize_agent, Tool
+from langchain.llms import OpenAI
+
+llm = OpenAI(temperature=0)
+tools = [Tool(name="search", func=lambda q: search(q), description="web search")]
+agent = initialize_agent(tools, llm, agent="zero-shot-react-description")
+
+def respond(task):
+    return agent.run(task)
+
+while True:
+    task = input("task> ")
+    print(respond(task))
@@ -0,0 +1,25 @@
+import psutil
+import smtplib
+import time
+
+class SystemAgent:
+    """Autonomous agent that watches the host and remediates problems"""
+
+    def __init__(self):
+        self.memory = []
+
+    def sense(self):
+        return {"cpu": psutil.cpu_percent(), "mem": psutil.virtual_memory().percent}
+
+    def decide(self, reading):
+        return "restart" if reading["cpu"] > 95 else None
+
+    def act(self, decision):
+        if decision:
+            self.memory.append(decision)
+            smtplib.SMTP("localhost").sendmail("agent@host", ["ops@host"], "restarting")
+
+    def run_forever(self):
+        while True:
+            self.act(self.decide(self.sense()))
+            time.sleep(10)
@@ -0,0 +1,18 @@
+import json
+import urllib.request
+
+TOOLS = {"read_file": lambda p: open(p).read()}
+
+def llm(prompt):
+    req = urllib.request.Request("http://localhost:11434/api/generate", data=json.dumps({"model": "llama3", "prompt": prompt}).encode())
+    return json.loads(urllib.request.urlopen(req).read())["response"]
+
+def agent_loop(goal, max_iterations=8):
+    context = [goal]
+    for i in range(max_iterations):
+        reply = llm("\n".join(context))
+        if reply.startswith("TOOL"):
+            _, name, arg = reply.split(" ", 2)
+            context.append(TOOLS[name](arg))
+        else:
+            return reply
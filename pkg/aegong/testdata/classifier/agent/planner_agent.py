@@ -0,0 +1,21 @@
+from transformers import pipeline
+
+generator = pipeline("text-generation", model="gpt2")
+
+class Planner:
+    def plan(self, goal):
+        prompt = f"Break the goal into tasks: {goal}"
+        return generator(prompt, max_length=200)[0]["generated_text"].split("\n")
+
+class Agent:
+    def __init__(self):
+        self.planner = Planner()
+        self.memory = {}
+
+    def perform(self, task):
+        self.memory[task] = "done"
+        return task
+
+    def pursue(self, goal):
+        for task in self.planner.plan(goal):
+            self.perform(task)
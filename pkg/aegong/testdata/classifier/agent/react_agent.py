@@ -0,0 +1,24 @@
+import openai
+
+class ReActAgent:
+    def __init__(self, tools):
+        self.tools = tools
+        self.memory = []
+
+    def think(self, observation):
+        prompt = "\n".join(self.memory + [observation])
+        resp = openai.chat.completions.create(model="gpt-4o", messages=[{"role": "user", "content": prompt}])
+        return resp.choices[0].message.content
+
+    def act(self, thought):
+        name, arg = thought.split(":", 1)
+        return self.tools[name](arg)
+
+    def run(self, goal, max_steps=10):
+        observation = goal
+        for step in range(max_steps):
+            thought = self.think(observation)
+            self.memory.append(thought)
+            if thought.startswith("FINISH"):
+                return thought
+            observation = self.act(thought)
@@ -0,0 +1,26 @@
+import numpy as np
+import gym
+
+class QAgent:
+    def __init__(self, env):
+        self.env = env
+        self.q = np.zeros((env.observation_space.n, env.action_space.n))
+        self.memory = []
+
+    def choose(self, state, epsilon):
+        if np.random.rand() < epsilon:
+            return self.env.action_space.sample()
+        return int(np.argmax(self.q[state]))
+
+    def remember(self, transition):
+        self.memory.append(transition)
+
+    def train(self, episodes=500):
+        for episode in range(episodes):
+            observation = self.env.reset()
+            done = False
+            while not done:
+                action = self.choose(observation, 0.1)
+                next_obs, reward, done, _ = self.env.step(action)
+                self.remember((observation, action, reward, next_obs))
+                observation = next_obs
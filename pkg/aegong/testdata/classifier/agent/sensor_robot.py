@@ -0,0 +1,22 @@
+import serial
+import torch
+
+model = torch.load("policy.pt")
+
+class Robot:
+    def __init__(self):
+        self.port = serial.Serial("/dev/ttyUSB0")
+        self.state = None
+
+    def perceive(self):
+        return torch.tensor(list(self.port.read(16)), dtype=torch.float32)
+
+    def policy(self, observation):
+        return model(observation).argmax().item()
+
+    def act(self, action):
+        self.port.write(bytes([action]))
+
+    def loop(self):
+        while True:
+            self.act(self.policy(self.perceive()))
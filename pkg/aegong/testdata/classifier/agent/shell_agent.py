@@ -0,0 +1,20 @@
+import subprocess
+import anthropic
+
+client = anthropic.Anthropic()
+history = []
+
+def decide(goal):
+    history.append({"role": "user", "content": goal})
+    msg = client.messages.create(model="claude-3-5-sonnet", max_tokens=1024, messages=history)
+    return msg.content[0].text
+
+def execute(command):
+    return subprocess.run(command, shell=True, capture_output=True).stdout
+
+def main():
+    goal = input("goal: ")
+    for _ in range(20):
+        command = decide(goal)
+        output = execute(command)
+        history.append({"role": "assistant", "content": output.decode()})
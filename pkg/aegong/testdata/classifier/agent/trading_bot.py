@@ -0,0 +1,27 @@
+import requests
+import time
+
+class TradingAgent:
+    def __init__(self):
+        self.state = {"position": 0}
+        self.history = []
+
+    def observe(self):
+        return requests.get("https://api.exchange.example/ticker").json()
+
+    def decide(self, ticker):
+        if ticker["price"] < ticker["avg"] * 0.98:
+            return "buy"
+        if ticker["price"] > ticker["avg"] * 1.02:
+            return "sell"
+        return "hold"
+
+    def execute(self, decision):
+        requests.post("https://api.exchange.example/order", json={"side": decision})
+        self.history.append(decision)
+
+    def run(self):
+        while True:
+            ticker = self.observe()
+            self.execute(self.decide(ticker))
+            time.sleep(60)
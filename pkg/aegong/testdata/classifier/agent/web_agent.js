@@ -0,0 +1,26 @@
+const OpenAI = require("openai");
+const axios = require("axios");
+
+const client = new OpenAI();
+const memory = [];
+
+async function think(observation) {
+  memory.push({ role: "user", content: observation });
+  const res = await client.chat.completions.create({ model: "gpt-4o-mini", messages: memory, tools: toolSpecs });
+  return res.choices[0].message;
+}
+
+async function act(message) {
+  if (message.tool_calls) {
+    const call = message.tool_calls[0];
+    const page = await axios.get(JSON.parse(call.function.arguments).url);
+    return page.data;
+  }
+  return message.content;
+}
+
+setInterval(async () => {
+  const obs = await fetch("http://localhost:8080/task").then(r => r.text());
+  const msg = await think(obs);
+  await act(msg);
+}, 5000);
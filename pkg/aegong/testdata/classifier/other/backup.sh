@@ -0,0 +1,7 @@
+#!/bin/sh
+set -e
+DEST=/backups/$(date +%F)
+mkdir -p "$DEST"
+tar czf "$DEST/home.tgz" /home
+find /backups -mtime +30 -delete
+echo "backup complete"
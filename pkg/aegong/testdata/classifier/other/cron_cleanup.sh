@@ -0,0 +1,5 @@
+#!/bin/sh
+# Remove temporary files older than a day
+while read -r dir; do
+  find "$dir" -type f -mtime +1 -print -delete
+done < /etc/cleanup_dirs
@@ -0,0 +1,13 @@
+import csv
+import sys
+
+def main(path):
+    totals = {}
+    with open(path) as f:
+        for row in csv.DictReader(f):
+            totals[row["region"]] = totals.get(row["region"], 0) + float(row["amount"])
+    for region, total in sorted(totals.items()):
+        print(f"{region}: {total:.2f}")
+
+if __name__ == "__main__":
+    main(sys.argv[1])
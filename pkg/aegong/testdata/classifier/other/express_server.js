@@ -0,0 +1,14 @@
+const express = require("express");
+const app = express();
+
+app.use(express.json());
+
+app.get("/users/:id", (req, res) => {
+  res.json({ id: req.params.id, name: "user" });
+});
+
+app.post("/users", (req, res) => {
+  res.status(201).json(req.body);
+});
+
+app.listen(3000, () => console.log("listening on 3000"));
@@ -0,0 +1,15 @@
+from flask import Flask, jsonify
+
+app = Flask(__name__)
+items = []
+
+@app.route("/items")
+def list_items():
+    return jsonify(items)
+
+@app.route("/health")
+def health():
+    return "ok"
+
+if __name__ == "__main__":
+    app.run(port=8080)
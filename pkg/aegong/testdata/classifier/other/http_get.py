@@ -0,0 +1,8 @@
+import requests
+
+def fetch_status(urls):
+    for url in urls:
+        response = requests.get(url, timeout=5)
+        print(url, response.status_code)
+
+fetch_status(["https://example.com", "https://example.org"])
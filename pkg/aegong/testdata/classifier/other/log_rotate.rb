@@ -0,0 +1,7 @@
+require "fileutils"
+
+Dir.glob("/var/log/app/*.log").each do |path|
+  next if File.size(path) < 10_000_000
+  FileUtils.mv(path, "#{path}.#{Time.now.to_i}")
+  FileUtils.touch(path)
+end
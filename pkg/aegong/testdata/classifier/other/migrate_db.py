@@ -0,0 +1,9 @@
+import sqlite3
+
+conn = sqlite3.connect("app.db")
+cur = conn.cursor()
+cur.execute("CREATE TABLE IF NOT EXISTS users (id INTEGER PRIMARY KEY, email TEXT)")
+cur.execute("ALTER TABLE users ADD COLUMN created_at TEXT")
+conn.commit()
+conn.close()
+print("migration applied")
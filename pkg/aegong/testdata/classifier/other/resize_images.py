@@ -0,0 +1,12 @@
+from PIL import Image
+import os
+
+SRC = "photos"
+DST = "thumbs"
+
+os.makedirs(DST, exist_ok=True)
+for name in os.listdir(SRC):
+    if name.lower().endswith((".jpg", ".png")):
+        img = Image.open(os.path.join(SRC, name))
+        img.thumbnail((256, 256))
+        img.save(os.path.join(DST, name))
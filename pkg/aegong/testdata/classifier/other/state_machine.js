@@ -0,0 +1,12 @@
+const transitions = { idle: ["running"], running: ["paused", "stopped"], paused: ["running"] };
+
+class Machine {
+  constructor() { this.state = "idle"; }
+  send(next) {
+    if (!transitions[this.state].includes(next)) throw new Error("bad transition");
+    this.state = next;
+    return this.state;
+  }
+}
+
+module.exports = Machine;
@@ -0,0 +1,15 @@
+import torch
+import torch.nn as nn
+from torchvision import datasets, transforms
+
+model = nn.Sequential(nn.Flatten(), nn.Linear(784, 128), nn.ReLU(), nn.Linear(128, 10))
+loader = torch.utils.data.DataLoader(datasets.MNIST(".", download=True, transform=transforms.ToTensor()), batch_size=64)
+optimizer = torch.optim.Adam(model.parameters())
+
+for epoch in range(3):
+    for images, labels in loader:
+        loss = nn.functional.cross_entropy(model(images), labels)
+        optimizer.zero_grad()
+        loss.backward()
+        optimizer.step()
+torch.save(model, "mnist.pt")
@@ -0,0 +1,59 @@
+package aegong
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"Agent_Auditor/pkg/telemetry"
+)
+
+// TestAuditSpans tests that an audit records spans for its phases, detectors and sandbox
+func TestAuditSpans(t *testing.T) {
+	var mutex sync.Mutex
+	names := map[string]bool{}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []struct {
+						Name string `json:"name"`
+					} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, resource := range request.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				for _, span := range scope.Spans {
+					names[span.Name] = true
+				}
+			}
+		}
+	}))
+	defer collector.Close()
+
+	tracer := telemetry.NewTracer(telemetry.Config{Endpoint: collector.URL})
+	telemetry.SetTracer(tracer)
+	defer telemetry.SetTracer(nil)
+
+	engine := newTestEngine(t)
+	if _, err := engine.Audit(context.Background(), strings.NewReader("#!/bin/sh\necho 'Hello, World!'\n")); err != nil {
+		t.Fatalf("Failed to audit agent: %v", err)
+	}
+	tracer.Shutdown(context.Background())
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, name := range []string{"aegong.audit", "aegong.phase static", "aegong.phase dynamic", "aegong.phase shield", "aegong.sandbox", "aegong.detector T1"} {
+		if !names[name] {
+			t.Fatalf("Should record a %q span, got %v", name, names)
+		}
+	}
+}
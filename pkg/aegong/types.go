@@ -0,0 +1,260 @@
+package aegong
+
+import (
+	"fmt"
+	"time"
+)
+
+// Core data structures
+type ThreatVector int
+
+const (
+	T1_REASONING_HIJACK ThreatVector = iota
+	T2_OBJECTIVE_CORRUPTION
+	T3_MEMORY_POISONING
+	T4_UNAUTHORIZED_ACTION
+	T5_RESOURCE_MANIPULATION
+	T6_IDENTITY_SPOOFING
+	T7_TRUST_MANIPULATION
+	T8_OVERSIGHT_SATURATION
+	T9_GOVERNANCE_EVASION
+)
+
+type ThreatSeverity int
+
+const (
+	LOW ThreatSeverity = iota
+	MEDIUM
+	HIGH
+	CRITICAL
+)
+
+type ThreatDetection struct {
+	Vector       ThreatVector           `json:"vector"`
+	VectorName   string                 `json:"vector_name"`
+	Severity     ThreatSeverity         `json:"severity"`
+	SeverityName string                 `json:"severity_name"`
+	Confidence   float64                `json:"confidence"`
+	Evidence     []string               `json:"evidence"`
+	Timestamp    time.Time              `json:"timestamp"`
+	Details      map[string]interface{} `json:"details"`
+	Location     *Location              `json:"location,omitempty"` // Where in the agent the earliest evidence was found
+}
+
+// Location is where in an agent a finding was made. Agents bundled from
+// several files, such as PyPI and npm packages, name the file.
+type Location struct {
+	File   string `json:"file,omitempty"` // Path within the archive or bundle
+	Offset int    `json:"offset"`         // Byte offset within the file
+	Line   int    `json:"line,omitempty"` // For scripts and other text
+}
+
+// String renders a location as "path:line", or with the offset for binaries
+func (l *Location) String() string {
+	position := fmt.Sprintf("offset %d", l.Offset)
+	if l.Line > 0 {
+		position = fmt.Sprintf("line %d", l.Line)
+	}
+	if l.File == "" {
+		return position
+	}
+	if l.Line > 0 {
+		return fmt.Sprintf("%s:%d", l.File, l.Line)
+	}
+	return fmt.Sprintf("%s at %s", l.File, position)
+}
+
+type AuditReport struct {
+	AgentHash          string                 `json:"agent_hash"`
+	AgentName          string                 `json:"agent_name"`
+	Timestamp          time.Time              `json:"timestamp"`
+	Threats            []ThreatDetection      `json:"threats"`
+	ShieldResults      map[string]interface{} `json:"shield_results"`
+	OverallRisk        float64                `json:"overall_risk"`
+	RiskLevel          string                 `json:"risk_level"`
+	RiskBreakdown      *RiskBreakdown         `json:"risk_breakdown,omitempty"`
+	Recommendations    []Recommendation       `json:"recommendations"`
+	AegongMessage      string                 `json:"aegong_message"`
+	Narration          string                 `json:"narration,omitempty"` // Profile the message is written in
+	ExecutiveSummary   *ExecutiveSummary      `json:"executive_summary,omitempty"`
+	Validation         *AgentValidationResult `json:"validation,omitempty"`
+	ValidationOverride *ValidationOverride    `json:"validation_override,omitempty"`
+	Source             *ArtifactSource        `json:"source,omitempty"`
+	Signature          *SignatureInfo         `json:"signature,omitempty"`
+	Manifest           *ManifestCheck         `json:"manifest,omitempty"`
+	Policy             *PolicyCheck           `json:"policy,omitempty"`
+	Protocols          *ProtocolAnalysis      `json:"protocols,omitempty"`
+	Soak               *SoakResult            `json:"soak,omitempty"`
+	Clock              *ClockManipulation     `json:"clock,omitempty"`
+	Runtime            *ScriptRuntime         `json:"runtime,omitempty"`
+	Cache              *CacheInfo             `json:"cache,omitempty"`
+	Partial            *PartialAudit          `json:"partial,omitempty"` // Progress of an audit that has not finished
+	Coverage           *Coverage              `json:"coverage,omitempty"`
+	DetectorErrors     []DetectorError        `json:"detector_errors,omitempty"` // Detectors that panicked
+	Engine             *EngineVersion         `json:"engine,omitempty"`
+	Ruleset            *Ruleset               `json:"ruleset,omitempty"`        // Detector and SHIELD configuration of the audit
+	PatternFeed        *PatternFeedInfo       `json:"pattern_feed,omitempty"`   // Signed pattern feed the detectors ran with
+	DurationMS         float64                `json:"duration_ms,omitempty"`    // Wall time of the audit
+	CorrelationID      string                 `json:"correlation_id,omitempty"` // Request that started the audit
+	Details            map[string]interface{} `json:"details,omitempty"`
+}
+
+// ArtifactSource records where an audited agent was downloaded from
+type ArtifactSource struct {
+	URL             string `json:"url"`              // URL or reference the audit was requested for
+	Kind            string `json:"kind"`             // huggingface, pypi, npm or oci
+	DownloadURL     string `json:"download_url"`     // Artifact that was actually fetched
+	Digest          string `json:"digest,omitempty"` // Published checksum of the artifact
+	DigestAlgorithm string `json:"digest_algorithm,omitempty"`
+	Verified        bool   `json:"verified"`                  // Whether the download matched the published checksum
+	Image           string `json:"image,omitempty"`           // Registry and repository of an OCI image
+	ManifestDigest  string `json:"manifest_digest,omitempty"` // Hex SHA-256 of the image manifest, checked if pinned
+}
+
+// ExecutiveSummary is an overview of a report and its most urgent actions,
+// written by an LLM from the structured findings
+type ExecutiveSummary struct {
+	Summary       string          `json:"summary"`
+	Actions       []SummaryAction `json:"actions"`                 // Most urgent first
+	Provider      string          `json:"provider"`                // Host of the LLM API
+	Model         string          `json:"model"`                   // Model that was asked for
+	ModelVersion  string          `json:"model_version,omitempty"` // Model and build the API reported
+	PromptVersion string          `json:"prompt_version"`
+	GeneratedAt   time.Time       `json:"generated_at"`
+}
+
+// SummaryAction is one step of an executive summary's action list
+type SummaryAction struct {
+	Priority int      `json:"priority"` // 1 is the most urgent
+	Action   string   `json:"action"`
+	Vectors  []string `json:"vectors,omitempty"` // Threat vectors it addresses
+}
+
+// ValidationOverride records who forced an audit of an agent that failed validation
+type ValidationOverride struct {
+	Actor         string    `json:"actor"`
+	Role          string    `json:"role"`
+	Confidence    float64   `json:"validator_confidence"`
+	Reasons       []string  `json:"validator_reasons"`
+	Timestamp     time.Time `json:"timestamp"`
+	CorrelationID string    `json:"correlation_id,omitempty"` // Request that forced the audit
+}
+
+// CertificateAccess records a request authenticated with a client certificate
+type CertificateAccess struct {
+	Actor         string    `json:"actor"`
+	Role          string    `json:"role"`
+	Subject       string    `json:"subject"`
+	Fingerprint   string    `json:"fingerprint"` // SHA-256 of the certificate
+	Method        string    `json:"method"`
+	Path          string    `json:"path"`
+	Timestamp     time.Time `json:"timestamp"`
+	CorrelationID string    `json:"correlation_id,omitempty"` // Request the certificate was presented with
+}
+
+// Kinds of record a legal hold can cover
+const (
+	HoldReport   = "report"
+	HoldUpload   = "upload"
+	HoldAuditLog = "audit_log"
+)
+
+// LegalHold exempts a report, an upload or a range of the audit log from
+// retention cleanup and deletion
+type LegalHold struct {
+	ID       string     `json:"id"`
+	Kind     string     `json:"kind"`             // report, upload or audit_log
+	Target   string     `json:"target,omitempty"` // Report hash or upload name
+	From     *time.Time `json:"from,omitempty"`   // First audit log time held
+	To       *time.Time `json:"to,omitempty"`     // Last audit log time held; absent holds everything after From
+	Reason   string     `json:"reason"`
+	PlacedBy string     `json:"placed_by"`
+	PlacedAt time.Time  `json:"placed_at"`
+}
+
+// LegalHoldChange records a legal hold being placed or released
+type LegalHoldChange struct {
+	Action        string    `json:"action"` // placed or released
+	Hold          LegalHold `json:"hold"`
+	Actor         string    `json:"actor"`
+	Role          string    `json:"role"`
+	Timestamp     time.Time `json:"timestamp"`
+	CorrelationID string    `json:"correlation_id,omitempty"` // Request that made the change
+}
+
+// RecordDeletion records a report or upload deleted through the API
+type RecordDeletion struct {
+	Kind          string    `json:"kind"`   // report or upload
+	Target        string    `json:"target"` // Report hash or upload name
+	Actor         string    `json:"actor"`
+	Role          string    `json:"role"`
+	Timestamp     time.Time `json:"timestamp"`
+	CorrelationID string    `json:"correlation_id,omitempty"` // Request that deleted it
+}
+
+// ReportArchival records a report being archived or restored to the listing
+type ReportArchival struct {
+	Action        string    `json:"action"` // archived or restored
+	ReportHash    string    `json:"report_hash"`
+	Actor         string    `json:"actor"`
+	Role          string    `json:"role"`
+	Timestamp     time.Time `json:"timestamp"`
+	CorrelationID string    `json:"correlation_id,omitempty"` // Request that made the change
+}
+
+// ReportShare records a share link created for a report
+type ReportShare struct {
+	ID            string    `json:"id"`
+	ReportHash    string    `json:"report_hash"`
+	Profile       string    `json:"profile,omitempty"` // Redaction profile; empty shares the full report
+	ExpiresAt     time.Time `json:"expires_at"`
+	Actor         string    `json:"actor"`
+	Role          string    `json:"role"`
+	Timestamp     time.Time `json:"timestamp"`
+	CorrelationID string    `json:"correlation_id,omitempty"` // Request that created the link
+}
+
+// ThreatName returns the human readable name of a threat vector
+func ThreatName(vector ThreatVector) string {
+	names := map[ThreatVector]string{
+		T1_REASONING_HIJACK:      "Reasoning Path Hijacking",
+		T2_OBJECTIVE_CORRUPTION:  "Objective Function Corruption",
+		T3_MEMORY_POISONING:      "Memory Poisoning",
+		T4_UNAUTHORIZED_ACTION:   "Unauthorized Action",
+		T5_RESOURCE_MANIPULATION: "Resource Manipulation",
+		T6_IDENTITY_SPOOFING:     "Identity Spoofing",
+		T7_TRUST_MANIPULATION:    "Trust Manipulation",
+		T8_OVERSIGHT_SATURATION:  "Oversight Saturation",
+		T9_GOVERNANCE_EVASION:    "Governance Evasion",
+	}
+	if name, ok := names[vector]; ok {
+		return name
+	}
+	definition, _ := CustomVector(vector)
+	return definition.Name
+}
+
+// SeverityName returns the name of a severity level
+func SeverityName(severity ThreatSeverity) string {
+	names := map[ThreatSeverity]string{
+		LOW:      "LOW",
+		MEDIUM:   "MEDIUM",
+		HIGH:     "HIGH",
+		CRITICAL: "CRITICAL",
+	}
+	return names[severity]
+}
+
+// RiskLevel maps an overall risk score to its named level
+func RiskLevel(risk float64) string {
+	if risk < 0.2 {
+		return "MINIMAL"
+	} else if risk < 0.4 {
+		return "LOW"
+	} else if risk < 0.6 {
+		return "MEDIUM"
+	} else if risk < 0.8 {
+		return "HIGH"
+	}
+	return "CRITICAL"
+}
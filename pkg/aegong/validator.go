@@ -1,4 +1,4 @@
-package main
+package aegong
 
 import (
 	"bytes"
@@ -15,13 +15,36 @@ import (
 
 // AgentValidationResult represents the result of agent validation
 type AgentValidationResult struct {
-	IsAgent      bool     `json:"is_agent"`
-	Confidence   float64  `json:"confidence"`
-	Reasons      []string `json:"reasons"`
-	AgentType    string   `json:"agent_type"`
-	Capabilities []string `json:"capabilities"`
+	IsAgent      bool                 `json:"is_agent"`
+	Confidence   float64              `json:"confidence"`
+	Reasons      []string             `json:"reasons"`
+	AgentType    string               `json:"agent_type"`
+	Capabilities []string             `json:"capabilities"`
+	Evidence     []CapabilityEvidence `json:"evidence"`
+	Scoring      *ConfidenceScoring   `json:"scoring,omitempty"`
+	Classifier   *ClassifierResult    `json:"classifier,omitempty"`
 }
 
+// CapabilityEvidence records what triggered a detected capability
+type CapabilityEvidence struct {
+	Capability string `json:"capability"`
+	Pattern    string `json:"pattern"`  // Indicator from the capability's pattern list
+	Match      string `json:"match"`    // Symbol, class, line or string that contained it
+	Location   string `json:"location"` // Where the match was found, e.g. "symbol table" or "section .edata"
+}
+
+// ConfidenceScoring explains how the validation confidence was derived
+type ConfidenceScoring struct {
+	Rule            string   `json:"rule"`
+	Present         []string `json:"present"`
+	Missing         []string `json:"missing,omitempty"`
+	CapabilityCount int      `json:"capability_count"`
+	Explanation     string   `json:"explanation"`
+}
+
+// The capabilities every agent must show
+const agentCapabilityRule = "perception AND action AND (reasoning OR memory)"
+
 // ValidateAgent checks if a file is an AI agent based on defined criteria
 func ValidateAgent(filePath string) (*AgentValidationResult, error) {
 	// Read file
@@ -30,6 +53,33 @@ func ValidateAgent(filePath string) (*AgentValidationResult, error) {
 		return nil, fmt.Errorf("failed to read file: %v", err)
 	}
 
+	// The heuristic pass always runs since it collects the capability evidence
+	result, err := validateHeuristic(fileData, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Let the trained classifier make the final call when its model is
+	// available. It was trained on scripts only, so it has learned nothing
+	// about executables, WASM or JARs and those keep the heuristic verdict.
+	fileType := detectFileType(fileData, filePath)
+	switch {
+	case fileType == "unknown":
+	case !classifierTrainedOn(fileType):
+		result.Reasons = append(result.Reasons, fmt.Sprintf("Agent classifier is not trained on %s files, using heuristic capability scoring", fileType))
+	default:
+		if classifier := defaultClassifier(); classifier != nil {
+			classifier.classify(result, fileData, fileType)
+		} else {
+			result.Reasons = append(result.Reasons, "Agent classifier unavailable, using heuristic capability scoring")
+		}
+	}
+
+	return result, nil
+}
+
+// validateHeuristic scores an agent by matching capability keywords
+func validateHeuristic(fileData []byte, filePath string) (*AgentValidationResult, error) {
 	// Initialize result with default values
 	result := &AgentValidationResult{
 		IsAgent:      false,
@@ -37,6 +87,7 @@ func ValidateAgent(filePath string) (*AgentValidationResult, error) {
 		Reasons:      []string{},
 		AgentType:    "unknown",
 		Capabilities: []string{},
+		Evidence:     []CapabilityEvidence{},
 	}
 
 	// Check file type
@@ -149,6 +200,7 @@ func validateWasmAgent(data []byte) (*AgentValidationResult, error) {
 		Reasons:      []string{},
 		AgentType:    "wasm",
 		Capabilities: []string{},
+		Evidence:     []CapabilityEvidence{},
 	}
 
 	// Check for exported functions that suggest agent capabilities
@@ -156,30 +208,34 @@ func validateWasmAgent(data []byte) (*AgentValidationResult, error) {
 
 	// Check for perception functions (input interfaces)
 	perceptionFuncs := []string{"sense", "input", "receive", "observe", "perceive", "get"}
-	hasPerception := containsAnyString(data, perceptionFuncs)
+	pattern, hasPerception := matchAnyString(data, perceptionFuncs)
 	if hasPerception {
 		result.Capabilities = append(result.Capabilities, "perception")
+		result.addEvidence("perception", pattern, pattern, "module bytes")
 	}
 
 	// Check for action functions (output interfaces)
 	actionFuncs := []string{"act", "output", "send", "respond", "execute", "set"}
-	hasAction := containsAnyString(data, actionFuncs)
+	pattern, hasAction := matchAnyString(data, actionFuncs)
 	if hasAction {
 		result.Capabilities = append(result.Capabilities, "action")
+		result.addEvidence("action", pattern, pattern, "module bytes")
 	}
 
 	// Check for reasoning/decision functions
 	reasoningFuncs := []string{"decide", "reason", "think", "process", "analyze", "evaluate"}
-	hasReasoning := containsAnyString(data, reasoningFuncs)
+	pattern, hasReasoning := matchAnyString(data, reasoningFuncs)
 	if hasReasoning {
 		result.Capabilities = append(result.Capabilities, "reasoning")
+		result.addEvidence("reasoning", pattern, pattern, "module bytes")
 	}
 
 	// Check for memory/state management
 	memoryIndicators := []string{"memory", "state", "store", "remember", "history", "global"}
-	hasMemory := containsAnyString(data, memoryIndicators)
+	pattern, hasMemory := matchAnyString(data, memoryIndicators)
 	if hasMemory {
 		result.Capabilities = append(result.Capabilities, "memory")
+		result.addEvidence("memory", pattern, pattern, "module bytes")
 	}
 
 	// Calculate confidence based on capabilities
@@ -205,6 +261,7 @@ func validateWasmAgent(data []byte) (*AgentValidationResult, error) {
 	} else {
 		result.Reasons = append(result.Reasons, "WASM file lacks minimum required agent capabilities")
 	}
+	result.explainScoring(hasPerception, hasAction, hasReasoning, hasMemory)
 
 	return result, nil
 }
@@ -217,6 +274,7 @@ func validateElfAgent(data []byte) (*AgentValidationResult, error) {
 		Reasons:      []string{},
 		AgentType:    "elf",
 		Capabilities: []string{},
+		Evidence:     []CapabilityEvidence{},
 	}
 
 	// Parse ELF file to extract symbols and sections
@@ -233,9 +291,10 @@ func validateElfAgent(data []byte) (*AgentValidationResult, error) {
 	perceptionFuncs := []string{"sense", "input", "receive", "observe", "perceive", "get"}
 	hasPerception := false
 	for _, sym := range symbols {
-		if containsAnySubstring(sym.Name, perceptionFuncs) {
+		if pattern, ok := matchAnySubstring(sym.Name, perceptionFuncs); ok {
 			hasPerception = true
 			result.Capabilities = append(result.Capabilities, "perception")
+			result.addEvidence("perception", pattern, sym.Name, "symbol table")
 			break
 		}
 	}
@@ -244,9 +303,10 @@ func validateElfAgent(data []byte) (*AgentValidationResult, error) {
 	actionFuncs := []string{"act", "output", "send", "respond", "execute", "set"}
 	hasAction := false
 	for _, sym := range symbols {
-		if containsAnySubstring(sym.Name, actionFuncs) {
+		if pattern, ok := matchAnySubstring(sym.Name, actionFuncs); ok {
 			hasAction = true
 			result.Capabilities = append(result.Capabilities, "action")
+			result.addEvidence("action", pattern, sym.Name, "symbol table")
 			break
 		}
 	}
@@ -255,9 +315,10 @@ func validateElfAgent(data []byte) (*AgentValidationResult, error) {
 	reasoningFuncs := []string{"decide", "reason", "think", "process", "analyze", "evaluate"}
 	hasReasoning := false
 	for _, sym := range symbols {
-		if containsAnySubstring(sym.Name, reasoningFuncs) {
+		if pattern, ok := matchAnySubstring(sym.Name, reasoningFuncs); ok {
 			hasReasoning = true
 			result.Capabilities = append(result.Capabilities, "reasoning")
+			result.addEvidence("reasoning", pattern, sym.Name, "symbol table")
 			break
 		}
 	}
@@ -266,9 +327,10 @@ func validateElfAgent(data []byte) (*AgentValidationResult, error) {
 	memoryIndicators := []string{"memory", "state", "store", "remember", "history"}
 	hasMemory := false
 	for _, sym := range symbols {
-		if containsAnySubstring(sym.Name, memoryIndicators) {
+		if pattern, ok := matchAnySubstring(sym.Name, memoryIndicators); ok {
 			hasMemory = true
 			result.Capabilities = append(result.Capabilities, "memory")
+			result.addEvidence("memory", pattern, sym.Name, "symbol table")
 			break
 		}
 	}
@@ -281,6 +343,7 @@ func validateElfAgent(data []byte) (*AgentValidationResult, error) {
 			for _, lib := range aiLibraries {
 				if bytes.Contains(bytes.ToLower(sectionData), []byte(lib)) {
 					result.Capabilities = append(result.Capabilities, "ai_libraries")
+					result.addEvidence("ai_libraries", lib, lib, "section "+section.Name)
 					break
 				}
 			}
@@ -310,6 +373,7 @@ func validateElfAgent(data []byte) (*AgentValidationResult, error) {
 	} else {
 		result.Reasons = append(result.Reasons, "ELF binary lacks minimum required agent capabilities")
 	}
+	result.explainScoring(hasPerception, hasAction, hasReasoning, hasMemory)
 
 	return result, nil
 }
@@ -322,6 +386,7 @@ func validatePeAgent(data []byte) (*AgentValidationResult, error) {
 		Reasons:      []string{},
 		AgentType:    "pe",
 		Capabilities: []string{},
+		Evidence:     []CapabilityEvidence{},
 	}
 
 	// Parse PE file
@@ -344,6 +409,7 @@ func validatePeAgent(data []byte) (*AgentValidationResult, error) {
 				for _, lib := range aiDlls {
 					if bytes.Contains(bytes.ToLower(data), []byte(lib)) {
 						result.Capabilities = append(result.Capabilities, "ai_libraries")
+						result.addEvidence("ai_libraries", lib, lib, "section "+section.Name)
 						hasAILibraries = true
 						break
 					}
@@ -383,6 +449,7 @@ func validatePeAgent(data []byte) (*AgentValidationResult, error) {
 						if bytes.Contains(bytes.ToLower(data), []byte(func_)) {
 							hasPerception = true
 							result.Capabilities = append(result.Capabilities, "perception")
+							result.addEvidence("perception", func_, func_, "section "+section.Name)
 							break
 						}
 					}
@@ -393,6 +460,7 @@ func validatePeAgent(data []byte) (*AgentValidationResult, error) {
 						if bytes.Contains(bytes.ToLower(data), []byte(func_)) {
 							hasAction = true
 							result.Capabilities = append(result.Capabilities, "action")
+							result.addEvidence("action", func_, func_, "section "+section.Name)
 							break
 						}
 					}
@@ -403,6 +471,7 @@ func validatePeAgent(data []byte) (*AgentValidationResult, error) {
 						if bytes.Contains(bytes.ToLower(data), []byte(func_)) {
 							hasReasoning = true
 							result.Capabilities = append(result.Capabilities, "reasoning")
+							result.addEvidence("reasoning", func_, func_, "section "+section.Name)
 							break
 						}
 					}
@@ -413,6 +482,7 @@ func validatePeAgent(data []byte) (*AgentValidationResult, error) {
 						if bytes.Contains(bytes.ToLower(data), []byte(func_)) {
 							hasMemory = true
 							result.Capabilities = append(result.Capabilities, "memory")
+							result.addEvidence("memory", func_, func_, "section "+section.Name)
 							break
 						}
 					}
@@ -444,6 +514,7 @@ func validatePeAgent(data []byte) (*AgentValidationResult, error) {
 	} else {
 		result.Reasons = append(result.Reasons, "PE binary lacks minimum required agent capabilities")
 	}
+	result.explainScoring(hasPerception, hasAction, hasReasoning, hasMemory)
 
 	// If we didn't find any capabilities through section analysis, try string-based analysis
 	if len(result.Capabilities) == 0 {
@@ -467,6 +538,7 @@ func validateMachoAgent(data []byte) (*AgentValidationResult, error) {
 		Reasons:      []string{},
 		AgentType:    "macho",
 		Capabilities: []string{},
+		Evidence:     []CapabilityEvidence{},
 	}
 
 	// Parse Mach-O file
@@ -488,6 +560,7 @@ func validateMachoAgent(data []byte) (*AgentValidationResult, error) {
 		for _, aiLib := range aiLibs {
 			if bytes.Contains(bytes.ToLower(loadBytes), []byte(aiLib)) {
 				result.Capabilities = append(result.Capabilities, "ai_libraries")
+				result.addEvidence("ai_libraries", aiLib, aiLib, "load commands")
 				hasAILibraries = true
 				break
 			}
@@ -497,14 +570,21 @@ func validateMachoAgent(data []byte) (*AgentValidationResult, error) {
 		}
 	}
 
-	// Check for symbols that suggest agent capabilities
+	// Check for symbols that suggest agent capabilities. Stripped or
+	// malformed files may have no symbol table.
+	var symbols []macho.Symbol
+	if machoFile.Symtab != nil {
+		symbols = machoFile.Symtab.Syms
+	}
+
 	// Check for perception functions
 	perceptionFuncs := []string{"sense", "input", "receive", "observe", "perceive", "get"}
 	hasPerception := false
-	for _, sym := range machoFile.Symtab.Syms {
-		if containsAnySubstring(sym.Name, perceptionFuncs) {
+	for _, sym := range symbols {
+		if pattern, ok := matchAnySubstring(sym.Name, perceptionFuncs); ok {
 			hasPerception = true
 			result.Capabilities = append(result.Capabilities, "perception")
+			result.addEvidence("perception", pattern, sym.Name, "symbol table")
 			break
 		}
 	}
@@ -512,10 +592,11 @@ func validateMachoAgent(data []byte) (*AgentValidationResult, error) {
 	// Check for action functions
 	actionFuncs := []string{"act", "output", "send", "respond", "execute", "set"}
 	hasAction := false
-	for _, sym := range machoFile.Symtab.Syms {
-		if containsAnySubstring(sym.Name, actionFuncs) {
+	for _, sym := range symbols {
+		if pattern, ok := matchAnySubstring(sym.Name, actionFuncs); ok {
 			hasAction = true
 			result.Capabilities = append(result.Capabilities, "action")
+			result.addEvidence("action", pattern, sym.Name, "symbol table")
 			break
 		}
 	}
@@ -523,10 +604,11 @@ func validateMachoAgent(data []byte) (*AgentValidationResult, error) {
 	// Check for reasoning/decision functions
 	reasoningFuncs := []string{"decide", "reason", "think", "process", "analyze", "evaluate"}
 	hasReasoning := false
-	for _, sym := range machoFile.Symtab.Syms {
-		if containsAnySubstring(sym.Name, reasoningFuncs) {
+	for _, sym := range symbols {
+		if pattern, ok := matchAnySubstring(sym.Name, reasoningFuncs); ok {
 			hasReasoning = true
 			result.Capabilities = append(result.Capabilities, "reasoning")
+			result.addEvidence("reasoning", pattern, sym.Name, "symbol table")
 			break
 		}
 	}
@@ -534,10 +616,11 @@ func validateMachoAgent(data []byte) (*AgentValidationResult, error) {
 	// Check for memory/state management
 	memoryIndicators := []string{"memory", "state", "store", "remember", "history"}
 	hasMemory := false
-	for _, sym := range machoFile.Symtab.Syms {
-		if containsAnySubstring(sym.Name, memoryIndicators) {
+	for _, sym := range symbols {
+		if pattern, ok := matchAnySubstring(sym.Name, memoryIndicators); ok {
 			hasMemory = true
 			result.Capabilities = append(result.Capabilities, "memory")
+			result.addEvidence("memory", pattern, sym.Name, "symbol table")
 			break
 		}
 	}
@@ -565,6 +648,7 @@ func validateMachoAgent(data []byte) (*AgentValidationResult, error) {
 	} else {
 		result.Reasons = append(result.Reasons, "Mach-O binary lacks minimum required agent capabilities")
 	}
+	result.explainScoring(hasPerception, hasAction, hasReasoning, hasMemory)
 
 	return result, nil
 }
@@ -577,6 +661,7 @@ func validateScriptAgent(data []byte) (*AgentValidationResult, error) {
 		Reasons:      []string{},
 		AgentType:    "script",
 		Capabilities: []string{},
+		Evidence:     []CapabilityEvidence{},
 	}
 
 	// Convert data to string for easier analysis
@@ -589,12 +674,17 @@ func validateScriptAgent(data []byte) (*AgentValidationResult, error) {
 		"spacy", "nltk", "gensim", "autogpt", "agent", "reinforcement",
 	}
 
+	hasAILibraries := false
 	for _, lib := range aiLibraries {
-		if strings.Contains(strings.ToLower(content), "import "+lib) ||
-			strings.Contains(strings.ToLower(content), "require '"+lib) ||
-			strings.Contains(strings.ToLower(content), "require \""+lib) ||
-			strings.Contains(strings.ToLower(content), "from "+lib) {
-			result.Capabilities = append(result.Capabilities, "ai_libraries")
+		for _, statement := range []string{"import " + lib, "require '" + lib, "require \"" + lib, "from " + lib} {
+			if strings.Contains(strings.ToLower(content), statement) {
+				hasAILibraries = true
+				result.Capabilities = append(result.Capabilities, "ai_libraries")
+				result.addEvidence("ai_libraries", statement, lineContaining(content, statement), "source")
+				break
+			}
+		}
+		if hasAILibraries {
 			break
 		}
 	}
@@ -610,6 +700,7 @@ func validateScriptAgent(data []byte) (*AgentValidationResult, error) {
 		if strings.Contains(strings.ToLower(content), strings.ToLower(pattern)) {
 			hasPerception = true
 			result.Capabilities = append(result.Capabilities, "perception")
+			result.addEvidence("perception", pattern, lineContaining(content, pattern), "source")
 			break
 		}
 	}
@@ -625,6 +716,7 @@ func validateScriptAgent(data []byte) (*AgentValidationResult, error) {
 		if strings.Contains(strings.ToLower(content), strings.ToLower(pattern)) {
 			hasAction = true
 			result.Capabilities = append(result.Capabilities, "action")
+			result.addEvidence("action", pattern, lineContaining(content, pattern), "source")
 			break
 		}
 	}
@@ -640,6 +732,7 @@ func validateScriptAgent(data []byte) (*AgentValidationResult, error) {
 		if strings.Contains(strings.ToLower(content), strings.ToLower(pattern)) {
 			hasReasoning = true
 			result.Capabilities = append(result.Capabilities, "reasoning")
+			result.addEvidence("reasoning", pattern, lineContaining(content, pattern), "source")
 			break
 		}
 	}
@@ -654,6 +747,7 @@ func validateScriptAgent(data []byte) (*AgentValidationResult, error) {
 		if strings.Contains(strings.ToLower(content), strings.ToLower(pattern)) {
 			hasMemory = true
 			result.Capabilities = append(result.Capabilities, "memory")
+			result.addEvidence("memory", pattern, lineContaining(content, pattern), "source")
 			break
 		}
 	}
@@ -668,6 +762,7 @@ func validateScriptAgent(data []byte) (*AgentValidationResult, error) {
 		if strings.Contains(strings.ToLower(content), strings.ToLower(pattern)) {
 			hasAutonomy = true
 			result.Capabilities = append(result.Capabilities, "autonomy")
+			result.addEvidence("autonomy", pattern, lineContaining(content, pattern), "source")
 			break
 		}
 	}
@@ -700,6 +795,7 @@ func validateScriptAgent(data []byte) (*AgentValidationResult, error) {
 	} else {
 		result.Reasons = append(result.Reasons, "Script lacks minimum required agent capabilities")
 	}
+	result.explainScoring(hasPerception, hasAction, hasReasoning, hasMemory)
 
 	return result, nil
 }
@@ -714,6 +810,7 @@ func validateJarAgent(data []byte, filePath string) (*AgentValidationResult, err
 		Reasons:      []string{},
 		AgentType:    "jar",
 		Capabilities: []string{},
+		Evidence:     []CapabilityEvidence{},
 	}
 
 	// Create a temporary file to analyze
@@ -750,6 +847,7 @@ func validateJarAgent(data []byte, filePath string) (*AgentValidationResult, err
 	for _, lib := range aiLibraries {
 		if strings.Contains(strings.ToLower(jarContents), lib) {
 			result.Capabilities = append(result.Capabilities, "ai_libraries")
+			result.addEvidence("ai_libraries", lib, lineContaining(jarContents, lib), "archive entries")
 			break
 		}
 	}
@@ -763,6 +861,7 @@ func validateJarAgent(data []byte, filePath string) (*AgentValidationResult, err
 		if strings.Contains(jarContents, class+".class") {
 			hasPerception = true
 			result.Capabilities = append(result.Capabilities, "perception")
+			result.addEvidence("perception", class, lineContaining(jarContents, class+".class"), "archive entries")
 			break
 		}
 	}
@@ -776,6 +875,7 @@ func validateJarAgent(data []byte, filePath string) (*AgentValidationResult, err
 		if strings.Contains(jarContents, class+".class") {
 			hasAction = true
 			result.Capabilities = append(result.Capabilities, "action")
+			result.addEvidence("action", class, lineContaining(jarContents, class+".class"), "archive entries")
 			break
 		}
 	}
@@ -789,6 +889,7 @@ func validateJarAgent(data []byte, filePath string) (*AgentValidationResult, err
 		if strings.Contains(jarContents, class+".class") {
 			hasReasoning = true
 			result.Capabilities = append(result.Capabilities, "reasoning")
+			result.addEvidence("reasoning", class, lineContaining(jarContents, class+".class"), "archive entries")
 			break
 		}
 	}
@@ -802,6 +903,7 @@ func validateJarAgent(data []byte, filePath string) (*AgentValidationResult, err
 		if strings.Contains(jarContents, class+".class") {
 			hasMemory = true
 			result.Capabilities = append(result.Capabilities, "memory")
+			result.addEvidence("memory", class, lineContaining(jarContents, class+".class"), "archive entries")
 			break
 		}
 	}
@@ -813,6 +915,7 @@ func validateJarAgent(data []byte, filePath string) (*AgentValidationResult, err
 	for _, class := range agentClasses {
 		if strings.Contains(jarContents, class+".class") {
 			result.Capabilities = append(result.Capabilities, "agent_class")
+			result.addEvidence("agent_class", class, lineContaining(jarContents, class+".class"), "archive entries")
 			break
 		}
 	}
@@ -842,6 +945,7 @@ func validateJarAgent(data []byte, filePath string) (*AgentValidationResult, err
 	} else {
 		result.Reasons = append(result.Reasons, "JAR file lacks minimum required agent capabilities")
 	}
+	result.explainScoring(hasPerception, hasAction, hasReasoning, hasMemory)
 
 	return result, nil
 }
@@ -854,6 +958,7 @@ func validateBasedOnStringContent(content string, fileType string) *AgentValidat
 		Reasons:      []string{},
 		AgentType:    fileType,
 		Capabilities: []string{},
+		Evidence:     []CapabilityEvidence{},
 	}
 
 	// Check for perception functions
@@ -863,6 +968,7 @@ func validateBasedOnStringContent(content string, fileType string) *AgentValidat
 		if strings.Contains(strings.ToLower(content), func_) {
 			hasPerception = true
 			result.Capabilities = append(result.Capabilities, "perception")
+			result.addEvidence("perception", func_, lineContaining(content, func_), "embedded strings")
 			break
 		}
 	}
@@ -874,6 +980,7 @@ func validateBasedOnStringContent(content string, fileType string) *AgentValidat
 		if strings.Contains(strings.ToLower(content), func_) {
 			hasAction = true
 			result.Capabilities = append(result.Capabilities, "action")
+			result.addEvidence("action", func_, lineContaining(content, func_), "embedded strings")
 			break
 		}
 	}
@@ -885,6 +992,7 @@ func validateBasedOnStringContent(content string, fileType string) *AgentValidat
 		if strings.Contains(strings.ToLower(content), func_) {
 			hasReasoning = true
 			result.Capabilities = append(result.Capabilities, "reasoning")
+			result.addEvidence("reasoning", func_, lineContaining(content, func_), "embedded strings")
 			break
 		}
 	}
@@ -896,6 +1004,7 @@ func validateBasedOnStringContent(content string, fileType string) *AgentValidat
 		if strings.Contains(strings.ToLower(content), indicator) {
 			hasMemory = true
 			result.Capabilities = append(result.Capabilities, "memory")
+			result.addEvidence("memory", indicator, lineContaining(content, indicator), "embedded strings")
 			break
 		}
 	}
@@ -905,6 +1014,7 @@ func validateBasedOnStringContent(content string, fileType string) *AgentValidat
 	for _, lib := range aiLibraries {
 		if strings.Contains(strings.ToLower(content), lib) {
 			result.Capabilities = append(result.Capabilities, "ai_libraries")
+			result.addEvidence("ai_libraries", lib, lineContaining(content, lib), "embedded strings")
 			break
 		}
 	}
@@ -932,6 +1042,7 @@ func validateBasedOnStringContent(content string, fileType string) *AgentValidat
 	} else {
 		result.Reasons = append(result.Reasons, "Binary lacks minimum required agent capabilities based on string analysis")
 	}
+	result.explainScoring(hasPerception, hasAction, hasReasoning, hasMemory)
 
 	return result
 }
@@ -963,16 +1074,6 @@ func extractStringsFromBinary(data []byte) string {
 	return result.String()
 }
 
-// Helper function to check if a byte slice contains any of the given strings
-func containsAnyString(data []byte, strings []string) bool {
-	for _, s := range strings {
-		if bytes.Contains(bytes.ToLower(data), []byte(s)) {
-			return true
-		}
-	}
-	return false
-}
-
 // validateLibraryAgent validates if a shared library or DLL is an AI agent
 func validateLibraryAgent(data []byte) (*AgentValidationResult, error) {
 	result := &AgentValidationResult{
@@ -981,6 +1082,7 @@ func validateLibraryAgent(data []byte) (*AgentValidationResult, error) {
 		Reasons:      []string{},
 		AgentType:    "library",
 		Capabilities: []string{},
+		Evidence:     []CapabilityEvidence{},
 	}
 
 	// Determine the library format based on magic numbers
@@ -1008,6 +1110,7 @@ func validateLibraryAgent(data []byte) (*AgentValidationResult, error) {
 		if strings.Contains(strings.ToLower(stringData), func_) {
 			hasPerception = true
 			result.Capabilities = append(result.Capabilities, "perception")
+			result.addEvidence("perception", func_, lineContaining(stringData, func_), "embedded strings")
 			break
 		}
 	}
@@ -1019,6 +1122,7 @@ func validateLibraryAgent(data []byte) (*AgentValidationResult, error) {
 		if strings.Contains(strings.ToLower(stringData), func_) {
 			hasAction = true
 			result.Capabilities = append(result.Capabilities, "action")
+			result.addEvidence("action", func_, lineContaining(stringData, func_), "embedded strings")
 			break
 		}
 	}
@@ -1030,6 +1134,7 @@ func validateLibraryAgent(data []byte) (*AgentValidationResult, error) {
 		if strings.Contains(strings.ToLower(stringData), func_) {
 			hasReasoning = true
 			result.Capabilities = append(result.Capabilities, "reasoning")
+			result.addEvidence("reasoning", func_, lineContaining(stringData, func_), "embedded strings")
 			break
 		}
 	}
@@ -1041,6 +1146,7 @@ func validateLibraryAgent(data []byte) (*AgentValidationResult, error) {
 		if strings.Contains(strings.ToLower(stringData), indicator) {
 			hasMemory = true
 			result.Capabilities = append(result.Capabilities, "memory")
+			result.addEvidence("memory", indicator, lineContaining(stringData, indicator), "embedded strings")
 			break
 		}
 	}
@@ -1050,6 +1156,7 @@ func validateLibraryAgent(data []byte) (*AgentValidationResult, error) {
 	for _, lib := range aiLibraries {
 		if strings.Contains(strings.ToLower(stringData), lib) {
 			result.Capabilities = append(result.Capabilities, "ai_libraries")
+			result.addEvidence("ai_libraries", lib, lineContaining(stringData, lib), "embedded strings")
 			break
 		}
 	}
@@ -1077,17 +1184,109 @@ func validateLibraryAgent(data []byte) (*AgentValidationResult, error) {
 	} else {
 		result.Reasons = append(result.Reasons, "Library lacks minimum required agent capabilities based on string analysis")
 	}
+	result.explainScoring(hasPerception, hasAction, hasReasoning, hasMemory)
 
 	return result, nil
 }
 
-// Helper function to check if a string contains any of the given substrings
-func containsAnySubstring(s string, substrings []string) bool {
+// addEvidence records the pattern and match that triggered a capability
+func (r *AgentValidationResult) addEvidence(capability, pattern, match, location string) {
+	r.Evidence = append(r.Evidence, CapabilityEvidence{
+		Capability: capability,
+		Pattern:    pattern,
+		Match:      match,
+		Location:   location,
+	})
+}
+
+// explainScoring records how the capability rule and confidence table were applied
+func (r *AgentValidationResult) explainScoring(hasPerception, hasAction, hasReasoning, hasMemory bool) {
+	scoring := &ConfidenceScoring{
+		Rule:            agentCapabilityRule,
+		Present:         []string{},
+		CapabilityCount: len(r.Capabilities),
+	}
+
+	seen := make(map[string]bool)
+	for _, capability := range r.Capabilities {
+		if !seen[capability] {
+			seen[capability] = true
+			scoring.Present = append(scoring.Present, capability)
+		}
+	}
+
+	if !hasPerception {
+		scoring.Missing = append(scoring.Missing, "perception")
+	}
+	if !hasAction {
+		scoring.Missing = append(scoring.Missing, "action")
+	}
+	if !hasReasoning && !hasMemory {
+		scoring.Missing = append(scoring.Missing, "reasoning or memory")
+	}
+
+	if r.IsAgent {
+		scoring.Explanation = fmt.Sprintf("Rule satisfied; %d capability matches (%s) map to confidence %.2f in the %s scoring table",
+			scoring.CapabilityCount, strings.Join(scoring.Present, ", "), r.Confidence, r.AgentType)
+		if seen["autonomy"] {
+			scoring.Explanation += "; autonomy indicators raise confidence to the maximum for scripts"
+		}
+	} else {
+		scoring.Explanation = fmt.Sprintf("Rule not satisfied; missing %s, so confidence stays %.2f",
+			strings.Join(scoring.Missing, ", "), r.Confidence)
+	}
+
+	r.Scoring = scoring
+}
+
+// matchAnyString returns the first of the given strings contained in data
+func matchAnyString(data []byte, strings []string) (string, bool) {
+	lower := bytes.ToLower(data)
+	for _, s := range strings {
+		if bytes.Contains(lower, []byte(s)) {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// matchAnySubstring returns the first of the given substrings contained in s
+func matchAnySubstring(s string, substrings []string) (string, bool) {
 	lowerS := strings.ToLower(s)
 	for _, sub := range substrings {
 		if strings.Contains(lowerS, strings.ToLower(sub)) {
-			return true
+			return sub, true
 		}
 	}
-	return false
+	return "", false
+}
+
+// lineContaining returns the trimmed line of content that contains pattern
+func lineContaining(content, pattern string) string {
+	// Lowercase ASCII only so byte offsets still line up with content
+	lower := []byte(content)
+	for i, c := range lower {
+		if 'A' <= c && c <= 'Z' {
+			lower[i] = c + 'a' - 'A'
+		}
+	}
+
+	index := strings.Index(string(lower), strings.ToLower(pattern))
+	if index < 0 {
+		return ""
+	}
+
+	start := strings.LastIndex(content[:index], "\n") + 1
+	end := strings.Index(content[index:], "\n")
+	if end < 0 {
+		end = len(content)
+	} else {
+		end += index
+	}
+
+	line := strings.TrimSpace(content[start:end])
+	if len(line) > 120 {
+		line = line[:120] + "..."
+	}
+	return line
 }
@@ -0,0 +1,79 @@
+package aegong
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestValidateAgentEvidence tests that script capabilities are traced back to source lines
+func TestValidateAgentEvidence(t *testing.T) {
+	script := "import openai\n\nclass Bot:\n    def sense(self):\n        pass\n    def act(self):\n        pass\n    def think(self):\n        self.memory = []\n"
+	path := filepath.Join(t.TempDir(), "agent.py")
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	result, err := ValidateAgent(path)
+	if err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+	if !result.IsAgent {
+		t.Fatalf("Script should be classified as an agent: %+v", result)
+	}
+
+	matches := make(map[string]CapabilityEvidence)
+	for _, evidence := range result.Evidence {
+		matches[evidence.Capability] = evidence
+	}
+	if got := matches["perception"].Match; got != "def sense(self):" {
+		t.Fatalf("Perception evidence should point at the source line, got %q", got)
+	}
+	if got := matches["ai_libraries"].Pattern; got != "import openai" {
+		t.Fatalf("AI library evidence should record the import, got %q", got)
+	}
+
+	if result.Scoring == nil || len(result.Scoring.Missing) != 0 {
+		t.Fatalf("Scoring should show the capability rule as satisfied: %+v", result.Scoring)
+	}
+	if result.Scoring.CapabilityCount != len(result.Capabilities) {
+		t.Fatalf("Scoring should count %d capabilities, got %d", len(result.Capabilities), result.Scoring.CapabilityCount)
+	}
+}
+
+// TestValidateAgentRejectionExplained tests that rejections list the missing capabilities
+func TestValidateAgentRejectionExplained(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello.py")
+	if err := os.WriteFile(path, []byte("print('hello')\n"), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	result, err := ValidateAgent(path)
+	if err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+	if result.IsAgent {
+		t.Fatal("Plain script should not be classified as an agent")
+	}
+
+	want := []string{"perception", "action", "reasoning or memory"}
+	if result.Scoring == nil || len(result.Scoring.Missing) != len(want) {
+		t.Fatalf("Scoring should list %v as missing, got %+v", want, result.Scoring)
+	}
+	for i, capability := range want {
+		if result.Scoring.Missing[i] != capability {
+			t.Fatalf("Missing capability %d should be %q, got %q", i, capability, result.Scoring.Missing[i])
+		}
+	}
+}
+
+// TestLineContaining tests source line extraction around a case-insensitive match
+func TestLineContaining(t *testing.T) {
+	content := "first\n  class Memory:\nlast"
+	if got := lineContaining(content, "class memory"); got != "class Memory:" {
+		t.Fatalf("Should return the trimmed matching line, got %q", got)
+	}
+	if got := lineContaining(content, "absent"); got != "" {
+		t.Fatalf("Should return an empty string without a match, got %q", got)
+	}
+}
@@ -0,0 +1,69 @@
+package aegong
+
+import "runtime/debug"
+
+// Build information, set with
+// -ldflags "-X Agent_Auditor/pkg/aegong.Version=v1.2.3 -X Agent_Auditor/pkg/aegong.Commit=<sha>".
+// When unset they are taken from the module and VCS information Go embeds.
+var (
+	Version string
+	Commit  string
+)
+
+// EngineVersion identifies the build and detector configuration that produced
+// a report, so findings can be traced to the exact ruleset
+type EngineVersion struct {
+	Version          string `json:"version"`
+	Commit           string `json:"commit,omitempty"`
+	DetectorRevision int    `json:"detector_revision"`
+	ConfigChecksum   string `json:"config_checksum"` // Detectors, shields and their runtime settings
+}
+
+// buildVersion returns the version and commit of the running binary
+func buildVersion() (string, string) {
+	version, commit := Version, Commit
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version, commit
+	}
+
+	if version == "" {
+		version = info.Main.Version
+	}
+	if commit == "" {
+		modified := false
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				commit = setting.Value
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if commit != "" && modified {
+			commit += "-dirty"
+		}
+	}
+	return version, commit
+}
+
+// Version returns the engine's build and current detector configuration
+func (e *Engine) Version() EngineVersion {
+	version, commit := buildVersion()
+	if version == "" {
+		version = "(devel)"
+	}
+	return EngineVersion{
+		Version:          version,
+		Commit:           commit,
+		DetectorRevision: detectorRevision,
+		ConfigChecksum:   e.configVersion(),
+	}
+}
+
+// Outdated reports whether a report stamped with v was produced by different
+// detector rules than current; reports without a stamp predate stamping and
+// are always outdated
+func (v *EngineVersion) Outdated(current EngineVersion) bool {
+	return v == nil || v.ConfigChecksum != current.ConfigChecksum
+}
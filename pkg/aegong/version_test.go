@@ -0,0 +1,61 @@
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestVersionStamping tests that reports and audit log entries carry the engine version
+func TestVersionStamping(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	engine, err := NewEngine(Config{AuditLogPath: logPath})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	report, err := engine.Audit(context.Background(), bytes.NewReader([]byte("#!/bin/sh\necho hello\n")))
+	if err != nil {
+		t.Fatalf("Failed to audit agent: %v", err)
+	}
+	current := engine.Version()
+	if report.Engine == nil || report.Engine.ConfigChecksum != current.ConfigChecksum || report.Engine.Version == "" ||
+		report.Engine.DetectorRevision != detectorRevision {
+		t.Fatalf("Report should be stamped with the engine version %+v, got %+v", current, report.Engine)
+	}
+	if report.Engine.Outdated(current) {
+		t.Fatal("Report from the current rules should not be outdated")
+	}
+
+	// Changing a detector's settings changes the ruleset
+	disabled := false
+	if _, err := engine.UpdateComponent("T2", ComponentUpdate{Enabled: &disabled}, "alice", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if !report.Engine.Outdated(engine.Version()) {
+		t.Fatal("Report from earlier rules should be outdated")
+	}
+	var unstamped *EngineVersion
+	if !unstamped.Outdated(current) {
+		t.Fatal("Reports without a stamp should be outdated")
+	}
+
+	data, _ := os.ReadFile(logPath)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for _, line := range lines {
+		var entry struct {
+			Engine *EngineVersion `json:"engine"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Engine == nil || entry.Engine.ConfigChecksum == "" {
+			t.Fatalf("Audit log entry should carry the engine version: %s", line)
+		}
+	}
+	if len(lines) != 2 {
+		t.Fatalf("Should log the audit and the component change, got %d entries", len(lines))
+	}
+}
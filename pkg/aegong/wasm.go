@@ -0,0 +1,99 @@
+package aegong
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// Context key the host functions find their run's pluginHost under
+type pluginHostKey struct{}
+
+func hostOf(ctx context.Context) *pluginHost {
+	return ctx.Value(pluginHostKey{}).(*pluginHost)
+}
+
+// wazeroRuntime runs plugins in wazero with the "aegong" host module as
+// their only import: no WASI, filesystem, network or clock
+type wazeroRuntime struct {
+	runtime wazero.Runtime
+}
+
+func newPluginRuntime(ctx context.Context) (pluginRuntime, error) {
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(pluginMemoryPages).
+		WithCloseOnContextDone(true)
+	runtime := wazero.NewRuntimeWithConfig(ctx, config)
+
+	_, err := runtime.NewHostModuleBuilder("aegong").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context) uint32 {
+		return hostOf(ctx).contextLen()
+	}).Export("context_len").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr uint32) int32 {
+		return hostOf(ctx).readContext(m.Memory(), ptr)
+	}).Export("read_context").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context) uint32 {
+		return hostOf(ctx).contentLen()
+	}).Export("content_len").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, offset, length uint32) int32 {
+		return hostOf(ctx).readContent(m.Memory(), ptr, offset, length)
+	}).Export("read_content").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, length uint32) int32 {
+		return hostOf(ctx).emitFinding(m.Memory(), ptr, length)
+	}).Export("emit_finding").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, length uint32) {
+		hostOf(ctx).log(ctx, m.Memory(), ptr, length)
+	}).Export("log").
+		Instantiate(ctx)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("failed to create plugin host module: %v", err)
+	}
+	return &wazeroRuntime{runtime: runtime}, nil
+}
+
+func (r *wazeroRuntime) compile(ctx context.Context, name string, wasm []byte) (pluginModule, error) {
+	compiled, err := r.runtime.CompileModule(ctx, wasm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile module: %v", err)
+	}
+	for _, imported := range compiled.ImportedFunctions() {
+		if module, _, _ := imported.Import(); module != "aegong" {
+			return nil, fmt.Errorf("module imports from %q; plugins may only import the aegong host API", module)
+		}
+	}
+	if _, ok := compiled.ExportedFunctions()["detect"]; !ok {
+		return nil, fmt.Errorf("module does not export detect")
+	}
+	return &wazeroModule{runtime: r.runtime, compiled: compiled}, nil
+}
+
+func (r *wazeroRuntime) close(ctx context.Context) error {
+	return r.runtime.Close(ctx)
+}
+
+type wazeroModule struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+}
+
+// run instantiates the module afresh and calls its detect export
+func (m *wazeroModule) run(ctx context.Context, host *pluginHost) error {
+	ctx = context.WithValue(ctx, pluginHostKey{}, host)
+	instance, err := m.runtime.InstantiateModule(ctx, m.compiled, wazero.NewModuleConfig().WithName("").WithStartFunctions())
+	if err != nil {
+		return err
+	}
+	defer instance.Close(ctx)
+
+	results, err := instance.ExportedFunction("detect").Call(ctx)
+	if err != nil {
+		return err
+	}
+	if len(results) > 0 && int32(results[0]) != 0 {
+		return fmt.Errorf("detect returned %d", int32(results[0]))
+	}
+	return nil
+}
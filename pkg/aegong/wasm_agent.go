@@ -0,0 +1,189 @@
+package aegong
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/experimental/logging"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// WebAssembly agents run in wazero rather than the ptrace sandbox: the
+// module gets WASI with the container directory as its root filesystem and
+// nothing else, no environment, sockets or other host modules. Instead of
+// syscalls, the execution log lists the WASI calls the module made.
+
+// The only host module WebAssembly agents may import
+const wasiModule = "wasi_snapshot_preview1"
+
+// Most bytes of WASI call details, standard output and standard error kept
+const maxWasmLog = 64 << 10
+
+const wasmPageSize = 64 << 10
+
+// limitedBuffer keeps the first limit bytes written to it
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.truncated = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+func (b *limitedBuffer) WriteString(s string) (int, error) {
+	return b.Write([]byte(s))
+}
+
+// wasmStrategy runs WASI commands in the WASI runtime. Modules without a
+// _start export are libraries, and modules importing anything but WASI
+// can't be instantiated.
+func wasmStrategy(ctx context.Context, binary []byte) DynamicStrategy {
+	// The interpreter only decodes and validates here, which is much faster than compiling
+	inspector := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer inspector.Close(ctx)
+	compiled, err := inspector.CompileModule(ctx, binary)
+	if err != nil {
+		return DynamicStrategy{Name: StrategyNone, Reason: fmt.Sprintf("invalid WebAssembly module: %v", err), unavailable: true}
+	}
+	for _, imported := range compiled.ImportedFunctions() {
+		if module, name, _ := imported.Import(); module != wasiModule {
+			return DynamicStrategy{Name: StrategyNone, Reason: fmt.Sprintf("WebAssembly module imports %s.%s, the WASI runtime only provides %s", module, name, wasiModule), unavailable: true}
+		}
+	}
+	if _, ok := compiled.ExportedFunctions()["_start"]; !ok {
+		return DynamicStrategy{Name: StrategyNone, Reason: "WebAssembly module without a _start export, a library with no entry point to run"}
+	}
+	return DynamicStrategy{Name: StrategyWASM, Reason: "WebAssembly module, run in the WASI runtime"}
+}
+
+// runWasmAgent runs a WebAssembly agent's _start in the WASI runtime and
+// returns the execution log
+func (e *Engine) runWasmAgent(ctx context.Context, binary []byte, container *CustomContainer) string {
+	var executionLog bytes.Buffer
+	writeLog := func(format string, args ...interface{}) {
+		executionLog.WriteString(fmt.Sprintf(format, args...))
+	}
+	fail := func(format string, args ...interface{}) string {
+		message := fmt.Sprintf(format, args...)
+		e.mutex.Lock()
+		container.ExecutionError = message
+		e.mutex.Unlock()
+		writeLog("ERROR: %s\n", message)
+		return executionLog.String()
+	}
+
+	pages := uint32(min(container.MemoryLimit/wasmPageSize, 65536))
+	writeLog("[EXECUTION] Container: %s\n", container.ID)
+	writeLog("Binary Size: %d bytes\n", len(binary))
+	writeLog("Runtime: WASI (%s)\n", wasiModule)
+	writeLog("Memory Limit: %d MB\n", int64(pages)*wasmPageSize/(1024*1024))
+	writeLog("Network: None (no sockets are provided)\n")
+	writeLog("Filesystem: %s\n", container.FileSystem)
+
+	// Count every WASI call, and log the filesystem, socket and process ones
+	// with their arguments. The module runs on this goroutine, so the
+	// listeners need no locking.
+	calls := make(map[string]int)
+	counter := experimental.FunctionListenerFactoryFunc(func(def api.FunctionDefinition) experimental.FunctionListener {
+		if def.GoFunction() == nil {
+			return nil
+		}
+		name := def.Name()
+		return experimental.FunctionListenerFunc(func(context.Context, api.Module, api.FunctionDefinition, []uint64, experimental.StackIterator) {
+			calls[name]++
+		})
+	})
+	details := &limitedBuffer{limit: maxWasmLog}
+	listeners := experimental.MultiFunctionListenerFactory(counter,
+		logging.NewHostLoggingListenerFactory(details, logging.LogScopeFilesystem|logging.LogScopeSock|logging.LogScopeProc))
+	runCtx, cancel := context.WithTimeout(experimental.WithFunctionListenerFactory(ctx, listeners), executionTimeout)
+	defer cancel()
+
+	config := wazero.NewRuntimeConfig().WithMemoryLimitPages(pages).WithCloseOnContextDone(true)
+	runtime := wazero.NewRuntimeWithConfig(runCtx, config)
+	defer runtime.Close(ctx)
+	if _, err := wasi_snapshot_preview1.Instantiate(runCtx, runtime); err != nil {
+		return fail("failed to set up WASI: %v", err)
+	}
+	compiled, err := runtime.CompileModule(runCtx, binary)
+	if err != nil {
+		return fail("failed to compile WebAssembly module: %v", err)
+	}
+
+	stdout, stderr := &limitedBuffer{limit: maxWasmLog}, &limitedBuffer{limit: maxWasmLog}
+	moduleConfig := wazero.NewModuleConfig().
+		WithName("agent").
+		WithArgs("agent").
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithFSConfig(wazero.NewFSConfig().WithDirMount(container.FileSystem, "/")).
+		WithSysWalltime().
+		WithSysNanotime()
+
+	startTime := time.Now()
+	exitCode := 0
+	_, err = runtime.InstantiateModule(runCtx, compiled, moduleConfig)
+	var exitErr *sys.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == sys.ExitCodeDeadlineExceeded:
+		writeLog("ERROR: Process execution timed out\n")
+		exitCode = -1
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == sys.ExitCodeContextCanceled:
+		writeLog("ERROR: Process execution cancelled: %v\n", ctx.Err())
+		exitCode = -1
+	case errors.As(err, &exitErr):
+		exitCode = int(exitErr.ExitCode())
+	default:
+		// Traps such as unreachable or running out of memory end the run like a crash
+		writeLog("ERROR: Module trapped: %v\n", err)
+		exitCode = -1
+	}
+	executionTime := time.Since(startTime)
+
+	writeLog("WASI Calls:\n")
+	names := make([]string, 0, len(calls))
+	for name := range calls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeLog("  %s: %d times\n", name, calls[name])
+	}
+	if details.Len() > 0 {
+		writeLog("WASI Call Details:\n%s", details.String())
+		if details.truncated {
+			writeLog("  (truncated)\n")
+		}
+	}
+	usage, _ := diskUsage(container, 0)
+	writeLog("Disk Usage: %d KB of %d KB\n", usage/1024, container.DiskQuota/1024)
+	e.mutex.Lock()
+	container.DiskUsage = usage
+	e.mutex.Unlock()
+
+	if stdout.Len() > 0 {
+		writeLog("Standard Output:\n%s\n", stdout.String())
+	}
+	if stderr.Len() > 0 {
+		writeLog("Standard Error:\n%s\n", stderr.String())
+	}
+	writeLog("Process Completed: Exit code %d\n", exitCode)
+	writeLog("Execution Time: %v\n", executionTime)
+	return executionLog.String()
+}
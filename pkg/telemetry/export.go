@@ -0,0 +1,306 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Batching of exported spans
+const (
+	exportInterval = 5 * time.Second
+	exportBatch    = 512  // Spans sent in one request
+	maxQueued      = 4096 // Spans waiting for export before new ones are dropped
+)
+
+// Config describes where spans are exported
+type Config struct {
+	Endpoint       string            // OTLP/HTTP traces URL, such as http://collector:4318/v1/traces
+	Headers        map[string]string // Sent with every export, for example for authentication
+	ServiceName    string
+	ServiceVersion string
+}
+
+// Tracer batches finished spans and exports them to an OTLP collector
+type Tracer struct {
+	config Config
+	client *http.Client
+
+	mutex   sync.Mutex
+	queue   []*Span
+	dropped int
+
+	flush chan chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewTracer starts a tracer exporting to config.Endpoint; call Shutdown to
+// export the remaining spans and stop it
+func NewTracer(config Config) *Tracer {
+	if config.ServiceName == "" {
+		config.ServiceName = "aegong"
+	}
+	t := &Tracer{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		flush:  make(chan chan struct{}),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// ConfigFromEnv reads the standard OTEL_* variables, returning false when
+// no collector endpoint is configured or the SDK is disabled
+func ConfigFromEnv() (Config, bool, error) {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return Config{}, false, nil
+	}
+	if protocol := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); protocol != "" && protocol != "http/json" {
+		return Config{}, false, fmt.Errorf("OTEL_EXPORTER_OTLP_PROTOCOL %q is not supported, use http/json", protocol)
+	}
+
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+		}
+	}
+	if endpoint == "" {
+		return Config{}, false, nil
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Config{}, false, fmt.Errorf("invalid OTLP endpoint %q", endpoint)
+	}
+
+	headers := make(map[string]string)
+	for _, entry := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return Config{}, false, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_HEADERS entry %q, expected key=value", entry)
+		}
+		if unescaped, err := url.QueryUnescape(strings.TrimSpace(value)); err == nil {
+			value = unescaped
+		}
+		headers[strings.TrimSpace(key)] = value
+	}
+
+	return Config{Endpoint: endpoint, Headers: headers, ServiceName: os.Getenv("OTEL_SERVICE_NAME")}, true, nil
+}
+
+// enqueue queues a finished span for the next export
+func (t *Tracer) enqueue(span *Span) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(t.queue) >= maxQueued {
+		t.dropped++
+		return
+	}
+	t.queue = append(t.queue, span)
+}
+
+// Flush exports every queued span, returning once done or ctx ends
+func (t *Tracer) Flush(ctx context.Context) {
+	finished := make(chan struct{})
+	select {
+	case t.flush <- finished:
+	case <-t.done:
+		return
+	case <-ctx.Done():
+		return
+	}
+	select {
+	case <-finished:
+	case <-ctx.Done():
+	}
+}
+
+// Shutdown exports the remaining spans and stops the tracer
+func (t *Tracer) Shutdown(ctx context.Context) {
+	select {
+	case <-t.stop:
+	default:
+		close(t.stop)
+	}
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.exportQueued()
+		case finished := <-t.flush:
+			t.exportQueued()
+			close(finished)
+		case <-t.stop:
+			t.exportQueued()
+			return
+		}
+	}
+}
+
+// exportQueued sends the queued spans in batches
+func (t *Tracer) exportQueued() {
+	t.mutex.Lock()
+	queue, dropped := t.queue, t.dropped
+	t.queue, t.dropped = nil, 0
+	t.mutex.Unlock()
+
+	if dropped > 0 {
+		log.Printf("Warning: Dropped %d trace spans, the export queue was full", dropped)
+	}
+	for len(queue) > 0 {
+		batch := queue[:min(len(queue), exportBatch)]
+		queue = queue[len(batch):]
+		if err := t.export(batch); err != nil {
+			log.Printf("Warning: Failed to export %d trace spans: %v", len(batch), err)
+		}
+	}
+}
+
+// export posts spans to the collector as an OTLP ExportTraceServiceRequest
+func (t *Tracer) export(spans []*Span) error {
+	body, err := json.Marshal(t.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", t.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// OTLP/JSON encoding of the trace export request
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            *otlpStatus     `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"` // 2 is error
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+)
+
+func (t *Tracer) request(spans []*Span) otlpRequest {
+	resource := []otlpAttribute{attribute("service.name", t.config.ServiceName)}
+	if t.config.ServiceVersion != "" {
+		resource = append(resource, attribute("service.version", t.config.ServiceVersion))
+	}
+
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		span.mutex.Lock()
+		s := otlpSpan{
+			TraceID:           hex.EncodeToString(span.context.TraceID[:]),
+			SpanID:            hex.EncodeToString(span.context.SpanID[:]),
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		}
+		if span.parent != [8]byte{} {
+			s.ParentSpanID = hex.EncodeToString(span.parent[:])
+		}
+		for key, value := range span.attributes {
+			s.Attributes = append(s.Attributes, attribute(key, value))
+		}
+		if span.err != "" {
+			s.Status = &otlpStatus{Code: 2, Message: span.err}
+		}
+		span.mutex.Unlock()
+		encoded = append(encoded, s)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: resource},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "Agent_Auditor"}, Spans: encoded}},
+	}}}
+}
+
+// attribute encodes a value as an OTLP AnyValue; 64-bit integers are strings in OTLP/JSON
+func attribute(key string, value interface{}) otlpAttribute {
+	var v map[string]interface{}
+	switch value := value.(type) {
+	case string:
+		v = map[string]interface{}{"stringValue": value}
+	case bool:
+		v = map[string]interface{}{"boolValue": value}
+	case int:
+		v = map[string]interface{}{"intValue": strconv.Itoa(value)}
+	case int64:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}
+	case float64:
+		v = map[string]interface{}{"doubleValue": value}
+	default:
+		v = map[string]interface{}{"stringValue": fmt.Sprint(value)}
+	}
+	return otlpAttribute{Key: key, Value: v}
+}
@@ -0,0 +1,187 @@
+// Package telemetry records OpenTelemetry trace spans and exports them to an
+// OTLP collector over HTTP/JSON, without pulling in the OpenTelemetry SDK.
+//
+// Until a tracer is installed with SetTracer, Start returns a nil span and
+// every Span method is a no-op, so instrumented code costs almost nothing
+// when tracing is off.
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Span kinds, as numbered by OTLP
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// SpanContext identifies a span within a trace
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+// Valid reports whether both IDs are set
+func (sc SpanContext) Valid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Span is a timed operation within a trace. A nil Span records nothing.
+type Span struct {
+	tracer  *Tracer
+	context SpanContext
+	parent  [8]byte
+	name    string
+	kind    int
+	start   time.Time
+
+	mutex      sync.Mutex
+	end        time.Time
+	attributes map[string]interface{}
+	err        string
+	ended      bool
+}
+
+// Installed tracer; nil while tracing is off
+var current atomic.Pointer[Tracer]
+
+// SetTracer installs the tracer spans are exported with; nil turns tracing off
+func SetTracer(t *Tracer) {
+	current.Store(t)
+}
+
+// Enabled reports whether spans are being recorded
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// Start begins a span named name as a child of the span in ctx, if any, and
+// returns a context holding it. Attributes are given as key, value pairs.
+func Start(ctx context.Context, name string, attributes ...interface{}) (context.Context, *Span) {
+	return StartKind(ctx, name, KindInternal, attributes...)
+}
+
+// StartKind begins a span of the given kind; see Start
+func StartKind(ctx context.Context, name string, kind int, attributes ...interface{}) (context.Context, *Span) {
+	tracer := current.Load()
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	span := &Span{tracer: tracer, name: name, kind: kind, start: time.Now(), attributes: make(map[string]interface{})}
+	if parent := FromContext(ctx); parent != nil {
+		span.context.TraceID = parent.context.TraceID
+		span.parent = parent.context.SpanID
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		span.context.TraceID = remote.TraceID
+		span.parent = remote.SpanID
+	} else {
+		rand.Read(span.context.TraceID[:])
+	}
+	rand.Read(span.context.SpanID[:])
+
+	for i := 0; i+1 < len(attributes); i += 2 {
+		if key, ok := attributes[i].(string); ok {
+			span.attributes[key] = attributes[i+1]
+		}
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// FromContext returns the span ctx holds, or nil
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SetAttribute records a string, bool, integer or floating point value on the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.attributes[key] = value
+}
+
+// SetError marks the span as failed; nil errors are ignored
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.err = err.Error()
+}
+
+// End finishes the span and queues it for export. Only the first call counts.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mutex.Unlock()
+	s.tracer.enqueue(s)
+}
+
+// Context returns the span's trace and span IDs
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// ParseTraceparent reads a W3C traceparent header such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+func ParseTraceparent(header string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return sc, fmt.Errorf("invalid traceparent %q", header)
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, fmt.Errorf("invalid trace ID in traceparent: %v", err)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, fmt.Errorf("invalid span ID in traceparent: %v", err)
+	}
+	if !sc.Valid() {
+		return sc, fmt.Errorf("traceparent %q has a zero ID", header)
+	}
+	return sc, nil
+}
+
+// WithRemoteParent returns a context whose spans continue the trace of a
+// span in another service
+func WithRemoteParent(ctx context.Context, parent SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, parent)
+}
+
+// Traceparent formats the span in ctx as a W3C traceparent header, or returns
+// "" if ctx holds no span
+func Traceparent(ctx context.Context) string {
+	span := FromContext(ctx)
+	if span == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(span.context.TraceID[:]), hex.EncodeToString(span.context.SpanID[:]))
+}
@@ -0,0 +1,137 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// collector is a fake OTLP/HTTP endpoint that keeps the spans it receives
+type collector struct {
+	mutex  sync.Mutex
+	spans  []otlpSpan
+	header http.Header
+}
+
+func newCollector(t *testing.T) (*collector, *httptest.Server) {
+	c := &collector{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Collector received invalid JSON: %v", err)
+		}
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		c.header = r.Header
+		for _, resource := range request.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				c.spans = append(c.spans, scope.Spans...)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return c, server
+}
+
+// TestExportSpans tests that finished spans reach the collector with their parents
+func TestExportSpans(t *testing.T) {
+	c, server := newCollector(t)
+	tracer := NewTracer(Config{Endpoint: server.URL + "/v1/traces", Headers: map[string]string{"Authorization": "Bearer x"}})
+	SetTracer(tracer)
+	t.Cleanup(func() { SetTracer(nil) })
+
+	ctx, parent := Start(context.Background(), "parent", "count", 3)
+	_, child := Start(ctx, "child")
+	child.SetError(errors.New("boom"))
+	child.End()
+	child.End()
+	parent.End()
+	tracer.Shutdown(context.Background())
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.spans) != 2 {
+		t.Fatalf("Should export 2 spans once each, got %d", len(c.spans))
+	}
+	exported := map[string]otlpSpan{}
+	for _, span := range c.spans {
+		exported[span.Name] = span
+	}
+	if exported["child"].TraceID != exported["parent"].TraceID || exported["child"].ParentSpanID != exported["parent"].SpanID {
+		t.Fatalf("Child should belong to the parent's trace, got %+v", c.spans)
+	}
+	if exported["child"].Status == nil || exported["child"].Status.Message != "boom" {
+		t.Fatal("Child should be exported with its error status")
+	}
+	if len(exported["parent"].Attributes) != 1 || exported["parent"].Attributes[0].Value["intValue"] != "3" {
+		t.Fatalf("Parent should carry its attribute, got %+v", exported["parent"].Attributes)
+	}
+	if c.header.Get("Authorization") != "Bearer x" {
+		t.Fatal("Export should send the configured headers")
+	}
+}
+
+// TestTraceparent tests continuing a trace from a W3C traceparent header
+func TestTraceparent(t *testing.T) {
+	SetTracer(NewTracer(Config{Endpoint: "http://127.0.0.1:0/v1/traces"}))
+	t.Cleanup(func() { SetTracer(nil) })
+
+	remote, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatalf("Failed to parse traceparent: %v", err)
+	}
+	ctx, span := Start(WithRemoteParent(context.Background(), remote), "server")
+	if span.Context().TraceID != remote.TraceID || span.parent != remote.SpanID {
+		t.Fatal("Span should continue the remote trace")
+	}
+	if header := Traceparent(ctx); len(header) != 55 || header[3:35] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("Unexpected traceparent %q", header)
+	}
+
+	for _, header := range []string{"", "00-abc-def-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"} {
+		if _, err := ParseTraceparent(header); err == nil {
+			t.Fatalf("Traceparent %q should be rejected", header)
+		}
+	}
+}
+
+// TestDisabled tests that spans are no-ops without a tracer
+func TestDisabled(t *testing.T) {
+	SetTracer(nil)
+	ctx, span := Start(context.Background(), "noop")
+	if span != nil || ctx != context.Background() || Enabled() {
+		t.Fatal("Start should return a nil span while tracing is off")
+	}
+	span.SetAttribute("key", "value")
+	span.SetError(errors.New("ignored"))
+	span.End()
+}
+
+// TestConfigFromEnv tests reading the standard OTEL_* variables
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	if _, ok, err := ConfigFromEnv(); ok || err != nil {
+		t.Fatal("Tracing should be off without an endpoint")
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318/")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "api-key=s%20ecret")
+	t.Setenv("OTEL_SERVICE_NAME", "aegong-eu")
+	config, ok, err := ConfigFromEnv()
+	if err != nil || !ok {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if config.Endpoint != "http://collector:4318/v1/traces" || config.Headers["api-key"] != "s ecret" || config.ServiceName != "aegong-eu" {
+		t.Fatalf("Unexpected config %+v", config)
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc")
+	if _, _, err := ConfigFromEnv(); err == nil {
+		t.Fatal("Unsupported protocol should be rejected")
+	}
+}
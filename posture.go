@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// The organization's risk posture rolls the latest report of every agent in
+// the fleet into one score from 0 to 1, so leadership can see whether the
+// fleet is getting safer. Each agent's exposure is its overall risk, scaled
+// down by the share of its findings marked as false positives. Exposures are
+// averaged, weighted by the agent's criticality and by how recently it was
+// audited, so a stale audit of a minor tool counts for little. Criticality is
+// tagged per agent name in the JSON file named by AEGONG_AGENT_CRITICALITY:
+//
+//	{"default": "medium", "agents": {"payments-bot": "critical", "lint-*": "low"}}
+//
+// The history recomputes the posture as of the end of each past day from the
+// reports saved by then.
+
+// Weight of each criticality tag
+var criticalityWeights = map[string]float64{
+	"critical": 4,
+	"high":     2,
+	"medium":   1,
+	"low":      0.5,
+}
+
+// How quickly an audit's weight fades: it halves every 30 days
+const postureHalfLife = 30 * 24 * time.Hour
+
+// Agents listed in a posture, highest contribution first
+const postureTopAgents = 10
+
+// criticalityConfig tags agents by name or glob pattern
+type criticalityConfig struct {
+	Default string            `json:"default"` // Tag of agents not listed; medium when empty
+	Agents  map[string]string `json:"agents"`  // Tag by agent name or path.Match pattern
+}
+
+var agentCriticality = criticalityConfig{Default: "medium"}
+
+// initAgentCriticality loads the criticality tags from the JSON file named
+// by AEGONG_AGENT_CRITICALITY
+func initAgentCriticality() error {
+	path := os.Getenv("AEGONG_AGENT_CRITICALITY")
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read agent criticality: %v", err)
+	}
+	config, err := parseCriticalityConfig(data)
+	if err != nil {
+		return err
+	}
+	agentCriticality = config
+	return nil
+}
+
+func parseCriticalityConfig(data []byte) (criticalityConfig, error) {
+	var config criticalityConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse agent criticality: %v", err)
+	}
+	config.Default = strings.ToLower(config.Default)
+	if config.Default == "" {
+		config.Default = "medium"
+	}
+	if _, ok := criticalityWeights[config.Default]; !ok {
+		return config, fmt.Errorf("unknown default criticality %q; use critical, high, medium or low", config.Default)
+	}
+	for pattern, tag := range config.Agents {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return config, fmt.Errorf("invalid agent pattern %q: %v", pattern, err)
+		}
+		tag = strings.ToLower(tag)
+		if _, ok := criticalityWeights[tag]; !ok {
+			return config, fmt.Errorf("agent %s has unknown criticality %q; use critical, high, medium or low", pattern, tag)
+		}
+		config.Agents[pattern] = tag
+	}
+	return config, nil
+}
+
+// of returns an agent's criticality: its own tag, else the highest tag of
+// the patterns it matches, else the default
+func (c criticalityConfig) of(agent string) string {
+	if tag, ok := c.Agents[agent]; ok {
+		return tag
+	}
+	best := ""
+	for pattern, tag := range c.Agents {
+		if matched, _ := path.Match(pattern, agent); matched && criticalityWeights[tag] > criticalityWeights[best] {
+			best = tag
+		}
+	}
+	if best == "" {
+		return c.Default
+	}
+	return best
+}
+
+// postureAgent is an agent's part in the posture
+type postureAgent struct {
+	AgentName          string    `json:"agent_name"`
+	Hash               string    `json:"hash"` // Of the agent's latest report
+	Criticality        string    `json:"criticality"`
+	AuditedAt          time.Time `json:"audited_at"`
+	Risk               float64   `json:"risk"`     // Overall risk of the latest report
+	Exposure           float64   `json:"exposure"` // Risk left once false positives are set aside
+	UnresolvedFindings int       `json:"unresolved_findings"`
+	ResolvedFindings   int       `json:"resolved_findings"` // Marked as false positives
+	Weight             float64   `json:"weight"`            // Criticality times recency
+	Contribution       float64   `json:"contribution"`      // Share of the score; contributions sum to it
+}
+
+// posturePoint is the posture as of the end of a day
+type posturePoint struct {
+	Date               string  `json:"date"`
+	Score              float64 `json:"score"`
+	RiskLevel          string  `json:"risk_level"`
+	Agents             int     `json:"agents"`
+	UnresolvedFindings int     `json:"unresolved_findings"`
+}
+
+// riskPosture is the fleet's posture now, with its history
+type riskPosture struct {
+	Score              float64        `json:"score"`
+	RiskLevel          string         `json:"risk_level"`
+	Change             float64        `json:"change"` // Score now minus at the start of the history; negative is safer
+	ComputedAt         time.Time      `json:"computed_at"`
+	Agents             int            `json:"agents"`
+	UnresolvedFindings int            `json:"unresolved_findings"`
+	ResolvedFindings   int            `json:"resolved_findings"`
+	Criticality        map[string]int `json:"criticality"` // Agents by criticality tag
+	RiskLevels         map[string]int `json:"risk_levels"` // Agents by the risk level of their exposure
+	TopAgents          []postureAgent `json:"top_agents"`  // Highest contribution first
+	History            []posturePoint `json:"history"`     // Oldest first, one per UTC day
+}
+
+// fleetPosture scores the latest report of each agent saved by asOf
+func fleetPosture(reports []*aegong.AuditReport, marked map[string]map[int]bool, criticality criticalityConfig, asOf time.Time) (float64, []postureAgent) {
+	latest := make(map[string]*aegong.AuditReport)
+	for _, report := range reports {
+		if report.Timestamp.After(asOf) {
+			continue
+		}
+		agent := report.AgentName
+		if agent == "" {
+			agent = report.AgentHash[:8]
+		}
+		if current := latest[agent]; current == nil || report.Timestamp.After(current.Timestamp) {
+			latest[agent] = report
+		}
+	}
+
+	agents := make([]postureAgent, 0, len(latest))
+	totalWeight := 0.0
+	for agent, report := range latest {
+		entry := postureAgent{
+			AgentName:   agent,
+			Hash:        report.AgentHash[:8],
+			Criticality: criticality.of(agent),
+			AuditedAt:   report.Timestamp,
+			Risk:        report.OverallRisk,
+			Exposure:    report.OverallRisk,
+		}
+
+		// Weigh findings by severity so a dismissed LOW barely lowers the exposure
+		var unresolved, total float64
+		for i, threat := range report.Threats {
+			weight := float64(threat.Severity) + 1
+			total += weight
+			if marked[report.AgentHash][i] {
+				entry.ResolvedFindings++
+				continue
+			}
+			entry.UnresolvedFindings++
+			unresolved += weight
+		}
+		if total > 0 {
+			entry.Exposure = report.OverallRisk * unresolved / total
+		}
+
+		age := max(asOf.Sub(report.Timestamp), 0)
+		entry.Weight = criticalityWeights[entry.Criticality] * math.Pow(0.5, float64(age)/float64(postureHalfLife))
+		totalWeight += entry.Weight
+		agents = append(agents, entry)
+	}
+
+	score := 0.0
+	for i := range agents {
+		if totalWeight > 0 {
+			agents[i].Contribution = agents[i].Weight * agents[i].Exposure / totalWeight
+		}
+		score += agents[i].Contribution
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		if agents[i].Contribution != agents[j].Contribution {
+			return agents[i].Contribution > agents[j].Contribution
+		}
+		return agents[i].AgentName < agents[j].AgentName
+	})
+	return score, agents
+}
+
+// computePosture scores the fleet now, with a series of the days up to and
+// including now's
+func computePosture(reports []*aegong.AuditReport, marked map[string]map[int]bool, criticality criticalityConfig, now time.Time, days int) riskPosture {
+	score, agents := fleetPosture(reports, marked, criticality, now)
+	posture := riskPosture{
+		Score:       roundScore(score),
+		RiskLevel:   aegong.RiskLevel(score),
+		ComputedAt:  now,
+		Agents:      len(agents),
+		Criticality: make(map[string]int, len(criticalityWeights)),
+		RiskLevels:  make(map[string]int, len(riskLevels)),
+		TopAgents:   []postureAgent{},
+		History:     make([]posturePoint, days),
+	}
+	for tag := range criticalityWeights {
+		posture.Criticality[tag] = 0
+	}
+	for _, level := range riskLevels {
+		posture.RiskLevels[level] = 0
+	}
+	for i, agent := range agents {
+		posture.UnresolvedFindings += agent.UnresolvedFindings
+		posture.ResolvedFindings += agent.ResolvedFindings
+		posture.Criticality[agent.Criticality]++
+		posture.RiskLevels[aegong.RiskLevel(agent.Exposure)]++
+		if i < postureTopAgents {
+			agent.Exposure, agent.Weight, agent.Contribution = roundScore(agent.Exposure), roundScore(agent.Weight), roundScore(agent.Contribution)
+			posture.TopAgents = append(posture.TopAgents, agent)
+		}
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	for i := range posture.History {
+		day := today.AddDate(0, 0, i-(days-1))
+		asOf := day.Add(24*time.Hour - time.Nanosecond)
+		if asOf.After(now) {
+			asOf = now
+		}
+		score, agents := fleetPosture(reports, marked, criticality, asOf)
+		point := posturePoint{Date: day.Format("2006-01-02"), Score: roundScore(score), RiskLevel: aegong.RiskLevel(score), Agents: len(agents)}
+		for _, agent := range agents {
+			point.UnresolvedFindings += agent.UnresolvedFindings
+		}
+		posture.History[i] = point
+	}
+	posture.Change = roundScore(posture.Score - posture.History[0].Score)
+	return posture
+}
+
+func roundScore(score float64) float64 {
+	return math.Round(score*1000) / 1000
+}
+
+// postureHandler serves the organization's risk posture; ?days= sets how
+// many days the history covers
+func postureHandler(w http.ResponseWriter, r *http.Request) {
+	days := defaultStatsDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxStatsDays {
+			http.Error(w, "days must be a number from 1 to 365", http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+
+	reports, err := loadReports()
+	if err != nil {
+		http.Error(w, "Error reading reports", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(computePosture(reports, engine.MarkedThreats(), agentCriticality, time.Now(), days))
+}
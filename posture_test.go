@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// TestAgentCriticality tests tagging agents by name and pattern
+func TestAgentCriticality(t *testing.T) {
+	config, err := parseCriticalityConfig([]byte(`{"default":"low","agents":{"payments-bot":"Critical","payments-*":"high","*-bot":"medium"}}`))
+	if err != nil {
+		t.Fatalf("Should parse the tags: %v", err)
+	}
+	for agent, want := range map[string]string{"payments-bot": "critical", "payments-api": "high", "chat-bot": "medium", "linter": "low"} {
+		if got := config.of(agent); got != want {
+			t.Errorf("%s: Should be %s, got %s", agent, want, got)
+		}
+	}
+	if config, _ := parseCriticalityConfig([]byte(`{}`)); config.of("any") != "medium" {
+		t.Fatal("Should default to medium")
+	}
+	for _, invalid := range []string{`{"default":"urgent"}`, `{"agents":{"x":"severe"}}`, `{"agents":{"[":"low"}}`, `not json`} {
+		if _, err := parseCriticalityConfig([]byte(invalid)); err == nil {
+			t.Errorf("%s: Should be refused", invalid)
+		}
+	}
+}
+
+// TestComputePosture tests weighting by criticality, recency and resolved findings, and the history
+func TestComputePosture(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	reports := []*aegong.AuditReport{
+		// payments-bot improved today; only its latest report counts
+		{AgentHash: "aaaaaaaa11", AgentName: "payments-bot", Timestamp: now.AddDate(0, 0, -2), OverallRisk: 0.9},
+		{AgentHash: "aaaaaaaa22", AgentName: "payments-bot", Timestamp: now.Add(-time.Hour), OverallRisk: 0.6, Threats: []aegong.ThreatDetection{
+			{Severity: aegong.HIGH}, {Severity: aegong.LOW},
+		}},
+		{AgentHash: "bbbbbbbb11", AgentName: "linter", Timestamp: now.AddDate(0, 0, -30), OverallRisk: 0.8, Threats: []aegong.ThreatDetection{
+			{Severity: aegong.CRITICAL},
+		}},
+	}
+	marked := map[string]map[int]bool{"aaaaaaaa22": {1: true}}
+	criticality := criticalityConfig{Default: "medium", Agents: map[string]string{"payments-bot": "critical", "linter": "low"}}
+
+	posture := computePosture(reports, marked, criticality, now, 3)
+	if posture.Agents != 2 || posture.UnresolvedFindings != 2 || posture.ResolvedFindings != 1 || posture.Criticality["critical"] != 1 {
+		t.Fatalf("Should count the latest report of two agents, got %+v", posture)
+	}
+	top := posture.TopAgents[0]
+	// The dismissed LOW finding is a quarter of the severity weight
+	if top.AgentName != "payments-bot" || top.Hash != "aaaaaaaa" || top.Exposure != 0.45 || top.UnresolvedFindings != 1 {
+		t.Fatalf("Should scale payments-bot's risk by its unresolved findings, got %+v", top)
+	}
+	// linter weighs 0.5 halved by its 30 day old audit, against payments-bot's 4
+	weight := 4.0 + 0.25
+	if want := roundScore((4*0.45 + 0.25*0.8) / weight); posture.Score != want || posture.RiskLevel != "MEDIUM" {
+		t.Fatalf("Should weigh agents by criticality and recency to %v, got %v (%s)", want, posture.Score, posture.RiskLevel)
+	}
+	if sum := roundScore(posture.TopAgents[0].Contribution + posture.TopAgents[1].Contribution); sum != posture.Score {
+		t.Fatalf("Contributions should add up to the score, got %v", sum)
+	}
+
+	if len(posture.History) != 3 || posture.History[0].Date != "2026-03-08" || posture.History[2].Date != "2026-03-10" {
+		t.Fatalf("Should cover the last 3 days, got %+v", posture.History)
+	}
+	if posture.History[0].Score <= posture.Score || posture.Change >= 0 {
+		t.Fatalf("Should show the posture improving since payments-bot's earlier audit, got %+v", posture.History)
+	}
+	if empty := computePosture(nil, nil, criticality, now, 1); empty.Score != 0 || empty.Agents != 0 || empty.RiskLevel != "MINIMAL" {
+		t.Fatalf("Should score an empty fleet 0, got %+v", empty)
+	}
+}
+
+// TestPostureHandler tests the endpoint over saved reports
+func TestPostureHandler(t *testing.T) {
+	withTestReports(t, &aegong.AuditReport{AgentHash: "abcdef0123", AgentName: "helper", Timestamp: time.Now(), OverallRisk: 0.3})
+
+	w := httptest.NewRecorder()
+	postureHandler(w, httptest.NewRequest("GET", "/api/posture?days=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Should reject an empty history, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	postureHandler(w, httptest.NewRequest("GET", "/api/posture?days=7", nil))
+	var posture riskPosture
+	if err := json.NewDecoder(w.Body).Decode(&posture); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Should serve the posture, got %d (%v)", w.Code, err)
+	}
+	if posture.Agents != 1 || posture.Score != 0.3 || len(posture.History) != 7 || posture.History[6].Agents != 1 {
+		t.Fatalf("Should score the saved report, got %+v", posture)
+	}
+}
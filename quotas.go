@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Quotas keep a shared deployment fair. Each tenant, and every tenant
+// together, may run a number of audits and upload a number of bytes per
+// UTC day, and have a number of audits queued or running at once. Tenants
+// are the names of API tokens and client certificates, or the workspace
+// grouping them; callers without either are counted by address. Requests
+// past a quota get a 429 with Retry-After. Daily usage is saved so a restart
+// doesn't reset it.
+
+// Where daily quota usage is saved
+const quotaUsageFile = "quota_usage.json"
+
+// quotaLimits caps what a tenant, or all of them together, may use; zero
+// is unlimited
+type quotaLimits struct {
+	AuditsPerDay      int   `json:"audits_per_day,omitempty"`
+	UploadBytesPerDay int64 `json:"upload_bytes_per_day,omitempty"`
+	ConcurrentJobs    int   `json:"concurrent_jobs,omitempty"` // Audits queued or running at once
+}
+
+// quotaConfig is the JSON file named by AEGONG_QUOTAS
+type quotaConfig struct {
+	Global  quotaLimits            `json:"global"`
+	Default quotaLimits            `json:"default"` // For tenants without limits of their own
+	Tenants map[string]quotaLimits `json:"tenants"`
+	// Workspaces share one quota between the API tokens and client
+	// certificates named in them
+	Workspaces map[string][]string `json:"workspaces"`
+}
+
+// quotaUsage is what a tenant has used today
+type quotaUsage struct {
+	Day         string `json:"day"` // UTC date, YYYY-MM-DD
+	Audits      int    `json:"audits"`
+	UploadBytes int64  `json:"upload_bytes"`
+	Running     int    `json:"running"` // Audits queued or running now
+}
+
+// quotaStatus is a tenant's limits and usage, as served by the usage endpoints
+type quotaStatus struct {
+	Tenant  string      `json:"tenant"`
+	Limits  quotaLimits `json:"limits"`
+	Usage   quotaUsage  `json:"usage"`
+	ResetAt time.Time   `json:"reset_at"` // When the daily counts start over
+}
+
+// quotaError is a request refused because it would exceed a quota
+type quotaError struct {
+	message    string
+	retryAfter time.Duration
+}
+
+func (e *quotaError) Error() string {
+	return e.message
+}
+
+// quotaManager tracks usage against the configured quotas
+type quotaManager struct {
+	mutex     sync.Mutex
+	config    quotaConfig
+	workspace map[string]string // Workspace of each member
+	usage     map[string]*quotaUsage
+	global    quotaUsage
+	path      string // Where daily usage is saved; empty keeps it in memory
+	now       func() time.Time
+}
+
+func newQuotaManager(config quotaConfig) *quotaManager {
+	q := &quotaManager{
+		config:    config,
+		workspace: make(map[string]string),
+		usage:     make(map[string]*quotaUsage),
+		now:       time.Now,
+	}
+	for name, members := range config.Workspaces {
+		for _, member := range members {
+			q.workspace[member] = name
+		}
+	}
+	return q
+}
+
+// Quotas of the server; unlimited until initQuotas configures them
+var quotas = newQuotaManager(quotaConfig{})
+
+// initQuotas loads the quotas from the JSON file named by AEGONG_QUOTAS and
+// the usage saved today
+func initQuotas() error {
+	path := os.Getenv("AEGONG_QUOTAS")
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read quotas: %v", err)
+	}
+	var config quotaConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse quotas: %v", err)
+	}
+	all := []quotaLimits{config.Global, config.Default}
+	for _, limits := range config.Tenants {
+		all = append(all, limits)
+	}
+	for _, limits := range all {
+		if limits.AuditsPerDay < 0 || limits.UploadBytesPerDay < 0 || limits.ConcurrentJobs < 0 {
+			return fmt.Errorf("quotas must not be negative")
+		}
+	}
+	seen := make(map[string]string)
+	for name, members := range config.Workspaces {
+		for _, member := range members {
+			if other, ok := seen[member]; ok && other != name {
+				return fmt.Errorf("%s is a member of workspaces %s and %s", member, other, name)
+			}
+			seen[member] = name
+		}
+	}
+
+	q := newQuotaManager(config)
+	q.path = quotaUsageFile
+	q.load()
+	quotas = q
+	return nil
+}
+
+type tenantKey struct{}
+
+// withTenant gives ctx the tenant an audit is counted against
+func withTenant(ctx context.Context, tenant string) context.Context {
+	if tenant == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// contextTenant returns the tenant of an audit's context, or ""
+func contextTenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// quotaTenant names the tenant a request is counted against
+func quotaTenant(r *http.Request) string {
+	if principal, ok := requestPrincipal(r); ok {
+		return quotas.tenantOf(principal.Name)
+	}
+	if addr, ok := netPolicy.clientAddr(r); ok {
+		return "anonymous@" + addr.String()
+	}
+	return "anonymous"
+}
+
+// tenantOf returns the workspace a principal belongs to, or the principal
+func (q *quotaManager) tenantOf(name string) string {
+	if workspace, ok := q.workspace[name]; ok {
+		return workspace
+	}
+	return name
+}
+
+// limits returns a tenant's own limits, or the default ones
+func (q *quotaManager) limits(tenant string) quotaLimits {
+	if limits, ok := q.config.Tenants[tenant]; ok {
+		return limits
+	}
+	return q.config.Default
+}
+
+// today returns the current UTC day and when the next one starts
+func (q *quotaManager) today() (string, time.Time) {
+	now := q.now().UTC()
+	return now.Format("2006-01-02"), time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+// rolloverLocked starts the global counts over when the day changes and
+// forgets tenants with nothing running, so callers counted by address don't
+// pile up from one day to the next
+func (q *quotaManager) rolloverLocked(day string) {
+	if q.global.Day == day {
+		return
+	}
+	q.global.Day, q.global.Audits, q.global.UploadBytes = day, 0, 0
+	for tenant, usage := range q.usage {
+		if usage.Day != day && usage.Running == 0 {
+			delete(q.usage, tenant)
+		}
+	}
+}
+
+// usageLocked returns a tenant's usage, starting the day's counts over if
+// they are from an earlier day
+func (q *quotaManager) usageLocked(tenant string) *quotaUsage {
+	day, _ := q.today()
+	q.rolloverLocked(day)
+	usage, ok := q.usage[tenant]
+	if !ok {
+		usage = &quotaUsage{Day: day}
+		q.usage[tenant] = usage
+	}
+	if usage.Day != day {
+		usage.Day, usage.Audits, usage.UploadBytes = day, 0, 0
+	}
+	return usage
+}
+
+// admitAudit counts an audit against a tenant's quotas, or refuses it. The
+// returned release must be called once the audit has finished.
+func (q *quotaManager) admitAudit(tenant string) (func(), error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	usage := q.usageLocked(tenant)
+	_, reset := q.today()
+
+	for _, check := range []struct {
+		scope  string
+		limits quotaLimits
+		usage  *quotaUsage
+	}{{tenant, q.limits(tenant), usage}, {"all tenants", q.config.Global, &q.global}} {
+		if limit := check.limits.ConcurrentJobs; limit > 0 && check.usage.Running >= limit {
+			return nil, &quotaError{fmt.Sprintf("Quota of %d concurrent audits for %s reached, try again once one finishes", limit, check.scope), 30 * time.Second}
+		}
+		if limit := check.limits.AuditsPerDay; limit > 0 && check.usage.Audits >= limit {
+			return nil, &quotaError{fmt.Sprintf("Daily quota of %d audits for %s reached", limit, check.scope), reset.Sub(q.now())}
+		}
+	}
+
+	usage.Audits++
+	q.global.Audits++
+	q.saveLocked()
+	return q.runningLocked(usage), nil
+}
+
+// resumeAudit counts an audit restored after a restart as running against a
+// tenant's quotas. It is never refused, even past the tenant's quota of
+// concurrent audits, as it was admitted before the restart; its daily count
+// was saved then.
+func (q *quotaManager) resumeAudit(tenant string) func() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.runningLocked(q.usageLocked(tenant))
+}
+
+// runningLocked counts an audit as running and returns the function that
+// frees its place
+func (q *quotaManager) runningLocked(usage *quotaUsage) func() {
+	usage.Running++
+	q.global.Running++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mutex.Lock()
+			defer q.mutex.Unlock()
+			usage.Running--
+			q.global.Running--
+		})
+	}
+}
+
+// addUpload counts uploaded bytes against a tenant's quotas, or refuses them
+func (q *quotaManager) addUpload(tenant string, size int64) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	usage := q.usageLocked(tenant)
+	_, reset := q.today()
+
+	for _, check := range []struct {
+		scope string
+		limit int64
+		usage *quotaUsage
+	}{{tenant, q.limits(tenant).UploadBytesPerDay, usage}, {"all tenants", q.config.Global.UploadBytesPerDay, &q.global}} {
+		if check.limit > 0 && check.usage.UploadBytes+size > check.limit {
+			return &quotaError{fmt.Sprintf("Daily upload quota of %d bytes for %s would be exceeded, %d bytes left", check.limit, check.scope, max(check.limit-check.usage.UploadBytes, 0)), reset.Sub(q.now())}
+		}
+	}
+
+	usage.UploadBytes += size
+	q.global.UploadBytes += size
+	q.saveLocked()
+	return nil
+}
+
+// status returns a tenant's limits and usage; the global ones for tenant ""
+func (q *quotaManager) status(tenant string) quotaStatus {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	_, reset := q.today()
+	if tenant == "" {
+		day, _ := q.today()
+		q.rolloverLocked(day)
+		return quotaStatus{Tenant: "*", Limits: q.config.Global, Usage: q.global, ResetAt: reset}
+	}
+	return quotaStatus{Tenant: tenant, Limits: q.limits(tenant), Usage: *q.usageLocked(tenant), ResetAt: reset}
+}
+
+// tenants lists the tenants with quotas of their own or usage today
+func (q *quotaManager) tenants() []string {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	seen := make(map[string]bool)
+	for tenant := range q.config.Tenants {
+		seen[tenant] = true
+	}
+	for name := range q.config.Workspaces {
+		seen[name] = true
+	}
+	day, _ := q.today()
+	for tenant, usage := range q.usage {
+		if usage.Day == day && (usage.Audits > 0 || usage.UploadBytes > 0) || usage.Running > 0 {
+			seen[tenant] = true
+		}
+	}
+	var tenants []string
+	for tenant := range seen {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// savedQuotaUsage is the usage file
+type savedQuotaUsage struct {
+	Global  quotaUsage             `json:"global"`
+	Tenants map[string]*quotaUsage `json:"tenants"`
+}
+
+// saveLocked writes today's usage; audits in progress are not saved, as
+// they don't survive a restart
+func (q *quotaManager) saveLocked() {
+	if q.path == "" {
+		return
+	}
+	saved := savedQuotaUsage{Global: q.global, Tenants: make(map[string]*quotaUsage)}
+	saved.Global.Running = 0
+	for tenant, usage := range q.usage {
+		if usage.Day == q.global.Day {
+			copied := *usage
+			copied.Running = 0
+			saved.Tenants[tenant] = &copied
+		}
+	}
+	data, _ := json.Marshal(saved)
+	if err := os.WriteFile(q.path, data, 0600); err != nil {
+		log.Printf("Warning: Failed to save quota usage: %v", err)
+	}
+}
+
+// load reads the usage saved by the last server, if it is from today
+func (q *quotaManager) load() {
+	data, err := os.ReadFile(q.path)
+	if os.IsNotExist(err) {
+		return
+	}
+	var saved savedQuotaUsage
+	if err == nil {
+		err = json.Unmarshal(data, &saved)
+	}
+	if err != nil {
+		log.Printf("Warning: Ignoring unreadable quota usage %s: %v", q.path, err)
+		return
+	}
+	day, _ := q.today()
+	if saved.Global.Day != day {
+		return
+	}
+	q.global = saved.Global
+	for tenant, usage := range saved.Tenants {
+		if usage.Day == day {
+			q.usage[tenant] = usage
+		}
+	}
+}
+
+// writeQuotaError writes a 429 for a quota error and reports whether it
+// wrote anything
+func writeQuotaError(w http.ResponseWriter, err error) bool {
+	if quotaErr, ok := err.(*quotaError); ok {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(quotaErr.retryAfter.Seconds()+0.999)))
+		http.Error(w, quotaErr.Error(), http.StatusTooManyRequests)
+		return true
+	}
+	return false
+}
+
+// admitRequestAudit counts an audit against the caller's quotas, writing a
+// 429 if it would exceed one. The audit's options get the tenant and the
+// function that frees its place.
+func admitRequestAudit(w http.ResponseWriter, r *http.Request, opts *auditOptions) bool {
+	tenant := quotaTenant(r)
+	release, err := quotas.admitAudit(tenant)
+	if err != nil {
+		writeQuotaError(w, err)
+		return false
+	}
+	opts.Tenant = tenant
+	opts.Release = release
+	return true
+}
+
+// chargeRequestUpload counts uploaded bytes against the caller's quotas,
+// writing a 429 if they would exceed one
+func chargeRequestUpload(w http.ResponseWriter, r *http.Request, size int64) bool {
+	if err := quotas.addUpload(quotaTenant(r), size); err != nil {
+		writeQuotaError(w, err)
+		return false
+	}
+	return true
+}
+
+// quotaHandler returns the caller's quotas and usage, and the global ones
+func quotaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]quotaStatus{
+		"tenant": quotas.status(quotaTenant(r)),
+		"global": quotas.status(""),
+	})
+}
+
+// adminQuotasHandler returns the quotas and usage of every tenant with
+// quotas of their own or usage today
+func adminQuotasHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireRole(w, r, RoleAdmin, RoleAuditor); !ok {
+		return
+	}
+	statuses := []quotaStatus{}
+	for _, tenant := range quotas.tenants() {
+		statuses = append(statuses, quotas.status(tenant))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"global":  quotas.status(""),
+		"tenants": statuses,
+	})
+}
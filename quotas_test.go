@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// withTestQuotas configures quotas for the test, counted from a fixed time
+func withTestQuotas(t *testing.T, config quotaConfig, now *time.Time) *quotaManager {
+	old := quotas
+	t.Cleanup(func() { quotas = old })
+	quotas = newQuotaManager(config)
+	quotas.now = func() time.Time { return *now }
+	return quotas
+}
+
+// TestQuotaManager tests daily audit and upload limits, concurrency and the daily reset
+func TestQuotaManager(t *testing.T) {
+	now := time.Date(2026, 3, 14, 23, 0, 0, 0, time.UTC)
+	q := withTestQuotas(t, quotaConfig{
+		Global:  quotaLimits{AuditsPerDay: 3},
+		Default: quotaLimits{AuditsPerDay: 2, UploadBytesPerDay: 100, ConcurrentJobs: 1},
+		Tenants: map[string]quotaLimits{"ci": {}},
+	}, &now)
+
+	release, err := q.admitAudit("alice")
+	if err != nil {
+		t.Fatalf("Should admit the first audit: %v", err)
+	}
+	_, err = q.admitAudit("alice")
+	var quotaErr *quotaError
+	if !errors.As(err, &quotaErr) || quotaErr.retryAfter != 30*time.Second {
+		t.Fatalf("Should refuse a second concurrent audit for 30 seconds, got %v", err)
+	}
+	release()
+	release()
+	if usage := q.status("alice").Usage; usage.Running != 0 || usage.Audits != 1 {
+		t.Fatalf("Should count one finished audit after a double release, got %+v", usage)
+	}
+
+	release, _ = q.admitAudit("alice")
+	release()
+	_, err = q.admitAudit("alice")
+	if !errors.As(err, &quotaErr) || quotaErr.retryAfter != time.Hour || !strings.Contains(err.Error(), "2 audits for alice") {
+		t.Fatalf("Should refuse a third audit until midnight UTC, got %v", err)
+	}
+
+	// ci has no limits of its own, but all tenants share the global one
+	if _, err := q.admitAudit("ci"); err != nil {
+		t.Fatalf("Should admit an audit for an unlimited tenant: %v", err)
+	}
+	if _, err := q.admitAudit("ci"); err == nil || !strings.Contains(err.Error(), "all tenants") {
+		t.Fatalf("Should refuse audits past the global quota, got %v", err)
+	}
+
+	if err := q.addUpload("alice", 60); err != nil {
+		t.Fatalf("Should accept an upload within the quota: %v", err)
+	}
+	if err := q.addUpload("alice", 60); err == nil || !strings.Contains(err.Error(), "40 bytes left") {
+		t.Fatalf("Should refuse an upload past the quota, got %v", err)
+	}
+
+	if err := q.addUpload("anonymous@192.0.2.1", 10); err != nil {
+		t.Fatalf("Should accept an anonymous upload: %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := q.admitAudit("alice"); err != nil {
+		t.Fatalf("Should reset daily quotas at midnight UTC: %v", err)
+	}
+	if _, ok := q.usage["anonymous@192.0.2.1"]; ok {
+		t.Error("Should forget yesterday's tenants with nothing running")
+	}
+	if usage, ok := q.usage["ci"]; !ok || usage.Running != 1 {
+		t.Errorf("Should keep tenants with audits still running, got %+v", usage)
+	}
+	if usage := q.status("alice").Usage; usage.Day != "2026-03-15" || usage.Audits != 1 || usage.UploadBytes != 0 || usage.Running != 1 {
+		t.Fatalf("Should start the day's counts over, keeping running audits, got %+v", usage)
+	}
+	if global := q.status(""); global.Tenant != "*" || global.Usage.Audits != 1 || global.Limits.AuditsPerDay != 3 {
+		t.Fatalf("Should report the global quota, got %+v", global)
+	}
+}
+
+// TestQuotaUsageSaved tests that daily usage survives a restart, but not into the next day
+func TestQuotaUsageSaved(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	q := withTestQuotas(t, quotaConfig{}, &now)
+	q.path = filepath.Join(dir, quotaUsageFile)
+	q.admitAudit("alice")
+	q.addUpload("alice", 42)
+
+	restarted := newQuotaManager(quotaConfig{})
+	restarted.path = q.path
+	restarted.now = q.now
+	restarted.load()
+	if usage := restarted.status("alice").Usage; usage.Audits != 1 || usage.UploadBytes != 42 || usage.Running != 0 {
+		t.Fatalf("Should reload today's usage without running audits, got %+v", usage)
+	}
+
+	now = now.Add(24 * time.Hour)
+	tomorrow := newQuotaManager(quotaConfig{})
+	tomorrow.path = q.path
+	tomorrow.now = q.now
+	tomorrow.load()
+	if usage := tomorrow.status("alice").Usage; usage.Audits != 0 {
+		t.Fatalf("Should not reload yesterday's usage, got %+v", usage)
+	}
+}
+
+// TestInitQuotas tests that the quota file is validated
+func TestInitQuotas(t *testing.T) {
+	withTestUpload(t)
+	old := quotas
+	t.Cleanup(func() { quotas = old })
+
+	for _, c := range []struct {
+		config string
+		valid  bool
+	}{
+		{`{"default":{"audits_per_day":10},"workspaces":{"team":["alice","bob"]}}`, true},
+		{`{"tenants":{"alice":{"concurrent_jobs":-1}}}`, false},
+		{`{"workspaces":{"red":["alice"],"blue":["alice"]}}`, false},
+		{`not json`, false},
+	} {
+		os.WriteFile("quotas.json", []byte(c.config), 0644)
+		t.Setenv("AEGONG_QUOTAS", "quotas.json")
+		if err := initQuotas(); (err == nil) != c.valid {
+			t.Errorf("%s: Should be valid: %v, got %v", c.config, c.valid, err)
+		}
+	}
+}
+
+// TestQuotaTenant tests that callers are counted by workspace, principal or address
+func TestQuotaTenant(t *testing.T) {
+	oldTokens := apiTokens
+	t.Cleanup(func() { apiTokens = oldTokens })
+	apiTokens, _ = loadAPITokens("alice:admin:admin,ci:auditor:audit,bob:viewer:view")
+	now := time.Now()
+	withTestQuotas(t, quotaConfig{Workspaces: map[string][]string{"build": {"ci", "bob"}}}, &now)
+
+	for token, want := range map[string]string{"admin": "alice", "audit": "build", "view": "build", "": "anonymous@192.0.2.1"} {
+		r := httptest.NewRequest("GET", "/api/quota", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		if got := quotaTenant(r); got != want {
+			t.Errorf("Token %q: Should be counted against %s, got %s", token, want, got)
+		}
+	}
+}
+
+// TestQuotaEndpoints tests 429 responses and the usage endpoints
+func TestQuotaEndpoints(t *testing.T) {
+	withTestUpload(t)
+	oldTokens, oldJobs, oldSlots := apiTokens, jobs, auditSlots
+	t.Cleanup(func() { apiTokens, jobs, auditSlots = oldTokens, oldJobs, oldSlots })
+	apiTokens, _ = loadAPITokens("alice:admin:admin,ci:auditor:audit")
+	auditSlots = newAuditExecutor(4, 4)
+	now := time.Now()
+	q := withTestQuotas(t, quotaConfig{Default: quotaLimits{UploadBytesPerDay: 50, ConcurrentJobs: 1}}, &now)
+
+	jobs = newJobStore(context.Background())
+	finish := make(chan struct{})
+	jobs.audit = func(ctx context.Context, filename string, opts auditOptions) (*aegong.AuditReport, error) {
+		<-finish
+		return &aegong.AuditReport{AgentHash: "abc123"}, nil
+	}
+	submit := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/api/jobs", strings.NewReader(`{"filename":"agent.py"}`))
+		r.Header.Set("Authorization", "Bearer audit")
+		createJobHandler(w, r)
+		return w
+	}
+	if w := submit(); w.Code != http.StatusAccepted {
+		t.Fatalf("Should accept the first job, got %d: %s", w.Code, w.Body)
+	}
+	w := submit()
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Fatalf("Should refuse a second concurrent job with 429, got %d (Retry-After %q)", w.Code, w.Header().Get("Retry-After"))
+	}
+	close(finish)
+	deadline := time.Now().Add(5 * time.Second)
+	for q.status("ci").Usage.Running != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if usage := q.status("ci").Usage; usage.Running != 0 || usage.Audits != 1 {
+		t.Fatalf("Should free the job's place once it finishes, got %+v", usage)
+	}
+
+	upload := func(data string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := uploadRequest(t, "agent.py", []byte(data))
+		r.Header.Set("Authorization", "Bearer audit")
+		uploadHandler(w, r)
+		return w
+	}
+	if w := upload("print('hi')\n"); w.Code != http.StatusOK {
+		t.Fatalf("Should accept an upload within the quota, got %d: %s", w.Code, w.Body)
+	}
+	if w := upload(strings.Repeat("#", 64)); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("Should refuse an upload past the quota with 429, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/quota", nil)
+	r.Header.Set("Authorization", "Bearer audit")
+	quotaHandler(w, r)
+	var own map[string]quotaStatus
+	json.NewDecoder(w.Body).Decode(&own)
+	if own["tenant"].Tenant != "ci" || own["tenant"].Usage.UploadBytes != 12 || own["tenant"].Limits.ConcurrentJobs != 1 || own["global"].Usage.Audits != 1 {
+		t.Fatalf("Should report the caller's usage and the global usage, got %+v", own)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/admin/quotas", nil)
+	adminQuotasHandler(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Should require a token to list every tenant, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	r.Header.Set("Authorization", "Bearer admin")
+	adminQuotasHandler(w, r)
+	var all struct {
+		Tenants []quotaStatus `json:"tenants"`
+	}
+	json.NewDecoder(w.Body).Decode(&all)
+	if len(all.Tenants) != 1 || all.Tenants[0].Tenant != "ci" {
+		t.Fatalf("Should list the tenants with usage today, got %+v", all.Tenants)
+	}
+}
+
+// TestQuotaRestoredJobs tests that jobs restored after a restart count
+// against their tenant's concurrent audits, even past the quota
+func TestQuotaRestoredJobs(t *testing.T) {
+	withTestUpload(t)
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	q := withTestQuotas(t, quotaConfig{Default: quotaLimits{ConcurrentJobs: 1}}, &now)
+	oldJobs := jobs
+	t.Cleanup(func() { jobs = oldJobs })
+
+	// The last server left two queued jobs of alice, one past her quota
+	previous := newJobStore(context.Background())
+	previous.dir = "audit_jobs"
+	for _, id := range []string{"first", "second"} {
+		previous.saveLocked(&auditJob{ID: id, Filename: "agent.py", Status: jobQueued, CreatedAt: now, options: jobOptions{Tenant: "alice"}})
+	}
+
+	proceed := make(chan struct{})
+	jobs = newJobStore(context.Background())
+	jobs.dir = "audit_jobs"
+	jobs.audit = func(ctx context.Context, filename string, opts auditOptions) (*aegong.AuditReport, error) {
+		<-proceed
+		return &aegong.AuditReport{AgentHash: "def456"}, nil
+	}
+	jobs.restore()
+
+	if usage := q.status("alice").Usage; usage.Running != 2 {
+		t.Fatalf("Restored jobs should all count as running, got %+v", usage)
+	}
+	if _, err := q.admitAudit("alice"); err == nil {
+		t.Fatal("Should refuse new audits while restored jobs fill the quota")
+	}
+
+	close(proceed)
+	jobs.wait(context.Background())
+	if usage := q.status("alice").Usage; usage.Running != 0 {
+		t.Fatalf("Finished restored jobs should free their places, got %+v", usage)
+	}
+	if _, err := q.admitAudit("alice"); err != nil {
+		t.Fatalf("Should admit audits once restored jobs finish: %v", err)
+	}
+}
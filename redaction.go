@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// redactionProfile says what to strip from a report before sharing it outside
+// the organisation
+type redactionProfile struct {
+	StripEvidence  bool     `json:"strip_evidence"`   // Replace evidence snippets with a count
+	StripDetails   bool     `json:"strip_details"`    // Drop threat details and SHIELD results
+	StripPaths     bool     `json:"strip_paths"`      // Mask file paths in every remaining string
+	StripAgentName bool     `json:"strip_agent_name"` // Hide the uploaded file name
+	RemoveFields   []string `json:"remove_fields"`    // Top-level report fields to drop
+}
+
+// Internal metadata no external reader needs
+var internalReportFields = []string{"cache", "coverage", "engine", "validation_override", "details", "duration_ms", "runtime"}
+
+// Built-in profiles; AEGONG_REDACTION_PROFILES can add to or replace them
+var redactionProfiles = map[string]redactionProfile{
+	// For the agent's vendor, who needs the evidence to fix the findings
+	"vendor": {StripPaths: true, RemoveFields: internalReportFields},
+	// For customers, who only need the verdict and what to do about it
+	"customer": {
+		StripEvidence:  true,
+		StripDetails:   true,
+		StripPaths:     true,
+		StripAgentName: true,
+		RemoveFields:   append([]string{"source", "validation", "signature", "manifest", "soak", "clock"}, internalReportFields...),
+	},
+}
+
+// Profile used when an export names none
+const defaultRedactionProfile = "customer"
+
+// initRedactionProfiles loads extra redaction profiles from the JSON file
+// named by AEGONG_REDACTION_PROFILES, a map of profile name to profile
+func initRedactionProfiles() error {
+	path := os.Getenv("AEGONG_REDACTION_PROFILES")
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read redaction profiles: %v", err)
+	}
+	var profiles map[string]redactionProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("failed to parse redaction profiles: %v", err)
+	}
+	for name, profile := range profiles {
+		redactionProfiles[name] = profile
+	}
+	return nil
+}
+
+// Absolute Unix and Windows file paths. URLs are left alone: the character
+// before a Unix path may not be part of a URL.
+var filePathPattern = regexp.MustCompile(`(^|[\s"'=(,\[])(/[\w.@+-]+){2,}/?|\b[A-Za-z]:\\[^\s"']+`)
+
+// maskPaths replaces the file paths in s
+func maskPaths(s string) string {
+	return filePathPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if match[0] == '/' || (len(match) > 1 && match[1] == ':') {
+			return "[path]"
+		}
+		// Keep the character the path followed
+		return match[:1] + "[path]"
+	})
+}
+
+// maskAllPaths masks paths in every string of a decoded JSON value
+func maskAllPaths(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return maskPaths(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = maskAllPaths(v[i])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = maskAllPaths(v[key])
+		}
+	}
+	return value
+}
+
+// redactReport applies a profile to a report as stored, returning the
+// shareable report
+func redactReport(data []byte, name string, profile redactionProfile, now time.Time) (map[string]interface{}, error) {
+	var report map[string]interface{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse report: %v", err)
+	}
+
+	for _, field := range profile.RemoveFields {
+		delete(report, field)
+	}
+	if profile.StripAgentName {
+		report["agent_name"] = "redacted"
+	}
+
+	threats, _ := report["threats"].([]interface{})
+	for _, entry := range threats {
+		threat, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if profile.StripEvidence {
+			evidence, _ := threat["evidence"].([]interface{})
+			threat["evidence"] = []string{fmt.Sprintf("%d evidence items redacted", len(evidence))}
+		}
+		if profile.StripDetails {
+			delete(threat, "details")
+		}
+	}
+
+	// Keep only whether each SHIELD module passed
+	if results, ok := report["shield_results"].(map[string]interface{}); ok && profile.StripDetails {
+		for module, result := range results {
+			valid := false
+			if fields, ok := result.(map[string]interface{}); ok {
+				valid, _ = fields["valid"].(bool)
+			}
+			results[module] = map[string]interface{}{"valid": valid}
+		}
+	}
+
+	if profile.StripPaths {
+		maskAllPaths(report)
+	}
+
+	report["redaction"] = map[string]interface{}{
+		"profile":     name,
+		"redacted_at": now,
+	}
+	return report, nil
+}
+
+// exportReportHandler serves a report redacted with the ?profile= profile
+func exportReportHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("profile")
+	if name == "" {
+		name = defaultRedactionProfile
+	}
+	profile, ok := redactionProfiles[name]
+	if !ok {
+		var names []string
+		for known := range redactionProfiles {
+			names = append(names, known)
+		}
+		sort.Strings(names)
+		http.Error(w, fmt.Sprintf("Unknown redaction profile %q, expected one of %v", name, names), http.StatusBadRequest)
+		return
+	}
+
+	hash := mux.Vars(r)["hash"]
+	reportPath, err := reportFile(hash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := readStored(reportPath)
+	if os.IsNotExist(err) {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read report: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	report, err := redactReport(data, name, profile, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"aegong_report_%s.json\"", safeFilename(hash+"_"+name)))
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// TestMaskPaths tests that file paths are masked and URLs left alone
+func TestMaskPaths(t *testing.T) {
+	cases := map[string]string{
+		"Denied openat of /etc/shadow: permission denied": "Denied openat of [path]: permission denied",
+		`open("/tmp/aegong/agent.py", 0x241)`:             `open("[path]", 0x241)`,
+		`Loaded C:\Users\build\agent.dll`:                 "Loaded [path]",
+		"Request to https://api.example.com/v1/chat":      "Request to https://api.example.com/v1/chat",
+		"/home/ci/agent.bin":                              "[path]",
+		"ratio 3/4 of calls":                              "ratio 3/4 of calls",
+	}
+	for input, want := range cases {
+		if got := maskPaths(input); got != want {
+			t.Errorf("maskPaths(%q) should be %q, got %q", input, want, got)
+		}
+	}
+}
+
+func testReportJSON(t *testing.T) []byte {
+	report := aegong.AuditReport{
+		AgentHash: "abcdef0123456789",
+		AgentName: "1700000000_internal-agent.py",
+		Threats: []aegong.ThreatDetection{{
+			Vector:   aegong.T4_UNAUTHORIZED_ACTION,
+			Evidence: []string{"Denied openat of /etc/shadow: permission denied", "Spawned a process: ['curl']"},
+			Details:  map[string]interface{}{"analysis": "sandbox_denials"},
+		}},
+		ShieldResults: map[string]interface{}{"integrity": map[string]interface{}{"valid": true, "results": []string{"/opt/aegong/checksums"}}},
+		RiskLevel:     "HIGH",
+		Engine:        &aegong.EngineVersion{Version: "v1.4.0"},
+		DurationMS:    1200,
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// TestRedactReport tests the built-in customer and vendor profiles
+func TestRedactReport(t *testing.T) {
+	now := time.Now()
+	customer, err := redactReport(testReportJSON(t), "customer", redactionProfiles["customer"], now)
+	if err != nil {
+		t.Fatalf("Redaction failed: %v", err)
+	}
+	if customer["agent_name"] != "redacted" || customer["engine"] != nil || customer["duration_ms"] != nil {
+		t.Errorf("Customer export should hide the agent name and internal metadata, got %v", customer)
+	}
+	threat := customer["threats"].([]interface{})[0].(map[string]interface{})
+	if evidence := threat["evidence"].([]string); len(evidence) != 1 || evidence[0] != "2 evidence items redacted" {
+		t.Errorf("Customer export should count evidence instead of quoting it, got %v", threat["evidence"])
+	}
+	if threat["details"] != nil || threat["vector"] == nil {
+		t.Errorf("Customer export should drop details but keep the vector, got %v", threat)
+	}
+	integrity := customer["shield_results"].(map[string]interface{})["integrity"].(map[string]interface{})
+	if integrity["valid"] != true || len(integrity) != 1 {
+		t.Errorf("Customer export should keep only SHIELD verdicts, got %v", integrity)
+	}
+	if customer["redaction"].(map[string]interface{})["profile"] != "customer" {
+		t.Errorf("Export should record its profile, got %v", customer["redaction"])
+	}
+
+	vendor, err := redactReport(testReportJSON(t), "vendor", redactionProfiles["vendor"], now)
+	if err != nil {
+		t.Fatalf("Redaction failed: %v", err)
+	}
+	evidence := vendor["threats"].([]interface{})[0].(map[string]interface{})["evidence"].([]interface{})
+	if len(evidence) != 2 || evidence[0] != "Denied openat of [path]: permission denied" || evidence[1] != "Spawned a process: ['curl']" {
+		t.Errorf("Vendor export should keep evidence with paths masked, got %v", evidence)
+	}
+	if vendor["agent_name"] != "1700000000_internal-agent.py" || vendor["engine"] != nil {
+		t.Errorf("Vendor export should keep the agent name and drop internal metadata, got %v", vendor)
+	}
+}
+
+// TestExportReportHandler tests profile selection and custom profiles
+func TestExportReportHandler(t *testing.T) {
+	withTestUpload(t)
+	os.MkdirAll("reports", 0755)
+	if err := writeStored(filepath.Join("reports", "report_abcdef01.json"), testReportJSON(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	profiles := filepath.Join(t.TempDir(), "profiles.json")
+	os.WriteFile(profiles, []byte(`{"auditor": {"remove_fields": ["shield_results"]}}`), 0644)
+	t.Setenv("AEGONG_REDACTION_PROFILES", profiles)
+	if err := initRedactionProfiles(); err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+	t.Cleanup(func() { delete(redactionProfiles, "auditor") })
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/report/{hash}/export", exportReportHandler)
+	get := func(url string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+		return recorder
+	}
+
+	recorder := get("/api/report/abcdef01/export")
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"profile":"customer"`) {
+		t.Fatalf("Should export with the customer profile by default, got %d %s", recorder.Code, recorder.Body)
+	}
+	if !strings.Contains(recorder.Header().Get("Content-Disposition"), "aegong_report_abcdef01_customer.json") {
+		t.Errorf("Should name the download, got %q", recorder.Header().Get("Content-Disposition"))
+	}
+
+	recorder = get("/api/report/abcdef01/export?profile=auditor")
+	if recorder.Code != http.StatusOK || strings.Contains(recorder.Body.String(), "shield_results") || !strings.Contains(recorder.Body.String(), "/etc/shadow") {
+		t.Errorf("Custom profile should only drop SHIELD results, got %d %s", recorder.Code, recorder.Body)
+	}
+
+	if recorder := get("/api/report/abcdef01/export?profile=press"); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Unknown profiles should be rejected, got %d", recorder.Code)
+	}
+	if recorder := get("/api/report/00000000/export"); recorder.Code != http.StatusNotFound {
+		t.Errorf("Missing reports should be 404, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// The agent registry tracks the agents the organization knows about, whether
+// or not they have been audited: who owns each one, where it runs, how far
+// along its lifecycle it is and how often it must be audited again. Agents
+// are matched to reports by name; the registry follows completed audits on
+// the bus to keep each agent's latest audit current. Approved and deployed
+// agents whose re-audit interval has passed since their latest audit are
+// overdue, and each one raises a status warning, which notification rules
+// can route to the agent's owner.
+
+// Where the registry is kept
+var agentRegistryPath = filepath.Join("reports", "agent_registry.json")
+
+const maxRegisteredAgents = 1000
+
+// Lifecycle states of a registered agent
+const (
+	agentProposed = "proposed"
+	agentApproved = "approved"
+	agentDeployed = "deployed"
+	agentRetired  = "retired"
+)
+
+// States an agent may move to from each state; retired agents stay retired
+var agentTransitions = map[string][]string{
+	agentProposed: {agentApproved, agentRetired},
+	agentApproved: {agentProposed, agentDeployed, agentRetired},
+	agentDeployed: {agentApproved, agentRetired},
+	agentRetired:  {},
+}
+
+// latestAudit summarizes the newest report of a registered agent
+type latestAudit struct {
+	Hash        string    `json:"hash"`
+	Timestamp   time.Time `json:"timestamp"`
+	RiskLevel   string    `json:"risk_level"`
+	OverallRisk float64   `json:"overall_risk"`
+}
+
+// registeredAgent is an agent in the registry
+type registeredAgent struct {
+	ID              string       `json:"id"`
+	Name            string       `json:"name"` // Matches the agent_name of its reports
+	Owner           string       `json:"owner"`
+	Environment     string       `json:"environment,omitempty"`
+	State           string       `json:"state"`
+	ReauditInterval string       `json:"reaudit_interval,omitempty"` // Like 30d or 720h; empty for none
+	LatestAudit     *latestAudit `json:"latest_audit,omitempty"`
+	CreatedBy       string       `json:"created_by"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedBy       string       `json:"updated_by"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+// agentView is a registered agent as served, with when its next audit is due
+type agentView struct {
+	registeredAgent
+	NextAuditDue *time.Time `json:"next_audit_due,omitempty"` // Only for approved and deployed agents with an interval
+	Overdue      bool       `json:"overdue"`
+}
+
+// agentRequest creates or replaces a registered agent
+type agentRequest struct {
+	Name            string `json:"name"`
+	Owner           string `json:"owner"`
+	Environment     string `json:"environment"`
+	State           string `json:"state"`
+	ReauditInterval string `json:"reaudit_interval"`
+}
+
+// registry holds the registered agents
+type registry struct {
+	mutex  sync.RWMutex
+	agents []registeredAgent
+}
+
+// Agents registered with the server; initAgentRegistry loads them
+var agentRegistry = &registry{agents: []registeredAgent{}}
+
+// initAgentRegistry loads the registry and keeps agents' latest audits
+// current as audits complete
+func initAgentRegistry() error {
+	agents, err := loadAgentRegistry()
+	if err != nil {
+		return err
+	}
+	agentRegistry = &registry{agents: agents}
+	bus.subscribe(agentRegistry.recordAudit, eventAuditCompleted)
+	if len(agents) > 0 {
+		log.Printf("Info: Loaded %d registered agents", len(agents))
+	}
+	return nil
+}
+
+// loadAgentRegistry reads the saved registry
+func loadAgentRegistry() ([]registeredAgent, error) {
+	data, err := readStored(agentRegistryPath)
+	if os.IsNotExist(err) {
+		return []registeredAgent{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var agents []registeredAgent
+	if err := json.Unmarshal(data, &agents); err != nil {
+		return nil, fmt.Errorf("failed to parse the agent registry: %v", err)
+	}
+	return agents, nil
+}
+
+// save writes the agents to the store and makes them current. The caller
+// must hold the mutex.
+func (reg *registry) save(agents []registeredAgent) error {
+	data, _ := json.MarshalIndent(agents, "", "  ")
+	os.MkdirAll(filepath.Dir(agentRegistryPath), 0755)
+	if err := writeStored(agentRegistryPath, data); err != nil {
+		return err
+	}
+	reg.agents = agents
+	return nil
+}
+
+// recordAudit updates the latest audit of the agent a completed report is of
+func (reg *registry) recordAudit(event busEvent) {
+	report := event.Report
+	if report == nil || report.AgentName == "" {
+		return
+	}
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+	agents := append([]registeredAgent{}, reg.agents...)
+	changed := false
+	for i := range agents {
+		if agents[i].Name == report.AgentName && (agents[i].LatestAudit == nil || !report.Timestamp.Before(agents[i].LatestAudit.Timestamp)) {
+			agents[i].LatestAudit = newLatestAudit(report)
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	if err := reg.save(agents); err != nil {
+		log.Printf("Warning: Failed to record the audit of registered agent %s: %v", report.AgentName, err)
+	}
+}
+
+func newLatestAudit(report *aegong.AuditReport) *latestAudit {
+	return &latestAudit{
+		Hash:        report.AgentHash[:8],
+		Timestamp:   report.Timestamp,
+		RiskLevel:   report.RiskLevel,
+		OverallRisk: report.OverallRisk,
+	}
+}
+
+// findLatestAudit looks through the saved reports for an agent's newest
+func findLatestAudit(name string) (*latestAudit, error) {
+	reports, err := loadReports()
+	if err != nil {
+		return nil, err
+	}
+	var latest *aegong.AuditReport
+	for _, report := range reports {
+		if report.AgentName == name && (latest == nil || report.Timestamp.After(latest.Timestamp)) {
+			latest = report
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+	return newLatestAudit(latest), nil
+}
+
+// view works out when the agent is next due for an audit. An agent that
+// needs audits and has never had one is due from when it was registered.
+func (agent registeredAgent) view(now time.Time) agentView {
+	v := agentView{registeredAgent: agent}
+	if agent.State != agentApproved && agent.State != agentDeployed || agent.ReauditInterval == "" {
+		return v
+	}
+	interval, err := parseMaxAge(agent.ReauditInterval)
+	if err != nil {
+		return v
+	}
+	due := agent.CreatedAt
+	if agent.LatestAudit != nil {
+		due = agent.LatestAudit.Timestamp.Add(interval)
+	}
+	v.NextAuditDue = &due
+	v.Overdue = now.After(due)
+	return v
+}
+
+// overdue lists the agents overdue for an audit, most overdue first
+func (reg *registry) overdue(now time.Time) []agentView {
+	reg.mutex.RLock()
+	defer reg.mutex.RUnlock()
+	var overdue []agentView
+	for _, agent := range reg.agents {
+		if v := agent.view(now); v.Overdue {
+			overdue = append(overdue, v)
+		}
+	}
+	sort.Slice(overdue, func(i, j int) bool {
+		return overdue[i].NextAuditDue.Before(*overdue[j].NextAuditDue)
+	})
+	return overdue
+}
+
+// validate checks an agent request, returning the agent it describes. The
+// state defaults to proposed and may only move along agentTransitions from
+// the current one.
+func (reg *registry) validate(request agentRequest, current *registeredAgent) (registeredAgent, error) {
+	agent := registeredAgent{
+		Name:            strings.TrimSpace(request.Name),
+		Owner:           strings.TrimSpace(request.Owner),
+		Environment:     strings.TrimSpace(request.Environment),
+		State:           strings.ToLower(request.State),
+		ReauditInterval: request.ReauditInterval,
+	}
+	if agent.Name == "" {
+		return agent, fmt.Errorf("a registered agent needs a name")
+	}
+	if agent.Owner == "" {
+		return agent, fmt.Errorf("a registered agent needs an owner")
+	}
+	if agent.State == "" {
+		agent.State = agentProposed
+		if current != nil {
+			agent.State = current.State
+		}
+	}
+	if _, ok := agentTransitions[agent.State]; !ok {
+		return agent, fmt.Errorf("state must be %q, %q, %q or %q, got %q", agentProposed, agentApproved, agentDeployed, agentRetired, agent.State)
+	}
+	if current != nil && agent.State != current.State {
+		allowed := false
+		for _, next := range agentTransitions[current.State] {
+			allowed = allowed || next == agent.State
+		}
+		if !allowed {
+			return agent, fmt.Errorf("a %s agent cannot become %s", current.State, agent.State)
+		}
+	}
+	if agent.ReauditInterval != "" {
+		if interval, err := parseMaxAge(agent.ReauditInterval); err != nil || interval <= 0 {
+			return agent, fmt.Errorf("invalid reaudit_interval %q, expected a positive duration such as 30d", agent.ReauditInterval)
+		}
+	}
+	for _, other := range reg.agents {
+		if other.Name == agent.Name && (current == nil || other.ID != current.ID) {
+			return agent, fmt.Errorf("an agent named %q is already registered", agent.Name)
+		}
+	}
+	return agent, nil
+}
+
+// decodeAgentRequest reads an agent request, writing a 400 if it is not JSON
+func decodeAgentRequest(w http.ResponseWriter, r *http.Request) (agentRequest, bool) {
+	var request agentRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		http.Error(w, "Request body must be JSON with \"name\", \"owner\" and optional \"environment\", \"state\" and \"reaudit_interval\"", http.StatusBadRequest)
+		return request, false
+	}
+	return request, true
+}
+
+// agentsHandler lists the registered agents by name; ?state= keeps those in
+// a lifecycle state and ?overdue=true those overdue for an audit
+func agentsHandler(w http.ResponseWriter, r *http.Request) {
+	state := strings.ToLower(r.URL.Query().Get("state"))
+	if _, ok := agentTransitions[state]; state != "" && !ok {
+		http.Error(w, fmt.Sprintf("state must be %q, %q, %q or %q, got %q", agentProposed, agentApproved, agentDeployed, agentRetired, state), http.StatusBadRequest)
+		return
+	}
+	overdueOnly := false
+	switch r.URL.Query().Get("overdue") {
+	case "":
+	case "true":
+		overdueOnly = true
+	default:
+		http.Error(w, "overdue must be true when given", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	agentRegistry.mutex.RLock()
+	views := []agentView{}
+	for _, agent := range agentRegistry.agents {
+		v := agent.view(now)
+		if state != "" && agent.State != state || overdueOnly && !v.Overdue {
+			continue
+		}
+		views = append(views, v)
+	}
+	agentRegistry.mutex.RUnlock()
+	sort.Slice(views, func(i, j int) bool { return views[i].Name < views[j].Name })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(views)
+}
+
+// agentHandler serves a registered agent
+func agentHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	agentRegistry.mutex.RLock()
+	var found *agentView
+	for _, agent := range agentRegistry.agents {
+		if agent.ID == id {
+			v := agent.view(time.Now())
+			found = &v
+		}
+	}
+	agentRegistry.mutex.RUnlock()
+	if found == nil {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(found)
+}
+
+// createAgentHandler lets admins and auditors register an agent. Its latest
+// audit is found among the saved reports.
+func createAgentHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin, RoleAuditor)
+	if !ok {
+		return
+	}
+	request, ok := decodeAgentRequest(w, r)
+	if !ok {
+		return
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to register agent: %v", err), http.StatusInternalServerError)
+		return
+	}
+	latest, err := findLatestAudit(strings.TrimSpace(request.Name))
+	if err != nil {
+		http.Error(w, "Error reading reports", http.StatusInternalServerError)
+		return
+	}
+
+	agentRegistry.mutex.Lock()
+	agent, err := agentRegistry.validate(request, nil)
+	if err != nil {
+		agentRegistry.mutex.Unlock()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := time.Now().UTC()
+	agent.ID = hex.EncodeToString(id)
+	agent.LatestAudit = latest
+	agent.CreatedBy, agent.CreatedAt = principal.Name, now
+	agent.UpdatedBy, agent.UpdatedAt = principal.Name, now
+	if len(agentRegistry.agents) >= maxRegisteredAgents {
+		err = fmt.Errorf("at most %d agents may be registered", maxRegisteredAgents)
+	} else {
+		err = agentRegistry.save(append(agentRegistry.agents[:len(agentRegistry.agents):len(agentRegistry.agents)], agent))
+	}
+	agentRegistry.mutex.Unlock()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to register agent: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logf(r.Context(), "Agent %s (%s) registered by %s", agent.ID, agent.Name, principal.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(agent.view(now))
+}
+
+// updateAgentHandler lets admins and auditors replace a registered agent's
+// details and move it along its lifecycle
+func updateAgentHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin, RoleAuditor)
+	if !ok {
+		return
+	}
+	request, ok := decodeAgentRequest(w, r)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+
+	agentRegistry.mutex.Lock()
+	defer agentRegistry.mutex.Unlock()
+	agents := append([]registeredAgent{}, agentRegistry.agents...)
+	i := -1
+	for j := range agents {
+		if agents[j].ID == id {
+			i = j
+		}
+	}
+	if i < 0 {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+	current := agents[i]
+	agent, err := agentRegistry.validate(request, &current)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	agent.ID = current.ID
+	agent.LatestAudit = current.LatestAudit
+	agent.CreatedBy, agent.CreatedAt = current.CreatedBy, current.CreatedAt
+	agent.UpdatedBy, agent.UpdatedAt = principal.Name, time.Now().UTC()
+	// A renamed agent's audits are those of its new name
+	if agent.Name != current.Name {
+		if agent.LatestAudit, err = findLatestAudit(agent.Name); err != nil {
+			http.Error(w, "Error reading reports", http.StatusInternalServerError)
+			return
+		}
+	}
+	agents[i] = agent
+	if err := agentRegistry.save(agents); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save agent: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if agent.State != current.State {
+		logf(r.Context(), "Agent %s (%s) moved from %s to %s by %s", agent.ID, agent.Name, current.State, agent.State, principal.Name)
+	} else {
+		logf(r.Context(), "Agent %s (%s) updated by %s", agent.ID, agent.Name, principal.Name)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(agent.view(agent.UpdatedAt))
+}
+
+// deleteAgentHandler lets admins remove an agent from the registry; its
+// reports are kept
+func deleteAgentHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+
+	agentRegistry.mutex.Lock()
+	agents := []registeredAgent{}
+	for _, agent := range agentRegistry.agents {
+		if agent.ID != id {
+			agents = append(agents, agent)
+		}
+	}
+	found := len(agents) < len(agentRegistry.agents)
+	var err error
+	if found {
+		err = agentRegistry.save(agents)
+	}
+	agentRegistry.mutex.Unlock()
+	if !found {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save the agent registry: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logf(r.Context(), "Agent %s removed from the registry by %s", id, principal.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
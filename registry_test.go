@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// TestAgentRegistryAPI tests registering agents and moving them along their lifecycle
+func TestAgentRegistryAPI(t *testing.T) {
+	withTestReports(t,
+		&aegong.AuditReport{AgentHash: "aaaaaaaa11", AgentName: "payments-bot", Timestamp: time.Now().AddDate(0, 0, -40), RiskLevel: "HIGH", OverallRisk: 0.7},
+		&aegong.AuditReport{AgentHash: "aaaaaaaa22", AgentName: "payments-bot", Timestamp: time.Now().AddDate(0, 0, -10), RiskLevel: "LOW", OverallRisk: 0.2},
+	)
+	oldRegistry, oldTokens := agentRegistry, apiTokens
+	t.Cleanup(func() { agentRegistry, apiTokens = oldRegistry, oldTokens })
+	agentRegistry = &registry{agents: []registeredAgent{}}
+	apiTokens, _ = loadAPITokens("alice:admin:admin,ci:auditor:audit,bob:viewer:view")
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/agents", agentsHandler).Methods("GET")
+	router.HandleFunc("/api/agents", createAgentHandler).Methods("POST")
+	router.HandleFunc("/api/agents/{id}", agentHandler).Methods("GET")
+	router.HandleFunc("/api/agents/{id}", updateAgentHandler).Methods("PUT")
+	router.HandleFunc("/api/agents/{id}", deleteAgentHandler).Methods("DELETE")
+	call := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, body := range []string{
+		`{"owner":"payments"}`,
+		`{"name":"x"}`,
+		`{"name":"x","owner":"y","state":"live"}`,
+		`{"name":"x","owner":"y","reaudit_interval":"monthly"}`,
+		`{"name":"x","owner":"y","team":"z"}`,
+	} {
+		if w := call("POST", "/api/agents", "audit", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: Should be rejected, got %d", body, w.Code)
+		}
+	}
+
+	agent := `{"name":"payments-bot","owner":"payments-team","environment":"production","reaudit_interval":"30d"}`
+	if w := call("POST", "/api/agents", "view", agent); w.Code != http.StatusForbidden {
+		t.Fatalf("Should not let viewers register agents, got %d", w.Code)
+	}
+	w := call("POST", "/api/agents", "audit", agent)
+	var created agentView
+	json.NewDecoder(w.Body).Decode(&created)
+	if w.Code != http.StatusCreated || created.ID == "" || created.State != agentProposed || created.CreatedBy != "ci" {
+		t.Fatalf("Should register a proposed agent, got %d: %+v", w.Code, created)
+	}
+	if created.LatestAudit == nil || created.LatestAudit.Hash != "aaaaaaaa" || created.LatestAudit.RiskLevel != "LOW" || created.NextAuditDue != nil {
+		t.Fatalf("Should find the latest audit and not expect audits of proposed agents, got %+v", created)
+	}
+	if w := call("POST", "/api/agents", "audit", agent); w.Code != http.StatusBadRequest {
+		t.Fatalf("Should not register a name twice, got %d", w.Code)
+	}
+
+	// Deploying the agent makes its re-audit due 30 days after its latest audit
+	w = call("PUT", "/api/agents/"+created.ID, "audit", strings.Replace(agent, `"reaudit_interval"`, `"state":"approved","reaudit_interval"`, 1))
+	var updated agentView
+	json.NewDecoder(w.Body).Decode(&updated)
+	if w.Code != http.StatusOK || updated.State != agentApproved || updated.NextAuditDue == nil || updated.Overdue || updated.CreatedBy != "ci" {
+		t.Fatalf("Should approve the agent, got %d: %+v", w.Code, updated)
+	}
+	if due := created.LatestAudit.Timestamp.AddDate(0, 0, 30); !updated.NextAuditDue.Equal(due) {
+		t.Fatalf("Should be due on %v, got %v", due, updated.NextAuditDue)
+	}
+	if w := call("PUT", "/api/agents/missing", "audit", agent); w.Code != http.StatusNotFound {
+		t.Fatalf("Should not update unknown agents, got %d", w.Code)
+	}
+
+	// A shorter interval makes it overdue, which raises a warning
+	w = call("PUT", "/api/agents/"+created.ID, "admin", `{"name":"payments-bot","owner":"payments-team","state":"deployed","reaudit_interval":"7d"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Should deploy the agent, got %d: %s", w.Code, w.Body)
+	}
+	w = call("GET", "/api/agents?overdue=true", "", "")
+	var listed []agentView
+	json.NewDecoder(w.Body).Decode(&listed)
+	if len(listed) != 1 || !listed[0].Overdue || listed[0].Environment != "" || listed[0].UpdatedBy != "alice" {
+		t.Fatalf("Should list the overdue agent, got %+v", listed)
+	}
+	if warnings := strings.Join(collectStatus().Warnings, "\n"); !strings.Contains(warnings, "Agent payments-bot (deployed, owned by payments-team) was due for re-audit") {
+		t.Fatalf("Should warn about the overdue agent, got %s", warnings)
+	}
+
+	// A new audit brings it up to date
+	agentRegistry.recordAudit(busEvent{Type: eventAuditCompleted, Report: &aegong.AuditReport{AgentHash: "aaaaaaaa33", AgentName: "payments-bot", Timestamp: time.Now(), RiskLevel: "MINIMAL"}})
+	if w := call("GET", "/api/agents?overdue=true", "", ""); strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Fatalf("Should not be overdue after an audit, got %s", w.Body)
+	}
+	w = call("GET", "/api/agents/"+created.ID, "", "")
+	var fetched agentView
+	json.NewDecoder(w.Body).Decode(&fetched)
+	if fetched.LatestAudit == nil || fetched.LatestAudit.RiskLevel != "MINIMAL" {
+		t.Fatalf("Should record the new audit, got %+v", fetched)
+	}
+
+	// Retired agents need no audits and stay retired
+	call("PUT", "/api/agents/"+created.ID, "audit", `{"name":"payments-bot","owner":"payments-team","state":"retired"}`)
+	if w := call("PUT", "/api/agents/"+created.ID, "audit", `{"name":"payments-bot","owner":"payments-team","state":"deployed"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("Should not bring back a retired agent, got %d", w.Code)
+	}
+	if w := call("GET", "/api/agents?state=deployed", "", ""); strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Fatalf("Should filter by state, got %s", w.Body)
+	}
+	if w := call("GET", "/api/agents?state=live", "", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("Should reject unknown states, got %d", w.Code)
+	}
+
+	saved, err := loadAgentRegistry()
+	if err != nil || len(saved) != 1 || saved[0].State != agentRetired || saved[0].LatestAudit.RiskLevel != "MINIMAL" {
+		t.Fatalf("Should save the registry, got %+v (%v)", saved, err)
+	}
+	if w := call("DELETE", "/api/agents/"+created.ID, "audit", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("Should only let admins remove agents, got %d", w.Code)
+	}
+	if w := call("DELETE", "/api/agents/"+created.ID, "admin", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Should remove the agent, got %d", w.Code)
+	}
+	if w := call("GET", "/api/agents/"+created.ID, "", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("Should not find a removed agent, got %d", w.Code)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// requestIDHeader carries the correlation ID of a request and its audit
+const requestIDHeader = "X-Request-ID"
+
+// Request IDs accepted from clients; others are replaced with a random one
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// withRequestID gives every request a correlation ID, taken from its
+// X-Request-ID header if it has a usable one, and echoes it in the response.
+// Audits started by the request carry the ID into the engine's logs, the
+// audit log, the report and the events published about them.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = randomID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(aegong.WithCorrelationID(r.Context(), id)))
+	})
+}
+
+// withCorrelationID gives ctx the correlation ID id unless it already has one
+func withCorrelationID(ctx context.Context, id string) context.Context {
+	if aegong.CorrelationID(ctx) != "" {
+		return ctx
+	}
+	return aegong.WithCorrelationID(ctx, id)
+}
+
+// logf logs a line, tagged with the correlation ID of the request or audit
+// ctx belongs to
+func logf(ctx context.Context, format string, args ...interface{}) {
+	if id := aegong.CorrelationID(ctx); id != "" {
+		format += " correlation_id=%s"
+		args = append(args, id)
+	}
+	log.Printf(format, args...)
+}
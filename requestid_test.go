@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// TestWithRequestID tests that requests get a correlation ID and echo it back
+func TestWithRequestID(t *testing.T) {
+	var seen string
+	handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = aegong.CorrelationID(r.Context())
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(requestIDHeader, "ci-build-7")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if seen != "ci-build-7" || w.Header().Get(requestIDHeader) != "ci-build-7" {
+		t.Fatalf("Should keep the client's request ID, got %q and %q", seen, w.Header().Get(requestIDHeader))
+	}
+
+	for _, id := range []string{"", "bad id\n", string(make([]byte, 65))} {
+		r := httptest.NewRequest("GET", "/", nil)
+		if id != "" {
+			r.Header.Set(requestIDHeader, id)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if seen == "" || seen == id || w.Header().Get(requestIDHeader) != seen {
+			t.Fatalf("Request ID %q should be replaced with a random one, got %q", id, seen)
+		}
+	}
+}
+
+// TestAuditEventsCorrelationID tests that audit events carry the request's correlation ID
+func TestAuditEventsCorrelationID(t *testing.T) {
+	b := newEventBus()
+	var events []busEvent
+	b.subscribe(func(event busEvent) { events = append(events, event) })
+
+	ctx := aegong.WithCorrelationID(context.Background(), "req-1")
+	ctx = b.auditStarted(ctx, "a1", "agent.py")
+	b.auditFinished(ctx, "a1", "agent.py", &aegong.AuditReport{}, nil)
+
+	if len(events) != 2 {
+		t.Fatalf("Should publish 2 events, got %d", len(events))
+	}
+	for _, event := range events {
+		if event.CorrelationID != "req-1" {
+			t.Fatalf("%s event should carry the correlation ID, got %q", event.Type, event.CorrelationID)
+		}
+	}
+
+	if withCorrelationID(ctx, "other") != ctx {
+		t.Fatal("Should keep an existing correlation ID")
+	}
+	if aegong.CorrelationID(withCorrelationID(context.Background(), "a2")) != "a2" {
+		t.Fatal("Should fall back to the given ID")
+	}
+}
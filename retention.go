@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// Where purged uploads are recorded by hash once the binary is gone
+const purgedUploadsLog = "reports/purged_uploads.jsonl"
+
+// uploadRetention controls how long raw agent binaries stay in uploads/
+type uploadRetention struct {
+	afterReport bool          // Purge an upload as soon as its report is saved
+	maxAge      time.Duration // Purge uploads older than this; zero keeps them
+}
+
+// Retention policy from AEGONG_UPLOAD_RETENTION
+var retention uploadRetention
+
+// parseRetention reads "keep", "after-report" or a maximum age such as "72h" or "7d"
+func parseRetention(value string) (uploadRetention, error) {
+	switch value {
+	case "", "keep":
+		return uploadRetention{}, nil
+	case "after-report":
+		return uploadRetention{afterReport: true}, nil
+	}
+
+	maxAge, err := parseMaxAge(value)
+	if err != nil {
+		return uploadRetention{}, fmt.Errorf("invalid upload retention %q, expected keep, after-report or a duration", value)
+	}
+	if maxAge <= 0 {
+		return uploadRetention{}, fmt.Errorf("upload retention %q must be positive", value)
+	}
+	return uploadRetention{maxAge: maxAge}, nil
+}
+
+// parseMaxAge reads a duration such as "72h", or a number of days such as "7d"
+func parseMaxAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// How long audit log entries are kept, from AEGONG_AUDIT_LOG_RETENTION;
+// zero keeps them
+var auditLogRetention time.Duration
+
+// initAuditLogRetention loads the audit log retention period from the environment
+func initAuditLogRetention() error {
+	value := os.Getenv("AEGONG_AUDIT_LOG_RETENTION")
+	if value == "" || value == "keep" {
+		return nil
+	}
+	maxAge, err := parseMaxAge(value)
+	if err != nil || maxAge <= 0 {
+		return fmt.Errorf("invalid audit log retention %q, expected keep or a positive duration such as 365d", value)
+	}
+	auditLogRetention = maxAge
+	return nil
+}
+
+// pruneAuditLog removes audit log entries older than the retention period,
+// except those under legal hold
+func pruneAuditLog(auditLog *aegong.AuditLogger, now time.Time) {
+	held, err := auditLogHeld()
+	if err != nil {
+		log.Printf("Warning: Not pruning the audit log, legal holds can't be read: %v", err)
+		return
+	}
+	removed, err := auditLog.Prune(now.Add(-auditLogRetention), held)
+	if err != nil {
+		log.Printf("Warning: Failed to prune the audit log: %v", err)
+		return
+	}
+	if removed > 0 {
+		log.Printf("Pruned %d audit log entries older than %v", removed, auditLogRetention)
+	}
+}
+
+// runAuditLogSweeper prunes the audit log until ctx is done
+func runAuditLogSweeper(ctx context.Context) {
+	if auditLogRetention == 0 || engine.AuditLog() == nil {
+		return
+	}
+
+	ticker := time.NewTicker(min(auditLogRetention/4, time.Hour))
+	defer ticker.Stop()
+
+	pruneAuditLog(engine.AuditLog(), time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			pruneAuditLog(engine.AuditLog(), now)
+		}
+	}
+}
+
+// initUploadRetention loads the retention policy from the environment
+func initUploadRetention() error {
+	policy, err := parseRetention(os.Getenv("AEGONG_UPLOAD_RETENTION"))
+	if err != nil {
+		return err
+	}
+	retention = policy
+	return nil
+}
+
+// uploadTracker counts the audits reading each upload so purges never
+// delete a binary out from under them
+type uploadTracker struct {
+	mutex   sync.Mutex
+	inUse   map[string]int
+	audited map[string]bool // Uploads with a saved report awaiting an after-report purge
+}
+
+var activeUploads = &uploadTracker{inUse: make(map[string]int), audited: make(map[string]bool)}
+
+// useUpload marks an upload as being read by an audit. The returned function
+// releases it, noting whether a report was saved; in after-report mode the
+// last release after a report purges the upload.
+func useUpload(filename string) func(reported bool) {
+	t := activeUploads
+	t.mutex.Lock()
+	t.inUse[filename]++
+	t.mutex.Unlock()
+
+	return func(reported bool) {
+		t.mutex.Lock()
+		if reported {
+			t.audited[filename] = true
+		}
+		t.inUse[filename]--
+		purge := false
+		if t.inUse[filename] == 0 {
+			delete(t.inUse, filename)
+			purge = retention.afterReport && t.audited[filename]
+			delete(t.audited, filename)
+		}
+		t.mutex.Unlock()
+
+		if purge {
+			if err := purgeUpload(filename, "report saved"); err != nil && err != errLegalHold {
+				log.Printf("Warning: Failed to purge upload %s: %v", filename, err)
+			}
+		}
+	}
+}
+
+func (t *uploadTracker) busy(filename string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.inUse[filename] > 0
+}
+
+// purgedUpload is what remains of an upload after its binary is deleted
+type purgedUpload struct {
+	Filename string    `json:"filename"`
+	SHA256   string    `json:"sha256"`
+	Size     int64     `json:"size"`
+	Uploaded time.Time `json:"uploaded_at"`
+	Purged   time.Time `json:"purged_at"`
+	Reason   string    `json:"reason"`
+}
+
+var purgeLogMutex sync.Mutex
+
+// purgeUpload deletes an upload and its signature, keeping only its hash,
+// unless it is under legal hold
+func purgeUpload(filename, reason string) error {
+	filePath, err := uploadPath(filename)
+	if err != nil {
+		return err
+	}
+	// As with reports, holds can't be placed between the check and the removal
+	holdsMutex.Lock()
+	defer holdsMutex.Unlock()
+	if isHeld(aegong.HoldUpload, filename) {
+		return errLegalHold
+	}
+	data, err := readStored(filePath)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil {
+		return err
+	}
+	os.Remove(filePath + signatureBundleSuffix)
+	os.Remove(filePath + agentManifestSuffix)
+
+	hash := sha256.Sum256(data)
+	record, _ := json.Marshal(purgedUpload{
+		Filename: filename,
+		SHA256:   hex.EncodeToString(hash[:]),
+		Size:     int64(len(data)),
+		Uploaded: info.ModTime(),
+		Purged:   time.Now(),
+		Reason:   reason,
+	})
+
+	purgeLogMutex.Lock()
+	defer purgeLogMutex.Unlock()
+	f, err := os.OpenFile(purgedUploadsLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to record purge: %v", err)
+	}
+	defer f.Close()
+	_, err = f.Write(append(record, '\n'))
+	return err
+}
+
+// purgeExpiredUploads deletes idle uploads older than the retention period
+func purgeExpiredUploads(now time.Time) {
+	entries, err := os.ReadDir("uploads")
+	if err != nil {
+		log.Printf("Warning: Failed to list uploads for purging: %v", err)
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasSuffix(name, signatureBundleSuffix) || strings.HasSuffix(name, agentManifestSuffix) || activeUploads.busy(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < retention.maxAge {
+			continue
+		}
+		if err := purgeUpload(name, fmt.Sprintf("older than %v", retention.maxAge)); err != nil && err != errLegalHold {
+			log.Printf("Warning: Failed to purge upload %s: %v", name, err)
+		}
+	}
+}
+
+// runUploadSweeper purges expired uploads until ctx is done
+func runUploadSweeper(ctx context.Context) {
+	if retention.maxAge == 0 {
+		return
+	}
+
+	interval := min(retention.maxAge/4, time.Hour)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	purgeExpiredUploads(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			purgeExpiredUploads(now)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseRetention tests the AEGONG_UPLOAD_RETENTION formats
+func TestParseRetention(t *testing.T) {
+	cases := map[string]uploadRetention{
+		"":             {},
+		"keep":         {},
+		"after-report": {afterReport: true},
+		"72h":          {maxAge: 72 * time.Hour},
+		"7d":           {maxAge: 7 * 24 * time.Hour},
+	}
+	for value, want := range cases {
+		got, err := parseRetention(value)
+		if err != nil || got != want {
+			t.Fatalf("Retention %q should parse to %+v, got %+v, %v", value, want, got, err)
+		}
+	}
+
+	for _, value := range []string{"forever", "-1h", "0d", "xd"} {
+		if _, err := parseRetention(value); err == nil {
+			t.Fatalf("Retention %q should be rejected", value)
+		}
+	}
+}
+
+// TestPurgeUploads tests expiry and after-report purging of uploads
+func TestPurgeUploads(t *testing.T) {
+	withTestUpload(t)
+	os.MkdirAll("reports", 0755)
+	oldRetention := retention
+	t.Cleanup(func() { retention = oldRetention })
+
+	// An upload being audited is never purged, however old
+	retention = uploadRetention{maxAge: time.Hour}
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(filepath.Join("uploads", "agent.py"), old, old)
+	os.WriteFile(filepath.Join("uploads", "fresh.py"), []byte("print('new')\n"), 0644)
+
+	release := useUpload("agent.py")
+	purgeExpiredUploads(time.Now())
+	if _, err := os.Stat(filepath.Join("uploads", "agent.py")); err != nil {
+		t.Fatal("Upload in use should not be purged")
+	}
+	release(false)
+
+	purgeExpiredUploads(time.Now())
+	if _, err := os.Stat(filepath.Join("uploads", "agent.py")); !os.IsNotExist(err) {
+		t.Fatal("Expired upload should be purged")
+	}
+	if _, err := os.Stat(filepath.Join("uploads", "fresh.py")); err != nil {
+		t.Fatal("Upload within the retention period should be kept")
+	}
+
+	data, _ := os.ReadFile(purgedUploadsLog)
+	var record purgedUpload
+	if err := json.Unmarshal(data, &record); err != nil || record.Filename != "agent.py" || len(record.SHA256) != 64 {
+		t.Fatalf("Purge should record the upload's hash, got %s", data)
+	}
+
+	// After-report mode purges once the last audit using the upload saved a report
+	retention = uploadRetention{afterReport: true}
+	first := useUpload("fresh.py")
+	second := useUpload("fresh.py")
+	first(true)
+	if _, err := os.Stat(filepath.Join("uploads", "fresh.py")); err != nil {
+		t.Fatal("Upload should be kept while another audit reads it")
+	}
+	second(false)
+	if _, err := os.Stat(filepath.Join("uploads", "fresh.py")); !os.IsNotExist(err) {
+		t.Fatal("Upload should be purged once its report is saved")
+	}
+}
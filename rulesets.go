@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// rulesetSummary is a ruleset with the saved reports audited under it
+type rulesetSummary struct {
+	aegong.Ruleset
+	Current bool `json:"current"` // Whether audits starting now run under it
+	Reports int  `json:"reports"`
+}
+
+// rulesetsHandler lists the rulesets the engine has run, newest first
+func rulesetsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireRole(w, r, RoleAdmin, RoleAuditor); !ok {
+		return
+	}
+
+	saved, err := loadReports()
+	if err != nil {
+		http.Error(w, "Error reading reports", http.StatusInternalServerError)
+		return
+	}
+	counts := make(map[int]int)
+	for _, report := range saved {
+		if report.Ruleset != nil {
+			counts[report.Ruleset.Version]++
+		}
+	}
+
+	current := engine.Ruleset()
+	history := engine.Rulesets()
+	rulesets := make([]rulesetSummary, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		rulesets = append(rulesets, rulesetSummary{
+			Ruleset: history[i],
+			Current: history[i].Version == current.Version,
+			Reports: counts[history[i].Version],
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rulesets)
+}
+
+// rescoreResult compares a re-audited report with the one it replaced
+type rescoreResult struct {
+	Previous rescoredReport      `json:"previous"`
+	Report   *aegong.AuditReport `json:"report"`
+}
+
+// rescoredReport is the outcome of the replaced audit
+type rescoredReport struct {
+	Ruleset     int     `json:"ruleset,omitempty"` // Absent for reports that predate rulesets
+	OverallRisk float64 `json:"overall_risk"`
+	RiskLevel   string  `json:"risk_level"`
+	ThreatCount int     `json:"threat_count"`
+}
+
+// rescoreHandler re-audits the agent of a saved report under the current
+// ruleset, with the scope and narration of the original audit
+func rescoreHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin, RoleAuditor)
+	if !ok {
+		return
+	}
+
+	hash := mux.Vars(r)["hash"]
+	previous, status, err := savedReport(hash)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if isHeld(aegong.HoldReport, hash) {
+		http.Error(w, fmt.Sprintf("Report %s is under legal hold", hash), http.StatusConflict)
+		return
+	}
+	filename, _ := findUpload(previous.AgentHash)
+	if filename == "" {
+		http.Error(w, "The agent's upload has been purged; upload it again to audit it under the current ruleset", http.StatusGone)
+		return
+	}
+
+	// An agent that was audited despite failed validation stays forced
+	opts := auditOptions{
+		Force:     previous.ValidationOverride != nil,
+		Principal: principal,
+		Source:    previous.Source,
+		Narration: reportNarration(previous),
+		Replace:   true,
+	}
+	if previous.Coverage != nil && previous.Coverage.Scope != nil {
+		opts.Scope = *previous.Coverage.Scope
+	}
+
+	if !admitRequestAudit(w, r, &opts) {
+		return
+	}
+	defer opts.Release()
+
+	report, err := runPublishedAudit(r.Context(), filename, opts)
+	if err == errAuditQueueFull {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if _, ok := err.(*notAgentError); ok {
+		http.Error(w, "The agent no longer passes validation; audit it with force=true", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, errLegalHold) {
+		// Placed while the agent was audited again
+		http.Error(w, fmt.Sprintf("Report %s is under legal hold", hash), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := rescoreResult{
+		Previous: rescoredReport{
+			OverallRisk: previous.OverallRisk,
+			RiskLevel:   previous.RiskLevel,
+			ThreatCount: len(previous.Threats),
+		},
+		Report: report,
+	}
+	if previous.Ruleset != nil {
+		result.Previous.Ruleset = previous.Ruleset.Version
+	}
+	log.Printf("Report %s rescored by %s under ruleset v%d: risk %.2f -> %.2f",
+		hash, principal.Name, report.Ruleset.Version, previous.OverallRisk, report.OverallRisk)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// TestRulesetsAndRescore tests listing ruleset versions and re-auditing reports under the current one
+func TestRulesetsAndRescore(t *testing.T) {
+	sum := sha256.Sum256([]byte("print('hi')\n"))
+	agentHash := hex.EncodeToString(sum[:])
+	withTestReports(t,
+		&aegong.AuditReport{AgentHash: agentHash, OverallRisk: 0.9, RiskLevel: "CRITICAL",
+			ValidationOverride: &aegong.ValidationOverride{Actor: "ci"}},
+		&aegong.AuditReport{AgentHash: "abcdef0123456789", Ruleset: &aegong.Ruleset{Version: 1}},
+	)
+	oldTokens, oldVoice := apiTokens, voiceManager
+	t.Cleanup(func() { apiTokens, voiceManager = oldTokens, oldVoice })
+	apiTokens, _ = loadAPITokens("ci:auditor:audit,bob:viewer:view")
+	voiceManager = &VoiceInferenceManager{}
+
+	router := mux.NewRouter()
+	registerAPIRoutes(router)
+	request := func(method, path, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := request("GET", "/api/admin/rulesets", "view"); rec.Code != http.StatusForbidden {
+		t.Errorf("Viewers should not list rulesets, got %d", rec.Code)
+	}
+	rec := request("GET", "/api/admin/rulesets", "audit")
+	var rulesets []rulesetSummary
+	json.Unmarshal(rec.Body.Bytes(), &rulesets)
+	if rec.Code != http.StatusOK || len(rulesets) != 1 || rulesets[0].Version != 1 || !rulesets[0].Current || rulesets[0].Reports != 1 {
+		t.Fatalf("Should list the current ruleset with its reports, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if rec := request("POST", "/api/report/abcdef01/rescore", "view"); rec.Code != http.StatusForbidden {
+		t.Errorf("Viewers should not rescore reports, got %d", rec.Code)
+	}
+	if rec := request("POST", "/api/report/ffffffff/rescore", "audit"); rec.Code != http.StatusNotFound {
+		t.Errorf("Unknown reports should return 404, got %d", rec.Code)
+	}
+	if rec := request("POST", "/api/report/abcdef01/rescore", "audit"); rec.Code != http.StatusGone {
+		t.Errorf("Reports whose upload was purged should return 410, got %d", rec.Code)
+	}
+
+	rec = request("POST", "/api/report/"+agentHash[:8]+"/rescore", "audit")
+	var result rescoreResult
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if rec.Code != http.StatusOK || result.Previous.Ruleset != 0 || result.Previous.OverallRisk != 0.9 ||
+		result.Report == nil || result.Report.Ruleset == nil || result.Report.Ruleset.Version != 1 {
+		t.Fatalf("Should re-audit the agent under the current ruleset, got %d: %s", rec.Code, rec.Body)
+	}
+	if report, _, err := savedReport(agentHash[:8]); err != nil || report.Ruleset == nil || report.ValidationOverride == nil {
+		t.Errorf("The rescored report should replace the saved one and stay forced, got %+v", report)
+	}
+}
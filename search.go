@@ -0,0 +1,401 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// Full-text search over the saved reports, so analysts can ask which agents
+// ever touched /etc/shadow across the whole history. Agent names, threat
+// evidence and recommendation text are indexed in memory by word. The index
+// follows the report files: before each search, reports saved, re-audited,
+// imported or deleted since the last one are indexed again or dropped, so it
+// never needs rebuilding.
+//
+// Every term of a query must appear in a report for it to match. A term is a
+// whole word, or a phrase in double quotes; terms with punctuation, like
+// /etc/shadow, must appear as written. Matching ignores case. Reports are
+// ranked by how many of their fields contain the terms, weighting rare terms
+// and matches in the agent's name higher.
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchTerms     = 16
+	maxSearchMatches   = 5  // Matching fields shown per report
+	searchSnippetWidth = 60 // Characters shown either side of a match
+)
+
+// searchField is an indexed piece of a report's text
+type searchField struct {
+	name   string // JSON path of the text in the report, like threats[0].evidence[1]
+	text   string
+	lower  string
+	weight float64
+}
+
+// searchDoc is an indexed report
+type searchDoc struct {
+	modTime time.Time
+	size    int64
+	report  *aegong.AuditReport
+	fields  []searchField
+	words   map[string]bool // Words in the report
+}
+
+// searchIndex maps words to the report files containing them
+type searchIndex struct {
+	mutex sync.Mutex
+	docs  map[string]*searchDoc      // By report file
+	words map[string]map[string]bool // Report files containing each word
+}
+
+var reportIndex = newSearchIndex()
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{docs: make(map[string]*searchDoc), words: make(map[string]map[string]bool)}
+}
+
+// searchWords splits text into lower case words
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// reportFields lists the text of a report that is searched
+func reportFields(report *aegong.AuditReport) []searchField {
+	var fields []searchField
+	add := func(name, text string, weight float64) {
+		if text != "" {
+			fields = append(fields, searchField{name: name, text: text, lower: strings.ToLower(text), weight: weight})
+		}
+	}
+	add("agent_name", report.AgentName, 3)
+	add("agent_hash", report.AgentHash, 3)
+	for i, threat := range report.Threats {
+		add(fmt.Sprintf("threats[%d].vector", i), vectorCode(threat.Vector)+" "+aegong.ThreatName(threat.Vector), 1)
+		for j, evidence := range threat.Evidence {
+			add(fmt.Sprintf("threats[%d].evidence[%d]", i, j), evidence, 2)
+		}
+	}
+	for i, recommendation := range report.Recommendations {
+		add(fmt.Sprintf("recommendations[%d].title", i), recommendation.Title, 1)
+		add(fmt.Sprintf("recommendations[%d].guidance", i), recommendation.Guidance, 1)
+	}
+	return fields
+}
+
+// refresh indexes the report files saved or changed since the last search
+// and drops those that are gone. The caller must hold the mutex.
+func (idx *searchIndex) refresh() error {
+	files, err := filepath.Glob("reports/report_*.json")
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(files))
+	for _, file := range files {
+		// Absolute, so reports are told apart if the working directory changes
+		if file, err = filepath.Abs(file); err != nil {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		seen[file] = true
+		if doc := idx.docs[file]; doc != nil && doc.modTime.Equal(info.ModTime()) && doc.size == info.Size() {
+			continue
+		}
+		idx.remove(file)
+
+		data, err := readStored(file)
+		if err != nil {
+			continue
+		}
+		report, err := parseReport(data)
+		if err != nil {
+			continue
+		}
+		doc := &searchDoc{modTime: info.ModTime(), size: info.Size(), report: report, fields: reportFields(report), words: make(map[string]bool)}
+		for _, field := range doc.fields {
+			for _, word := range searchWords(field.text) {
+				doc.words[word] = true
+			}
+		}
+		idx.docs[file] = doc
+		for word := range doc.words {
+			if idx.words[word] == nil {
+				idx.words[word] = make(map[string]bool)
+			}
+			idx.words[word][file] = true
+		}
+	}
+	for file := range idx.docs {
+		if !seen[file] {
+			idx.remove(file)
+		}
+	}
+	return nil
+}
+
+// remove drops a report file from the index. The caller must hold the mutex.
+func (idx *searchIndex) remove(file string) {
+	doc := idx.docs[file]
+	if doc == nil {
+		return
+	}
+	for word := range doc.words {
+		delete(idx.words[word], file)
+		if len(idx.words[word]) == 0 {
+			delete(idx.words, word)
+		}
+	}
+	delete(idx.docs, file)
+}
+
+// searchTerm is a word or phrase of a query
+type searchTerm struct {
+	text  string   // Lower case, as written
+	words []string // Its words, all of which a report must contain
+}
+
+// exact reports whether the term must be found as written rather than as a word
+func (t searchTerm) exact() bool {
+	return len(t.words) != 1 || t.words[0] != t.text
+}
+
+// parseSearchQuery splits a query into its words and quoted phrases
+func parseSearchQuery(q string) ([]searchTerm, error) {
+	var terms []searchTerm
+	add := func(text string) {
+		text = strings.ToLower(strings.TrimSpace(text))
+		if words := searchWords(text); len(words) > 0 {
+			terms = append(terms, searchTerm{text: text, words: words})
+		}
+	}
+	for i, part := range strings.Split(q, `"`) {
+		if i%2 == 1 {
+			add(part)
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			add(word)
+		}
+	}
+	if strings.Count(q, `"`)%2 == 1 {
+		return nil, fmt.Errorf("q has an unclosed quote")
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("q must contain a word to search for")
+	}
+	if len(terms) > maxSearchTerms {
+		return nil, fmt.Errorf("q may contain at most %d terms", maxSearchTerms)
+	}
+	return terms, nil
+}
+
+// fieldMatch locates a term in a field, returning the offset or -1
+func (t searchTerm) fieldMatch(field searchField) int {
+	if t.exact() {
+		return strings.Index(field.lower, t.text)
+	}
+	offset := 0
+	for {
+		i := strings.Index(field.lower[offset:], t.text)
+		if i < 0 {
+			return -1
+		}
+		start, end := offset+i, offset+i+len(t.text)
+		if !isWordByte(field.lower, start-1) && !isWordByte(field.lower, end) {
+			return start
+		}
+		offset = start + 1
+	}
+}
+
+// isWordByte reports whether s has a letter, digit or underscore at i
+func isWordByte(s string, i int) bool {
+	if i < 0 || i >= len(s) {
+		return false
+	}
+	c := s[i]
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 0x80
+}
+
+// searchMatch is a field of a report a query matched, with the text around the match
+type searchMatch struct {
+	Field   string `json:"field"`
+	Snippet string `json:"snippet"`
+}
+
+// searchResult is a report a query matched
+type searchResult struct {
+	Hash        string        `json:"hash"`
+	AgentName   string        `json:"agent_name"`
+	Timestamp   time.Time     `json:"timestamp"`
+	RiskLevel   string        `json:"risk_level"`
+	OverallRisk float64       `json:"overall_risk"`
+	Score       float64       `json:"score"`
+	Matches     []searchMatch `json:"matches"`
+}
+
+// search finds the reports containing every term, best first
+func (idx *searchIndex) search(terms []searchTerm) ([]searchResult, error) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	if err := idx.refresh(); err != nil {
+		return nil, err
+	}
+
+	// Candidates contain every word of every term
+	var candidates map[string]bool
+	for _, term := range terms {
+		for _, word := range term.words {
+			files := idx.words[word]
+			if candidates == nil {
+				candidates = make(map[string]bool, len(files))
+				for file := range files {
+					candidates[file] = true
+				}
+				continue
+			}
+			for file := range candidates {
+				if !files[file] {
+					delete(candidates, file)
+				}
+			}
+		}
+	}
+
+	// Rare terms count for more; a phrase is as rare as its rarest word
+	idf := make([]float64, len(terms))
+	for i, term := range terms {
+		reports := len(idx.docs)
+		for _, word := range term.words {
+			reports = min(reports, len(idx.words[word]))
+		}
+		idf[i] = math.Log(1 + float64(len(idx.docs))/float64(max(reports, 1)))
+	}
+
+	results := []searchResult{}
+	for file := range candidates {
+		doc := idx.docs[file]
+		score := 0.0
+		var matches []searchMatch
+		found := make([]bool, len(terms))
+		for _, field := range doc.fields {
+			first := -1
+			for i, term := range terms {
+				offset := term.fieldMatch(field)
+				if offset < 0 {
+					continue
+				}
+				found[i] = true
+				if first < 0 || offset < first {
+					first = offset
+				}
+				score += field.weight * idf[i]
+			}
+			if first >= 0 && len(matches) < maxSearchMatches {
+				// Offsets are into the lower case text, which can differ in length
+				text := field.text
+				if len(text) != len(field.lower) {
+					text = field.lower
+				}
+				matches = append(matches, searchMatch{Field: field.name, Snippet: snippet(text, first)})
+			}
+		}
+		// Phrases must appear as written, not just word by word
+		if slices.Contains(found, false) {
+			continue
+		}
+		results = append(results, searchResult{
+			Hash:        doc.report.AgentHash[:8],
+			AgentName:   doc.report.AgentName,
+			Timestamp:   doc.report.Timestamp,
+			RiskLevel:   doc.report.RiskLevel,
+			OverallRisk: doc.report.OverallRisk,
+			Score:       math.Round(score*1000) / 1000,
+			Matches:     matches,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Timestamp.After(results[j].Timestamp)
+	})
+	return results, nil
+}
+
+// snippet returns the text around offset, marking cut ends with an ellipsis
+func snippet(text string, offset int) string {
+	start, end := max(offset-searchSnippetWidth, 0), min(offset+searchSnippetWidth, len(text))
+	// Keep whole UTF-8 characters
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	s := text[start:end]
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(text) {
+		s += "…"
+	}
+	return s
+}
+
+// searchHandler searches saved reports; ?q= is the query and ?limit= caps the results
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	terms, err := parseSearchQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := defaultSearchLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxSearchLimit {
+			http.Error(w, fmt.Sprintf("limit must be a number from 1 to %d", maxSearchLimit), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	results, err := reportIndex.search(terms)
+	if err != nil {
+		http.Error(w, "Error reading reports", http.StatusInternalServerError)
+		return
+	}
+	total := len(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":   r.URL.Query().Get("q"),
+		"total":   total,
+		"results": results,
+	})
+}
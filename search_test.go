@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// TestParseSearchQuery tests splitting queries into words and phrases
+func TestParseSearchQuery(t *testing.T) {
+	terms, err := parseSearchQuery(`Shadow "read the  file" /etc/passwd`)
+	if err != nil || len(terms) != 3 {
+		t.Fatalf("Should find three terms, got %+v (%v)", terms, err)
+	}
+	if terms[0].text != "shadow" || terms[0].exact() || terms[1].text != "read the  file" || !terms[1].exact() || !terms[2].exact() || len(terms[2].words) != 2 {
+		t.Fatalf("Should match words whole and phrases as written, got %+v", terms)
+	}
+	for _, q := range []string{"", "  /// ", `"unclosed`, strings.Repeat("a ", maxSearchTerms+1)} {
+		if _, err := parseSearchQuery(q); err == nil {
+			t.Errorf("%q: Should be refused", q)
+		}
+	}
+}
+
+// TestSearchReports tests finding reports by agent name, evidence and recommendation text
+func TestSearchReports(t *testing.T) {
+	now := time.Now()
+	withTestReports(t,
+		&aegong.AuditReport{AgentHash: "aaaaaaaa11", AgentName: "harvester", Timestamp: now, RiskLevel: "CRITICAL", Threats: []aegong.ThreatDetection{
+			{Vector: aegong.T4_UNAUTHORIZED_ACTION, Evidence: []string{"Opened /etc/shadow for reading", "Connected to 10.0.0.1"}},
+		}},
+		&aegong.AuditReport{AgentHash: "bbbbbbbb22", AgentName: "shadow-copy", Timestamp: now.Add(-time.Hour), RiskLevel: "LOW", Recommendations: []aegong.Recommendation{
+			{Title: "Restrict file access", Guidance: "Never read /etc/passwd from an agent"},
+		}},
+		&aegong.AuditReport{AgentHash: "cccccccc33", AgentName: "helper", Timestamp: now, RiskLevel: "MINIMAL"},
+	)
+	search := func(q string) []searchResult {
+		terms, err := parseSearchQuery(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := reportIndex.search(terms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	results := search("/etc/shadow")
+	if len(results) != 1 || results[0].Hash != "aaaaaaaa" || results[0].Matches[0].Field != "threats[0].evidence[0]" || results[0].Matches[0].Snippet != "Opened /etc/shadow for reading" {
+		t.Fatalf("Should find the agent that touched /etc/shadow, got %+v", results)
+	}
+	if results := search("SHADOW"); len(results) != 2 || results[0].Hash != "bbbbbbbb" {
+		t.Fatalf("Should rank the match in the agent's name first, got %+v", results)
+	}
+	if results := search(`"file access" passwd`); len(results) != 1 || results[0].Hash != "bbbbbbbb" || len(results[0].Matches) != 2 {
+		t.Fatalf("Should match phrases in recommendations, got %+v", results)
+	}
+	if results := search(`"for shadow"`); len(results) != 0 {
+		t.Fatalf("Should only match phrases as written, got %+v", results)
+	}
+	if results := search("shad"); len(results) != 0 {
+		t.Fatalf("Should match whole words, got %+v", results)
+	}
+
+	// Deleted and rewritten reports are reindexed
+	os.Remove("reports/report_aaaaaaaa.json")
+	data, _ := json.Marshal(&aegong.AuditReport{AgentHash: "cccccccc33", AgentName: "helper", Threats: []aegong.ThreatDetection{
+		{Vector: aegong.T6_IDENTITY_SPOOFING, Evidence: []string{"Read /etc/shadow twice"}},
+	}})
+	os.WriteFile("reports/report_cccccccc.json", data, 0644)
+	if results := search("/etc/shadow"); len(results) != 1 || results[0].Hash != "cccccccc" {
+		t.Fatalf("Should follow changes to the saved reports, got %+v", results)
+	}
+}
+
+// TestSearchHandler tests the search endpoint's parameters
+func TestSearchHandler(t *testing.T) {
+	withTestReports(t,
+		&aegong.AuditReport{AgentHash: "aaaaaaaa11", AgentName: "scanner one"},
+		&aegong.AuditReport{AgentHash: "bbbbbbbb22", AgentName: "scanner two"},
+	)
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		searchHandler(w, httptest.NewRequest("GET", "/api/search?"+query, nil))
+		return w
+	}
+
+	for _, query := range []string{"", "q=scanner&limit=0", "q=scanner&limit=many"} {
+		if w := get(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: Should be rejected, got %d", query, w.Code)
+		}
+	}
+	w := get("q=scanner&limit=1")
+	var response struct {
+		Total   int            `json:"total"`
+		Results []searchResult `json:"results"`
+	}
+	json.NewDecoder(w.Body).Decode(&response)
+	if w.Code != http.StatusOK || response.Total != 2 || len(response.Results) != 1 {
+		t.Fatalf("Should count every match and return the first, got %d: %+v", w.Code, response)
+	}
+	if w := get("q=nothing"); !strings.Contains(w.Body.String(), `"results":[]`) {
+		t.Fatalf("Should return an empty list when nothing matches, got %s", w.Body)
+	}
+}
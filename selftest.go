@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// Every detector is run against its canary when the server starts and again
+// whenever a component's settings change, so a configuration that stops a
+// detector from reporting anything is noticed rather than silently producing
+// clean reports.
+
+// How detector self-test failures are handled
+const (
+	selfTestWarn   = "warn"   // Log them and raise a status warning
+	selfTestStrict = "strict" // Refuse to start
+	selfTestOff    = "off"
+)
+
+var selfTestMode = selfTestWarn
+
+// Latest detector self-test result
+var (
+	selfTestResult *aegong.SelfTestResult
+	selfTestMutex  sync.Mutex
+)
+
+// initSelfTest reads how self-test failures are handled from AEGONG_SELFTEST
+func initSelfTest() error {
+	switch mode := os.Getenv("AEGONG_SELFTEST"); mode {
+	case "":
+	case selfTestWarn, selfTestStrict, selfTestOff:
+		selfTestMode = mode
+	default:
+		return fmt.Errorf("AEGONG_SELFTEST must be %q, %q or %q, got %q", selfTestWarn, selfTestStrict, selfTestOff, mode)
+	}
+	return nil
+}
+
+// runSelfTest runs every detector against its canary, logging each one
+// missed. It returns an error if any was missed in strict mode.
+func runSelfTest(ctx context.Context) error {
+	if selfTestMode == selfTestOff || engine == nil {
+		return nil
+	}
+	result := engine.SelfTest(ctx)
+	selfTestMutex.Lock()
+	selfTestResult = result
+	selfTestMutex.Unlock()
+
+	failures := result.Failures()
+	if len(failures) == 0 {
+		log.Printf("Info: Detector self-test passed, %d detectors flagged their canaries", len(result.Detectors))
+		return nil
+	}
+	for _, failure := range failures {
+		log.Printf("Warning: Detector %s missed its self-test canary %s (%s), agents are not being checked for %s findings", failure.Detector, failure.Canary, failure.Reason, failure.Detector)
+	}
+	if selfTestMode == selfTestStrict {
+		return fmt.Errorf("%d of %d detectors missed their canaries", len(failures), len(result.Detectors))
+	}
+	return nil
+}
+
+// selfTestFailures returns the detectors that missed their canary in the
+// latest self-test
+func selfTestFailures() []aegong.DetectorCanary {
+	selfTestMutex.Lock()
+	defer selfTestMutex.Unlock()
+	if selfTestResult == nil {
+		return nil
+	}
+	return selfTestResult.Failures()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// TestRunSelfTest tests how each AEGONG_SELFTEST mode handles a detector
+// missing its canary
+func TestRunSelfTest(t *testing.T) {
+	oldEngine, oldMode, oldResult := engine, selfTestMode, selfTestResult
+	t.Cleanup(func() { engine, selfTestMode, selfTestResult = oldEngine, oldMode, oldResult })
+	engine, _ = aegong.NewEngine(aegong.Config{})
+	defer engine.Close()
+
+	selfTestMode = selfTestStrict
+	if err := runSelfTest(context.Background()); err != nil || len(selfTestFailures()) != 0 {
+		t.Fatalf("Self-test should pass with the default settings, got %v", err)
+	}
+
+	disabled := false
+	engine.UpdateComponent("T4", aegong.ComponentUpdate{Enabled: &disabled}, "alice", "admin")
+	if err := runSelfTest(context.Background()); err == nil {
+		t.Fatal("Strict mode should fail when a detector misses its canary")
+	}
+
+	selfTestMode = selfTestWarn
+	if err := runSelfTest(context.Background()); err != nil {
+		t.Fatalf("Warn mode should not fail, got %v", err)
+	}
+	status := collectStatus()
+	if len(status.SelfTestFailures) != 1 || status.SelfTestFailures[0].Detector != "T4" {
+		t.Fatalf("Status should list the detector that missed its canary, got %+v", status.SelfTestFailures)
+	}
+	if !strings.Contains(strings.Join(status.Warnings, "\n"), "Detector T4 missed its self-test canary") {
+		t.Errorf("Status should warn about the detector, got %v", status.Warnings)
+	}
+
+	t.Setenv("AEGONG_SELFTEST", "sometimes")
+	if err := initSelfTest(); err == nil {
+		t.Error("An unknown AEGONG_SELFTEST mode should be rejected")
+	}
+}
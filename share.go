@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// Share links let auditors send a report to people without an account. The
+// link carries the report, the redaction profile and the expiry, signed with
+// the share key, so the server keeps no state for it: a link stops working
+// when it expires or when the key changes.
+
+// Key share links are signed with
+var shareKey []byte
+
+// How long share links last unless the request asks for less
+const defaultShareTTL = 7 * 24 * time.Hour
+
+// Longest lifetime a share link may be given
+var maxShareTTL = 30 * 24 * time.Hour
+
+// Shortest share key accepted from AEGONG_SHARE_KEY
+const minShareKeyLength = 32
+
+// shareClaims is what a share link grants
+type shareClaims struct {
+	ID      string `json:"i"`
+	Hash    string `json:"h"`
+	Profile string `json:"p,omitempty"` // Redaction profile; empty shares the full report
+	Expires int64  `json:"e"`           // Unix time
+}
+
+// shareRequest asks for a share link to a report
+type shareRequest struct {
+	ExpiresIn string `json:"expires_in"` // Duration such as 72h
+	Profile   string `json:"profile"`    // Redaction profile; empty shares the full report
+}
+
+// shareLink is a created share link
+type shareLink struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Profile   string    `json:"profile,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// initShareLinks loads the share key from AEGONG_SHARE_KEY, or makes one up
+// so links last until the server restarts, and the longest lifetime of a
+// link from AEGONG_SHARE_MAX_TTL
+func initShareLinks() error {
+	if value := os.Getenv("AEGONG_SHARE_MAX_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			return fmt.Errorf("AEGONG_SHARE_MAX_TTL must be a positive duration such as 720h, got %q", value)
+		}
+		maxShareTTL = ttl
+	}
+
+	if key := os.Getenv("AEGONG_SHARE_KEY"); key != "" {
+		if len(key) < minShareKeyLength {
+			return fmt.Errorf("AEGONG_SHARE_KEY must be at least %d characters", minShareKeyLength)
+		}
+		shareKey = []byte(key)
+		return nil
+	}
+	shareKey = make([]byte, 32)
+	if _, err := rand.Read(shareKey); err != nil {
+		return fmt.Errorf("failed to generate share key: %v", err)
+	}
+	log.Printf("Info: AEGONG_SHARE_KEY is not set, share links will stop working when the server restarts")
+	return nil
+}
+
+// signShare encodes claims as a share token
+func signShare(claims shareClaims) string {
+	payload, _ := json.Marshal(claims)
+	mac := hmac.New(sha256.New, shareKey)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyShare checks a share token's signature and expiry, returning what it grants
+func verifyShare(token string, now time.Time) (shareClaims, error) {
+	var claims shareClaims
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return claims, fmt.Errorf("malformed share link")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return claims, fmt.Errorf("malformed share link")
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return claims, fmt.Errorf("malformed share link")
+	}
+	mac := hmac.New(sha256.New, shareKey)
+	mac.Write(payload)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return claims, fmt.Errorf("invalid share link")
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, fmt.Errorf("malformed share link")
+	}
+	if now.Unix() >= claims.Expires {
+		return claims, fmt.Errorf("share link expired at %s", time.Unix(claims.Expires, 0).UTC().Format(time.RFC3339))
+	}
+	return claims, nil
+}
+
+// requestBaseURL returns the scheme and host a request was made to
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// shareReportHandler lets auditors create a time-limited, read-only link to
+// a report, optionally redacted
+func shareReportHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin, RoleAuditor)
+	if !ok {
+		return
+	}
+
+	var request shareRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	// The body is optional
+	if err := decoder.Decode(&request); err != nil && err != io.EOF {
+		http.Error(w, "Request body must be JSON with optional \"expires_in\" and \"profile\"", http.StatusBadRequest)
+		return
+	}
+	ttl := defaultShareTTL
+	if request.ExpiresIn != "" {
+		var err error
+		if ttl, err = time.ParseDuration(request.ExpiresIn); err != nil || ttl <= 0 {
+			http.Error(w, fmt.Sprintf("expires_in must be a positive duration such as 72h, got %q", request.ExpiresIn), http.StatusBadRequest)
+			return
+		}
+	}
+	ttl = min(ttl, maxShareTTL)
+	if request.Profile != "" {
+		if _, ok := redactionProfiles[request.Profile]; !ok {
+			http.Error(w, fmt.Sprintf("Unknown redaction profile %q", request.Profile), http.StatusBadRequest)
+			return
+		}
+	}
+
+	hash := mux.Vars(r)["hash"]
+	reportPath, err := reportFile(hash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Stat(reportPath); os.IsNotExist(err) {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create share link: %v", err), http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	expires := now.Add(ttl).Truncate(time.Second)
+	claims := shareClaims{ID: hex.EncodeToString(id), Hash: hash, Profile: request.Profile, Expires: expires.Unix()}
+
+	if engine != nil && engine.AuditLog() != nil {
+		engine.AuditLog().LogReportShare(&aegong.ReportShare{
+			ID:            claims.ID,
+			ReportHash:    hash,
+			Profile:       request.Profile,
+			ExpiresAt:     expires,
+			Actor:         principal.Name,
+			Role:          string(principal.Role),
+			Timestamp:     now,
+			CorrelationID: aegong.CorrelationID(r.Context()),
+		})
+	}
+	logf(r.Context(), "Share link %s to report %s created by %s, expires %s", claims.ID, hash, principal.Name, expires.UTC().Format(time.RFC3339))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(shareLink{
+		ID:        claims.ID,
+		URL:       requestBaseURL(r) + "/api/shared/" + signShare(claims),
+		Profile:   request.Profile,
+		ExpiresAt: expires,
+	})
+}
+
+// sharedReportHandler serves the report a share link grants, redacted with
+// the link's profile
+func sharedReportHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := verifyShare(mux.Vars(r)["token"], time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	reportPath, err := reportFile(claims.Hash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := readStored(reportPath)
+	if os.IsNotExist(err) {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read report: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if claims.Profile != "" {
+		profile, ok := redactionProfiles[claims.Profile]
+		if !ok {
+			http.Error(w, fmt.Sprintf("Redaction profile %q no longer exists", claims.Profile), http.StatusGone)
+			return
+		}
+		report, err := redactReport(data, claims.Profile, profile, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data, _ = json.Marshal(report)
+	}
+	logf(r.Context(), "Share link %s to report %s opened", claims.ID, claims.Hash)
+
+	// Links end up in chat logs and emails; keep them out of caches and referrers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Write(data)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/mux"
+)
+
+// TestShareLinks tests creating share links and opening them without a token
+func TestShareLinks(t *testing.T) {
+	withTestReports(t, &aegong.AuditReport{
+		AgentHash: "abcdef0123",
+		AgentName: "agent.py",
+		Threats: []aegong.ThreatDetection{
+			{Vector: aegong.T4_UNAUTHORIZED_ACTION, Evidence: []string{"os.system in /srv/agents/agent.py"}},
+		},
+	})
+	oldTokens, oldKey := apiTokens, shareKey
+	t.Cleanup(func() { apiTokens, shareKey = oldTokens, oldKey })
+	apiTokens, _ = loadAPITokens("ci:auditor:audit,bob:viewer:view")
+	t.Setenv("AEGONG_SHARE_KEY", strings.Repeat("k", minShareKeyLength))
+	if err := initShareLinks(); err != nil {
+		t.Fatalf("Failed to load share key: %v", err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/report/{hash}/share", shareReportHandler).Methods("POST")
+	router.HandleFunc("/api/shared/{token}", sharedReportHandler).Methods("GET")
+	request := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, r)
+		return rec
+	}
+	share := func(body string) shareLink {
+		t.Helper()
+		rec := request("POST", "/api/report/abcdef01/share", "audit", body)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Auditors should create share links, got %d: %s", rec.Code, rec.Body)
+		}
+		var link shareLink
+		if err := json.Unmarshal(rec.Body.Bytes(), &link); err != nil {
+			t.Fatalf("Failed to parse share link: %v", err)
+		}
+		return link
+	}
+
+	if rec := request("POST", "/api/report/abcdef01/share", "view", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("Viewers should not create share links, got %d", rec.Code)
+	}
+	if rec := request("POST", "/api/report/abcdef01/share", "audit", `{"profile":"nobody"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("An unknown profile should return 400, got %d", rec.Code)
+	}
+	if rec := request("POST", "/api/report/abcdef01/share", "audit", `{"expires_in":"-1h"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("A negative lifetime should return 400, got %d", rec.Code)
+	}
+	if rec := request("POST", "/api/report/ffffffff/share", "audit", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("An unknown report should return 404, got %d", rec.Code)
+	}
+
+	full := share("")
+	if !strings.HasPrefix(full.URL, "http://example.com/api/shared/") {
+		t.Fatalf("Should return an absolute link, got %q", full.URL)
+	}
+	if ttl := time.Until(full.ExpiresAt); ttl < defaultShareTTL-time.Minute || ttl > defaultShareTTL {
+		t.Errorf("Should last %v by default, got %v", defaultShareTTL, ttl)
+	}
+	rec := request("GET", strings.TrimPrefix(full.URL, "http://example.com"), "", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/srv/agents/agent.py") {
+		t.Fatalf("Anyone should open a link to the full report, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Error("Shared reports should not be cached")
+	}
+	if rec := request("POST", strings.TrimPrefix(full.URL, "http://example.com"), "", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Share links should be read-only, got %d", rec.Code)
+	}
+
+	redacted := share(`{"profile":"customer","expires_in":"1h"}`)
+	if ttl := time.Until(redacted.ExpiresAt); ttl > time.Hour {
+		t.Errorf("Should honour expires_in, got %v", ttl)
+	}
+	rec = request("GET", strings.TrimPrefix(redacted.URL, "http://example.com"), "", "")
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "agent.py") || !strings.Contains(rec.Body.String(), `"profile":"customer"`) {
+		t.Fatalf("A link with a profile should serve the redacted report, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Tampering with the claims breaks the signature
+	token := strings.TrimPrefix(redacted.URL, "http://example.com/api/shared/")
+	forged := signShare(shareClaims{Hash: "abcdef01", Expires: time.Now().Add(time.Hour).Unix()})
+	_, signature, _ := strings.Cut(token, ".")
+	payload, _, _ := strings.Cut(forged, ".")
+	if rec := request("GET", "/api/shared/"+payload+"."+signature, "", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("A tampered link should return 403, got %d", rec.Code)
+	}
+	if rec := request("GET", "/api/shared/garbage", "", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("A malformed link should return 403, got %d", rec.Code)
+	}
+}
+
+// TestVerifyShareExpiry tests that share links stop working when they expire
+// or the share key changes
+func TestVerifyShareExpiry(t *testing.T) {
+	oldKey := shareKey
+	t.Cleanup(func() { shareKey = oldKey })
+	shareKey = []byte(strings.Repeat("a", minShareKeyLength))
+
+	now := time.Now()
+	token := signShare(shareClaims{ID: "1", Hash: "abcdef01", Expires: now.Add(time.Hour).Unix()})
+	if claims, err := verifyShare(token, now); err != nil || claims.Hash != "abcdef01" {
+		t.Fatalf("Should accept a valid link, got %+v, %v", claims, err)
+	}
+	if _, err := verifyShare(token, now.Add(2*time.Hour)); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("Should refuse an expired link, got %v", err)
+	}
+	shareKey = []byte(strings.Repeat("b", minShareKeyLength))
+	if _, err := verifyShare(token, now); err == nil {
+		t.Error("Should refuse a link signed with another key")
+	}
+
+	t.Setenv("AEGONG_SHARE_KEY", "short")
+	if err := initShareLinks(); err == nil {
+		t.Error("Should refuse a short share key")
+	}
+}
@@ -0,0 +1,820 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// Registry endpoints; variables so tests can point them at local servers
+var (
+	huggingFaceBaseURL = "https://huggingface.co"
+	pypiBaseURL        = "https://pypi.org"
+	npmRegistryURL     = "https://registry.npmjs.org"
+	ociScheme          = "https"
+)
+
+// Default cap on downloaded artifacts, overridden by AEGONG_MAX_FETCH_BYTES
+const defaultMaxFetchBytes = 100 << 20
+
+// Limits for the source bundle built from package archives
+const (
+	maxBundledFileBytes = 1 << 20
+	maxBundleBytes      = 5 << 20
+)
+
+var fetchClient = &http.Client{Timeout: 5 * time.Minute}
+
+// Whoever calls /api/audit-url picks the OCI registry, so registry requests
+// go through ociFetchClient, which refuses to connect to loopback, private
+// and link-local addresses unless the registry is one of
+// AEGONG_OCI_REGISTRIES. The address is checked after DNS resolution, so
+// rebinding can't get around it, and also applies to token realms and blob
+// redirects. No proxy is used, as it would hide the address being reached.
+var ociFetchClient = &http.Client{
+	Timeout:   5 * time.Minute,
+	Transport: &http.Transport{DialContext: dialRegistry, TLSHandshakeTimeout: 10 * time.Second},
+}
+
+// Hosts registries commonly hand out pull tokens from, besides themselves
+var ociAuthHosts = []string{"auth.docker.io"}
+
+// fetchedArtifact is a downloaded agent ready to be saved into uploads/
+type fetchedArtifact struct {
+	filename string
+	data     []byte
+	source   *aegong.ArtifactSource
+}
+
+// maxFetchBytes returns the configured download size limit
+func maxFetchBytes() int64 {
+	if limit, err := strconv.ParseInt(os.Getenv("AEGONG_MAX_FETCH_BYTES"), 10, 64); err == nil && limit > 0 {
+		return limit
+	}
+	return defaultMaxFetchBytes
+}
+
+// fetchArtifact downloads the agent referenced by a registry URL or reference:
+//
+//	https://huggingface.co/<org>/<repo>/blob/<revision>/<file>
+//	https://pypi.org/project/<name>[/<version>]   or  pypi:<name>[==<version>]
+//	https://www.npmjs.com/package/<name>[/v/<version>]  or  npm:<name>[@<version>]
+//	oci://<registry>/<repository>[:<tag>|@<digest>]  or  docker://...
+func fetchArtifact(ctx context.Context, rawURL string) (*fetchedArtifact, error) {
+	rawURL = strings.TrimSpace(rawURL)
+
+	switch {
+	case strings.HasPrefix(rawURL, "pypi:"):
+		name, version, _ := strings.Cut(strings.TrimPrefix(rawURL, "pypi:"), "==")
+		return fetchPyPI(ctx, rawURL, name, version)
+	case strings.HasPrefix(rawURL, "npm:"):
+		name, version := splitNpmSpec(strings.TrimPrefix(rawURL, "npm:"))
+		return fetchNpm(ctx, rawURL, name, version)
+	case strings.HasPrefix(rawURL, "oci://"), strings.HasPrefix(rawURL, "docker://"):
+		_, ref, _ := strings.Cut(rawURL, "://")
+		return fetchOCI(ctx, rawURL, ref)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported source %q: expected an https registry URL or a pypi:, npm: or oci:// reference", rawURL)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch u.Host {
+	case "huggingface.co":
+		// <org>/<repo>/(blob|resolve)/<revision>/<file...>
+		if len(parts) < 5 || (parts[2] != "blob" && parts[2] != "resolve") {
+			return nil, fmt.Errorf("HuggingFace URLs must point at a file, e.g. https://huggingface.co/<org>/<repo>/blob/main/agent.py")
+		}
+		return fetchHuggingFace(ctx, rawURL, parts[0]+"/"+parts[1], parts[3], strings.Join(parts[4:], "/"))
+	case "pypi.org":
+		if len(parts) < 2 || parts[0] != "project" {
+			return nil, fmt.Errorf("PyPI URLs must look like https://pypi.org/project/<name>/")
+		}
+		version := ""
+		if len(parts) > 2 {
+			version = parts[2]
+		}
+		return fetchPyPI(ctx, rawURL, parts[1], version)
+	case "www.npmjs.com", "npmjs.com":
+		if len(parts) < 2 || parts[0] != "package" {
+			return nil, fmt.Errorf("npm URLs must look like https://www.npmjs.com/package/<name>")
+		}
+		rest := parts[1:]
+		name := rest[0]
+		if strings.HasPrefix(name, "@") && len(rest) > 1 {
+			name, rest = name+"/"+rest[1], rest[1:]
+		}
+		version := ""
+		if len(rest) > 2 && rest[1] == "v" {
+			version = rest[2]
+		}
+		return fetchNpm(ctx, rawURL, name, version)
+	}
+
+	return nil, fmt.Errorf("unsupported registry host %q", u.Host)
+}
+
+// fetchHuggingFace downloads a single file from a HuggingFace repository
+func fetchHuggingFace(ctx context.Context, rawURL, repo, revision, file string) (*fetchedArtifact, error) {
+	downloadURL := fmt.Sprintf("%s/%s/resolve/%s/%s", huggingFaceBaseURL, repo, revision, file)
+	resp, err := fetchGet(ctx, downloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// HTML means we were sent to a web page (login, not found) instead of the file
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil, fmt.Errorf("HuggingFace returned a web page instead of %s", file)
+	}
+
+	data, err := readLimited(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &aegong.ArtifactSource{URL: rawURL, Kind: "huggingface", DownloadURL: downloadURL}
+
+	// LFS files publish their SHA-256 as the (linked) ETag
+	etag := strings.Trim(resp.Header.Get("X-Linked-Etag"), `"`)
+	if etag == "" {
+		etag = strings.Trim(resp.Header.Get("ETag"), `"`)
+	}
+	if isHexDigest(etag, sha256.Size) {
+		if err := verifyDigest(source, data, "sha256", etag); err != nil {
+			return nil, err
+		}
+	}
+
+	// The file name comes from the caller's URL, so it is reduced to safe characters
+	return &fetchedArtifact{filename: safeFilename(path.Base(file)), data: data, source: source}, nil
+}
+
+// fetchPyPI downloads a release of a PyPI package, preferring wheels over sdists
+func fetchPyPI(ctx context.Context, rawURL, name, version string) (*fetchedArtifact, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing PyPI package name")
+	}
+
+	metadataURL := fmt.Sprintf("%s/pypi/%s/json", pypiBaseURL, url.PathEscape(name))
+	if version != "" {
+		metadataURL = fmt.Sprintf("%s/pypi/%s/%s/json", pypiBaseURL, url.PathEscape(name), url.PathEscape(version))
+	}
+
+	var metadata struct {
+		Info struct {
+			Version string `json:"version"`
+		} `json:"info"`
+		URLs []struct {
+			PackageType string `json:"packagetype"`
+			Filename    string `json:"filename"`
+			URL         string `json:"url"`
+			Size        int64  `json:"size"`
+			Digests     struct {
+				SHA256 string `json:"sha256"`
+			} `json:"digests"`
+		} `json:"urls"`
+	}
+	if err := fetchJSON(ctx, metadataURL, nil, &metadata); err != nil {
+		return nil, err
+	}
+
+	// Pick a pure-Python wheel if there is one, else any wheel, else the sdist
+	best := -1
+	rank := func(i int) int {
+		file := metadata.URLs[i]
+		switch {
+		case file.PackageType == "bdist_wheel" && strings.HasSuffix(file.Filename, "-none-any.whl"):
+			return 3
+		case file.PackageType == "bdist_wheel":
+			return 2
+		case file.PackageType == "sdist" && (strings.HasSuffix(file.Filename, ".tar.gz") || strings.HasSuffix(file.Filename, ".zip")):
+			return 1
+		}
+		return 0
+	}
+	for i := range metadata.URLs {
+		if rank(i) > 0 && (best < 0 || rank(i) > rank(best)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil, fmt.Errorf("PyPI package %s has no wheel or sdist to audit", name)
+	}
+	file := metadata.URLs[best]
+	if file.Size > maxFetchBytes() {
+		return nil, fmt.Errorf("%s is %d bytes, over the %d byte limit", file.Filename, file.Size, maxFetchBytes())
+	}
+
+	data, err := fetchBody(ctx, file.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &aegong.ArtifactSource{URL: rawURL, Kind: "pypi", DownloadURL: file.URL}
+	if err := verifyDigest(source, data, "sha256", file.Digests.SHA256); err != nil {
+		return nil, err
+	}
+
+	var bundle []byte
+	if strings.HasSuffix(file.Filename, ".tar.gz") {
+		bundle, err = bundleTarGz(data, ".py", "#")
+	} else {
+		bundle, err = bundleZip(data, ".py", "#")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack %s: %v", file.Filename, err)
+	}
+
+	return &fetchedArtifact{
+		filename: fmt.Sprintf("%s-%s.py", safeFilename(name), safeFilename(metadata.Info.Version)),
+		data:     bundle,
+		source:   source,
+	}, nil
+}
+
+// fetchNpm downloads the tarball of an npm package version
+func fetchNpm(ctx context.Context, rawURL, name, version string) (*fetchedArtifact, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing npm package name")
+	}
+	if version == "" {
+		version = "latest"
+	}
+
+	// Scoped packages keep their "@" but escape the "/"
+	metadataURL := fmt.Sprintf("%s/%s/%s", npmRegistryURL, strings.Replace(name, "/", "%2F", 1), url.PathEscape(version))
+
+	var metadata struct {
+		Version string `json:"version"`
+		Dist    struct {
+			Tarball   string `json:"tarball"`
+			Shasum    string `json:"shasum"`
+			Integrity string `json:"integrity"`
+		} `json:"dist"`
+	}
+	if err := fetchJSON(ctx, metadataURL, nil, &metadata); err != nil {
+		return nil, err
+	}
+	if metadata.Dist.Tarball == "" {
+		return nil, fmt.Errorf("npm package %s@%s has no tarball", name, version)
+	}
+
+	data, err := fetchBody(ctx, metadata.Dist.Tarball, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &aegong.ArtifactSource{URL: rawURL, Kind: "npm", DownloadURL: metadata.Dist.Tarball}
+	if algorithm, digest, ok := strings.Cut(metadata.Dist.Integrity, "-"); ok && algorithm == "sha512" {
+		err = verifyDigest(source, data, "sha512", digest)
+	} else {
+		err = verifyDigest(source, data, "sha1", metadata.Dist.Shasum)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bundle, err := bundleTarGz(data, ".js", "//")
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack %s: %v", path.Base(metadata.Dist.Tarball), err)
+	}
+
+	return &fetchedArtifact{
+		filename: fmt.Sprintf("%s-%s.js", safeFilename(name), safeFilename(metadata.Version)),
+		data:     bundle,
+		source:   source,
+	}, nil
+}
+
+// ociReference is a parsed image reference
+type ociReference struct {
+	registry   string
+	repository string
+	reference  string // Tag or digest
+}
+
+// parseOCIReference parses [registry/]repository[:tag|@digest] with Docker Hub defaults
+func parseOCIReference(ref string) (ociReference, error) {
+	parsed := ociReference{registry: "registry-1.docker.io", reference: "latest"}
+
+	name := ref
+	if before, digest, ok := strings.Cut(ref, "@"); ok {
+		name, parsed.reference = before, digest
+	} else if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		name, parsed.reference = ref[:i], ref[i+1:]
+	}
+
+	// The first component is a registry if it looks like a host
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		parsed.registry, name = first, rest
+		if parsed.registry == "docker.io" {
+			parsed.registry = "registry-1.docker.io"
+		}
+	}
+	if parsed.registry == "registry-1.docker.io" && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	if name == "" || parsed.reference == "" {
+		return parsed, fmt.Errorf("invalid image reference %q", ref)
+	}
+	parsed.repository = name
+	return parsed, nil
+}
+
+// ociRegistries returns the registries in AEGONG_OCI_REGISTRIES. When set,
+// only these can be audited from, and they may be on private addresses.
+func ociRegistries() []string {
+	var registries []string
+	for _, registry := range strings.Split(os.Getenv("AEGONG_OCI_REGISTRIES"), ",") {
+		registry = strings.ToLower(strings.TrimSpace(registry))
+		if registry == "docker.io" {
+			registry = "registry-1.docker.io"
+		}
+		if registry != "" {
+			registries = append(registries, registry)
+		}
+	}
+	return registries
+}
+
+// hostname strips the port from a host
+func hostname(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		return name
+	}
+	return host
+}
+
+// dialRegistry connects to a registry, refusing non-public addresses unless
+// the registry is configured
+func dialRegistry(ctx context.Context, network, addr string) (net.Conn, error) {
+	host := hostname(addr)
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if !slices.ContainsFunc(ociRegistries(), func(registry string) bool { return strings.EqualFold(hostname(registry), host) }) {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			if ip := net.ParseIP(hostname(address)); !publicAddress(ip) {
+				return fmt.Errorf("refusing to connect to %s at non-public address %s", host, hostname(address))
+			}
+			return nil
+		}
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// publicAddress reports whether an address is reachable on the internet
+// rather than loopback, private, link-local (such as cloud metadata) or
+// multicast
+func publicAddress(ip net.IP) bool {
+	return ip != nil && !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// ociClient talks to a registry's v2 API, fetching anonymous tokens on demand
+type ociClient struct {
+	ref   ociReference
+	token string
+}
+
+func (c *ociClient) get(ctx context.Context, urlPath string, accept []string) ([]byte, string, error) {
+	endpoint := fmt.Sprintf("%s://%s/v2/%s/%s", ociScheme, c.ref.registry, c.ref.repository, urlPath)
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, mediaType := range accept {
+			req.Header.Add("Accept", mediaType)
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+
+		resp, err := ociFetchClient.Do(req)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to fetch %s: %v", endpoint, err)
+		}
+		if resp.StatusCode == http.StatusUnauthorized && c.token == "" {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if err := c.authenticate(ctx, challenge); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, "", fmt.Errorf("failed to fetch %s: %s", endpoint, resp.Status)
+		}
+
+		data, err := readLimited(resp)
+		return data, resp.Header.Get("Content-Type"), err
+	}
+	return nil, "", fmt.Errorf("registry rejected anonymous access to %s", c.ref.repository)
+}
+
+// authenticate answers a Bearer challenge with an anonymous pull token
+func (c *ociClient) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return fmt.Errorf("unsupported registry authentication %q", scheme)
+	}
+
+	fields := make(map[string]string)
+	for _, match := range regexp.MustCompile(`(\w+)="([^"]*)"`).FindAllStringSubmatch(params, -1) {
+		fields[match[1]] = match[2]
+	}
+	if fields["realm"] == "" {
+		return fmt.Errorf("registry challenge has no token realm")
+	}
+	// The registry names the realm, so it is held to the registry's own host
+	realm, err := url.Parse(fields["realm"])
+	if err != nil || realm.Scheme != ociScheme || realm.Host == "" {
+		return fmt.Errorf("registry token realm %q is not an %s URL", fields["realm"], ociScheme)
+	}
+	if !c.trustedRealm(realm.Host) {
+		return fmt.Errorf("registry token realm %s is not on registry %s", realm.Host, c.ref.registry)
+	}
+
+	query := realm.Query()
+	if fields["service"] != "" {
+		query.Set("service", fields["service"])
+	}
+	query.Set("scope", fmt.Sprintf("repository:%s:pull", c.ref.repository))
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := ociFetchClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch registry token: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch registry token: %s", resp.Status)
+	}
+	data, err := readLimited(resp)
+	if err != nil {
+		return err
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return fmt.Errorf("failed to parse registry token: %v", err)
+	}
+	c.token = token.Token
+	if c.token == "" {
+		c.token = token.AccessToken
+	}
+	return nil
+}
+
+// trustedRealm reports whether a token realm host is the registry itself, a
+// configured registry or a known token service
+func (c *ociClient) trustedRealm(host string) bool {
+	host = strings.ToLower(host)
+	return host == strings.ToLower(c.ref.registry) || slices.Contains(ociRegistries(), host) || slices.Contains(ociAuthHosts, hostname(host))
+}
+
+// verifyManifest checks a manifest fetched by digest against that digest, so
+// a registry can't serve any manifest under a digest it was asked for
+func verifyManifest(data []byte, reference string) error {
+	algorithm, expected, byDigest := strings.Cut(reference, ":")
+	if !byDigest {
+		return nil
+	}
+	if algorithm != "sha256" {
+		return fmt.Errorf("unsupported manifest digest algorithm %q", algorithm)
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != strings.ToLower(expected) {
+		return fmt.Errorf("registry served a manifest that does not match %s", reference)
+	}
+	return nil
+}
+
+// fetchOCI downloads the top layer of a container image
+func fetchOCI(ctx context.Context, rawURL, refString string) (*fetchedArtifact, error) {
+	ref, err := parseOCIReference(refString)
+	if err != nil {
+		return nil, err
+	}
+	if registries := ociRegistries(); len(registries) > 0 && !slices.Contains(registries, strings.ToLower(ref.registry)) {
+		return nil, fmt.Errorf("registry %s is not one of AEGONG_OCI_REGISTRIES", ref.registry)
+	}
+	client := &ociClient{ref: ref}
+
+	manifestTypes := []string{
+		"application/vnd.oci.image.index.v1+json",
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+	}
+
+	type descriptor struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+		Size      int64  `json:"size"`
+		Platform  struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+		} `json:"platform"`
+	}
+	var manifest struct {
+		Manifests []descriptor `json:"manifests"`
+		Layers    []descriptor `json:"layers"`
+	}
+
+	// The digest of the manifest first fetched is the one images are pinned by
+	var manifestDigest string
+	reference := ref.reference
+	for i := 0; i < 2; i++ {
+		data, _, err := client.get(ctx, "manifests/"+reference, manifestTypes)
+		if err != nil {
+			return nil, err
+		}
+		if err := verifyManifest(data, reference); err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			sum := sha256.Sum256(data)
+			manifestDigest = hex.EncodeToString(sum[:])
+		}
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse image manifest: %v", err)
+		}
+		if len(manifest.Manifests) == 0 {
+			break
+		}
+
+		// Multi-platform index: follow the linux/amd64 image
+		reference = ""
+		for _, m := range manifest.Manifests {
+			if m.Platform.OS == "linux" && m.Platform.Architecture == "amd64" {
+				reference = m.Digest
+				break
+			}
+		}
+		if reference == "" {
+			return nil, fmt.Errorf("image %s has no linux/amd64 variant", refString)
+		}
+		manifest.Manifests = nil
+	}
+	if len(manifest.Layers) == 0 {
+		return nil, fmt.Errorf("image %s has no layers", refString)
+	}
+
+	// The top layer holds what the image adds on top of its base
+	layer := manifest.Layers[len(manifest.Layers)-1]
+	if layer.Size > maxFetchBytes() {
+		return nil, fmt.Errorf("image layer is %d bytes, over the %d byte limit", layer.Size, maxFetchBytes())
+	}
+	data, _, err := client.get(ctx, "blobs/"+layer.Digest, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &aegong.ArtifactSource{
+		URL:            rawURL,
+		Kind:           "oci",
+		DownloadURL:    fmt.Sprintf("%s://%s/v2/%s/blobs/%s", ociScheme, ref.registry, ref.repository, layer.Digest),
+		Image:          ref.registry + "/" + ref.repository,
+		ManifestDigest: manifestDigest,
+	}
+	algorithm, digest, _ := strings.Cut(layer.Digest, ":")
+	if err := verifyDigest(source, data, algorithm, digest); err != nil {
+		return nil, err
+	}
+
+	// Layers are usually gzipped tarballs; audit the uncompressed tar so its strings are visible
+	if gz, err := gzip.NewReader(bytes.NewReader(data)); err == nil {
+		data, err = io.ReadAll(io.LimitReader(gz, maxFetchBytes()+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress image layer: %v", err)
+		}
+		if int64(len(data)) > maxFetchBytes() {
+			return nil, fmt.Errorf("decompressed image layer is over the %d byte limit", maxFetchBytes())
+		}
+	}
+
+	name := path.Base(ref.repository)
+	if !strings.HasPrefix(ref.reference, "sha256:") {
+		name += "-" + ref.reference
+	}
+	return &fetchedArtifact{filename: safeFilename(name) + ".bin", data: data, source: source}, nil
+}
+
+// fetchGet issues a GET and fails on non-200 responses or oversized bodies
+func fetchGet(ctx context.Context, rawURL string, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %v", rawURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch %s: %s", rawURL, resp.Status)
+	}
+	if resp.ContentLength > maxFetchBytes() {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s is %d bytes, over the %d byte limit", rawURL, resp.ContentLength, maxFetchBytes())
+	}
+	return resp, nil
+}
+
+func fetchBody(ctx context.Context, rawURL string, headers map[string]string) ([]byte, error) {
+	resp, err := fetchGet(ctx, rawURL, headers)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return readLimited(resp)
+}
+
+func fetchJSON(ctx context.Context, rawURL string, headers map[string]string, v interface{}) error {
+	data, err := fetchBody(ctx, rawURL, headers)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", rawURL, err)
+	}
+	return nil
+}
+
+// readLimited reads a response body, failing once it passes the size limit
+func readLimited(resp *http.Response) ([]byte, error) {
+	limit := maxFetchBytes()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %v", resp.Request.URL, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s is over the %d byte limit", resp.Request.URL, limit)
+	}
+	return data, nil
+}
+
+// verifyDigest checks data against a published checksum and records it on source
+func verifyDigest(source *aegong.ArtifactSource, data []byte, algorithm, expected string) error {
+	if expected == "" {
+		return fmt.Errorf("%s publishes no checksum for %s", source.Kind, source.DownloadURL)
+	}
+
+	var actual string
+	switch algorithm {
+	case "sha256":
+		sum := sha256.Sum256(data)
+		actual = hex.EncodeToString(sum[:])
+	case "sha1":
+		sum := sha1.Sum(data)
+		actual = hex.EncodeToString(sum[:])
+	case "sha512":
+		// npm publishes SHA-512 integrity as base64
+		sum := sha512.Sum512(data)
+		actual = base64.StdEncoding.EncodeToString(sum[:])
+	default:
+		return fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s %s, got %s", source.DownloadURL, algorithm, expected, actual)
+	}
+
+	source.Digest = expected
+	source.DigestAlgorithm = algorithm
+	source.Verified = true
+	return nil
+}
+
+// bundleTarGz concatenates the source files with the given extension in a .tar.gz
+func bundleTarGz(data []byte, ext, comment string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+
+	files := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg || !strings.HasSuffix(header.Name, ext) || header.Size > maxBundledFileBytes {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[header.Name] = content
+	}
+	return bundleSources(files, comment)
+}
+
+// bundleZip concatenates the source files with the given extension in a zip or wheel
+func bundleZip(data []byte, ext, comment string) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !strings.HasSuffix(f.Name, ext) || f.UncompressedSize64 > maxBundledFileBytes {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, maxBundledFileBytes))
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[f.Name] = content
+	}
+	return bundleSources(files, comment)
+}
+
+// bundleSources joins source files into one script, each headed by its path
+func bundleSources(files map[string][]byte, comment string) ([]byte, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("archive contains no source files")
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var bundle bytes.Buffer
+	for _, name := range names {
+		if bundle.Len()+len(files[name]) > maxBundleBytes {
+			fmt.Fprintf(&bundle, "%s ---- bundle truncated at %d bytes ----\n", comment, maxBundleBytes)
+			break
+		}
+		bundle.WriteString(aegong.BundleHeader(comment, name))
+		bundle.Write(files[name])
+		bundle.WriteString("\n")
+	}
+	return bundle.Bytes(), nil
+}
+
+// splitNpmSpec splits name@version, keeping the leading "@" of scoped packages
+func splitNpmSpec(spec string) (string, string) {
+	if i := strings.LastIndex(spec, "@"); i > 0 {
+		return spec[:i], spec[i+1:]
+	}
+	return spec, ""
+}
+
+func isHexDigest(s string, size int) bool {
+	if len(s) != size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testRegistry serves fixed responses by path and points the registry URLs at itself
+func testRegistry(t *testing.T, routes map[string][]byte) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+
+	oldHF, oldPyPI, oldNpm := huggingFaceBaseURL, pypiBaseURL, npmRegistryURL
+	huggingFaceBaseURL, pypiBaseURL, npmRegistryURL = server.URL, server.URL, server.URL
+	t.Cleanup(func() { huggingFaceBaseURL, pypiBaseURL, npmRegistryURL = oldHF, oldPyPI, oldNpm })
+	return server
+}
+
+func testWheel(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"helper/agent.py":             "def act(observation):\n    return plan(observation)\n",
+		"helper-1.0.dist-info/RECORD": "helper/agent.py,,\n",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func testTarball(t *testing.T) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	content := []byte("module.exports = function act() {}\n")
+	if err := tw.WriteHeader(&tar.Header{Name: "package/index.js", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(content)
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// TestFetchPyPI tests that wheels are downloaded, verified and bundled
+func TestFetchPyPI(t *testing.T) {
+	wheel := testWheel(t)
+	sum := sha256.Sum256(wheel)
+	digest := hex.EncodeToString(sum[:])
+
+	var server *httptest.Server
+	metadata := func(digest string) []byte {
+		return []byte(fmt.Sprintf(`{"info":{"version":"1.0"},"urls":[
+			{"packagetype":"sdist","filename":"helper-1.0.tar.gz","url":"%[1]s/files/helper-1.0.tar.gz","digests":{"sha256":"00"}},
+			{"packagetype":"bdist_wheel","filename":"helper-1.0-py3-none-any.whl","url":"%[1]s/files/helper.whl","digests":{"sha256":"%[2]s"}}]}`,
+			server.URL, digest))
+	}
+	routes := map[string][]byte{"/files/helper.whl": wheel}
+	server = testRegistry(t, routes)
+	routes["/pypi/helper/json"] = metadata(digest)
+	routes["/pypi/tampered/json"] = metadata(strings.Repeat("0", 64))
+
+	artifact, err := fetchArtifact(context.Background(), "https://pypi.org/project/helper/")
+	if err != nil {
+		t.Fatalf("Fetch should succeed: %v", err)
+	}
+	if artifact.filename != "helper-1.0.py" {
+		t.Fatalf("Wheel should be bundled as helper-1.0.py, got %q", artifact.filename)
+	}
+	if !strings.Contains(string(artifact.data), "# ---- helper/agent.py ----") || strings.Contains(string(artifact.data), "RECORD") {
+		t.Fatalf("Bundle should contain only the Python sources, got:\n%s", artifact.data)
+	}
+	if !artifact.source.Verified || artifact.source.Digest != digest || artifact.source.Kind != "pypi" {
+		t.Fatalf("Source should record the verified digest, got %+v", artifact.source)
+	}
+
+	if _, err := fetchArtifact(context.Background(), "pypi:tampered"); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Fetch should reject a checksum mismatch, got %v", err)
+	}
+}
+
+// TestFetchHuggingFace tests that file names taken from the URL are reduced to safe characters
+func TestFetchHuggingFace(t *testing.T) {
+	content := []byte("def act(observation):\n    return observation\n")
+	testRegistry(t, map[string][]byte{
+		"/acme/agent/resolve/main/agent.py":                  content,
+		"/acme/agent/resolve/main/dir/..\\..\\uploads\\x.py": content,
+		"/acme/agent/resolve/main/dir/..":                    content,
+	})
+
+	for rawURL, want := range map[string]string{
+		"https://huggingface.co/acme/agent/blob/main/agent.py":                     "agent.py",
+		"https://huggingface.co/acme/agent/blob/main/dir/..%5C..%5Cuploads%5Cx.py": "_.._uploads_x.py",
+		"https://huggingface.co/acme/agent/resolve/main/dir/%2E%2E":                "",
+	} {
+		artifact, err := fetchArtifact(context.Background(), rawURL)
+		if err != nil {
+			t.Fatalf("%s: Fetch should succeed: %v", rawURL, err)
+		}
+		if artifact.filename != want {
+			t.Errorf("%s: Should save as %q, got %q", rawURL, want, artifact.filename)
+		}
+		if artifact.filename != "" {
+			if _, err := uploadPath(artifact.filename); err != nil {
+				t.Errorf("%s: File name should stay inside uploads/: %v", rawURL, err)
+			}
+		}
+	}
+}
+
+// TestFetchNpm tests that npm tarballs are verified against their integrity hash
+func TestFetchNpm(t *testing.T) {
+	tarball := testTarball(t)
+	sum := sha512.Sum512(tarball)
+	integrity := "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+
+	routes := map[string][]byte{"/files/agent-2.0.0.tgz": tarball}
+	server := testRegistry(t, routes)
+	routes["/@acme/agent/2.0.0"] = []byte(fmt.Sprintf(`{"version":"2.0.0","dist":{"tarball":"%s/files/agent-2.0.0.tgz","integrity":"%s"}}`,
+		server.URL, integrity))
+
+	artifact, err := fetchArtifact(context.Background(), "npm:@acme/agent@2.0.0")
+	if err != nil {
+		t.Fatalf("Fetch should succeed: %v", err)
+	}
+	if artifact.filename != "_acme_agent-2.0.0.js" {
+		t.Fatalf("Tarball should be bundled as a .js file, got %q", artifact.filename)
+	}
+	if !strings.Contains(string(artifact.data), "// ---- package/index.js ----") {
+		t.Fatalf("Bundle should contain index.js, got:\n%s", artifact.data)
+	}
+	if !artifact.source.Verified || artifact.source.DigestAlgorithm != "sha512" {
+		t.Fatalf("Source should record the verified integrity hash, got %+v", artifact.source)
+	}
+}
+
+// TestFetchArtifactLimits tests size limits and unsupported sources
+func TestFetchArtifactLimits(t *testing.T) {
+	routes := map[string][]byte{"/files/helper.whl": testWheel(t)}
+	server := testRegistry(t, routes)
+	routes["/pypi/helper/json"] = []byte(fmt.Sprintf(`{"info":{"version":"1.0"},"urls":[
+		{"packagetype":"bdist_wheel","filename":"helper-1.0-py3-none-any.whl","url":"%s/files/helper.whl","digests":{"sha256":"00"}}]}`, server.URL))
+
+	t.Setenv("AEGONG_MAX_FETCH_BYTES", "64")
+	if _, err := fetchArtifact(context.Background(), "pypi:helper"); err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Fatalf("Fetch should enforce AEGONG_MAX_FETCH_BYTES, got %v", err)
+	}
+
+	for _, source := range []string{"http://pypi.org/project/helper", "https://example.com/agent.py", "ftp:agent"} {
+		if _, err := fetchArtifact(context.Background(), source); err == nil {
+			t.Fatalf("Fetch should reject %s", source)
+		}
+	}
+}
+
+// TestParseOCIReference tests Docker Hub defaults and explicit registries
+func TestParseOCIReference(t *testing.T) {
+	cases := []struct {
+		ref  string
+		want ociReference
+	}{
+		{"python", ociReference{"registry-1.docker.io", "library/python", "latest"}},
+		{"acme/agent:1.2", ociReference{"registry-1.docker.io", "acme/agent", "1.2"}},
+		{"ghcr.io/acme/agent@sha256:abc", ociReference{"ghcr.io", "acme/agent", "sha256:abc"}},
+		{"localhost:5000/agent", ociReference{"localhost:5000", "agent", "latest"}},
+	}
+	for _, c := range cases {
+		got, err := parseOCIReference(c.ref)
+		if err != nil || got != c.want {
+			t.Fatalf("Reference %q should parse as %+v, got %+v (%v)", c.ref, c.want, got, err)
+		}
+	}
+}
+
+// TestFetchOCI tests that image manifests are verified and registries are held to public or configured hosts
+func TestFetchOCI(t *testing.T) {
+	layer := []byte("agent binary layer")
+	layerSum := sha256.Sum256(layer)
+	layerDigest := "sha256:" + hex.EncodeToString(layerSum[:])
+	manifest := []byte(`{"layers":[{"digest":"` + layerDigest + `","size":18}]}`)
+	manifestSum := sha256.Sum256(manifest)
+	manifestDigest := hex.EncodeToString(manifestSum[:])
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/v2/acme/agent/manifests/"):
+			// Served whatever is asked for, as a dishonest registry would
+			w.Write(manifest)
+		case r.URL.Path == "/v2/acme/agent/blobs/"+layerDigest:
+			w.Write(layer)
+		case r.URL.Path == "/v2/acme/private/manifests/1.0":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="http://169.254.169.254/latest/meta-data",service="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	oldScheme := ociScheme
+	ociScheme = "http"
+	t.Cleanup(func() { ociScheme = oldScheme })
+	registry := strings.TrimPrefix(server.URL, "http://")
+
+	if _, err := fetchArtifact(context.Background(), "oci://"+registry+"/acme/agent:1.0"); err == nil || !strings.Contains(err.Error(), "non-public address") {
+		t.Fatalf("Fetch should refuse a registry on a loopback address, got %v", err)
+	}
+	t.Setenv("AEGONG_OCI_REGISTRIES", "ghcr.io")
+	if _, err := fetchArtifact(context.Background(), "oci://"+registry+"/acme/agent:1.0"); err == nil || !strings.Contains(err.Error(), "AEGONG_OCI_REGISTRIES") {
+		t.Fatalf("Fetch should refuse a registry that is not configured, got %v", err)
+	}
+
+	t.Setenv("AEGONG_OCI_REGISTRIES", "ghcr.io,"+registry)
+	artifact, err := fetchArtifact(context.Background(), "oci://"+registry+"/acme/agent:1.0")
+	if err != nil {
+		t.Fatalf("Fetch from a configured registry should succeed: %v", err)
+	}
+	if artifact.source.ManifestDigest != manifestDigest || artifact.source.Image != registry+"/acme/agent" || !artifact.source.Verified {
+		t.Fatalf("Source should record the image and its manifest digest, got %+v", artifact.source)
+	}
+	if _, err := fetchArtifact(context.Background(), "oci://"+registry+"/acme/agent@sha256:"+manifestDigest); err != nil {
+		t.Fatalf("Fetch by the manifest's digest should succeed: %v", err)
+	}
+	if _, err := fetchArtifact(context.Background(), "oci://"+registry+"/acme/agent@sha256:"+strings.Repeat("c", 64)); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("Fetch should reject a manifest that does not match its digest, got %v", err)
+	}
+	if _, err := fetchArtifact(context.Background(), "oci://"+registry+"/acme/private:1.0"); err == nil || !strings.Contains(err.Error(), "not on registry") {
+		t.Fatalf("Fetch should refuse a token realm on another host, got %v", err)
+	}
+}
+
+// TestPublicAddress tests which addresses registries may be reached at without being configured
+func TestPublicAddress(t *testing.T) {
+	for address, public := range map[string]bool{
+		"140.82.112.3": true, "2606:4700::1111": true, "127.0.0.1": false, "::1": false, "10.0.0.8": false,
+		"192.168.1.1": false, "169.254.169.254": false, "fe80::1": false, "0.0.0.0": false, "::ffff:127.0.0.1": false,
+	} {
+		if publicAddress(net.ParseIP(address)) != public {
+			t.Errorf("%s: Should be public: %v", address, public)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+// Client for the AEGONG Agent Auditor API 1.0.0, generated from openapi.json by `make api-client`.
+// Do not edit; change the specification and regenerate.
+// Types are in static/ts/aegong-api.ts.
+
+// AegongAPIError is a response outside 2xx; body is its JSON, if any
+class AegongAPIError extends Error {
+    constructor(status, message, body) {
+        super(message);
+        this.status = status;
+        this.body = body;
+    }
+}
+
+class AegongAPI {
+    constructor(options = {}) {
+        this.options = options;
+    }
+
+    // Export reports as a .tar.gz archive
+    exportArchive(query = {}) {
+        return this.request("GET", `/api/admin/archive`, query, undefined, "", "blob");
+    }
+
+    // Import reports from an archive
+    importArchive(body, query = {}) {
+        return this.request("POST", `/api/admin/archive`, query, body, "application/gzip", "json");
+    }
+
+    // List detectors and SHIELD modules with their settings
+    listComponents() {
+        return this.request("GET", `/api/admin/components`, undefined, undefined, "", "json");
+    }
+
+    // Enable, disable or set the confidence threshold of a component
+    updateComponent(name, body) {
+        return this.request("PATCH", `/api/admin/components/${encodeURIComponent(name)}`, undefined, body, "application/json", "json");
+    }
+
+    // Dry-run a custom vector definition on a payload or a saved report
+    testDetector(body) {
+        return this.request("POST", `/api/admin/detectors/test`, undefined, body, "application/json", "json");
+    }
+
+    // Count false positive marks per vector and pattern
+    getFeedbackSummary() {
+        return this.request("GET", `/api/admin/feedback`, undefined, undefined, "", "json");
+    }
+
+    // List the legal holds in force
+    listLegalHolds() {
+        return this.request("GET", `/api/admin/holds`, undefined, undefined, "", "json");
+    }
+
+    // Place a legal hold
+    placeLegalHold(body) {
+        return this.request("POST", `/api/admin/holds`, undefined, body, "application/json", "json");
+    }
+
+    // Release a legal hold
+    releaseLegalHold(id) {
+        return this.request("DELETE", `/api/admin/holds/${encodeURIComponent(id)}`, undefined, undefined, "", "none");
+    }
+
+    // List the notification rules
+    listNotificationRules() {
+        return this.request("GET", `/api/admin/notifications`, undefined, undefined, "", "json");
+    }
+
+    // Add a notification rule
+    createNotificationRule(body) {
+        return this.request("POST", `/api/admin/notifications`, undefined, body, "application/json", "json");
+    }
+
+    // Replace a notification rule
+    updateNotificationRule(id, body) {
+        return this.request("PUT", `/api/admin/notifications/${encodeURIComponent(id)}`, undefined, body, "application/json", "json");
+    }
+
+    // Delete a notification rule
+    deleteNotificationRule(id) {
+        return this.request("DELETE", `/api/admin/notifications/${encodeURIComponent(id)}`, undefined, undefined, "", "none");
+    }
+
+    // List the quotas and usage of every tenant with limits of its own or usage today
+    listQuotas() {
+        return this.request("GET", `/api/admin/quotas`, undefined, undefined, "", "json");
+    }
+
+    // Delete a report with its anchor receipt, comments, voice reports and upload
+    deleteReport(hash) {
+        return this.request("DELETE", `/api/admin/reports/${encodeURIComponent(hash)}`, undefined, undefined, "", "none");
+    }
+
+    // List the rulesets the engine has run, newest first
+    listRulesets() {
+        return this.request("GET", `/api/admin/rulesets`, undefined, undefined, "", "json");
+    }
+
+    // Get the server's status and warnings
+    getStatus() {
+        return this.request("GET", `/api/admin/status`, undefined, undefined, "", "json");
+    }
+
+    // Delete an upload, keeping its hash
+    deleteUpload(filename) {
+        return this.request("DELETE", `/api/admin/uploads/${encodeURIComponent(filename)}`, undefined, undefined, "", "none");
+    }
+
+    // Get the voice report settings
+    getVoiceConfig() {
+        return this.request("GET", `/api/admin/voice`, undefined, undefined, "", "json");
+    }
+
+    // Change the voice report settings
+    updateVoiceConfig(body) {
+        return this.request("PATCH", `/api/admin/voice`, undefined, body, "application/json", "json");
+    }
+
+    // Admit a Kubernetes pod or workload only if its images and agents passed their audits
+    reviewAdmission(body) {
+        return this.request("POST", `/api/admission/validate`, undefined, body, "application/json", "json");
+    }
+
+    // List the registered agents
+    listAgents(query = {}) {
+        return this.request("GET", `/api/agents`, query, undefined, "", "json");
+    }
+
+    // Register an agent
+    registerAgent(body) {
+        return this.request("POST", `/api/agents`, undefined, body, "application/json", "json");
+    }
+
+    // Get a registered agent
+    getAgent(id) {
+        return this.request("GET", `/api/agents/${encodeURIComponent(id)}`, undefined, undefined, "", "json");
+    }
+
+    // Replace a registered agent's details or move it along its lifecycle
+    updateAgent(id, body) {
+        return this.request("PUT", `/api/agents/${encodeURIComponent(id)}`, undefined, body, "application/json", "json");
+    }
+
+    // Remove an agent from the registry, keeping its reports
+    deleteAgent(id) {
+        return this.request("DELETE", `/api/agents/${encodeURIComponent(id)}`, undefined, undefined, "", "none");
+    }
+
+    // Get the public key attestations are signed with
+    getAttestationKey() {
+        return this.request("GET", `/api/attestation/key`, undefined, undefined, "", "json");
+    }
+
+    // Download an agent from a registry URL and audit it
+    auditURL(body, query = {}) {
+        return this.request("POST", `/api/audit-url`, query, body, "application/json", "json");
+    }
+
+    // Audit an upload and wait for its report
+    auditUpload(filename, body, query = {}) {
+        return this.request("POST", `/api/audit/${encodeURIComponent(filename)}`, query, body, "application/json", "json");
+    }
+
+    // Describe what each detector and SHIELD module checks
+    listDetectors() {
+        return this.request("GET", `/api/detectors`, undefined, undefined, "", "json");
+    }
+
+    // List audit jobs, newest first
+    listJobs() {
+        return this.request("GET", `/api/jobs`, undefined, undefined, "", "json");
+    }
+
+    // Audit an upload in the background
+    createJob(body) {
+        return this.request("POST", `/api/jobs`, undefined, body, "application/json", "json");
+    }
+
+    // Get an audit job
+    getJob(id) {
+        return this.request("GET", `/api/jobs/${encodeURIComponent(id)}`, undefined, undefined, "", "json");
+    }
+
+    // Cancel a queued or running job
+    cancelJob(id) {
+        return this.request("DELETE", `/api/jobs/${encodeURIComponent(id)}`, undefined, undefined, "", "none");
+    }
+
+    // Get the findings of a job's audit so far
+    getPartialReport(id) {
+        return this.request("GET", `/api/jobs/${encodeURIComponent(id)}/partial`, undefined, undefined, "", "json");
+    }
+
+    // Get the organization's risk posture and its history
+    getPosture(query = {}) {
+        return this.request("GET", `/api/posture`, query, undefined, "", "json");
+    }
+
+    // Get the caller's quotas and usage today, and the global ones
+    getQuota() {
+        return this.request("GET", `/api/quota`, undefined, undefined, "", "json");
+    }
+
+    // Get a saved report
+    getReport(hash, query = {}) {
+        return this.request("GET", `/api/report/${encodeURIComponent(hash)}`, query, undefined, "", "json");
+    }
+
+    // Delete a report with its anchor receipt, comments, voice reports and upload
+    deleteSavedReport(hash) {
+        return this.request("DELETE", `/api/report/${encodeURIComponent(hash)}`, undefined, undefined, "", "none");
+    }
+
+    // Get the transparency log receipt of a report
+    getReportAnchor(hash) {
+        return this.request("GET", `/api/report/${encodeURIComponent(hash)}/anchor`, undefined, undefined, "", "json");
+    }
+
+    // Hide a report from the default report listing
+    archiveReport(hash) {
+        return this.request("POST", `/api/report/${encodeURIComponent(hash)}/archive`, undefined, undefined, "", "json");
+    }
+
+    // Put an archived report back in the default report listing
+    restoreReport(hash) {
+        return this.request("DELETE", `/api/report/${encodeURIComponent(hash)}/archive`, undefined, undefined, "", "none");
+    }
+
+    // Get a signed in-toto attestation that the agent was audited
+    getReportAttestation(hash) {
+        return this.request("GET", `/api/report/${encodeURIComponent(hash)}/attestation`, undefined, undefined, "", "json");
+    }
+
+    // List the comments on a report, oldest first
+    listComments(hash, query = {}) {
+        return this.request("GET", `/api/report/${encodeURIComponent(hash)}/comments`, query, undefined, "", "json");
+    }
+
+    // Comment on a report or one of its findings
+    addComment(hash, body) {
+        return this.request("POST", `/api/report/${encodeURIComponent(hash)}/comments`, undefined, body, "application/json", "json");
+    }
+
+    // Export a redacted report for sharing
+    exportReport(hash, query = {}) {
+        return this.request("GET", `/api/report/${encodeURIComponent(hash)}/export`, query, undefined, "", "json");
+    }
+
+    // Mark a finding of a report as a false positive
+    markFalsePositive(hash, body) {
+        return this.request("POST", `/api/report/${encodeURIComponent(hash)}/feedback`, undefined, body, "application/json", "json");
+    }
+
+    // Re-audit the agent of a report under the current ruleset
+    rescoreReport(hash) {
+        return this.request("POST", `/api/report/${encodeURIComponent(hash)}/rescore`, undefined, undefined, "", "json");
+    }
+
+    // Create a time-limited link to a report, optionally redacted
+    shareReport(hash, body) {
+        return this.request("POST", `/api/report/${encodeURIComponent(hash)}/share`, undefined, body, "application/json", "json");
+    }
+
+    // List saved reports
+    listReports(query = {}) {
+        return this.request("GET", `/api/reports`, query, undefined, "", "json");
+    }
+
+    // Search the agent names, evidence and recommendations of saved reports
+    searchReports(query = {}) {
+        return this.request("GET", `/api/search`, query, undefined, "", "json");
+    }
+
+    // Open a share link
+    getSharedReport(token) {
+        return this.request("GET", `/api/shared/${encodeURIComponent(token)}`, undefined, undefined, "", "json");
+    }
+
+    // Get dashboard statistics
+    getStats(query = {}) {
+        return this.request("GET", `/api/stats`, query, undefined, "", "json");
+    }
+
+    // Upload an agent, optionally with a signature bundle and manifest
+    uploadAgent(body) {
+        return this.request("POST", `/api/upload`, undefined, body, "multipart/form-data", "json");
+    }
+
+    // Check whether an upload is an AI agent
+    validateUpload(filename) {
+        return this.request("GET", `/api/validate/${encodeURIComponent(filename)}`, undefined, undefined, "", "json");
+    }
+
+    // Generate or fetch the voice report of a report
+    getVoiceReport(hash, query = {}) {
+        return this.request("GET", `/api/voice/${encodeURIComponent(hash)}`, query, undefined, "", "json");
+    }
+
+    // Run a GraphQL query over saved reports
+    graphql(body) {
+        return this.request("POST", `/graphql`, undefined, body, "application/json", "json");
+    }
+
+    // Sends a request and decodes its response; responses outside 2xx reject with an AegongAPIError
+    async request(method, path, query, body, contentType, expect) {
+        const url = new URL((this.options.baseURL || "") + path, window.location.origin);
+        Object.keys(query || {}).forEach(key => {
+            const value = query[key];
+            if (value !== undefined && value !== null && value !== "") {
+                url.searchParams.set(key, String(value));
+            }
+        });
+
+        const headers = Object.assign({}, this.options.headers ? this.options.headers() : {});
+        if (this.options.token) {
+            headers["Authorization"] = `Bearer ${this.options.token}`;
+        }
+        let payload;
+        if (body !== undefined) {
+            if (contentType === "application/json") {
+                payload = JSON.stringify(body);
+            } else {
+                payload = body;
+            }
+            // FormData sets its own multipart boundary
+            if (contentType !== "multipart/form-data") {
+                headers["Content-Type"] = contentType;
+            }
+        }
+
+        const response = await fetch(url.toString(), { method, headers, body: payload });
+        if (!response.ok) {
+            const text = await response.text();
+            let parsed;
+            try {
+                parsed = JSON.parse(text);
+            } catch (error) {
+                parsed = undefined;
+            }
+            throw new AegongAPIError(response.status, (parsed && parsed.error) || text.trim() || response.statusText, parsed);
+        }
+        if (expect === "json") {
+            return response.json();
+        }
+        if (expect === "blob") {
+            return response.blob();
+        }
+        return undefined;
+    }
+}
+
+(window).AegongAPI = AegongAPI;
+(window).AegongAPIError = AegongAPIError;
@@ -0,0 +1,86 @@
+// Typed client for the AEGONG WebSocket protocol served at /ws.
+// Compile with `make ws-client`; the UI loads static/js/aegong-ws-client.js.
+class AegongWSClient {
+    constructor(url = AegongWSClient.defaultURL(), reconnectDelay = 5000) {
+        this.url = url;
+        this.reconnectDelay = reconnectDelay;
+        this.ws = null;
+        this.nextId = 1;
+        this.pending = new Map();
+        this.topics = new Set();
+        this.handlers = new Map();
+    }
+    static defaultURL() {
+        const protocol = window.location.protocol === "https:" ? "wss:" : "ws:";
+        return `${protocol}//${window.location.host}/ws`;
+    }
+    connect() {
+        this.ws = new WebSocket(this.url);
+        this.ws.onopen = () => {
+            // Restore subscriptions after a reconnect
+            this.topics.forEach(topic => this.send({ type: "subscribe", data: { topic } }));
+        };
+        this.ws.onmessage = (event) => {
+            this.dispatch(JSON.parse(event.data));
+        };
+        this.ws.onclose = () => {
+            this.pending.forEach(p => p.reject(new Error("connection closed")));
+            this.pending.clear();
+            setTimeout(() => this.connect(), this.reconnectDelay);
+        };
+    }
+    on(type, handler) {
+        if (!this.handlers.has(type)) {
+            this.handlers.set(type, new Set());
+        }
+        this.handlers.get(type).add(handler);
+        return () => this.handlers.get(type).delete(handler);
+    }
+    ping() {
+        return this.send({ type: "ping" });
+    }
+    subscribe(topic) {
+        this.topics.add(topic);
+        return this.send({ type: "subscribe", data: { topic } });
+    }
+    unsubscribe(topic) {
+        this.topics.delete(topic);
+        return this.send({ type: "unsubscribe", data: { topic } });
+    }
+    // Resolves with the audit ID once the server has accepted the audit
+    async startAudit(filename) {
+        const ack = await this.send({ type: "start_audit", data: { filename } });
+        return ack.data.audit_id;
+    }
+    cancel(auditId) {
+        return this.send({ type: "cancel", data: { audit_id: auditId } });
+    }
+    // Sends a command and resolves with its direct reply; "error" replies reject
+    send(command) {
+        var _a;
+        const id = (_a = command.id) !== null && _a !== void 0 ? _a : String(this.nextId++);
+        return new Promise((resolve, reject) => {
+            if (!this.ws || this.ws.readyState !== WebSocket.OPEN) {
+                reject(new Error("not connected"));
+                return;
+            }
+            this.pending.set(id, { resolve, reject });
+            this.ws.send(JSON.stringify(Object.assign(Object.assign({}, command), { id })));
+        });
+    }
+    dispatch(msg) {
+        var _a, _b;
+        if (msg.id && this.pending.has(msg.id)) {
+            const p = this.pending.get(msg.id);
+            this.pending.delete(msg.id);
+            if (msg.type === "error") {
+                p.reject(new Error(`${(_a = msg.data) === null || _a === void 0 ? void 0 : _a.code}: ${msg.message}`));
+            }
+            else {
+                p.resolve(msg);
+            }
+        }
+        (_b = this.handlers.get(msg.type)) === null || _b === void 0 ? void 0 : _b.forEach(handler => handler(msg));
+    }
+}
+window.AegongWSClient = AegongWSClient;
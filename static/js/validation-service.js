@@ -2,6 +2,7 @@ class AegongInterface {
     constructor() {
         this.ws = null;
         this.currentReport = null;
+        this.api = new AegongAPI({ headers: () => this.csrfHeaders() });
         this.init();
     }
 
@@ -9,9 +10,17 @@ class AegongInterface {
         this.setupEventListeners();
         this.connectWebSocket();
         this.loadHistory();
+        this.loadStats();
         this.updateStatus('Ready', 'ready');
     }
 
+    // The server sets the aegong_csrf cookie with the page and expects it back
+    // in a header on uploads and audits
+    csrfHeaders() {
+        const match = document.cookie.match(/(?:^|;\s*)aegong_csrf=([^;]+)/);
+        return match ? { 'X-CSRF-Token': match[1] } : {};
+    }
+
     setupEventListeners() {
         // File upload
         const fileInput = document.getElementById('fileInput');
@@ -34,31 +43,14 @@ class AegongInterface {
         // Action buttons
         document.getElementById('newAnalysisBtn').addEventListener('click', this.resetInterface.bind(this));
         document.getElementById('downloadReportBtn').addEventListener('click', this.downloadReport.bind(this));
+        document.getElementById('downloadSharedReportBtn').addEventListener('click', this.downloadSharedReport.bind(this));
     }
 
     connectWebSocket() {
-        const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        this.ws = new WebSocket(`${protocol}//${window.location.host}/ws`);
-
-        this.ws.onopen = () => {
-            console.log('WebSocket connected');
-        };
-
-        this.ws.onmessage = (event) => {
-            const message = JSON.parse(event.data);
-            this.handleWebSocketMessage(message);
-        };
-
-        this.ws.onclose = () => {
-            console.log('WebSocket disconnected');
-            setTimeout(() => this.connectWebSocket(), 5000);
-        };
-    }
-
-    handleWebSocketMessage(message) {
-        if (message.type === 'aegong_message') {
-            this.showAegongMessage(message.message);
-        }
+        this.ws = new AegongWSClient();
+        this.ws.on('aegong_message', (message) => this.showAegongMessage(message.message));
+        this.ws.on('audit_started', (message) => this.showAegongMessage(`Audit of ${message.data.filename} started`));
+        this.ws.connect();
     }
 
     showAegongMessage(message) {
@@ -114,21 +106,13 @@ class AegongInterface {
         uploadBtn.querySelector('.btn-loader').hidden = false;
 
         try {
-            const response = await fetch('/api/upload', {
-                method: 'POST',
-                body: formData
-            });
-
-            const result = await response.json();
-            
-            if (response.ok) {
-                this.startAnalysis(result.filename);
-            } else {
-                throw new Error(result.error || 'Upload failed');
-            }
+            const result = await this.api.uploadAgent(formData);
+            this.startAnalysis(result.filename);
         } catch (error) {
             console.error('Upload error:', error);
-            this.updateStatus('Upload failed', 'error');
+            // Rejected file types come with an explanation
+            const message = error.status === 415 && error.body ? error.body.message : 'Upload failed';
+            this.updateStatus(message, 'error');
             
             // Reset button
             uploadBtn.disabled = false;
@@ -156,34 +140,28 @@ class AegongInterface {
             console.log(`Starting analysis for ${filename}...`);
             const startTime = performance.now();
             
-            const response = await fetch(`/api/audit/${filename}`, {
-                method: 'POST'
-            });
-
-            const result = await response.json();
+            const result = await this.api.auditUpload(filename);
             
             // Log the actual processing time for future reference
             const processingTime = performance.now() - startTime;
             console.log(`Analysis completed in ${Math.round(processingTime)}ms`);
             
-            if (response.ok) {
-                this.currentReport = result;
-                
-                // Wait for the progress simulation to reach at least the "Finalizing report" step
-                // before showing results to ensure a smooth user experience
-                setTimeout(() => {
-                    this.showResults(result);
-                    this.loadHistory(); // Refresh history
-                }, 500); // Small delay to ensure progress animation looks natural
-            } else {
-                // Check if this is a "not an agent" error
-                if (result.error === "Not an AI agent" && result.validation) {
-                    this.showNotAgentError(result.validation, filename);
-                } else {
-                    throw new Error(result.error || result.message || 'Analysis failed');
-                }
-            }
+            this.currentReport = result;
+            
+            // Wait for the progress simulation to reach at least the "Finalizing report" step
+            // before showing results to ensure a smooth user experience
+            setTimeout(() => {
+                this.showResults(result);
+                this.loadHistory(); // Refresh history
+                this.loadStats();
+            }, 500); // Small delay to ensure progress animation looks natural
         } catch (error) {
+            // Check if this is a "not an agent" error
+            const body = error instanceof AegongAPIError ? error.body : null;
+            if (body && body.error === "Not an AI agent" && body.validation) {
+                this.showNotAgentError(body.validation, filename);
+                return;
+            }
             console.error('Analysis error:', error);
             this.updateStatus('Analysis failed', 'error');
             
@@ -567,6 +545,32 @@ class AegongInterface {
             `;
             
             threatsList.appendChild(threatItem);
+            this.explain(threatItem.querySelector('.threat-title'), `T${threat.vector + 1}`);
+        });
+    }
+
+    // Detector and SHIELD module documentation by name, loaded once
+    detectorDocs() {
+        if (!this.detectorDocsRequest) {
+            this.detectorDocsRequest = this.api.listDetectors()
+                .then(docs => Object.fromEntries(docs.map(doc => [doc.name, doc])))
+                .catch(() => ({}));
+        }
+        return this.detectorDocsRequest;
+    }
+
+    // Shows what a detector or SHIELD module checks as the element's tooltip
+    explain(element, name) {
+        this.detectorDocs().then(docs => {
+            const doc = docs[name];
+            if (!doc) {
+                return;
+            }
+            let tooltip = `${doc.description}\n\nLooks for:\n- ${doc.looks_for.join('\n- ')}`;
+            if (doc.false_positives && doc.false_positives.length > 0) {
+                tooltip += `\n\nCommon false positives:\n- ${doc.false_positives.join('\n- ')}`;
+            }
+            element.title = tooltip;
         });
     }
 
@@ -593,6 +597,7 @@ class AegongInterface {
             `;
             
             shieldsGrid.appendChild(shieldItem);
+            this.explain(shieldItem.querySelector('.shield-name'), name);
         });
     }
 
@@ -628,7 +633,45 @@ class AegongInterface {
         recommendations.forEach(rec => {
             const recItem = document.createElement('div');
             recItem.className = 'recommendation-item fade-in';
-            recItem.textContent = rec;
+
+            // Reports saved before recommendations were structured hold plain strings
+            if (typeof rec === 'string') {
+                recItem.textContent = rec;
+                recommendationsList.appendChild(recItem);
+                return;
+            }
+
+            recItem.classList.add(`priority-${rec.priority}`);
+
+            const title = document.createElement('h4');
+            title.textContent = `${rec.title} (${rec.instances} instance${rec.instances === 1 ? '' : 's'})`;
+            recItem.appendChild(title);
+
+            const meta = document.createElement('p');
+            meta.className = 'recommendation-meta';
+            meta.textContent = `Priority: ${rec.priority} · Effort: ${rec.effort}${rec.vector ? ` · ${rec.vector}` : ''}`;
+            recItem.appendChild(meta);
+
+            const guidance = document.createElement('p');
+            guidance.textContent = rec.guidance;
+            recItem.appendChild(guidance);
+
+            if (rec.snippet) {
+                const snippet = document.createElement('pre');
+                snippet.className = 'recommendation-snippet';
+                snippet.textContent = rec.snippet;
+                recItem.appendChild(snippet);
+            }
+
+            (rec.links || []).forEach(link => {
+                const anchor = document.createElement('a');
+                anchor.href = link;
+                anchor.target = '_blank';
+                anchor.rel = 'noopener noreferrer';
+                anchor.textContent = link;
+                recItem.appendChild(anchor);
+            });
+
             recommendationsList.appendChild(recItem);
         });
     }
@@ -651,8 +694,7 @@ class AegongInterface {
 
     async loadHistory() {
         try {
-            const response = await fetch('/api/reports');
-            const reports = await response.json();
+            const reports = (await this.api.listReports()) || [];
             
             const historyList = document.getElementById('historyList');
             historyList.innerHTML = '';
@@ -688,10 +730,44 @@ class AegongInterface {
         }
     }
 
+    async loadStats() {
+        try {
+            const stats = await this.api.getStats();
+
+            document.getElementById('statsReports').textContent = stats.reports;
+            document.getElementById('statsThreats').textContent = stats.threats;
+            document.getElementById('statsRisk').textContent = `${Math.round(stats.average_risk * 100)}%`;
+            document.getElementById('statsDuration').textContent = stats.average_duration_ms > 0
+                ? `${(stats.average_duration_ms / 1000).toFixed(1)}s`
+                : '-';
+
+            const chart = document.getElementById('statsChart');
+            chart.innerHTML = '';
+            const busiest = Math.max(1, ...stats.days.map(day => day.reports));
+            stats.days.forEach(day => {
+                const bar = document.createElement('div');
+                bar.className = 'stats-bar';
+                bar.style.height = `${(day.reports / busiest) * 100}%`;
+                bar.title = `${day.date}: ${day.reports} audits, ${day.threats} threats`;
+                chart.appendChild(bar);
+            });
+
+            const vectors = document.getElementById('statsVectors');
+            vectors.innerHTML = '';
+            stats.top_vectors.slice(0, 5).forEach(vector => {
+                const item = document.createElement('span');
+                item.className = 'stats-vector';
+                item.textContent = `${vector.vector} ${vector.name}: ${vector.reports}`;
+                vectors.appendChild(item);
+            });
+        } catch (error) {
+            console.error('Failed to load stats:', error);
+        }
+    }
+
     async loadReport(hash) {
         try {
-            const response = await fetch(`/api/report/${hash}`);
-            const report = await response.json();
+            const report = await this.api.getReport(hash);
             
             this.currentReport = report;
             this.showResults(report);
@@ -719,6 +795,15 @@ class AegongInterface {
         link.click();
     }
 
+    downloadSharedReport() {
+        if (!this.currentReport) return;
+
+        // The server strips evidence and internal metadata before sending it
+        const link = document.createElement('a');
+        link.href = `/api/report/${this.currentReport.agent_hash.substring(0, 8)}/export?profile=customer`;
+        link.click();
+    }
+
     resetInterface() {
         // Reset file input
         document.getElementById('fileInput').value = '';
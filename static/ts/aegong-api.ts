@@ -0,0 +1,994 @@
+// Client for the AEGONG Agent Auditor API 1.0.0, generated from openapi.json by `make api-client`.
+// Do not edit; change the specification and regenerate.
+// The UI loads the JavaScript build, static/js/aegong-api.js.
+
+// Kubernetes admission review
+interface AdmissionReview {
+    // admission.k8s.io/v1
+    apiVersion: string;
+    // AdmissionReview
+    kind: string;
+    // Sent by the Kubernetes API server; object is the pod or workload being admitted
+    request?: { kind?: Record<string, any>; name?: string; namespace?: string; object?: Record<string, any>; operation?: string; uid: string };
+    // Returned by the webhook
+    response?: { allowed: boolean; status?: { code?: number; message?: string }; uid: string; warnings?: string[] };
+}
+
+interface AgentRequest {
+    environment?: string;
+    // Agent name its reports carry
+    name: string;
+    owner: string;
+    // How often the agent must be audited again, like 30d or 720h
+    reaudit_interval?: string;
+    // Lifecycle state, proposed by default or unchanged on update
+    state?: "proposed" | "approved" | "deployed" | "retired";
+}
+
+interface ArchivedReport {
+    archived_at: string;
+    archived_by: string;
+    hash: string;
+}
+
+interface AttestationKey {
+    algorithm: "ecdsa-p256-sha256" | "ed25519";
+    keyid: string;
+    // PEM public key
+    public_key: string;
+}
+
+interface AuditJob {
+    correlation_id: string;
+    created_at: string;
+    error?: string;
+    filename: string;
+    finished_at?: string;
+    id: string;
+    // Audit phase a running job is in
+    phase?: string;
+    queue_position?: number;
+    report_hash?: string;
+    started_at?: string;
+    status: "queued" | "running" | "completed" | "failed" | "cancelled";
+}
+
+interface AuditReport {
+    aegong_message: string;
+    agent_hash: string;
+    agent_name: string;
+    cache?: Record<string, any>;
+    clock?: Record<string, any>;
+    correlation_id?: string;
+    coverage?: Coverage;
+    details?: Record<string, any>;
+    // Detectors that panicked
+    detector_errors?: DetectorError[];
+    duration_ms?: number;
+    engine?: EngineVersion;
+    executive_summary?: Record<string, any>;
+    manifest?: Record<string, any>;
+    narration?: string;
+    overall_risk: number;
+    partial?: PartialAudit;
+    // Signed pattern feed the detectors ran with
+    pattern_feed?: PatternFeedInfo;
+    policy?: Record<string, any>;
+    protocols?: Record<string, any>;
+    recommendations: Recommendation[];
+    risk_breakdown?: Record<string, any>;
+    risk_level: string;
+    ruleset?: Ruleset;
+    runtime?: Record<string, any>;
+    shield_results: Record<string, any>;
+    signature?: Record<string, any>;
+    soak?: Record<string, any>;
+    source?: Record<string, any>;
+    threats: ThreatDetection[];
+    timestamp: string;
+    validation?: Record<string, any>;
+    validation_override?: Record<string, any>;
+}
+
+interface AuditRequest {
+    options?: AuditScope;
+}
+
+// Limits an audit to some threat vectors and SHIELD modules
+interface AuditScope {
+    shields?: string[];
+    skip_shields?: boolean;
+    // Detector names such as T4
+    vectors?: string[];
+}
+
+interface AuditURLRequest {
+    force?: boolean;
+    options?: AuditScope;
+    url: string;
+}
+
+interface CommentRequest {
+    // Markdown
+    body: string;
+    // Index into the report's threats; leave out to comment on the report as a whole
+    threat?: number;
+}
+
+interface ComponentCoverage {
+    duration_ms: number;
+    findings: number;
+    kind: string;
+    name: string;
+    phase: string;
+    reason?: string;
+    status: "ran" | "cached" | "resumed" | "skipped" | "disabled" | "excluded" | "not_applicable";
+    version: string;
+}
+
+// What a detector or SHIELD module checks
+interface ComponentDoc {
+    description: string;
+    // Benign code that commonly triggers it
+    false_positives?: string[];
+    kind: "detector" | "shield";
+    looks_for: string[];
+    // T1 to T99, or the SHIELD module name
+    name: string;
+    references?: string[];
+    title: string;
+}
+
+interface ComponentStatus {
+    description: string;
+    enabled: boolean;
+    kind: "detector" | "shield";
+    min_confidence?: number;
+    name: string;
+}
+
+// Fields left out are unchanged
+interface ComponentUpdate {
+    enabled?: boolean;
+    min_confidence?: number;
+}
+
+interface Coverage {
+    complete: boolean;
+    components: ComponentCoverage[];
+    dynamic_strategy?: DynamicStrategy;
+    scope?: AuditScope;
+}
+
+// Signed attestation that an agent was audited
+interface DSSEEnvelope {
+    // Base64 in-toto statement whose subject is the agent's SHA-256 and whose predicate gives the auditor, audit time and result
+    payload: string;
+    // application/vnd.in-toto+json
+    payloadType: string;
+    signatures: { keyid: string; sig: string }[];
+}
+
+interface DashboardStats {
+    average_duration_ms: number;
+    average_risk: number;
+    days: DayStats[];
+    reports: number;
+    risk_levels: Record<string, number>;
+    threats: number;
+    top_vectors: VectorStats[];
+}
+
+interface DayStats {
+    average_duration_ms: number;
+    average_risk: number;
+    date: string;
+    reports: number;
+    risk_levels: Record<string, number>;
+    threats: number;
+}
+
+// A detector that panicked during an audit; the rest of the audit carried on
+interface DetectorError {
+    // Panicked on decoded or unpacked content
+    derived?: boolean;
+    // T1 to T99
+    detector: string;
+    // The panic value
+    error: string;
+    location?: string;
+    phase: string;
+    timestamp: string;
+}
+
+// Exactly one of payload and report_hash is required
+interface DryRunRequest {
+    definition: VectorDefinition;
+    payload?: string;
+    report_hash?: string;
+}
+
+interface DryRunResult {
+    finding: ThreatDetection | null;
+    patterns: PatternResult[];
+    source: "payload" | "upload" | "evidence";
+    vector: string;
+}
+
+// How the agent was run in the dynamic phase
+interface DynamicStrategy {
+    name: "sandbox" | "harness" | "interpreter" | "wasm" | "none";
+    reason: string;
+}
+
+interface EngineVersion {
+    commit?: string;
+    config_checksum: string;
+    detector_revision: number;
+    version: string;
+}
+
+// Most errors are plain text; some audits answer with JSON
+interface ErrorResponse {
+    error?: string;
+    message?: string;
+}
+
+interface FalsePositiveRequest {
+    reason?: string;
+    // Index into the report's threats
+    threat: number;
+}
+
+// What a file's content, rather than its name, says it is
+interface FileType {
+    group: "executable" | "script" | "archive" | "document" | "image" | "text" | "unknown";
+    // Such as elf, docx or python
+    name: string;
+}
+
+// Where in an agent a finding was made
+interface FindingLocation {
+    // Path within the archive or bundle
+    file?: string;
+    // For scripts and other text
+    line?: number;
+    // Byte offset within the file
+    offset: number;
+}
+
+interface GraphQLRequest {
+    operationName?: string;
+    query: string;
+    variables?: Record<string, any>;
+}
+
+interface GraphQLResponse {
+    data?: Record<string, any>;
+    errors?: Record<string, any>[];
+}
+
+interface HoldRequest {
+    // First audit log time held; required for audit_log holds
+    from?: string;
+    kind: "report" | "upload" | "audit_log";
+    reason: string;
+    // Report hash or upload name; not used for audit_log holds
+    target?: string;
+    // Last audit log time held; leave out to hold everything after from
+    to?: string;
+}
+
+interface JobRequest {
+    // Name of an upload
+    filename: string;
+    force?: boolean;
+    options?: AuditScope;
+}
+
+interface LatestAudit {
+    // First 8 characters of the hash of the agent's newest report
+    hash: string;
+    overall_risk: number;
+    risk_level: string;
+    timestamp: string;
+}
+
+// Exempts a report, an upload or a range of the audit log from retention cleanup and deletion
+interface LegalHold {
+    from?: string;
+    id: string;
+    kind: "report" | "upload" | "audit_log";
+    placed_at: string;
+    placed_by: string;
+    reason: string;
+    target?: string;
+    to?: string;
+}
+
+interface NotificationChannel {
+    // Email recipients
+    to?: string[];
+    type: "webhook" | "slack" | "email";
+    // Webhook and Slack incoming webhook URL
+    url?: string;
+}
+
+// Every condition given must hold; conditions on the report only hold for completed audits
+interface NotificationConditions {
+    min_risk_level?: "MINIMAL" | "LOW" | "MEDIUM" | "HIGH" | "CRITICAL";
+    // The report has every one of these tags
+    tags?: string[];
+    // Any of these vectors was found, as T1 to T99
+    vectors?: string[];
+    // The audit was started by one of these tenants
+    workspaces?: string[];
+}
+
+// Routes matching audit events and status warnings to notification channels
+interface NotificationRule {
+    channels: NotificationChannel[];
+    conditions: NotificationConditions;
+    disabled?: boolean;
+    events: string[];
+    id: string;
+    name: string;
+    updated_at: string;
+    updated_by: string;
+}
+
+interface NotificationRuleRequest {
+    channels: NotificationChannel[];
+    conditions?: NotificationConditions;
+    disabled?: boolean;
+    // Events to notify, audit_completed by default
+    events?: ("audit_completed" | "audit_failed" | "status_warning")[];
+    name: string;
+}
+
+interface PartialAudit {
+    completed_phases: string[];
+    components?: string[];
+    phase?: string;
+    started_at: string;
+    updated_at: string;
+}
+
+interface PatternExcerpt {
+    line: number;
+    offset: number;
+    text: string;
+}
+
+// Detector pattern feed an engine applied after verifying its signature
+interface PatternFeedInfo {
+    applied_at: string;
+    // SHA-256 of the feed as signed
+    checksum: string;
+    // Patterns the feed adds
+    patterns: number;
+    published: string;
+    // Where the feed was fetched from
+    source?: string;
+    version: number;
+}
+
+interface PatternResult {
+    excerpts?: PatternExcerpt[];
+    matches: number;
+    pattern: string;
+}
+
+interface PostureAgent {
+    agent_name: string;
+    audited_at: string;
+    // Share of the score; contributions sum to it
+    contribution: number;
+    criticality: "critical" | "high" | "medium" | "low";
+    // Risk left once false positives are set aside
+    exposure: number;
+    // First 8 characters of the hash of the agent's latest report
+    hash: string;
+    // Findings marked as false positives
+    resolved_findings: number;
+    // Overall risk of the latest report
+    risk: number;
+    unresolved_findings: number;
+    // Criticality times recency
+    weight: number;
+}
+
+// Posture as of the end of a UTC day
+interface PosturePoint {
+    agents: number;
+    date: string;
+    risk_level: string;
+    score: number;
+    unresolved_findings: number;
+}
+
+// What a tenant, or every tenant together, may use; a limit left out is unlimited
+interface QuotaLimits {
+    audits_per_day?: number;
+    // Audits queued or running at once
+    concurrent_jobs?: number;
+    upload_bytes_per_day?: number;
+}
+
+interface QuotaStatus {
+    limits: QuotaLimits;
+    // When the daily counts start over
+    reset_at: string;
+    // API token or client certificate name, workspace, or anonymous@<address>; * for the global quota
+    tenant: string;
+    usage: QuotaUsage;
+}
+
+interface QuotaUsage {
+    audits: number;
+    // UTC date
+    day: string;
+    // Audits queued or running now
+    running: number;
+    upload_bytes: number;
+}
+
+interface Recommendation {
+    effort: string;
+    evidence_type?: string;
+    guidance: string;
+    id: string;
+    instances: number;
+    links?: string[];
+    priority: string;
+    shield?: string;
+    snippet?: string;
+    title: string;
+    vector?: string;
+}
+
+// An agent in the registry, with its latest audit and when the next one is due
+interface RegisteredAgent {
+    created_at: string;
+    created_by: string;
+    environment?: string;
+    id: string;
+    latest_audit?: LatestAudit;
+    name: string;
+    // Only for approved and deployed agents with a re-audit interval
+    next_audit_due?: string;
+    overdue: boolean;
+    owner: string;
+    reaudit_interval?: string;
+    state: "proposed" | "approved" | "deployed" | "retired";
+    updated_at: string;
+    updated_by: string;
+}
+
+interface ReportComment {
+    author: string;
+    // Markdown
+    body: string;
+    created_at: string;
+    id: string;
+    role: string;
+    // Index into the report's threats; absent for comments on the report as a whole
+    threat?: number;
+}
+
+interface ReportSummary {
+    agent_name: string;
+    // Present and true on archived reports, which are only listed when asked for
+    archived?: boolean;
+    config_checksum?: string;
+    engine_version?: string;
+    // First 8 characters of the agent hash
+    hash: string;
+    outdated: boolean;
+    overall_risk: number;
+    risk_level: string;
+    // Version of the ruleset the report was audited under
+    ruleset?: number;
+    threat_count: number;
+    timestamp: string;
+}
+
+interface RescoreResult {
+    previous: RescoredReport;
+    report: AuditReport;
+}
+
+interface RescoredReport {
+    overall_risk: number;
+    risk_level: string;
+    // Absent for reports that predate rulesets
+    ruleset?: number;
+    threat_count: number;
+}
+
+interface RiskPosture {
+    agents: number;
+    // Score now minus at the start of the history; negative is safer
+    change: number;
+    computed_at: string;
+    // Agents by criticality tag
+    criticality: Record<string, number>;
+    // Oldest first, one per UTC day
+    history: PosturePoint[];
+    resolved_findings: number;
+    risk_level: string;
+    // Agents by the risk level of their exposure
+    risk_levels: Record<string, number>;
+    // Weighted exposure of the fleet, from 0 to 1
+    score: number;
+    // Up to ten agents, highest contribution first
+    top_agents: PostureAgent[];
+    unresolved_findings: number;
+}
+
+// Snapshot of the detector and SHIELD configuration an audit ran under
+interface Ruleset {
+    // The config checksum of EngineVersion
+    checksum: string;
+    components: ComponentStatus[];
+    custom_vectors?: VectorDefinition[];
+    detector_revision: number;
+    first_used: string;
+    // Sandbox settings that change findings
+    options: Record<string, string>;
+    // Version by plugin name
+    plugins?: Record<string, string>;
+    version: number;
+}
+
+type RulesetSummary = Ruleset & { current: boolean; reports: number };
+
+interface SearchResult {
+    agent_name: string;
+    // First 8 characters of the agent hash
+    hash: string;
+    // Up to five fields the query matched, with the text around the match
+    matches: { field: string; snippet: string }[];
+    overall_risk: number;
+    risk_level: string;
+    // Relevance; higher is better
+    score: number;
+    timestamp: string;
+}
+
+interface SearchResults {
+    query: string;
+    results: SearchResult[];
+    // Reports matched, before the limit
+    total: number;
+}
+
+interface SeverityRule {
+    min_matches?: number;
+    pattern?: string;
+    severity: string;
+}
+
+interface ShareLink {
+    expires_at: string;
+    // Identifies the link in the audit log
+    id: string;
+    profile?: string;
+    // Read-only link anyone can open until it expires
+    url: string;
+}
+
+interface ShareRequest {
+    // Duration such as 72h; 168h by default, capped at AEGONG_SHARE_MAX_TTL
+    expires_in?: string;
+    // Redaction profile; the full report is shared without one
+    profile?: string;
+}
+
+interface ThreatDetection {
+    confidence: number;
+    details: Record<string, any>;
+    evidence: string[];
+    location?: FindingLocation;
+    // 0 LOW to 3 CRITICAL
+    severity: number;
+    severity_name: string;
+    timestamp: string;
+    // 0 for T1, 1 for T2 and so on
+    vector: number;
+    vector_name: string;
+}
+
+interface UnsupportedFileType {
+    // Absent when every type not denied is accepted
+    accepted?: string[];
+    denied?: string[];
+    error: string;
+    file_type: FileType;
+    message: string;
+}
+
+interface UploadResult {
+    filename: string;
+    message: string;
+}
+
+interface VectorDefinition {
+    description?: string;
+    id?: string;
+    match_confidence?: number;
+    name?: string;
+    patterns: string[];
+    recommendation?: VectorRemediation;
+    severity?: string;
+    severity_rules?: SeverityRule[];
+}
+
+interface VectorRemediation {
+    effort?: string;
+    guidance: string;
+    links?: string[];
+    title: string;
+}
+
+interface VectorStats {
+    name: string;
+    reports: number;
+    threats: number;
+    vector: string;
+}
+
+// AegongAPIError is a response outside 2xx; body is its JSON, if any
+class AegongAPIError extends Error {
+    constructor(public status: number, message: string, public body?: any) {
+        super(message);
+    }
+}
+
+interface AegongAPIOptions {
+    // Prefix of every path; the page's own server by default
+    baseURL?: string;
+    // API token sent as a bearer token
+    token?: string;
+    // Extra headers for each request, such as the CSRF token
+    headers?: () => Record<string, string>;
+}
+
+class AegongAPI {
+    constructor(private options: AegongAPIOptions = {}) {}
+
+    // Export reports as a .tar.gz archive
+    exportArchive(query: { audit_log?: "1" } = {}): Promise<Blob> {
+        return this.request("GET", `/api/admin/archive`, query, undefined, "", "blob");
+    }
+
+    // Import reports from an archive
+    importArchive(body: Blob, query: { overwrite?: "1" } = {}): Promise<Record<string, any>> {
+        return this.request("POST", `/api/admin/archive`, query, body, "application/gzip", "json");
+    }
+
+    // List detectors and SHIELD modules with their settings
+    listComponents(): Promise<ComponentStatus[]> {
+        return this.request("GET", `/api/admin/components`, undefined, undefined, "", "json");
+    }
+
+    // Enable, disable or set the confidence threshold of a component
+    updateComponent(name: string, body: ComponentUpdate): Promise<ComponentStatus> {
+        return this.request("PATCH", `/api/admin/components/${encodeURIComponent(name)}`, undefined, body, "application/json", "json");
+    }
+
+    // Dry-run a custom vector definition on a payload or a saved report
+    testDetector(body: DryRunRequest): Promise<DryRunResult> {
+        return this.request("POST", `/api/admin/detectors/test`, undefined, body, "application/json", "json");
+    }
+
+    // Count false positive marks per vector and pattern
+    getFeedbackSummary(): Promise<Record<string, any>> {
+        return this.request("GET", `/api/admin/feedback`, undefined, undefined, "", "json");
+    }
+
+    // List the legal holds in force
+    listLegalHolds(): Promise<LegalHold[]> {
+        return this.request("GET", `/api/admin/holds`, undefined, undefined, "", "json");
+    }
+
+    // Place a legal hold
+    placeLegalHold(body: HoldRequest): Promise<LegalHold> {
+        return this.request("POST", `/api/admin/holds`, undefined, body, "application/json", "json");
+    }
+
+    // Release a legal hold
+    releaseLegalHold(id: string): Promise<void> {
+        return this.request("DELETE", `/api/admin/holds/${encodeURIComponent(id)}`, undefined, undefined, "", "none");
+    }
+
+    // List the notification rules
+    listNotificationRules(): Promise<NotificationRule[]> {
+        return this.request("GET", `/api/admin/notifications`, undefined, undefined, "", "json");
+    }
+
+    // Add a notification rule
+    createNotificationRule(body: NotificationRuleRequest): Promise<NotificationRule> {
+        return this.request("POST", `/api/admin/notifications`, undefined, body, "application/json", "json");
+    }
+
+    // Replace a notification rule
+    updateNotificationRule(id: string, body: NotificationRuleRequest): Promise<NotificationRule> {
+        return this.request("PUT", `/api/admin/notifications/${encodeURIComponent(id)}`, undefined, body, "application/json", "json");
+    }
+
+    // Delete a notification rule
+    deleteNotificationRule(id: string): Promise<void> {
+        return this.request("DELETE", `/api/admin/notifications/${encodeURIComponent(id)}`, undefined, undefined, "", "none");
+    }
+
+    // List the quotas and usage of every tenant with limits of its own or usage today
+    listQuotas(): Promise<{ global: QuotaStatus; tenants: QuotaStatus[] }> {
+        return this.request("GET", `/api/admin/quotas`, undefined, undefined, "", "json");
+    }
+
+    // Delete a report with its anchor receipt, comments, voice reports and upload
+    deleteReport(hash: string): Promise<void> {
+        return this.request("DELETE", `/api/admin/reports/${encodeURIComponent(hash)}`, undefined, undefined, "", "none");
+    }
+
+    // List the rulesets the engine has run, newest first
+    listRulesets(): Promise<RulesetSummary[]> {
+        return this.request("GET", `/api/admin/rulesets`, undefined, undefined, "", "json");
+    }
+
+    // Get the server's status and warnings
+    getStatus(): Promise<Record<string, any>> {
+        return this.request("GET", `/api/admin/status`, undefined, undefined, "", "json");
+    }
+
+    // Delete an upload, keeping its hash
+    deleteUpload(filename: string): Promise<void> {
+        return this.request("DELETE", `/api/admin/uploads/${encodeURIComponent(filename)}`, undefined, undefined, "", "none");
+    }
+
+    // Get the voice report settings
+    getVoiceConfig(): Promise<Record<string, any>> {
+        return this.request("GET", `/api/admin/voice`, undefined, undefined, "", "json");
+    }
+
+    // Change the voice report settings
+    updateVoiceConfig(body: Record<string, any>): Promise<Record<string, any>> {
+        return this.request("PATCH", `/api/admin/voice`, undefined, body, "application/json", "json");
+    }
+
+    // Admit a Kubernetes pod or workload only if its images and agents passed their audits
+    reviewAdmission(body: AdmissionReview): Promise<AdmissionReview> {
+        return this.request("POST", `/api/admission/validate`, undefined, body, "application/json", "json");
+    }
+
+    // List the registered agents
+    listAgents(query: { state?: string; overdue?: boolean } = {}): Promise<RegisteredAgent[]> {
+        return this.request("GET", `/api/agents`, query, undefined, "", "json");
+    }
+
+    // Register an agent
+    registerAgent(body: AgentRequest): Promise<RegisteredAgent> {
+        return this.request("POST", `/api/agents`, undefined, body, "application/json", "json");
+    }
+
+    // Get a registered agent
+    getAgent(id: string): Promise<RegisteredAgent> {
+        return this.request("GET", `/api/agents/${encodeURIComponent(id)}`, undefined, undefined, "", "json");
+    }
+
+    // Replace a registered agent's details or move it along its lifecycle
+    updateAgent(id: string, body: AgentRequest): Promise<RegisteredAgent> {
+        return this.request("PUT", `/api/agents/${encodeURIComponent(id)}`, undefined, body, "application/json", "json");
+    }
+
+    // Remove an agent from the registry, keeping its reports
+    deleteAgent(id: string): Promise<void> {
+        return this.request("DELETE", `/api/agents/${encodeURIComponent(id)}`, undefined, undefined, "", "none");
+    }
+
+    // Get the public key attestations are signed with
+    getAttestationKey(): Promise<AttestationKey> {
+        return this.request("GET", `/api/attestation/key`, undefined, undefined, "", "json");
+    }
+
+    // Download an agent from a registry URL and audit it
+    auditURL(body: AuditURLRequest, query: { narration?: string } = {}): Promise<AuditReport> {
+        return this.request("POST", `/api/audit-url`, query, body, "application/json", "json");
+    }
+
+    // Audit an upload and wait for its report
+    auditUpload(filename: string, body?: AuditRequest, query: { force?: "true"; narration?: string } = {}): Promise<AuditReport> {
+        return this.request("POST", `/api/audit/${encodeURIComponent(filename)}`, query, body, "application/json", "json");
+    }
+
+    // Describe what each detector and SHIELD module checks
+    listDetectors(): Promise<ComponentDoc[]> {
+        return this.request("GET", `/api/detectors`, undefined, undefined, "", "json");
+    }
+
+    // List audit jobs, newest first
+    listJobs(): Promise<AuditJob[]> {
+        return this.request("GET", `/api/jobs`, undefined, undefined, "", "json");
+    }
+
+    // Audit an upload in the background
+    createJob(body: JobRequest): Promise<AuditJob> {
+        return this.request("POST", `/api/jobs`, undefined, body, "application/json", "json");
+    }
+
+    // Get an audit job
+    getJob(id: string): Promise<AuditJob> {
+        return this.request("GET", `/api/jobs/${encodeURIComponent(id)}`, undefined, undefined, "", "json");
+    }
+
+    // Cancel a queued or running job
+    cancelJob(id: string): Promise<void> {
+        return this.request("DELETE", `/api/jobs/${encodeURIComponent(id)}`, undefined, undefined, "", "none");
+    }
+
+    // Get the findings of a job's audit so far
+    getPartialReport(id: string): Promise<AuditReport> {
+        return this.request("GET", `/api/jobs/${encodeURIComponent(id)}/partial`, undefined, undefined, "", "json");
+    }
+
+    // Get the organization's risk posture and its history
+    getPosture(query: { days?: number } = {}): Promise<RiskPosture> {
+        return this.request("GET", `/api/posture`, query, undefined, "", "json");
+    }
+
+    // Get the caller's quotas and usage today, and the global ones
+    getQuota(): Promise<{ global: QuotaStatus; tenant: QuotaStatus }> {
+        return this.request("GET", `/api/quota`, undefined, undefined, "", "json");
+    }
+
+    // Get a saved report
+    getReport(hash: string, query: { narration?: string } = {}): Promise<AuditReport> {
+        return this.request("GET", `/api/report/${encodeURIComponent(hash)}`, query, undefined, "", "json");
+    }
+
+    // Delete a report with its anchor receipt, comments, voice reports and upload
+    deleteSavedReport(hash: string): Promise<void> {
+        return this.request("DELETE", `/api/report/${encodeURIComponent(hash)}`, undefined, undefined, "", "none");
+    }
+
+    // Get the transparency log receipt of a report
+    getReportAnchor(hash: string): Promise<Record<string, any>> {
+        return this.request("GET", `/api/report/${encodeURIComponent(hash)}/anchor`, undefined, undefined, "", "json");
+    }
+
+    // Hide a report from the default report listing
+    archiveReport(hash: string): Promise<ArchivedReport> {
+        return this.request("POST", `/api/report/${encodeURIComponent(hash)}/archive`, undefined, undefined, "", "json");
+    }
+
+    // Put an archived report back in the default report listing
+    restoreReport(hash: string): Promise<void> {
+        return this.request("DELETE", `/api/report/${encodeURIComponent(hash)}/archive`, undefined, undefined, "", "none");
+    }
+
+    // Get a signed in-toto attestation that the agent was audited
+    getReportAttestation(hash: string): Promise<DSSEEnvelope> {
+        return this.request("GET", `/api/report/${encodeURIComponent(hash)}/attestation`, undefined, undefined, "", "json");
+    }
+
+    // List the comments on a report, oldest first
+    listComments(hash: string, query: { threat?: string } = {}): Promise<ReportComment[]> {
+        return this.request("GET", `/api/report/${encodeURIComponent(hash)}/comments`, query, undefined, "", "json");
+    }
+
+    // Comment on a report or one of its findings
+    addComment(hash: string, body: CommentRequest): Promise<ReportComment> {
+        return this.request("POST", `/api/report/${encodeURIComponent(hash)}/comments`, undefined, body, "application/json", "json");
+    }
+
+    // Export a redacted report for sharing
+    exportReport(hash: string, query: { profile?: string } = {}): Promise<AuditReport> {
+        return this.request("GET", `/api/report/${encodeURIComponent(hash)}/export`, query, undefined, "", "json");
+    }
+
+    // Mark a finding of a report as a false positive
+    markFalsePositive(hash: string, body: FalsePositiveRequest): Promise<Record<string, any>> {
+        return this.request("POST", `/api/report/${encodeURIComponent(hash)}/feedback`, undefined, body, "application/json", "json");
+    }
+
+    // Re-audit the agent of a report under the current ruleset
+    rescoreReport(hash: string): Promise<RescoreResult> {
+        return this.request("POST", `/api/report/${encodeURIComponent(hash)}/rescore`, undefined, undefined, "", "json");
+    }
+
+    // Create a time-limited link to a report, optionally redacted
+    shareReport(hash: string, body?: ShareRequest): Promise<ShareLink> {
+        return this.request("POST", `/api/report/${encodeURIComponent(hash)}/share`, undefined, body, "application/json", "json");
+    }
+
+    // List saved reports
+    listReports(query: { archived?: "include" | "only" } = {}): Promise<ReportSummary[] | null> {
+        return this.request("GET", `/api/reports`, query, undefined, "", "json");
+    }
+
+    // Search the agent names, evidence and recommendations of saved reports
+    searchReports(query: { q?: string; limit?: number } = {}): Promise<SearchResults> {
+        return this.request("GET", `/api/search`, query, undefined, "", "json");
+    }
+
+    // Open a share link
+    getSharedReport(token: string): Promise<AuditReport> {
+        return this.request("GET", `/api/shared/${encodeURIComponent(token)}`, undefined, undefined, "", "json");
+    }
+
+    // Get dashboard statistics
+    getStats(query: { days?: number } = {}): Promise<DashboardStats> {
+        return this.request("GET", `/api/stats`, query, undefined, "", "json");
+    }
+
+    // Upload an agent, optionally with a signature bundle and manifest
+    uploadAgent(body: FormData): Promise<UploadResult> {
+        return this.request("POST", `/api/upload`, undefined, body, "multipart/form-data", "json");
+    }
+
+    // Check whether an upload is an AI agent
+    validateUpload(filename: string): Promise<Record<string, any>> {
+        return this.request("GET", `/api/validate/${encodeURIComponent(filename)}`, undefined, undefined, "", "json");
+    }
+
+    // Generate or fetch the voice report of a report
+    getVoiceReport(hash: string, query: { narration?: string } = {}): Promise<Record<string, any>> {
+        return this.request("GET", `/api/voice/${encodeURIComponent(hash)}`, query, undefined, "", "json");
+    }
+
+    // Run a GraphQL query over saved reports
+    graphql(body: GraphQLRequest): Promise<GraphQLResponse> {
+        return this.request("POST", `/graphql`, undefined, body, "application/json", "json");
+    }
+
+    // Sends a request and decodes its response; responses outside 2xx reject with an AegongAPIError
+    private async request(method: string, path: string, query: Record<string, any> | undefined, body: any, contentType: string, expect: string): Promise<any> {
+        const url = new URL((this.options.baseURL || "") + path, window.location.origin);
+        Object.keys(query || {}).forEach(key => {
+            const value = (query as Record<string, any>)[key];
+            if (value !== undefined && value !== null && value !== "") {
+                url.searchParams.set(key, String(value));
+            }
+        });
+
+        const headers: Record<string, string> = Object.assign({}, this.options.headers ? this.options.headers() : {});
+        if (this.options.token) {
+            headers["Authorization"] = `Bearer ${this.options.token}`;
+        }
+        let payload: BodyInit | undefined;
+        if (body !== undefined) {
+            if (contentType === "application/json") {
+                payload = JSON.stringify(body);
+            } else {
+                payload = body;
+            }
+            // FormData sets its own multipart boundary
+            if (contentType !== "multipart/form-data") {
+                headers["Content-Type"] = contentType;
+            }
+        }
+
+        const response = await fetch(url.toString(), { method, headers, body: payload });
+        if (!response.ok) {
+            const text = await response.text();
+            let parsed: any;
+            try {
+                parsed = JSON.parse(text);
+            } catch (error) {
+                parsed = undefined;
+            }
+            throw new AegongAPIError(response.status, (parsed && parsed.error) || text.trim() || response.statusText, parsed);
+        }
+        if (expect === "json") {
+            return response.json();
+        }
+        if (expect === "blob") {
+            return response.blob();
+        }
+        return undefined;
+    }
+}
+
+(window as any).AegongAPI = AegongAPI;
+(window as any).AegongAPIError = AegongAPIError;
@@ -0,0 +1,144 @@
+// Typed client for the AEGONG WebSocket protocol served at /ws.
+// Compile with `make ws-client`; the UI loads static/js/aegong-ws-client.js.
+
+type AegongTopic = "audits" | `audit:${string}`;
+
+type AegongCommand =
+    | { type: "ping"; id?: string }
+    | { type: "subscribe"; id?: string; data: { topic: AegongTopic } }
+    | { type: "unsubscribe"; id?: string; data: { topic: AegongTopic } }
+    | { type: "start_audit"; id?: string; data: { filename: string } }
+    | { type: "cancel"; id?: string; data: { audit_id: string } };
+
+type AegongMessageType =
+    | "aegong_message"
+    | "pong"
+    | "ack"
+    | "error"
+    | "audit_started"
+    | "audit_completed"
+    | "audit_failed"
+    | "audit_cancelled"
+    | "audit_phase"
+    | "threat_found"
+    | "upload_received"
+    | "voice_ready";
+
+type AegongErrorCode = "invalid_json" | "unknown_type" | "invalid_data" | "not_found" | "quota_exceeded" | "forbidden";
+
+interface AegongMessage<T = any> {
+    type: AegongMessageType;
+    id?: string;
+    data: T;
+    message: string;
+}
+
+interface AuditEvent {
+    audit_id: string;
+    filename: string;
+    phase?: "static" | "dynamic" | "shield";
+    threat?: any;
+    report?: any;
+    validation?: any;
+}
+
+interface VoiceReadyEvent {
+    report_hash: string;
+    audio_url: string;
+}
+
+type Pending = { resolve: (msg: AegongMessage) => void; reject: (err: Error) => void };
+
+class AegongWSClient {
+    private ws: WebSocket | null = null;
+    private nextId = 1;
+    private pending = new Map<string, Pending>();
+    private topics = new Set<AegongTopic>();
+    private handlers = new Map<AegongMessageType, Set<(msg: AegongMessage) => void>>();
+
+    constructor(private url: string = AegongWSClient.defaultURL(), private reconnectDelay = 5000) {}
+
+    static defaultURL(): string {
+        const protocol = window.location.protocol === "https:" ? "wss:" : "ws:";
+        return `${protocol}//${window.location.host}/ws`;
+    }
+
+    connect(): void {
+        this.ws = new WebSocket(this.url);
+
+        this.ws.onopen = () => {
+            // Restore subscriptions after a reconnect
+            this.topics.forEach(topic => this.send({ type: "subscribe", data: { topic } }));
+        };
+
+        this.ws.onmessage = (event: MessageEvent) => {
+            this.dispatch(JSON.parse(event.data) as AegongMessage);
+        };
+
+        this.ws.onclose = () => {
+            this.pending.forEach(p => p.reject(new Error("connection closed")));
+            this.pending.clear();
+            setTimeout(() => this.connect(), this.reconnectDelay);
+        };
+    }
+
+    on(type: AegongMessageType, handler: (msg: AegongMessage) => void): () => void {
+        if (!this.handlers.has(type)) {
+            this.handlers.set(type, new Set());
+        }
+        this.handlers.get(type)!.add(handler);
+        return () => this.handlers.get(type)!.delete(handler);
+    }
+
+    ping(): Promise<AegongMessage> {
+        return this.send({ type: "ping" });
+    }
+
+    subscribe(topic: AegongTopic): Promise<AegongMessage> {
+        this.topics.add(topic);
+        return this.send({ type: "subscribe", data: { topic } });
+    }
+
+    unsubscribe(topic: AegongTopic): Promise<AegongMessage> {
+        this.topics.delete(topic);
+        return this.send({ type: "unsubscribe", data: { topic } });
+    }
+
+    // Resolves with the audit ID once the server has accepted the audit
+    async startAudit(filename: string): Promise<string> {
+        const ack = await this.send({ type: "start_audit", data: { filename } });
+        return ack.data.audit_id;
+    }
+
+    cancel(auditId: string): Promise<AegongMessage> {
+        return this.send({ type: "cancel", data: { audit_id: auditId } });
+    }
+
+    // Sends a command and resolves with its direct reply; "error" replies reject
+    send(command: AegongCommand): Promise<AegongMessage> {
+        const id = command.id ?? String(this.nextId++);
+        return new Promise((resolve, reject) => {
+            if (!this.ws || this.ws.readyState !== WebSocket.OPEN) {
+                reject(new Error("not connected"));
+                return;
+            }
+            this.pending.set(id, { resolve, reject });
+            this.ws.send(JSON.stringify({ ...command, id }));
+        });
+    }
+
+    private dispatch(msg: AegongMessage): void {
+        if (msg.id && this.pending.has(msg.id)) {
+            const p = this.pending.get(msg.id)!;
+            this.pending.delete(msg.id);
+            if (msg.type === "error") {
+                p.reject(new Error(`${msg.data?.code}: ${msg.message}`));
+            } else {
+                p.resolve(msg);
+            }
+        }
+        this.handlers.get(msg.type)?.forEach(handler => handler(msg));
+    }
+}
+
+(window as any).AegongWSClient = AegongWSClient;
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// Days of history /api/stats returns by default, and at most
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 365
+)
+
+// Risk levels in increasing order, as aegong.RiskLevel names them
+var riskLevels = []string{"MINIMAL", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// dashboardStats summarises the saved reports for the dashboard home page.
+// Totals cover every report; days covers the requested window.
+type dashboardStats struct {
+	Reports           int            `json:"reports"`
+	Threats           int            `json:"threats"`
+	AverageRisk       float64        `json:"average_risk"`
+	AverageDurationMS float64        `json:"average_duration_ms"` // Over reports that record their duration
+	RiskLevels        map[string]int `json:"risk_levels"`
+	TopVectors        []vectorStats  `json:"top_vectors"` // Most common first
+	Days              []dayStats     `json:"days"`        // Oldest first, including days without audits
+}
+
+// vectorStats counts the findings of one threat vector
+type vectorStats struct {
+	Vector  string `json:"vector"` // T1 to T9
+	Name    string `json:"name"`
+	Reports int    `json:"reports"` // Reports with at least one finding
+	Threats int    `json:"threats"`
+}
+
+// dayStats summarises the reports of one UTC day
+type dayStats struct {
+	Date              string         `json:"date"`
+	Reports           int            `json:"reports"`
+	Threats           int            `json:"threats"`
+	AverageRisk       float64        `json:"average_risk"`
+	AverageDurationMS float64        `json:"average_duration_ms"`
+	RiskLevels        map[string]int `json:"risk_levels"`
+}
+
+// reportTotals accumulates the averages shared by the totals and each day
+type reportTotals struct {
+	reports, threats, timed int
+	risk, durationMS        float64
+	levels                  map[string]int
+}
+
+func newReportTotals() *reportTotals {
+	levels := make(map[string]int, len(riskLevels))
+	for _, level := range riskLevels {
+		levels[level] = 0
+	}
+	return &reportTotals{levels: levels}
+}
+
+func (t *reportTotals) add(report *aegong.AuditReport) {
+	t.reports++
+	t.threats += len(report.Threats)
+	t.risk += report.OverallRisk
+	t.levels[report.RiskLevel]++
+	// Reports saved before audits were timed have no duration
+	if report.DurationMS > 0 {
+		t.timed++
+		t.durationMS += report.DurationMS
+	}
+}
+
+func (t *reportTotals) averages() (risk, durationMS float64) {
+	if t.reports > 0 {
+		risk = t.risk / float64(t.reports)
+	}
+	if t.timed > 0 {
+		durationMS = t.durationMS / float64(t.timed)
+	}
+	return risk, durationMS
+}
+
+// computeStats summarises reports, with a series of the days up to and
+// including now's
+func computeStats(reports []*aegong.AuditReport, now time.Time, days int) dashboardStats {
+	today := now.UTC().Truncate(24 * time.Hour)
+	first := today.AddDate(0, 0, -(days - 1))
+
+	total := newReportTotals()
+	daily := make([]*reportTotals, days)
+	for i := range daily {
+		daily[i] = newReportTotals()
+	}
+	vectors := make(map[aegong.ThreatVector]*vectorStats)
+
+	for _, report := range reports {
+		total.add(report)
+		if day := int(report.Timestamp.UTC().Sub(first) / (24 * time.Hour)); !report.Timestamp.Before(first) && day < days {
+			daily[day].add(report)
+		}
+
+		seen := make(map[aegong.ThreatVector]bool)
+		for _, threat := range report.Threats {
+			stats := vectors[threat.Vector]
+			if stats == nil {
+				stats = &vectorStats{Vector: vectorCode(threat.Vector), Name: aegong.ThreatName(threat.Vector)}
+				vectors[threat.Vector] = stats
+			}
+			stats.Threats++
+			if !seen[threat.Vector] {
+				seen[threat.Vector] = true
+				stats.Reports++
+			}
+		}
+	}
+
+	stats := dashboardStats{
+		Reports:    total.reports,
+		Threats:    total.threats,
+		RiskLevels: total.levels,
+		TopVectors: []vectorStats{},
+		Days:       make([]dayStats, days),
+	}
+	stats.AverageRisk, stats.AverageDurationMS = total.averages()
+	for _, vector := range vectors {
+		stats.TopVectors = append(stats.TopVectors, *vector)
+	}
+	sort.Slice(stats.TopVectors, func(i, j int) bool {
+		a, b := stats.TopVectors[i], stats.TopVectors[j]
+		if a.Reports != b.Reports {
+			return a.Reports > b.Reports
+		}
+		return a.Vector < b.Vector
+	})
+	for i, day := range daily {
+		stats.Days[i] = dayStats{
+			Date:       first.AddDate(0, 0, i).Format("2006-01-02"),
+			Reports:    day.reports,
+			Threats:    day.threats,
+			RiskLevels: day.levels,
+		}
+		stats.Days[i].AverageRisk, stats.Days[i].AverageDurationMS = day.averages()
+	}
+	return stats
+}
+
+// statsHandler serves the dashboard statistics; ?days= sets how many days
+// the series covers
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	days := defaultStatsDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxStatsDays {
+			http.Error(w, "days must be a number from 1 to 365", http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+
+	reports, err := loadReports()
+	if err != nil {
+		http.Error(w, "Error reading reports", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(computeStats(reports, time.Now(), days))
+}
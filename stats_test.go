@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// TestComputeStats tests the totals, vector ranking and daily series
+func TestComputeStats(t *testing.T) {
+	now := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+	reports := []*aegong.AuditReport{
+		{Timestamp: now.Add(-time.Hour), OverallRisk: 0.9, RiskLevel: "CRITICAL", DurationMS: 3000, Threats: []aegong.ThreatDetection{
+			{Vector: aegong.T4_UNAUTHORIZED_ACTION}, {Vector: aegong.T4_UNAUTHORIZED_ACTION}, {Vector: aegong.T1_REASONING_HIJACK},
+		}},
+		{Timestamp: now.AddDate(0, 0, -1), OverallRisk: 0.5, RiskLevel: "MEDIUM", DurationMS: 1000, Threats: []aegong.ThreatDetection{
+			{Vector: aegong.T4_UNAUTHORIZED_ACTION},
+		}},
+		// Older than the window, and saved before audits were timed
+		{Timestamp: now.AddDate(0, 0, -40), OverallRisk: 0.1, RiskLevel: "MINIMAL"},
+	}
+
+	stats := computeStats(reports, now, 7)
+	if stats.Reports != 3 || stats.Threats != 4 || stats.AverageDurationMS != 2000 {
+		t.Fatalf("Totals should cover every report, got %+v", stats)
+	}
+	if stats.AverageRisk < 0.49 || stats.AverageRisk > 0.51 {
+		t.Fatalf("Average risk should be 0.5, got %f", stats.AverageRisk)
+	}
+	if stats.RiskLevels["CRITICAL"] != 1 || stats.RiskLevels["HIGH"] != 0 || len(stats.RiskLevels) != 5 {
+		t.Fatalf("Every risk level should be counted, got %v", stats.RiskLevels)
+	}
+	if len(stats.TopVectors) != 2 || stats.TopVectors[0].Vector != "T4" || stats.TopVectors[0].Reports != 2 || stats.TopVectors[0].Threats != 3 {
+		t.Fatalf("T4 should be the most common vector, got %+v", stats.TopVectors)
+	}
+
+	if len(stats.Days) != 7 || stats.Days[0].Date != "2026-03-04" || stats.Days[6].Date != "2026-03-10" {
+		t.Fatalf("Series should cover the last 7 days, got %+v", stats.Days)
+	}
+	if today := stats.Days[6]; today.Reports != 1 || today.Threats != 3 || today.AverageDurationMS != 3000 || today.RiskLevels["CRITICAL"] != 1 {
+		t.Fatalf("Today should have one critical audit, got %+v", today)
+	}
+	if stats.Days[5].Reports != 1 || stats.Days[0].Reports != 0 {
+		t.Fatalf("Yesterday should have one audit and the first day none, got %+v", stats.Days)
+	}
+}
+
+// TestStatsHandler tests the endpoint over saved reports
+func TestStatsHandler(t *testing.T) {
+	withTestUpload(t)
+	os.MkdirAll("reports", 0755)
+	report := aegong.AuditReport{AgentHash: "abcdef0123456789", Timestamp: time.Now(), OverallRisk: 0.3, RiskLevel: "LOW"}
+	data, _ := json.Marshal(report)
+	if err := writeStored(filepath.Join("reports", "report_abcdef01.json"), data); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	statsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/stats?days=3", nil))
+	var stats dashboardStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("Should return stats, got %d %s", rec.Code, rec.Body.String())
+	}
+	if stats.Reports != 1 || len(stats.Days) != 3 || stats.Days[2].Reports != 1 || stats.RiskLevels["LOW"] != 1 {
+		t.Fatalf("Saved report should be counted today, got %+v", stats)
+	}
+
+	for _, days := range []string{"0", "366", "week"} {
+		rec := httptest.NewRecorder()
+		statsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/stats?days="+days, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("days=%s should be rejected, got %d", days, rec.Code)
+		}
+	}
+}
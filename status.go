@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"time"
+
+	keys "Agent_Auditor/key_manager"
+	"Agent_Auditor/pkg/aegong"
+)
+
+// How often the status monitor checks the thresholds
+const statusCheckInterval = time.Minute
+
+// statusThresholds are the limits past which the status reports warnings
+type statusThresholds struct {
+	diskMB       int // Combined size of uploads, reports and voice reports; zero disables
+	freePercent  int // Free space left on the filesystem holding them
+	queuePercent int // How full the audit queue may get
+	keyDays      int // Days before a key expires to start warning; zero only warns once expired
+}
+
+var thresholds = statusThresholds{diskMB: 1024, freePercent: 10, queuePercent: 80, keyDays: 14}
+
+// initStatusThresholds reads the warning thresholds from the environment
+func initStatusThresholds() error {
+	diskMB, err := envInt("AEGONG_STATUS_DISK_WARN_MB", thresholds.diskMB, 0)
+	if err != nil {
+		return err
+	}
+	freePercent, err := envInt("AEGONG_STATUS_FREE_WARN_PERCENT", thresholds.freePercent, 0)
+	if err != nil {
+		return err
+	}
+	queuePercent, err := envInt("AEGONG_STATUS_QUEUE_WARN_PERCENT", thresholds.queuePercent, 1)
+	if err != nil {
+		return err
+	}
+	keyDays, err := envInt("AEGONG_STATUS_KEY_EXPIRY_WARN_DAYS", thresholds.keyDays, 0)
+	if err != nil {
+		return err
+	}
+	thresholds = statusThresholds{diskMB: diskMB, freePercent: freePercent, queuePercent: queuePercent, keyDays: keyDays}
+	return nil
+}
+
+// systemStatus is the server's health as reported by /api/admin/status
+type systemStatus struct {
+	Status    string               `json:"status"` // "ok" or "warning"
+	CheckedAt time.Time            `json:"checked_at"`
+	Disk      diskStatus           `json:"disk"`
+	Sandboxes aegong.SandboxStatus `json:"sandboxes"`
+	Queue     queueStatus          `json:"queue"`
+	Voice     VoiceHealth          `json:"voice"`
+	Keys      []keyStatus          `json:"keys"`
+	// NoisyPatterns are evidence patterns users often mark as false positives
+	NoisyPatterns []aegong.PatternFeedback `json:"noisy_patterns"`
+	// DetectorPanics counts the panics of each detector since startup
+	DetectorPanics map[string]int `json:"detector_panics"`
+	// SelfTestFailures are the detectors that missed their canary
+	SelfTestFailures []aegong.DetectorCanary `json:"self_test_failures"`
+	PatternFeed      *patternFeedStatus      `json:"pattern_feed,omitempty"` // Only when a feed is configured
+	Warnings         []string                `json:"warnings"`
+}
+
+// keyStatus is when a key in use was created and expires; zero times are unknown or never
+type keyStatus struct {
+	Source    string    `json:"source"` // Key file the key is used from: storage or voice
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Expired   bool      `json:"expired"`
+}
+
+type diskStatus struct {
+	Directories     map[string]directoryUsage `json:"directories"`
+	UsedBytes       int64                     `json:"used_bytes"` // Sum over the directories
+	FreeBytes       uint64                    `json:"free_bytes"`
+	FilesystemBytes uint64                    `json:"filesystem_bytes"`
+	FreePercent     float64                   `json:"free_percent"`
+}
+
+type directoryUsage struct {
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+	Files int    `json:"files"`
+}
+
+type queueStatus struct {
+	Running int `json:"running"`
+	Queued  int `json:"queued"`
+	Limit   int `json:"limit"` // Audits allowed to run at once
+	Depth   int `json:"depth"` // Audits allowed to wait
+}
+
+// directorySize sums the files under dir; a missing directory is empty
+func directorySize(dir string) directoryUsage {
+	usage := directoryUsage{Path: dir}
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			usage.Bytes += info.Size()
+			usage.Files++
+		}
+		return nil
+	})
+	return usage
+}
+
+// collectStatus gathers the server's status and the warnings it raises
+func collectStatus() *systemStatus {
+	voiceDir := "voice_reports"
+	voice := VoiceHealth{}
+	if voiceManager != nil {
+		voiceDir = voiceManager.Config().OutputDir
+		voice = voiceManager.Health()
+	}
+
+	status := &systemStatus{
+		Status:         "ok",
+		CheckedAt:      time.Now(),
+		Disk:           diskStatus{Directories: make(map[string]directoryUsage)},
+		Voice:          voice,
+		NoisyPatterns:  []aegong.PatternFeedback{},
+		DetectorPanics: map[string]int{},
+		Warnings:       []string{},
+	}
+	status.SelfTestFailures = selfTestFailures()
+	if status.SelfTestFailures == nil {
+		status.SelfTestFailures = []aegong.DetectorCanary{}
+	}
+	warn := func(format string, args ...interface{}) {
+		status.Warnings = append(status.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	for name, dir := range map[string]string{"uploads": "uploads", "reports": "reports", "voice_reports": voiceDir} {
+		usage := directorySize(dir)
+		status.Disk.Directories[name] = usage
+		status.Disk.UsedBytes += usage.Bytes
+	}
+	if thresholds.diskMB > 0 && status.Disk.UsedBytes > int64(thresholds.diskMB)<<20 {
+		warn("Uploads, reports and voice reports use %d MB, over the %d MB threshold", status.Disk.UsedBytes>>20, thresholds.diskMB)
+	}
+
+	if free, total, ok := filesystemSpace("."); ok && total > 0 {
+		status.Disk.FreeBytes = free
+		status.Disk.FilesystemBytes = total
+		status.Disk.FreePercent = float64(free) / float64(total) * 100
+		if status.Disk.FreePercent < float64(thresholds.freePercent) {
+			warn("Only %.1f%% of the filesystem is free, under the %d%% threshold", status.Disk.FreePercent, thresholds.freePercent)
+		}
+	}
+
+	if engine != nil {
+		status.NoisyPatterns = engine.Feedback().NoisyPatterns
+		status.Sandboxes = engine.SandboxStatus()
+		if status.Sandboxes.StaticOnly != "" {
+			warn("Agents are audited without being run: %s", status.Sandboxes.StaticOnly)
+		}
+		if !status.Sandboxes.Cgroups {
+			warn("Sandboxes run without cgroup memory and CPU limits: %s", status.Sandboxes.CgroupReason)
+		}
+		status.DetectorPanics = engine.DetectorPanics()
+		detectors := make([]string, 0, len(status.DetectorPanics))
+		for detector := range status.DetectorPanics {
+			detectors = append(detectors, detector)
+		}
+		sort.Strings(detectors)
+		for _, detector := range detectors {
+			warn("Detector %s has panicked %d times since startup", detector, status.DetectorPanics[detector])
+		}
+	}
+	for _, failure := range status.SelfTestFailures {
+		warn("Detector %s missed its self-test canary: %s", failure.Detector, failure.Reason)
+	}
+
+	if patternFeed != nil {
+		status.PatternFeed = patternFeed.status()
+		if status.PatternFeed.LastError != "" {
+			warn("Pattern feed update failed: %s", status.PatternFeed.LastError)
+		}
+	}
+
+	for _, agent := range agentRegistry.overdue(status.CheckedAt) {
+		warn("Agent %s (%s, owned by %s) was due for re-audit on %s", agent.Name, agent.State, agent.Owner, agent.NextAuditDue.Format("2006-01-02"))
+	}
+
+	running, queued := auditSlots.stats()
+	status.Queue = queueStatus{Running: running, Queued: queued, Limit: auditSlots.limit, Depth: auditSlots.depth}
+	if auditSlots.depth > 0 && queued*100 >= auditSlots.depth*thresholds.queuePercent {
+		warn("Audit queue holds %d of %d audits", queued, auditSlots.depth)
+	}
+
+	if voice.Enabled && !voice.Healthy {
+		warn("Voice provider %s is unavailable: %s", voice.Provider, voice.Reason)
+	} else if voice.Fallback != "" {
+		warn("Voice provider %s is unavailable, falling back to %s: %s", voice.Provider, voice.Fallback, voice.Reason)
+	}
+
+	status.Keys = keyStatuses(status.CheckedAt)
+	for _, key := range status.Keys {
+		switch {
+		case key.Expired:
+			warn("The %s key %s expired on %s and must be rotated", key.Source, key.Name, key.ExpiresAt.Format("2006-01-02"))
+		case !key.ExpiresAt.IsZero() && key.ExpiresAt.Sub(status.CheckedAt) < time.Duration(thresholds.keyDays)*24*time.Hour:
+			warn("The %s key %s expires on %s, rotate it soon", key.Source, key.Name, key.ExpiresAt.Format("2006-01-02"))
+		}
+	}
+
+	if len(status.Warnings) > 0 {
+		status.Status = "warning"
+	}
+	return status
+}
+
+// keyStatuses lists the keys loaded for storage encryption, executive
+// summaries and voice providers
+func keyStatuses(now time.Time) []keyStatus {
+	var voiceKeys, summaryKeys *keys.KeyManager
+	if voiceManager != nil {
+		voiceKeys = voiceManager.apiKeys()
+	}
+	if summarizer != nil {
+		summaryKeys = summarizer.keys
+	}
+
+	statuses := []keyStatus{}
+	for _, source := range []struct {
+		name    string
+		manager *keys.KeyManager
+	}{{"storage", storageKeyManager}, {"summary", summaryKeys}, {"voice", voiceKeys}} {
+		if source.manager == nil {
+			continue
+		}
+		names := source.manager.GetAllKeys()
+		sort.Strings(names)
+		for _, name := range names {
+			metadata, _ := source.manager.GetKeyMetadata(name)
+			statuses = append(statuses, keyStatus{
+				Source:    source.name,
+				Name:      name,
+				CreatedAt: metadata.CreatedAt,
+				ExpiresAt: metadata.ExpiresAt,
+				Expired:   metadata.Expired(now),
+			})
+		}
+	}
+	return statuses
+}
+
+// statusHandler reports disk usage, sandboxes, the audit queue, voice health and key expiry
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireRole(w, r, RoleAdmin, RoleAuditor); !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collectStatus())
+}
+
+// runStatusMonitor checks the status periodically, logging each warning and
+// publishing it on the event bus when it first appears
+func runStatusMonitor(ctx context.Context) {
+	ticker := time.NewTicker(statusCheckInterval)
+	defer ticker.Stop()
+
+	active := make(map[string]bool)
+	for {
+		current := make(map[string]bool)
+		for _, warning := range collectStatus().Warnings {
+			current[warning] = true
+			if !active[warning] {
+				log.Printf("Warning: %s", warning)
+				bus.publish(busEvent{Type: eventStatusWarning, Warning: warning})
+			}
+		}
+		active = current
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	keys "Agent_Auditor/key_manager"
+	"Agent_Auditor/pkg/aegong"
+)
+
+// TestStatusAPI tests disk usage, queue depth and threshold warnings in the admin status
+func TestStatusAPI(t *testing.T) {
+	withTestUpload(t)
+
+	oldTokens, oldEngine, oldSlots, oldThresholds := apiTokens, engine, auditSlots, thresholds
+	t.Cleanup(func() { apiTokens, engine, auditSlots, thresholds = oldTokens, oldEngine, oldSlots, oldThresholds })
+	apiTokens, _ = loadAPITokens("alice:admin:admin,bob:viewer:view")
+	engine, _ = aegong.NewEngine(aegong.Config{})
+	defer engine.Close()
+	auditSlots = newAuditExecutor(1, 2)
+	thresholds = statusThresholds{queuePercent: 50}
+
+	request := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/admin/status", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		statusHandler(rec, r)
+		return rec
+	}
+
+	if rec := request("view"); rec.Code != http.StatusForbidden {
+		t.Fatalf("Viewers should not see the status, got %d", rec.Code)
+	}
+
+	// One running audit and one queued fills half the queue
+	running, _ := auditSlots.enqueue()
+	queued, _ := auditSlots.enqueue()
+	defer running.release()
+	defer queued.release()
+
+	rec := request("admin")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Admins should see the status, got %d", rec.Code)
+	}
+	var status systemStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+
+	uploads := status.Disk.Directories["uploads"]
+	if uploads.Files != 1 || uploads.Bytes != int64(len("print('hi')\n")) || status.Disk.UsedBytes != uploads.Bytes {
+		t.Fatalf("Should measure the uploads directory, got %+v", status.Disk)
+	}
+	if status.Queue.Running != 1 || status.Queue.Queued != 1 || status.Queue.Depth != 2 {
+		t.Fatalf("Should report queue depth, got %+v", status.Queue)
+	}
+	if status.Voice.Enabled {
+		t.Fatal("Voice should be reported as disabled")
+	}
+
+	queueWarning := false
+	for _, warning := range status.Warnings {
+		queueWarning = queueWarning || strings.Contains(warning, "Audit queue holds 1 of 2")
+	}
+	if status.Status != "warning" || !queueWarning {
+		t.Fatalf("A half full queue should cross the 50%% threshold, got %s %v", status.Status, status.Warnings)
+	}
+}
+
+// TestKeyExpiryStatus tests warnings about keys that have expired or expire soon
+func TestKeyExpiryStatus(t *testing.T) {
+	withTestUpload(t)
+	oldKeys, oldThresholds := storageKeyManager, thresholds
+	t.Cleanup(func() { storageKeyManager, thresholds = oldKeys, oldThresholds })
+	thresholds = statusThresholds{keyDays: 14}
+
+	now := time.Now().UTC()
+	keyFile := filepath.Join(t.TempDir(), "default.key")
+	metadata := map[string]keys.KeyMetadata{
+		"storage": {ExpiresAt: now.Add(3 * 24 * time.Hour)},
+		"old":     {ExpiresAt: now.Add(-24 * time.Hour)},
+		"later":   {ExpiresAt: now.Add(90 * 24 * time.Hour)},
+	}
+	if err := keys.CreateKeyFileWithMetadata(keyFile, "pass", map[string]string{"storage": "s", "old": "o", "later": "l", "plain": "p"}, metadata); err != nil {
+		t.Fatal(err)
+	}
+	storageKeyManager = keys.NewKeyManager(keyFile)
+	storageKeyManager.Initialize("pass")
+	if err := storageKeyManager.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	status := collectStatus()
+	if len(status.Keys) != 4 || status.Keys[0].Name != "later" || status.Keys[1].Name != "old" || !status.Keys[1].Expired || status.Keys[0].Source != "storage" {
+		t.Fatalf("Should list the keys in use with their expiry, got %+v", status.Keys)
+	}
+	var expired, expiring, others int
+	for _, warning := range status.Warnings {
+		switch {
+		case strings.Contains(warning, "key old expired on"):
+			expired++
+		case strings.Contains(warning, "key storage expires on"):
+			expiring++
+		case strings.Contains(warning, "key later") || strings.Contains(warning, "key plain"):
+			others++
+		}
+	}
+	if expired != 1 || expiring != 1 || others != 0 {
+		t.Errorf("Should warn about the expired key and the one expiring within 14 days only, got %v", status.Warnings)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	keys "Agent_Auditor/key_manager"
+)
+
+// Header marking a file in uploads/ or reports/ as encrypted, so plaintext
+// files written before encryption was enabled stay readable
+var encryptedHeader = []byte("AEGONG-ENC1\n")
+
+// Key file holding the key that encrypts uploads and reports at rest, also
+// watched for the key's expiry; nil stores them in plaintext. The key is
+// looked up on each use rather than kept, so it only lives in the key
+// manager's memory, which AEGONG_KEY_MLOCK, AEGONG_KEY_IDLE_TIMEOUT and
+// wipeKeys protect.
+var storageKeyManager *keys.KeyManager
+
+// Name of the at-rest key in the key file
+var storageKeyName string
+
+// initKeyProtection reads how decrypted keys are held in memory:
+// AEGONG_KEY_MLOCK=1 locks them into RAM and AEGONG_KEY_IDLE_TIMEOUT zeroes
+// them after that long unused, decrypting the key file again when needed
+func initKeyProtection() error {
+	keys.LockMemory = os.Getenv("AEGONG_KEY_MLOCK") == "1"
+	if value := os.Getenv("AEGONG_KEY_IDLE_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("AEGONG_KEY_IDLE_TIMEOUT must be a duration such as 15m, got %q", value)
+		}
+		keys.IdleTimeout = timeout
+	}
+	return nil
+}
+
+// wipeKeys zeroes the decrypted keys held by the storage, summary and voice
+// key managers
+func wipeKeys() {
+	if storageKeyManager != nil {
+		storageKeyManager.Wipe()
+	}
+	if summarizer != nil {
+		summarizer.keys.Wipe()
+	}
+	if voiceManager != nil {
+		if manager := voiceManager.apiKeys(); manager != nil {
+			manager.Wipe()
+		}
+	}
+}
+
+// initStorageEncryption loads the at-rest key when AEGONG_ENCRYPT_AT_REST=1.
+// The key is read from the encrypted key file AEGONG_STORAGE_KEY_FILE
+// (default default.key), unlocked with AEGONG_KEY_PASS, under the name in
+// AEGONG_STORAGE_KEY_NAME (default storage).
+func initStorageEncryption() error {
+	if os.Getenv("AEGONG_ENCRYPT_AT_REST") != "1" {
+		return nil
+	}
+
+	keyFile := os.Getenv("AEGONG_STORAGE_KEY_FILE")
+	if keyFile == "" {
+		keyFile = "default.key"
+	}
+	keyName := os.Getenv("AEGONG_STORAGE_KEY_NAME")
+	if keyName == "" {
+		keyName = "storage"
+	}
+
+	manager := keys.NewKeyManager(keyFile)
+	if err := manager.Initialize(os.Getenv("AEGONG_KEY_PASS")); err != nil {
+		return fmt.Errorf("failed to unlock %s: %v", keyFile, err)
+	}
+	if err := manager.LoadKeys(); err != nil {
+		return err
+	}
+	err := manager.WithKey(keyName, func(key []byte) error {
+		if len(key) == 0 {
+			return fmt.Errorf("key %s is empty", keyName)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	storageKeyManager, storageKeyName = manager, keyName
+	return nil
+}
+
+// writeStored writes an upload, signature or report, encrypting it if enabled
+func writeStored(path string, data []byte) error {
+	if storageKeyManager == nil {
+		return os.WriteFile(path, data, 0644)
+	}
+
+	var sealed []byte
+	err := storageKeyManager.WithKey(storageKeyName, func(key []byte) (err error) {
+		sealed, err = keys.Encrypt(data, key)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s: %v", filepath.Base(path), err)
+	}
+	return os.WriteFile(path, append(append([]byte{}, encryptedHeader...), sealed...), 0600)
+}
+
+// readStored reads a file written by writeStored, decrypting it if needed
+func readStored(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, ok := bytes.CutPrefix(data, encryptedHeader)
+	if !ok {
+		return data, nil
+	}
+	if storageKeyManager == nil {
+		return nil, fmt.Errorf("%s is encrypted but AEGONG_ENCRYPT_AT_REST is not enabled", filepath.Base(path))
+	}
+	var plain []byte
+	err = storageKeyManager.WithKey(storageKeyName, func(key []byte) (err error) {
+		plain, err = keys.Decrypt(sealed, key)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %v", filepath.Base(path), err)
+	}
+	return plain, nil
+}
+
+// plaintextPath returns a path at which a stored file can be read in the clear
+// by code that only takes paths, such as the validator and the voice script.
+// Encrypted files are decrypted into a private temporary directory under the
+// same name; cleanup removes it.
+func plaintextPath(path string) (string, func(), error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if !bytes.HasPrefix(data, encryptedHeader) {
+		return path, func() {}, nil
+	}
+
+	plain, err := readStored(path)
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := os.MkdirTemp("", "aegong-plain-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create decryption directory: %v", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	plainPath := filepath.Join(dir, filepath.Base(path))
+	if err := os.WriteFile(plainPath, plain, 0600); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write decrypted copy: %v", err)
+	}
+	return plainPath, cleanup, nil
+}
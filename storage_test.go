@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	keys "Agent_Auditor/key_manager"
+
+	"github.com/gorilla/mux"
+)
+
+// TestStorageEncryption tests that stored files are encrypted on disk and
+// read back transparently
+func TestStorageEncryption(t *testing.T) {
+	withTestUpload(t)
+	os.MkdirAll("reports", 0755)
+	oldManager, oldName := storageKeyManager, storageKeyName
+	t.Cleanup(func() { storageKeyManager, storageKeyName = oldManager, oldName })
+
+	if err := keys.CreateKeyFile("storage.key", "passphrase", map[string]string{"storage": "at-rest-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AEGONG_ENCRYPT_AT_REST", "1")
+	t.Setenv("AEGONG_STORAGE_KEY_FILE", "storage.key")
+	t.Setenv("AEGONG_KEY_PASS", "passphrase")
+	if err := initStorageEncryption(); err != nil {
+		t.Fatalf("Should load the storage key: %v", err)
+	}
+
+	report := []byte(`{"agent_hash":"abcdef0123456789","threats":[]}`)
+	reportPath := filepath.Join("reports", "report_abcdef01.json")
+	if err := writeStored(reportPath, report); err != nil {
+		t.Fatal(err)
+	}
+	onDisk, _ := os.ReadFile(reportPath)
+	if bytes.Contains(onDisk, []byte("agent_hash")) {
+		t.Fatal("Report should not be stored in plaintext")
+	}
+
+	// Handlers decrypt transparently
+	r := mux.NewRouter()
+	r.HandleFunc("/api/report/{hash}", reportHandler)
+	oldVoice := voiceManager
+	voiceManager = &VoiceInferenceManager{}
+	t.Cleanup(func() { voiceManager = oldVoice })
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/report/abcdef01", nil))
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), report) {
+		t.Fatalf("Should serve the decrypted report, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Plaintext files from before encryption was enabled stay readable
+	if data, err := readStored(filepath.Join("uploads", "agent.py")); err != nil || string(data) != "print('hi')\n" {
+		t.Fatalf("Should read legacy plaintext upload, got %q, %v", data, err)
+	}
+
+	// Path-based readers get a private decrypted copy under the same name
+	plainPath, cleanup, err := plaintextPath(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plainPath == reportPath || filepath.Base(plainPath) != filepath.Base(reportPath) {
+		t.Fatalf("Should decrypt to a copy named like the original, got %s", plainPath)
+	}
+	if data, _ := os.ReadFile(plainPath); !bytes.Equal(data, report) {
+		t.Fatal("Decrypted copy should match the original")
+	}
+	cleanup()
+	if _, err := os.Stat(plainPath); !os.IsNotExist(err) {
+		t.Fatal("Cleanup should remove the decrypted copy")
+	}
+
+	// Wiped keys can no longer be used
+	storageKeyManager.Wipe()
+	if _, err := readStored(reportPath); err == nil {
+		t.Fatal("Should not decrypt once the keys are wiped")
+	}
+	if err := writeStored(reportPath, report); err == nil {
+		t.Fatal("Should not encrypt once the keys are wiped")
+	}
+
+	// A different key cannot read the files
+	keys.CreateKeyFile("wrong.key", "passphrase", map[string]string{"storage": "wrong"})
+	t.Setenv("AEGONG_STORAGE_KEY_FILE", "wrong.key")
+	if err := initStorageEncryption(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readStored(reportPath); err == nil {
+		t.Fatal("Should fail to decrypt with the wrong key")
+	}
+	storageKeyManager = nil
+	if _, err := readStored(reportPath); err == nil {
+		t.Fatal("Should refuse encrypted files when encryption is disabled")
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	keys "Agent_Auditor/key_manager"
+	"Agent_Auditor/pkg/aegong"
+	"Agent_Auditor/pkg/telemetry"
+)
+
+// An LLM can write each report an executive summary and action list. It is
+// given only the structured findings: vectors, severities, counts and
+// recommendations, never the agent or its evidence.
+
+const (
+	defaultSummaryURL     = "https://api.cerebras.ai/v1" // Any OpenAI compatible API
+	defaultSummaryKeyName = "cerebras"
+	summaryTimeout        = 60 * time.Second
+	summaryPromptVersion  = "v1"
+	maxSummaryActions     = 7
+	maxSummaryLength      = 2000
+)
+
+const summaryPrompt = `You write executive summaries of security audits of AI agents.
+You are given the audit's findings as JSON. Reply with a JSON object with two fields:
+"summary", two to four plain sentences for a non-technical reader on how risky the agent is and why,
+and "actions", the steps to take before deploying it, most urgent first, each an object with
+"action", one imperative sentence, and "vectors", the IDs of the threat vectors it addresses.
+Only mention what the findings support.`
+
+// reportSummarizer asks an LLM for executive summaries
+type reportSummarizer struct {
+	url     string // Base URL of the API
+	model   string
+	keys    *keys.KeyManager
+	keyName string
+	client  *http.Client
+}
+
+// Executive summaries configured by AEGONG_SUMMARY_MODEL; nil when disabled
+var summarizer *reportSummarizer
+
+// initExecutiveSummary reads AEGONG_SUMMARY_MODEL, AEGONG_SUMMARY_URL and the
+// API key named AEGONG_SUMMARY_KEY_NAME (default cerebras) in the encrypted key
+// file AEGONG_SUMMARY_KEY_FILE (default default.key), unlocked with
+// AEGONG_KEY_PASS
+func initExecutiveSummary() error {
+	model := os.Getenv("AEGONG_SUMMARY_MODEL")
+	if model == "" {
+		return nil
+	}
+	baseURL := os.Getenv("AEGONG_SUMMARY_URL")
+	if baseURL == "" {
+		baseURL = defaultSummaryURL
+	}
+	parsed, err := url.Parse(baseURL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return fmt.Errorf("AEGONG_SUMMARY_URL must be an http(s) URL, got %q", baseURL)
+	}
+
+	keyFile := os.Getenv("AEGONG_SUMMARY_KEY_FILE")
+	if keyFile == "" {
+		keyFile = "default.key"
+	}
+	keyName := os.Getenv("AEGONG_SUMMARY_KEY_NAME")
+	if keyName == "" {
+		keyName = defaultSummaryKeyName
+	}
+	manager := keys.NewKeyManager(keyFile)
+	if err := manager.Initialize(os.Getenv("AEGONG_KEY_PASS")); err != nil {
+		return fmt.Errorf("failed to unlock %s: %v", keyFile, err)
+	}
+	if err := manager.LoadKeys(); err != nil {
+		return err
+	}
+	if _, err := manager.GetKey(keyName); err != nil {
+		return err
+	}
+
+	summarizer = &reportSummarizer{
+		url:     strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+		keys:    manager,
+		keyName: keyName,
+		client:  &http.Client{Timeout: summaryTimeout},
+	}
+	log.Printf("Info: Writing executive summaries with %s at %s", model, parsed.Host)
+	return nil
+}
+
+// summaryFinding is the findings of one vector and severity
+type summaryFinding struct {
+	Vector     string   `json:"vector"`
+	Name       string   `json:"name"`
+	Severity   string   `json:"severity"`
+	Count      int      `json:"count"`
+	Confidence float64  `json:"max_confidence"`
+	Analyses   []string `json:"analyses,omitempty"`
+}
+
+// summaryInput is what the LLM is told about a report
+type summaryInput struct {
+	Agent           string           `json:"agent"`
+	OverallRisk     float64          `json:"overall_risk"`
+	RiskLevel       string           `json:"risk_level"`
+	Findings        []summaryFinding `json:"findings"`
+	Recommendations []string         `json:"recommendations"`
+}
+
+// newSummaryInput reduces a report to its structured findings
+func newSummaryInput(report *aegong.AuditReport) summaryInput {
+	input := summaryInput{
+		Agent:           report.AgentName,
+		OverallRisk:     report.OverallRisk,
+		RiskLevel:       aegong.RiskLevel(report.OverallRisk),
+		Findings:        []summaryFinding{},
+		Recommendations: []string{},
+	}
+
+	groups := make(map[string]*summaryFinding)
+	for _, threat := range report.Threats {
+		vector, severity := vectorCode(threat.Vector), aegong.SeverityName(threat.Severity)
+		group := groups[vector+"/"+severity]
+		if group == nil {
+			group = &summaryFinding{Vector: vector, Name: aegong.ThreatName(threat.Vector), Severity: severity}
+			groups[vector+"/"+severity] = group
+		}
+		group.Count++
+		group.Confidence = max(group.Confidence, threat.Confidence)
+		if analysis, ok := threat.Details["analysis"].(string); ok && !slices.Contains(group.Analyses, analysis) {
+			group.Analyses = append(group.Analyses, analysis)
+		}
+	}
+	for _, group := range groups {
+		input.Findings = append(input.Findings, *group)
+	}
+	sort.Slice(input.Findings, func(i, j int) bool {
+		a, b := input.Findings[i], input.Findings[j]
+		if a.Vector != b.Vector {
+			return a.Vector < b.Vector
+		}
+		return a.Severity < b.Severity
+	})
+
+	for _, recommendation := range report.Recommendations {
+		line := fmt.Sprintf("%s (%s priority)", recommendation.Title, recommendation.Priority)
+		if recommendation.Vector != "" {
+			line += " for " + recommendation.Vector
+		}
+		input.Recommendations = append(input.Recommendations, line)
+	}
+	return input
+}
+
+// chatRequest and chatResponse are the parts of the chat completions API used
+type chatRequest struct {
+	Model          string            `json:"model"`
+	Messages       []chatMessage     `json:"messages"`
+	Temperature    float64           `json:"temperature"`
+	ResponseFormat map[string]string `json:"response_format"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatResponse struct {
+	Model             string `json:"model"`
+	SystemFingerprint string `json:"system_fingerprint"`
+	Choices           []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// summarize asks the LLM for a report's executive summary
+func (s *reportSummarizer) summarize(ctx context.Context, report *aegong.AuditReport) (*aegong.ExecutiveSummary, error) {
+	ctx, span := telemetry.Start(ctx, "aegong.summary", "llm.model", s.model)
+	defer span.End()
+	summary, err := s.request(ctx, report)
+	span.SetError(err)
+	return summary, err
+}
+
+func (s *reportSummarizer) request(ctx context.Context, report *aegong.AuditReport) (*aegong.ExecutiveSummary, error) {
+	apiKey, err := s.keys.GetKey(s.keyName)
+	if err != nil {
+		return nil, err
+	}
+	input, _ := json.Marshal(newSummaryInput(report))
+	body, _ := json.Marshal(chatRequest{
+		Model: s.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: summaryPrompt},
+			{Role: "user", Content: string(input)},
+		},
+		Temperature:    0.2,
+		ResponseFormat: map[string]string{"type": "json_object"},
+	})
+
+	request, err := http.NewRequestWithContext(ctx, "POST", s.url+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+apiKey)
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("summary request failed: %v", err)
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read summary response: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("summary request failed with %s: %s", response.Status, strings.TrimSpace(string(data)))
+	}
+
+	var completion chatResponse
+	if err := json.Unmarshal(data, &completion); err != nil || len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("invalid summary response: %s", strings.TrimSpace(string(data)))
+	}
+	summary, err := parseSummary(completion.Choices[0].Message.Content, report)
+	if err != nil {
+		return nil, err
+	}
+
+	summary.Provider = requestHost(s.url)
+	summary.Model = s.model
+	summary.ModelVersion = completion.Model
+	if completion.SystemFingerprint != "" {
+		summary.ModelVersion += " (" + completion.SystemFingerprint + ")"
+	}
+	summary.PromptVersion = summaryPromptVersion
+	summary.GeneratedAt = time.Now()
+	return summary, nil
+}
+
+func requestHost(rawURL string) string {
+	parsed, _ := url.Parse(rawURL)
+	return parsed.Host
+}
+
+// parseSummary validates the LLM's reply, keeping only vectors the report has
+func parseSummary(content string, report *aegong.AuditReport) (*aegong.ExecutiveSummary, error) {
+	var reply struct {
+		Summary string `json:"summary"`
+		Actions []struct {
+			Action  string   `json:"action"`
+			Vectors []string `json:"vectors"`
+		} `json:"actions"`
+	}
+	if err := json.Unmarshal([]byte(content), &reply); err != nil {
+		return nil, fmt.Errorf("summary is not the requested JSON: %v", err)
+	}
+	reply.Summary = strings.TrimSpace(reply.Summary)
+	if reply.Summary == "" {
+		return nil, fmt.Errorf("summary is empty")
+	}
+	if len(reply.Summary) > maxSummaryLength {
+		reply.Summary = reply.Summary[:maxSummaryLength]
+	}
+
+	found := make(map[string]bool)
+	for _, threat := range report.Threats {
+		found[vectorCode(threat.Vector)] = true
+	}
+	summary := &aegong.ExecutiveSummary{Summary: reply.Summary, Actions: []aegong.SummaryAction{}}
+	for _, action := range reply.Actions {
+		text := strings.TrimSpace(action.Action)
+		if text == "" || len(summary.Actions) == maxSummaryActions {
+			continue
+		}
+		var vectors []string
+		for _, vector := range action.Vectors {
+			if vector = strings.ToUpper(strings.TrimSpace(vector)); found[vector] && !slices.Contains(vectors, vector) {
+				vectors = append(vectors, vector)
+			}
+		}
+		summary.Actions = append(summary.Actions, aegong.SummaryAction{
+			Priority: len(summary.Actions) + 1,
+			Action:   text,
+			Vectors:  vectors,
+		})
+	}
+	return summary, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	keys "Agent_Auditor/key_manager"
+	"Agent_Auditor/pkg/aegong"
+)
+
+// TestExecutiveSummary tests asking an LLM for a summary of the structured findings
+func TestExecutiveSummary(t *testing.T) {
+	withTestUpload(t)
+	if err := keys.CreateKeyFile("summary.key", "passphrase", map[string]string{"cerebras": "llm-secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var request chatRequest
+	var authorization string
+	reply := `{"summary": "The agent runs shell commands it should not.", "actions": [` +
+		`{"action": "Remove shell access", "vectors": ["T4", "t4", "T7"]}, {"action": " "}, {"action": "Audit it again"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		authorization = r.Header.Get("Authorization")
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &request)
+		content, _ := json.Marshal(reply)
+		w.Write([]byte(`{"model": "llama3.1-8b-0925", "system_fingerprint": "fp_1", "choices": [{"message": {"role": "assistant", "content": ` + string(content) + `}}]}`))
+	}))
+	defer server.Close()
+
+	oldSummarizer := summarizer
+	t.Cleanup(func() { summarizer = oldSummarizer })
+	t.Setenv("AEGONG_SUMMARY_MODEL", "llama3.1-8b")
+	t.Setenv("AEGONG_SUMMARY_URL", server.URL+"/v1/")
+	t.Setenv("AEGONG_SUMMARY_KEY_FILE", "summary.key")
+	t.Setenv("AEGONG_KEY_PASS", "passphrase")
+	if err := initExecutiveSummary(); err != nil || summarizer == nil {
+		t.Fatalf("Executive summaries should be configured: %v", err)
+	}
+
+	report := &aegong.AuditReport{
+		AgentName:   "crawler",
+		OverallRisk: 0.7,
+		Threats: []aegong.ThreatDetection{
+			{Vector: aegong.T4_UNAUTHORIZED_ACTION, Severity: aegong.HIGH, Confidence: 0.6, Evidence: []string{"Spawned /bin/sh with password=hunter2"}, Details: map[string]interface{}{"analysis": "sandbox_denials"}},
+			{Vector: aegong.T4_UNAUTHORIZED_ACTION, Severity: aegong.HIGH, Confidence: 0.9},
+		},
+		Recommendations: []aegong.Recommendation{{Title: "Drop the shell", Priority: "high", Vector: "T4"}},
+	}
+	summary, err := summarizer.summarize(context.Background(), report)
+	if err != nil {
+		t.Fatalf("Summary should be written: %v", err)
+	}
+
+	if authorization != "Bearer llm-secret" || request.Model != "llama3.1-8b" || request.ResponseFormat["type"] != "json_object" {
+		t.Errorf("Should ask the configured model with the key from the key file, got %q and %+v", authorization, request)
+	}
+	input := request.Messages[1].Content
+	if strings.Contains(input, "hunter2") || !strings.Contains(input, `"vector":"T4","name":"Unauthorized Action","severity":"HIGH","count":2,"max_confidence":0.9`) ||
+		!strings.Contains(input, "Drop the shell (high priority) for T4") {
+		t.Errorf("The LLM should be given the structured findings without their evidence, got %s", input)
+	}
+
+	if summary.Summary != "The agent runs shell commands it should not." || len(summary.Actions) != 2 ||
+		summary.Actions[0].Priority != 1 || summary.Actions[0].Action != "Remove shell access" || strings.Join(summary.Actions[0].Vectors, ",") != "T4" ||
+		summary.Actions[1].Priority != 2 || summary.Actions[1].Action != "Audit it again" {
+		t.Errorf("Actions should be numbered and keep only vectors the report has, got %+v", summary)
+	}
+	if summary.Model != "llama3.1-8b" || summary.ModelVersion != "llama3.1-8b-0925 (fp_1)" || summary.PromptVersion != summaryPromptVersion ||
+		!strings.HasPrefix(summary.Provider, "127.0.0.1:") || summary.GeneratedAt.IsZero() {
+		t.Errorf("Summary should record its provenance, got %+v", summary)
+	}
+
+	reply = `Sure! Here is the summary.`
+	if _, err := summarizer.summarize(context.Background(), report); err == nil {
+		t.Error("Replies that are not the requested JSON should be rejected")
+	}
+}
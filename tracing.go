@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"Agent_Auditor/pkg/aegong"
+	"Agent_Auditor/pkg/telemetry"
+
+	"github.com/gorilla/mux"
+)
+
+// Tracer exporting spans when OTEL_EXPORTER_OTLP_ENDPOINT is set
+var tracer *telemetry.Tracer
+
+// initTracing starts exporting trace spans if an OTLP collector is configured
+func initTracing() error {
+	config, ok, err := telemetry.ConfigFromEnv()
+	if err != nil || !ok {
+		return err
+	}
+	config.ServiceVersion = aegong.Version
+	tracer = telemetry.NewTracer(config)
+	telemetry.SetTracer(tracer)
+	return nil
+}
+
+// shutdownTracing exports the spans still queued
+func shutdownTracing(ctx context.Context) {
+	if tracer != nil {
+		tracer.Shutdown(ctx)
+	}
+}
+
+// traceRequests records a server span for each request, continuing the
+// caller's trace when it sends a traceparent header
+func traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !telemetry.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		if parent, err := telemetry.ParseTraceparent(r.Header.Get("traceparent")); err == nil {
+			ctx = telemetry.WithRemoteParent(ctx, parent)
+		}
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		ctx, span := telemetry.StartKind(ctx, r.Method+" "+route, telemetry.KindServer,
+			"http.request.method", r.Method,
+			"http.route", route,
+			"url.path", r.URL.Path,
+			"aegong.correlation_id", aegong.CorrelationID(ctx),
+		)
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		span.SetAttribute("http.response.status_code", recorder.status)
+		if recorder.status >= 500 {
+			span.SetError(fmt.Errorf("%s", http.StatusText(recorder.status)))
+		}
+	})
+}
+
+// statusRecorder remembers the status code a handler wrote, and passes
+// through the flushing and hijacking that event streams and WebSockets need
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("connection cannot be hijacked")
+	}
+	s.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
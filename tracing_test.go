@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"Agent_Auditor/pkg/telemetry"
+
+	"github.com/gorilla/mux"
+)
+
+// TestTraceRequests tests that requests get server spans named by their route
+func TestTraceRequests(t *testing.T) {
+	type exportedSpan struct {
+		TraceID      string `json:"traceId"`
+		ParentSpanID string `json:"parentSpanId"`
+		Name         string `json:"name"`
+		Kind         int    `json:"kind"`
+		Attributes   []struct {
+			Key   string                 `json:"key"`
+			Value map[string]interface{} `json:"value"`
+		} `json:"attributes"`
+	}
+	var mutex sync.Mutex
+	var spans []exportedSpan
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, resource := range request.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				spans = append(spans, scope.Spans...)
+			}
+		}
+	}))
+	defer collector.Close()
+
+	testTracer := telemetry.NewTracer(telemetry.Config{Endpoint: collector.URL})
+	telemetry.SetTracer(testTracer)
+	defer telemetry.SetTracer(nil)
+
+	router := mux.NewRouter()
+	router.Use(traceRequests)
+	router.HandleFunc("/api/report/{hash}", func(w http.ResponseWriter, r *http.Request) {
+		if telemetry.FromContext(r.Context()) == nil {
+			t.Error("Handler should run within the request span")
+		}
+		http.NotFound(w, r)
+	})
+
+	r := httptest.NewRequest("GET", "/api/report/abcdef01", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	testTracer.Shutdown(context.Background())
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(spans) != 1 {
+		t.Fatalf("Should export one span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /api/report/{hash}" || span.Kind != telemetry.KindServer {
+		t.Fatalf("Span should be a server span named by its route, got %q kind %d", span.Name, span.Kind)
+	}
+	if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentSpanID != "00f067aa0ba902b7" {
+		t.Fatal("Span should continue the caller's trace")
+	}
+	status := ""
+	for _, attribute := range span.Attributes {
+		if attribute.Key == "http.response.status_code" {
+			status, _ = attribute.Value["intValue"].(string)
+		}
+	}
+	if status != "404" {
+		t.Fatalf("Span should record the response status, got %q", status)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// VoiceConfigUpdate changes voice settings at runtime; omitted fields are kept
+type VoiceConfigUpdate struct {
+	Enabled      *bool                  `json:"enabled,omitempty"`
+	Provider     *string                `json:"provider,omitempty"`
+	DefaultVoice *string                `json:"default_voice,omitempty"`
+	DefaultModel *string                `json:"default_model,omitempty"`
+	Timeout      *int                   `json:"timeout,omitempty"`
+	Fallbacks    *[]VoiceProviderConfig `json:"fallbacks,omitempty"`
+	LocalEngine  *string                `json:"local_engine,omitempty"`
+	Pregenerate  *VoicePregenerate      `json:"pregenerate,omitempty"`
+}
+
+// Update applies a configuration change and saves it to the configuration
+// file, so it survives a restart. Reports already spoken keep their audio.
+func (v *VoiceInferenceManager) Update(update VoiceConfigUpdate) (VoiceInferenceConfig, error) {
+	v.configLock.Lock()
+	defer v.configLock.Unlock()
+
+	config := v.config
+	if update.Enabled != nil {
+		config.Enabled = *update.Enabled
+	}
+	if update.Provider != nil {
+		config.Provider = *update.Provider
+	}
+	if update.DefaultVoice != nil {
+		config.DefaultVoice = *update.DefaultVoice
+	}
+	if update.DefaultModel != nil {
+		config.DefaultModel = *update.DefaultModel
+	}
+	if update.Timeout != nil {
+		config.Timeout = *update.Timeout
+	}
+	if update.Fallbacks != nil {
+		config.Fallbacks = *update.Fallbacks
+	}
+	if update.LocalEngine != nil {
+		config.LocalEngine = *update.LocalEngine
+	}
+	if update.Pregenerate != nil {
+		config.Pregenerate = *update.Pregenerate
+	}
+	config.clearOpenAIDefaults()
+	if err := validateVoiceConfig(config); err != nil {
+		return v.config, err
+	}
+
+	if config.Enabled {
+		if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+			return v.config, fmt.Errorf("failed to create voice reports directory: %v", err)
+		}
+	}
+	if err := saveVoiceConfig(v.configPath, config); err != nil {
+		return v.config, err
+	}
+
+	if config.Enabled && config.needsAPIKeys() && v.keyManager == nil {
+		v.loadKeys(config)
+	}
+	v.config = config
+	return config, nil
+}
+
+// validateVoiceConfig checks the providers and engine are ones Aegong knows
+func validateVoiceConfig(config VoiceInferenceConfig) error {
+	if config.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if config.Pregenerate.MinRisk < 0 || config.Pregenerate.MinRisk > 1 {
+		return fmt.Errorf("pregenerate min_risk must be between 0 and 1")
+	}
+	for _, provider := range config.chain() {
+		if _, ok := voiceProviderKeys[provider.Provider]; !ok && !isLocalProvider(provider.Provider) {
+			return fmt.Errorf("unsupported TTS provider: %s", provider.Provider)
+		}
+		if provider.Timeout < 0 {
+			return fmt.Errorf("timeout of %s must not be negative", provider.Provider)
+		}
+	}
+	if config.LocalEngine == "" {
+		return nil
+	}
+	for _, engine := range localEngines {
+		if engine.name == config.LocalEngine {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown local TTS engine: %s", config.LocalEngine)
+}
+
+// saveVoiceConfig writes the configuration file, replacing it only once the
+// new one is complete
+func saveVoiceConfig(path string, config VoiceInferenceConfig) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), ".voice_config-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save voice config: %v", err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(append(data, '\n')); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to save voice config: %v", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to save voice config: %v", err)
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save voice config: %v", err)
+	}
+	return nil
+}
+
+// voiceConfigResponse is the voice configuration and whether it currently works
+type voiceConfigResponse struct {
+	Config VoiceInferenceConfig `json:"config"`
+	Health VoiceHealth          `json:"health"`
+}
+
+// voiceConfigHandler returns the voice configuration
+func voiceConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireRole(w, r, RoleAdmin); !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(voiceConfigResponse{Config: voiceManager.Config(), Health: voiceManager.Health()})
+}
+
+// updateVoiceConfigHandler changes the voice provider, voice, model or
+// enablement without a restart
+func updateVoiceConfigHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requireRole(w, r, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	var update VoiceConfigUpdate
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&update); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid voice configuration: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	config, err := voiceManager.Update(update)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Voice configuration updated by %s: enabled=%t provider=%s voice=%s model=%s", principal.Name, config.Enabled, config.Provider, config.DefaultVoice, config.DefaultModel)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(voiceConfigResponse{Config: config, Health: voiceManager.Health()})
+}
+
+// voiceFilesHandler serves generated audio from the current output directory
+func voiceFilesHandler(w http.ResponseWriter, r *http.Request) {
+	if !voiceManager.IsEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	path, err := confinedPath(voiceManager.Config().OutputDir, r.URL.Path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	serveFile(w, r, path)
+}
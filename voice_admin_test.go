@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestVoiceConfigAdmin tests changing voice settings at runtime and saving them
+func TestVoiceConfigAdmin(t *testing.T) {
+	oldTokens, oldManager := apiTokens, voiceManager
+	t.Cleanup(func() { apiTokens, voiceManager = oldTokens, oldManager })
+	apiTokens, _ = loadAPITokens("alice:admin:admin,ci:auditor:audit")
+
+	withTestUpload(t)
+	os.WriteFile("voice_config.json", []byte(`{"enabled": false, "provider": "openai", "ws_url": "wss://example.livekit.cloud"}`), 0644)
+	var err error
+	if voiceManager, err = NewVoiceInferenceManager("voice_config.json"); err != nil {
+		t.Fatalf("Failed to create voice manager: %v", err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/admin/voice", voiceConfigHandler).Methods("GET")
+	router.HandleFunc("/api/admin/voice", updateVoiceConfigHandler).Methods("PATCH")
+	router.PathPrefix("/voice_reports/").Handler(http.StripPrefix("/voice_reports/", http.HandlerFunc(voiceFilesHandler)))
+	request := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := request("PATCH", "/api/admin/voice", "audit", `{"enabled": true}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("Only admins should change voice settings, got %d", rec.Code)
+	}
+	if rec := request("GET", "/voice_reports/aegong_report_abcdef01.wav", "", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("Audio should not be served while voice is disabled, got %d", rec.Code)
+	}
+
+	rec := request("PATCH", "/api/admin/voice", "admin", `{"enabled": true, "provider": "local", "local_engine": "espeak"}`)
+	var response voiceConfigResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("Admins should change voice settings, got %d: %s", rec.Code, rec.Body)
+	}
+	if !response.Config.Enabled || response.Config.Provider != "local" || response.Config.DefaultVoice != "" || !response.Health.Enabled {
+		t.Errorf("Response should show the new settings, got %+v", response)
+	}
+	if config := voiceManager.Config(); !config.Enabled || config.LocalEngine != "espeak" {
+		t.Errorf("Settings should apply without a restart, got %+v", config)
+	}
+
+	var saved VoiceInferenceConfig
+	data, _ := os.ReadFile("voice_config.json")
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Provider != "local" || !saved.Enabled || saved.WSURL != "wss://example.livekit.cloud" {
+		t.Errorf("Settings should be saved with the rest of the file, got %v:\n%s", err, data)
+	}
+
+	os.WriteFile(filepath.Join("voice_reports", "aegong_report_abcdef01.wav"), []byte("RIFF"), 0644)
+	if rec := request("GET", "/voice_reports/aegong_report_abcdef01.wav", "", ""); rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), []byte("RIFF")) {
+		t.Errorf("Audio should be served once voice is enabled, got %d", rec.Code)
+	}
+
+	for _, body := range []string{`{"provider": "tin-can"}`, `{"local_engine": "festival"}`, `{"timeout": -1}`, `{"pregenerate": {"enabled": true, "min_risk": 1.5}}`, `{"api_key": "sk-123"}`} {
+		if rec := request("PATCH", "/api/admin/voice", "admin", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s should be rejected, got %d", body, rec.Code)
+		}
+	}
+	if rec := request("GET", "/api/admin/voice", "admin", ""); !strings.Contains(rec.Body.String(), `"provider":"local"`) {
+		t.Errorf("Rejected changes should leave the settings alone, got %s", rec.Body)
+	}
+}
+
+// TestVoiceFileDownload tests range requests and resuming voice report downloads
+func TestVoiceFileDownload(t *testing.T) {
+	oldManager := voiceManager
+	t.Cleanup(func() { voiceManager = oldManager })
+
+	withTestUpload(t)
+	os.WriteFile("voice_config.json", []byte(`{"enabled": true, "provider": "local", "output_dir": "voice_reports"}`), 0644)
+	var err error
+	if voiceManager, err = NewVoiceInferenceManager("voice_config.json"); err != nil {
+		t.Fatalf("Failed to create voice manager: %v", err)
+	}
+	os.MkdirAll(filepath.Join("voice_reports", "old"), 0755)
+	audio := []byte("RIFF0123456789")
+	os.WriteFile(filepath.Join("voice_reports", "aegong_report_abcdef01.wav"), audio, 0644)
+
+	router := mux.NewRouter()
+	router.PathPrefix("/voice_reports/").Handler(http.StripPrefix("/voice_reports/", http.HandlerFunc(voiceFilesHandler)))
+	request := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := request("/voice_reports/aegong_report_abcdef01.wav", nil)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" || rec.Header().Get("Accept-Ranges") != "bytes" || rec.Header().Get("X-Accel-Buffering") != "no" {
+		t.Fatalf("Audio should be served for ranged downloads, got %d: %v", rec.Code, rec.Header())
+	}
+	if disposition := rec.Header().Get("Content-Disposition"); disposition != "inline; filename=aegong_report_abcdef01.wav" {
+		t.Errorf("Audio should play inline, got %q", disposition)
+	}
+	if disposition := request("/voice_reports/aegong_report_abcdef01.wav?download=1", nil).Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, "attachment;") {
+		t.Errorf("download=1 should save the audio, got %q", disposition)
+	}
+
+	rec = request("/voice_reports/aegong_report_abcdef01.wav", map[string]string{"Range": "bytes=4-7", "If-Range": etag})
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "0123" || rec.Header().Get("Content-Range") != "bytes 4-7/14" {
+		t.Errorf("Range should be served when the file is unchanged, got %d: %q", rec.Code, rec.Body)
+	}
+	rec = request("/voice_reports/aegong_report_abcdef01.wav", map[string]string{"Range": "bytes=4-7", "If-Range": `"stale"`})
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), audio) {
+		t.Errorf("Changed files should be sent whole, got %d", rec.Code)
+	}
+	if rec := request("/voice_reports/aegong_report_abcdef01.wav", map[string]string{"If-None-Match": etag}); rec.Code != http.StatusNotModified {
+		t.Errorf("Unchanged files should not be sent again, got %d", rec.Code)
+	}
+
+	for _, path := range []string{"/voice_reports/", "/voice_reports/old", "/voice_reports/old/", "/voice_reports/..%5Cvoice_config.json", "/voice_reports/missing.wav"} {
+		if rec := request(path, nil); rec.Code != http.StatusNotFound {
+			t.Errorf("%s should not be served, got %d", path, rec.Code)
+		}
+	}
+}
@@ -83,6 +83,9 @@ RECOMMENDATION_EXPLANATIONS = {
 }
 DEFAULT_EXPLANATION = "This requires immediate attention to maintain agent security integrity."
 
+# Narration profiles, matching the server's; aegong is the playful personality
+NARRATION_PROFILES = ["aegong", "professional", "executive"]
+
 
 class AegongVoiceAgent:
     """Aegong Voice Agent for delivering audit reports using multiple TTS providers"""
@@ -90,7 +93,7 @@ class AegongVoiceAgent:
     def __init__(self, provider: TTSProvider, api_key: str, use_cerebras_enhancement: bool = False, 
                  cerebras_api_key: Optional[str] = None, livekit_api_key: Optional[str] = None, 
                  livekit_api_secret: Optional[str] = None, http_session: Optional[aiohttp.ClientSession] = None, 
-                 **kwargs):
+                 narration: str = "aegong", **kwargs):
         """Initialize the Aegong Voice Agent
 
         Args:
@@ -101,6 +104,7 @@ class AegongVoiceAgent:
             livekit_api_key: LiveKit API key (if needed)
             livekit_api_secret: LiveKit API secret (if needed)
             http_session: aiohttp.ClientSession for HTTP requests (required for standalone usage)
+            narration: Narration profile, one of NARRATION_PROFILES
             **kwargs: Additional provider-specific arguments
         """
         self.provider = provider
@@ -109,6 +113,7 @@ class AegongVoiceAgent:
         self.use_cerebras_enhancement = use_cerebras_enhancement
         self.cerebras_api_key = cerebras_api_key or api_key
         self.http_session = http_session
+        self.narration = narration
         
         # Store LiveKit credentials
         self.livekit_api_key = livekit_api_key or os.environ.get("LIVEKIT_API_KEY")
@@ -331,8 +336,10 @@ Enhanced AEGONG speech:"""
             logger.info("Enhancing text with Cerebras LLM for AEGONG's judgmental delivery...")
             enhanced_message = await self._enhance_text_with_cerebras(enhanced_message, report)
 
-        # Generate the audio file path (as a .wav file)
-        audio_path = os.path.join(output_path, f"aegong_report_{report['agent_hash'][:8]}.wav")
+        # Generate the audio file path (as a .wav file); Aegong's narration
+        # keeps the name it had before there were profiles
+        suffix = "" if self.narration == "aegong" else f"_{self.narration}"
+        audio_path = os.path.join(output_path, f"aegong_report_{report['agent_hash'][:8]}{suffix}.wav")
 
         # Use TTS to generate speech
         logger.info(f"Generating speech with {self.provider.value} provider")
@@ -340,7 +347,8 @@ Enhanced AEGONG speech:"""
         
         # Keep track of providers we've tried
         tried_providers = set([self.provider])
-        max_retries = 2  # Maximum number of fallback providers to try
+        # Maximum number of fallback providers to try; the server configures its own chain
+        max_retries = 0 if self.provider_kwargs.get("no_fallback") else 2
         retry_count = 0
         
         while retry_count <= max_retries:
@@ -475,29 +483,54 @@ Enhanced AEGONG speech:"""
             Enhanced message with deeper analysis
         """
         enhanced_message = base_message
-        
+
+        # The executive summary is read as it is, followed by the recommendation titles
+        if self.narration == "executive":
+            titles = [r.get("title", "") if isinstance(r, dict) else r for r in report.get("recommendations", [])[:3]]
+            if titles:
+                enhanced_message += " Recommended actions: " + "; ".join(titles) + "."
+            return enhanced_message
+
         # Add introduction for voice report
-        enhanced_message = f"Greetings, human. This is Aegong, the Agent Auditor. I have completed my analysis of the agent '{report.get('agent_name', 'Unknown Agent')}'. {enhanced_message}"
+        if self.narration == "aegong":
+            enhanced_message = f"Greetings, human. This is Aegong, the Agent Auditor. I have completed my analysis of the agent '{report.get('agent_name', 'Unknown Agent')}'. {enhanced_message}"
         
         # Add detailed recommendation analysis if recommendations exist
         if recommendations := report.get("recommendations", []):
-            enhanced_message += "\n\nI have prepared detailed recommendations to address the security concerns:"
+            if self.narration == "aegong":
+                enhanced_message += "\n\nI have prepared detailed recommendations to address the security concerns:"
+            else:
+                enhanced_message += "\n\nRecommendations:"
             
             for i, recommendation in enumerate(recommendations, 1):
+                # Reports saved before recommendations were structured hold plain strings
+                if isinstance(recommendation, dict):
+                    title = recommendation.get("title", "")
+                    guidance = recommendation.get("guidance") or DEFAULT_EXPLANATION
+                    heading = f"{title}, {recommendation.get('priority', 'medium')} priority"
+                else:
+                    title = recommendation
+                    guidance = DEFAULT_EXPLANATION
+                    heading = recommendation
+
                 # Extract the core recommendation text for matching
-                core_rec = re.sub(r'\s*\(\d+ instances detected\)', '', recommendation).strip().lower()
+                core_rec = re.sub(r'\s*\(\d+ instances detected\)', '', title).strip().lower()
                 
-                # Find the matching explanation from our data-driven dictionary
-                explanation = DEFAULT_EXPLANATION
+                # Find the matching explanation from our data-driven dictionary,
+                # falling back to the report's own guidance
+                explanation = guidance
                 for keyword, detail in RECOMMENDATION_EXPLANATIONS.items():
                     if keyword in core_rec:
                         explanation = detail
                         break
 
-                enhanced_message += f"\n\n{i}. {recommendation}. {explanation}"
+                enhanced_message += f"\n\n{i}. {heading}. {explanation}"
         
         # Add conclusion
-        enhanced_message += "\n\nI remain vigilant, protecting the digital realm one audit at a time. This concludes my voice report."
+        if self.narration == "aegong":
+            enhanced_message += "\n\nI remain vigilant, protecting the digital realm one audit at a time. This concludes my voice report."
+        else:
+            enhanced_message += "\n\nEnd of report."
         
         return enhanced_message
 
@@ -597,6 +630,10 @@ async def main():
     parser.add_argument("--speed", type=float, default=0.95, help="Speech speed (default: 0.95)")
     parser.add_argument("--language", default="en-US", help="Language code (default: en-US)")
     parser.add_argument("--timeout", type=int, default=30, help="Timeout in seconds for TTS operations (default: 30)")
+    parser.add_argument("--no-fallback", action="store_true",
+                       help="Fail instead of falling back to another provider")
+    parser.add_argument("--narration", choices=NARRATION_PROFILES, default="aegong",
+                       help="Narration profile: aegong, professional or executive (default: aegong)")
 
     args = parser.parse_args()
 
@@ -607,7 +644,8 @@ async def main():
     provider_kwargs = {
         "speed": args.speed,
         "language": args.language,
-        "timeout": args.timeout
+        "timeout": args.timeout,
+        "no_fallback": args.no_fallback
     }
 
     if args.voice:
@@ -615,8 +653,9 @@ async def main():
     if args.model:
         provider_kwargs["model"] = args.model
     
-    # Use Cerebras enhancement by default unless explicitly disabled
-    use_cerebras_enhancement = not args.no_cerebras_enhancement
+    # Use Cerebras enhancement by default unless explicitly disabled; it gives
+    # the text Aegong's personality, so other profiles go without
+    use_cerebras_enhancement = not args.no_cerebras_enhancement and args.narration == "aegong"
     
     # Add Cerebras-specific parameters if enhancement is enabled
     if use_cerebras_enhancement:
@@ -726,6 +765,7 @@ async def main():
         livekit_api_key=livekit_api_key,
         livekit_api_secret=livekit_api_secret,
         http_session=http_session,  # Pass the HTTP session to the agent
+        narration=args.narration,
         **provider_kwargs
     )
     
@@ -733,7 +773,7 @@ async def main():
         await agent.initialize()
         audio_path = await agent.generate_voice_report(args.report, args.output)
         print(f"Voice report generated: {audio_path}")
-        print(f"Provider used: {provider.value}")
+        print(f"Provider used: {agent.provider.value}")
         if use_cerebras_enhancement:
             print("AEGONG's judgmental personality enabled via Cerebras LLM")
         print("\nTo play the audio report:")
@@ -2,14 +2,29 @@ package main
 
 import (
 	keys "Agent_Auditor/key_manager"
+	"Agent_Auditor/pkg/aegong"
+	"Agent_Auditor/pkg/telemetry"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+)
+
+const (
+	defaultVoiceTimeout      = 60 // Seconds a provider gets to synthesize a report
+	voiceStartupGrace        = 30 * time.Second
+	maxSpokenRecommendations = 5
+
+	// OpenAI's voice and model, the defaults for the primary provider
+	defaultOpenAIVoice = "alloy"
+	defaultOpenAIModel = "gpt-4o-mini-tts"
 )
 
 // VoiceInferenceConfig holds configuration for the voice inference system
@@ -21,14 +36,60 @@ type VoiceInferenceConfig struct {
 	OutputDir    string `json:"output_dir"`
 	DefaultVoice string `json:"default_voice"`
 	DefaultModel string `json:"default_model"`
-	WSURL        string `json:"ws_url"` // WebSocket URL for LiveKit
+	WSURL        string `json:"ws_url"`  // WebSocket URL for LiveKit
+	Timeout      int    `json:"timeout"` // Seconds the primary provider gets (default 60)
+
+	// Providers tried in order when the primary fails or times out
+	Fallbacks []VoiceProviderConfig `json:"fallbacks"`
+
+	// Offline engines, for air-gapped deployments
+	LocalEngine string `json:"local_engine"` // piper, coqui, espeak or say (default: the first installed)
+	PiperPath   string `json:"piper_path"`   // Piper program (default: piper on the PATH)
+
+	// Reports spoken as soon as their audit finishes
+	Pregenerate VoicePregenerate `json:"pregenerate"`
+}
+
+// VoicePregenerate speaks risky reports right after their audit, so the first
+// request for their voice report does not wait on the provider
+type VoicePregenerate struct {
+	Enabled bool    `json:"enabled"`
+	MinRisk float64 `json:"min_risk"` // Reports whose overall risk exceeds this
+}
+
+// VoiceProviderConfig is a provider in the fallback chain
+type VoiceProviderConfig struct {
+	Provider string `json:"provider"`
+	Voice    string `json:"voice,omitempty"`
+	Model    string `json:"model,omitempty"`   // For piper, the path of the voice's .onnx model; for coqui, the model name
+	Timeout  int    `json:"timeout,omitempty"` // Seconds (default 60)
+}
+
+// VoiceAttempt is a provider that failed to generate a voice report
+type VoiceAttempt struct {
+	Provider string `json:"provider"`
+	Error    string `json:"error"`
+}
+
+// VoiceMetadata records how a voice report was generated
+type VoiceMetadata struct {
+	Provider    string         `json:"provider"`
+	Engine      string         `json:"engine,omitempty"` // Offline engine that spoke the report
+	Voice       string         `json:"voice,omitempty"`
+	Model       string         `json:"model,omitempty"`
+	Narration   string         `json:"narration,omitempty"` // Profile the report was narrated in
+	Fallback    bool           `json:"fallback"`            // A fallback provider was used
+	Attempts    []VoiceAttempt `json:"attempts,omitempty"`  // Providers that failed first
+	GeneratedAt time.Time      `json:"generated_at"`
 }
 
 // VoiceInferenceManager manages voice report generation
 type VoiceInferenceManager struct {
 	config     VoiceInferenceConfig
+	configPath string // Where configuration changes are saved
+	configLock sync.RWMutex
 	reportLock sync.Mutex
-	audioCache map[string]string // Maps report hash to audio file path
+	audioCache map[string]string // Maps report hash and narration to audio file path
 	keyManager *keys.KeyManager  // Secure key manager
 }
 
@@ -41,8 +102,8 @@ func NewVoiceInferenceManager(configPath string) (*VoiceInferenceManager, error)
 		KeyFile:      "default.key",
 		KeyPassEnv:   "AEGONG_KEY_PASS",
 		OutputDir:    "voice_reports",
-		DefaultVoice: "alloy",
-		DefaultModel: "gpt-4o-mini-tts",
+		DefaultVoice: defaultOpenAIVoice,
+		DefaultModel: defaultOpenAIModel,
 		// WSURL is not used directly as a command-line parameter
 		// It's set in the configuration for reference only
 		WSURL: "",
@@ -57,6 +118,8 @@ func NewVoiceInferenceManager(configPath string) (*VoiceInferenceManager, error)
 		}
 	}
 
+	config.clearOpenAIDefaults()
+
 	// Create output directory if it doesn't exist
 	if config.Enabled {
 		if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
@@ -67,83 +130,96 @@ func NewVoiceInferenceManager(configPath string) (*VoiceInferenceManager, error)
 	// Create voice inference manager
 	vim := &VoiceInferenceManager{
 		config:     config,
+		configPath: configPath,
 		audioCache: make(map[string]string),
 	}
 
-	// Initialize key manager if enabled
-	if config.Enabled {
-		// Check if we're in development mode (using .env file)
-		// In development mode, we'll use environment variables directly
-		cerebrasKey := os.Getenv("CEREBRAS_API_KEY")
-		cartesiaKey := os.Getenv("CARTESIA_API_KEY")
-		livekitKey := os.Getenv("LIVEKIT_API_KEY")
-		livekitSecret := os.Getenv("LIVEKIT_API_SECRET")
-
-		// If we have keys in environment variables, create an in-memory key manager
-		if cerebrasKey != "" || cartesiaKey != "" || livekitKey != "" || livekitSecret != "" {
-			log.Printf("Using API keys from environment variables (development mode)")
-
-			// Create a map of keys
-			keyMap := make(map[string]string)
-			if cerebrasKey != "" {
-				keyMap["cerebras"] = cerebrasKey
-			}
-			if cartesiaKey != "" {
-				keyMap["cartesia"] = cartesiaKey
-			}
-			if livekitKey != "" {
-				keyMap["LIVEKIT_API_KEY"] = livekitKey
-			}
-			if livekitSecret != "" {
-				keyMap["LIVEKIT_API_SECRET"] = livekitSecret
-			}
+	// Initialize key manager if enabled and a cloud provider needs it
+	if config.Enabled && config.needsAPIKeys() {
+		vim.loadKeys(config)
+	}
+
+	return vim, nil
+}
+
+// loadKeys initializes the key manager from environment variables or the
+// encrypted key file
+func (v *VoiceInferenceManager) loadKeys(config VoiceInferenceConfig) {
+	// Check if we're in development mode (using .env file)
+	// In development mode, we'll use environment variables directly
+	cerebrasKey := os.Getenv("CEREBRAS_API_KEY")
+	cartesiaKey := os.Getenv("CARTESIA_API_KEY")
+	livekitKey := os.Getenv("LIVEKIT_API_KEY")
+	livekitSecret := os.Getenv("LIVEKIT_API_SECRET")
+
+	// If we have keys in environment variables, create an in-memory key manager
+	if cerebrasKey != "" || cartesiaKey != "" || livekitKey != "" || livekitSecret != "" {
+		log.Printf("Using API keys from environment variables (development mode)")
+
+		// Create a map of keys
+		keyMap := make(map[string]string)
+		if cerebrasKey != "" {
+			keyMap["cerebras"] = cerebrasKey
+		}
+		if cartesiaKey != "" {
+			keyMap["cartesia"] = cartesiaKey
+		}
+		if livekitKey != "" {
+			keyMap["LIVEKIT_API_KEY"] = livekitKey
+		}
+		if livekitSecret != "" {
+			keyMap["LIVEKIT_API_SECRET"] = livekitSecret
+		}
 
-			// Create a temporary key file
-			tempKeyFile := "temp_keys.json"
-			if err := keys.CreateKeyFile(tempKeyFile, "dummy", keyMap); err != nil {
-				log.Printf("Warning: Failed to create temporary key file: %v", err)
+		// Create a temporary key file
+		tempKeyFile := "temp_keys.json"
+		if err := keys.CreateKeyFile(tempKeyFile, "dummy", keyMap); err != nil {
+			log.Printf("Warning: Failed to create temporary key file: %v", err)
+		} else {
+			// Use the temporary key file
+			v.keyManager = keys.NewKeyManager(tempKeyFile)
+			v.keyManager.Initialize("dummy")
+			if err := v.keyManager.LoadKeys(); err != nil {
+				log.Printf("Warning: Failed to load API keys from temporary file: %v", err)
 			} else {
-				// Use the temporary key file
-				vim.keyManager = keys.NewKeyManager(tempKeyFile)
-				vim.keyManager.Initialize("dummy")
-				if err := vim.keyManager.LoadKeys(); err != nil {
-					log.Printf("Warning: Failed to load API keys from temporary file: %v", err)
-				} else {
-					log.Printf("Successfully loaded API keys from environment variables")
-				}
-				// Clean up the temporary file
-				os.Remove(tempKeyFile)
+				log.Printf("Successfully loaded API keys from environment variables")
 			}
-		} else if config.KeyFile != "" {
-			// Try to use the encrypted key file (production mode)
-			vim.keyManager = keys.NewKeyManager(config.KeyFile)
-
-			// Try to initialize with passphrase from environment variable
-			if passphrase := os.Getenv(config.KeyPassEnv); passphrase != "" {
-				if err := vim.keyManager.Initialize(passphrase); err != nil {
-					log.Printf("Warning: Failed to initialize key manager: %v", err)
+			// Clean up the temporary file
+			os.Remove(tempKeyFile)
+		}
+	} else if config.KeyFile != "" {
+		// Try to use the encrypted key file (production mode)
+		v.keyManager = keys.NewKeyManager(config.KeyFile)
+
+		// Try to initialize with passphrase from environment variable
+		if passphrase := os.Getenv(config.KeyPassEnv); passphrase != "" {
+			if err := v.keyManager.Initialize(passphrase); err != nil {
+				log.Printf("Warning: Failed to initialize key manager: %v", err)
+			} else {
+				// Load keys
+				if err := v.keyManager.LoadKeys(); err != nil {
+					log.Printf("Warning: Failed to load API keys: %v", err)
 				} else {
-					// Load keys
-					if err := vim.keyManager.LoadKeys(); err != nil {
-						log.Printf("Warning: Failed to load API keys: %v", err)
-					} else {
-						log.Printf("Successfully loaded API keys from %s", config.KeyFile)
-					}
+					log.Printf("Successfully loaded API keys from %s", config.KeyFile)
 				}
-			} else {
-				log.Printf("Warning: Environment variable %s not set, API keys will not be available", config.KeyPassEnv)
 			}
 		} else {
-			log.Printf("Warning: No API keys available, voice inference will not work")
+			log.Printf("Warning: Environment variable %s not set, API keys will not be available", config.KeyPassEnv)
 		}
+	} else {
+		log.Printf("Warning: No API keys available, voice inference will not work")
 	}
+}
 
-	return vim, nil
+// voiceCacheKey identifies a report's narration in the audio cache
+func voiceCacheKey(reportHash, narration string) string {
+	return reportHash + "/" + narration
 }
 
-// GenerateVoiceReport generates a voice report for the given audit report
-func (v *VoiceInferenceManager) GenerateVoiceReport(reportPath string) (string, error) {
-	if !v.config.Enabled {
+// GenerateVoiceReport generates a voice report for the given audit report,
+// narrated in a profile
+func (v *VoiceInferenceManager) GenerateVoiceReport(reportPath, narration string) (string, error) {
+	if !v.IsEnabled() {
 		return "", fmt.Errorf("voice inference is disabled")
 	}
 
@@ -155,7 +231,7 @@ func (v *VoiceInferenceManager) GenerateVoiceReport(reportPath string) (string,
 	reportHash = reportHash[7:15] // Extract hash from "report_XXXXXXXX.json"
 
 	// Check if we already have an audio file for this report
-	if audioPath, exists := v.audioCache[reportHash]; exists {
+	if audioPath, exists := v.audioCache[voiceCacheKey(reportHash, narration)]; exists {
 		// Check if the file exists
 		if _, err := os.Stat(audioPath); err == nil {
 			return audioPath, nil
@@ -163,165 +239,439 @@ func (v *VoiceInferenceManager) GenerateVoiceReport(reportPath string) (string,
 	}
 
 	// Generate a new voice report
-	audioPath, err := v.runVoiceInference(reportPath)
+	audioPath, err := v.runVoiceInference(reportPath, narration)
 	if err != nil {
 		return "", fmt.Errorf("voice inference failed: %v", err)
 	}
 
 	// Cache the result
-	v.audioCache[reportHash] = audioPath
+	v.audioCache[voiceCacheKey(reportHash, narration)] = audioPath
 	return audioPath, nil
 }
 
-// runVoiceInference runs the Python voice inference script
-func (v *VoiceInferenceManager) runVoiceInference(reportPath string) (string, error) {
+// voiceChain is the primary provider followed by its fallbacks, in the order
+// they are tried
+func (v *VoiceInferenceManager) voiceChain() []VoiceProviderConfig {
+	return v.Config().chain()
+}
+
+// chain is the primary provider followed by its fallbacks
+func (c VoiceInferenceConfig) chain() []VoiceProviderConfig {
+	primary := VoiceProviderConfig{
+		Provider: c.Provider,
+		Voice:    c.DefaultVoice,
+		Model:    c.DefaultModel,
+		Timeout:  c.Timeout,
+	}
+	return append([]VoiceProviderConfig{primary}, c.Fallbacks...)
+}
+
+// clearOpenAIDefaults drops OpenAI's default voice and model from an offline
+// primary provider, since offline engines have their own voices
+func (c *VoiceInferenceConfig) clearOpenAIDefaults() {
+	if !isLocalProvider(c.Provider) {
+		return
+	}
+	if c.DefaultVoice == defaultOpenAIVoice {
+		c.DefaultVoice = ""
+	}
+	if c.DefaultModel == defaultOpenAIModel {
+		c.DefaultModel = ""
+	}
+}
+
+// needsAPIKeys reports whether any provider in the chain is a cloud provider
+func (c VoiceInferenceConfig) needsAPIKeys() bool {
+	for _, provider := range c.chain() {
+		if !isLocalProvider(provider.Provider) {
+			return true
+		}
+	}
+	return false
+}
+
+// runVoiceInference generates the voice report with the first provider in the
+// chain that succeeds, recording the one used in the report's metadata
+func (v *VoiceInferenceManager) runVoiceInference(reportPath, narration string) (string, error) {
+	// The script reads the report by path, so encrypted reports are decrypted to a private copy
+	reportPath, cleanup, err := plaintextPath(reportPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read report: %v", err)
+	}
+	defer cleanup()
+
+	// Reports in another profile are narrated again
+	reportPath, cleanupNarrated, err := narratedReportPath(reportPath, narration)
+	if err != nil {
+		return "", fmt.Errorf("failed to narrate report: %v", err)
+	}
+	defer cleanupNarrated()
+
+	ctx, span := telemetry.Start(context.Background(), "voice.generate", "aegong.report", filepath.Base(reportPath))
+	defer span.End()
+
+	chain := v.voiceChain()
+	var attempts []VoiceAttempt
+	for i, provider := range chain {
+		providerCtx, providerSpan := telemetry.Start(ctx, "voice.provider "+provider.Provider, "voice.fallback", i > 0)
+		audioPath, metadata, err := v.runProvider(providerCtx, provider, reportPath, narration)
+		providerSpan.SetError(err)
+		if metadata.Engine != "" {
+			providerSpan.SetAttribute("voice.engine", metadata.Engine)
+		}
+		providerSpan.End()
+		if err != nil {
+			log.Printf("Warning: Voice provider %s failed: %v", provider.Provider, err)
+			attempts = append(attempts, VoiceAttempt{Provider: provider.Provider, Error: err.Error()})
+			if i+1 < len(chain) {
+				log.Printf("Falling back to voice provider %s", chain[i+1].Provider)
+			}
+			continue
+		}
+
+		span.SetAttribute("voice.provider", provider.Provider)
+		metadata.Voice = provider.Voice
+		metadata.Model = provider.Model
+		metadata.Narration = narration
+		metadata.Fallback = i > 0
+		metadata.Attempts = attempts
+		metadata.GeneratedAt = time.Now()
+		data, _ := json.MarshalIndent(metadata, "", "  ")
+		if err := os.WriteFile(voiceMetadataPath(audioPath), data, 0644); err != nil {
+			log.Printf("Warning: Failed to write voice metadata: %v", err)
+		}
+		return audioPath, nil
+	}
+
+	var failures []string
+	for _, attempt := range attempts {
+		failures = append(failures, fmt.Sprintf("%s: %s", attempt.Provider, attempt.Error))
+	}
+	err = fmt.Errorf("every voice provider failed (%s)", strings.Join(failures, "; "))
+	span.SetError(err)
+	return "", err
+}
+
+// runProvider generates the voice report with one provider, returning the
+// audio path and the provider and engine that produced it
+func (v *VoiceInferenceManager) runProvider(ctx context.Context, provider VoiceProviderConfig, reportPath, narration string) (string, VoiceMetadata, error) {
+	timeout := provider.Timeout
+	if timeout <= 0 {
+		timeout = defaultVoiceTimeout
+	}
+	// The script enforces the timeout on synthesis; this also covers its startup
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second+voiceStartupGrace)
+	defer cancel()
+
+	if isLocalProvider(provider.Provider) {
+		audioPath, engine, err := v.runLocal(ctx, provider, reportPath, narration)
+		return audioPath, VoiceMetadata{Provider: provider.Provider, Engine: engine}, err
+	}
+
 	// Check if key manager is initialized
-	if v.keyManager == nil {
-		return "", fmt.Errorf("key manager not initialized, cannot access API keys")
+	if v.apiKeys() == nil {
+		return "", VoiceMetadata{}, fmt.Errorf("key manager not initialized, cannot access API keys")
 	}
 
 	// Base command with common arguments
 	args := []string{
 		"voice_inference.py",
 		"--report", reportPath,
-		"--output", v.config.OutputDir,
-		"--provider", v.config.Provider,
+		"--output", v.Config().OutputDir,
+		"--provider", provider.Provider,
+		"--narration", narration,
 	}
 
 	// Add voice if specified
-	if v.config.DefaultVoice != "" {
-		args = append(args, "--voice", v.config.DefaultVoice)
+	if provider.Voice != "" {
+		args = append(args, "--voice", provider.Voice)
 	}
 
 	// Add model if specified
-	if v.config.DefaultModel != "" {
-		args = append(args, "--model", v.config.DefaultModel)
+	if provider.Model != "" {
+		args = append(args, "--model", provider.Model)
 	}
 
 	// Add timeout parameter to prevent hanging
-	args = append(args, "--timeout", "60")
+	args = append(args, "--timeout", strconv.Itoa(timeout))
+
+	// The configured chain decides the fallbacks, not the script
+	args = append(args, "--no-fallback")
 
 	// Note: WebSocket URL is handled by the LiveKit environment variables
 	// and doesn't need to be passed as a command-line argument
 
 	// Add provider-specific API keys
-	switch v.config.Provider {
+	keyArgs, err := v.providerKeyArgs(provider.Provider)
+	if err != nil {
+		return "", VoiceMetadata{}, err
+	}
+
+	// Prepare the command
+	cmd := exec.CommandContext(ctx, "python3", append(args, keyArgs...)...)
+
+	// Log the command being executed, without the keys
+	log.Printf("Running voice inference command: python3 %s", strings.Join(args, " "))
+
+	// Run the command
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", VoiceMetadata{}, fmt.Errorf("voice inference timed out after %d seconds", timeout)
+	}
+	if err != nil {
+		log.Printf("Voice inference script failed with error: %v", err)
+		log.Printf("Script output: %s", string(output))
+		return "", VoiceMetadata{}, fmt.Errorf("voice inference script failed: %v, output: %s", err, output)
+	}
+
+	// Log the output
+	log.Printf("Voice inference script output: %s", string(output))
+
+	audioPath, used := parseVoiceOutput(string(output))
+	if audioPath == "" {
+		return "", VoiceMetadata{}, fmt.Errorf("failed to parse voice inference output")
+	}
+	if used == "" {
+		used = provider.Provider
+	}
+	return audioPath, VoiceMetadata{Provider: used}, nil
+}
+
+// parseVoiceOutput finds the audio path and the provider used in the
+// inference script's output
+func parseVoiceOutput(output string) (string, string) {
+	var audioPath, provider string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if value, ok := strings.CutPrefix(line, "Voice report generated: "); ok {
+			audioPath = strings.TrimSpace(value)
+		} else if value, ok := strings.CutPrefix(line, "Provider used: "); ok {
+			provider = strings.TrimSpace(value)
+		}
+	}
+	return audioPath, provider
+}
+
+// providerKeyArgs returns the script arguments carrying a provider's API keys
+func (v *VoiceInferenceManager) providerKeyArgs(provider string) ([]string, error) {
+	keyManager := v.apiKeys()
+	var args []string
+	switch provider {
 	case "openai":
 		// Get OpenAI API key
-		apiKey, err := v.keyManager.GetKey("openai")
+		apiKey, err := keyManager.GetKey("openai")
 		if err != nil {
-			return "", fmt.Errorf("failed to get OpenAI API key: %v", err)
+			return nil, fmt.Errorf("failed to get OpenAI API key: %v", err)
 		}
 		args = append(args, "--openai-api-key", apiKey)
 
 	case "cerebras":
 		// Get Cerebras API key
-		cerebrasKey, err := v.keyManager.GetKey("cerebras")
+		cerebrasKey, err := keyManager.GetKey("cerebras")
 		if err != nil {
-			return "", fmt.Errorf("failed to get Cerebras API key: %v", err)
+			return nil, fmt.Errorf("failed to get Cerebras API key: %v", err)
 		}
 		args = append(args, "--cerebras-api-key", cerebrasKey)
 
 		// Get Google credentials path (for Cerebras hybrid approach)
-		googleCreds, err := v.keyManager.GetKey("google_credentials_path")
+		googleCreds, err := keyManager.GetKey("google_credentials_path")
 		if err != nil {
-			return "", fmt.Errorf("failed to get Google credentials path: %v", err)
+			return nil, fmt.Errorf("failed to get Google credentials path: %v", err)
 		}
 		args = append(args, "--google-credentials", googleCreds)
 
 	case "google":
 		// Get Google credentials path
-		googleCreds, err := v.keyManager.GetKey("google_credentials_path")
+		googleCreds, err := keyManager.GetKey("google_credentials_path")
 		if err != nil {
-			return "", fmt.Errorf("failed to get Google credentials path: %v", err)
+			return nil, fmt.Errorf("failed to get Google credentials path: %v", err)
 		}
 		args = append(args, "--google-credentials", googleCreds)
 
 	case "azure":
 		// Get Azure API key
-		azureKey, err := v.keyManager.GetKey("azure")
+		azureKey, err := keyManager.GetKey("azure")
 		if err != nil {
-			return "", fmt.Errorf("failed to get Azure API key: %v", err)
+			return nil, fmt.Errorf("failed to get Azure API key: %v", err)
 		}
 		args = append(args, "--azure-api-key", azureKey)
 
 		// Get Azure region if available
-		if azureRegion, err := v.keyManager.GetKey("azure_region"); err == nil {
+		if azureRegion, err := keyManager.GetKey("azure_region"); err == nil {
 			args = append(args, "--azure-region", azureRegion)
 		}
 
 	case "cartesia":
 		// Get Cartesia API key
-		cartesiaKey, err := v.keyManager.GetKey("cartesia")
+		cartesiaKey, err := keyManager.GetKey("cartesia")
 		if err != nil {
-			return "", fmt.Errorf("failed to get Cartesia API key: %v", err)
+			return nil, fmt.Errorf("failed to get Cartesia API key: %v", err)
 		}
 		args = append(args, "--cartesia-api-key", cartesiaKey)
 
 	case "livekit":
 		// Get LiveKit API key
-		livekitKey, err := v.keyManager.GetKey("LIVEKIT_API_KEY")
+		livekitKey, err := keyManager.GetKey("LIVEKIT_API_KEY")
 		if err != nil {
-			return "", fmt.Errorf("failed to get LiveKit API key: %v", err)
+			return nil, fmt.Errorf("failed to get LiveKit API key: %v", err)
 		}
 		args = append(args, "--livekit-api-key", livekitKey)
 
 		// Get LiveKit API secret
-		livekitSecret, err := v.keyManager.GetKey("LIVEKIT_API_SECRET")
+		livekitSecret, err := keyManager.GetKey("LIVEKIT_API_SECRET")
 		if err != nil {
-			return "", fmt.Errorf("failed to get LiveKit API secret: %v", err)
+			return nil, fmt.Errorf("failed to get LiveKit API secret: %v", err)
 		}
 		args = append(args, "--livekit-api-secret", livekitSecret)
 
 	default:
-		return "", fmt.Errorf("unsupported TTS provider: %s", v.config.Provider)
+		return nil, fmt.Errorf("unsupported TTS provider: %s", provider)
 	}
+	return args, nil
+}
 
-	// Prepare the command
-	cmd := exec.Command("python3", args...)
-
-	// Log the command being executed
-	log.Printf("Running voice inference command: python3 %s", strings.Join(args, " "))
+// voiceMetadataPath is where the metadata of a voice report is kept
+func voiceMetadataPath(audioPath string) string {
+	return strings.TrimSuffix(audioPath, filepath.Ext(audioPath)) + ".json"
+}
 
-	// Run the command
-	output, err := cmd.CombinedOutput()
+// Metadata returns how a voice report was generated, or nil if it was not recorded
+func (v *VoiceInferenceManager) Metadata(audioPath string) *VoiceMetadata {
+	data, err := os.ReadFile(voiceMetadataPath(audioPath))
 	if err != nil {
-		log.Printf("Voice inference script failed with error: %v", err)
-		log.Printf("Script output: %s", string(output))
-		return "", fmt.Errorf("voice inference script failed: %v, output: %s", err, output)
+		return nil
 	}
+	var metadata VoiceMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil
+	}
+	return &metadata
+}
 
-	// Log the output
-	log.Printf("Voice inference script output: %s", string(output))
+// IsEnabled returns whether voice inference is enabled
+func (v *VoiceInferenceManager) IsEnabled() bool {
+	return v.Config().Enabled
+}
 
-	// Parse the output to get the audio file path
-	outputStr := string(output)
-	var audioPath string
-	_, err = fmt.Sscanf(outputStr, "Voice report generated: %s", &audioPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse voice inference output: %v", err)
+// Config returns the current voice configuration
+func (v *VoiceInferenceManager) Config() VoiceInferenceConfig {
+	v.configLock.RLock()
+	defer v.configLock.RUnlock()
+	return v.config
+}
+
+// apiKeys returns the key manager, or nil if no keys were loaded
+func (v *VoiceInferenceManager) apiKeys() *keys.KeyManager {
+	v.configLock.RLock()
+	defer v.configLock.RUnlock()
+	return v.keyManager
+}
+
+// API keys each TTS provider needs from the key manager
+var voiceProviderKeys = map[string][]string{
+	"openai":   {"openai"},
+	"cerebras": {"cerebras", "google_credentials_path"},
+	"google":   {"google_credentials_path"},
+	"azure":    {"azure"},
+	"cartesia": {"cartesia"},
+	"livekit":  {"LIVEKIT_API_KEY", "LIVEKIT_API_SECRET"},
+}
+
+// VoiceHealth reports whether voice reports can currently be generated
+type VoiceHealth struct {
+	Enabled  bool   `json:"enabled"`
+	Provider string `json:"provider"`
+	Healthy  bool   `json:"healthy"`
+	Reason   string `json:"reason,omitempty"`   // Why the primary provider is unavailable
+	Fallback string `json:"fallback,omitempty"` // Provider used instead of an unavailable primary
+}
+
+// Health checks the providers, their API keys and the inference script
+// without generating anything. Voice reports are healthy while any provider
+// in the chain is available.
+func (v *VoiceInferenceManager) Health() VoiceHealth {
+	config := v.Config()
+	health := VoiceHealth{Enabled: config.Enabled, Provider: config.Provider}
+	if !config.Enabled {
+		return health
 	}
 
-	return audioPath, nil
+	for i, provider := range v.voiceChain() {
+		reason := v.providerProblem(provider)
+		if i == 0 {
+			health.Reason = reason
+		}
+		if reason == "" {
+			health.Healthy = true
+			if i > 0 {
+				health.Fallback = provider.Provider
+			}
+			break
+		}
+	}
+	return health
 }
 
-// IsEnabled returns whether voice inference is enabled
-func (v *VoiceInferenceManager) IsEnabled() bool {
-	return v.config.Enabled
+// providerProblem returns why a provider cannot be used, or "" if it can
+func (v *VoiceInferenceManager) providerProblem(provider VoiceProviderConfig) string {
+	if isLocalProvider(provider.Provider) {
+		return v.localProblem(provider)
+	}
+	keyManager := v.apiKeys()
+
+	required, ok := voiceProviderKeys[provider.Provider]
+	switch {
+	case !ok:
+		return fmt.Sprintf("unsupported TTS provider: %s", provider.Provider)
+	case keyManager == nil:
+		return "key manager not initialized, cannot access API keys"
+	}
+	for _, name := range required {
+		if _, err := keyManager.GetKey(name); err != nil {
+			return fmt.Sprintf("API key %s unavailable: %v", name, err)
+		}
+	}
+	if _, err := exec.LookPath("python3"); err != nil {
+		return "python3 is not installed"
+	}
+	if _, err := os.Stat("voice_inference.py"); err != nil {
+		return "voice_inference.py not found"
+	}
+	return ""
 }
 
-// GetAudioPathForReport returns the cached audio path for a report hash, if available
-func (v *VoiceInferenceManager) GetAudioPathForReport(reportHash string) (string, bool) {
+// forgetReport drops the cached audio paths of a deleted report
+func (v *VoiceInferenceManager) forgetReport(reportHash string) {
 	v.reportLock.Lock()
 	defer v.reportLock.Unlock()
 
-	path, exists := v.audioCache[reportHash]
+	for key := range v.audioCache {
+		if strings.HasPrefix(key, voiceCacheKey(reportHash, "")) {
+			delete(v.audioCache, key)
+		}
+	}
+}
+
+// GetAudioPathForReport returns the cached audio path for a report hash and
+// narration, if available
+func (v *VoiceInferenceManager) GetAudioPathForReport(reportHash, narration string) (string, bool) {
+	v.reportLock.Lock()
+	defer v.reportLock.Unlock()
+
+	path, exists := v.audioCache[voiceCacheKey(reportHash, narration)]
 	return path, exists
 }
 
+// ShouldPregenerate reports whether a report is spoken as soon as its audit
+// finishes rather than on first request
+func (v *VoiceInferenceManager) ShouldPregenerate(report *aegong.AuditReport) bool {
+	config := v.Config()
+	return config.Enabled && config.Pregenerate.Enabled && report.OverallRisk > config.Pregenerate.MinRisk
+}
+
 // GenerateVoiceReportAsync generates a voice report asynchronously
-func (v *VoiceInferenceManager) GenerateVoiceReportAsync(reportPath string, callback func(string, error)) {
-	if !v.config.Enabled {
+func (v *VoiceInferenceManager) GenerateVoiceReportAsync(reportPath, narration string, callback func(string, error)) {
+	if !v.IsEnabled() {
 		if callback != nil {
 			callback("", fmt.Errorf("voice inference is disabled"))
 		}
@@ -329,7 +679,7 @@ func (v *VoiceInferenceManager) GenerateVoiceReportAsync(reportPath string, call
 	}
 
 	go func() {
-		audioPath, err := v.GenerateVoiceReport(reportPath)
+		audioPath, err := v.GenerateVoiceReport(reportPath, narration)
 		if callback != nil {
 			callback(audioPath, err)
 		}
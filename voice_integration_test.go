@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// TestParseVoiceOutput tests finding the audio path and provider among the script's logs
+func TestParseVoiceOutput(t *testing.T) {
+	output := "INFO:voice_inference:Using TTS provider: cartesia\n" +
+		"Voice report generated: voice_reports/aegong_report_abcdef01.wav\n" +
+		"Provider used: openai\n" +
+		"AEGONG's judgmental personality enabled via Cerebras LLM\n"
+	audioPath, provider := parseVoiceOutput(output)
+	if audioPath != "voice_reports/aegong_report_abcdef01.wav" || provider != "openai" {
+		t.Errorf("Should parse the audio path and provider, got %q and %q", audioPath, provider)
+	}
+	if audioPath, _ := parseVoiceOutput("Traceback (most recent call last):\n"); audioPath != "" {
+		t.Errorf("Output without an audio path should parse as empty, got %q", audioPath)
+	}
+}
+
+// TestVoiceFallback tests falling back to the offline engine when the primary provider fails
+func TestVoiceFallback(t *testing.T) {
+	withTestUpload(t)
+	os.MkdirAll("reports", 0755)
+	var report aegong.AuditReport
+	json.Unmarshal(testReportJSON(t), &report)
+	report.Recommendations = []aegong.Recommendation{{Title: "Restrict file system access"}}
+	data, _ := json.Marshal(report)
+	reportPath := filepath.Join("reports", "report_abcdef01.json")
+	writeStored(reportPath, data)
+
+	// A stand-in for piper that writes what it was asked to say
+	dir := t.TempDir()
+	piper := filepath.Join(dir, "piper")
+	os.WriteFile(piper, []byte("#!/bin/sh\nwhile [ $# -gt 0 ]; do [ \"$1\" = --output_file ] && out=$2; shift; done\ncat > \"$out\"\n"), 0755)
+	model := filepath.Join(dir, "en_US-lessac-medium.onnx")
+	os.WriteFile(model, []byte("model"), 0644)
+
+	manager := &VoiceInferenceManager{
+		config: VoiceInferenceConfig{
+			Enabled:   true,
+			Provider:  "openai",
+			OutputDir: "voice_reports",
+			Fallbacks: []VoiceProviderConfig{{Provider: "piper", Model: model}},
+			PiperPath: piper,
+		},
+		audioCache: make(map[string]string),
+	}
+	os.MkdirAll("voice_reports", 0755)
+
+	health := manager.Health()
+	if !health.Healthy || health.Fallback != "piper" || !strings.Contains(health.Reason, "key manager") {
+		t.Fatalf("Voice should stay healthy through the fallback, got %+v", health)
+	}
+
+	audioPath, err := manager.GenerateVoiceReport(reportPath, NarrationAegong)
+	if err != nil {
+		t.Fatalf("Piper should generate the report after OpenAI fails: %v", err)
+	}
+	if spoken, _ := os.ReadFile(audioPath); !strings.Contains(string(spoken), "The risk level is HIGH") || !strings.Contains(string(spoken), "Restrict file system access.") {
+		t.Errorf("Piper should be given the report to read, got %q", spoken)
+	}
+	metadata := manager.Metadata(audioPath)
+	if metadata == nil || metadata.Provider != "piper" || !metadata.Fallback || len(metadata.Attempts) != 1 || metadata.Attempts[0].Provider != "openai" {
+		t.Fatalf("Metadata should record the fallback, got %+v", metadata)
+	}
+
+	manager.config.Fallbacks = nil
+	manager.audioCache = make(map[string]string)
+	if _, err := manager.GenerateVoiceReport(reportPath, NarrationAegong); err == nil || !strings.Contains(err.Error(), "openai: key manager not initialized") {
+		t.Errorf("Without fallbacks the primary's failure should be reported, got %v", err)
+	}
+}
+
+// TestLocalVoice tests the offline provider picking an installed engine
+func TestLocalVoice(t *testing.T) {
+	withTestUpload(t)
+	os.MkdirAll("reports", 0755)
+	reportPath := filepath.Join("reports", "report_abcdef01.json")
+	writeStored(reportPath, testReportJSON(t))
+
+	// A stand-in for espeak-ng, the only engine on the PATH
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	os.WriteFile(filepath.Join(dir, "espeak-ng"), []byte("#!/bin/sh\n"+
+		"while [ $# -gt 0 ]; do case $1 in -w) out=$2;; -v) voice=$2;; esac; shift; done\n"+
+		"{ echo \"voice=$voice\"; while read -r line || [ -n \"$line\" ]; do echo \"$line\"; done; } > \"$out\"\n"), 0755)
+
+	os.WriteFile("voice_config.json", []byte(`{"enabled": true, "provider": "local", "default_voice": "en-us"}`), 0644)
+	manager, err := NewVoiceInferenceManager("voice_config.json")
+	if err != nil {
+		t.Fatalf("Failed to create voice manager: %v", err)
+	}
+	if manager.keyManager != nil || manager.config.DefaultModel != "" {
+		t.Errorf("Local voices should need no API keys or OpenAI model, got %+v", manager.config)
+	}
+	if health := manager.Health(); !health.Healthy {
+		t.Fatalf("Voice should be healthy with espeak installed, got %+v", health)
+	}
+
+	audioPath, err := manager.GenerateVoiceReport(reportPath, NarrationAegong)
+	if err != nil {
+		t.Fatalf("espeak should generate the report: %v", err)
+	}
+	if spoken, _ := os.ReadFile(audioPath); !strings.HasPrefix(string(spoken), "voice=en-us\n") || !strings.Contains(string(spoken), "The risk level is HIGH") {
+		t.Errorf("espeak should read the report in the configured voice, got %q", spoken)
+	}
+	if metadata := manager.Metadata(audioPath); metadata == nil || metadata.Provider != "local" || metadata.Engine != "espeak" || metadata.Fallback {
+		t.Errorf("Metadata should record the engine, got %+v", metadata)
+	}
+
+	manager.config.LocalEngine = "coqui"
+	if health := manager.Health(); health.Healthy || health.Reason != "coqui is not installed" {
+		t.Errorf("A configured engine that is missing should be reported, got %+v", health)
+	}
+	manager.config.LocalEngine = "festival"
+	if health := manager.Health(); health.Healthy || !strings.Contains(health.Reason, "unknown local TTS engine") {
+		t.Errorf("Unknown engines should be reported, got %+v", health)
+	}
+}
+
+// TestVoicePregenerate tests which reports are spoken as soon as their audit finishes
+func TestVoicePregenerate(t *testing.T) {
+	manager := &VoiceInferenceManager{config: VoiceInferenceConfig{Enabled: true, Provider: "local"}}
+	risky := &aegong.AuditReport{OverallRisk: 0.8}
+	safe := &aegong.AuditReport{OverallRisk: 0.3}
+	if manager.ShouldPregenerate(risky) {
+		t.Error("Reports should be spoken on request unless pre-generation is enabled")
+	}
+
+	manager.config.Pregenerate = VoicePregenerate{Enabled: true, MinRisk: 0.5}
+	if !manager.ShouldPregenerate(risky) || manager.ShouldPregenerate(safe) {
+		t.Error("Only reports whose risk exceeds the threshold should be pre-generated")
+	}
+	manager.config.Enabled = false
+	if manager.ShouldPregenerate(risky) {
+		t.Error("Nothing should be pre-generated while voice is disabled")
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"Agent_Auditor/pkg/aegong"
+)
+
+// Offline TTS for air-gapped deployments. The "local" provider speaks with
+// the engine named by local_engine, or the first one installed; "piper"
+// always uses Piper. Local engines read Aegong's message and the top
+// recommendations, without the Cerebras enhancement the script applies.
+
+// Offline providers
+const (
+	voiceProviderLocal = "local"
+	voiceProviderPiper = "piper"
+)
+
+// localEngine is an offline TTS program
+type localEngine struct {
+	name       string
+	programs   []string // Looked up on the PATH in order
+	needsModel bool     // Only speaks with a voice model file
+	stdin      bool     // Reads the text from stdin
+	// args builds the command line that speaks text into the WAV file out
+	args func(model, voice, out, text string) []string
+}
+
+// Engines in the order "local" picks them when none is configured
+var localEngines = []*localEngine{
+	{
+		name:       "piper",
+		programs:   []string{"piper"},
+		needsModel: true,
+		stdin:      true,
+		args: func(model, voice, out, text string) []string {
+			return []string{"--model", model, "--output_file", out}
+		},
+	},
+	{
+		name:     "coqui",
+		programs: []string{"tts"},
+		args: func(model, voice, out, text string) []string {
+			args := []string{"--text", text, "--out_path", out}
+			if model != "" {
+				args = append(args, "--model_name", model)
+			}
+			if voice != "" {
+				args = append(args, "--speaker_idx", voice)
+			}
+			return args
+		},
+	},
+	{
+		name:     "espeak",
+		programs: []string{"espeak-ng", "espeak"},
+		stdin:    true,
+		args: func(model, voice, out, text string) []string {
+			args := []string{"--stdin", "-w", out}
+			if voice != "" {
+				args = append(args, "-v", voice)
+			}
+			return args
+		},
+	},
+	{
+		name:     "say", // macOS
+		programs: []string{"say"},
+		stdin:    true,
+		args: func(model, voice, out, text string) []string {
+			args := []string{"-o", out, "--file-format=WAVE", "--data-format=LEI16@22050", "-f", "-"}
+			if voice != "" {
+				args = append(args, "-v", voice)
+			}
+			return args
+		},
+	},
+}
+
+// isLocalProvider reports whether a provider runs without network access
+func isLocalProvider(provider string) bool {
+	return provider == voiceProviderLocal || provider == voiceProviderPiper
+}
+
+// localProgram finds an engine's program
+func (v *VoiceInferenceManager) localProgram(engine *localEngine) (string, error) {
+	if piperPath := v.Config().PiperPath; engine.name == "piper" && piperPath != "" {
+		return piperPath, nil
+	}
+	for _, program := range engine.programs {
+		if path, err := exec.LookPath(program); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not installed", engine.name)
+}
+
+// selectLocalEngine picks the engine for an offline provider and finds its program
+func (v *VoiceInferenceManager) selectLocalEngine(provider VoiceProviderConfig) (*localEngine, string, error) {
+	name := v.Config().LocalEngine
+	if provider.Provider == voiceProviderPiper {
+		name = "piper"
+	}
+
+	if name != "" {
+		for _, engine := range localEngines {
+			if engine.name != name {
+				continue
+			}
+			if engine.needsModel && provider.Model == "" {
+				return nil, "", fmt.Errorf("%s needs the path of a voice model", name)
+			}
+			program, err := v.localProgram(engine)
+			return engine, program, err
+		}
+		return nil, "", fmt.Errorf("unknown local TTS engine: %s", name)
+	}
+
+	var names []string
+	for _, engine := range localEngines {
+		names = append(names, engine.name)
+		if engine.needsModel && provider.Model == "" {
+			continue
+		}
+		if program, err := v.localProgram(engine); err == nil {
+			return engine, program, nil
+		}
+	}
+	return nil, "", fmt.Errorf("no offline TTS engine is installed (%s)", strings.Join(names, ", "))
+}
+
+// localProblem returns why an offline provider cannot be used, or "" if it can
+func (v *VoiceInferenceManager) localProblem(provider VoiceProviderConfig) string {
+	engine, _, err := v.selectLocalEngine(provider)
+	if err != nil {
+		return err.Error()
+	}
+	if engine.needsModel {
+		if _, err := os.Stat(provider.Model); err != nil {
+			return fmt.Sprintf("%s voice model %q not found", engine.name, provider.Model)
+		}
+	}
+	return ""
+}
+
+// runLocal speaks the report with an offline engine, returning the audio
+// path and the engine used
+func (v *VoiceInferenceManager) runLocal(ctx context.Context, provider VoiceProviderConfig, reportPath, narration string) (string, string, error) {
+	engine, program, err := v.selectLocalEngine(provider)
+	if err != nil {
+		return "", "", err
+	}
+
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read report: %v", err)
+	}
+	report, err := parseReport(data)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse report: %v", err)
+	}
+
+	text := voiceScript(report)
+	audioPath := filepath.Join(v.Config().OutputDir, voiceAudioName(report.AgentHash[:8], narration))
+	cmd := exec.CommandContext(ctx, program, engine.args(provider.Model, provider.Voice, audioPath, text)...)
+	if engine.stdin {
+		cmd.Stdin = strings.NewReader(text)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", "", fmt.Errorf("%s timed out", engine.name)
+		}
+		return "", "", fmt.Errorf("%s failed: %v, output: %s", engine.name, err, output)
+	}
+	if info, err := os.Stat(audioPath); err != nil || info.Size() == 0 {
+		return "", "", fmt.Errorf("%s did not write %s", engine.name, audioPath)
+	}
+	return audioPath, engine.name, nil
+}
+
+// voiceScript is what offline engines read aloud: Aegong's message and the
+// most pressing recommendations
+func voiceScript(report *aegong.AuditReport) string {
+	var script strings.Builder
+	if report.AegongMessage != "" {
+		script.WriteString(report.AegongMessage)
+	} else {
+		fmt.Fprintf(&script, "Aegong has audited %s. The risk level is %s.", report.AgentName, report.RiskLevel)
+	}
+	introduction := "Aegong recommends the following."
+	if reportNarration(report) != NarrationAegong {
+		introduction = "Recommendations:"
+	}
+	for i, recommendation := range report.Recommendations {
+		if i == maxSpokenRecommendations {
+			break
+		}
+		if i == 0 {
+			script.WriteString("\n\n" + introduction)
+		}
+		fmt.Fprintf(&script, "\n%s.", strings.TrimSuffix(recommendation.Title, "."))
+	}
+	return script.String()
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket protocol
+//
+// Clients send commands as JSON objects {"type", "id", "data"}. The optional
+// id is echoed on every direct reply so clients can match replies to commands.
+//
+//	ping                                  -> pong
+//	subscribe    {"topic": "audits"}      -> ack
+//	unsubscribe  {"topic": "audit:<id>"}  -> ack
+//	start_audit  {"filename": "<upload>"} -> ack {"audit_id"}, then audit events
+//	             optional "options": {"vectors", "shields", "skip_shields"};
+//	             refused unless the client is in the upload allowlist
+//	cancel       {"audit_id": "<id>"}     -> ack, then audit_cancelled
+//
+// Invalid commands get an "error" reply whose data carries a machine readable
+// code. Audit events (audit_started, audit_phase, threat_found,
+// audit_completed, audit_failed, audit_cancelled) are pushed to clients
+// subscribed to "audits" or to the audit's own "audit:<id>" topic; the client
+// that starts an audit is subscribed to it automatically. upload_received and
+// voice_ready only go to "audits". Events come from the event bus, so audits
+// run as jobs or over HTTP appear under their job or audit id, and
+// /api/events streams the same events as server-sent events.
+
+// Message types sent by the client
+const (
+	wsTypePing        = "ping"
+	wsTypeSubscribe   = "subscribe"
+	wsTypeUnsubscribe = "unsubscribe"
+	wsTypeStartAudit  = "start_audit"
+	wsTypeCancel      = "cancel"
+)
+
+// Message types sent by the server
+const (
+	wsTypeAegongMessage  = "aegong_message"
+	wsTypePong           = "pong"
+	wsTypeAck            = "ack"
+	wsTypeError          = "error"
+	wsTypeAuditStarted   = "audit_started"
+	wsTypeAuditCompleted = "audit_completed"
+	wsTypeAuditFailed    = "audit_failed"
+	wsTypeAuditCancelled = "audit_cancelled"
+	wsTypeAuditPhase     = "audit_phase"
+	wsTypeThreatFound    = "threat_found"
+	wsTypeUploadReceived = "upload_received"
+	wsTypeVoiceReady     = "voice_ready"
+)
+
+// Bus events forwarded to clients, and the message type they arrive as
+var wsEventTypes = map[string]string{
+	eventUploadReceived: wsTypeUploadReceived,
+	eventAuditStarted:   wsTypeAuditStarted,
+	eventAuditPhase:     wsTypeAuditPhase,
+	eventThreatFound:    wsTypeThreatFound,
+	eventAuditCompleted: wsTypeAuditCompleted,
+	eventAuditFailed:    wsTypeAuditFailed,
+	eventAuditCancelled: wsTypeAuditCancelled,
+	eventVoiceReady:     wsTypeVoiceReady,
+}
+
+// Error codes carried in "error" replies
+const (
+	wsErrInvalidJSON = "invalid_json"
+	wsErrUnknownType = "unknown_type"
+	wsErrInvalidData = "invalid_data"
+	wsErrNotFound    = "not_found"
+	wsErrQuota       = "quota_exceeded"
+	wsErrForbidden   = "forbidden"
+)
+
+// Topic that receives events for every audit
+const wsTopicAudits = "audits"
+
+const (
+	wsWriteTimeout   = 10 * time.Second
+	wsMaxMessageSize = 64 << 10
+)
+
+// WebSocketMessage is a message sent from the server to a client
+type WebSocketMessage struct {
+	Type    string      `json:"type"`
+	ID      string      `json:"id,omitempty"`
+	Data    interface{} `json:"data"`
+	Message string      `json:"message"`
+}
+
+// wsCommand is a message sent from a client to the server
+type wsCommand struct {
+	Type string          `json:"type"`
+	ID   string          `json:"id,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type wsTopicData struct {
+	Topic string `json:"topic"`
+}
+
+type wsStartAuditData struct {
+	Filename string            `json:"filename"`
+	Options  aegong.AuditScope `json:"options"`
+}
+
+type wsCancelData struct {
+	AuditID string `json:"audit_id"`
+}
+
+// wsError is a protocol error reported back to the client
+type wsError struct {
+	Code    string
+	Message string
+}
+
+func (e *wsError) Error() string {
+	return e.Message
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: originAllowed,
+}
+
+// eventSubscriber receives events from the hub; WebSocket and server-sent
+// event clients are both fed from the event bus
+type eventSubscriber interface {
+	subscribed(topics ...string) bool
+	send(msg WebSocketMessage) error
+}
+
+// wsClient is a single WebSocket connection
+type wsClient struct {
+	conn     *websocket.Conn
+	ctx      context.Context // Cancelled when the connection closes
+	writeMu  sync.Mutex
+	subMu    sync.Mutex
+	topics   map[string]bool
+	audits   map[string]bool // Audits started by this client
+	auditsMu sync.Mutex
+	tenant   string // Whose quotas the client's audits count against
+	addr     netip.Addr
+	canAudit bool // Whether the client is in the upload allowlist
+}
+
+func (c *wsClient) send(msg WebSocketMessage) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return c.conn.WriteJSON(msg)
+}
+
+func (c *wsClient) sendError(id string, err *wsError) {
+	c.send(WebSocketMessage{
+		Type:    wsTypeError,
+		ID:      id,
+		Data:    map[string]string{"code": err.Code},
+		Message: err.Message,
+	})
+}
+
+func (c *wsClient) subscribed(topics ...string) bool {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+
+	for _, topic := range topics {
+		if c.topics[topic] {
+			return true
+		}
+	}
+	return false
+}
+
+// auditRun is an audit started over the WebSocket
+type auditRun struct {
+	id       string
+	filename string
+	scope    aegong.AuditScope
+	cancel   context.CancelFunc
+	tenant   string
+	release  func() // Frees the audit's place in its tenant's quota
+}
+
+// wsHub tracks connected clients and running audits, and forwards bus
+// events to the clients subscribed to them
+type wsHub struct {
+	mutex   sync.RWMutex
+	clients map[eventSubscriber]bool
+	audits  map[string]*auditRun
+	bus     *eventBus
+	// Runs the audit; replaced in tests
+	audit func(ctx context.Context, filename string, opts auditOptions) (*aegong.AuditReport, error)
+}
+
+func newWSHub(bus *eventBus) *wsHub {
+	h := &wsHub{
+		clients: make(map[eventSubscriber]bool),
+		audits:  make(map[string]*auditRun),
+		bus:     bus,
+		audit:   runAudit,
+	}
+	bus.subscribe(h.forward)
+	return h
+}
+
+var hub = newWSHub(bus)
+
+func (h *wsHub) addClient(client eventSubscriber) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.clients[client] = true
+}
+
+func (h *wsHub) removeClient(client eventSubscriber) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.clients, client)
+}
+
+// publish sends an audit event to every client subscribed to it
+func (h *wsHub) publish(auditID string, msg WebSocketMessage) {
+	h.mutex.RLock()
+	var targets []eventSubscriber
+	for client := range h.clients {
+		if client.subscribed(wsTopicAudits, "audit:"+auditID) {
+			targets = append(targets, client)
+		}
+	}
+	h.mutex.RUnlock()
+
+	for _, client := range targets {
+		client.send(msg)
+	}
+}
+
+func websocketHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Print("upgrade failed: ", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(wsMaxMessageSize)
+
+	// Audits started by this client stop when it disconnects
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	client := &wsClient{
+		conn:   conn,
+		ctx:    ctx,
+		topics: make(map[string]bool),
+		audits: make(map[string]bool),
+		tenant: quotaTenant(r),
+	}
+	// /ws is public, but starting audits is an upload action
+	client.canAudit, client.addr = netPolicy.allowedIn(r, groupUpload)
+	hub.addClient(client)
+	defer hub.removeClient(client)
+
+	// Send welcome message
+	client.send(WebSocketMessage{
+		Type:    wsTypeAegongMessage,
+		Message: "🤖 Aegong awakens! The Agent Auditor is ready to inspect your digital minions...",
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		hub.handleCommand(client, data)
+	}
+}
+
+// handleCommand validates and dispatches a single client command
+func (h *wsHub) handleCommand(client *wsClient, data []byte) {
+	var cmd wsCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		client.sendError("", &wsError{wsErrInvalidJSON, fmt.Sprintf("invalid JSON: %v", err)})
+		return
+	}
+
+	var reply *WebSocketMessage
+	var err *wsError
+	switch cmd.Type {
+	case wsTypePing:
+		reply = &WebSocketMessage{Type: wsTypePong}
+	case wsTypeSubscribe, wsTypeUnsubscribe:
+		reply, err = h.handleSubscription(client, cmd)
+	case wsTypeStartAudit:
+		reply, err = h.handleStartAudit(client, cmd)
+	case wsTypeCancel:
+		reply, err = h.handleCancel(client, cmd)
+	case "":
+		err = &wsError{wsErrInvalidData, "message type is required"}
+	default:
+		err = &wsError{wsErrUnknownType, fmt.Sprintf("unknown message type %q", cmd.Type)}
+	}
+
+	if err != nil {
+		client.sendError(cmd.ID, err)
+		return
+	}
+	reply.ID = cmd.ID
+	client.send(*reply)
+}
+
+// decodeCommandData strictly decodes a command payload into v
+func decodeCommandData(cmd wsCommand, v interface{}) *wsError {
+	if len(cmd.Data) == 0 {
+		return &wsError{wsErrInvalidData, fmt.Sprintf("%s requires data", cmd.Type)}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(cmd.Data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return &wsError{wsErrInvalidData, fmt.Sprintf("invalid %s data: %v", cmd.Type, err)}
+	}
+	return nil
+}
+
+func validTopic(topic string) bool {
+	return topic == wsTopicAudits || (strings.HasPrefix(topic, "audit:") && len(topic) > len("audit:"))
+}
+
+func (h *wsHub) handleSubscription(client *wsClient, cmd wsCommand) (*WebSocketMessage, *wsError) {
+	var data wsTopicData
+	if err := decodeCommandData(cmd, &data); err != nil {
+		return nil, err
+	}
+	if !validTopic(data.Topic) {
+		return nil, &wsError{wsErrInvalidData, fmt.Sprintf("invalid topic %q", data.Topic)}
+	}
+
+	client.subMu.Lock()
+	if cmd.Type == wsTypeSubscribe {
+		client.topics[data.Topic] = true
+	} else {
+		delete(client.topics, data.Topic)
+	}
+	client.subMu.Unlock()
+
+	return &WebSocketMessage{Type: wsTypeAck, Data: data}, nil
+}
+
+func (h *wsHub) handleStartAudit(client *wsClient, cmd wsCommand) (*WebSocketMessage, *wsError) {
+	if !client.canAudit {
+		log.Printf("Warning: Denied WebSocket start_audit from %s, not in the %s allowlist", client.addr, groupUpload)
+		return nil, &wsError{wsErrForbidden, "starting audits from your network is not allowed"}
+	}
+	var data wsStartAuditData
+	if err := decodeCommandData(cmd, &data); err != nil {
+		return nil, err
+	}
+	filePath, err := uploadPath(data.Filename)
+	if err != nil {
+		return nil, &wsError{wsErrInvalidData, "filename must name an uploaded file"}
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		return nil, &wsError{wsErrNotFound, fmt.Sprintf("upload %q not found", data.Filename)}
+	}
+	if err := engine.CheckScope(data.Options); err != nil {
+		return nil, &wsError{wsErrInvalidData, err.Error()}
+	}
+	release, err := quotas.admitAudit(client.tenant)
+	if err != nil {
+		return nil, &wsError{wsErrQuota, err.Error()}
+	}
+
+	run := &auditRun{
+		id:       randomID(),
+		filename: data.Filename,
+		scope:    data.Options,
+		tenant:   client.tenant,
+		release:  release,
+	}
+
+	ctx, cancel := context.WithCancel(client.ctx)
+	run.cancel = cancel
+
+	h.mutex.Lock()
+	h.audits[run.id] = run
+	h.mutex.Unlock()
+
+	client.auditsMu.Lock()
+	client.audits[run.id] = true
+	client.auditsMu.Unlock()
+
+	// The starting client always hears about its own audit
+	client.subMu.Lock()
+	client.topics["audit:"+run.id] = true
+	client.subMu.Unlock()
+
+	go h.runAudit(ctx, run)
+
+	return &WebSocketMessage{
+		Type: wsTypeAck,
+		Data: map[string]string{"audit_id": run.id},
+	}, nil
+}
+
+// runAudit executes an audit and publishes its lifecycle events
+func (h *wsHub) runAudit(ctx context.Context, run *auditRun) {
+	defer func() {
+		run.cancel()
+		run.release()
+		h.mutex.Lock()
+		delete(h.audits, run.id)
+		h.mutex.Unlock()
+	}()
+
+	ctx = withCorrelationID(ctx, run.id)
+	ctx = withTenant(ctx, run.tenant)
+	ctx = h.bus.auditStarted(ctx, run.id, run.filename)
+	report, err := h.audit(ctx, run.filename, auditOptions{Scope: run.scope, Tenant: run.tenant})
+	h.bus.auditFinished(ctx, run.id, run.filename, report, err)
+}
+
+// forward turns a bus event into a message for the clients subscribed to it;
+// events without an audit only reach the "audits" topic
+func (h *wsHub) forward(event busEvent) {
+	msgType, ok := wsEventTypes[event.Type]
+	if !ok {
+		return
+	}
+
+	data := map[string]interface{}{}
+	if event.AuditID != "" {
+		data["audit_id"] = event.AuditID
+	}
+	if event.CorrelationID != "" {
+		data["correlation_id"] = event.CorrelationID
+	}
+	if event.Filename != "" {
+		data["filename"] = event.Filename
+	}
+	var message string
+	switch event.Type {
+	case eventAuditPhase:
+		data["phase"] = event.Phase
+	case eventThreatFound:
+		data["threat"] = event.Threat
+	case eventAuditFailed:
+		if notAgent, ok := event.Err.(*notAgentError); ok {
+			data["validation"] = notAgent.validation
+		}
+		message = event.Err.Error()
+	case eventAuditCompleted:
+		data["report"] = event.Report
+		message = event.Report.AegongMessage
+	case eventVoiceReady:
+		data["report_hash"] = event.ReportHash
+		data["audio_url"] = event.AudioURL
+	}
+	h.publish(event.AuditID, WebSocketMessage{Type: msgType, Data: data, Message: message})
+}
+
+func (h *wsHub) handleCancel(client *wsClient, cmd wsCommand) (*WebSocketMessage, *wsError) {
+	var data wsCancelData
+	if err := decodeCommandData(cmd, &data); err != nil {
+		return nil, err
+	}
+
+	// Clients may only cancel the audits they started
+	client.auditsMu.Lock()
+	owned := client.audits[data.AuditID]
+	client.auditsMu.Unlock()
+
+	h.mutex.RLock()
+	run, running := h.audits[data.AuditID]
+	h.mutex.RUnlock()
+
+	if !owned || !running {
+		return nil, &wsError{wsErrNotFound, fmt.Sprintf("no running audit %q", data.AuditID)}
+	}
+
+	run.cancel()
+	return &WebSocketMessage{Type: wsTypeAck, Data: data}, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"Agent_Auditor/pkg/aegong"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialTestHub starts a WebSocket server backed by a fresh hub and connects to it
+func dialTestHub(t *testing.T, audit func(ctx context.Context, filename string, opts auditOptions) (*aegong.AuditReport, error)) *websocket.Conn {
+	oldHub := hub
+	hub = newWSHub(newEventBus())
+	hub.audit = audit
+	t.Cleanup(func() { hub = oldHub })
+
+	server := httptest.NewServer(http.HandlerFunc(websocketHandler))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	// Skip the welcome message
+	readTestMessage(t, conn)
+	return conn
+}
+
+func readTestMessage(t *testing.T, conn *websocket.Conn) WebSocketMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg WebSocketMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	return msg
+}
+
+// withTestUpload runs the test from a directory containing uploads/agent.py
+func withTestUpload(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "uploads"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "uploads", "agent.py"), []byte("print('hi')\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// TestWebSocketValidation tests that malformed commands get error replies
+func TestWebSocketValidation(t *testing.T) {
+	conn := dialTestHub(t, nil)
+
+	cases := []struct {
+		command string
+		code    string
+	}{
+		{`not json`, wsErrInvalidJSON},
+		{`{"type":"dance","id":"1"}`, wsErrUnknownType},
+		{`{"type":"subscribe","id":"2"}`, wsErrInvalidData},
+		{`{"type":"subscribe","id":"3","data":{"topic":"nope"}}`, wsErrInvalidData},
+		{`{"type":"subscribe","id":"4","data":{"topic":"audits","extra":1}}`, wsErrInvalidData},
+		{`{"type":"start_audit","id":"5","data":{"filename":"../main.go"}}`, wsErrInvalidData},
+		{`{"type":"cancel","id":"6","data":{"audit_id":"missing"}}`, wsErrNotFound},
+	}
+
+	for _, c := range cases {
+		conn.WriteMessage(websocket.TextMessage, []byte(c.command))
+		msg := readTestMessage(t, conn)
+		if msg.Type != wsTypeError {
+			t.Fatalf("Command %s should be rejected, got %q", c.command, msg.Type)
+		}
+		data, _ := msg.Data.(map[string]interface{})
+		if data["code"] != c.code {
+			t.Fatalf("Command %s should fail with %q, got %v", c.command, c.code, data["code"])
+		}
+	}
+
+	conn.WriteJSON(map[string]string{"type": "ping", "id": "7"})
+	if msg := readTestMessage(t, conn); msg.Type != wsTypePong || msg.ID != "7" {
+		t.Fatalf("Ping should be answered with a matching pong, got %+v", msg)
+	}
+}
+
+// TestWebSocketUploadAllowlist tests that only clients in the upload allowlist can start audits
+func TestWebSocketUploadAllowlist(t *testing.T) {
+	withTestUpload(t)
+	oldPolicy := netPolicy
+	t.Cleanup(func() { netPolicy = oldPolicy })
+	t.Setenv("AEGONG_ALLOW_UPLOAD", "10.0.0.0/8")
+	if err := initNetworkPolicy(); err != nil {
+		t.Fatal(err)
+	}
+
+	audited := false
+	conn := dialTestHub(t, func(ctx context.Context, filename string, opts auditOptions) (*aegong.AuditReport, error) {
+		audited = true
+		return nil, ctx.Err()
+	})
+	conn.WriteJSON(map[string]interface{}{"type": "start_audit", "id": "a", "data": map[string]string{"filename": "agent.py"}})
+	msg := readTestMessage(t, conn)
+	data, _ := msg.Data.(map[string]interface{})
+	if msg.Type != wsTypeError || data["code"] != wsErrForbidden || audited {
+		t.Fatalf("Should refuse to start an audit from outside the upload allowlist, got %+v", msg)
+	}
+
+	// Other commands are still answered
+	conn.WriteJSON(map[string]string{"type": "ping", "id": "b"})
+	if msg := readTestMessage(t, conn); msg.Type != wsTypePong {
+		t.Fatalf("Should answer a ping, got %+v", msg)
+	}
+}
+
+// TestWebSocketAuditLifecycle tests starting and cancelling an audit
+func TestWebSocketAuditLifecycle(t *testing.T) {
+	withTestUpload(t)
+
+	conn := dialTestHub(t, func(ctx context.Context, filename string, opts auditOptions) (*aegong.AuditReport, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	conn.WriteJSON(map[string]interface{}{
+		"type": "start_audit",
+		"id":   "a",
+		"data": map[string]string{"filename": "agent.py"},
+	})
+
+	// The ack and the started event may arrive in either order
+	var auditID string
+	var started bool
+	for auditID == "" || !started {
+		msg := readTestMessage(t, conn)
+		switch msg.Type {
+		case wsTypeAck:
+			if msg.ID != "a" {
+				t.Fatalf("Ack should carry the command id, got %q", msg.ID)
+			}
+			data, _ := msg.Data.(map[string]interface{})
+			auditID, _ = data["audit_id"].(string)
+		case wsTypeAuditStarted:
+			started = true
+		default:
+			t.Fatalf("Unexpected message %+v", msg)
+		}
+	}
+
+	conn.WriteJSON(map[string]interface{}{
+		"type": "cancel",
+		"id":   "b",
+		"data": map[string]string{"audit_id": auditID},
+	})
+
+	var acked, cancelled bool
+	for !acked || !cancelled {
+		msg := readTestMessage(t, conn)
+		switch msg.Type {
+		case wsTypeAck:
+			acked = true
+		case wsTypeAuditCancelled:
+			cancelled = true
+		default:
+			t.Fatalf("Unexpected message %+v", msg)
+		}
+	}
+}